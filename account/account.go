@@ -0,0 +1,159 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package account extracts the accounts signed in to browser profiles
+// into a common model, so that profiles can be grouped by owner.
+package account
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/firefox"
+)
+
+// Account is an account signed in to a browser profile.
+type Account struct {
+	Provider Provider
+	ID       string // provider account ID, e.g. Gaia ID or Firefox Accounts UID
+	Email    string
+	Name     string
+	Browser  string // e.g. "chrome", "edge", "firefox"
+	Profile  string // profile directory; for Chrome, its name in Local State, e.g. "Default"
+}
+
+// Provider is the identity provider of an account.
+type Provider string
+
+// Values for Provider:
+const (
+	Google    Provider = "google"
+	Microsoft Provider = "microsoft"
+	Firefox   Provider = "firefox"
+)
+
+// Browsers for Account:
+const (
+	BrowserChrome  = "chrome"
+	BrowserEdge    = "edge"
+	BrowserFirefox = "firefox"
+)
+
+// FromChromePreferences extracts the Google accounts in the Preferences
+// of a Chrome profile.
+func FromChromePreferences(p *chrome.Preferences, browser, profileDir string) []Account {
+	accounts := make([]Account, 0, len(p.AccountInfo))
+	for _, info := range p.AccountInfo {
+		id := info.Gaia
+		if id == "" {
+			id = info.AccountID
+		}
+		accounts = append(accounts, Account{
+			Provider: Google,
+			ID:       id,
+			Email:    info.Email,
+			Name:     info.FullName,
+			Browser:  browser,
+			Profile:  profileDir,
+		})
+	}
+	return accounts
+}
+
+// FromChromeLocalState extracts the signed-in accounts of the profiles
+// listed in Local State. For Edge, accounts without a Gaia ID are
+// Microsoft accounts.
+func FromChromeLocalState(s *chrome.LocalState, browser string) []Account {
+	var accounts []Account
+	for dir, info := range s.Profile.InfoCache {
+		if info.UserName == "" {
+			continue
+		}
+		a := Account{
+			Provider: Google,
+			ID:       info.GAIAID,
+			Email:    info.UserName,
+			Name:     info.GAIAName,
+			Browser:  browser,
+			Profile:  dir,
+		}
+		if browser == BrowserEdge && info.GAIAID == "" {
+			a.Provider = Microsoft
+		}
+		accounts = append(accounts, a)
+	}
+	sortAccounts(accounts)
+	return accounts
+}
+
+// FromFirefoxSignedInUser extracts the Firefox Account signed in for
+// Sync in a Firefox profile.
+func FromFirefoxSignedInUser(u *firefox.SignedInUser, profileDir string) Account {
+	return Account{
+		Provider: Firefox,
+		ID:       u.AccountData.UID,
+		Email:    u.AccountData.Email,
+		Name:     u.AccountData.DisplayName(),
+		Browser:  BrowserFirefox,
+		Profile:  profileDir,
+	}
+}
+
+// Key identifies the owner of an account. Accounts with the same email
+// are considered to have the same owner, even across providers, since a
+// Firefox Account, for example, is often registered with a Gmail
+// address.
+func (a *Account) Key() string {
+	if a.Email != "" {
+		return strings.ToLower(a.Email)
+	}
+	return string(a.Provider) + ":" + a.ID
+}
+
+// Group groups accounts by owner, as given by Account.Key. Duplicate
+// accounts for the same profile, such as from both Preferences and
+// Local State, are merged, preferring fields from the first.
+func Group(accounts []Account) map[string][]Account {
+	groups := make(map[string][]Account)
+	for _, a := range accounts {
+		key := a.Key()
+		merged := false
+		for i := range groups[key] {
+			g := &groups[key][i]
+			if g.Browser == a.Browser && g.Profile == a.Profile && g.Provider == a.Provider {
+				if g.ID == "" {
+					g.ID = a.ID
+				}
+				if g.Name == "" {
+					g.Name = a.Name
+				}
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			groups[key] = append(groups[key], a)
+		}
+	}
+	for _, g := range groups {
+		sortAccounts(g)
+	}
+	return groups
+}
+
+func sortAccounts(accounts []Account) {
+	sort.SliceStable(accounts, func(i, j int) bool {
+		a, b := &accounts[i], &accounts[j]
+		if a.Browser != b.Browser {
+			return a.Browser < b.Browser
+		}
+		if a.Profile != b.Profile {
+			return a.Profile < b.Profile
+		}
+		return a.Email < b.Email
+	})
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package account
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/firefox"
+)
+
+func TestGroup(t *testing.T) {
+	prefs := &chrome.Preferences{AccountInfo: []chrome.AccountInfo{
+		{AccountID: "123", Gaia: "123", Email: "User@gmail.com", FullName: "User"},
+	}}
+	state := &chrome.LocalState{Profile: chrome.LocalStateProfile{InfoCache: map[string]chrome.ProfileInfo{
+		"Default":   {Name: "Person 1", UserName: "user@gmail.com", GAIAID: "123"},
+		"Profile 1": {Name: "Work", UserName: "user@example.com"},
+		"Profile 2": {Name: "Guest"},
+	}}}
+	user := &firefox.SignedInUser{AccountData: firefox.AccountData{UID: "abc", Email: "user@gmail.com"}}
+
+	var accounts []Account
+	accounts = append(accounts, FromChromePreferences(prefs, BrowserChrome, "Default")...)
+	accounts = append(accounts, FromChromeLocalState(state, BrowserChrome)...)
+	accounts = append(accounts, FromFirefoxSignedInUser(user, "abcdefgh.default"))
+	groups := Group(accounts)
+
+	want := map[string][]Account{
+		"user@gmail.com": {
+			{Google, "123", "User@gmail.com", "User", BrowserChrome, "Default"},
+			{Firefox, "abc", "user@gmail.com", "", BrowserFirefox, "abcdefgh.default"},
+		},
+		"user@example.com": {
+			{Google, "", "user@example.com", "", BrowserChrome, "Profile 1"},
+		},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("got: %v, want: %v", groups, want)
+	}
+}
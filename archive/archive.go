@@ -0,0 +1,651 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package archive archives the browsing data of all Chrome and Firefox
+// profiles on a machine with a single call.
+//
+// An archive is a directory named by its creation time, e.g.
+// "browser-archive-20210218T150405Z", containing:
+//
+//	manifest.json    profiles, artifacts, errors, and output checksums
+//	artifacts.jsonl  every parsed artifact, one JSON record per line
+//	history.jsonl    visits and downloads in the history wire format
+//	archive.sqlite   visits, downloads, and bookmarks as SQL tables
+//	SHA256SUMS       checksums of the other files, for sha256sum -c
+//
+// When Archiver.Recipients is set, the outputs other than the manifest
+// are encrypted to them and named with the ".enc" suffix, for storing
+// archives where others can read them, such as in cloud storage. The
+// manifest is not encrypted, so it reveals the machine, profile paths,
+// and names of artifacts, but not their contents. When
+// Archiver.SigningKey is set, the manifest is signed in
+// "manifest.json.sig", and Verify checks the signature and checksums.
+//
+// Archives are labeled with the machine they were collected on, so that
+// archives from several computers can be combined with Merge.
+//
+// Visits and downloads are written in a deterministic order, by time,
+// then URL, within each profile, so that archives of the same data are
+// identical and can be diffed across runs. This is configured by
+// Archiver.Order.
+//
+// By default, an artifact that fails to parse is recorded with its
+// error in the manifest and does not stop the archive, so that one
+// corrupt or unsupported file does not lose the rest of the data. This
+// is configured by Archiver.OnError.
+package archive
+
+import (
+	"bufio"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/bookmark"
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/firefox"
+	"github.com/andrewarchi/browser/history"
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+// Format is the value of Manifest.Format.
+const Format = "browser-archive"
+
+// Version is the version of the archive layout written by Archive.
+// Version 2 added visit IDs to archive.sqlite, at schema version 2.
+// Archives of older versions are upgraded by Migrate.
+const Version = 2
+
+// Output files in an archive:
+const (
+	ManifestFile  = "manifest.json"
+	ArtifactsFile = "artifacts.jsonl"
+	HistoryFile   = "history.jsonl"
+	SQLiteFile    = "archive.sqlite"
+)
+
+// Browsers in the manifest:
+const (
+	BrowserChrome  = "chrome"
+	BrowserFirefox = "firefox"
+	BrowserImport  = "import" // history imported from another tool by Import
+)
+
+// Archiver archives browsing data. The zero value archives the profiles
+// in the default locations for the current user.
+type Archiver struct {
+	FirefoxDir string           // Firefox root or profiles directory; defaults to firefox.Dir
+	ChromeDir  string           // Chrome user data directory; defaults to chrome.UserDataDir
+	Machine    string           // label for this machine; defaults to the hostname
+	Now        func() time.Time // defaults to time.Now
+
+	// Forensic also recovers deleted history from the unallocated pages
+	// of history databases. Recovered visits are marked with
+	// history.TrustRecovered, since they may be stale or incomplete.
+	Forensic bool
+
+	// SpillDir is a directory for temporary files when merging history
+	// that does not fit in memory. When empty, Merge holds all visits in
+	// memory.
+	SpillDir string
+
+	// Order is the order of visits and downloads in history.jsonl and
+	// archive.sqlite. With history.OrderTime, the default, they are
+	// ordered by time, then URL, within each profile, and with
+	// history.OrderInput, in the order that they were read. Merge always
+	// orders visits by time, then URL, as it deduplicates them.
+	Order history.Order
+
+	// OnError handles artifacts that fail to parse and is passed to the
+	// parsers of artifacts of several files. With browser.Collect, the
+	// default, errors are recorded in the manifest and the data that was
+	// read is still archived; with browser.FailFast, the first error
+	// stops the archive; and otherwise, failed files and artifacts are
+	// omitted.
+	OnError browser.ErrorPolicy
+
+	Recipients []*ecdh.PublicKey  // encrypt outputs to these X25519 keys, if any
+	SigningKey ed25519.PrivateKey // sign the manifest, if set
+}
+
+// Manifest describes the contents of an archive.
+type Manifest struct {
+	Format   string            `json:"format"`
+	Version  int               `json:"version"`
+	Created  time.Time         `json:"created"`
+	Machine  string            `json:"machine,omitempty"` // empty for merged archives
+	TimeZone *TimeZone         `json:"time_zone,omitempty"`
+	Dir      string            `json:"-"`                 // path of the archive directory
+	Sources  []Source          `json:"sources,omitempty"` // archives combined by Merge
+	Profiles []ManifestProfile `json:"profiles"`
+	Files    []OutputFile      `json:"files"`
+
+	Retention  []RetentionRecord `json:"retention,omitempty"`  // policies applied by Prune
+	Migrations []MigrationRecord `json:"migrations,omitempty"` // upgrades by Migrate
+}
+
+// TimeZone is the local time zone of a machine when it was archived.
+// Times in archives are in UTC, so the zone is only needed to display
+// times as they were seen on that machine.
+type TimeZone struct {
+	Name   string `json:"name"`   // IANA name, when known, or abbreviation, e.g. "America/New_York" or "EST"
+	Offset int    `json:"offset"` // seconds east of UTC
+}
+
+// ManifestProfile is an archived browser profile, or the browser root
+// for data shared by all profiles, such as Chrome "Local State".
+type ManifestProfile struct {
+	Machine   string             `json:"machine,omitempty"`
+	Browser   string             `json:"browser"`
+	Path      string             `json:"path"`
+	Artifacts []ManifestArtifact `json:"artifacts"`
+}
+
+// ManifestArtifact is an artifact found in a profile. Error is set when
+// it could not be parsed.
+type ManifestArtifact struct {
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+// OutputFile is an output file in the archive.
+type OutputFile struct {
+	Name      string       `json:"name"`
+	Size      int64        `json:"size"`
+	SHA256    jsonutil.Hex `json:"sha256"`
+	Encrypted bool         `json:"encrypted,omitempty"` // encrypted by Encrypt, with EncryptedSuffix
+}
+
+// Archive writes an archive of all profiles into a new timestamped
+// directory in dir using the default Archiver.
+func Archive(dir string) (*Manifest, error) {
+	var a Archiver
+	return a.Archive(dir)
+}
+
+// artifactRecord is a line in artifacts.jsonl.
+type artifactRecord struct {
+	Machine  string          `json:"machine,omitempty"`
+	Browser  string          `json:"browser"`
+	Profile  string          `json:"profile"`
+	Artifact string          `json:"artifact"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// collected is the data collected from a profile that is also written
+// to history.jsonl and archive.sqlite.
+type collected struct {
+	Visits    []history.Visit
+	Downloads []history.Download
+	Bookmarks []bookmark.BookmarkEntry
+	Forensic  bool            // recover deleted records
+	Options   browser.Options // for parsers of several files
+}
+
+// Archive writes an archive of all profiles into a new timestamped
+// directory in dir.
+func (a *Archiver) Archive(dir string) (*Manifest, error) {
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	machine, err := a.machine()
+	if err != nil {
+		return nil, err
+	}
+	created := now()
+	m := &Manifest{
+		Format:   Format,
+		Version:  Version,
+		Created:  created.UTC().Truncate(time.Second),
+		Machine:  machine,
+		TimeZone: timeZone(created),
+		Profiles: []ManifestProfile{},
+	}
+	m.Dir = filepath.Join(dir, "browser-archive-"+m.Created.Format("20060102T150405Z"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.Mkdir(m.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+
+	artifacts, err := os.Create(filepath.Join(m.Dir, ArtifactsFile))
+	if err != nil {
+		return nil, err
+	}
+	defer artifacts.Close()
+	w := &artifactWriter{w: bufio.NewWriter(artifacts)}
+	db, err := createDB(filepath.Join(m.Dir, SQLiteFile))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	hist, err := os.Create(filepath.Join(m.Dir, HistoryFile))
+	if err != nil {
+		return nil, err
+	}
+	defer hist.Close()
+	enc := history.NewEncoder(hist)
+
+	profiles, err := a.profiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range profiles {
+		mp := ManifestProfile{Machine: machine, Browser: p.Browser, Path: p.Path}
+		c := collected{Forensic: a.Forensic, Options: browser.Options{OnError: a.OnError}}
+		for _, art := range p.Artifacts {
+			if !art.exists(p.Path) {
+				continue
+			}
+			ma := ManifestArtifact{Name: art.Name}
+			data, err := art.Parse(p.Path, &c)
+			if err == nil || partial(err) {
+				// The data that was read is written, even with the errors
+				// collected while reading it.
+				b, merr := json.Marshal(data)
+				if merr != nil {
+					err = merr
+				} else if err := w.write(&artifactRecord{machine, p.Browser, p.Path, art.Name, b}); err != nil {
+					return nil, err
+				}
+			}
+			if err != nil {
+				switch a.OnError {
+				case browser.FailFast:
+					return nil, fmt.Errorf("archive: %s: %s: %w", p.Path, art.Name, err)
+				case browser.SkipRecord, browser.SkipFile:
+					continue
+				}
+				ma.Error = err.Error()
+			}
+			mp.Artifacts = append(mp.Artifacts, ma)
+		}
+		if len(mp.Artifacts) == 0 {
+			continue
+		}
+		a.sortHistory(c.Visits, c.Downloads)
+		for i := range c.Visits {
+			// Visits in local history were recorded on this machine.
+			if c.Visits[i].Device == "" {
+				c.Visits[i].Device = machine
+			}
+			if err := enc.EncodeVisit(&c.Visits[i]); err != nil {
+				return nil, err
+			}
+		}
+		for i := range c.Downloads {
+			if err := enc.EncodeDownload(&c.Downloads[i]); err != nil {
+				return nil, err
+			}
+		}
+		if err := db.insertProfile(&mp, &c); err != nil {
+			return nil, fmt.Errorf("archive: %s: %w", SQLiteFile, err)
+		}
+		m.Profiles = append(m.Profiles, mp)
+	}
+
+	if err := w.w.Flush(); err != nil {
+		return nil, err
+	}
+	if err := artifacts.Close(); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	if err := hist.Close(); err != nil {
+		return nil, err
+	}
+	if err := db.Close(); err != nil {
+		return nil, err
+	}
+	if err := a.finish(m, []string{ArtifactsFile, HistoryFile, SQLiteFile}); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// sortHistory orders visits and downloads by a.Order.
+func (a *Archiver) sortHistory(visits []history.Visit, downloads []history.Download) {
+	if a.Order == history.OrderTime {
+		history.SortVisits(visits)
+		history.SortDownloads(downloads)
+	}
+}
+
+// machine returns the label for this machine.
+func (a *Archiver) machine() (string, error) {
+	if a.Machine != "" {
+		return a.Machine, nil
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("archive: machine label: %w", err)
+	}
+	return hostname, nil
+}
+
+type artifactWriter struct {
+	w *bufio.Writer
+}
+
+func (w *artifactWriter) write(r *artifactRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := w.w.Write(b); err != nil {
+		return err
+	}
+	return w.w.WriteByte('\n')
+}
+
+func timeZone(t time.Time) *TimeZone {
+	name, offset := t.Zone()
+	if loc := t.Location().String(); loc != "Local" {
+		name = loc
+	}
+	return &TimeZone{name, offset}
+}
+
+func checksumFile(dir, name string) (*OutputFile, error) {
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return nil, err
+	}
+	return &OutputFile{Name: name, Size: n, SHA256: h.Sum(nil)}, nil
+}
+
+func writeManifest(filename string, m *Manifest) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(b, '\n'), 0o644)
+}
+
+// profile is a discovered profile and the artifacts to parse in it.
+type profile struct {
+	Browser   string
+	Path      string
+	Artifacts []artifact
+}
+
+// artifact is a file or directory in a profile, relative to the
+// profile, and its parser. It is parsed when Name or any of Alt exist.
+// Parse may add data to c.
+type artifact struct {
+	Name  string
+	Parse func(dir string, c *collected) (interface{}, error)
+	Alt   []string
+}
+
+func (a *artifact) exists(dir string) bool {
+	for _, name := range append([]string{a.Name}, a.Alt...) {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// profiles discovers the Firefox and Chrome profiles. A missing browser
+// directory is skipped. Firefox profiles are listed in profiles.ini or,
+// when it is missing, are the subdirectories of the Firefox directory.
+func (a *Archiver) profiles() ([]profile, error) {
+	var profiles []profile
+
+	firefoxDir := a.FirefoxDir
+	if firefoxDir == "" {
+		if dir, err := firefox.Dir(); err == nil {
+			firefoxDir = dir
+		}
+	}
+	if firefoxDir != "" {
+		entries, err := firefox.ListProfiles(firefoxDir)
+		var dirs []string
+		if errors.Is(err, os.ErrNotExist) {
+			if dirs, err = subdirs(firefoxDir); err != nil {
+				return nil, err
+			}
+		} else if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			dirs = append(dirs, e.Dir)
+		}
+		for _, dir := range dirs {
+			profiles = append(profiles, profile{BrowserFirefox, dir, firefoxArtifacts})
+		}
+	}
+
+	chromeDir := a.ChromeDir
+	if chromeDir == "" {
+		if dir, err := chrome.UserDataDir(); err == nil {
+			chromeDir = dir
+		}
+	}
+	if chromeDir != "" {
+		if _, err := os.Stat(chromeDir); err == nil {
+			profiles = append(profiles, profile{BrowserChrome, chromeDir, chromeRootArtifacts})
+		}
+		state, err := chrome.ParseLocalState(filepath.Join(chromeDir, "Local State"))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if state != nil {
+			names := make([]string, 0, len(state.Profile.InfoCache))
+			for name := range state.Profile.InfoCache {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				profiles = append(profiles, profile{BrowserChrome, filepath.Join(chromeDir, name), chromeArtifacts})
+			}
+		}
+	}
+	return profiles, nil
+}
+
+// subdirs returns the subdirectories of dir, ordered by name, or none
+// if dir does not exist.
+func subdirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() {
+			dirs = append(dirs, filepath.Join(dir, e.Name()))
+		}
+	}
+	return dirs, nil
+}
+
+// partial reports whether err is the errors collected under
+// browser.Collect, which are returned with the data that was read.
+func partial(err error) bool {
+	var errs browser.Errors
+	return errors.As(err, &errs)
+}
+
+// parseFile adapts a parser of a single file in a profile.
+func parseFile(name string, parse func(filename string) (interface{}, error)) artifact {
+	return artifact{Name: name, Parse: func(dir string, _ *collected) (interface{}, error) {
+		return parse(filepath.Join(dir, name))
+	}}
+}
+
+var firefoxArtifacts = []artifact{
+	parseFile("addonStartup.json.lz4", func(f string) (interface{}, error) { return firefox.ParseAddonStartup(f) }),
+	parseFile("addons.json", func(f string) (interface{}, error) { return firefox.ParseAddons(f) }),
+	{"blocklist-addons.json", func(dir string, _ *collected) (interface{}, error) {
+		return firefox.ParseAddonBlocklist(filepath.Join(dir, "blocklist-addons.json"))
+	}, nil},
+	parseFile("autofill-profiles.json", func(f string) (interface{}, error) { return firefox.ParseAutofillProfiles(f) }),
+	parseFile("broadcast-listeners.json", func(f string) (interface{}, error) { return firefox.ParseBroadcastListeners(f) }),
+	parseFile("cert_override.txt", func(f string) (interface{}, error) { return firefox.ParseCertOverrides(f) }),
+	parseFile("compatibility.ini", func(f string) (interface{}, error) { return firefox.ParseCompatibility(f) }),
+	parseFile("containers.json", func(f string) (interface{}, error) { return firefox.ParseContainers(f) }),
+	parseFile("content-prefs.sqlite", func(f string) (interface{}, error) { return firefox.ParseContentPrefs(f) }),
+	{"datareporting", func(dir string, c *collected) (interface{}, error) {
+		return firefox.ProfileTelemetryOptions(dir, c.Options)
+	}, []string{"saved-telemetry-pings"}},
+	{"downloads.json", func(dir string, c *collected) (interface{}, error) {
+		downloads, err := firefox.ProfileDownloadsOptions(dir, c.Options)
+		if err != nil && !partial(err) {
+			return nil, err
+		}
+		c.Downloads = append(c.Downloads, history.FromFirefoxDownloads(downloads)...)
+		return downloads, err
+	}, []string{"downloads.sqlite", "places.sqlite"}},
+	parseFile("enumerate_devices.txt", func(f string) (interface{}, error) { return firefox.ParseEnumerateDevices(f) }),
+	parseFile("extension-preferences.json", func(f string) (interface{}, error) { return firefox.ParseExtensionPreferences(f) }),
+	parseFile("extension-settings.json", func(f string) (interface{}, error) { return firefox.ParseExtensionSettings(f) }),
+	parseFile("extensions.json", func(f string) (interface{}, error) { return firefox.ParseExtensions(f) }),
+	parseFile("formhistory.sqlite", func(f string) (interface{}, error) { return firefox.ParseFormHistory(f) }),
+	parseFile("handlers.json", func(f string) (interface{}, error) { return firefox.ParseHandlers(f) }),
+	parseFile("logins.json", func(f string) (interface{}, error) { return firefox.ParseLogins(f) }),
+	parseFile("notificationstore.json", func(f string) (interface{}, error) { return firefox.ParseNotificationStore(f) }),
+	{"places.sqlite", func(dir string, c *collected) (interface{}, error) {
+		bookmarks, err := firefox.ParsePlacesBookmarks(filepath.Join(dir, "places.sqlite"))
+		if err != nil {
+			return nil, err
+		}
+		c.Bookmarks = append(c.Bookmarks, bookmarks...)
+		visits, err := firefox.ParsePlacesVisits(filepath.Join(dir, "places.sqlite"))
+		if err != nil {
+			return nil, err
+		}
+		c.Visits = append(c.Visits, history.FromFirefoxPlaces(visits)...)
+		places := struct {
+			Bookmarks []bookmark.BookmarkEntry `json:"bookmarks"`
+			Visits    []firefox.Visit          `json:"visits"`
+			Recovered []firefox.RecoveredPlace `json:"recovered,omitempty"`
+		}{Bookmarks: bookmarks, Visits: visits}
+		if !c.Forensic {
+			return places, nil
+		}
+		places.Recovered, err = firefox.RecoverPlaces(filepath.Join(dir, "places.sqlite"))
+		if err != nil {
+			return nil, err
+		}
+		c.Visits = append(c.Visits, history.FromRecoveredFirefoxPlaces(places.Recovered)...)
+		return places, nil
+	}, nil},
+	{"prefs.js", func(dir string, c *collected) (interface{}, error) {
+		return firefox.ProfilePrefsOptions(dir, c.Options)
+	}, nil},
+	parseFile("protections.sqlite", func(f string) (interface{}, error) { return firefox.ParseProtections(f) }),
+	parseFile("search.json.mozlz4", func(f string) (interface{}, error) { return firefox.ParseSearchEngines(f) }),
+	parseFile("serviceworker.txt", func(f string) (interface{}, error) { return firefox.ParseServiceWorkers(f) }),
+	{"sessionstore.jsonlz4", func(dir string, _ *collected) (interface{}, error) {
+		// Only the most recent session is archived.
+		files, err := firefox.SessionFiles(dir)
+		if err != nil || len(files) == 0 {
+			return nil, err
+		}
+		return firefox.ParseSession(files[0].Path)
+	}, []string{"sessionstore.js", "sessionstore-backups"}},
+	parseFile("shield-preference-experiments.json", func(f string) (interface{}, error) { return firefox.ParsePreferenceExperiments(f) }),
+	{"site_security_service_state.bin", func(dir string, c *collected) (interface{}, error) {
+		return firefox.ProfileSiteSecurityOptions(dir, c.Options)
+	}, []string{"SiteSecurityServiceState.txt"}},
+	{"signedInUser.json", func(dir string, c *collected) (interface{}, error) {
+		return firefox.ProfileSyncStateOptions(dir, c.Options)
+	}, []string{"weave"}},
+	{"storage", func(dir string, c *collected) (interface{}, error) { return firefox.ScanStorageOptions(dir, c.Options) }, nil},
+	{"storage-sync-v2.sqlite", func(dir string, c *collected) (interface{}, error) {
+		return firefox.ProfileStorageSyncOptions(dir, c.Options)
+	}, nil},
+	parseFile("storage.sqlite", func(f string) (interface{}, error) { return firefox.ParseStorageCache(f) }),
+	parseFile("times.json", func(f string) (interface{}, error) { return firefox.ParseTimes(f) }),
+	{"webappsstore.sqlite", func(dir string, c *collected) (interface{}, error) {
+		return firefox.ProfileLocalStorageOptions(dir, c.Options)
+	}, []string{"storage"}},
+	parseFile("xulstore.json", func(f string) (interface{}, error) { return firefox.ParseXULStore(f) }),
+}
+
+var chromeRootArtifacts = []artifact{
+	parseFile("Local State", func(f string) (interface{}, error) { return chrome.ParseLocalState(f) }),
+}
+
+var chromeArtifacts = []artifact{
+	{"Bookmarks", func(dir string, c *collected) (interface{}, error) {
+		bookmarks, err := chrome.ParseBookmarks(filepath.Join(dir, "Bookmarks"))
+		if err != nil {
+			return nil, err
+		}
+		c.Bookmarks = append(c.Bookmarks, bookmarks.Tree()...)
+		return bookmarks, nil
+	}, nil},
+	parseFile("BudgetDatabase", func(f string) (interface{}, error) { return chrome.ParseBudgetDatabase(f) }),
+	{"Cookies", func(dir string, c *collected) (interface{}, error) {
+		return chrome.ProfileCookiesOptions(dir, c.Options)
+	}, []string{chrome.CookiesFile}},
+	{"Extensions", func(dir string, c *collected) (interface{}, error) {
+		return chrome.ListExtensionsOptions(dir, c.Options)
+	}, nil},
+	{"History", func(dir string, c *collected) (interface{}, error) {
+		h, err := chrome.OpenHistory(filepath.Join(dir, "History"))
+		if err != nil {
+			return nil, err
+		}
+		defer h.Close()
+		visits, err := h.Visits()
+		if err != nil {
+			return nil, err
+		}
+		c.Visits = append(c.Visits, history.FromChromeHistory(visits)...)
+		if !c.Forensic {
+			return visits, nil
+		}
+		recovered, err := h.Recover()
+		if err != nil {
+			return nil, err
+		}
+		c.Visits = append(c.Visits, history.FromRecoveredChromeHistory(recovered.Visits)...)
+		return struct {
+			Visits    []chrome.HistoryVisit    `json:"visits"`
+			Recovered *chrome.RecoveredHistory `json:"recovered"`
+		}{visits, recovered}, nil
+	}, nil},
+	{"Login Data", func(dir string, c *collected) (interface{}, error) {
+		return chrome.ProfileLoginsOptions(dir, c.Options)
+	}, []string{"Login Data For Account"}},
+	parseFile("Platform Notifications", func(f string) (interface{}, error) { return chrome.ParsePlatformNotifications(f) }),
+	{"Preferences", func(dir string, c *collected) (interface{}, error) {
+		return chrome.ProfilePrefsSnapshotOptions(dir, c.Options)
+	}, nil},
+	{filepath.Join("Sync Data", "LevelDB"), func(dir string, c *collected) (interface{}, error) {
+		return chrome.ParseSavedTabGroupsOptions(dir, c.Options)
+	}, nil},
+	{"Web Applications", func(dir string, c *collected) (interface{}, error) {
+		return chrome.ParseWebAppsOptions(dir, c.Options)
+	}, []string{filepath.Join("Sync Data", "LevelDB")}},
+	parseFile("Web Data", func(f string) (interface{}, error) { return chrome.ParseWebData(f) }),
+}
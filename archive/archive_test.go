@@ -0,0 +1,313 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/bookmark"
+	"github.com/andrewarchi/browser/history"
+)
+
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	root := t.TempDir()
+	firefoxDir := filepath.Join(root, "firefox")
+	chromeDir := filepath.Join(root, "chrome")
+	copyFile(t, "../firefox/testdata/corpus/times.json/firefox-85.json", filepath.Join(firefoxDir, "a.default", "times.json"))
+	if err := os.WriteFile(filepath.Join(firefoxDir, "a.default", "extensions.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A user.js that fails to parse does not lose the prefs in prefs.js.
+	if err := os.WriteFile(filepath.Join(firefoxDir, "a.default", "prefs.js"), []byte(`user_pref("a", 1);`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(firefoxDir, "a.default", "user.js"), []byte(`user_pref("b", 2)`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(firefoxDir, "Crash Reports"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	places, err := sql.Open("sqlite3", filepath.Join(firefoxDir, "a.default", "places.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = places.Exec(`
+		CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR);
+		CREATE TABLE moz_keywords (id INTEGER PRIMARY KEY, keyword TEXT UNIQUE, place_id INTEGER, post_data TEXT);
+		CREATE TABLE moz_bookmarks (id INTEGER PRIMARY KEY, type INTEGER, fk INTEGER DEFAULT NULL,
+			parent INTEGER, position INTEGER, title LONGVARCHAR, keyword_id INTEGER, folder_type TEXT,
+			dateAdded INTEGER, lastModified INTEGER, guid TEXT, syncStatus INTEGER, syncChangeCounter INTEGER);
+		CREATE TABLE moz_historyvisits (id INTEGER PRIMARY KEY, from_visit INTEGER, place_id INTEGER,
+			visit_date INTEGER, visit_type INTEGER, session INTEGER);
+		INSERT INTO moz_places VALUES (1, 'https://example.com/', 'Example'), (2, 'https://example.org/', NULL);
+		INSERT INTO moz_bookmarks (id, type, parent, position, title, guid) VALUES (1, 2, 0, 0, '', 'root________');
+		INSERT INTO moz_historyvisits VALUES (1, 0, 1, 1613606400000000, 2, 0), (2, 1, 2, 1613610000000000, 1, 0);`)
+	places.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	copyFile(t, "../chrome/testdata/corpus/Local State/chrome-88.json", filepath.Join(chromeDir, "Local State"))
+	copyFile(t, "../chrome/testdata/corpus/Bookmarks/chrome-88.json", filepath.Join(chromeDir, "Default", "Bookmarks"))
+
+	a := Archiver{
+		FirefoxDir: firefoxDir,
+		ChromeDir:  chromeDir,
+		Now:        func() time.Time { return time.Date(2021, 2, 18, 15, 4, 5, 0, time.UTC) },
+	}
+	out := filepath.Join(root, "out")
+	m, err := a.Archive(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(out, "browser-archive-20210218T150405Z"); m.Dir != want {
+		t.Errorf("got dir %q, want %q", m.Dir, want)
+	}
+
+	type artifact struct{ Browser, Profile, Name string }
+	var got []artifact
+	var errs int
+	for _, p := range m.Profiles {
+		for _, a := range p.Artifacts {
+			got = append(got, artifact{p.Browser, filepath.Base(p.Path), a.Name})
+			if a.Error != "" {
+				errs++
+			}
+		}
+	}
+	want := []artifact{
+		{BrowserFirefox, "a.default", "downloads.json"},
+		{BrowserFirefox, "a.default", "extensions.json"},
+		{BrowserFirefox, "a.default", "places.sqlite"},
+		{BrowserFirefox, "a.default", "prefs.js"},
+		{BrowserFirefox, "a.default", "times.json"},
+		{BrowserChrome, "chrome", "Local State"},
+		{BrowserChrome, "Default", "Bookmarks"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got artifacts:\n%v\nwant:\n%v", got, want)
+	}
+	if errs != 2 {
+		t.Errorf("got %d errors, want 2 for extensions.json and prefs.js", errs)
+	}
+
+	var manifest Manifest
+	b, err := os.ReadFile(filepath.Join(m.Dir, ManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Format != Format || len(manifest.Files) != 3 || len(manifest.Profiles) != 3 {
+		t.Errorf("got manifest %+v", manifest)
+	}
+
+	f, err := os.Open(filepath.Join(m.Dir, ArtifactsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var names []string
+	for s := bufio.NewScanner(f); s.Scan(); {
+		var r artifactRecord
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, r.Artifact)
+		if r.Artifact == "prefs.js" && !bytes.Contains(r.Data, []byte(`"a"`)) {
+			t.Errorf("got prefs %s, want pref a", r.Data)
+		}
+	}
+	if want := []string{"downloads.json", "places.sqlite", "prefs.js", "times.json", "Local State", "Bookmarks"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got artifact records %q, want %q", names, want)
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(m.Dir, SQLiteFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var bookmarks int
+	if err := db.QueryRow(`SELECT count(*) FROM bookmarks`).Scan(&bookmarks); err != nil {
+		t.Fatal(err)
+	}
+	if bookmarks != 1 {
+		t.Errorf("got %d bookmarks, want 1", bookmarks)
+	}
+	var visits int
+	if err := db.QueryRow(`SELECT count(*) FROM visits`).Scan(&visits); err != nil {
+		t.Fatal(err)
+	}
+	if visits != 2 {
+		t.Errorf("got %d visits, want 2 from places.sqlite", visits)
+	}
+
+	a.OnError = browser.FailFast
+	a.Now = func() time.Time { return time.Date(2021, 2, 18, 15, 4, 6, 0, time.UTC) }
+	if _, err := a.Archive(out); err == nil || !strings.Contains(err.Error(), "extensions.json") {
+		t.Errorf("got error %v, want error for extensions.json", err)
+	}
+}
+
+func TestReadVisits(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "laptop")
+	at := time.Date(2021, 2, 18, 10, 0, 0, 0, time.UTC)
+	writeArchive(t, dir, "laptop", []history.Visit{
+		{URL: "https://example.com/", Time: at},
+		{URL: "https://example.org/", Time: at.Add(time.Hour), Device: "phone"},
+	}, nil)
+	visits, err := ReadVisits(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []history.Visit{
+		{URL: "https://example.com/", Time: at, Device: "laptop"},
+		{URL: "https://example.org/", Time: at.Add(time.Hour), Device: "phone"},
+	}
+	if !reflect.DeepEqual(visits, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", visits, want)
+	}
+}
+
+// writeArchive writes an archive of a single Chrome profile with the
+// given visits and bookmarks and without machine labels on records.
+func writeArchive(t *testing.T, dir, machine string, visits []history.Visit, bookmarks []bookmark.BookmarkEntry) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	p := ManifestProfile{Machine: machine, Browser: BrowserChrome, Path: "/chrome/Default",
+		Artifacts: []ManifestArtifact{{Name: "History"}}}
+	m := &Manifest{Format: Format, Version: Version, Dir: dir, Machine: machine, Profiles: []ManifestProfile{p}}
+	b, _ := json.Marshal(&artifactRecord{Browser: BrowserChrome, Profile: p.Path, Artifact: "History", Data: []byte("[]")})
+	if err := os.WriteFile(filepath.Join(dir, ArtifactsFile), append(b, '\n'), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	enc := history.NewEncoder(&buf)
+	for i := range visits {
+		if err := enc.EncodeVisit(&visits[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, HistoryFile), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := createDB(filepath.Join(dir, SQLiteFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.insertProfile(&p, &collected{Visits: visits, Bookmarks: bookmarks}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeManifest(filepath.Join(dir, ManifestFile), m); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	root := t.TempDir()
+	est := time.FixedZone("EST", -5*60*60)
+	synced := time.Date(2021, 2, 18, 10, 0, 0, 0, est)
+	bm := []bookmark.BookmarkEntry{&bookmark.Bookmark{Title: "Example", URL: "https://example.com/", GUID: "abc"}}
+	writeArchive(t, filepath.Join(root, "laptop"), "laptop", []history.Visit{
+		{URL: "https://example.com/", Time: synced},
+		{URL: "https://example.com/laptop", Time: synced.Add(time.Hour)},
+	}, bm)
+	writeArchive(t, filepath.Join(root, "desktop"), "desktop", []history.Visit{
+		{URL: "https://example.com/", Time: synced.UTC(), Device: "laptop"},
+		{URL: "https://example.com/desktop", Time: synced.Add(2 * time.Hour)},
+	}, bm)
+
+	a := Archiver{Now: func() time.Time { return time.Date(2021, 2, 19, 0, 0, 0, 0, time.UTC) }}
+	m, err := a.Merge(filepath.Join(root, "out"), filepath.Join(root, "laptop"), filepath.Join(root, "desktop"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Sources) != 2 || m.Sources[0].Machine != "laptop" || m.Sources[1].Machine != "desktop" {
+		t.Errorf("got sources %+v", m.Sources)
+	}
+	if len(m.Profiles) != 2 || m.Profiles[0].Machine != "laptop" || m.Profiles[1].Machine != "desktop" {
+		t.Errorf("got profiles %+v", m.Profiles)
+	}
+
+	f, err := os.Open(filepath.Join(m.Dir, HistoryFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	type visit struct{ URL, Device string }
+	var got []visit
+	d := history.NewDecoder(f)
+	for {
+		r, err := d.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, visit{r.Visit.URL, r.Visit.Device})
+	}
+	want := []visit{
+		{"https://example.com/", "laptop"},
+		{"https://example.com/laptop", "laptop"},
+		{"https://example.com/desktop", "desktop"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got visits:\n%v\nwant:\n%v", got, want)
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(m.Dir, SQLiteFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var visits, bookmarks, machines int
+	if err := db.QueryRow(`SELECT count(*) FROM visits`).Scan(&visits); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow(`SELECT count(*) FROM bookmarks`).Scan(&bookmarks); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow(`SELECT count(DISTINCT machine) FROM profiles`).Scan(&machines); err != nil {
+		t.Fatal(err)
+	}
+	if visits != 3 || bookmarks != 1 || machines != 2 {
+		t.Errorf("got %d visits, %d bookmarks, %d machines, want 3, 1, 2", visits, bookmarks, machines)
+	}
+}
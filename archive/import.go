@@ -0,0 +1,121 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/andrewarchi/browser/history"
+)
+
+// Import writes an archive of visits imported from another history
+// tool, such as those converted by history.FromBrowserExport,
+// history.FromPromnesia, or history.FromURLLog, into a new timestamped
+// directory in dir, so that they can be combined with archives of
+// browser profiles by Merge. The archive has a single profile with
+// BrowserImport and the path of the imported file, and an artifact
+// named by the source of the visits, which holds the visits. Visits
+// without a device are attributed to this machine.
+func (a *Archiver) Import(dir, path string, visits []history.Visit) (*Manifest, error) {
+	if len(visits) == 0 {
+		return nil, errors.New("archive: import: no visits")
+	}
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	machine, err := a.machine()
+	if err != nil {
+		return nil, err
+	}
+	created := now()
+	source := visits[0].Source
+	p := ManifestProfile{Machine: machine, Browser: BrowserImport, Path: path,
+		Artifacts: []ManifestArtifact{{Name: source}}}
+	m := &Manifest{
+		Format:   Format,
+		Version:  Version,
+		Created:  created.UTC().Truncate(time.Second),
+		Machine:  machine,
+		TimeZone: timeZone(created),
+		Profiles: []ManifestProfile{p},
+	}
+	m.Dir = filepath.Join(dir, "browser-archive-"+m.Created.Format("20060102T150405Z"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.Mkdir(m.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+
+	visits = append([]history.Visit{}, visits...)
+	a.sortHistory(visits, nil)
+	for i := range visits {
+		if visits[i].Device == "" {
+			visits[i].Device = machine
+		}
+	}
+	data, err := json.Marshal(visits)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(filepath.Join(m.Dir, ArtifactsFile), func(w *bufio.Writer) error {
+		aw := &artifactWriter{w: w}
+		return aw.write(&artifactRecord{machine, BrowserImport, path, source, data})
+	}); err != nil {
+		return nil, err
+	}
+	if err := writeFile(filepath.Join(m.Dir, HistoryFile), func(w *bufio.Writer) error {
+		enc := history.NewEncoder(w)
+		for i := range visits {
+			if err := enc.EncodeVisit(&visits[i]); err != nil {
+				return err
+			}
+		}
+		return enc.Flush()
+	}); err != nil {
+		return nil, err
+	}
+	db, err := createDB(filepath.Join(m.Dir, SQLiteFile))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	if err := db.insertProfile(&p, &collected{Visits: visits}); err != nil {
+		return nil, fmt.Errorf("archive: %s: %w", SQLiteFile, err)
+	}
+	if err := db.Close(); err != nil {
+		return nil, err
+	}
+	if err := a.finish(m, []string{ArtifactsFile, HistoryFile, SQLiteFile}); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// writeFile creates a file and writes it through a buffer.
+func writeFile(filename string, write func(w *bufio.Writer) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
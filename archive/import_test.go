@@ -0,0 +1,59 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package archive
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/history"
+	"github.com/andrewarchi/browser/tools/urllog"
+)
+
+func TestImport(t *testing.T) {
+	root := t.TempDir()
+	entries, err := urllog.Parse(strings.NewReader("1613606400\thttps://example.com/\tExample\nhttps://example.org/\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := Archiver{Machine: "laptop", Now: func() time.Time { return time.Date(2021, 2, 19, 0, 0, 0, 0, time.UTC) }}
+	m, err := a.Import(filepath.Join(root, "imported"), "/home/user/urls.log", history.FromURLLog(entries))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := m.Profiles[0]; p.Browser != BrowserImport || p.Path != "/home/user/urls.log" ||
+		!reflect.DeepEqual(p.Artifacts, []ManifestArtifact{{Name: history.SourceURLLog}}) {
+		t.Errorf("got profile %+v", p)
+	}
+	if err := Verify(m.Dir, nil); err != nil {
+		t.Error(err)
+	}
+	visits, err := ReadVisits(m.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []history.Visit{{URL: "https://example.com/", Title: "Example", Time: time.Date(2021, 2, 18, 0, 0, 0, 0, time.UTC),
+		Source: history.SourceURLLog, Device: "laptop", Precision: history.PrecisionSecond, Trust: history.TrustExport}}
+	if !reflect.DeepEqual(visits, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", visits, want)
+	}
+
+	// Imported archives merge with others.
+	writeArchive(t, filepath.Join(root, "desktop"), "desktop", []history.Visit{
+		{URL: "https://example.net/", Time: time.Date(2021, 2, 18, 1, 0, 0, 0, time.UTC)},
+	}, nil)
+	merged, err := a.Merge(filepath.Join(root, "out"), m.Dir, filepath.Join(root, "desktop"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if visits, err := ReadVisits(merged.Dir); err != nil || len(visits) != 2 {
+		t.Errorf("got merged visits %+v, error %v", visits, err)
+	}
+}
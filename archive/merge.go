@@ -0,0 +1,306 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser/history"
+)
+
+// Source is an archive combined into a merged archive.
+type Source struct {
+	Dir      string    `json:"dir"`
+	Machine  string    `json:"machine,omitempty"`
+	Created  time.Time `json:"created"`
+	TimeZone *TimeZone `json:"time_zone,omitempty"`
+}
+
+// ReadManifest reads the manifest of an archive.
+func ReadManifest(dir string) (*Manifest, error) {
+	b, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("archive: %s: %w", dir, err)
+	}
+	if m.Format != Format {
+		return nil, fmt.Errorf("archive: %s: not a %s manifest", dir, Format)
+	}
+	if m.Version > Version {
+		return nil, fmt.Errorf("archive: %s: unsupported version %d", dir, m.Version)
+	}
+	m.Dir = dir
+	return &m, nil
+}
+
+// ReadVisits reads the visits in the history of an archive, in the
+// order written. Visits without a device are attributed to the machine
+// of the archive.
+func ReadVisits(dir string) ([]history.Visit, error) {
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, HistoryFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var visits []history.Visit
+	d := history.NewDecoder(f)
+	for {
+		r, err := d.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("archive: %s: %w", dir, err)
+		}
+		if v := r.Visit; v != nil {
+			if v.Device == "" {
+				v.Device = m.Machine
+			}
+			visits = append(visits, *v)
+		}
+	}
+	return visits, nil
+}
+
+// Merge combines archives into a new timestamped archive in dir using
+// the default Archiver.
+func Merge(dir string, archives ...string) (*Manifest, error) {
+	var a Archiver
+	return a.Merge(dir, archives...)
+}
+
+// Merge combines archives, such as those collected from several
+// computers, into a new timestamped archive in dir. Each profile keeps
+// the label of the machine it was collected on, which also labels
+// visits without a device.
+//
+// Synced data appears in the archive of every machine it was synced
+// to, and archiving the same machine twice repeats its data, so records
+// already present in an earlier archive in the argument order are
+// dropped: visits with the same URL and time, downloads with the same
+// URL and start time, and bookmarks with the same GUID and URL. Times
+// are compared in UTC, so archives from machines in different time
+// zones merge correctly.
+func (a *Archiver) Merge(dir string, archives ...string) (*Manifest, error) {
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	m := &Manifest{
+		Format:   Format,
+		Version:  Version,
+		Created:  now().UTC().Truncate(time.Second),
+		Profiles: []ManifestProfile{},
+	}
+	var sources []*Manifest
+	for _, src := range archives {
+		sm, err := ReadManifest(src)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range sm.Files {
+			if !f.Encrypted {
+				continue
+			}
+			plain := filepath.Join(src, strings.TrimSuffix(f.Name, EncryptedSuffix))
+			if _, err := os.Stat(plain); err != nil {
+				return nil, fmt.Errorf("archive: %s: encrypted and not decrypted by DecryptArchive: %w", src, err)
+			}
+		}
+		sources = append(sources, sm)
+		if len(sm.Sources) != 0 {
+			m.Sources = append(m.Sources, sm.Sources...)
+		} else {
+			m.Sources = append(m.Sources, Source{src, sm.Machine, sm.Created, sm.TimeZone})
+		}
+		for _, p := range sm.Profiles {
+			if p.Machine == "" {
+				p.Machine = sm.Machine
+			}
+			m.Profiles = append(m.Profiles, p)
+		}
+	}
+
+	m.Dir = filepath.Join(dir, "browser-archive-"+m.Created.Format("20060102T150405Z"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.Mkdir(m.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+	if err := mergeArtifacts(m.Dir, sources); err != nil {
+		return nil, fmt.Errorf("archive: %s: %w", ArtifactsFile, err)
+	}
+	if err := mergeHistory(m.Dir, a.SpillDir, a.Order, sources); err != nil {
+		return nil, fmt.Errorf("archive: %s: %w", HistoryFile, err)
+	}
+	if err := mergeDB(m.Dir, sources); err != nil {
+		return nil, fmt.Errorf("archive: %s: %w", SQLiteFile, err)
+	}
+	if err := a.finish(m, []string{ArtifactsFile, HistoryFile, SQLiteFile}); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// mergeArtifacts concatenates the artifacts of the sources and labels
+// each with its machine.
+func mergeArtifacts(dir string, sources []*Manifest) error {
+	out, err := os.Create(filepath.Join(dir, ArtifactsFile))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	w := &artifactWriter{w: bufio.NewWriter(out)}
+	for _, src := range sources {
+		f, err := os.Open(filepath.Join(src.Dir, ArtifactsFile))
+		if err != nil {
+			return err
+		}
+		d := json.NewDecoder(f)
+		for {
+			var r artifactRecord
+			if err := d.Decode(&r); err == io.EOF {
+				break
+			} else if err != nil {
+				f.Close()
+				return fmt.Errorf("%s: %w", src.Dir, err)
+			}
+			if r.Machine == "" {
+				r.Machine = src.Machine
+			}
+			if err := w.write(&r); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		f.Close()
+	}
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+type visitKey struct {
+	URL  string
+	Time int64
+}
+
+// mergeHistory merges the visits and downloads of the sources. Visits
+// are collapsed by a history.Merger, which keeps the most precise of
+// duplicates and spills to spillDir, if set, and are written first,
+// ordered by time, then URL. Downloads in earlier sources are dropped
+// and the rest are ordered by order.
+func mergeHistory(dir, spillDir string, order history.Order, sources []*Manifest) error {
+	out, err := os.Create(filepath.Join(dir, HistoryFile))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	visits := &history.Merger{SpillDir: spillDir}
+	defer visits.Close()
+	var downloads []history.Download
+	seenDownloads := make(map[visitKey]bool)
+	for _, src := range sources {
+		srcDownloads := make(map[visitKey]bool)
+		f, err := os.Open(filepath.Join(src.Dir, HistoryFile))
+		if err != nil {
+			return err
+		}
+		d := history.NewDecoder(f)
+		for {
+			r, err := d.Decode()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				f.Close()
+				return fmt.Errorf("%s: %w", src.Dir, err)
+			}
+			switch {
+			case r.Visit != nil:
+				v := r.Visit
+				if v.Device == "" {
+					v.Device = src.Machine
+				}
+				if err := visits.Add(*v); err != nil {
+					f.Close()
+					return err
+				}
+			case r.Download != nil:
+				dl := r.Download
+				key := visitKey{dl.URL, dl.StartTime.UnixNano()}
+				if seenDownloads[key] {
+					continue
+				}
+				srcDownloads[key] = true
+				downloads = append(downloads, *dl)
+			}
+		}
+		f.Close()
+		for key := range srcDownloads {
+			seenDownloads[key] = true
+		}
+	}
+
+	enc := history.NewEncoder(out)
+	if err := visits.Each(enc.EncodeVisit); err != nil {
+		return err
+	}
+	if order == history.OrderTime {
+		history.SortDownloads(downloads)
+	}
+	for i := range downloads {
+		if err := enc.EncodeDownload(&downloads[i]); err != nil {
+			return err
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// mergeDB copies the tables of the sources into a new database,
+// dropping rows in earlier sources. Unlike mergeHistory, visits are
+// only duplicates when their times are equal exactly, since the
+// database does not record their precision. Sources with an older
+// schema are merged from upgraded copies.
+func mergeDB(dir string, sources []*Manifest) error {
+	db, err := createDB(filepath.Join(dir, SQLiteFile))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	for _, src := range sources {
+		filename, tmp, err := migratedDB(dir, filepath.Join(src.Dir, SQLiteFile))
+		if err != nil {
+			return fmt.Errorf("%s: %w", src.Dir, err)
+		}
+		err = db.merge(filename, src.Machine)
+		if tmp {
+			os.Remove(filename)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", src.Dir, err)
+		}
+	}
+	return db.Close()
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package archive
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SchemaVersion is the version of the schema of archive.sqlite written
+// by this package, which is stored in its user_version. Databases
+// written before the schema was versioned have a user_version of 0 and
+// are version 1.
+const SchemaVersion = 2
+
+// migrations upgrade archive.sqlite from each version to the next:
+// migrations[i] upgrades version i+1 to i+2. Migrations only add to the
+// schema, so that the data of archives is never lost, and the schema
+// after all migrations must equal schema.
+var migrations = []string{
+	// 1 → 2: visit IDs, for linking redirect chains, and an index on
+	// the columns used to find duplicate visits when merging.
+	`ALTER TABLE visits ADD COLUMN visit_id INTEGER;
+	ALTER TABLE visits ADD COLUMN from_visit INTEGER;
+	CREATE INDEX visits_url_time ON visits (url, time);`,
+}
+
+// MigrationRecord records an upgrade of an archive by Migrate.
+type MigrationRecord struct {
+	Applied    time.Time `json:"applied"`
+	From       int       `json:"from"`        // archive version before
+	To         int       `json:"to"`          // archive version after
+	FromSchema int       `json:"from_schema"` // schema version of archive.sqlite before
+	ToSchema   int       `json:"to_schema"`   // schema version of archive.sqlite after
+}
+
+// Migrate upgrades an archive written by an older version of this
+// package in place, so that it can be read and merged without
+// re-archiving the profiles it was collected from, which may no longer
+// exist. archive.sqlite is upgraded to SchemaVersion, one version at a
+// time, each in a transaction, and the upgrade is recorded in the
+// manifest. The checksums and, when a.SigningKey is set, the signature
+// are then rewritten; otherwise, a stale signature is removed.
+//
+// history.jsonl and artifacts.jsonl are not rewritten, since their
+// readers accept older versions. An archive that is already current is
+// not changed and the returned manifest has no new record. Encrypted
+// archives must be decrypted by DecryptArchive first.
+func (a *Archiver) Migrate(dir string) (*Manifest, error) {
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range m.Files {
+		if f.Encrypted {
+			return nil, fmt.Errorf("archive: %s: cannot migrate an encrypted archive", dir)
+		}
+	}
+	from, err := migrateDB(filepath.Join(dir, SQLiteFile))
+	if err != nil {
+		return nil, fmt.Errorf("archive: %s: %w", SQLiteFile, err)
+	}
+	if m.Version == Version && from == SchemaVersion {
+		return m, nil
+	}
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	m.Migrations = append(m.Migrations, MigrationRecord{
+		Applied:    now().UTC().Truncate(time.Second),
+		From:       m.Version,
+		To:         Version,
+		FromSchema: from,
+		ToSchema:   SchemaVersion,
+	})
+	m.Version = Version
+	m.Files = nil
+	if err := a.finish(m, []string{ArtifactsFile, HistoryFile, SQLiteFile}); err != nil {
+		return nil, err
+	}
+	if a.SigningKey == nil {
+		if err := os.Remove(filepath.Join(dir, SignatureFile)); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// migrateDB upgrades a database in place to SchemaVersion and returns
+// its version before.
+func migrateDB(filename string) (int, error) {
+	if _, err := os.Stat(filename); err != nil {
+		return 0, err
+	}
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	from, err := schemaVersion(db)
+	if err != nil {
+		return 0, err
+	}
+	if from > SchemaVersion {
+		return 0, fmt.Errorf("unsupported schema version %d", from)
+	}
+	for v := from; v < SchemaVersion; v++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(migrations[v-1]); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("migrate schema version %d to %d: %w", v, v+1, err)
+		}
+		if err := setSchemaVersion(tx, v+1); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, err
+		}
+	}
+	return from, db.Close()
+}
+
+// migratedDB returns the filename of a copy of the database of a
+// source archive, upgraded to SchemaVersion in dir, so that sources are
+// not modified. When it is already current, filename is returned and
+// tmp is false; otherwise, the caller removes the copy.
+func migratedDB(dir, filename string) (migrated string, tmp bool, err error) {
+	if _, err := os.Stat(filename); err != nil {
+		return "", false, err
+	}
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return "", false, err
+	}
+	v, err := schemaVersion(db)
+	db.Close()
+	if err != nil {
+		return "", false, err
+	}
+	if v == SchemaVersion {
+		return filename, false, nil
+	}
+	in, err := os.Open(filename)
+	if err != nil {
+		return "", false, err
+	}
+	defer in.Close()
+	out, err := os.CreateTemp(dir, SQLiteFile+".*")
+	if err != nil {
+		return "", false, err
+	}
+	migrated = out.Name()
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		_, err = migrateDB(migrated)
+	}
+	if err != nil {
+		os.Remove(migrated)
+		return "", false, err
+	}
+	return migrated, true, nil
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func schemaVersion(db *sql.DB) (int, error) {
+	var v int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&v); err != nil {
+		return 0, err
+	}
+	if v == 0 {
+		v = 1
+	}
+	return v, nil
+}
+
+func setSchemaVersion(db execer, v int) error {
+	// PRAGMA does not accept parameters.
+	_, err := db.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, v))
+	return err
+}
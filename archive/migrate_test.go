@@ -0,0 +1,139 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package archive
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/history"
+)
+
+// schemaV1 is the schema of archive.sqlite before it was versioned.
+const schemaV1 = `
+CREATE TABLE profiles (id INTEGER PRIMARY KEY, machine TEXT, browser TEXT NOT NULL, path TEXT NOT NULL);
+CREATE TABLE artifacts (profile_id INTEGER NOT NULL, name TEXT NOT NULL, error TEXT);
+CREATE TABLE visits (profile_id INTEGER NOT NULL, url TEXT NOT NULL, title TEXT, time TEXT,
+	transition INTEGER, source TEXT, device TEXT);
+CREATE TABLE downloads (profile_id INTEGER NOT NULL, url TEXT NOT NULL, referrer TEXT, target_path TEXT,
+	mime_type TEXT, start_time TEXT, end_time TEXT, received_bytes INTEGER, total_bytes INTEGER,
+	state TEXT, source TEXT);
+CREATE TABLE bookmarks (profile_id INTEGER NOT NULL, folder TEXT NOT NULL, title TEXT, url TEXT NOT NULL,
+	guid TEXT, add_date TEXT);
+INSERT INTO profiles VALUES (1, NULL, 'chrome', '/chrome/Default');
+INSERT INTO visits VALUES (1, 'https://example.com/', 'Example', '2021-02-18T00:00:00Z', 1, 'chrome', NULL);
+`
+
+// writeArchiveV1 writes an archive as written by version 1.
+func writeArchiveV1(t *testing.T, dir, machine string) {
+	t.Helper()
+	writeArchive(t, dir, machine, nil, nil)
+	filename := filepath.Join(dir, SQLiteFile)
+	if err := os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(schemaV1); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Version = 1
+	if err := writeManifest(filepath.Join(dir, ManifestFile), m); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	root := t.TempDir()
+	old := filepath.Join(root, "old")
+	writeArchiveV1(t, old, "laptop")
+	at := time.Date(2021, 2, 19, 0, 0, 0, 0, time.UTC)
+	writeArchive(t, filepath.Join(root, "new"), "desktop", []history.Visit{
+		{URL: "https://example.org/", Time: at, ID: 2, FromVisit: 1},
+	}, nil)
+
+	// Merge upgrades a copy of older sources.
+	a := Archiver{Now: func() time.Time { return at }}
+	merged, err := a.Merge(filepath.Join(root, "out"), old, filepath.Join(root, "new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := userVersion(t, filepath.Join(old, SQLiteFile)); v != 0 {
+		t.Errorf("merge changed source user_version to %d", v)
+	}
+	entries, err := os.ReadDir(filepath.Join(root, "out", filepath.Base(merged.Dir)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 {
+		t.Errorf("merge left temporary files: %v", entries)
+	}
+
+	m, err := a.Migrate(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != Version || len(m.Migrations) != 1 {
+		t.Fatalf("got manifest version %d and migrations %+v", m.Version, m.Migrations)
+	}
+	if rec := m.Migrations[0]; rec.From != 1 || rec.To != Version || rec.FromSchema != 1 || rec.ToSchema != SchemaVersion {
+		t.Errorf("got migration record %+v", rec)
+	}
+	if err := Verify(old, nil); err != nil {
+		t.Error(err)
+	}
+	if v := userVersion(t, filepath.Join(old, SQLiteFile)); v != SchemaVersion {
+		t.Errorf("got user_version %d, want %d", v, SchemaVersion)
+	}
+	for _, dir := range []string{old, merged.Dir} {
+		db, err := sql.Open("sqlite3", filepath.Join(dir, SQLiteFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var title string
+		var id sql.NullInt64
+		if err := db.QueryRow(`SELECT title, visit_id FROM visits WHERE url = 'https://example.com/'`).Scan(&title, &id); err != nil {
+			t.Errorf("%s: %v", dir, err)
+		} else if title != "Example" || id.Valid {
+			t.Errorf("%s: got title %q and visit_id %v", dir, title, id)
+		}
+		db.Close()
+	}
+
+	// Migrating again changes nothing.
+	m, err = a.Migrate(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Migrations) != 1 {
+		t.Errorf("got migrations %+v", m.Migrations)
+	}
+}
+
+func userVersion(t *testing.T, filename string) int {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var v int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
@@ -0,0 +1,359 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser/history"
+)
+
+// Retention is a policy for forgetting records in an archive.
+type Retention struct {
+	// Before drops visits and downloads before this time. The zero
+	// time keeps all.
+	Before time.Time
+	// Domains drops visits, downloads, and bookmarks with URLs in
+	// these domains or their subdomains, e.g. "example.com".
+	Domains []string
+	// Vacuum rebuilds archive.sqlite, so that the deleted rows are not
+	// left in its free pages, where they could be recovered.
+	Vacuum bool
+}
+
+// RetentionRecord records a retention policy applied to an archive.
+// The dropped domains are counted, but not listed, so that the
+// manifest does not reveal them.
+type RetentionRecord struct {
+	Applied   time.Time  `json:"applied"`
+	Before    *time.Time `json:"before,omitempty"`
+	Domains   int        `json:"domains,omitempty"`
+	Vacuumed  bool       `json:"vacuumed,omitempty"`
+	Visits    int        `json:"visits"`    // visits dropped
+	Downloads int        `json:"downloads"` // downloads dropped
+	Bookmarks int        `json:"bookmarks"` // bookmarks dropped
+}
+
+// historyArtifacts are the artifacts that hold the visits and
+// downloads of a profile, which are filtered by time like history.jsonl.
+var historyArtifacts = map[string]bool{
+	"History":        true,
+	"downloads.json": true,
+}
+
+// Prune applies a retention policy to an archive in place and records
+// it in the manifest, then rewrites the checksums and, when
+// a.SigningKey is set, the signature; otherwise, a stale signature is
+// removed.
+//
+// history.jsonl and archive.sqlite are filtered exactly. In
+// artifacts.jsonl, which holds the data as parsed, elements of arrays
+// with a URL in a dropped domain are removed from every artifact, but
+// only elements of the artifacts holding history, whose times are all
+// before Before, are removed by time. Other artifacts, such as
+// bookmarks and sessions, are not filtered by time. Encrypted archives
+// must be decrypted by DecryptArchive first.
+func (a *Archiver) Prune(dir string, r Retention) (*Manifest, error) {
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range m.Files {
+		if f.Encrypted {
+			return nil, fmt.Errorf("archive: %s: cannot prune an encrypted archive", dir)
+		}
+	}
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	rec := RetentionRecord{
+		Applied:  now().UTC().Truncate(time.Second),
+		Domains:  len(r.Domains),
+		Vacuumed: r.Vacuum,
+	}
+	if !r.Before.IsZero() {
+		before := r.Before.UTC()
+		rec.Before = &before
+	}
+	if err := pruneArtifacts(dir, &r); err != nil {
+		return nil, fmt.Errorf("archive: %s: %w", ArtifactsFile, err)
+	}
+	if err := pruneHistory(dir, &r, &rec); err != nil {
+		return nil, fmt.Errorf("archive: %s: %w", HistoryFile, err)
+	}
+	if err := pruneDB(dir, &r, &rec); err != nil {
+		return nil, fmt.Errorf("archive: %s: %w", SQLiteFile, err)
+	}
+	m.Retention = append(m.Retention, rec)
+	m.Files = nil
+	if err := a.finish(m, []string{ArtifactsFile, HistoryFile, SQLiteFile}); err != nil {
+		return nil, err
+	}
+	if a.SigningKey == nil {
+		if err := os.Remove(filepath.Join(dir, SignatureFile)); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// dropsURL reports whether a URL is in a dropped domain.
+func (r *Retention) dropsURL(u string) bool {
+	if len(r.Domains) == 0 || u == "" {
+		return false
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "" {
+		return false
+	}
+	for _, d := range r.Domains {
+		d = strings.TrimSuffix(strings.ToLower(d), ".")
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// dropsTime reports whether a time is before the retention cutoff.
+// Unknown times are kept.
+func (r *Retention) dropsTime(t time.Time) bool {
+	return !r.Before.IsZero() && !t.IsZero() && t.Before(r.Before)
+}
+
+// replaceFile writes a file in dir through a temporary file, then
+// replaces the original.
+func replaceFile(dir, name string, write func(w *bufio.Writer) error) error {
+	tmp, err := os.CreateTemp(dir, name+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	w := bufio.NewWriter(tmp)
+	if err := write(w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
+
+func pruneArtifacts(dir string, r *Retention) error {
+	f, err := os.Open(filepath.Join(dir, ArtifactsFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return replaceFile(dir, ArtifactsFile, func(w *bufio.Writer) error {
+		aw := &artifactWriter{w: w}
+		d := json.NewDecoder(f)
+		for {
+			var rec artifactRecord
+			if err := d.Decode(&rec); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			var data interface{}
+			dd := json.NewDecoder(bytes.NewReader(rec.Data))
+			dd.UseNumber()
+			if err := dd.Decode(&data); err != nil {
+				return err
+			}
+			// Artifacts are only re-encoded when changed, since
+			// re-encoding reorders object keys.
+			if data, changed := r.pruneJSON(data, historyArtifacts[rec.Artifact]); changed {
+				b, err := json.Marshal(data)
+				if err != nil {
+					return err
+				}
+				rec.Data = b
+			}
+			if err := aw.write(&rec); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// pruneJSON removes the elements of arrays in v that are objects with
+// a string field that is a URL in a dropped domain or, when byTime is
+// set, with time fields that are all before the cutoff. It reports
+// whether any were removed.
+func (r *Retention) pruneJSON(v interface{}, byTime bool) (interface{}, bool) {
+	changed := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			e, c := r.pruneJSON(e, byTime)
+			v[k], changed = e, changed || c
+		}
+		return v, changed
+	case []interface{}:
+		kept := v[:0]
+		for _, e := range v {
+			if obj, ok := e.(map[string]interface{}); ok && r.dropsObject(obj, byTime) {
+				changed = true
+				continue
+			}
+			e, c := r.pruneJSON(e, byTime)
+			kept, changed = append(kept, e), changed || c
+		}
+		return kept, changed
+	default:
+		return v, false
+	}
+}
+
+func (r *Retention) dropsObject(obj map[string]interface{}, byTime bool) bool {
+	times, old := 0, 0
+	for _, e := range obj {
+		s, ok := e.(string)
+		if !ok {
+			continue
+		}
+		if strings.Contains(s, "://") && r.dropsURL(s) {
+			return true
+		}
+		if !byTime {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			times++
+			if r.dropsTime(t) {
+				old++
+			}
+		}
+	}
+	return times != 0 && old == times
+}
+
+func pruneHistory(dir string, r *Retention, rec *RetentionRecord) error {
+	f, err := os.Open(filepath.Join(dir, HistoryFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return replaceFile(dir, HistoryFile, func(w *bufio.Writer) error {
+		enc := history.NewEncoder(w)
+		d := history.NewDecoder(f)
+		for {
+			hr, err := d.Decode()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+			switch {
+			case hr.Visit != nil:
+				if r.dropsTime(hr.Visit.Time) || r.dropsURL(hr.Visit.URL) {
+					rec.Visits++
+					continue
+				}
+				err = enc.EncodeVisit(hr.Visit)
+			case hr.Download != nil:
+				dl := hr.Download
+				if r.dropsTime(dl.StartTime) || r.dropsURL(dl.URL) || r.dropsURL(dl.Referrer) {
+					rec.Downloads++
+					continue
+				}
+				err = enc.EncodeDownload(dl)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return enc.Flush()
+	})
+}
+
+// pruneDB deletes the dropped rows from archive.sqlite. Rows are
+// selected in Go, since times are stored as text and URLs are matched
+// by parsed host. Visits and downloads are counted by pruneHistory.
+func pruneDB(dir string, r *Retention, rec *RetentionRecord) error {
+	db, err := sql.Open("sqlite3", filepath.Join(dir, SQLiteFile))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, t := range []struct{ table, columns string }{
+		{"visits", "url, url, time"},
+		{"downloads", "url, referrer, start_time"},
+		{"bookmarks", "url, url, NULL"},
+	} {
+		rows, err := tx.Query(`SELECT rowid, ` + t.columns + ` FROM ` + t.table)
+		if err != nil {
+			return err
+		}
+		var drop []int64
+		for rows.Next() {
+			var id int64
+			var u1, u2, ts sql.NullString
+			if err := rows.Scan(&id, &u1, &u2, &ts); err != nil {
+				rows.Close()
+				return err
+			}
+			var tm time.Time
+			if ts.Valid {
+				if tm, err = time.Parse(time.RFC3339Nano, ts.String); err != nil {
+					rows.Close()
+					return fmt.Errorf("%s: %w", t.table, err)
+				}
+			}
+			if r.dropsTime(tm) || r.dropsURL(u1.String) || r.dropsURL(u2.String) {
+				drop = append(drop, id)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if t.table == "bookmarks" {
+			rec.Bookmarks = len(drop)
+		}
+		for _, id := range drop {
+			if _, err := tx.Exec(`DELETE FROM `+t.table+` WHERE rowid = ?`, id); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if r.Vacuum {
+		if _, err := db.Exec(`VACUUM`); err != nil {
+			return err
+		}
+	}
+	return db.Close()
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package archive
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/bookmark"
+	"github.com/andrewarchi/browser/history"
+)
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC)
+	recent := time.Date(2021, 2, 18, 0, 0, 0, 0, time.UTC)
+	writeArchive(t, dir, "laptop", []history.Visit{
+		{URL: "https://example.com/old", Time: old},
+		{URL: "https://example.com/recent", Time: recent},
+		{URL: "https://www.secret.example/", Time: recent},
+	}, []bookmark.BookmarkEntry{
+		&bookmark.Bookmark{Title: "Example", URL: "https://example.com/"},
+		&bookmark.Bookmark{Title: "Secret", URL: "https://secret.example/"},
+	})
+	artifacts := `{"browser":"chrome","profile":"/chrome/Default","artifact":"History","data":[` +
+		`{"URL":"https://example.com/old","VisitTime":"2015-06-01T00:00:00Z"},` +
+		`{"URL":"https://example.com/recent","VisitTime":"2021-02-18T00:00:00Z"},` +
+		`{"URL":"https://www.secret.example/","VisitTime":"2021-02-18T00:00:00Z"}]}` + "\n" +
+		`{"browser":"chrome","profile":"/chrome/Default","artifact":"Bookmarks","data":{"roots":[` +
+		`{"url":"https://example.com/","date_added":"2015-06-01T00:00:00Z"}]}}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, ArtifactsFile), []byte(artifacts), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := Archiver{Now: func() time.Time { return time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC) }}
+	m, err := a.Prune(dir, Retention{
+		Before:  time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC),
+		Domains: []string{"secret.example"},
+		Vacuum:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Retention) != 1 {
+		t.Fatalf("got retention %+v", m.Retention)
+	}
+	if rec := m.Retention[0]; rec.Visits != 2 || rec.Bookmarks != 1 || rec.Domains != 1 || !rec.Vacuumed {
+		t.Errorf("got retention record %+v", rec)
+	}
+	if err := Verify(dir, nil); err != nil {
+		t.Error(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("secret")) {
+		t.Errorf("manifest reveals dropped domain:\n%s", b)
+	}
+
+	f, err := os.Open(filepath.Join(dir, HistoryFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var urls []string
+	d := history.NewDecoder(f)
+	for {
+		r, err := d.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		urls = append(urls, r.Visit.URL)
+	}
+	if want := []string{"https://example.com/recent"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("got visits %v, want %v", urls, want)
+	}
+
+	b, err = os.ReadFile(filepath.Join(dir, ArtifactsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(b), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d artifact records, want 2", len(lines))
+	}
+	var hist struct{ Data []map[string]string }
+	if err := json.Unmarshal(lines[0], &hist); err != nil {
+		t.Fatal(err)
+	}
+	if len(hist.Data) != 1 || hist.Data[0]["URL"] != "https://example.com/recent" {
+		t.Errorf("got History artifact %s", lines[0])
+	}
+	// Bookmarks are not filtered by time, so the old bookmark remains.
+	if !bytes.Contains(lines[1], []byte("https://example.com/")) {
+		t.Errorf("got Bookmarks artifact %s", lines[1])
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, SQLiteFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var visits, bookmarks int
+	if err := db.QueryRow(`SELECT count(*) FROM visits`).Scan(&visits); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow(`SELECT count(*) FROM bookmarks`).Scan(&bookmarks); err != nil {
+		t.Fatal(err)
+	}
+	if visits != 1 || bookmarks != 1 {
+		t.Errorf("got %d visits and %d bookmarks, want 1 and 1", visits, bookmarks)
+	}
+}
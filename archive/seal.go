@@ -0,0 +1,372 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Encrypted files are in a format modeled on age
+// (https://age-encryption.org/v1), using only the standard library:
+//
+//	"browser-archive-encrypted/v1\n"
+//	recipient count (1 byte)
+//	for each recipient:
+//		ephemeral X25519 public key (32 bytes)
+//		file key sealed with AES-256-GCM (48 bytes)
+//	payload: 64 KiB chunks sealed with AES-256-GCM
+//
+// A random file key is encrypted to each recipient with a key derived
+// by HKDF-SHA256 from an X25519 exchange with an ephemeral key. The
+// payload key is derived from the file key with the header as salt,
+// so the header cannot be altered. Each chunk nonce is a big-endian
+// counter followed by a byte that is 1 for the last chunk, so chunks
+// cannot be reordered or the file truncated.
+
+// Files written alongside the outputs of an archive:
+const (
+	ChecksumsFile   = "SHA256SUMS"        // sha256sum checksums of the manifest and outputs
+	SignatureFile   = "manifest.json.sig" // Ed25519 signature of the manifest, in base64
+	EncryptedSuffix = ".enc"              // suffix of encrypted outputs
+	encryptedMagic  = "browser-archive-encrypted/v1\n"
+)
+
+const (
+	chunkSize   = 64 << 10
+	tagSize     = 16
+	stanzaSize  = 32 + 32 + tagSize
+	fileKeySize = 32
+)
+
+// Encrypt encrypts r to w for the X25519 public keys of the recipients,
+// any of which can decrypt it.
+func Encrypt(w io.Writer, r io.Reader, recipients ...*ecdh.PublicKey) error {
+	if len(recipients) == 0 || len(recipients) > 255 {
+		return fmt.Errorf("archive: encrypt: %d recipients", len(recipients))
+	}
+	fileKey := make([]byte, fileKeySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return err
+	}
+	header := []byte(encryptedMagic)
+	header = append(header, byte(len(recipients)))
+	for _, recipient := range recipients {
+		if recipient.Curve() != ecdh.X25519() {
+			return errors.New("archive: encrypt: recipient is not an X25519 key")
+		}
+		eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		shared, err := eph.ECDH(recipient)
+		if err != nil {
+			return err
+		}
+		aead, err := wrapAEAD(shared, eph.PublicKey().Bytes(), recipient.Bytes())
+		if err != nil {
+			return err
+		}
+		header = append(header, eph.PublicKey().Bytes()...)
+		header = aead.Seal(header, make([]byte, aead.NonceSize()), fileKey, nil)
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	aead, err := payloadAEAD(fileKey, header)
+	if err != nil {
+		return err
+	}
+
+	br := bufio.NewReaderSize(r, chunkSize)
+	buf := make([]byte, chunkSize, chunkSize+tagSize)
+	for counter := uint64(0); ; counter++ {
+		n, err := io.ReadFull(br, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := err != nil
+		if !last {
+			if _, err := br.Peek(1); err == io.EOF {
+				last = true
+			} else if err != nil {
+				return err
+			}
+		}
+		out := aead.Seal(buf[:0], chunkNonce(counter, last), buf[:n], nil)
+		if _, err := w.Write(out); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		buf = buf[:chunkSize]
+	}
+}
+
+// Decrypt decrypts r, encrypted by Encrypt, to w with the X25519
+// private key of one of its recipients. Data is written to w as each
+// chunk is authenticated, so when an error is returned, w may have
+// received a truncated prefix of the plaintext.
+func Decrypt(w io.Writer, r io.Reader, identity *ecdh.PrivateKey) error {
+	br := bufio.NewReaderSize(r, chunkSize+tagSize)
+	header := make([]byte, len(encryptedMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[:len(encryptedMagic)]) != encryptedMagic {
+		return errors.New("archive: decrypt: not an encrypted archive file")
+	}
+	count := int(header[len(header)-1])
+	stanzas := make([]byte, count*stanzaSize)
+	if _, err := io.ReadFull(br, stanzas); err != nil {
+		return fmt.Errorf("archive: decrypt: header: %w", err)
+	}
+	header = append(header, stanzas...)
+	var fileKey []byte
+	for i := 0; i < count && fileKey == nil; i++ {
+		s := stanzas[i*stanzaSize : (i+1)*stanzaSize]
+		eph, err := ecdh.X25519().NewPublicKey(s[:32])
+		if err != nil {
+			continue
+		}
+		shared, err := identity.ECDH(eph)
+		if err != nil {
+			continue
+		}
+		aead, err := wrapAEAD(shared, s[:32], identity.PublicKey().Bytes())
+		if err != nil {
+			return err
+		}
+		fileKey, _ = aead.Open(nil, make([]byte, aead.NonceSize()), s[32:], nil)
+	}
+	if fileKey == nil {
+		return errors.New("archive: decrypt: no recipient matches the key")
+	}
+	aead, err := payloadAEAD(fileKey, header)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, chunkSize+tagSize)
+	for counter := uint64(0); ; counter++ {
+		n, err := io.ReadFull(br, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := err != nil
+		if !last {
+			if _, err := br.Peek(1); err == io.EOF {
+				last = true
+			} else if err != nil {
+				return err
+			}
+		}
+		out, err := aead.Open(buf[:0], chunkNonce(counter, last), buf[:n], nil)
+		if err != nil {
+			return fmt.Errorf("archive: decrypt: chunk %d: %w", counter, err)
+		}
+		if _, err := w.Write(out); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// wrapAEAD returns the cipher that seals the file key for a recipient,
+// keyed by the shared secret of the ephemeral and recipient keys.
+func wrapAEAD(shared, eph, recipient []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte(nil), eph...), recipient...)
+	return newGCM(hkdfSHA256(shared, salt, "browser-archive file key"))
+}
+
+func payloadAEAD(fileKey, header []byte) (cipher.AEAD, error) {
+	return newGCM(hkdfSHA256(fileKey, header, "browser-archive payload"))
+}
+
+// hkdfSHA256 derives a 32-byte key with HKDF-SHA256 (RFC 5869).
+func hkdfSHA256(secret, salt []byte, info string) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write([]byte(info))
+	expand.Write([]byte{1})
+	return expand.Sum(nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptFile replaces a file in dir with its encryption.
+func encryptFile(dir, name string, recipients []*ecdh.PublicKey) error {
+	in, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(filepath.Join(dir, name+EncryptedSuffix))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := Encrypt(out, in, recipients...); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	in.Close()
+	return os.Remove(filepath.Join(dir, name))
+}
+
+// DecryptArchive decrypts the encrypted outputs of an archive, writing
+// each next to its encryption without the suffix, so the archive can be
+// read or merged.
+func DecryptArchive(dir string, identity *ecdh.PrivateKey) error {
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range m.Files {
+		if !f.Encrypted {
+			continue
+		}
+		if err := decryptFile(dir, f.Name, identity); err != nil {
+			return fmt.Errorf("archive: %s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+func decryptFile(dir, name string, identity *ecdh.PrivateKey) error {
+	in, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	plain := filepath.Join(dir, strings.TrimSuffix(name, EncryptedSuffix))
+	out, err := os.Create(plain)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := Decrypt(out, in, identity); err != nil {
+		out.Close()
+		os.Remove(plain)
+		return err
+	}
+	return out.Close()
+}
+
+// finish encrypts and checksums the outputs of an archive, then writes
+// its manifest, checksums, and signature.
+func (a *Archiver) finish(m *Manifest, names []string) error {
+	for _, name := range names {
+		encrypted := len(a.Recipients) != 0
+		if encrypted {
+			if err := encryptFile(m.Dir, name, a.Recipients); err != nil {
+				return fmt.Errorf("archive: encrypt %s: %w", name, err)
+			}
+			name += EncryptedSuffix
+		}
+		f, err := checksumFile(m.Dir, name)
+		if err != nil {
+			return err
+		}
+		f.Encrypted = encrypted
+		m.Files = append(m.Files, *f)
+	}
+	manifest := filepath.Join(m.Dir, ManifestFile)
+	if err := writeManifest(manifest, m); err != nil {
+		return err
+	}
+	mf, err := checksumFile(m.Dir, ManifestFile)
+	if err != nil {
+		return err
+	}
+	var sums bytes.Buffer
+	for _, f := range append([]OutputFile{*mf}, m.Files...) {
+		fmt.Fprintf(&sums, "%x  %s\n", []byte(f.SHA256), f.Name)
+	}
+	if err := os.WriteFile(filepath.Join(m.Dir, ChecksumsFile), sums.Bytes(), 0o644); err != nil {
+		return err
+	}
+	if a.SigningKey == nil {
+		return nil
+	}
+	b, err := os.ReadFile(manifest)
+	if err != nil {
+		return err
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(a.SigningKey, b))
+	return os.WriteFile(filepath.Join(m.Dir, SignatureFile), []byte(sig+"\n"), 0o644)
+}
+
+// Verify checks the sizes and checksums of the outputs of an archive
+// against its manifest. When key is not nil, it also checks that the
+// manifest is signed by it, so that the checksums can be trusted.
+func Verify(dir string, key ed25519.PublicKey) error {
+	if key != nil {
+		b, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+		if err != nil {
+			return err
+		}
+		sig, err := os.ReadFile(filepath.Join(dir, SignatureFile))
+		if err != nil {
+			return err
+		}
+		sig, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+		if err != nil {
+			return fmt.Errorf("archive: %s: %w", SignatureFile, err)
+		}
+		if !ed25519.Verify(key, b, sig) {
+			return fmt.Errorf("archive: %s: invalid signature", dir)
+		}
+	}
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return err
+	}
+	for _, want := range m.Files {
+		got, err := checksumFile(dir, want.Name)
+		if err != nil {
+			return err
+		}
+		if got.Size != want.Size || !bytes.Equal(got.SHA256, want.SHA256) {
+			return fmt.Errorf("archive: %s: checksum mismatch", want.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package archive
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEncrypt(t *testing.T) {
+	key1, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 2*chunkSize + 5} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+		var enc bytes.Buffer
+		if err := Encrypt(&enc, bytes.NewReader(plain), key1.PublicKey(), key2.PublicKey()); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		for _, key := range []*ecdh.PrivateKey{key1, key2} {
+			var dec bytes.Buffer
+			if err := Decrypt(&dec, bytes.NewReader(enc.Bytes()), key); err != nil {
+				t.Errorf("#%d: %v", i, err)
+			} else if !bytes.Equal(dec.Bytes(), plain) {
+				t.Errorf("#%d: decrypted %d bytes, want %d", i, dec.Len(), size)
+			}
+		}
+		if err := Decrypt(new(bytes.Buffer), bytes.NewReader(enc.Bytes()), other); err == nil {
+			t.Errorf("#%d: decrypted with another key", i)
+		}
+		// Truncating at a chunk boundary or flipping a bit is detected.
+		header := len(encryptedMagic) + 1 + 2*stanzaSize
+		if size > chunkSize {
+			truncated := enc.Bytes()[:header+chunkSize+tagSize]
+			if err := Decrypt(new(bytes.Buffer), bytes.NewReader(truncated), key1); err == nil {
+				t.Errorf("#%d: decrypted truncated file", i)
+			}
+		}
+		corrupt := append([]byte(nil), enc.Bytes()...)
+		corrupt[len(corrupt)-1] ^= 1
+		if err := Decrypt(new(bytes.Buffer), bytes.NewReader(corrupt), key1); err == nil {
+			t.Errorf("#%d: decrypted corrupt file", i)
+		}
+	}
+}
+
+func TestArchiveSealed(t *testing.T) {
+	root := t.TempDir()
+	chromeDir := filepath.Join(root, "chrome")
+	copyFile(t, "../chrome/testdata/corpus/Bookmarks/chrome-88.json", filepath.Join(chromeDir, "Default", "Bookmarks"))
+	identity, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := Archiver{
+		FirefoxDir: filepath.Join(root, "firefox"),
+		ChromeDir:  chromeDir,
+		Machine:    "laptop",
+		Now:        func() time.Time { return time.Date(2021, 2, 18, 15, 4, 5, 0, time.UTC) },
+		Recipients: []*ecdh.PublicKey{identity.PublicKey()},
+		SigningKey: priv,
+	}
+	m, err := a.Archive(filepath.Join(root, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range m.Files {
+		if !f.Encrypted || filepath.Ext(f.Name) != EncryptedSuffix {
+			t.Errorf("got file %+v, want encrypted", f)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(m.Dir, SQLiteFile)); !os.IsNotExist(err) {
+		t.Errorf("plaintext %s remains: %v", SQLiteFile, err)
+	}
+	sums, err := os.ReadFile(filepath.Join(m.Dir, ChecksumsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(sums, []byte("\n")); n != 4 {
+		t.Errorf("got %d checksums, want 4:\n%s", n, sums)
+	}
+	if err := Verify(m.Dir, pub); err != nil {
+		t.Error(err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(m.Dir, otherPub); err == nil {
+		t.Error("verified with another key")
+	}
+
+	if _, err := a.Merge(filepath.Join(root, "merged"), m.Dir); err == nil {
+		t.Error("merged encrypted archive")
+	}
+	if err := DecryptArchive(m.Dir, identity); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(m.Dir, SQLiteFile)); err != nil {
+		t.Error(err)
+	}
+	a.Recipients, a.SigningKey = nil, nil
+	if _, err := a.Merge(filepath.Join(root, "merged"), m.Dir); err != nil {
+		t.Error(err)
+	}
+
+	// Verify detects a modified output.
+	if err := os.WriteFile(filepath.Join(m.Dir, m.Files[0].Name), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(m.Dir, nil); err == nil {
+		t.Error("verified modified output")
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package archive
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser/bookmark"
+	_ "github.com/mattn/go-sqlite3" // register sqlite3 driver
+)
+
+// schema is the schema of archive.sqlite at SchemaVersion. Times are
+// RFC 3339 in UTC, or NULL when unknown. Bookmark folders are the
+// titles of the enclosing folders joined by "/". Visit IDs are those of
+// the source history, which are unique only within a profile and
+// device. Changes to the schema must add a migration.
+const schema = `
+CREATE TABLE profiles (
+	id INTEGER PRIMARY KEY,
+	machine TEXT,
+	browser TEXT NOT NULL,
+	path TEXT NOT NULL
+);
+CREATE TABLE artifacts (
+	profile_id INTEGER NOT NULL REFERENCES profiles(id),
+	name TEXT NOT NULL,
+	error TEXT
+);
+CREATE TABLE visits (
+	profile_id INTEGER NOT NULL REFERENCES profiles(id),
+	url TEXT NOT NULL,
+	title TEXT,
+	time TEXT,
+	transition INTEGER,
+	source TEXT,
+	device TEXT,
+	visit_id INTEGER,
+	from_visit INTEGER
+);
+CREATE INDEX visits_url_time ON visits (url, time);
+CREATE TABLE downloads (
+	profile_id INTEGER NOT NULL REFERENCES profiles(id),
+	url TEXT NOT NULL,
+	referrer TEXT,
+	target_path TEXT,
+	mime_type TEXT,
+	start_time TEXT,
+	end_time TEXT,
+	received_bytes INTEGER,
+	total_bytes INTEGER,
+	state TEXT,
+	source TEXT
+);
+CREATE TABLE bookmarks (
+	profile_id INTEGER NOT NULL REFERENCES profiles(id),
+	folder TEXT NOT NULL,
+	title TEXT,
+	url TEXT NOT NULL,
+	guid TEXT,
+	add_date TEXT
+);
+`
+
+type archiveDB struct {
+	db *sql.DB
+	n  int64 // number of profiles
+}
+
+func createDB(filename string) (*archiveDB, error) {
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return nil, err
+	}
+	// ATTACH applies to a single connection.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := setSchemaVersion(db, SchemaVersion); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &archiveDB{db: db}, nil
+}
+
+func (db *archiveDB) Close() error { return db.db.Close() }
+
+func (db *archiveDB) insertProfile(p *ManifestProfile, c *collected) error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	db.n++
+	id := db.n
+	if _, err := tx.Exec(`INSERT INTO profiles VALUES (?, ?, ?, ?)`, id, nullString(p.Machine), p.Browser, p.Path); err != nil {
+		return err
+	}
+	for _, a := range p.Artifacts {
+		if _, err := tx.Exec(`INSERT INTO artifacts VALUES (?, ?, ?)`, id, a.Name, nullString(a.Error)); err != nil {
+			return err
+		}
+	}
+	for _, v := range c.Visits {
+		if _, err := tx.Exec(`INSERT INTO visits VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, id, v.URL,
+			v.Title, formatTime(v.Time), int64(v.Transition), v.Source, nullString(v.Device),
+			nullInt(v.ID), nullInt(v.FromVisit)); err != nil {
+			return err
+		}
+	}
+	for _, d := range c.Downloads {
+		if _, err := tx.Exec(`INSERT INTO downloads VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, id, d.URL,
+			nullString(d.Referrer), nullString(d.TargetPath), nullString(d.MIMEType),
+			formatTime(d.StartTime), formatTime(d.EndTime), d.ReceivedBytes, d.TotalBytes,
+			d.State.String(), d.Source); err != nil {
+			return err
+		}
+	}
+	if err := insertBookmarks(tx, id, nil, c.Bookmarks); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// merge copies the rows of another archive database at SchemaVersion,
+// skipping visits, downloads, and bookmarks already present. Rows
+// without a machine or device are labeled with machine.
+func (db *archiveDB) merge(filename, machine string) error {
+	if _, err := db.db.Exec(`ATTACH DATABASE ? AS src`, filename); err != nil {
+		return err
+	}
+	defer db.db.Exec(`DETACH DATABASE src`)
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	offset := db.n
+	m := nullString(machine)
+	for _, q := range []string{
+		`INSERT INTO main.profiles
+			SELECT id + ?1, coalesce(machine, ?2), browser, path FROM src.profiles`,
+		`INSERT INTO main.artifacts
+			SELECT profile_id + ?1, name, error FROM src.artifacts`,
+		`INSERT INTO main.visits
+			SELECT profile_id + ?1, url, title, time, transition, source, coalesce(device, ?2),
+				visit_id, from_visit
+			FROM src.visits s WHERE NOT EXISTS (SELECT 1 FROM main.visits v
+				WHERE v.url = s.url AND v.time IS s.time)`,
+		`INSERT INTO main.downloads
+			SELECT profile_id + ?1, url, referrer, target_path, mime_type, start_time,
+				end_time, received_bytes, total_bytes, state, source
+			FROM src.downloads s WHERE NOT EXISTS (SELECT 1 FROM main.downloads d
+				WHERE d.url = s.url AND d.start_time IS s.start_time)`,
+		`INSERT INTO main.bookmarks
+			SELECT profile_id + ?1, folder, title, url, guid, add_date
+			FROM src.bookmarks s WHERE s.guid IS NULL OR NOT EXISTS (SELECT 1 FROM main.bookmarks b
+				WHERE b.guid = s.guid AND b.url = s.url)`,
+	} {
+		if _, err := tx.Exec(q, offset, m); err != nil {
+			return err
+		}
+	}
+	if err := tx.QueryRow(`SELECT coalesce(max(id), 0) FROM main.profiles`).Scan(&db.n); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertBookmarks(tx *sql.Tx, id int64, folders []string, entries []bookmark.BookmarkEntry) error {
+	for _, e := range entries {
+		switch e := e.(type) {
+		case *bookmark.BookmarkFolder:
+			if err := insertBookmarks(tx, id, append(folders, e.Title), e.Entries); err != nil {
+				return err
+			}
+		case *bookmark.Bookmark:
+			if _, err := tx.Exec(`INSERT INTO bookmarks VALUES (?, ?, ?, ?, ?, ?)`, id,
+				strings.Join(folders, "/"), e.Title, e.URL, nullString(e.GUID), formatTime(e.AddDate)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nullInt(n int64) interface{} {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+func formatTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
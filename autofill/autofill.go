@@ -0,0 +1,244 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package autofill converts the addresses and credit cards saved for
+// form autofill by browsers into a common model.
+package autofill
+
+import (
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/firefox"
+	"github.com/andrewarchi/browser/takeout"
+)
+
+// Address is a saved address, independent of the browser that it was
+// read from. Fields not recorded by a source are empty.
+type Address struct {
+	GUID              string
+	Name              string // full name
+	GivenName         string
+	AdditionalName    string
+	FamilyName        string
+	Organization      string
+	StreetAddress     string // lines separated by "\n"
+	DependentLocality string
+	Locality          string // e.g. city
+	Region            string // e.g. state or province
+	PostalCode        string
+	SortingCode       string
+	Country           string // ISO 3166-1 alpha-2 code, e.g. "US"
+	Phone             string
+	Email             string
+	UseCount          int
+	Created           time.Time
+	LastUsed          time.Time
+	Modified          time.Time
+	Browser           string // e.g. "chrome" or "firefox"
+	Source            string // source read from, e.g. "autofill-profiles.json" or "takeout"
+}
+
+// CreditCard is a saved credit card, independent of the browser that
+// it was read from. The full number is only available encrypted, in a
+// browser-specific format.
+type CreditCard struct {
+	GUID            string
+	Name            string // name on card
+	Network         string // e.g. "visa" or "mastercard"
+	LastFour        string
+	EncryptedNumber string
+	ExpMonth        int
+	ExpYear         int
+	UseCount        int
+	Created         time.Time
+	LastUsed        time.Time
+	Modified        time.Time
+	Browser         string
+	Source          string
+}
+
+// Browsers and sources:
+const (
+	BrowserChrome  = "chrome"
+	BrowserFirefox = "firefox"
+
+	SourceFirefoxProfiles = "autofill-profiles.json"
+	SourceChromeWebData   = chrome.WebDataFile
+	SourceTakeout         = "takeout"
+)
+
+// FromFirefox converts the addresses and credit cards in
+// autofill-profiles.json. Deleted records are dropped.
+func FromFirefox(p *firefox.AutofillProfiles) ([]Address, []CreditCard) {
+	var addresses []Address
+	for _, a := range p.Addresses {
+		if a.Deleted {
+			continue
+		}
+		name := a.Name
+		if name == "" {
+			name = joinNonEmpty(" ", a.GivenName, a.AdditionalName, a.FamilyName)
+		}
+		addresses = append(addresses, Address{
+			GUID:              a.GUID,
+			Name:              name,
+			GivenName:         a.GivenName,
+			AdditionalName:    a.AdditionalName,
+			FamilyName:        a.FamilyName,
+			Organization:      a.Organization,
+			StreetAddress:     a.StreetAddress,
+			DependentLocality: a.AddressLevel3,
+			Locality:          a.AddressLevel2,
+			Region:            a.AddressLevel1,
+			PostalCode:        a.PostalCode,
+			Country:           a.Country,
+			Phone:             a.Tel,
+			Email:             a.Email,
+			UseCount:          a.TimesUsed,
+			Created:           a.TimeCreated.Time,
+			LastUsed:          a.TimeLastUsed.Time,
+			Modified:          a.TimeLastModified.Time,
+			Browser:           BrowserFirefox,
+			Source:            SourceFirefoxProfiles,
+		})
+	}
+	var cards []CreditCard
+	for _, c := range p.CreditCards {
+		if c.Deleted {
+			continue
+		}
+		cards = append(cards, CreditCard{
+			GUID:            c.GUID,
+			Name:            c.CCName,
+			Network:         c.CCType,
+			LastFour:        c.LastFour(),
+			EncryptedNumber: c.CCNumberEncrypted,
+			ExpMonth:        c.CCExpMonth,
+			ExpYear:         c.CCExpYear,
+			UseCount:        c.TimesUsed,
+			Created:         c.TimeCreated.Time,
+			LastUsed:        c.TimeLastUsed.Time,
+			Modified:        c.TimeLastModified.Time,
+			Browser:         BrowserFirefox,
+			Source:          SourceFirefoxProfiles,
+		})
+	}
+	return addresses, cards
+}
+
+// FromChrome converts the autofill profiles and credit cards in the Web
+// Data database of a Chrome profile. Profiles with several names,
+// emails, or phone numbers use the first of each. Encrypted card
+// numbers are base64-encoded and can be decrypted by
+// chrome.CreditCard.DecryptNumber.
+func FromChrome(profiles []chrome.AutofillProfile, cards []chrome.CreditCard) ([]Address, []CreditCard) {
+	addresses := make([]Address, 0, len(profiles))
+	for i := range profiles {
+		p := &profiles[i]
+		a := Address{
+			GUID:              p.GUID,
+			Organization:      p.CompanyName,
+			StreetAddress:     p.StreetAddress,
+			DependentLocality: p.DependentLocality,
+			Locality:          p.City,
+			Region:            p.State,
+			PostalCode:        p.Zipcode,
+			SortingCode:       p.SortingCode,
+			Country:           p.CountryCode,
+			UseCount:          p.UseCount,
+			LastUsed:          p.UseDate,
+			Modified:          p.DateModified,
+			Browser:           BrowserChrome,
+			Source:            SourceChromeWebData,
+		}
+		if len(p.Names) != 0 {
+			n := p.Names[0]
+			a.Name = n.Full
+			if a.Name == "" {
+				a.Name = joinNonEmpty(" ", n.First, n.Middle, n.Last)
+			}
+			a.GivenName, a.AdditionalName, a.FamilyName = n.First, n.Middle, n.Last
+		}
+		a.Email = first(p.Emails)
+		a.Phone = first(p.Phones)
+		addresses = append(addresses, a)
+	}
+	creditCards := make([]CreditCard, 0, len(cards))
+	for i := range cards {
+		c := &cards[i]
+		creditCards = append(creditCards, CreditCard{
+			GUID:            c.GUID,
+			Name:            c.NameOnCard,
+			EncryptedNumber: base64.StdEncoding.EncodeToString(c.CardNumberEncrypted),
+			ExpMonth:        c.ExpirationMonth,
+			ExpYear:         c.ExpirationYear,
+			UseCount:        c.UseCount,
+			LastUsed:        c.UseDate,
+			Modified:        c.DateModified,
+			Browser:         BrowserChrome,
+			Source:          SourceChromeWebData,
+		})
+	}
+	return addresses, creditCards
+}
+
+// FromTakeout converts the autofill profiles in a Takeout export of
+// Chrome. Profiles with several names, emails, or phone numbers use
+// the first of each.
+func FromTakeout(data *takeout.Chrome) []Address {
+	profiles := append(append([]takeout.AutofillProfile{}, data.Autofill...), data.AutofillProfile...)
+	addresses := make([]Address, 0, len(profiles))
+	for _, p := range profiles {
+		a := Address{
+			Name:              first(p.NameFull),
+			GivenName:         first(p.NameFirst),
+			AdditionalName:    first(p.NameMiddle),
+			FamilyName:        first(p.NameLast),
+			Organization:      p.CompanyName,
+			StreetAddress:     p.AddressHomeStreetAddress,
+			DependentLocality: p.AddressHomeDependentLocality,
+			Locality:          p.AddressHomeCity,
+			Region:            p.AddressHomeState,
+			PostalCode:        p.AddressHomeZip,
+			SortingCode:       p.AddressHomeSortingCode,
+			Country:           p.AddressHomeCountry,
+			Phone:             first(p.PhoneHomeWholeNumber),
+			Email:             first(p.EmailAddress),
+			UseCount:          p.UseCount,
+			LastUsed:          p.UseDate.Time,
+			Browser:           BrowserChrome,
+			Source:            SourceTakeout,
+		}
+		if p.GUID != nil {
+			a.GUID = p.GUID.String()
+		}
+		if a.StreetAddress == "" {
+			a.StreetAddress = joinNonEmpty("\n", p.AddressHomeLine1, p.AddressHomeLine2)
+		}
+		addresses = append(addresses, a)
+	}
+	return addresses
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func joinNonEmpty(sep string, elems ...string) string {
+	nonEmpty := elems[:0:0]
+	for _, e := range elems {
+		if e != "" {
+			nonEmpty = append(nonEmpty, e)
+		}
+	}
+	return strings.Join(nonEmpty, sep)
+}
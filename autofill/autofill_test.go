@@ -0,0 +1,118 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package autofill
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/firefox"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/takeout"
+)
+
+func TestFromFirefox(t *testing.T) {
+	const data = `{
+		"version": 1,
+		"addresses": [
+			{"guid": "a1", "version": 1, "given-name": "Jane", "family-name": "Doe", "organization": "Example",
+				"street-address": "1 Main St\nApt 2", "address-level2": "Springfield", "address-level1": "IL",
+				"postal-code": "62701", "country": "US", "tel": "+15555550100", "email": "jane@example.com",
+				"timeCreated": 1613610123000, "timeLastUsed": 1613610124000, "timeLastModified": 1613610125000,
+				"timesUsed": 2, "_sync": {"changeCounter": 1}},
+			{"guid": "a2", "timeLastModified": 1613610126000, "deleted": true}
+		],
+		"creditCards": [
+			{"guid": "c1", "version": 3, "cc-name": "Jane Doe", "cc-number": "************1234",
+				"cc-number-encrypted": "ZW5jcnlwdGVk", "cc-exp-month": 12, "cc-exp-year": 2025, "cc-type": "visa",
+				"timeCreated": 1613610123000, "timeLastUsed": 0, "timeLastModified": 1613610123000, "timesUsed": 0}
+		]
+	}`
+	filename := filepath.Join(t.TempDir(), "autofill-profiles.json")
+	if err := os.WriteFile(filename, []byte(data), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	p, err := firefox.ParseAutofillProfiles(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addresses, cards := FromFirefox(p)
+	msec := func(ms int64) time.Time { return time.Unix(0, ms*1e6).UTC() }
+	wantAddresses := []Address{{
+		GUID: "a1", Name: "Jane Doe", GivenName: "Jane", FamilyName: "Doe", Organization: "Example",
+		StreetAddress: "1 Main St\nApt 2", Locality: "Springfield", Region: "IL", PostalCode: "62701",
+		Country: "US", Phone: "+15555550100", Email: "jane@example.com", UseCount: 2,
+		Created: msec(1613610123000), LastUsed: msec(1613610124000), Modified: msec(1613610125000),
+		Browser: BrowserFirefox, Source: SourceFirefoxProfiles,
+	}}
+	wantCards := []CreditCard{{
+		GUID: "c1", Name: "Jane Doe", Network: "visa", LastFour: "1234", EncryptedNumber: "ZW5jcnlwdGVk",
+		ExpMonth: 12, ExpYear: 2025, Created: msec(1613610123000), Modified: msec(1613610123000),
+		Browser: BrowserFirefox, Source: SourceFirefoxProfiles,
+	}}
+	if !reflect.DeepEqual(addresses, wantAddresses) {
+		t.Errorf("got addresses:\n%+v\nwant:\n%+v", addresses, wantAddresses)
+	}
+	if !reflect.DeepEqual(cards, wantCards) {
+		t.Errorf("got cards:\n%+v\nwant:\n%+v", cards, wantCards)
+	}
+}
+
+func TestFromChrome(t *testing.T) {
+	modified, used := time.Unix(1613610125, 0).UTC(), time.Unix(1613610126, 0).UTC()
+	profiles := []chrome.AutofillProfile{{
+		GUID: "p1", CompanyName: "Example", StreetAddress: "1 Main St", City: "Springfield",
+		State: "IL", Zipcode: "62701", CountryCode: "US", DateModified: modified, UseCount: 4, UseDate: used,
+		Names:  []chrome.AutofillName{{First: "Jane", Last: "Doe"}, {Full: "J. Doe"}},
+		Emails: []string{"jane@example.com"},
+	}}
+	cards := []chrome.CreditCard{{
+		GUID: "c1", NameOnCard: "Jane Doe", ExpirationMonth: 12, ExpirationYear: 2025,
+		CardNumberEncrypted: []byte("v10encrypted"), DateModified: modified,
+	}}
+	addresses, creditCards := FromChrome(profiles, cards)
+	wantAddresses := []Address{{
+		GUID: "p1", Name: "Jane Doe", GivenName: "Jane", FamilyName: "Doe", Organization: "Example",
+		StreetAddress: "1 Main St", Locality: "Springfield", Region: "IL", PostalCode: "62701", Country: "US",
+		Email: "jane@example.com", UseCount: 4, LastUsed: used, Modified: modified,
+		Browser: BrowserChrome, Source: SourceChromeWebData,
+	}}
+	wantCards := []CreditCard{{
+		GUID: "c1", Name: "Jane Doe", EncryptedNumber: "djEwZW5jcnlwdGVk", ExpMonth: 12, ExpYear: 2025,
+		Modified: modified, Browser: BrowserChrome, Source: SourceChromeWebData,
+	}}
+	if !reflect.DeepEqual(addresses, wantAddresses) {
+		t.Errorf("got addresses:\n%+v\nwant:\n%+v", addresses, wantAddresses)
+	}
+	if !reflect.DeepEqual(creditCards, wantCards) {
+		t.Errorf("got cards:\n%+v\nwant:\n%+v", creditCards, wantCards)
+	}
+}
+
+func TestFromTakeout(t *testing.T) {
+	used := time.Unix(1613610123, 0).UTC()
+	data := &takeout.Chrome{AutofillProfile: []takeout.AutofillProfile{{
+		NameFull:         []string{"Jane Doe", "J. Doe"},
+		AddressHomeLine1: "1 Main St",
+		AddressHomeLine2: "Apt 2",
+		AddressHomeCity:  "Springfield",
+		EmailAddress:     []string{"jane@example.com"},
+		UseCount:         3,
+		UseDate:          timefmt.UnixSec{Time: used},
+	}}}
+	want := []Address{{
+		Name: "Jane Doe", StreetAddress: "1 Main St\nApt 2", Locality: "Springfield",
+		Email: "jane@example.com", UseCount: 3, LastUsed: used, Browser: BrowserChrome, Source: SourceTakeout,
+	}}
+	if got := FromTakeout(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got, want)
+	}
+}
@@ -4,13 +4,16 @@
 // License, v. 2.0. If a copy of the MPL was not distributed with this
 // file, You can obtain one at http://mozilla.org/MPL/2.0/.
 
-// Package bookmark parses Netscape-style HTML bookmark files.
+// Package bookmark models bookmark trees and reads and writes
+// Netscape-style HTML bookmark files and Markdown link lists.
 package bookmark
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
@@ -29,20 +32,45 @@ import (
 // Other services appear to use fields not used by
 // Chrome bookmarks in Google Takeout.
 
-type BookmarkEntry interface{} // BookmarkFolder or Bookmark
+type BookmarkEntry interface{} // BookmarkFolder, Bookmark, or BookmarkSeparator
 
+// BookmarkFolder is a folder of bookmarks. Entries are kept in their
+// original order.
 type BookmarkFolder struct {
 	Title        string
+	GUID         string // sync identity, when known
 	AddDate      time.Time
 	LastModified time.Time
+	Attrs        []Attr // other attributes, preserved when written
 	Entries      []BookmarkEntry
 }
 
+// Bookmark is a bookmarked URL.
 type Bookmark struct {
-	Title   string
-	URL     string
-	AddDate time.Time
-	IconURI string
+	Title        string
+	URL          string
+	GUID         string // sync identity, when known
+	AddDate      time.Time
+	LastModified time.Time
+	IconURI      string
+	Tags         []string
+	Keyword      string // shortcut for the URL in the address bar
+	Attrs        []Attr // other attributes, preserved when written
+}
+
+// BookmarkSeparator is a horizontal line between entries in a folder.
+type BookmarkSeparator struct {
+	GUID         string // sync identity, when known
+	AddDate      time.Time
+	LastModified time.Time
+}
+
+// Attr is an attribute of a bookmark or folder that has no
+// corresponding field, such as ICON or LAST_CHARSET in Firefox
+// exports. Attributes are kept in document order with lowercase keys.
+type Attr struct {
+	Key string
+	Val string
 }
 
 // ParseHTML parses a Netscape-style HTML bookmark file.
@@ -77,56 +105,173 @@ func checkDoctype(doc *goquery.Document, doctype string) error {
 	return errors.New("bookmark: doctype not found")
 }
 
+// Units of ADD_DATE and LAST_MODIFIED. These follow Chrome bookmarks
+// in Google Takeout, which uses different units for folders and
+// bookmarks.
+const (
+	folderUnit    = timefmt.Milli
+	folderEpoch   = timefmt.Unix
+	bookmarkUnit  = timefmt.Micro
+	bookmarkEpoch = timefmt.Windows
+)
+
 func parseFolder(dt *goquery.Selection) (*BookmarkFolder, error) {
 	h3 := dt.ChildrenFiltered("h3").First()
-	add := h3.AttrOr("add_date", "")
-	mod := h3.AttrOr("last_modified", "")
-	addDate, err := timefmt.Parse(add, timefmt.Milli, timefmt.Unix)
-	if err != nil {
-		return nil, err
+	f := &BookmarkFolder{Title: h3.Text()}
+	for _, attr := range h3.Nodes[0].Attr {
+		var err error
+		switch attr.Key {
+		case "add_date":
+			f.AddDate, err = timefmt.Parse(attr.Val, folderUnit, folderEpoch)
+		case "last_modified":
+			f.LastModified, err = timefmt.Parse(attr.Val, folderUnit, folderEpoch)
+		case "guid":
+			f.GUID = attr.Val
+		default:
+			f.Attrs = append(f.Attrs, Attr{attr.Key, attr.Val})
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
-	lastModified, err := timefmt.Parse(mod, timefmt.Milli, timefmt.Unix)
+	entries, err := parseFolderList(dt.ChildrenFiltered("dl").First())
 	if err != nil {
 		return nil, err
 	}
-	entries, err := parseFolderList(dt.ChildrenFiltered("dl").First())
-	f := &BookmarkFolder{
-		Title:        h3.Text(),
-		AddDate:      addDate,
-		LastModified: lastModified,
-		Entries:      entries,
-	}
+	f.Entries = entries
 	return f, nil
 }
 
+func parseBookmark(a *goquery.Selection) (*Bookmark, error) {
+	b := &Bookmark{Title: a.Text()}
+	for _, attr := range a.Nodes[0].Attr {
+		var err error
+		switch attr.Key {
+		case "href":
+			b.URL = attr.Val
+		case "add_date":
+			b.AddDate, err = timefmt.Parse(attr.Val, bookmarkUnit, bookmarkEpoch)
+		case "last_modified":
+			b.LastModified, err = timefmt.Parse(attr.Val, bookmarkUnit, bookmarkEpoch)
+		case "tags":
+			if attr.Val != "" {
+				b.Tags = strings.Split(attr.Val, ",")
+			}
+		case "shortcuturl":
+			b.Keyword = attr.Val
+		case "icon_uri":
+			b.IconURI = attr.Val
+		case "guid":
+			b.GUID = attr.Val
+		default:
+			b.Attrs = append(b.Attrs, Attr{attr.Key, attr.Val})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
 func parseFolderList(dl *goquery.Selection) ([]BookmarkEntry, error) {
 	var err error
-	children := dl.ChildrenFiltered("dt")
+	children := dl.ChildrenFiltered("dt, hr")
 	entries := make([]BookmarkEntry, 0, children.Length())
 	children.EachWithBreak(func(_ int, dt *goquery.Selection) bool {
 		var e BookmarkEntry
-		a := dt.ChildrenFiltered("a").First()
-		if a.Length() == 0 {
+		if goquery.NodeName(dt) == "hr" {
+			e = &BookmarkSeparator{}
+		} else if a := dt.ChildrenFiltered("a").First(); a.Length() == 0 {
 			e, err = parseFolder(dt)
-			if err != nil {
-				return false
-			}
 		} else {
-			var addDate time.Time
-			add := a.AttrOr("add_date", "0")
-			addDate, err = timefmt.Parse(add, timefmt.Micro, timefmt.Windows)
-			if err != nil {
-				return false
-			}
-			e = &Bookmark{
-				Title:   a.Text(),
-				URL:     a.AttrOr("href", ""),
-				AddDate: addDate,
-				IconURI: a.AttrOr("icon_uri", ""),
-			}
+			e, err = parseBookmark(a)
+		}
+		if err != nil {
+			return false
 		}
 		entries = append(entries, e)
+		// The HTML parser nests an HR following an unclosed DT within it.
+		dt.ChildrenFiltered("hr").Each(func(_ int, _ *goquery.Selection) {
+			entries = append(entries, &BookmarkSeparator{})
+		})
 		return true
 	})
 	return entries, err
 }
+
+// WriteHTML writes bookmarks as a Netscape-style HTML bookmark file,
+// which ParseHTML reads back to the same entries, in the same order.
+// GUIDs are written as a nonstandard GUID attribute. Separators are
+// written as HR elements, which have no attributes.
+func WriteHTML(w io.Writer, entries []BookmarkEntry) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+`)
+	if err := writeFolderList(bw, entries, 0); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func writeFolderList(w *bufio.Writer, entries []BookmarkEntry, depth int) error {
+	indent := strings.Repeat("    ", depth)
+	w.WriteString(indent + "<DL><p>\n")
+	for _, e := range entries {
+		if _, ok := e.(*BookmarkSeparator); ok {
+			w.WriteString(indent + "    <HR>\n")
+			continue
+		}
+		w.WriteString(indent + "    <DT>")
+		switch e := e.(type) {
+		case *BookmarkFolder:
+			w.WriteString("<H3")
+			writeTime(w, "ADD_DATE", e.AddDate, folderUnit, folderEpoch)
+			writeTime(w, "LAST_MODIFIED", e.LastModified, folderUnit, folderEpoch)
+			writeAttr(w, "GUID", e.GUID)
+			writeAttrs(w, e.Attrs)
+			w.WriteString(">" + html.EscapeString(e.Title) + "</H3>\n")
+			if err := writeFolderList(w, e.Entries, depth+1); err != nil {
+				return err
+			}
+		case *Bookmark:
+			w.WriteString("<A")
+			writeAttr(w, "HREF", e.URL)
+			writeTime(w, "ADD_DATE", e.AddDate, bookmarkUnit, bookmarkEpoch)
+			writeTime(w, "LAST_MODIFIED", e.LastModified, bookmarkUnit, bookmarkEpoch)
+			writeAttr(w, "ICON_URI", e.IconURI)
+			writeAttr(w, "SHORTCUTURL", e.Keyword)
+			writeAttr(w, "TAGS", strings.Join(e.Tags, ","))
+			writeAttr(w, "GUID", e.GUID)
+			writeAttrs(w, e.Attrs)
+			w.WriteString(">" + html.EscapeString(e.Title) + "</A>\n")
+		default:
+			return fmt.Errorf("bookmark: illegal entry type: %T", e)
+		}
+	}
+	w.WriteString(indent + "</DL><p>\n")
+	return nil
+}
+
+func writeAttr(w *bufio.Writer, key, val string) {
+	if val != "" {
+		w.WriteString(" " + key + `="` + html.EscapeString(val) + `"`)
+	}
+}
+
+func writeTime(w *bufio.Writer, key string, t time.Time, unit timefmt.Unit, epoch timefmt.Epoch) {
+	if !t.IsZero() {
+		writeAttr(w, key, timefmt.Format(t, unit, epoch))
+	}
+}
+
+func writeAttrs(w *bufio.Writer, attrs []Attr) {
+	for _, attr := range attrs {
+		w.WriteString(" " + strings.ToUpper(attr.Key) + `="` + html.EscapeString(attr.Val) + `"`)
+	}
+}
@@ -7,9 +7,12 @@
 package bookmark
 
 import (
+	"bytes"
 	"encoding/json"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestBookmarks(t *testing.T) {
@@ -28,3 +31,36 @@ func TestBookmarks(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestHTMLRoundTrip(t *testing.T) {
+	add := time.Date(2021, 2, 18, 1, 2, 3, 456000000, time.UTC)
+	entries := []BookmarkEntry{
+		&BookmarkFolder{
+			Title:        "Toolbar & <more>",
+			GUID:         "toolbar_____",
+			AddDate:      add,
+			LastModified: add.Add(time.Hour),
+			Attrs:        []Attr{{"personal_toolbar_folder", "true"}},
+			Entries: []BookmarkEntry{
+				&Bookmark{Title: "Z", URL: "https://z.example/", GUID: "zzzzzzzzzzzz", AddDate: add},
+				&Bookmark{Title: "A", URL: "https://a.example/?q=\"x\"", Keyword: "a", Tags: []string{"x", "y"}, Attrs: []Attr{{"last_charset", "UTF-8"}}},
+				&BookmarkSeparator{},
+				&BookmarkFolder{Title: "Empty", Entries: []BookmarkEntry{}},
+			},
+		},
+		&Bookmark{Title: "M", URL: "https://m.example/", IconURI: "https://m.example/favicon.ico"},
+	}
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseHTML(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		gotJSON, _ := json.MarshalIndent(got, "", "  ")
+		wantJSON, _ := json.MarshalIndent(entries, "", "  ")
+		t.Errorf("got:\n%s\nwant:\n%s", gotJSON, wantJSON)
+	}
+}
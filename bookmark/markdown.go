@@ -0,0 +1,339 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package bookmark
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Markdown bookmarks are nested lists of links, as commonly kept in
+// curated link collections:
+//
+//	# Reading
+//
+//	- [The Go Blog](https://go.dev/blog/) - official blog
+//	- Tutorials
+//	  - [A Tour of Go](https://go.dev/tour/)
+//	  - <https://gobyexample.com/>
+//	- ---
+//
+// Top-level folders are headings and other folders are list items
+// without a link, followed by a nested list of their entries.
+// Separators are thematic breaks. Text after a link, following " - " or
+// ": ", is the description. Only titles, URLs, descriptions, and the
+// order of entries are kept.
+
+// attrDescription is the attribute for the description of a bookmark
+// in Markdown.
+const attrDescription = "description"
+
+var (
+	mdHeading  = regexp.MustCompile(`^(#{1,6})(?:[ \t]+(.*?))??(?:[ \t]+#+)?[ \t]*$`)
+	mdListItem = regexp.MustCompile(`^([ \t]*)(?:[-*+]|[0-9]{1,9}[.)])(?:[ \t]+(.*))?$`)
+	mdBreak    = regexp.MustCompile(`^(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	mdBareURL  = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*:\S+$`)
+)
+
+// ParseMarkdown parses bookmarks from nested Markdown lists of links.
+// Headings become folders, nested by level, and list items without a
+// link become folders of their nested lists. Other text is ignored.
+func ParseMarkdown(r io.Reader) ([]BookmarkEntry, error) {
+	type listLevel struct {
+		indent int
+		folder *BookmarkFolder
+	}
+	root := &BookmarkFolder{}
+	headings := []*BookmarkFolder{root} // index is heading level
+	var lists []listLevel
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimRight(s.Text(), " \t\r")
+		if text == "" {
+			continue
+		}
+		section := headings[len(headings)-1]
+		if m := mdHeading.FindStringSubmatch(text); m != nil {
+			level := len(m[1])
+			for len(headings) > level {
+				headings = headings[:len(headings)-1]
+			}
+			parent := headings[len(headings)-1]
+			for len(headings) < level {
+				headings = append(headings, parent) // skipped levels
+			}
+			f := &BookmarkFolder{Title: unescapeMarkdown(m[2])}
+			parent.Entries = append(parent.Entries, f)
+			headings = append(headings, f)
+			lists = lists[:0]
+			continue
+		}
+		if mdBreak.MatchString(text) {
+			section.Entries = append(section.Entries, &BookmarkSeparator{})
+			lists = lists[:0]
+			continue
+		}
+		m := mdListItem.FindStringSubmatch(text)
+		if m == nil {
+			continue // paragraph text
+		}
+		indent := len(strings.ReplaceAll(m[1], "\t", "    "))
+		for len(lists) != 0 && lists[len(lists)-1].indent >= indent {
+			lists = lists[:len(lists)-1]
+		}
+		parent := section
+		if len(lists) != 0 {
+			parent = lists[len(lists)-1].folder
+		}
+		e, err := parseMarkdownItem(m[2])
+		if err != nil {
+			return nil, fmt.Errorf("bookmark: markdown line %d: %w", line, err)
+		}
+		parent.Entries = append(parent.Entries, e)
+		if f, ok := e.(*BookmarkFolder); ok {
+			lists = append(lists, listLevel{indent, f})
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return root.Entries, nil
+}
+
+// parseMarkdownItem parses the content of a list item as a link,
+// separator, or folder title.
+func parseMarkdownItem(item string) (BookmarkEntry, error) {
+	switch {
+	case mdBreak.MatchString(item):
+		return &BookmarkSeparator{}, nil
+	case strings.HasPrefix(item, "<"):
+		if i := strings.IndexByte(item, '>'); i != -1 && !strings.ContainsAny(item[1:i], " \t<") {
+			return markdownBookmark("", item[1:i], item[i+1:]), nil
+		}
+	case strings.HasPrefix(item, "["):
+		title, dest, rest, err := parseMarkdownLink(item)
+		if err != nil {
+			return nil, err
+		}
+		return markdownBookmark(title, dest, rest), nil
+	case mdBareURL.MatchString(item) && strings.Contains(item, "://"):
+		return &Bookmark{URL: item}, nil
+	}
+	return &BookmarkFolder{Title: unescapeMarkdown(item)}, nil
+}
+
+func markdownBookmark(title, url, rest string) *Bookmark {
+	b := &Bookmark{Title: title, URL: url}
+	rest = strings.TrimSpace(rest)
+	for _, sep := range []string{"- ", "– ", "— ", ": "} {
+		if strings.HasPrefix(rest, sep) {
+			rest = strings.TrimSpace(rest[len(sep):])
+			break
+		}
+	}
+	if rest != "" {
+		b.Attrs = []Attr{{attrDescription, unescapeMarkdown(rest)}}
+	}
+	return b
+}
+
+// parseMarkdownLink parses an inline link at the start of s, returning
+// the text following it. The link title, if any, is discarded.
+func parseMarkdownLink(s string) (text, dest, rest string, err error) {
+	depth := 0
+	i := 0
+	for ; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == '[' {
+			depth++
+		} else if s[i] == ']' {
+			depth--
+			if depth == 0 {
+				break
+			}
+		}
+	}
+	if i >= len(s) || i+1 >= len(s) || s[i+1] != '(' {
+		return "", "", "", fmt.Errorf("malformed link: %q", s)
+	}
+	text = unescapeMarkdown(s[1:i])
+	s = s[i+2:]
+	if strings.HasPrefix(s, "<") {
+		j := strings.IndexByte(s, '>')
+		if j == -1 {
+			return "", "", "", fmt.Errorf("unclosed link destination: %q", s)
+		}
+		dest, s = s[1:j], s[j+1:]
+	} else {
+		depth, j := 0, 0
+	loop:
+		for ; j < len(s); j++ {
+			switch s[j] {
+			case '\\':
+				j++
+			case '(':
+				depth++
+			case ')':
+				if depth == 0 {
+					break loop
+				}
+				depth--
+			case ' ', '\t':
+				break loop
+			}
+		}
+		dest, s = unescapeMarkdown(s[:j]), s[j:]
+	}
+	end := strings.IndexByte(s, ')')
+	if end == -1 {
+		return "", "", "", fmt.Errorf("unclosed link: %q", s)
+	}
+	return text, dest, s[end+1:], nil
+}
+
+// WriteMarkdown writes bookmarks as nested Markdown lists of links,
+// which ParseMarkdown reads back to the same titles, URLs, and order.
+// Top-level folders are written as headings when no bookmarks or
+// separators follow them at the top level; otherwise the top level is
+// written as a list.
+func WriteMarkdown(w io.Writer, entries []BookmarkEntry) error {
+	bw := bufio.NewWriter(w)
+	headings := true
+	folder := false
+	for _, e := range entries {
+		if _, ok := e.(*BookmarkFolder); ok {
+			folder = true
+		} else if folder {
+			headings = false
+		}
+	}
+	if !headings {
+		if err := writeMarkdownList(bw, entries, 0); err != nil {
+			return err
+		}
+		return bw.Flush()
+	}
+	started := false
+	for _, e := range entries {
+		f, ok := e.(*BookmarkFolder)
+		if !ok {
+			if err := writeMarkdownList(bw, []BookmarkEntry{e}, 0); err != nil {
+				return err
+			}
+			started = true
+			continue
+		}
+		if started {
+			bw.WriteString("\n")
+		}
+		started = true
+		bw.WriteString("# " + escapeMarkdown(f.Title) + "\n")
+		if len(f.Entries) != 0 {
+			bw.WriteString("\n")
+		}
+		if err := writeMarkdownList(bw, f.Entries, 0); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func writeMarkdownList(w *bufio.Writer, entries []BookmarkEntry, depth int) error {
+	indent := strings.Repeat("  ", depth)
+	for _, e := range entries {
+		switch e := e.(type) {
+		case *BookmarkFolder:
+			w.WriteString(indent + "- " + escapeMarkdown(e.Title) + "\n")
+			if err := writeMarkdownList(w, e.Entries, depth+1); err != nil {
+				return err
+			}
+		case *Bookmark:
+			w.WriteString(indent + "- ")
+			if e.Title == "" {
+				w.WriteString("<" + e.URL + ">")
+			} else {
+				w.WriteString("[" + escapeMarkdown(e.Title) + "](" + markdownDest(e.URL) + ")")
+			}
+			for _, attr := range e.Attrs {
+				if attr.Key == attrDescription && attr.Val != "" {
+					w.WriteString(" - " + escapeMarkdown(attr.Val))
+				}
+			}
+			w.WriteString("\n")
+		case *BookmarkSeparator:
+			w.WriteString(indent + "- ---\n")
+		default:
+			return fmt.Errorf("bookmark: illegal entry type: %T", e)
+		}
+	}
+	return nil
+}
+
+// markdownDest formats a URL as a link destination, wrapping it in
+// angle brackets when it has spaces or unbalanced parentheses.
+func markdownDest(url string) string {
+	depth := 0
+	for _, c := range url {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ' ', '\t', '\\':
+			depth = -1
+		}
+		if depth < 0 {
+			return "<" + url + ">"
+		}
+	}
+	if depth != 0 {
+		return "<" + url + ">"
+	}
+	return url
+}
+
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`, `<`, `\<`, `*`, `\*`, `_`, `\_`, "`", "\\`")
+
+// escapeMarkdown escapes text so that it is not parsed as markup.
+func escapeMarkdown(text string) string {
+	text = markdownEscaper.Replace(text)
+	// Escape text that would otherwise start a heading, a list, or a
+	// thematic break, or be read as a bare URL.
+	if strings.HasPrefix(text, "#") || strings.HasPrefix(text, "-") || strings.HasPrefix(text, "+") {
+		text = `\` + text
+	} else if mdBareURL.MatchString(text) && strings.Contains(text, "://") {
+		text = strings.Replace(text, ":", `\:`, 1)
+	}
+	return text
+}
+
+// unescapeMarkdown removes backslash escapes of ASCII punctuation.
+func unescapeMarkdown(text string) string {
+	if strings.IndexByte(text, '\\') == -1 {
+		return text
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) && isASCIIPunct(text[i+1]) {
+			i++
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+func isASCIIPunct(c byte) bool {
+	return '!' <= c && c <= '/' || ':' <= c && c <= '@' || '[' <= c && c <= '`' || '{' <= c && c <= '~'
+}
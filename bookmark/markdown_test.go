@@ -0,0 +1,99 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package bookmark
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMarkdown(t *testing.T) {
+	const md = `Links I like.
+
+# Go
+
+1. [The Go Blog](https://go.dev/blog/) - official blog
+2. Tutorials
+   * [A Tour of Go](<https://go.dev/tour/> "Tour")
+   * <https://gobyexample.com/>
+3. https://pkg.go.dev/
+
+## Talks
+- [Concurrency \[is not\] parallelism](https://go.dev/s/(talk)): Rob Pike
+
+***
+
+# Empty
+`
+	got, err := ParseMarkdown(strings.NewReader(md))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BookmarkEntry{
+		&BookmarkFolder{Title: "Go", Entries: []BookmarkEntry{
+			&Bookmark{Title: "The Go Blog", URL: "https://go.dev/blog/", Attrs: []Attr{{"description", "official blog"}}},
+			&BookmarkFolder{Title: "Tutorials", Entries: []BookmarkEntry{
+				&Bookmark{Title: "A Tour of Go", URL: "https://go.dev/tour/"},
+				&Bookmark{URL: "https://gobyexample.com/"},
+			}},
+			&Bookmark{URL: "https://pkg.go.dev/"},
+			&BookmarkFolder{Title: "Talks", Entries: []BookmarkEntry{
+				&Bookmark{Title: "Concurrency [is not] parallelism", URL: "https://go.dev/s/(talk)", Attrs: []Attr{{"description", "Rob Pike"}}},
+				&BookmarkSeparator{},
+			}},
+		}},
+		&BookmarkFolder{Title: "Empty"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.MarshalIndent(got, "", "  ")
+		wantJSON, _ := json.MarshalIndent(want, "", "  ")
+		t.Errorf("got:\n%s\nwant:\n%s", gotJSON, wantJSON)
+	}
+}
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	tests := [][]BookmarkEntry{
+		{
+			&Bookmark{Title: "Top", URL: "https://top.example/"},
+			&BookmarkFolder{Title: "Toolbar [1] *bold*", Entries: []BookmarkEntry{
+				&Bookmark{Title: "A_b", URL: "https://a.example/?q=a b", Attrs: []Attr{{"description", "has - dashes"}}},
+				&Bookmark{URL: "https://untitled.example/"},
+				&BookmarkSeparator{},
+				&BookmarkFolder{Title: "# not a heading", Entries: []BookmarkEntry{
+					&BookmarkFolder{Title: "https://not.a.link/"},
+					&Bookmark{Title: "Paren", URL: "https://p.example/a)b"},
+				}},
+				&BookmarkFolder{Title: "---"},
+			}},
+			&BookmarkFolder{Title: "Other"},
+		},
+		{
+			&BookmarkFolder{Title: "Menu", Entries: []BookmarkEntry{&Bookmark{Title: "M", URL: "https://m.example/"}}},
+			&Bookmark{Title: "After a folder", URL: "https://after.example/"},
+			&BookmarkSeparator{},
+		},
+	}
+	for i, entries := range tests {
+		var buf bytes.Buffer
+		if err := WriteMarkdown(&buf, entries); err != nil {
+			t.Fatal(err)
+		}
+		md := buf.String()
+		got, err := ParseMarkdown(&buf)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, entries) {
+			gotJSON, _ := json.MarshalIndent(got, "", "  ")
+			wantJSON, _ := json.MarshalIndent(entries, "", "  ")
+			t.Errorf("#%d: markdown:\n%s\ngot:\n%s\nwant:\n%s", i, md, gotJSON, wantJSON)
+		}
+	}
+}
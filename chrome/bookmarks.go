@@ -7,17 +7,36 @@
 package chrome
 
 import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/andrewarchi/browser/bookmark"
 	"github.com/andrewarchi/browser/jsonutil"
 	"github.com/andrewarchi/browser/jsonutil/timefmt"
 	"github.com/andrewarchi/browser/jsonutil/uuid"
 )
 
+// Bookmarks format:
+// https://source.chromium.org/chromium/chromium/src/+/master:components/bookmarks/browser/bookmark_codec.cc
+//
+// Fields are in the order that Chrome writes them, which is sorted by
+// key.
+
 // Bookmarks contains Chrome bookmark information.
 type Bookmarks struct {
-	Checksum     jsonutil.Hex    `json:"checksum"`
-	Roots        BookmarkRoots   `json:"roots"`
-	SyncMetadata jsonutil.Base64 `json:"sync_metadata,omitempty"`
-	Version      int             `json:"version"` // e.g. 1
+	Checksum     jsonutil.Hex      `json:"checksum"` // MD5 of the bookmarks, as computed by Checksum
+	MetaInfo     map[string]string `json:"meta_info,omitempty"`
+	Roots        BookmarkRoots     `json:"roots"`
+	SyncMetadata jsonutil.Base64   `json:"sync_metadata,omitempty"`
+	Version      int               `json:"version"` // e.g. 1
 }
 
 // BookmarkRoots contains the root level bookmarks folders.
@@ -31,19 +50,20 @@ type BookmarkRoots struct {
 type BookmarkEntry struct {
 	Children     []BookmarkEntry      `json:"children"` // for folder type only
 	DateAdded    timefmt.QuotedChrome `json:"date_added"`
-	DateModified timefmt.QuotedChrome `json:"date_modified,omitempty"` // for folder type only
-	GUID         *uuid.UUID           `json:"guid"`                    // "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"
-	ID           string               `json:"id"`                      // e.g. "567"
+	DateLastUsed timefmt.QuotedChrome `json:"date_last_used"` // for url type only
+	DateModified timefmt.QuotedChrome `json:"date_modified"`  // for folder type only
+	GUID         *uuid.UUID           `json:"guid"`           // "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"
+	ID           string               `json:"id"`             // e.g. "567"
+	MetaInfo     map[string]string    `json:"meta_info,omitempty"`
 	Name         string               `json:"name"`
-	Type         string               `json:"type"` // "folder" or "url"
-	MetaInfo     *BookmarkMetaInfo    `json:"meta_info,omitempty"`
+	Type         string               `json:"type"`          // "folder" or "url"
 	URL          string               `json:"url,omitempty"` // for url type only
 }
 
-// BookmarkMetaInfo contains additional bookmark metadata.
-type BookmarkMetaInfo struct {
-	LastVisitedDesktop timefmt.QuotedChrome `json:"last_visited_desktop"`
-}
+// Keys of BookmarkEntry.MetaInfo:
+const (
+	MetaLastVisitedDesktop = "last_visited_desktop" // Chrome time in microseconds, as a string
+)
 
 // ParseBookmarks parses "Bookmarks" in a Chrome profile.
 func ParseBookmarks(filename string) (*Bookmarks, error) {
@@ -53,3 +73,351 @@ func ParseBookmarks(filename string) (*Bookmarks, error) {
 	}
 	return &bookmarks, nil
 }
+
+// MarshalJSON implements the json.Marshaler interface, writing only
+// the fields of the entry type, like Chrome. The last used date, which
+// was added in Chrome 113, is omitted when zero.
+func (e BookmarkEntry) MarshalJSON() ([]byte, error) {
+	type entry struct {
+		Children     *[]BookmarkEntry      `json:"children,omitempty"`
+		DateAdded    timefmt.QuotedChrome  `json:"date_added"`
+		DateLastUsed *timefmt.QuotedChrome `json:"date_last_used,omitempty"`
+		DateModified *timefmt.QuotedChrome `json:"date_modified,omitempty"`
+		GUID         *uuid.UUID            `json:"guid"`
+		ID           string                `json:"id"`
+		MetaInfo     map[string]string     `json:"meta_info,omitempty"`
+		Name         string                `json:"name"`
+		Type         string                `json:"type"`
+		URL          string                `json:"url,omitempty"`
+	}
+	v := entry{
+		DateAdded: e.DateAdded,
+		GUID:      e.GUID,
+		ID:        e.ID,
+		MetaInfo:  e.MetaInfo,
+		Name:      e.Name,
+		Type:      e.Type,
+		URL:       e.URL,
+	}
+	if e.Type == "url" {
+		if !e.DateLastUsed.IsZero() {
+			v.DateLastUsed = &e.DateLastUsed
+		}
+	} else {
+		children := e.Children
+		if children == nil {
+			children = []BookmarkEntry{}
+		}
+		v.Children = &children
+		v.DateModified = &e.DateModified
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(&v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+// WriteBookmarks writes bookmarks in the format of "Bookmarks", with
+// the checksum recomputed, so that Chrome accepts the file. It is
+// formatted like Chrome formats it: keys are sorted, objects are
+// indented by three spaces, and arrays are on one line.
+func WriteBookmarks(w io.Writer, b *Bookmarks) error {
+	bc := *b
+	bc.Checksum = b.Roots.Checksum()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(&bc); err != nil {
+		return fmt.Errorf("chrome: bookmarks: %w", err)
+	}
+	var out bytes.Buffer
+	indentChromeJSON(&out, bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}))
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// Checksum computes the checksum of the bookmarks, as Chrome does to
+// detect external changes: the MD5 hash of, for each entry in
+// depth-first order, its ID, its name in UTF-16LE, its type, and, for
+// URLs, its URL.
+func (r *BookmarkRoots) Checksum() jsonutil.Hex {
+	h := md5.New()
+	var update func(e *BookmarkEntry)
+	update = func(e *BookmarkEntry) {
+		io.WriteString(h, e.ID)
+		title := utf16.Encode([]rune(e.Name))
+		b := make([]byte, 2*len(title))
+		for i, c := range title {
+			binary.LittleEndian.PutUint16(b[2*i:], c)
+		}
+		h.Write(b)
+		if e.Type == "url" {
+			io.WriteString(h, "url")
+			io.WriteString(h, e.URL)
+			return
+		}
+		io.WriteString(h, "folder")
+		for i := range e.Children {
+			update(&e.Children[i])
+		}
+	}
+	update(&r.BookmarkBar)
+	update(&r.Other)
+	update(&r.Synced)
+	return h.Sum(nil)
+}
+
+// indentChromeJSON formats compact JSON like base::JSONWriter with
+// pretty printing: each object member is on its own line, indented by
+// three spaces per object, arrays are on the line of their parent with
+// spaces inside the brackets, and "<" in strings is escaped.
+func indentChromeJSON(dst *bytes.Buffer, src []byte) {
+	var stack []byte
+	depth := 0
+	indent := func() {
+		dst.WriteString(strings.Repeat("   ", depth))
+	}
+	inString, escaped := false, false
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			case c == '<':
+				dst.WriteString(`\u003C`)
+				continue
+			}
+			dst.WriteByte(c)
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+			dst.WriteByte(c)
+		case '{':
+			dst.WriteString("{\n")
+			if i+1 < len(src) && src[i+1] == '}' {
+				indent()
+				dst.WriteByte('}')
+				i++
+				continue
+			}
+			stack = append(stack, c)
+			depth++
+			indent()
+		case '}':
+			stack = stack[:len(stack)-1]
+			depth--
+			dst.WriteByte('\n')
+			indent()
+			dst.WriteByte('}')
+		case '[':
+			dst.WriteString("[ ")
+			if i+1 < len(src) && src[i+1] == ']' {
+				dst.WriteString(" ]")
+				i++
+				continue
+			}
+			stack = append(stack, c)
+		case ']':
+			stack = stack[:len(stack)-1]
+			dst.WriteString(" ]")
+		case ',':
+			if stack[len(stack)-1] == '{' {
+				dst.WriteString(",\n")
+				indent()
+			} else {
+				dst.WriteString(", ")
+			}
+		case ':':
+			dst.WriteString(": ")
+		default:
+			dst.WriteByte(c)
+		}
+	}
+	dst.WriteByte('\n')
+}
+
+// Attributes in the bookmark model for Chrome fields without a
+// corresponding field. Meta info other than the last visit time is
+// kept with the key prefixed by attrMetaInfo.
+const (
+	attrID                 = "id"
+	attrLastVisitedDesktop = MetaLastVisitedDesktop
+	attrDateLastUsed       = "date_last_used"
+	attrMetaInfo           = "meta_info."
+)
+
+// Tree converts the bookmarks to the bookmark model. The roots become
+// folders in the order bookmark bar, other, and synced. Entries keep
+// their order and GUIDs, and IDs and metadata are kept as attributes,
+// so that BookmarkRootsFromTree restores the same bookmarks.
+func (b *Bookmarks) Tree() []bookmark.BookmarkEntry {
+	return []bookmark.BookmarkEntry{
+		toBookmarkModel(&b.Roots.BookmarkBar),
+		toBookmarkModel(&b.Roots.Other),
+		toBookmarkModel(&b.Roots.Synced),
+	}
+}
+
+func toBookmarkModel(e *BookmarkEntry) bookmark.BookmarkEntry {
+	var guid string
+	if e.GUID != nil {
+		guid = e.GUID.String()
+	}
+	attrs := []bookmark.Attr{{Key: attrID, Val: e.ID}}
+	if !e.DateLastUsed.IsZero() {
+		attrs = append(attrs, bookmark.Attr{Key: attrDateLastUsed,
+			Val: timefmt.Format(e.DateLastUsed.Time, timefmt.Micro, timefmt.Windows)})
+	}
+	keys := make([]string, 0, len(e.MetaInfo))
+	for key := range e.MetaInfo {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		attr := key
+		if key != MetaLastVisitedDesktop {
+			attr = attrMetaInfo + key
+		}
+		attrs = append(attrs, bookmark.Attr{Key: attr, Val: e.MetaInfo[key]})
+	}
+	if e.Type == "url" {
+		return &bookmark.Bookmark{
+			Title:   e.Name,
+			URL:     e.URL,
+			GUID:    guid,
+			AddDate: e.DateAdded.Time,
+			Attrs:   attrs,
+		}
+	}
+	f := &bookmark.BookmarkFolder{
+		Title:        e.Name,
+		GUID:         guid,
+		AddDate:      e.DateAdded.Time,
+		LastModified: e.DateModified.Time,
+		Attrs:        attrs,
+		Entries:      make([]bookmark.BookmarkEntry, len(e.Children)),
+	}
+	for i := range e.Children {
+		f.Entries[i] = toBookmarkModel(&e.Children[i])
+	}
+	return f
+}
+
+// BookmarkRootsFromTree converts bookmarks in the bookmark model, as
+// returned by Bookmarks.Tree, to Chrome bookmark roots. The tree must
+// have exactly three folders for the roots. Entries without an ID are
+// assigned IDs following the largest existing ID.
+func BookmarkRootsFromTree(tree []bookmark.BookmarkEntry) (*BookmarkRoots, error) {
+	if len(tree) != 3 {
+		return nil, fmt.Errorf("chrome: bookmark tree has %d roots, want 3", len(tree))
+	}
+	c := &bookmarkConverter{}
+	c.maxID(tree)
+	var roots BookmarkRoots
+	for i, root := range []*BookmarkEntry{&roots.BookmarkBar, &roots.Other, &roots.Synced} {
+		f, ok := tree[i].(*bookmark.BookmarkFolder)
+		if !ok {
+			return nil, fmt.Errorf("chrome: bookmark root %d is not a folder: %T", i, tree[i])
+		}
+		e, err := c.fromBookmarkModel(f)
+		if err != nil {
+			return nil, err
+		}
+		*root = *e
+	}
+	return &roots, nil
+}
+
+type bookmarkConverter struct {
+	nextID int64
+}
+
+func (c *bookmarkConverter) maxID(entries []bookmark.BookmarkEntry) {
+	for _, e := range entries {
+		var attrs []bookmark.Attr
+		switch e := e.(type) {
+		case *bookmark.BookmarkFolder:
+			attrs = e.Attrs
+			c.maxID(e.Entries)
+		case *bookmark.Bookmark:
+			attrs = e.Attrs
+		}
+		for _, attr := range attrs {
+			if attr.Key == attrID {
+				if id, err := strconv.ParseInt(attr.Val, 10, 64); err == nil && id >= c.nextID {
+					c.nextID = id + 1
+				}
+			}
+		}
+	}
+}
+
+func (c *bookmarkConverter) fromBookmarkModel(entry bookmark.BookmarkEntry) (*BookmarkEntry, error) {
+	var e BookmarkEntry
+	var guid string
+	var attrs []bookmark.Attr
+	switch entry := entry.(type) {
+	case *bookmark.BookmarkFolder:
+		e.Type = "folder"
+		e.Name = entry.Title
+		e.DateAdded.Time = entry.AddDate
+		e.DateModified.Time = entry.LastModified
+		e.Children = make([]BookmarkEntry, 0, len(entry.Entries))
+		for _, child := range entry.Entries {
+			if _, ok := child.(*bookmark.BookmarkSeparator); ok {
+				continue // Chrome has no separators
+			}
+			ce, err := c.fromBookmarkModel(child)
+			if err != nil {
+				return nil, err
+			}
+			e.Children = append(e.Children, *ce)
+		}
+		guid, attrs = entry.GUID, entry.Attrs
+	case *bookmark.Bookmark:
+		e.Type = "url"
+		e.Name = entry.Title
+		e.URL = entry.URL
+		e.DateAdded.Time = entry.AddDate
+		guid, attrs = entry.GUID, entry.Attrs
+	default:
+		return nil, fmt.Errorf("chrome: illegal bookmark entry type: %T", entry)
+	}
+	// GUIDs from other browsers, like the 12-character Firefox GUIDs,
+	// are not UUIDs and are dropped, so that Chrome assigns new ones.
+	if id, err := uuid.Decode([]byte(guid)); err == nil {
+		e.GUID = id
+	}
+	for _, attr := range attrs {
+		switch {
+		case attr.Key == attrID:
+			e.ID = attr.Val
+		case attr.Key == attrDateLastUsed:
+			t, err := timefmt.Parse(attr.Val, timefmt.Micro, timefmt.Windows)
+			if err != nil {
+				return nil, fmt.Errorf("chrome: bookmark last used: %w", err)
+			}
+			e.DateLastUsed.Time = t
+		case attr.Key == attrLastVisitedDesktop, strings.HasPrefix(attr.Key, attrMetaInfo):
+			if e.MetaInfo == nil {
+				e.MetaInfo = make(map[string]string)
+			}
+			e.MetaInfo[strings.TrimPrefix(attr.Key, attrMetaInfo)] = attr.Val
+		}
+	}
+	if e.ID == "" {
+		e.ID = strconv.FormatInt(c.nextID, 10)
+		c.nextID++
+	}
+	return &e, nil
+}
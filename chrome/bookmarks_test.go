@@ -0,0 +1,136 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/bookmark"
+	"github.com/andrewarchi/browser/jsonutil"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+)
+
+const testBookmarks = `{
+  "checksum": "00112233445566778899aabbccddeeff",
+  "roots": {
+    "bookmark_bar": {
+      "children": [
+        {"date_added": "13258083723456789", "guid": "8b1fd2f2-7b7a-4b21-9b4d-7b4c2a1b0f01", "id": "5", "name": "Z", "type": "url", "url": "https://z.example/",
+         "meta_info": {"last_visited_desktop": "13258083800000000"}},
+        {"children": [], "date_added": "13258083723456789", "date_modified": "13258083723456790", "guid": "8b1fd2f2-7b7a-4b21-9b4d-7b4c2a1b0f02", "id": "6", "name": "Folder", "type": "folder"},
+        {"date_added": "13258083723456789", "guid": "8b1fd2f2-7b7a-4b21-9b4d-7b4c2a1b0f03", "id": "4", "name": "A", "type": "url", "url": "https://a.example/"}
+      ],
+      "date_added": "13258083723456789", "date_modified": "0", "guid": "0bc5d13f-2cba-5d74-951f-3f233fe6c908", "id": "1", "name": "Bookmarks bar", "type": "folder"
+    },
+    "other": {"children": [], "date_added": "13258083723456789", "date_modified": "0", "guid": "82b081ec-3dd3-529c-8475-ab6c344590dd", "id": "2", "name": "Other bookmarks", "type": "folder"},
+    "synced": {"children": [], "date_added": "13258083723456789", "date_modified": "0", "guid": "4cf2e351-0e85-532b-bb37-df045d8f8d0f", "id": "3", "name": "Mobile bookmarks", "type": "folder"}
+  },
+  "version": 1
+}`
+
+func TestBookmarksTree(t *testing.T) {
+	var b Bookmarks
+	if err := json.Unmarshal([]byte(testBookmarks), &b); err != nil {
+		t.Fatal(err)
+	}
+	tree := b.Tree()
+	roots, err := BookmarkRootsFromTree(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*roots, b.Roots) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", *roots, b.Roots)
+	}
+
+	bar := tree[0].(*bookmark.BookmarkFolder)
+	bar.Entries = append(bar.Entries, &bookmark.Bookmark{Title: "New", URL: "https://new.example/", GUID: "xQxadA7g1y_x"})
+	roots, err = BookmarkRootsFromTree(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := roots.BookmarkBar.Children[3]; e.ID != "7" || e.GUID != nil {
+		t.Errorf("got ID %q and GUID %v, want ID 7 and no GUID", e.ID, e.GUID)
+	}
+}
+
+func TestWriteBookmarks(t *testing.T) {
+	folder := func(id, name string, children ...BookmarkEntry) BookmarkEntry {
+		return BookmarkEntry{ID: id, Name: name, Type: "folder", Children: children}
+	}
+	b := &Bookmarks{
+		Checksum: jsonutil.Hex{0},
+		Roots: BookmarkRoots{
+			BookmarkBar: folder("1", "Bookmarks bar", BookmarkEntry{
+				ID: "4", Name: "Café", Type: "url", URL: "https://a.example/?q=<b>",
+				DateAdded: timefmt.QuotedChrome{Time: time.Date(2021, 2, 18, 0, 0, 0, 0, time.UTC)},
+			}),
+			Other:  folder("2", "Other bookmarks"),
+			Synced: folder("3", "Mobile bookmarks"),
+		},
+		Version: 1,
+	}
+	var buf bytes.Buffer
+	if err := WriteBookmarks(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+   "checksum": "acdf4c98a3b5b8443d42fcaa8f363d78",
+   "roots": {
+      "bookmark_bar": {
+         "children": [ {
+            "date_added": "13258080000000000",
+            "guid": null,
+            "id": "4",
+            "name": "Café",
+            "type": "url",
+            "url": "https://a.example/?q=\u003Cb>"
+         } ],
+         "date_added": "0",
+         "date_modified": "0",
+         "guid": null,
+         "id": "1",
+         "name": "Bookmarks bar",
+         "type": "folder"
+      },
+      "other": {
+         "children": [  ],
+         "date_added": "0",
+         "date_modified": "0",
+         "guid": null,
+         "id": "2",
+         "name": "Other bookmarks",
+         "type": "folder"
+      },
+      "synced": {
+         "children": [  ],
+         "date_added": "0",
+         "date_modified": "0",
+         "guid": null,
+         "id": "3",
+         "name": "Mobile bookmarks",
+         "type": "folder"
+      }
+   },
+   "version": 1
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	var parsed Bookmarks
+	if err := json.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(parsed.Checksum, parsed.Roots.Checksum()) {
+		t.Errorf("got checksum %s, want %s", parsed.Checksum, parsed.Roots.Checksum())
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"time"
+
+	"github.com/andrewarchi/browser/protoutil"
+)
+
+// Budget database format:
+// https://source.chromium.org/chromium/chromium/src/+/master:chrome/browser/budget_service/budget_database.cc
+// https://source.chromium.org/chromium/chromium/src/+/master:chrome/browser/budget_service/budget.proto
+//
+// Keys are origins and values are Budget messages. The budget service
+// limited background work, like silent push messages, by sites and was
+// removed in Chrome 80, but the database remains in older profiles.
+
+// Budget is the background processing budget of an origin.
+type Budget struct {
+	Origin                string // e.g. "https://example.com/"
+	EngagementLastUpdated time.Time
+	Chunks                []BudgetChunk
+}
+
+// BudgetChunk is an amount of budget that expires at a given time.
+type BudgetChunk struct {
+	Amount     float64
+	Expiration time.Time
+}
+
+// ParseBudgetDatabase parses the "BudgetDatabase" LevelDB database in
+// a Chrome profile. Budgets are ordered by origin.
+func ParseBudgetDatabase(dir string) ([]Budget, error) {
+	var budgets []Budget
+	err := walkLevelDB(dir, nil, func(key, value []byte) error {
+		b := Budget{Origin: string(key)}
+		err := protoutil.Walk(value, "Budget", func(f *protoutil.Field) error {
+			var err error
+			switch f.Num {
+			case 1:
+				b.EngagementLastUpdated, err = chromeTime(f.Int64())
+			case 2:
+				var c BudgetChunk
+				err = parseBudgetChunk(f.Bytes, &c)
+				b.Chunks = append(b.Chunks, c)
+			default:
+				return f.Unknown()
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		budgets = append(budgets, b)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return budgets, nil
+}
+
+func parseBudgetChunk(b []byte, c *BudgetChunk) error {
+	return protoutil.Walk(b, "BudgetChunk", func(f *protoutil.Field) error {
+		var err error
+		switch f.Num {
+		case 1:
+			c.Amount = f.Double()
+		case 2:
+			c.Expiration, err = chromeTime(f.Int64())
+		default:
+			return f.Unknown()
+		}
+		return err
+	})
+}
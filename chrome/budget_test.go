@@ -0,0 +1,88 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/syndtr/goleveldb/leveldb"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func budgetChunk(amount float64, expiration int64) []byte {
+	var c []byte
+	c = protowire.AppendTag(c, 1, protowire.Fixed64Type)
+	c = protowire.AppendFixed64(c, math.Float64bits(amount))
+	c = protowire.AppendTag(c, 2, protowire.VarintType)
+	return protowire.AppendVarint(c, uint64(expiration))
+}
+
+func writeBudgetDatabase(t *testing.T, dir string, entries map[string][]byte) {
+	db, err := leveldb.OpenFile(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for origin, value := range entries {
+		if err := db.Put([]byte(origin), value, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseBudgetDatabase(t *testing.T) {
+	var a []byte
+	a = protowire.AppendTag(a, 1, protowire.VarintType)
+	a = protowire.AppendVarint(a, 13258080000000000) // 2021-02-18 00:00:00 UTC
+	a = protowire.AppendTag(a, 2, protowire.BytesType)
+	a = protowire.AppendBytes(a, budgetChunk(2.5, 13258166400000000))
+	a = protowire.AppendTag(a, 2, protowire.BytesType)
+	a = protowire.AppendBytes(a, budgetChunk(1, 13258252800000000))
+
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.VarintType)
+	b = protowire.AppendVarint(b, 13258080000000000)
+
+	dir := filepath.Join(t.TempDir(), "BudgetDatabase")
+	writeBudgetDatabase(t, dir, map[string][]byte{
+		"https://b.example/": b,
+		"https://a.example/": a,
+	})
+	budgets, err := ParseBudgetDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := timefmt.FromInt(13258080000000000, 0, timefmt.Micro, timefmt.Windows)
+	want := []Budget{
+		{Origin: "https://a.example/", EngagementLastUpdated: updated, Chunks: []BudgetChunk{
+			{2.5, timefmt.FromInt(13258166400000000, 0, timefmt.Micro, timefmt.Windows)},
+			{1, timefmt.FromInt(13258252800000000, 0, timefmt.Micro, timefmt.Windows)},
+		}},
+		{Origin: "https://b.example/", EngagementLastUpdated: updated},
+	}
+	if !reflect.DeepEqual(budgets, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", budgets, want)
+	}
+
+	// Unknown fields and negative times are rejected.
+	for name, value := range map[string][]byte{
+		"unknown field": protowire.AppendVarint(protowire.AppendTag(nil, 99, protowire.VarintType), 1),
+		"negative time": protowire.AppendBytes(protowire.AppendTag(nil, 2, protowire.BytesType), budgetChunk(1, -1)),
+	} {
+		dir := filepath.Join(t.TempDir(), "BudgetDatabase")
+		writeBudgetDatabase(t, dir, map[string][]byte{"https://c.example/": value})
+		if _, err := ParseBudgetDatabase(dir); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
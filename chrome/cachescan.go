@@ -0,0 +1,161 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/andrewarchi/browser"
+)
+
+// Simple cache entry format:
+// https://source.chromium.org/chromium/chromium/src/+/master:net/disk_cache/simple/simple_entry_format.h
+//
+// "Code Cache" uses the simple cache backend with keys of the form
+// "_key{resource URL} \n{origin lock}". "GPUCache" uses the blockfile
+// backend, in which keys are embedded in data_* files. Both are scanned
+// for URLs without decoding the cached data, so that sites that left
+// traces can be found even after history has been cleared.
+
+const simpleCacheMagic = 0xfcfb6d1ba7725c30
+
+// simpleFileHeaderSize is the size of SimpleFileHeader: the magic,
+// version, key length, key hash, and padding.
+const simpleFileHeaderSize = 24
+
+// CacheTrace is an origin found in a cache directory.
+type CacheTrace struct {
+	Origin       string    // e.g. "https://example.com"
+	URLs         []string  // distinct URLs, sorted
+	Files        []string  // files containing the origin, relative to the scanned directory
+	LastModified time.Time // latest modification time of the files
+}
+
+// CacheDirs lists the cache directories in a Chrome profile that can
+// be scanned with ScanCache.
+var CacheDirs = []string{
+	"GPUCache",
+	filepath.Join("Code Cache", "js"),
+	filepath.Join("Code Cache", "wasm"),
+	"Cache",
+	filepath.Join("Service Worker", "CacheStorage"),
+}
+
+var cacheURLPattern = regexp.MustCompile(`(?:https?|wss?|chrome-extension)://[^\x00-\x20"'<>\\^` + "`" + `{|}\x7f-\xff]+`)
+
+// ScanCache extracts origins from the files in a cache directory,
+// recursively, for triage. Keys of simple cache entries are decoded;
+// other files are searched for URL-like strings, so results may include
+// URLs embedded in cached content. Traces are ordered by origin. Files
+// that fail to read are handled as by ScanCacheOptions with the zero
+// Options.
+func ScanCache(dir string) ([]CacheTrace, error) {
+	return ScanCacheOptions(dir, browser.Options{})
+}
+
+// ScanCacheOptions extracts origins from the files in a cache directory
+// and handles files that fail to read by the error policy in opts.
+func ScanCacheOptions(dir string, opts browser.Options) ([]CacheTrace, error) {
+	h := opts.Handler()
+	type trace struct {
+		urls  map[string]struct{}
+		files map[string]struct{}
+		mod   time.Time
+	}
+	traces := make(map[string]*trace)
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil && path != dir {
+			return h.File(path, err)
+		}
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return h.File(path, err)
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		for _, u := range cacheURLs(b) {
+			origin := urlOrigin(u)
+			if origin == "" {
+				continue
+			}
+			t, ok := traces[origin]
+			if !ok {
+				t = &trace{urls: make(map[string]struct{}), files: make(map[string]struct{})}
+				traces[origin] = t
+			}
+			t.urls[u] = struct{}{}
+			t.files[rel] = struct{}{}
+			if fi.ModTime().After(t.mod) {
+				t.mod = fi.ModTime()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]CacheTrace, 0, len(traces))
+	for origin, t := range traces {
+		results = append(results, CacheTrace{
+			Origin:       origin,
+			URLs:         sortedKeys(t.urls),
+			Files:        sortedKeys(t.files),
+			LastModified: t.mod,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Origin < results[j].Origin
+	})
+	return results, h.Err()
+}
+
+// cacheURLs returns the URLs in a cache file. For simple cache entries,
+// only the key is searched.
+func cacheURLs(b []byte) []string {
+	if len(b) >= simpleFileHeaderSize && binary.LittleEndian.Uint64(b) == simpleCacheMagic {
+		n := uint64(binary.LittleEndian.Uint32(b[12:]))
+		if uint64(len(b)) >= simpleFileHeaderSize+n {
+			b = b[simpleFileHeaderSize : simpleFileHeaderSize+n]
+		}
+	}
+	matches := cacheURLPattern.FindAll(b, -1)
+	urls := make([]string, len(matches))
+	for i, m := range matches {
+		urls[i] = string(m)
+	}
+	return urls
+}
+
+func urlOrigin(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
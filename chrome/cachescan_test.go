@@ -0,0 +1,72 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// simpleCacheEntry encodes a simple cache entry with a key and data.
+func simpleCacheEntry(key, data string) []byte {
+	b := make([]byte, simpleFileHeaderSize, simpleFileHeaderSize+len(key)+len(data))
+	binary.LittleEndian.PutUint64(b, simpleCacheMagic)
+	binary.LittleEndian.PutUint32(b[8:], 5) // version
+	binary.LittleEndian.PutUint32(b[12:], uint32(len(key)))
+	binary.LittleEndian.PutUint32(b[16:], 0x12345678) // key hash
+	b = append(b, key...)
+	return append(b, data...)
+}
+
+func TestScanCache(t *testing.T) {
+	dir := t.TempDir()
+	js := filepath.Join(dir, "Code Cache", "js")
+	if err := os.MkdirAll(js, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{
+		// The key is read exactly, so its last bytes are kept and URLs
+		// in the cached data are ignored.
+		"1a2b3c4d_0": simpleCacheEntry("_key https://example.com/app.js \nhttps://example.com",
+			"var u = 'https://ignored.example/';"),
+		"index": []byte("\x00\x01https://example.org/page?q=1\x00"),
+	} {
+		if err := ioutil.WriteFile(filepath.Join(js, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	traces, err := ScanCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range traces {
+		if traces[i].LastModified.IsZero() {
+			t.Errorf("%s: no modification time", traces[i].Origin)
+		}
+		traces[i].LastModified = traces[0].LastModified
+	}
+	mod := traces[0].LastModified
+	want := []CacheTrace{{
+		Origin:       "https://example.com",
+		URLs:         []string{"https://example.com", "https://example.com/app.js"},
+		Files:        []string{filepath.Join("Code Cache", "js", "1a2b3c4d_0")},
+		LastModified: mod,
+	}, {
+		Origin:       "https://example.org",
+		URLs:         []string{"https://example.org/page?q=1"},
+		Files:        []string{filepath.Join("Code Cache", "js", "index")},
+		LastModified: mod,
+	}}
+	if !reflect.DeepEqual(traces, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", traces, want)
+	}
+}
@@ -8,11 +8,44 @@
 package chrome
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"time"
+
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
 )
 
+// UserDataDir returns the path for the Chrome user data directory,
+// which contains "Local State" and a directory for each profile.
+// https://chromium.googlesource.com/chromium/src/+/master/docs/user_data_dir.md
+func UserDataDir() (string, error) {
+	if runtime.GOOS == "windows" {
+		if localAppData := os.Getenv("LocalAppData"); localAppData != "" {
+			return localAppData + `\Google\Chrome\User Data`, nil
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	switch runtime.GOOS {
+	case "windows":
+		return home + `\AppData\Local\Google\Chrome\User Data`, nil
+	case "darwin":
+		return home + "/Library/Application Support/Google/Chrome", nil
+	case "linux":
+		if config := os.Getenv("XDG_CONFIG_HOME"); config != "" {
+			return filepath.Join(config, "google-chrome"), nil
+		}
+		return home + "/.config/google-chrome", nil
+	default:
+		return "", fmt.Errorf("chrome: unsupported GOOS: %s", runtime.GOOS)
+	}
+}
+
 // GetFirstRun retrieves the time that Chrome was first ran from
 // "First Run" in the Chrome root.
 func GetFirstRun(chromeDir string) (time.Time, error) {
@@ -22,3 +55,12 @@ func GetFirstRun(chromeDir string) (time.Time, error) {
 	}
 	return fi.ModTime(), nil
 }
+
+// chromeTime converts an internal Chrome time in microseconds since
+// 1601-01-01 00:00:00 UTC.
+func chromeTime(usec int64) (time.Time, error) {
+	if usec < 0 {
+		return time.Time{}, fmt.Errorf("chrome: negative time: %d", usec)
+	}
+	return timefmt.FromInt(usec, 0, timefmt.Micro, timefmt.Windows), nil
+}
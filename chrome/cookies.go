@@ -0,0 +1,258 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/secret"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Cookies schema:
+// https://source.chromium.org/chromium/chromium/src/+/master:net/extras/sqlite/sqlite_persistent_cookie_store.cc
+//
+// Since Chrome 96, Cookies is in the Network directory of the profile.
+// Columns were renamed from secure, httponly, and persistent to
+// is_secure, is_httponly, and is_persistent in version 12, and later
+// versions add columns, which are read when present.
+
+// Cookie is a cookie in the cookies table of "Cookies".
+type Cookie struct {
+	CreationUTC     time.Time
+	HostKey         string // e.g. "example.com" or ".example.com" for domain cookies
+	TopFrameSiteKey string // partition key of partitioned cookies, e.g. "https://example.com"
+	Name            string
+	Value           string // plaintext value, when not encrypted
+	EncryptedValue  []byte // encrypted with os_crypt; see DecryptValue
+	Path            string
+	ExpiresUTC      time.Time // zero for session cookies
+	IsSecure        bool
+	IsHTTPOnly      bool
+	LastAccessUTC   time.Time
+	HasExpires      bool
+	IsPersistent    bool
+	Priority        CookiePriority
+	SameSite        CookieSameSite
+	SourceScheme    CookieSourceScheme
+	SourcePort      int       // -1 when unknown
+	LastUpdateUTC   time.Time // zero before Chrome 110
+}
+
+// CookiePriority is the priority of a cookie for eviction.
+type CookiePriority uint8
+
+// Values for CookiePriority:
+const (
+	CookiePriorityLow    CookiePriority = 0
+	CookiePriorityMedium CookiePriority = 1
+	CookiePriorityHigh   CookiePriority = 2
+)
+
+// CookieSameSite is the SameSite attribute of a cookie.
+type CookieSameSite int8
+
+// Values for CookieSameSite:
+const (
+	CookieSameSiteUnspecified   CookieSameSite = -1
+	CookieSameSiteNoRestriction CookieSameSite = 0
+	CookieSameSiteLax           CookieSameSite = 1
+	CookieSameSiteStrict        CookieSameSite = 2
+)
+
+// CookieSourceScheme is the scheme of the URL that set a cookie.
+type CookieSourceScheme uint8
+
+// Values for CookieSourceScheme:
+const (
+	CookieSourceSchemeUnset     CookieSourceScheme = 0
+	CookieSourceSchemeNonSecure CookieSourceScheme = 1
+	CookieSourceSchemeSecure    CookieSourceScheme = 2
+)
+
+// Cookie databases in a profile, the first since Chrome 96:
+var (
+	CookiesFile    = filepath.Join("Network", "Cookies")
+	OldCookiesFile = "Cookies"
+)
+
+// ParseCookies parses the cookies in a "Cookies" database. Cookies are
+// ordered by host, partition, name, and path.
+func ParseCookies(filename string) ([]Cookie, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	cols, err := sqliteutil.Columns(db, "cookies")
+	if err != nil {
+		return nil, fmt.Errorf("chrome: cookies: %w", err)
+	}
+	has := make(map[string]bool, len(cols))
+	for _, col := range cols {
+		has[col] = true
+	}
+	optional := func(col, old, def string) string {
+		if has[col] {
+			return col
+		}
+		if old != "" && has[old] {
+			return old
+		}
+		return def
+	}
+	var cookies []Cookie
+	err = sqliteutil.Query(db, `
+		SELECT creation_utc, host_key, `+optional("top_frame_site_key", "", "''")+`, name, value,
+			`+optional("encrypted_value", "", "x''")+`, path, expires_utc,
+			`+optional("is_secure", "secure", "0")+`, `+optional("is_httponly", "httponly", "0")+`,
+			last_access_utc, `+optional("has_expires", "", "1")+`,
+			`+optional("is_persistent", "persistent", "1")+`, `+optional("priority", "", "1")+`,
+			`+optional("samesite", "", "-1")+`, `+optional("source_scheme", "", "0")+`,
+			`+optional("source_port", "", "-1")+`, `+optional("last_update_utc", "", "0")+`
+		FROM cookies
+		ORDER BY host_key, 3, name, path`, func(rows *sql.Rows) error {
+		var c Cookie
+		var created, expires, accessed, updated int64
+		if err := rows.Scan(&created, &c.HostKey, &c.TopFrameSiteKey, &c.Name, &c.Value,
+			&c.EncryptedValue, &c.Path, &expires, &c.IsSecure, &c.IsHTTPOnly,
+			&accessed, &c.HasExpires, &c.IsPersistent, &c.Priority,
+			&c.SameSite, &c.SourceScheme, &c.SourcePort, &updated); err != nil {
+			return err
+		}
+		var err error
+		if c.CreationUTC, err = chromeTime(created); err != nil {
+			return err
+		}
+		if c.ExpiresUTC, err = chromeTime(expires); err != nil {
+			return err
+		}
+		if c.LastAccessUTC, err = chromeTime(accessed); err != nil {
+			return err
+		}
+		if c.LastUpdateUTC, err = chromeTime(updated); err != nil {
+			return err
+		}
+		cookies = append(cookies, c)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chrome: cookies: %w", err)
+	}
+	return cookies, nil
+}
+
+// ProfileCookies reads the cookies of a Chrome profile from
+// "Network/Cookies" or, before Chrome 96, "Cookies". A database that
+// fails to parse is handled as by ProfileCookiesOptions with the zero
+// Options.
+func ProfileCookies(profileDir string) ([]Cookie, error) {
+	return ProfileCookiesOptions(profileDir, browser.Options{})
+}
+
+// ProfileCookiesOptions reads the cookies of a Chrome profile and
+// handles a database that fails to parse by the error policy in opts.
+func ProfileCookiesOptions(profileDir string, opts browser.Options) ([]Cookie, error) {
+	filename := filepath.Join(profileDir, CookiesFile)
+	cookies, err := ParseCookies(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		filename = filepath.Join(profileDir, OldCookiesFile)
+		cookies, err = ParseCookies(filename)
+	}
+	if err == nil || errors.Is(err, os.ErrNotExist) {
+		return cookies, err
+	}
+	h := opts.Handler()
+	if err := h.File(filename, err); err != nil {
+		return nil, err
+	}
+	return nil, h.Err()
+}
+
+// DecryptValue returns the value of the cookie, decrypting
+// EncryptedValue with a key from kp. Cookies are stored in Value only
+// when encryption was unavailable.
+func (c *Cookie) DecryptValue(kp KeyProvider) (secret.Secret, error) {
+	if len(c.EncryptedValue) == 0 {
+		return secret.Secret(c.Value), nil
+	}
+	plain, err := decryptOSCrypt(kp, c.EncryptedValue)
+	if err != nil {
+		return "", err
+	}
+	// Since Chrome 130, values are prefixed with the SHA-256 hash of the
+	// host key, so that they cannot be moved to another domain.
+	if h := sha256.Sum256([]byte(c.HostKey)); bytes.HasPrefix(plain, h[:]) {
+		plain = plain[len(h):]
+	}
+	return secret.Secret(plain), nil
+}
+
+// decryptOSCrypt decrypts a value with the key from kp for its version
+// or, for a value without a version, with kp, when it is a Decryptor.
+func decryptOSCrypt(kp KeyProvider, value []byte) ([]byte, error) {
+	if len(value) < 3 || (string(value[:3]) != "v10" && string(value[:3]) != "v11") {
+		if d, ok := kp.(Decryptor); ok {
+			return d.Decrypt(value)
+		}
+		return nil, errUnversioned
+	}
+	key, err := kp.OSCryptKey(string(value[:3]))
+	if err != nil {
+		return nil, err
+	}
+	return DecryptOSCryptValue(key, value)
+}
+
+func (p CookiePriority) String() string {
+	switch p {
+	case CookiePriorityLow:
+		return "low"
+	case CookiePriorityMedium:
+		return "medium"
+	case CookiePriorityHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("priority(%d)", uint8(p))
+	}
+}
+
+func (s CookieSameSite) String() string {
+	switch s {
+	case CookieSameSiteUnspecified:
+		return "unspecified"
+	case CookieSameSiteNoRestriction:
+		return "no_restriction"
+	case CookieSameSiteLax:
+		return "lax"
+	case CookieSameSiteStrict:
+		return "strict"
+	default:
+		return fmt.Sprintf("samesite(%d)", int8(s))
+	}
+}
+
+func (s CookieSourceScheme) String() string {
+	switch s {
+	case CookieSourceSchemeUnset:
+		return "unset"
+	case CookieSourceSchemeNonSecure:
+		return "non_secure"
+	case CookieSourceSchemeSecure:
+		return "secure"
+	default:
+		return fmt.Sprintf("sourcescheme(%d)", uint8(s))
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// encryptCBC encrypts a value as on macOS and Linux.
+func encryptCBC(version string, key, plaintext []byte) []byte {
+	block, _ := aes.NewCipher(key)
+	pad := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := append(append([]byte{}, plaintext...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, bytes.Repeat([]byte{' '}, aes.BlockSize)).CryptBlocks(ciphertext, padded)
+	return append([]byte(version), ciphertext...)
+}
+
+func TestProfileCookies(t *testing.T) {
+	peanuts := DeriveOSCryptKey([]byte(OSCryptLinuxPassword), OSCryptIterationsLinux)
+	keyring := DeriveOSCryptKey([]byte("keyring"), OSCryptIterationsLinux)
+	hash := sha256.Sum256([]byte(".example.com"))
+	v10 := encryptCBC("v10", peanuts, []byte("a"))
+	v11 := encryptCBC("v11", keyring, append(hash[:], "b"...))
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "Network"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(dir, CookiesFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE cookies (creation_utc INTEGER NOT NULL, host_key TEXT NOT NULL,
+		top_frame_site_key TEXT NOT NULL, name TEXT NOT NULL, value TEXT NOT NULL,
+		encrypted_value BLOB NOT NULL, path TEXT NOT NULL, expires_utc INTEGER NOT NULL,
+		is_secure INTEGER NOT NULL, is_httponly INTEGER NOT NULL, last_access_utc INTEGER NOT NULL,
+		has_expires INTEGER NOT NULL, is_persistent INTEGER NOT NULL, priority INTEGER NOT NULL,
+		samesite INTEGER NOT NULL, source_scheme INTEGER NOT NULL, source_port INTEGER NOT NULL,
+		last_update_utc INTEGER NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO cookies VALUES
+		(13258000000000000, 'example.org', '', 'plain', 'c', x'', '/', 0, 0, 0, 13258000001000000, 0, 0, 1, -1, 1, 80, 13258000000000000),
+		(13258000000000000, '.example.com', 'https://example.net', 'v11', '', ?2, '/', 13290000000000000, 1, 1, 13258000001000000, 1, 1, 2, 0, 2, 443, 13258000000000000),
+		(13258000000000000, '.example.com', '', 'v10', '', ?1, '/a', 13290000000000000, 1, 0, 13258000001000000, 1, 1, 1, 1, 2, 443, 13258000000000000)`,
+		v10, v11); err != nil {
+		t.Fatal(err)
+	}
+
+	cookies, err := ProfileCookies(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, accessed := chromeTestTime(13258000000000000), chromeTestTime(13258000001000000)
+	expires := chromeTestTime(13290000000000000)
+	want := []Cookie{
+		{created, ".example.com", "", "v10", "", v10, "/a", expires, true, false, accessed, true, true,
+			CookiePriorityMedium, CookieSameSiteLax, CookieSourceSchemeSecure, 443, created},
+		{created, ".example.com", "https://example.net", "v11", "", v11, "/", expires, true, true, accessed, true, true,
+			CookiePriorityHigh, CookieSameSiteNoRestriction, CookieSourceSchemeSecure, 443, created},
+		{created, "example.org", "", "plain", "c", []byte{}, "/", time.Time{}, false, false, accessed, false, false,
+			CookiePriorityMedium, CookieSameSiteUnspecified, CookieSourceSchemeNonSecure, 80, created},
+	}
+	if !reflect.DeepEqual(cookies, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", cookies, want)
+	}
+
+	kp := &PasswordKey{
+		Password:    func() ([]byte, error) { return []byte("keyring"), nil },
+		Iterations:  OSCryptIterationsLinux,
+		V10Password: []byte(OSCryptLinuxPassword),
+	}
+	for i, value := range []string{"a", "b", "c"} {
+		got, err := cookies[i].DecryptValue(kp)
+		if err != nil {
+			t.Errorf("%s: %v", cookies[i].Name, err)
+		} else if got.Reveal() != value {
+			t.Errorf("%s: got value %q, want %q", cookies[i].Name, got.Reveal(), value)
+		}
+	}
+	if _, err := cookies[0].DecryptValue(StaticKey(keyring)); err == nil {
+		t.Error("decrypted v10 value with the keyring key")
+	}
+}
+
+func TestOldCookies(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sql.Open("sqlite3", filepath.Join(dir, OldCookiesFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE cookies (creation_utc INTEGER NOT NULL UNIQUE PRIMARY KEY,
+		host_key TEXT NOT NULL, name TEXT NOT NULL, value TEXT NOT NULL, path TEXT NOT NULL,
+		expires_utc INTEGER NOT NULL, secure INTEGER NOT NULL, httponly INTEGER NOT NULL,
+		last_access_utc INTEGER NOT NULL, has_expires INTEGER NOT NULL DEFAULT 1,
+		persistent INTEGER NOT NULL DEFAULT 1, priority INTEGER NOT NULL DEFAULT 1,
+		encrypted_value BLOB DEFAULT '');
+		INSERT INTO cookies VALUES (13258000000000000, 'example.com', 'id', '', '/', 0, 1, 1, 13258000000000000, 0, 0, 1, x'01');`); err != nil {
+		t.Fatal(err)
+	}
+	cookies, err := ProfileCookies(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, want 1", len(cookies))
+	}
+	c := &cookies[0]
+	if c.Name != "id" || !c.IsSecure || !c.IsHTTPOnly || c.SameSite != CookieSameSiteUnspecified || c.SourcePort != -1 {
+		t.Errorf("got %+v", c)
+	}
+	// Values without a version are DPAPI blobs from before Chrome 80.
+	dpapi := &LocalStateKey{Decryptor: DecryptorFunc(func(b []byte) ([]byte, error) { return []byte("old"), nil })}
+	if got, err := c.DecryptValue(CacheKeys(dpapi)); err != nil || got.Reveal() != "old" {
+		t.Errorf("got value %q, error %v", got.Reveal(), err)
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"testing"
+
+	"github.com/andrewarchi/browser/internal/golden"
+)
+
+// corpusParsers parses the samples in testdata/corpus, keyed by the
+// name of the file in a profile or user data directory.
+var corpusParsers = map[string]golden.ParseFunc{
+	"Bookmarks":   func(f string) (interface{}, error) { return ParseBookmarks(f) },
+	"Local State": func(f string) (interface{}, error) { return ParseLocalState(f) },
+	"Preferences": func(f string) (interface{}, error) { return ParsePreferences(f) },
+	"policies":    func(f string) (interface{}, error) { return ParsePolicyDir(f) },
+}
+
+func TestCorpus(t *testing.T) {
+	golden.Corpus(t, "testdata/corpus", corpusParsers)
+}
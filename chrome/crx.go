@@ -0,0 +1,317 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/andrewarchi/browser/manifest"
+	"github.com/andrewarchi/browser/protoutil"
+)
+
+// CRX format:
+// https://source.chromium.org/chromium/chromium/src/+/master:components/crx_file/crx3.proto
+// https://source.chromium.org/chromium/chromium/src/+/master:components/crx_file/crx_verifier.cc
+//
+// A CRX starts with the magic "Cr24" and a little-endian 32-bit
+// version. In version 2, the lengths of the public key and signature
+// follow, then the key and the signature of the archive. In version 3,
+// the length of a CrxFileHeader message follows, then the message. The
+// ZIP archive comes last.
+
+// CRX is an open Chrome extension package.
+type CRX struct {
+	Version    int    // 2 or 3
+	ID         string // extension ID; for version 3, as signed in the header
+	Proofs     []CRXProof
+	SignedData []byte // signed header data, for version 3
+	Zip        *zip.Reader
+
+	archive *io.SectionReader
+	closer  io.Closer
+}
+
+// CRXProof is a public key and its signature of a package.
+type CRXProof struct {
+	Algorithm CRXAlgorithm
+	PublicKey []byte // DER-encoded SubjectPublicKeyInfo
+	Signature []byte
+}
+
+// CRXAlgorithm is a signature algorithm of a package.
+type CRXAlgorithm uint8
+
+// Values for CRXAlgorithm:
+const (
+	CRXRSASHA1     CRXAlgorithm = iota // version 2
+	CRXRSASHA256                       // version 3
+	CRXECDSASHA256                     // version 3
+)
+
+var crxMagic = []byte("Cr24")
+
+// crx3SignaturePrefix is prepended to the signed data in version 3
+// signatures.
+const crx3SignaturePrefix = "CRX3 SignedData\x00"
+
+// OpenCRX opens a .crx file. The file is closed by CRX.Close.
+func OpenCRX(filename string) (*CRX, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	c, err := ReadCRX(f, fi.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	c.closer = f
+	return c, nil
+}
+
+// ReadCRX reads a CRX from r, which has the given size.
+func ReadCRX(r io.ReaderAt, size int64) (*CRX, error) {
+	var head [12]byte
+	if _, err := r.ReadAt(head[:], 0); err != nil {
+		return nil, fmt.Errorf("chrome: crx: %w", err)
+	}
+	if !bytes.Equal(head[:4], crxMagic) {
+		return nil, errors.New("chrome: crx: bad magic")
+	}
+	c := &CRX{Version: int(binary.LittleEndian.Uint32(head[4:]))}
+	var offset int64
+	switch c.Version {
+	case 2:
+		var lens [4]byte
+		if _, err := r.ReadAt(lens[:], 12); err != nil {
+			return nil, fmt.Errorf("chrome: crx: %w", err)
+		}
+		keyLen := int64(binary.LittleEndian.Uint32(head[8:]))
+		sigLen := int64(binary.LittleEndian.Uint32(lens[:]))
+		if 16+keyLen+sigLen > size {
+			return nil, errors.New("chrome: crx: header exceeds file")
+		}
+		b := make([]byte, keyLen+sigLen)
+		if _, err := r.ReadAt(b, 16); err != nil {
+			return nil, fmt.Errorf("chrome: crx: %w", err)
+		}
+		c.Proofs = []CRXProof{{CRXRSASHA1, b[:keyLen], b[keyLen:]}}
+		c.ID = ExtensionID(b[:keyLen])
+		offset = 16 + keyLen + sigLen
+	case 3:
+		headerLen := int64(binary.LittleEndian.Uint32(head[8:]))
+		if 12+headerLen > size {
+			return nil, errors.New("chrome: crx: header exceeds file")
+		}
+		b := make([]byte, headerLen)
+		if _, err := r.ReadAt(b, 12); err != nil {
+			return nil, fmt.Errorf("chrome: crx: %w", err)
+		}
+		if err := c.parseHeader(b); err != nil {
+			return nil, fmt.Errorf("chrome: crx: %w", err)
+		}
+		offset = 12 + headerLen
+	default:
+		return nil, fmt.Errorf("chrome: crx: unsupported version %d", c.Version)
+	}
+	c.archive = io.NewSectionReader(r, offset, size-offset)
+	z, err := zip.NewReader(c.archive, c.archive.Size())
+	if err != nil {
+		return nil, fmt.Errorf("chrome: crx: %w", err)
+	}
+	c.Zip = z
+	return c, nil
+}
+
+// parseHeader parses a CrxFileHeader message.
+func (c *CRX) parseHeader(b []byte) error {
+	err := protoutil.Walk(b, "CrxFileHeader", func(f *protoutil.Field) error {
+		switch f.Num {
+		case 2, 3:
+			p := CRXProof{Algorithm: CRXRSASHA256}
+			if f.Num == 3 {
+				p.Algorithm = CRXECDSASHA256
+			}
+			err := protoutil.Walk(f.Bytes, "AsymmetricKeyProof", func(f *protoutil.Field) error {
+				switch f.Num {
+				case 1:
+					p.PublicKey = f.Bytes
+				case 2:
+					p.Signature = f.Bytes
+				default:
+					return f.Unknown()
+				}
+				return nil
+			})
+			c.Proofs = append(c.Proofs, p)
+			return err
+		case 10000:
+			c.SignedData = f.Bytes
+			return protoutil.Walk(f.Bytes, "SignedData", func(f *protoutil.Field) error {
+				if f.Num == 1 {
+					c.ID = encodeID(f.Bytes)
+				}
+				return nil
+			})
+		default:
+			return f.Unknown()
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if c.ID == "" {
+		return errors.New("no crx_id in signed data")
+	}
+	return nil
+}
+
+// Close closes the file opened by OpenCRX.
+func (c *CRX) Close() error {
+	if c.closer == nil {
+		return nil
+	}
+	return c.closer.Close()
+}
+
+// Manifest reads the manifest.json in the package.
+func (c *CRX) Manifest() (*manifest.Manifest, error) {
+	return manifest.ReadZip(c.Zip)
+}
+
+// Files returns the names of the files in the package, sorted.
+func (c *CRX) Files() []string {
+	return manifest.ZipFiles(c.Zip)
+}
+
+// CompareDir compares the files in the package to an installed
+// extension directory, such as Extensions/{id}/{version} in a profile.
+// The hashes that Chrome writes to _metadata on install are ignored.
+func (c *CRX) CompareDir(dir string) (*manifest.FileDiff, error) {
+	return manifest.CompareDir(c.Zip, dir, "_metadata/")
+}
+
+// Verify verifies the signatures of the package. Every proof must be
+// valid and, as Chrome requires, the key of an RSA proof must match the
+// extension ID. ECDSA proofs are only publisher proofs, such as by the
+// Chrome Web Store, so never establish the ID.
+func (c *CRX) Verify() error {
+	hashes := make(map[crypto.Hash][]byte)
+	idMatched := false
+	for _, p := range c.Proofs {
+		h := crypto.SHA256
+		if p.Algorithm == CRXRSASHA1 {
+			h = crypto.SHA1
+		}
+		digest, ok := hashes[h]
+		if !ok {
+			var err error
+			if digest, err = c.digest(h); err != nil {
+				return err
+			}
+			hashes[h] = digest
+		}
+		key, err := x509.ParsePKIXPublicKey(p.PublicKey)
+		if err != nil {
+			return fmt.Errorf("chrome: crx: public key: %w", err)
+		}
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			if p.Algorithm == CRXECDSASHA256 {
+				return errors.New("chrome: crx: RSA key in ECDSA proof")
+			}
+			err = rsa.VerifyPKCS1v15(key, h, digest, p.Signature)
+			if err == nil && ExtensionID(p.PublicKey) == c.ID {
+				idMatched = true
+			}
+		case *ecdsa.PublicKey:
+			if p.Algorithm != CRXECDSASHA256 || !ecdsa.VerifyASN1(key, digest, p.Signature) {
+				err = errors.New("invalid signature")
+			}
+		default:
+			err = fmt.Errorf("unsupported key type %T", key)
+		}
+		if err != nil {
+			return fmt.Errorf("chrome: crx: %s proof: %w", p.Algorithm, err)
+		}
+	}
+	if !idMatched {
+		return errors.New("chrome: crx: no RSA proof with the key of the extension ID")
+	}
+	return nil
+}
+
+// digest hashes the signed contents: the archive for version 2 and the
+// signed data and archive for version 3.
+func (c *CRX) digest(h crypto.Hash) ([]byte, error) {
+	var w hash.Hash
+	if h == crypto.SHA1 {
+		w = sha1.New()
+	} else {
+		w = sha256.New()
+	}
+	if c.Version == 3 {
+		var n [4]byte
+		binary.LittleEndian.PutUint32(n[:], uint32(len(c.SignedData)))
+		w.Write([]byte(crx3SignaturePrefix))
+		w.Write(n[:])
+		w.Write(c.SignedData)
+	}
+	if _, err := io.Copy(w, io.NewSectionReader(c.archive, 0, c.archive.Size())); err != nil {
+		return nil, err
+	}
+	return w.Sum(nil), nil
+}
+
+// ExtensionID returns the ID of the extension with the given
+// DER-encoded public key: the first 128 bits of its SHA-256 hash, in
+// hexadecimal with the digits a-p.
+func ExtensionID(publicKey []byte) string {
+	sum := sha256.Sum256(publicKey)
+	return encodeID(sum[:16])
+}
+
+// encodeID encodes an ID in hexadecimal with the digits a-p.
+func encodeID(b []byte) string {
+	id := make([]byte, 2*len(b))
+	for i, c := range b {
+		id[2*i] = 'a' + c>>4
+		id[2*i+1] = 'a' + c&0xf
+	}
+	return string(id)
+}
+
+func (alg CRXAlgorithm) String() string {
+	switch alg {
+	case CRXRSASHA1:
+		return "sha1_with_rsa"
+	case CRXRSASHA256:
+		return "sha256_with_rsa"
+	case CRXECDSASHA256:
+		return "sha256_with_ecdsa"
+	default:
+		return fmt.Sprintf("algorithm(%d)", uint8(alg))
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestCRX(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for _, f := range []struct{ name, data string }{
+		{"manifest.json", `{"manifest_version": 3, "name": "Example", "version": "1.0"}`},
+		{"js/", ""},
+		{"js/background.js", "chrome.runtime.onInstalled.addListener(() => {});\n"},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPub, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPub, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := sha256.Sum256(rsaPub)
+	signed := protowire.AppendTag(nil, 1, protowire.BytesType)
+	signed = protowire.AppendBytes(signed, id[:16])
+
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(signed)))
+	msg := append(append(append([]byte(crx3SignaturePrefix), n[:]...), signed...), archive.Bytes()...)
+	digest := sha256.Sum256(msg)
+	rsaSig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecSig, err := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof := func(key, sig []byte) []byte {
+		b := protowire.AppendTag(nil, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, key)
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		return protowire.AppendBytes(b, sig)
+	}
+	header := protowire.AppendTag(nil, 2, protowire.BytesType)
+	header = protowire.AppendBytes(header, proof(rsaPub, rsaSig))
+	header = protowire.AppendTag(header, 3, protowire.BytesType)
+	header = protowire.AppendBytes(header, proof(ecPub, ecSig))
+	header = protowire.AppendTag(header, 10000, protowire.BytesType)
+	header = protowire.AppendBytes(header, signed)
+
+	crx := append([]byte("Cr24"), 3, 0, 0, 0)
+	binary.LittleEndian.PutUint32(n[:], uint32(len(header)))
+	crx = append(append(append(crx, n[:]...), header...), archive.Bytes()...)
+	filename := filepath.Join(t.TempDir(), "example.crx")
+	if err := os.WriteFile(filename, crx, 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := OpenCRX(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.Version != 3 || c.ID != ExtensionID(rsaPub) || len(c.Proofs) != 2 ||
+		c.Proofs[1].Algorithm != CRXECDSASHA256 {
+		t.Errorf("got %+v", c)
+	}
+	if err := c.Verify(); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+	// An ECDSA key cannot establish the extension ID.
+	ecID := *c
+	ecID.ID = ExtensionID(ecPub)
+	if err := ecID.Verify(); err == nil {
+		t.Error("verify: expected error for ID matching only an ECDSA key")
+	}
+	m, err := c.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "Example" || m.ManifestVersion != 3 {
+		t.Errorf("got manifest %+v", m)
+	}
+	if files := c.Files(); !reflect.DeepEqual(files, []string{"js/background.js", "manifest.json"}) {
+		t.Errorf("got files %q", files)
+	}
+
+	installed := t.TempDir()
+	for name, data := range map[string]string{
+		"manifest.json":                  `{"manifest_version": 3, "name": "Example", "version": "1.0"}`,
+		"js/background.js":               "fetch('https://tracker.example/');\n",
+		"_metadata/computed_hashes.json": "{}",
+		"injected.js":                    "",
+	} {
+		path := filepath.Join(installed, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d, err := c.CompareDir(installed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d.Modified, []string{"js/background.js"}) || d.Missing != nil ||
+		!reflect.DeepEqual(d.Added, []string{"injected.js"}) {
+		t.Errorf("got diff %+v", d)
+	}
+
+	// Tampering with the archive invalidates the signatures.
+	crx[len(crx)-30] ^= 0xff
+	c2, err := ReadCRX(bytes.NewReader(crx), int64(len(crx)))
+	if err == nil {
+		if err := c2.Verify(); err == nil {
+			t.Error("verify: expected error for tampered archive")
+		}
+	}
+}
+
+func TestCRX2(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, err := zw.Create("manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(`{"manifest_version": 2, "name": "Old", "version": "0.1"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha1.Sum(archive.Bytes())
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA1, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	crx := append([]byte("Cr24"), 2, 0, 0, 0)
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(pub)))
+	crx = append(crx, n[:]...)
+	binary.LittleEndian.PutUint32(n[:], uint32(len(sig)))
+	crx = append(append(append(append(crx, n[:]...), pub...), sig...), archive.Bytes()...)
+
+	c, err := ReadCRX(bytes.NewReader(crx), int64(len(crx)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != 2 || c.ID != ExtensionID(pub) {
+		t.Errorf("got %+v", c)
+	}
+	if err := c.Verify(); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/manifest"
+)
+
+// Extension is an extension unpacked in the Extensions directory of a
+// profile, at Extensions/{id}/{version}_{n}/manifest.json.
+type Extension struct {
+	ID       string // 32 characters in a-p
+	Version  string // name of the version directory, e.g. "1.2.3_0"
+	Path     string // version directory
+	Manifest *manifest.Manifest
+}
+
+// ListExtensions reads the manifests of the extensions in a Chrome
+// profile, ordered by ID, then version directory. Directories without a
+// manifest, such as "Temp", are skipped. Manifests that fail to parse
+// are handled as by ListExtensionsOptions with the zero Options.
+func ListExtensions(profileDir string) ([]Extension, error) {
+	return ListExtensionsOptions(profileDir, browser.Options{})
+}
+
+// ListExtensionsOptions reads the manifests of the extensions in a
+// Chrome profile and handles manifests and directories that fail to
+// read by the error policy in opts.
+func ListExtensionsOptions(profileDir string, opts browser.Options) ([]Extension, error) {
+	h := opts.Handler()
+	dir := filepath.Join(profileDir, "Extensions")
+	ids, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var exts []Extension
+	for _, id := range ids {
+		if !id.IsDir() {
+			continue
+		}
+		versions, err := os.ReadDir(filepath.Join(dir, id.Name()))
+		if err != nil {
+			if err := h.File(filepath.Join(dir, id.Name()), err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, version := range versions {
+			if !version.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, id.Name(), version.Name())
+			m, err := manifest.ParseFile(filepath.Join(path, "manifest.json"))
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			} else if err != nil {
+				if err := h.File(filepath.Join(path, "manifest.json"), err); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			exts = append(exts, Extension{
+				ID:       id.Name(),
+				Version:  version.Name(),
+				Path:     path,
+				Manifest: m,
+			})
+		}
+	}
+	return exts, h.Err()
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/manifest"
+)
+
+func TestListExtensions(t *testing.T) {
+	profile := t.TempDir()
+	const id = "cjpalhdlnbpafiamejdnhcphjbkeiagm"
+	files := map[string]string{
+		"Extensions/" + id + "/1.33.2_0/manifest.json": `{"manifest_version": 2, "name": "uBlock Origin",
+			"version": "1.33.2", "permissions": ["webRequest", "webRequestBlocking", "<all_urls>"]}`,
+		"Extensions/Temp/.keep": "",
+	}
+	for name, data := range files {
+		path := filepath.Join(profile, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exts, err := ListExtensions(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exts) != 1 {
+		t.Fatalf("got %d extensions, want 1", len(exts))
+	}
+	e := exts[0]
+	if e.ID != id || e.Version != "1.33.2_0" || e.Manifest.Name != "uBlock Origin" {
+		t.Errorf("got %+v", e)
+	}
+	if r := e.Manifest.Risk(); r.Score != 10 || r.Level != manifest.RiskHigh {
+		t.Errorf("got risk %+v", r)
+	}
+
+	const corruptID = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	corrupt := filepath.Join(profile, "Extensions", corruptID, "1.0_0", "manifest.json")
+	if err := os.MkdirAll(filepath.Dir(corrupt), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(corrupt, []byte(`{"manifest_version": 2,`), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	exts, err = ListExtensions(profile)
+	var errs browser.Errors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].File != corrupt {
+		t.Errorf("got error %v, want error for %s", err, corruptID)
+	}
+	if len(exts) != 1 || exts[0].ID != id {
+		t.Errorf("got %+v, want %s only", exts, id)
+	}
+	exts, err = ListExtensionsOptions(profile, browser.Options{OnError: browser.SkipFile})
+	if err != nil || len(exts) != 1 {
+		t.Errorf("skip-file: got %+v, %v", exts, err)
+	}
+	if _, err := ListExtensionsOptions(profile, browser.Options{OnError: browser.FailFast}); err == nil || errors.As(err, &errs) {
+		t.Errorf("fail-fast: got error %v, want first error only", err)
+	}
+}
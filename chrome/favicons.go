@@ -0,0 +1,271 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"  // register GIF decoder
+	_ "image/jpeg" // register JPEG decoder
+	"image/png"
+	"time"
+
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Favicons database schema:
+// https://source.chromium.org/chromium/chromium/src/+/master:components/favicon/core/favicon_database.cc
+//
+// The database is often hundreds of megabytes, so icons are looked up
+// by page URL rather than read all at once.
+
+// Favicons is an open "Favicons" database in a Chrome profile.
+type Favicons struct {
+	db *sql.DB
+}
+
+// Favicon is an icon for one or more pages, with bitmaps at several
+// sizes.
+type Favicon struct {
+	ID      int64
+	URL     string // URL of the icon
+	Type    IconType
+	Bitmaps []FaviconBitmap
+}
+
+// FaviconBitmap is an icon image at a single size.
+type FaviconBitmap struct {
+	ID            int64
+	IconID        int64
+	LastUpdated   time.Time // zero when the bitmap is expired
+	LastRequested time.Time
+	Width         int
+	Height        int
+	Data          []byte // encoded image, usually PNG
+}
+
+// IconType is the type of a favicon.
+type IconType uint8
+
+// Values for IconType:
+const (
+	IconFavicon          IconType = 1 << 0 // rel="icon"
+	IconTouch            IconType = 1 << 1 // rel="apple-touch-icon"
+	IconTouchPrecomposed IconType = 1 << 2 // rel="apple-touch-icon-precomposed"
+	IconWebManifest      IconType = 1 << 3 // icon from a web app manifest
+	IconInvalid          IconType = 0
+)
+
+// OpenFavicons opens the "Favicons" database in a Chrome profile.
+func OpenFavicons(filename string) (*Favicons, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &Favicons{db}, nil
+}
+
+// Close closes the database.
+func (f *Favicons) Close() error { return f.db.Close() }
+
+// Lookup returns the icons mapped to a page URL, with their bitmaps.
+func (f *Favicons) Lookup(pageURL string) ([]Favicon, error) {
+	var icons []Favicon
+	err := sqliteutil.Query(f.db, `
+		SELECT f.id, f.url, f.icon_type
+		FROM icon_mapping m JOIN favicons f ON m.icon_id = f.id
+		WHERE m.page_url = ?
+		ORDER BY f.id`, func(rows *sql.Rows) error {
+		var icon Favicon
+		if err := rows.Scan(&icon.ID, &icon.URL, &icon.Type); err != nil {
+			return err
+		}
+		icons = append(icons, icon)
+		return nil
+	}, pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("chrome: favicons: %w", err)
+	}
+	for i := range icons {
+		icons[i].Bitmaps, err = f.bitmaps(icons[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return icons, nil
+}
+
+func (f *Favicons) bitmaps(iconID int64) ([]FaviconBitmap, error) {
+	var bitmaps []FaviconBitmap
+	err := sqliteutil.Query(f.db, `
+		SELECT id, icon_id, last_updated, last_requested, width, height, image_data
+		FROM favicon_bitmaps
+		WHERE icon_id = ?
+		ORDER BY width, id`, func(rows *sql.Rows) error {
+		var b FaviconBitmap
+		var updated, requested int64
+		if err := rows.Scan(&b.ID, &b.IconID, &updated, &requested, &b.Width, &b.Height, &b.Data); err != nil {
+			return err
+		}
+		var err error
+		if b.LastUpdated, err = chromeTime(updated); err != nil {
+			return err
+		}
+		if b.LastRequested, err = chromeTime(requested); err != nil {
+			return err
+		}
+		bitmaps = append(bitmaps, b)
+		return nil
+	}, iconID)
+	if err != nil {
+		return nil, fmt.Errorf("chrome: favicons: %w", err)
+	}
+	return bitmaps, nil
+}
+
+// Icon returns the best bitmap for a page URL at the requested size in
+// pixels: the smallest bitmap at least as large as size, or else the
+// largest bitmap. Favicon types are preferred over touch and manifest
+// icons. It returns nil when the page has no icon.
+func (f *Favicons) Icon(pageURL string, size int) (*FaviconBitmap, error) {
+	icons, err := f.Lookup(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	var best *FaviconBitmap
+	var bestType IconType
+	for i := range icons {
+		for j := range icons[i].Bitmaps {
+			b := &icons[i].Bitmaps[j]
+			if best == nil || betterBitmap(b, icons[i].Type, best, bestType, size) {
+				best, bestType = b, icons[i].Type
+			}
+		}
+	}
+	return best, nil
+}
+
+func betterBitmap(b *FaviconBitmap, typ IconType, best *FaviconBitmap, bestType IconType, size int) bool {
+	if (typ == IconFavicon) != (bestType == IconFavicon) {
+		return typ == IconFavicon
+	}
+	if (b.Width >= size) != (best.Width >= size) {
+		return b.Width >= size
+	}
+	if b.Width >= size {
+		return b.Width < best.Width
+	}
+	return b.Width > best.Width
+}
+
+// IconDataURI returns the best icon for a page URL at the requested
+// size as a PNG data URI, downscaled as by PNG. It returns "" when the
+// page has no icon.
+func (f *Favicons) IconDataURI(pageURL string, size int) (string, error) {
+	b, err := f.Icon(pageURL, size)
+	if err != nil || b == nil {
+		return "", err
+	}
+	return b.DataURI(size)
+}
+
+// PNG decodes the bitmap and encodes it as PNG, downscaling it to fit
+// within size×size pixels when larger. Smaller images are not
+// upscaled. A size of 0 keeps the original dimensions.
+func (b *FaviconBitmap) PNG(size int) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(b.Data))
+	if err != nil {
+		return nil, fmt.Errorf("chrome: favicon %d: %w", b.ID, err)
+	}
+	if size > 0 {
+		img = downscale(img, size)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DataURI returns the bitmap as a PNG data URI, downscaled as by PNG,
+// for embedding in HTML reports and bookmark files.
+func (b *FaviconBitmap) DataURI(size int) (string, error) {
+	p, err := b.PNG(size)
+	if err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(p), nil
+}
+
+// downscale shrinks an image to fit within size×size pixels using box
+// filtering, preserving the aspect ratio.
+func downscale(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	dw, dh := size, size
+	if w > h {
+		dh = maxInt(1, h*size/w)
+	} else if h > w {
+		dw = maxInt(1, w*size/h)
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := bounds.Min.Y+y*h/dh, bounds.Min.Y+(y+1)*h/dh
+		for x := 0; x < dw; x++ {
+			x0, x1 := bounds.Min.X+x*w/dw, bounds.Min.X+(x+1)*w/dw
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBA64Model.Convert(img.At(sx, sy)).(color.NRGBA64)
+					// Weight by alpha so transparent pixels do not darken edges.
+					r += uint64(c.R) * uint64(c.A)
+					g += uint64(c.G) * uint64(c.A)
+					b += uint64(c.B) * uint64(c.A)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			if a != 0 {
+				dst.Set(x, y, color.NRGBA64{
+					R: uint16(r / a), G: uint16(g / a), B: uint16(b / a), A: uint16(a / n),
+				})
+			}
+		}
+	}
+	return dst
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (typ IconType) String() string {
+	switch typ {
+	case IconInvalid:
+		return "invalid"
+	case IconFavicon:
+		return "favicon"
+	case IconTouch:
+		return "touch_icon"
+	case IconTouchPrecomposed:
+		return "touch_precomposed_icon"
+	case IconWebManifest:
+		return "web_manifest_icon"
+	default:
+		return fmt.Sprintf("icon_type(%d)", uint8(typ))
+	}
+}
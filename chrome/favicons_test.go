@@ -0,0 +1,153 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func encodeTestPNG(t *testing.T, size int, c color.Color) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFavicons(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "Favicons")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	red := color.NRGBA{R: 255, A: 255}
+	for _, q := range []string{
+		`CREATE TABLE favicons (id INTEGER PRIMARY KEY, url LONGVARCHAR NOT NULL, icon_type INTEGER DEFAULT 1)`,
+		`CREATE TABLE icon_mapping (id INTEGER PRIMARY KEY, page_url LONGVARCHAR NOT NULL, icon_id INTEGER)`,
+		`CREATE TABLE favicon_bitmaps (id INTEGER PRIMARY KEY, icon_id INTEGER NOT NULL,
+			last_updated INTEGER DEFAULT 0, image_data BLOB, width INTEGER DEFAULT 0,
+			height INTEGER DEFAULT 0, last_requested INTEGER NOT NULL DEFAULT 0)`,
+		`INSERT INTO favicons VALUES (1, 'https://example.com/favicon.ico', 1)`,
+		`INSERT INTO favicons VALUES (2, 'https://example.com/apple-touch-icon.png', 2)`,
+		`INSERT INTO icon_mapping VALUES (1, 'https://example.com/', 1)`,
+		`INSERT INTO icon_mapping VALUES (2, 'https://example.com/', 2)`,
+		`INSERT INTO icon_mapping VALUES (3, 'https://example.org/', 2)`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, b := range []struct {
+		id, icon int64
+		size     int
+	}{{1, 1, 16}, {2, 1, 64}, {3, 2, 32}, {4, 2, 180}} {
+		if _, err := db.Exec(`INSERT INTO favicon_bitmaps VALUES (?, ?, 13258087200000000, ?, ?, ?, 0)`,
+			b.id, b.icon, encodeTestPNG(t, b.size, red), b.size, b.size); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := OpenFavicons(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	icons, err := f.Lookup("https://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icons) != 2 || icons[0].Type != IconFavicon || icons[1].Type != IconTouch ||
+		len(icons[0].Bitmaps) != 2 || icons[0].Bitmaps[0].Width != 16 {
+		t.Fatalf("got icons %+v", icons)
+	}
+
+	// Favicons are preferred over touch icons, then the smallest bitmap
+	// at least as large as the size, or else the largest.
+	for _, test := range []struct {
+		page      string
+		size      int
+		wantWidth int
+	}{
+		{"https://example.com/", 16, 16},
+		{"https://example.com/", 32, 64},
+		{"https://example.com/", 128, 64},
+		{"https://example.org/", 16, 32},
+		{"https://example.org/", 64, 180},
+	} {
+		b, err := f.Icon(test.page, test.size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b == nil || b.Width != test.wantWidth {
+			t.Errorf("Icon(%q, %d) = %+v, want width %d", test.page, test.size, b, test.wantWidth)
+		}
+	}
+	if b, err := f.Icon("https://example.net/", 16); err != nil || b != nil {
+		t.Errorf("got icon %+v, %v for page without icon", b, err)
+	}
+
+	uri, err := f.IconDataURI("https://example.org/", 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const prefix = "data:image/png;base64,"
+	if !strings.HasPrefix(uri, prefix) {
+		t.Fatalf("got data URI %q", uri)
+	}
+	data, err := base64.StdEncoding.DecodeString(uri[len(prefix):])
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 64 {
+		t.Errorf("got %dx%d icon, want it downscaled to 64x64", b.Dx(), b.Dy())
+	}
+	if c := color.NRGBAModel.Convert(img.At(10, 10)); c != red {
+		t.Errorf("got color %v, want %v", c, red)
+	}
+}
+
+func TestDownscale(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 20; x++ {
+		for y := 0; y < 20; y++ {
+			img.Set(x, y, color.NRGBA{B: 255, A: 255}) // left half opaque blue, right transparent
+		}
+	}
+	got := downscale(img, 10)
+	if b := got.Bounds(); b.Dx() != 10 || b.Dy() != 5 {
+		t.Fatalf("got %dx%d, want 10x5 preserving the aspect ratio", b.Dx(), b.Dy())
+	}
+	if c := color.NRGBAModel.Convert(got.At(2, 2)); c != (color.NRGBA{B: 255, A: 255}) {
+		t.Errorf("got opaque color %v", c)
+	}
+	if c := color.NRGBAModel.Convert(got.At(7, 2)).(color.NRGBA); c.A != 0 {
+		t.Errorf("got transparent color %v", c)
+	}
+	if small := image.NewNRGBA(image.Rect(0, 0, 8, 8)); downscale(small, 16) != image.Image(small) {
+		t.Error("smaller image was not kept")
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// History database schema:
+// https://source.chromium.org/chromium/chromium/src/+/master:components/history/core/browser/url_database.cc
+// https://source.chromium.org/chromium/chromium/src/+/master:components/history/core/browser/visit_database.cc
+
+// History is an open "History" database in a Chrome profile.
+type History struct {
+	db       *sql.DB
+	filename string
+}
+
+// HistoryURL is a row in the urls table, which aggregates visits to a
+// URL.
+type HistoryURL struct {
+	ID            int64
+	URL           string
+	Title         string
+	VisitCount    int
+	TypedCount    int // visits with a typed transition
+	LastVisitTime time.Time
+	Hidden        bool // hidden from omnibox autocomplete (e.g. subframes)
+}
+
+// HistoryVisit is a row in the visits table joined with its URL.
+type HistoryVisit struct {
+	ID            int64
+	URLID         int64
+	URL           string
+	Title         string
+	VisitTime     time.Time
+	FromVisit     int64 // referring visit ID, or 0
+	Transition    PageTransition
+	SegmentID     int64
+	VisitDuration time.Duration
+}
+
+// OpenHistory opens the "History" database in a Chrome profile.
+func OpenHistory(filename string) (*History, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &History{db, filename}, nil
+}
+
+// Close closes the database.
+func (h *History) Close() error { return h.db.Close() }
+
+// URLs returns all URLs, ordered by ID.
+func (h *History) URLs() ([]HistoryURL, error) {
+	var urls []HistoryURL
+	err := sqliteutil.Query(h.db, `
+		SELECT id, url, title, visit_count, typed_count, last_visit_time, hidden
+		FROM urls
+		ORDER BY id`, func(rows *sql.Rows) error {
+		var u HistoryURL
+		var lastVisit int64
+		if err := rows.Scan(&u.ID, &u.URL, &u.Title, &u.VisitCount,
+			&u.TypedCount, &lastVisit, &u.Hidden); err != nil {
+			return err
+		}
+		var err error
+		if u.LastVisitTime, err = chromeTime(lastVisit); err != nil {
+			return err
+		}
+		urls = append(urls, u)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chrome: history: %w", err)
+	}
+	return urls, nil
+}
+
+// Visits returns all visits, ordered by visit time, then ID.
+func (h *History) Visits() ([]HistoryVisit, error) {
+	var visits []HistoryVisit
+	err := sqliteutil.Query(h.db, `
+		SELECT v.id, v.url, u.url, u.title, v.visit_time, v.from_visit,
+			v.transition, v.segment_id, v.visit_duration
+		FROM visits v JOIN urls u ON v.url = u.id
+		ORDER BY v.visit_time, v.id`, func(rows *sql.Rows) error {
+		var v HistoryVisit
+		var visitTime, transition, duration int64
+		var segment sql.NullInt64
+		if err := rows.Scan(&v.ID, &v.URLID, &v.URL, &v.Title, &visitTime,
+			&v.FromVisit, &transition, &segment, &duration); err != nil {
+			return err
+		}
+		// Transitions are stored as signed 32-bit integers, so
+		// redirect qualifiers make the value negative.
+		v.Transition = PageTransition(uint32(transition))
+		var err error
+		if v.VisitTime, err = chromeTime(visitTime); err != nil {
+			return err
+		}
+		v.SegmentID = segment.Int64
+		v.VisitDuration = time.Duration(duration) * time.Microsecond
+		visits = append(visits, v)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chrome: history: %w", err)
+	}
+	return visits, nil
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// RecoveredHistory is history carved from the unallocated pages of a
+// History database and its write-ahead log. It is forensic data: rows
+// may be incomplete or stale and their tables are inferred from the
+// shape of the rows.
+type RecoveredHistory struct {
+	URLs   []HistoryURL   // deleted rows and older versions of rows in urls
+	Visits []HistoryVisit // deleted rows in visits, with URLs when known
+}
+
+// Range of plausible visit times, 1990 to 2100, in microseconds since
+// 1601, for distinguishing visits rows from other tables.
+const (
+	minRecoveredTime = 12275625600000000
+	maxRecoveredTime = 15746918400000000
+)
+
+// Recover carves deleted URLs and visits from the database file and an
+// uncheckpointed -wal file, such as after history is cleared. Chrome
+// enables secure_delete, which zeroes freed pages, so rows are mostly
+// recovered from the write-ahead log while Chrome is running or after
+// it exits uncleanly. Rows that match the live database are omitted.
+func (h *History) Recover() (*RecoveredHistory, error) {
+	records, err := sqliteutil.Recover(h.filename)
+	if err != nil {
+		return nil, fmt.Errorf("chrome: history: %w", err)
+	}
+	liveURLs, err := h.URLs()
+	if err != nil {
+		return nil, err
+	}
+	live := make(map[int64]*HistoryURL, len(liveURLs))
+	for i := range liveURLs {
+		live[liveURLs[i].ID] = &liveURLs[i]
+	}
+	liveVisits := make(map[int64]time.Time)
+	visits, err := h.Visits()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range visits {
+		liveVisits[v.ID] = v.VisitTime
+	}
+
+	// Keep the latest version of each recovered URL row.
+	type urlKey struct {
+		id  int64
+		url string
+	}
+	var rh RecoveredHistory
+	urls := make(map[urlKey]int)  // index in rh.URLs
+	urlIDs := make(map[int64]int) // index of any version, for visits
+	seenVisits := make(map[int64]bool)
+	for _, r := range records {
+		if u, ok := recoveredURL(&r); ok {
+			if l, ok := live[u.ID]; ok && l.URL == u.URL {
+				continue
+			}
+			k := urlKey{u.ID, u.URL}
+			if i, ok := urls[k]; ok {
+				if u.LastVisitTime.After(rh.URLs[i].LastVisitTime) {
+					rh.URLs[i] = u
+				}
+				continue
+			}
+			urls[k] = len(rh.URLs)
+			urlIDs[u.ID] = len(rh.URLs)
+			rh.URLs = append(rh.URLs, u)
+		} else if v, ok := recoveredVisit(&r); ok {
+			if t, ok := liveVisits[v.ID]; ok && t.Equal(v.VisitTime) || seenVisits[v.ID] {
+				continue
+			}
+			seenVisits[v.ID] = true
+			rh.Visits = append(rh.Visits, v)
+		}
+	}
+	for i := range rh.Visits {
+		v := &rh.Visits[i]
+		if u, ok := live[v.URLID]; ok {
+			v.URL, v.Title = u.URL, u.Title
+		} else if j, ok := urlIDs[v.URLID]; ok {
+			v.URL, v.Title = rh.URLs[j].URL, rh.URLs[j].Title
+		}
+	}
+	sort.SliceStable(rh.URLs, func(i, j int) bool { return rh.URLs[i].ID < rh.URLs[j].ID })
+	sort.SliceStable(rh.Visits, func(i, j int) bool {
+		a, b := &rh.Visits[i], &rh.Visits[j]
+		if !a.VisitTime.Equal(b.VisitTime) {
+			return a.VisitTime.Before(b.VisitTime)
+		}
+		return a.ID < b.ID
+	})
+	return &rh, nil
+}
+
+// recoveredURL converts a record with the shape of a row in urls: id
+// (stored as the row ID), url, title, visit_count, typed_count,
+// last_visit_time, and hidden.
+func recoveredURL(r *sqliteutil.RecoveredRecord) (HistoryURL, bool) {
+	var u HistoryURL
+	v := r.Values
+	if len(v) < 7 || v[0] != nil {
+		return u, false
+	}
+	url, ok1 := v[1].(string)
+	title, ok2 := v[2].(string)
+	visitCount, ok3 := v[3].(int64)
+	typedCount, ok4 := v[4].(int64)
+	lastVisit, ok5 := v[5].(int64)
+	hidden, ok6 := v[6].(int64)
+	if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 || !ok6 || !strings.Contains(url, ":") ||
+		lastVisit != 0 && (lastVisit < minRecoveredTime || lastVisit > maxRecoveredTime) {
+		return u, false
+	}
+	t, err := chromeTime(lastVisit)
+	if err != nil {
+		return u, false
+	}
+	return HistoryURL{
+		ID:            r.RowID,
+		URL:           url,
+		Title:         title,
+		VisitCount:    int(visitCount),
+		TypedCount:    int(typedCount),
+		LastVisitTime: t,
+		Hidden:        hidden != 0,
+	}, true
+}
+
+// recoveredVisit converts a record with the shape of a row in visits:
+// id (stored as the row ID), url, visit_time, from_visit, transition,
+// segment_id, and visit_duration, followed by columns that vary by
+// version.
+func recoveredVisit(r *sqliteutil.RecoveredRecord) (HistoryVisit, bool) {
+	var visit HistoryVisit
+	v := r.Values
+	if len(v) < 7 || v[0] != nil {
+		return visit, false
+	}
+	urlID, ok1 := v[1].(int64)
+	visitTime, ok2 := v[2].(int64)
+	fromVisit, ok3 := v[3].(int64)
+	transition, ok4 := v[4].(int64)
+	duration, ok5 := v[6].(int64)
+	segment, _ := v[5].(int64)
+	if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 || urlID <= 0 ||
+		visitTime < minRecoveredTime || visitTime > maxRecoveredTime {
+		return visit, false
+	}
+	t, err := chromeTime(visitTime)
+	if err != nil {
+		return visit, false
+	}
+	return HistoryVisit{
+		ID:            r.RowID,
+		URLID:         urlID,
+		VisitTime:     t,
+		FromVisit:     fromVisit,
+		Transition:    PageTransition(uint32(transition)),
+		SegmentID:     segment,
+		VisitDuration: time.Duration(duration) * time.Microsecond,
+	}, true
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+)
+
+func TestRecoveredTimeRange(t *testing.T) {
+	for _, tt := range []struct {
+		got  int64
+		year int
+	}{
+		{minRecoveredTime, 1990},
+		{maxRecoveredTime, 2100},
+	} {
+		want, _ := timefmt.ToInt(time.Date(tt.year, 1, 1, 0, 0, 0, 0, time.UTC), timefmt.Micro, timefmt.Windows)
+		if tt.got != want {
+			t.Errorf("got %d, want %d for %d", tt.got, want, tt.year)
+		}
+	}
+}
+
+func TestHistoryRecover(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "History")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	for _, q := range []string{
+		`PRAGMA journal_mode = WAL`,
+		`PRAGMA wal_autocheckpoint = 0`,
+		`CREATE TABLE urls (id INTEGER PRIMARY KEY AUTOINCREMENT, url LONGVARCHAR, title LONGVARCHAR,
+			visit_count INTEGER DEFAULT 0 NOT NULL, typed_count INTEGER DEFAULT 0 NOT NULL,
+			last_visit_time INTEGER NOT NULL, hidden INTEGER DEFAULT 0 NOT NULL)`,
+		`CREATE TABLE visits (id INTEGER PRIMARY KEY, url INTEGER NOT NULL, visit_time INTEGER NOT NULL,
+			from_visit INTEGER, transition INTEGER DEFAULT 0 NOT NULL, segment_id INTEGER,
+			visit_duration INTEGER DEFAULT 0 NOT NULL, incremented_omnibox_typed_score BOOLEAN DEFAULT FALSE NOT NULL)`,
+		`INSERT INTO urls VALUES (1, 'https://example.com/', 'Example', 1, 0, 13258080000000000, 0)`,
+		`INSERT INTO urls VALUES (2, 'https://example.com/secret', 'Secret', 1, 1, 13258083600000000, 0)`,
+		`INSERT INTO visits VALUES (1, 1, 13258080000000000, 0, 805306368, 0, 0, 0)`,
+		`INSERT INTO visits VALUES (2, 2, 13258083600000000, 1, 805306369, 0, 1500000, 1)`,
+		`DELETE FROM visits WHERE id = 2`,
+		`DELETE FROM urls WHERE id = 2`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h, err := OpenHistory(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	rh, err := h.Recover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rh.URLs) != 1 || rh.URLs[0].ID != 2 || rh.URLs[0].URL != "https://example.com/secret" || rh.URLs[0].Title != "Secret" {
+		t.Errorf("got URLs %+v", rh.URLs)
+	}
+	if len(rh.Visits) != 1 {
+		t.Fatalf("got visits %+v", rh.Visits)
+	}
+	v := rh.Visits[0]
+	want := time.Date(2021, 2, 18, 1, 0, 0, 0, time.UTC)
+	if v.ID != 2 || v.URL != "https://example.com/secret" || !v.VisitTime.Equal(want) ||
+		v.FromVisit != 1 || v.VisitDuration != 1500*time.Millisecond {
+		t.Errorf("got visit %+v", v)
+	}
+}
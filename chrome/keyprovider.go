@@ -0,0 +1,200 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// KeyProvider provides the keys for values encrypted with os_crypt, by
+// the version prefix of the value, "v10" or "v11". Keys are AES-256
+// keys from OSCrypt.Key, or AES-128 keys from DeriveOSCryptKey.
+type KeyProvider interface {
+	OSCryptKey(version string) ([]byte, error)
+}
+
+// KeyProviderFunc is a function that implements KeyProvider.
+type KeyProviderFunc func(version string) ([]byte, error)
+
+// OSCryptKey calls f(version).
+func (f KeyProviderFunc) OSCryptKey(version string) ([]byte, error) { return f(version) }
+
+// StaticKey is a KeyProvider with the same key for every version, such
+// as a key extracted from another machine.
+type StaticKey []byte
+
+// OSCryptKey returns k.
+func (k StaticKey) OSCryptKey(version string) ([]byte, error) { return k, nil }
+
+// DPAPI decrypts data with CryptUnprotectData as the current user. It
+// is only supported on Windows.
+var DPAPI Decryptor = DecryptorFunc(dpapiDecrypt)
+
+// LocalStateKey provides the AES-256 key in "Local State", which is
+// decrypted by Decryptor, as on Windows. Values written before Chrome
+// 80, which have no version prefix, are DPAPI blobs and are decrypted
+// by Decryptor directly.
+type LocalStateKey struct {
+	LocalStateFile string
+	Decryptor      Decryptor
+}
+
+// OSCryptKey extracts the key from Local State.
+func (k *LocalStateKey) OSCryptKey(version string) ([]byte, error) {
+	return ExtractOSCryptKey(k.LocalStateFile, k.Decryptor)
+}
+
+// Decrypt decrypts a value without a version prefix with k.Decryptor.
+func (k *LocalStateKey) Decrypt(ciphertext []byte) ([]byte, error) {
+	return k.Decryptor.Decrypt(ciphertext)
+}
+
+// PasswordKey derives keys from the password that Chrome stores in the
+// keychain on macOS, or in the keyring on Linux, with
+// DeriveOSCryptKey. On Linux, "v10" values are encrypted with
+// OSCryptLinuxPassword instead, when no keyring was available.
+type PasswordKey struct {
+	Password    func() ([]byte, error)
+	Iterations  int    // OSCryptIterationsMac or OSCryptIterationsLinux
+	V10Password []byte // password for "v10" values, if not Password
+}
+
+// OSCryptKey derives the key for the version.
+func (k *PasswordKey) OSCryptKey(version string) ([]byte, error) {
+	if version == "v10" && k.V10Password != nil {
+		return DeriveOSCryptKey(k.V10Password, k.Iterations), nil
+	}
+	password, err := k.Password()
+	if err != nil {
+		return nil, err
+	}
+	return DeriveOSCryptKey(password, k.Iterations), nil
+}
+
+// CacheKeys wraps a KeyProvider, so that the key for each version is
+// requested once, since providers may read files, run commands, or
+// prompt the user for access to the keychain. Errors are not cached.
+func CacheKeys(kp KeyProvider) KeyProvider {
+	return &keyCache{kp: kp, keys: make(map[string][]byte)}
+}
+
+type keyCache struct {
+	kp   KeyProvider
+	mu   sync.Mutex
+	keys map[string][]byte
+}
+
+func (c *keyCache) OSCryptKey(version string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key, ok := c.keys[version]; ok {
+		return key, nil
+	}
+	key, err := c.kp.OSCryptKey(version)
+	if err != nil {
+		return nil, err
+	}
+	c.keys[version] = key
+	return key, nil
+}
+
+func (c *keyCache) Decrypt(ciphertext []byte) ([]byte, error) {
+	if d, ok := c.kp.(Decryptor); ok {
+		return d.Decrypt(ciphertext)
+	}
+	return nil, errUnversioned
+}
+
+// DefaultKeyProvider returns a KeyProvider for the keys of the current
+// user on this machine for the Chrome user data directory, with keys
+// cached by CacheKeys:
+//
+//   - on Windows, the key in Local State decrypted with DPAPI;
+//   - on macOS, the password "Chrome Safe Storage" in the login
+//     keychain, read with security(1), which may prompt for access;
+//   - on Linux, the password in the Secret Service, such as GNOME
+//     Keyring, read with secret-tool(1), or in KWallet, read with
+//     kwallet-query(1), and OSCryptLinuxPassword for "v10" values.
+func DefaultKeyProvider(userDataDir string) (KeyProvider, error) {
+	switch runtime.GOOS {
+	case "windows":
+		return CacheKeys(&LocalStateKey{filepath.Join(userDataDir, "Local State"), DPAPI}), nil
+	case "darwin":
+		return CacheKeys(&PasswordKey{
+			Password:   KeychainPassword("Chrome Safe Storage", "Chrome"),
+			Iterations: OSCryptIterationsMac,
+		}), nil
+	case "linux":
+		return CacheKeys(&PasswordKey{
+			Password:    FirstPassword(SecretServicePassword("chrome"), KWalletPassword("Chrome Keys", "Chrome Safe Storage")),
+			Iterations:  OSCryptIterationsLinux,
+			V10Password: []byte(OSCryptLinuxPassword),
+		}), nil
+	default:
+		return nil, fmt.Errorf("chrome: unsupported GOOS: %s", runtime.GOOS)
+	}
+}
+
+// KeychainPassword returns a function that reads a generic password
+// from the macOS keychain with security(1).
+func KeychainPassword(service, account string) func() ([]byte, error) {
+	return commandPassword("security", "find-generic-password", "-w", "-s", service, "-a", account)
+}
+
+// SecretServicePassword returns a function that reads the os_crypt
+// password for an application, such as "chrome" or "chromium", from the
+// Secret Service with secret-tool(1), from libsecret.
+func SecretServicePassword(application string) func() ([]byte, error) {
+	return commandPassword("secret-tool", "lookup", "application", application)
+}
+
+// KWalletPassword returns a function that reads a password from the
+// default KWallet with kwallet-query(1).
+func KWalletPassword(folder, key string) func() ([]byte, error) {
+	return commandPassword("kwallet-query", "-r", key, "-f", folder, "kdewallet")
+}
+
+// FirstPassword returns a function that returns the first password
+// that is read without error, or else the last error.
+func FirstPassword(passwords ...func() ([]byte, error)) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		err := errors.New("chrome: no password sources")
+		for _, password := range passwords {
+			var p []byte
+			if p, err = password(); err == nil {
+				return p, nil
+			}
+		}
+		return nil, err
+	}
+}
+
+func commandPassword(name string, args ...string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		var stderr bytes.Buffer
+		cmd := exec.Command(name, args...)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) != 0 {
+				return nil, fmt.Errorf("chrome: %s: %w: %s", name, err, msg)
+			}
+			return nil, fmt.Errorf("chrome: %s: %w", name, err)
+		}
+		out = bytes.TrimSuffix(out, []byte("\n"))
+		if len(out) == 0 {
+			return nil, fmt.Errorf("chrome: %s: empty password", name)
+		}
+		return out, nil
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// openLevelDB opens a LevelDB database directory in a Chrome profile
+// for reading. The database is not created when missing.
+func openLevelDB(dir string) (*leveldb.DB, error) {
+	return leveldb.OpenFile(dir, &opt.Options{
+		ReadOnly:       true,
+		ErrorIfMissing: true,
+	})
+}
+
+// walkLevelDB calls fn for each key with the given prefix, in key
+// order.
+func walkLevelDB(dir string, prefix []byte, fn func(key, value []byte) error) error {
+	db, err := openLevelDB(dir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	iter := db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		if err := fn(iter.Key(), iter.Value()); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"sort"
+
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+// LocalState contains selected settings from "Local State" in the
+// Chrome root, which holds settings shared by all profiles. As with
+// Preferences, only known settings are decoded.
+type LocalState struct {
+	Profile LocalStateProfile `json:"profile"`
+	OSCrypt OSCrypt           `json:"os_crypt"`
+}
+
+// LocalStateProfile contains the profiles known to the browser.
+type LocalStateProfile struct {
+	InfoCache  map[string]ProfileInfo `json:"info_cache"` // key: profile directory, e.g. "Default", "Profile 1"
+	LastUsed   string                 `json:"last_used,omitempty"`
+	LastActive []string               `json:"last_active_profiles,omitempty"`
+}
+
+// ProfileInfo describes a profile in the profile picker. Edge uses the
+// same structure, with the signed-in Microsoft account as the user
+// name.
+// https://source.chromium.org/chromium/chromium/src/+/master:chrome/browser/profiles/profile_attributes_entry.cc
+type ProfileInfo struct {
+	Name                      string  `json:"name"`      // profile name, e.g. "Person 1"
+	UserName                  string  `json:"user_name"` // signed-in email, if any
+	GAIAName                  string  `json:"gaia_name,omitempty"`
+	GAIAGivenName             string  `json:"gaia_given_name,omitempty"`
+	GAIAID                    string  `json:"gaia_id,omitempty"`
+	HostedDomain              string  `json:"hosted_domain,omitempty"`
+	IsEphemeral               bool    `json:"is_ephemeral,omitempty"`
+	ActiveTime                float64 `json:"active_time,omitempty"` // seconds since the Unix epoch
+	AvatarIcon                string  `json:"avatar_icon,omitempty"` // e.g. "chrome://theme/IDR_PROFILE_AVATAR_26"
+	IsUsingDefaultName        bool    `json:"is_using_default_name,omitempty"`
+	IsUsingDefaultAvatar      bool    `json:"is_using_default_avatar,omitempty"`
+	IsConsentedPrimaryAccount bool    `json:"is_consented_primary_account,omitempty"` // signed in, rather than only to the web
+	ManagedUserID             string  `json:"managed_user_id,omitempty"`              // nonempty for supervised users
+	BackgroundApps            bool    `json:"background_apps,omitempty"`
+	MetricsBucketIndex        int     `json:"metrics_bucket_index,omitempty"`
+	ShortcutName              string  `json:"shortcut_name,omitempty"` // desktop shortcut on Windows
+}
+
+// ParseLocalState parses "Local State" in the Chrome root.
+func ParseLocalState(filename string) (*LocalState, error) {
+	var state LocalState
+	if err := jsonutil.DecodeFileAllowUnknownFields(filename, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Dirs returns the directories of the profiles in the info cache,
+// ordered by name.
+func (p *LocalStateProfile) Dirs() []string {
+	dirs := make([]string, 0, len(p.InfoCache))
+	for dir := range p.InfoCache {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/secret"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Login Data schema:
+// https://source.chromium.org/chromium/chromium/src/+/master:components/password_manager/core/browser/login_database.cc
+//
+// Since Chrome 87, passwords saved to the Google account without
+// enabling sync are kept in a separate database, "Login Data For
+// Account", with the same schema as "Login Data", which holds the
+// passwords saved to the profile.
+
+// Login is a saved credential in the logins table.
+type Login struct {
+	ID                   int64 // row ID in the store read first
+	OriginURL            string
+	ActionURL            string
+	UsernameElement      string
+	UsernameValue        string
+	PasswordElement      string
+	PasswordValue        []byte // encrypted with os_crypt; see DecryptPassword
+	SignonRealm          string // e.g. "https://example.com/"
+	DateCreated          time.Time
+	DateLastUsed         time.Time // zero before Chrome 81
+	DatePasswordModified time.Time // zero before Chrome 87
+	TimesUsed            int
+	BlockedByUser        bool // "never save" entry for the site
+	Scheme               LoginScheme
+	Store                LoginStore // stores that contain the credential
+}
+
+// LoginScheme is the authentication scheme of a login.
+type LoginScheme uint8
+
+// Values for LoginScheme:
+const (
+	LoginSchemeHTML         LoginScheme = 0
+	LoginSchemeBasic        LoginScheme = 1
+	LoginSchemeDigest       LoginScheme = 2
+	LoginSchemeOther        LoginScheme = 3
+	LoginSchemeUsernameOnly LoginScheme = 4
+)
+
+// LoginStore is a set of the credential stores of a profile.
+type LoginStore uint8
+
+// Values for LoginStore:
+const (
+	LoginStoreProfile LoginStore = 1 << iota // "Login Data"
+	LoginStoreAccount                        // "Login Data For Account"
+)
+
+// Credential databases in a profile:
+const (
+	LoginDataFile           = "Login Data"
+	LoginDataForAccountFile = "Login Data For Account"
+)
+
+// ParseLoginData parses the logins in a "Login Data" or "Login Data
+// For Account" database and attributes them to store. Logins are
+// ordered by ID.
+func ParseLoginData(filename string, store LoginStore) ([]Login, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	cols, err := sqliteutil.Columns(db, "logins")
+	if err != nil {
+		return nil, fmt.Errorf("chrome: login data: %w", err)
+	}
+	has := make(map[string]bool, len(cols))
+	for _, col := range cols {
+		has[col] = true
+	}
+	optional := func(col string) string {
+		if has[col] {
+			return col
+		}
+		return "0"
+	}
+	var logins []Login
+	err = sqliteutil.Query(db, `
+		SELECT id, origin_url, action_url, username_element, username_value,
+			password_element, password_value, signon_realm, date_created,
+			`+optional("date_last_used")+`, `+optional("date_password_modified")+`,
+			times_used, blacklisted_by_user, scheme
+		FROM logins
+		ORDER BY id`, func(rows *sql.Rows) error {
+		l := Login{Store: store}
+		var action, usernameElem, username, passwordElem sql.NullString
+		var created, lastUsed, modified int64
+		if err := rows.Scan(&l.ID, &l.OriginURL, &action, &usernameElem, &username,
+			&passwordElem, &l.PasswordValue, &l.SignonRealm, &created,
+			&lastUsed, &modified, &l.TimesUsed, &l.BlockedByUser, &l.Scheme); err != nil {
+			return err
+		}
+		l.ActionURL = action.String
+		l.UsernameElement = usernameElem.String
+		l.UsernameValue = username.String
+		l.PasswordElement = passwordElem.String
+		var err error
+		if l.DateCreated, err = chromeTime(created); err != nil {
+			return err
+		}
+		if l.DateLastUsed, err = chromeTime(lastUsed); err != nil {
+			return err
+		}
+		if l.DatePasswordModified, err = chromeTime(modified); err != nil {
+			return err
+		}
+		logins = append(logins, l)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chrome: login data: %w", err)
+	}
+	return logins, nil
+}
+
+// ProfileLogins reads the logins of a Chrome profile from both "Login
+// Data" and "Login Data For Account" and merges them. A credential in
+// both stores, by its origin, signon realm, and username and password
+// fields, is listed once with both stores, keeping the row in "Login
+// Data" and the latest use of either. Logins are ordered by signon
+// realm, then username. Missing stores are skipped and stores that fail
+// to parse are handled as by ProfileLoginsOptions with the zero
+// Options.
+func ProfileLogins(profileDir string) ([]Login, error) {
+	return ProfileLoginsOptions(profileDir, browser.Options{})
+}
+
+// ProfileLoginsOptions reads and merges the logins of a Chrome profile
+// and handles stores that fail to parse by the error policy in opts.
+func ProfileLoginsOptions(profileDir string, opts browser.Options) ([]Login, error) {
+	h := opts.Handler()
+	var merged []Login
+	index := make(map[string]int)
+	for _, s := range []struct {
+		name  string
+		store LoginStore
+	}{{LoginDataFile, LoginStoreProfile}, {LoginDataForAccountFile, LoginStoreAccount}} {
+		filename := filepath.Join(profileDir, s.name)
+		logins, err := ParseLoginData(filename, s.store)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			if err := h.File(filename, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, l := range logins {
+			key := strings.Join([]string{l.OriginURL, l.UsernameElement, l.UsernameValue, l.PasswordElement, l.SignonRealm}, "\x00")
+			i, ok := index[key]
+			if !ok {
+				index[key] = len(merged)
+				merged = append(merged, l)
+				continue
+			}
+			m := &merged[i]
+			m.Store |= l.Store
+			if l.DateLastUsed.After(m.DateLastUsed) {
+				m.DateLastUsed = l.DateLastUsed
+			}
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].SignonRealm != merged[j].SignonRealm {
+			return merged[i].SignonRealm < merged[j].SignonRealm
+		}
+		return merged[i].UsernameValue < merged[j].UsernameValue
+	})
+	return merged, h.Err()
+}
+
+// DecryptPassword decrypts the password with a key from OSCrypt.Key or
+// DeriveOSCryptKey.
+func (l *Login) DecryptPassword(key []byte) (secret.Secret, error) {
+	if len(l.PasswordValue) == 0 {
+		return "", nil
+	}
+	plain, err := DecryptOSCryptValue(key, l.PasswordValue)
+	if err != nil {
+		return "", err
+	}
+	return secret.Secret(plain), nil
+}
+
+func (scheme LoginScheme) String() string {
+	switch scheme {
+	case LoginSchemeHTML:
+		return "html"
+	case LoginSchemeBasic:
+		return "basic"
+	case LoginSchemeDigest:
+		return "digest"
+	case LoginSchemeOther:
+		return "other"
+	case LoginSchemeUsernameOnly:
+		return "username_only"
+	default:
+		return fmt.Sprintf("scheme(%d)", uint8(scheme))
+	}
+}
+
+func (store LoginStore) String() string {
+	switch store {
+	case LoginStoreProfile:
+		return "profile"
+	case LoginStoreAccount:
+		return "account"
+	case LoginStoreProfile | LoginStoreAccount:
+		return "profile,account"
+	default:
+		return fmt.Sprintf("store(%d)", uint8(store))
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProfileLogins(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	block, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(block)
+	nonce := make([]byte, gcm.NonceSize())
+	password := gcm.Seal(append([]byte("v10"), nonce...), nonce, []byte("hunter2"), nil)
+
+	dir := t.TempDir()
+	createLogins := func(name, columns, rows string) {
+		db, err := sql.Open("sqlite3", filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		if _, err := db.Exec(`CREATE TABLE logins (origin_url VARCHAR NOT NULL, action_url VARCHAR,
+			username_element VARCHAR, username_value VARCHAR, password_element VARCHAR,
+			password_value BLOB, signon_realm VARCHAR NOT NULL, date_created INTEGER NOT NULL,
+			blacklisted_by_user INTEGER NOT NULL, scheme INTEGER NOT NULL, times_used INTEGER,
+			id INTEGER PRIMARY KEY AUTOINCREMENT` + columns + `)`); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(rows, password); err != nil {
+			t.Fatal(err)
+		}
+	}
+	createLogins(LoginDataFile, ", date_last_used INTEGER NOT NULL DEFAULT 0, date_password_modified INTEGER NOT NULL DEFAULT 0", `
+		INSERT INTO logins VALUES
+			('https://example.com/login', 'https://example.com/session', 'user', 'alice', 'pass', ?1,
+				'https://example.com/', 13258000000000000, 0, 0, 3, 1, 13258000001000000, 13258000000000000),
+			('https://example.org/', '', '', '', '', x'', 'https://example.org/', 13258000000000000, 1, 0, 0, 2, 0, 0)`)
+	createLogins(LoginDataForAccountFile, ", date_last_used INTEGER NOT NULL DEFAULT 0", `
+		INSERT INTO logins VALUES
+			('https://example.com/login', 'https://example.com/session', 'user', 'alice', 'pass', ?1,
+				'https://example.com/', 13258000000000000, 0, 0, 1, 1, 13258000002000000),
+			('https://example.net/', NULL, NULL, 'bob', NULL, ?1,
+				'https://example.net/', 13258000000000000, 0, 1, 0, 2, 0)`)
+
+	logins, err := ProfileLogins(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logins) != 3 {
+		t.Fatalf("got %d logins, want 3", len(logins))
+	}
+	com, net, org := &logins[0], &logins[1], &logins[2]
+	if com.SignonRealm != "https://example.com/" || com.Store != LoginStoreProfile|LoginStoreAccount ||
+		com.TimesUsed != 3 || com.DateLastUsed != chromeTestTime(13258000002000000) ||
+		com.DatePasswordModified != chromeTestTime(13258000000000000) {
+		t.Errorf("example.com: got %+v", com)
+	}
+	if net.SignonRealm != "https://example.net/" || net.Store != LoginStoreAccount ||
+		net.Scheme != LoginSchemeBasic || !net.DatePasswordModified.IsZero() {
+		t.Errorf("example.net: got %+v", net)
+	}
+	if org.SignonRealm != "https://example.org/" || org.Store != LoginStoreProfile || !org.BlockedByUser {
+		t.Errorf("example.org: got %+v", org)
+	}
+	if p, err := com.DecryptPassword(key); err != nil || p.Reveal() != "hunter2" {
+		t.Errorf("got password %q, error %v", p.Reveal(), err)
+	}
+	if p, err := org.DecryptPassword(key); err != nil || !p.IsEmpty() {
+		t.Errorf("got password %q, error %v for blocked site", p.Reveal(), err)
+	}
+}
+
+func chromeTestTime(usec int64) time.Time {
+	t, _ := chromeTime(usec)
+	return t
+}
@@ -0,0 +1,280 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/andrewarchi/browser/protoutil"
+)
+
+// Notification database format:
+// https://source.chromium.org/chromium/chromium/src/+/master:content/browser/notifications/notification_database.cc
+// https://source.chromium.org/chromium/chromium/src/+/master:content/browser/notifications/notification_database_data.proto
+//
+// Keys are of the form "DATA:{origin}\x00{notification_id}" and values
+// are NotificationDatabaseDataProto messages. Despite the field names,
+// times are stored as microseconds since the Windows epoch and
+// durations are stored as milliseconds.
+
+// Notification is a persistent notification displayed by a site using
+// a service worker.
+type Notification struct {
+	ID                           string // e.g. "p#https://example.com/#01234"
+	PersistentID                 int64  // deprecated
+	Origin                       string // e.g. "https://example.com/"
+	ServiceWorkerRegistrationID  int64
+	Data                         NotificationData
+	ReplacedExistingNotification bool
+	NumClicks                    int
+	NumActionButtonClicks        int
+	CreationTime                 time.Time
+	TimeUntilFirstClick          time.Duration
+	TimeUntilLastClick           time.Duration
+	TimeUntilClose               time.Duration
+	ClosedReason                 NotificationClosedReason
+	HasTriggered                 bool
+	IsShownByBrowser             bool
+}
+
+// NotificationData is the content of a notification, as given to
+// ServiceWorkerRegistration.showNotification.
+type NotificationData struct {
+	Title              string
+	Direction          NotificationDirection
+	Lang               string
+	Body               string
+	Tag                string
+	Image              string
+	Icon               string
+	Badge              string
+	VibrationPattern   []int
+	Timestamp          time.Time
+	Renotify           bool
+	Silent             bool
+	RequireInteraction bool
+	Data               []byte // serialized script value
+	Actions            []NotificationAction
+	ShowTriggerTime    time.Time
+}
+
+// NotificationAction is a button or text input displayed on a
+// notification.
+type NotificationAction struct {
+	Type        NotificationActionType
+	Action      string
+	Title       string
+	Icon        string
+	Placeholder string
+}
+
+// NotificationClosedReason is the reason that a notification was
+// closed.
+type NotificationClosedReason uint8
+
+// Values for NotificationClosedReason:
+const (
+	ClosedByUser NotificationClosedReason = iota
+	ClosedByDeveloper
+	ClosedUnknown
+)
+
+// NotificationDirection is the text direction of a notification.
+type NotificationDirection uint8
+
+// Values for NotificationDirection:
+const (
+	DirectionLeftToRight NotificationDirection = iota
+	DirectionRightToLeft
+	DirectionAuto
+)
+
+// NotificationActionType is the type of a notification action.
+type NotificationActionType uint8
+
+// Values for NotificationActionType:
+const (
+	ActionButton NotificationActionType = iota
+	ActionText
+)
+
+var notificationDataPrefix = []byte("DATA:")
+
+// ParsePlatformNotifications parses the "Platform Notifications"
+// LevelDB database in a Chrome profile. Notifications are ordered by
+// origin, then ID.
+func ParsePlatformNotifications(dir string) ([]Notification, error) {
+	var notifications []Notification
+	err := walkLevelDB(dir, notificationDataPrefix, func(key, value []byte) error {
+		n, err := parseNotification(value)
+		if err != nil {
+			return err
+		}
+		// Check that the key and the value agree.
+		k := key[len(notificationDataPrefix):]
+		i := bytes.IndexByte(k, 0)
+		if i == -1 || string(k[:i]) != n.Origin || string(k[i+1:]) != n.ID {
+			return fmt.Errorf("chrome: notification key %q does not match origin %q and ID %q", key, n.Origin, n.ID)
+		}
+		notifications = append(notifications, *n)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func parseNotification(b []byte) (*Notification, error) {
+	var n Notification
+	err := protoutil.Walk(b, "NotificationDatabaseDataProto", func(f *protoutil.Field) error {
+		var err error
+		switch f.Num {
+		case 1:
+			n.PersistentID = f.Int64()
+		case 2:
+			n.Origin = f.String()
+		case 3:
+			n.ServiceWorkerRegistrationID = f.Int64()
+		case 4:
+			err = parseNotificationData(f.Bytes, &n.Data)
+		case 5:
+			n.ID = f.String()
+		case 6:
+			n.ReplacedExistingNotification = f.Bool()
+		case 7:
+			n.NumClicks = f.Int()
+		case 8:
+			n.NumActionButtonClicks = f.Int()
+		case 9:
+			n.CreationTime, err = chromeTime(f.Int64())
+		case 10:
+			n.TimeUntilFirstClick = time.Duration(f.Int64()) * time.Millisecond
+		case 11:
+			n.TimeUntilLastClick = time.Duration(f.Int64()) * time.Millisecond
+		case 12:
+			n.TimeUntilClose = time.Duration(f.Int64()) * time.Millisecond
+		case 13:
+			n.ClosedReason = NotificationClosedReason(f.Varint)
+		case 14:
+			n.HasTriggered = f.Bool()
+		case 15:
+			n.IsShownByBrowser = f.Bool()
+		default:
+			return f.Unknown()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func parseNotificationData(b []byte, d *NotificationData) error {
+	return protoutil.Walk(b, "NotificationData", func(f *protoutil.Field) error {
+		var err error
+		switch f.Num {
+		case 1:
+			d.Title = f.String()
+		case 2:
+			d.Direction = NotificationDirection(f.Varint)
+		case 3:
+			d.Lang = f.String()
+		case 4:
+			d.Body = f.String()
+		case 5:
+			d.Tag = f.String()
+		case 6:
+			d.Icon = f.String()
+		case 7:
+			d.Silent = f.Bool()
+		case 8:
+			d.Data = f.Bytes
+		case 9:
+			d.VibrationPattern, err = f.PackedInts()
+		case 10:
+			var a NotificationAction
+			err = parseNotificationAction(f.Bytes, &a)
+			d.Actions = append(d.Actions, a)
+		case 11:
+			d.RequireInteraction = f.Bool()
+		case 12:
+			d.Timestamp, err = chromeTime(f.Int64())
+		case 13:
+			d.Renotify = f.Bool()
+		case 14:
+			d.Badge = f.String()
+		case 15:
+			d.Image = f.String()
+		case 16:
+			d.ShowTriggerTime, err = chromeTime(f.Int64())
+		default:
+			return f.Unknown()
+		}
+		return err
+	})
+}
+
+func parseNotificationAction(b []byte, a *NotificationAction) error {
+	return protoutil.Walk(b, "NotificationAction", func(f *protoutil.Field) error {
+		switch f.Num {
+		case 1:
+			a.Action = f.String()
+		case 2:
+			a.Title = f.String()
+		case 3:
+			a.Icon = f.String()
+		case 4:
+			a.Type = NotificationActionType(f.Varint)
+		case 5:
+			a.Placeholder = f.String()
+		default:
+			return f.Unknown()
+		}
+		return nil
+	})
+}
+
+func (r NotificationClosedReason) String() string {
+	switch r {
+	case ClosedByUser:
+		return "user"
+	case ClosedByDeveloper:
+		return "developer"
+	case ClosedUnknown:
+		return "unknown"
+	default:
+		return fmt.Sprintf("closed_reason(%d)", uint8(r))
+	}
+}
+
+func (d NotificationDirection) String() string {
+	switch d {
+	case DirectionLeftToRight:
+		return "ltr"
+	case DirectionRightToLeft:
+		return "rtl"
+	case DirectionAuto:
+		return "auto"
+	default:
+		return fmt.Sprintf("direction(%d)", uint8(d))
+	}
+}
+
+func (typ NotificationActionType) String() string {
+	switch typ {
+	case ActionButton:
+		return "button"
+	case ActionText:
+		return "text"
+	default:
+		return fmt.Sprintf("action_type(%d)", uint8(typ))
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestParseNotification(t *testing.T) {
+	var action []byte
+	action = protowire.AppendTag(action, 1, protowire.BytesType)
+	action = protowire.AppendString(action, "reply")
+	action = protowire.AppendTag(action, 4, protowire.VarintType)
+	action = protowire.AppendVarint(action, uint64(ActionText))
+
+	var data []byte
+	data = protowire.AppendTag(data, 1, protowire.BytesType)
+	data = protowire.AppendString(data, "Title")
+	data = protowire.AppendTag(data, 4, protowire.BytesType)
+	data = protowire.AppendString(data, "Body")
+	data = protowire.AppendTag(data, 9, protowire.BytesType)
+	data = protowire.AppendBytes(data, []byte{200, 1, 100})
+	data = protowire.AppendTag(data, 10, protowire.BytesType)
+	data = protowire.AppendBytes(data, action)
+
+	var b []byte
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendString(b, "https://example.com/")
+	b = protowire.AppendTag(b, 4, protowire.BytesType)
+	b = protowire.AppendBytes(b, data)
+	b = protowire.AppendTag(b, 5, protowire.BytesType)
+	b = protowire.AppendString(b, "p#https://example.com/#01")
+	b = protowire.AppendTag(b, 9, protowire.VarintType)
+	b = protowire.AppendVarint(b, 13258080000000000) // 2021-02-18 00:00:00 UTC
+	b = protowire.AppendTag(b, 12, protowire.VarintType)
+	b = protowire.AppendVarint(b, 1500)
+
+	n, err := parseNotification(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Origin != "https://example.com/" || n.ID != "p#https://example.com/#01" {
+		t.Errorf("got origin %q and ID %q", n.Origin, n.ID)
+	}
+	if want := time.Date(2021, 2, 18, 0, 0, 0, 0, time.UTC); !n.CreationTime.Equal(want) {
+		t.Errorf("got creation time %s, want %s", n.CreationTime, want)
+	}
+	if n.TimeUntilClose != 1500*time.Millisecond {
+		t.Errorf("got time until close %s, want 1.5s", n.TimeUntilClose)
+	}
+	if n.Data.Title != "Title" || n.Data.Body != "Body" {
+		t.Errorf("got title %q and body %q", n.Data.Title, n.Data.Body)
+	}
+	if p := n.Data.VibrationPattern; len(p) != 2 || p[0] != 200 || p[1] != 100 {
+		t.Errorf("got vibration pattern %v, want [200 100]", p)
+	}
+	if len(n.Data.Actions) != 1 || n.Data.Actions[0].Action != "reply" || n.Data.Actions[0].Type != ActionText {
+		t.Errorf("got actions %v", n.Data.Actions)
+	}
+
+	b = protowire.AppendTag(b, 99, protowire.VarintType)
+	b = protowire.AppendVarint(b, 1)
+	if _, err := parseNotification(b); err == nil {
+		t.Error("unknown field not rejected")
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// OS crypt reference:
+// https://source.chromium.org/chromium/chromium/src/+/master:components/os_crypt/os_crypt_win.cc
+// https://source.chromium.org/chromium/chromium/src/+/master:components/os_crypt/os_crypt_mac.mm
+// https://source.chromium.org/chromium/chromium/src/+/master:components/os_crypt/os_crypt_linux.cc
+//
+// Encrypted values, such as encrypted_value in Cookies and
+// password_value in Login Data, are prefixed with "v10" or "v11". On
+// Windows, they are encrypted with AES-256-GCM with a key in Local
+// State, which is itself encrypted with DPAPI. On macOS and Linux, they
+// are encrypted with AES-128-CBC with a key derived from a password in
+// the keychain or keyring.
+
+// OSCrypt contains the encrypted key for os_crypt in Local State. It is
+// only present on Windows.
+type OSCrypt struct {
+	EncryptedKey string `json:"encrypted_key,omitempty"` // base64 of "DPAPI" and a DPAPI blob
+}
+
+// Decryptor decrypts data protected by the operating system, such as
+// with CryptUnprotectData on Windows. It is provided by the caller, so
+// that keys can be decrypted offline or on another machine with the
+// credentials of the user.
+type Decryptor interface {
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// DecryptorFunc is a function that implements Decryptor.
+type DecryptorFunc func(ciphertext []byte) ([]byte, error)
+
+// Decrypt calls f(ciphertext).
+func (f DecryptorFunc) Decrypt(ciphertext []byte) ([]byte, error) { return f(ciphertext) }
+
+// ErrNoOSCryptKey is returned when Local State has no os_crypt key, such
+// as on macOS and Linux.
+var ErrNoOSCryptKey = errors.New("chrome: no os_crypt key in Local State")
+
+var errUnversioned = errors.New("chrome: value is not encrypted with os_crypt")
+
+// Parameters for deriving os_crypt keys on macOS and Linux with
+// DeriveOSCryptKey.
+const (
+	OSCryptIterationsMac   = 1003
+	OSCryptIterationsLinux = 1
+	// OSCryptLinuxPassword is the password for "v10" values on Linux,
+	// which are used when no keyring is available.
+	OSCryptLinuxPassword = "peanuts"
+)
+
+const (
+	osCryptKeyPrefix = "DPAPI"
+	osCryptSalt      = "saltysalt"
+	osCryptKeyLen    = 16
+	osCryptNonceLen  = 12
+)
+
+// Key decrypts the os_crypt key with d, which must undo DPAPI, and
+// returns the AES-256 key for encrypted values.
+func (c *OSCrypt) Key(d Decryptor) ([]byte, error) {
+	if c.EncryptedKey == "" {
+		return nil, ErrNoOSCryptKey
+	}
+	b, err := base64.StdEncoding.DecodeString(c.EncryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf("chrome: os_crypt key: %w", err)
+	}
+	if !bytes.HasPrefix(b, []byte(osCryptKeyPrefix)) {
+		return nil, fmt.Errorf("chrome: os_crypt key does not have %s prefix", osCryptKeyPrefix)
+	}
+	key, err := d.Decrypt(b[len(osCryptKeyPrefix):])
+	if err != nil {
+		return nil, fmt.Errorf("chrome: os_crypt key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("chrome: os_crypt key has length %d, want 32", len(key))
+	}
+	return key, nil
+}
+
+// ExtractOSCryptKey reads the os_crypt key from "Local State" in the
+// Chrome root and decrypts it with d.
+func ExtractOSCryptKey(localStateFile string, d Decryptor) ([]byte, error) {
+	state, err := ParseLocalState(localStateFile)
+	if err != nil {
+		return nil, err
+	}
+	return state.OSCrypt.Key(d)
+}
+
+// DeriveOSCryptKey derives the AES-128 key for encrypted values on macOS
+// and Linux from the password in the keychain ("Chrome Safe Storage")
+// or keyring, with OSCryptIterationsMac or OSCryptIterationsLinux.
+func DeriveOSCryptKey(password []byte, iterations int) []byte {
+	// PBKDF2-HMAC-SHA1 with a single block, since the key is shorter
+	// than the hash.
+	m := hmac.New(sha1.New, password)
+	m.Write([]byte(osCryptSalt))
+	m.Write([]byte{0, 0, 0, 1})
+	u := m.Sum(nil)
+	key := append([]byte{}, u...)
+	for n := 1; n < iterations; n++ {
+		m.Reset()
+		m.Write(u)
+		u = m.Sum(u[:0])
+		for i := range key {
+			key[i] ^= u[i]
+		}
+	}
+	return key[:osCryptKeyLen]
+}
+
+// DecryptOSCryptValue decrypts a "v10" or "v11" value with a key from
+// OSCrypt.Key or DeriveOSCryptKey. AES-256 keys decrypt with GCM, as
+// on Windows, and AES-128 keys with CBC, as on macOS and Linux.
+func DecryptOSCryptValue(key, value []byte) ([]byte, error) {
+	if len(value) < 3 || (string(value[:3]) != "v10" && string(value[:3]) != "v11") {
+		return nil, errUnversioned
+	}
+	value = value[3:]
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("chrome: os_crypt: %w", err)
+	}
+	if len(key) == 32 {
+		if len(value) < osCryptNonceLen {
+			return nil, errors.New("chrome: os_crypt: value too short")
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("chrome: os_crypt: %w", err)
+		}
+		plaintext, err := gcm.Open(nil, value[:osCryptNonceLen], value[osCryptNonceLen:], nil)
+		if err != nil {
+			return nil, fmt.Errorf("chrome: os_crypt: %w", err)
+		}
+		return plaintext, nil
+	}
+	if len(value) == 0 || len(value)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("chrome: os_crypt: value length %d is not a multiple of the block size", len(value))
+	}
+	iv := bytes.Repeat([]byte{' '}, aes.BlockSize)
+	plaintext := make([]byte, len(value))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, value)
+	pad := int(plaintext[len(plaintext)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(plaintext) {
+		return nil, errors.New("chrome: os_crypt: invalid padding")
+	}
+	for _, b := range plaintext[len(plaintext)-pad:] {
+		if int(b) != pad {
+			return nil, errors.New("chrome: os_crypt: invalid padding")
+		}
+	}
+	return plaintext[:len(plaintext)-pad], nil
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOSCryptKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	// xor stands in for DPAPI.
+	xor := DecryptorFunc(func(b []byte) ([]byte, error) {
+		out := make([]byte, len(b))
+		for i := range b {
+			out[i] = b[i] ^ 0xff
+		}
+		return out, nil
+	})
+	blob, _ := xor(key)
+	encrypted := base64.StdEncoding.EncodeToString(append([]byte("DPAPI"), blob...))
+	filename := filepath.Join(t.TempDir(), "Local State")
+	if err := os.WriteFile(filename, []byte(`{"os_crypt":{"encrypted_key":"`+encrypted+`"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ExtractOSCryptKey(filename, xor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, key) {
+		t.Fatalf("got key %x, want %x", got, key)
+	}
+
+	block, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(block)
+	nonce := make([]byte, gcm.NonceSize())
+	value := append([]byte("v10"), nonce...)
+	value = gcm.Seal(value, nonce, []byte("cookie"), nil)
+	plaintext, err := DecryptOSCryptValue(key, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "cookie" {
+		t.Errorf("got plaintext %q, want %q", plaintext, "cookie")
+	}
+
+	var empty OSCrypt
+	if _, err := empty.Key(xor); !errors.Is(err, ErrNoOSCryptKey) {
+		t.Errorf("got error %v, want ErrNoOSCryptKey", err)
+	}
+}
+
+func TestDeriveOSCryptKey(t *testing.T) {
+	tests := []struct {
+		Password   string
+		Iterations int
+		Key        string
+	}{
+		{OSCryptLinuxPassword, OSCryptIterationsLinux, "fd621fe5a2b402539dfa147ca9272778"},
+		{"password", OSCryptIterationsMac, "9395139d5abdba8b749042ad882c0937"},
+	}
+	for i, tt := range tests {
+		key := DeriveOSCryptKey([]byte(tt.Password), tt.Iterations)
+		if got := hex.EncodeToString(key); got != tt.Key {
+			t.Errorf("#%d: got: %s, want: %s", i, got, tt.Key)
+		}
+	}
+
+	key := DeriveOSCryptKey([]byte(OSCryptLinuxPassword), OSCryptIterationsLinux)
+	block, _ := aes.NewCipher(key)
+	padded := append([]byte("password"), bytes.Repeat([]byte{8}, 8)...)
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, bytes.Repeat([]byte{' '}, aes.BlockSize)).CryptBlocks(ciphertext, padded)
+	plaintext, err := DecryptOSCryptValue(key, append([]byte("v11"), ciphertext...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "password" {
+		t.Errorf("got plaintext %q, want %q", plaintext, "password")
+	}
+}
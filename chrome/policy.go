@@ -0,0 +1,422 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/jsonutil"
+	"howett.net/plist"
+)
+
+// Policy locations:
+// https://www.chromium.org/administrators/linux-quick-start
+// https://www.chromium.org/administrators/mac-quick-start
+// https://www.chromium.org/administrators/windows-quick-start
+// https://source.chromium.org/chromium/chromium/src/+/master:components/policy/core/common/policy_loader_win.cc
+//
+// Policy names and value types are listed in policy_templates.json:
+// https://source.chromium.org/chromium/chromium/src/+/master:components/policy/resources/policy_templates.json
+
+// Policy is a setting enforced or recommended by an administrator.
+type Policy struct {
+	Name   string      // e.g. "HomepageLocation"
+	Value  interface{} // bool, string, number, []interface{}, or map[string]interface{}
+	Level  PolicyLevel
+	Scope  PolicyScope
+	Source string // file that set the policy
+}
+
+// PolicyLevel is whether the user can override a policy.
+type PolicyLevel uint8
+
+// Values for PolicyLevel:
+const (
+	PolicyMandatory   PolicyLevel = iota // cannot be changed by the user
+	PolicyRecommended                    // default that the user can change
+)
+
+// PolicyScope is whether a policy applies to the machine or a user.
+type PolicyScope uint8
+
+// Values for PolicyScope:
+const (
+	PolicyMachine PolicyScope = iota
+	PolicyUser
+)
+
+// LinuxPolicyDirs are the policy directories for Chrome and Chromium on
+// Linux.
+var LinuxPolicyDirs = []string{
+	"/etc/opt/chrome/policies",
+	"/etc/chromium/policies",
+}
+
+// MacPolicyPlists are the managed preferences plists for Chrome and
+// Chromium on macOS, which are set with configuration profiles. Per-user
+// plists are in "/Library/Managed Preferences/{user}/".
+var MacPolicyPlists = []string{
+	"/Library/Managed Preferences/com.google.Chrome.plist",
+	"/Library/Managed Preferences/org.chromium.Chromium.plist",
+}
+
+// ParsePolicyDir parses the JSON policy files in the "managed" and
+// "recommended" subdirectories of a Linux policy directory, such as
+// "/etc/opt/chrome/policies". Files are read in name order, so a policy
+// in a later file replaces an earlier one, as in Chrome. Files that fail
+// to parse are handled as by ParsePolicyDirOptions with the zero
+// Options.
+func ParsePolicyDir(dir string) ([]Policy, error) {
+	return ParsePolicyDirOptions(dir, browser.Options{})
+}
+
+// ParsePolicyDirOptions parses the JSON policy files in a Linux policy
+// directory and handles files that fail to parse by the error policy in
+// opts.
+func ParsePolicyDirOptions(dir string, opts browser.Options) ([]Policy, error) {
+	h := opts.Handler()
+	var policies []Policy
+	for _, sub := range []struct {
+		Name  string
+		Level PolicyLevel
+	}{{"managed", PolicyMandatory}, {"recommended", PolicyRecommended}} {
+		files, err := filepath.Glob(filepath.Join(dir, sub.Name, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+		merged := make(map[string]Policy)
+		for _, file := range files {
+			var values map[string]interface{}
+			if err := jsonutil.DecodeFile(file, &values); err != nil {
+				if err := h.File(file, fmt.Errorf("chrome: policy: %w", err)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			for name, value := range values {
+				merged[name] = Policy{name, value, sub.Level, PolicyMachine, file}
+			}
+		}
+		for _, p := range merged {
+			policies = append(policies, p)
+		}
+	}
+	sortPolicies(policies)
+	return policies, h.Err()
+}
+
+// ParsePolicyPlist parses a macOS managed preferences plist, in XML or
+// binary form. Managed preferences are mandatory.
+func ParsePolicyPlist(filename string, scope PolicyScope) ([]Policy, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]interface{}
+	if _, err := plist.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("chrome: policy %s: %w", filename, err)
+	}
+	policies := make([]Policy, 0, len(values))
+	for name, value := range values {
+		if name == "PayloadUUID" || strings.HasPrefix(name, "PolicyGroup") {
+			// Added by configuration profiles.
+			continue
+		}
+		policies = append(policies, Policy{name, value, PolicyMandatory, scope, filename})
+	}
+	sortPolicies(policies)
+	return policies, nil
+}
+
+// ParsePolicyReg parses a Windows registry export (.reg) of a policy key,
+// such as
+// HKEY_LOCAL_MACHINE\SOFTWARE\Policies\Google\Chrome. Values in the
+// "Recommended" subkey are recommended and other subkeys are list
+// policies, with values named by index.
+func ParsePolicyReg(filename string) ([]Policy, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := parseReg(data)
+	if err != nil {
+		return nil, fmt.Errorf("chrome: policy %s: %w", filename, err)
+	}
+
+	var policies []Policy
+	lists := make(map[string]*Policy)
+	for _, k := range keys {
+		scope := PolicyMachine
+		if strings.HasPrefix(k.Path, `HKEY_CURRENT_USER\`) {
+			scope = PolicyUser
+		}
+		parts := strings.Split(k.Path, `\`)
+		i := -1
+		for j := range parts {
+			if strings.EqualFold(parts[j], "Policies") {
+				i = j
+				break
+			}
+		}
+		if i == -1 || len(parts) < i+3 {
+			continue // not a policy key, e.g. HKEY_LOCAL_MACHINE\SOFTWARE\Policies
+		}
+		sub := parts[i+3:] // after Policies\{vendor}\{product}
+		level := PolicyMandatory
+		if len(sub) != 0 && sub[0] == "Recommended" {
+			level = PolicyRecommended
+			sub = sub[1:]
+		}
+		switch len(sub) {
+		case 0:
+			for _, v := range k.Values {
+				policies = append(policies, Policy{v.Name, v.Value, level, scope, filename})
+			}
+		case 1:
+			id := fmt.Sprintf("%d/%d/%s", scope, level, sub[0])
+			list, ok := lists[id]
+			if !ok {
+				list = &Policy{sub[0], []interface{}{}, level, scope, filename}
+				lists[id] = list
+			}
+			// Lists are indexed from 1 and Chrome stops at the first gap.
+			values := make(map[int]interface{}, len(k.Values))
+			for _, v := range k.Values {
+				if n, err := strconv.Atoi(v.Name); err == nil {
+					values[n] = v.Value
+				}
+			}
+			for n := 1; ; n++ {
+				v, ok := values[n]
+				if !ok {
+					break
+				}
+				list.Value = append(list.Value.([]interface{}), v)
+			}
+		default:
+			return nil, fmt.Errorf("chrome: policy %s: nested key %s", filename, k.Path)
+		}
+	}
+	for _, list := range lists {
+		policies = append(policies, *list)
+	}
+	sortPolicies(policies)
+	return policies, nil
+}
+
+// PlatformPolicies parses policies from the Linux and macOS locations
+// that exist on this machine.
+func PlatformPolicies() ([]Policy, error) {
+	var policies []Policy
+	for _, dir := range LinuxPolicyDirs {
+		p, err := ParsePolicyDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p...)
+	}
+	for _, file := range MacPolicyPlists {
+		p, err := ParsePolicyPlist(file, PolicyMachine)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p...)
+	}
+	return policies, nil
+}
+
+func sortPolicies(policies []Policy) {
+	sort.SliceStable(policies, func(i, j int) bool {
+		if policies[i].Name != policies[j].Name {
+			return policies[i].Name < policies[j].Name
+		}
+		return policies[i].Level < policies[j].Level
+	})
+}
+
+// regKey is a key in a registry export.
+type regKey struct {
+	Path   string
+	Values []regValue
+}
+
+type regValue struct {
+	Name  string
+	Value interface{} // string, int64, or []string
+}
+
+// parseReg parses a registry export, as written by regedit in UTF-16LE
+// ("Windows Registry Editor Version 5.00") or ANSI ("REGEDIT4").
+// https://support.microsoft.com/en-us/help/310516
+func parseReg(data []byte) ([]regKey, error) {
+	if bytes.HasPrefix(data, []byte{0xff, 0xfe}) {
+		data = []byte(decodeUTF16(data[2:]))
+	}
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(nil, 1<<20)
+	if !s.Scan() {
+		return nil, errors.New("empty registry export")
+	}
+	if header := strings.TrimSpace(s.Text()); header != "Windows Registry Editor Version 5.00" && header != "REGEDIT4" {
+		return nil, fmt.Errorf("not a registry export: %q", header)
+	}
+	var keys []regKey
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		for strings.HasSuffix(line, `\`) && s.Scan() { // continued hex data
+			line = line[:len(line)-1] + strings.TrimSpace(s.Text())
+		}
+		switch {
+		case line == "" || line[0] == ';':
+		case line[0] == '[':
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("malformed key: %q", line)
+			}
+			keys = append(keys, regKey{Path: line[1 : len(line)-1]})
+		default:
+			if len(keys) == 0 {
+				return nil, fmt.Errorf("value outside of key: %q", line)
+			}
+			v, err := parseRegValue(line)
+			if err != nil {
+				return nil, err
+			}
+			k := &keys[len(keys)-1]
+			k.Values = append(k.Values, *v)
+		}
+	}
+	return keys, s.Err()
+}
+
+func parseRegValue(line string) (*regValue, error) {
+	var v regValue
+	var rest string
+	if strings.HasPrefix(line, "@=") {
+		rest = line[2:]
+	} else {
+		name, n, err := readRegString(line)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line[n:], "=") {
+			return nil, fmt.Errorf("malformed value: %q", line)
+		}
+		v.Name, rest = name, line[n+1:]
+	}
+	switch {
+	case strings.HasPrefix(rest, `"`):
+		str, _, err := readRegString(rest)
+		if err != nil {
+			return nil, err
+		}
+		v.Value = str
+	case strings.HasPrefix(rest, "dword:"):
+		n, err := strconv.ParseUint(rest[len("dword:"):], 16, 32)
+		if err != nil {
+			return nil, err
+		}
+		v.Value = int64(n)
+	default:
+		i := strings.IndexByte(rest, ':')
+		if i == -1 || !strings.HasPrefix(rest, "hex") {
+			return nil, fmt.Errorf("malformed value: %q", line)
+		}
+		b, err := hex.DecodeString(strings.ReplaceAll(rest[i+1:], ",", ""))
+		if err != nil {
+			return nil, err
+		}
+		switch typ := rest[:i]; typ {
+		case "hex(b)": // REG_QWORD
+			if len(b) != 8 {
+				return nil, fmt.Errorf("qword has %d bytes", len(b))
+			}
+			var n uint64
+			for j := 7; j >= 0; j-- {
+				n = n<<8 | uint64(b[j])
+			}
+			v.Value = int64(n)
+		case "hex(2)": // REG_EXPAND_SZ
+			v.Value = strings.TrimRight(decodeUTF16(b), "\x00")
+		case "hex(7)": // REG_MULTI_SZ
+			v.Value = strings.Split(strings.TrimRight(decodeUTF16(b), "\x00"), "\x00")
+		default:
+			return nil, fmt.Errorf("unsupported value type %s", typ)
+		}
+	}
+	return &v, nil
+}
+
+// readRegString reads a quoted string at the start of s and returns it
+// and the number of bytes read.
+func readRegString(s string) (string, int, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", 0, fmt.Errorf("malformed string: %q", s)
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			i++
+			if i == len(s) {
+				break
+			}
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string: %q", s)
+}
+
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = uint16(b[2*i]) | uint16(b[2*i+1])<<8
+	}
+	return string(utf16.Decode(u))
+}
+
+func (l PolicyLevel) String() string {
+	switch l {
+	case PolicyMandatory:
+		return "mandatory"
+	case PolicyRecommended:
+		return "recommended"
+	default:
+		return fmt.Sprintf("level(%d)", uint8(l))
+	}
+}
+
+func (s PolicyScope) String() string {
+	switch s {
+	case PolicyMachine:
+		return "machine"
+	case PolicyUser:
+		return "user"
+	default:
+		return fmt.Sprintf("scope(%d)", uint8(s))
+	}
+}
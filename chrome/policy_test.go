@@ -0,0 +1,37 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseReg(t *testing.T) {
+	const data = "REGEDIT4\r\n\r\n" +
+		"[HKEY_LOCAL_MACHINE\\SOFTWARE\\Policies\\Google\\Chrome]\r\n" +
+		"\"HomepageLocation\"=\"https://example.com/\\\"q\\\"\"\r\n" +
+		"\"BrowserSignin\"=dword:00000002\r\n" +
+		"\"Size\"=hex(b):00,01,00,00,00,00,\\\r\n  00,00\r\n" +
+		"\"Paths\"=hex(7):61,00,00,00,62,00,00,00,00,00\r\n"
+	keys, err := parseReg([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []regKey{{
+		Path: `HKEY_LOCAL_MACHINE\SOFTWARE\Policies\Google\Chrome`,
+		Values: []regValue{
+			{"HomepageLocation", `https://example.com/"q"`},
+			{"BrowserSignin", int64(2)},
+			{"Size", int64(256)},
+			{"Paths", []string{"a", "b"}},
+		},
+	}}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("got: %#v, want: %#v", keys, want)
+	}
+}
@@ -0,0 +1,390 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/jsonutil"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+)
+
+// Preferences contains selected settings from "Preferences" in a Chrome
+// profile. The file holds hundreds of settings that vary by version and
+// platform, so only known settings are decoded and the rest are
+// ignored.
+//
+// Preference names:
+// https://source.chromium.org/chromium/chromium/src/+/master:chrome/common/pref_names.cc
+type Preferences struct {
+	Session              SessionPreferences    `json:"session"`
+	PinnedTabs           []PinnedTab           `json:"pinned_tabs,omitempty"` // deprecated; pinned tabs are restored with the session
+	Homepage             string                `json:"homepage,omitempty"`
+	HomepageIsNewTabPage *bool                 `json:"homepage_is_newtabpage,omitempty"`
+	AccountInfo          []AccountInfo         `json:"account_info,omitempty"`
+	WebApps              WebAppsPreferences    `json:"web_apps"`
+	Profile              ProfilePreferences    `json:"profile"`
+	Extensions           ExtensionsPreferences `json:"extensions"`
+}
+
+// ProfilePreferences contains the settings of the profile itself and
+// its content settings.
+type ProfilePreferences struct {
+	Name                        string                         `json:"name,omitempty"`
+	AvatarIndex                 *int                           `json:"avatar_index,omitempty"`
+	CreatedByVersion            string                         `json:"created_by_version,omitempty"`
+	CreationTime                *timefmt.QuotedChrome          `json:"creation_time,omitempty"`
+	ExitType                    string                         `json:"exit_type,omitempty"` // e.g. "Normal", "Crashed", or "SessionEnded"
+	ContentSettings             ContentSettings                `json:"content_settings"`
+	DefaultContentSettingValues map[string]ContentSettingValue `json:"default_content_setting_values,omitempty"` // key: content type
+}
+
+// ContentSettings contains the per-site exceptions to the default
+// content settings.
+// https://source.chromium.org/chromium/chromium/src/+/master:components/content_settings/core/browser/content_settings_pref.cc
+type ContentSettings struct {
+	// Exceptions is keyed by content type, e.g. "cookies" or
+	// "notifications", then by a pattern pair, e.g.
+	// "https://example.com:443,*".
+	Exceptions map[string]map[string]ContentSettingException `json:"exceptions,omitempty"`
+}
+
+// ContentSettingException is the setting of a content type for a
+// pattern pair.
+type ContentSettingException struct {
+	LastModified *timefmt.QuotedChrome `json:"last_modified,omitempty"`
+	LastVisit    *timefmt.QuotedChrome `json:"last_visit,omitempty"`
+	Expiration   *timefmt.QuotedChrome `json:"expiration,omitempty"` // "0" for no expiration
+	Model        int                   `json:"model,omitempty"`      // content_settings::mojom::SessionModel
+	// Setting is a ContentSettingValue for most types, but an object
+	// for website settings, like "site_engagement".
+	Setting json.RawMessage `json:"setting,omitempty"`
+}
+
+// ContentSettingRule is a content setting exception, flattened with its
+// content type and patterns.
+type ContentSettingRule struct {
+	Type             string
+	PrimaryPattern   string // pattern of the URL, e.g. "https://[*.]example.com"
+	SecondaryPattern string // pattern of the top-level URL, or "*"
+	Setting          ContentSettingValue
+	IsWebsiteSetting bool // the setting is not a ContentSettingValue; see Exception.Setting
+	Exception        ContentSettingException
+}
+
+// ContentSettingValue is the value of a content setting.
+// https://source.chromium.org/chromium/chromium/src/+/master:components/content_settings/core/common/content_settings.h
+type ContentSettingValue uint8
+
+// Values for ContentSettingValue:
+const (
+	ContentSettingDefault                ContentSettingValue = 0
+	ContentSettingAllow                  ContentSettingValue = 1
+	ContentSettingBlock                  ContentSettingValue = 2
+	ContentSettingAsk                    ContentSettingValue = 3
+	ContentSettingSessionOnly            ContentSettingValue = 4
+	ContentSettingDetectImportantContent ContentSettingValue = 5
+)
+
+// ExtensionsPreferences contains the settings of installed extensions.
+// On Windows and macOS, the settings are tracked preferences in "Secure
+// Preferences" rather than "Preferences"; ParseProfilePreferences reads
+// both.
+// https://source.chromium.org/chromium/chromium/src/+/master:extensions/browser/extension_prefs.cc
+type ExtensionsPreferences struct {
+	Settings map[string]ExtensionSettings `json:"settings,omitempty"` // key: extension ID
+}
+
+// ExtensionSettings contains the settings of an installed extension.
+type ExtensionSettings struct {
+	Location              ExtensionLocation       `json:"location"`
+	Path                  string                  `json:"path,omitempty"` // relative to Extensions, or absolute when unpacked
+	State                 *int                    `json:"state,omitempty"`
+	DisableReasons        ExtensionDisableReasons `json:"disable_reasons,omitempty"`
+	FromWebStore          bool                    `json:"from_webstore,omitempty"`
+	WasInstalledByDefault bool                    `json:"was_installed_by_default,omitempty"`
+	WasInstalledByOEM     bool                    `json:"was_installed_by_oem,omitempty"`
+	InstallTime           *timefmt.QuotedChrome   `json:"install_time,omitempty"`
+	FirstInstallTime      *timefmt.QuotedChrome   `json:"first_install_time,omitempty"`
+	LastUpdateTime        *timefmt.QuotedChrome   `json:"last_update_time,omitempty"`
+	CreationFlags         int                     `json:"creation_flags,omitempty"`
+	Incognito             bool                    `json:"incognito,omitempty"` // allowed in incognito
+	ActivePermissions     *ExtensionPermissions   `json:"active_permissions,omitempty"`
+	GrantedPermissions    *ExtensionPermissions   `json:"granted_permissions,omitempty"`
+}
+
+// ExtensionPermissions is a set of permissions of an extension.
+type ExtensionPermissions struct {
+	API                 []json.RawMessage `json:"api,omitempty"` // names, or objects for permissions with arguments
+	ExplicitHost        []string          `json:"explicit_host,omitempty"`
+	ScriptableHost      []string          `json:"scriptable_host,omitempty"`
+	ManifestPermissions []json.RawMessage `json:"manifest_permissions,omitempty"`
+}
+
+// ExtensionLocation is where an extension was installed from.
+// https://source.chromium.org/chromium/chromium/src/+/master:extensions/common/mojom/manifest.mojom
+type ExtensionLocation uint8
+
+// Values for ExtensionLocation:
+const (
+	ExtensionLocationInvalid                ExtensionLocation = 0
+	ExtensionLocationInternal               ExtensionLocation = 1 // installed by the user, e.g. from the web store
+	ExtensionLocationExternalPref           ExtensionLocation = 2
+	ExtensionLocationExternalRegistry       ExtensionLocation = 3
+	ExtensionLocationUnpacked               ExtensionLocation = 4 // loaded unpacked in developer mode
+	ExtensionLocationComponent              ExtensionLocation = 5
+	ExtensionLocationExternalPrefDownload   ExtensionLocation = 6
+	ExtensionLocationExternalPolicyDownload ExtensionLocation = 7 // force-installed by policy
+	ExtensionLocationCommandLine            ExtensionLocation = 8
+	ExtensionLocationExternalPolicy         ExtensionLocation = 9
+	ExtensionLocationExternalComponent      ExtensionLocation = 10
+)
+
+// ExtensionDisableReasons are the reasons that an extension is
+// disabled, e.g. 1 for disabled by the user. Before Chrome 134, the
+// reasons were stored as a bit mask, which is decoded as a list of its
+// bits.
+// https://source.chromium.org/chromium/chromium/src/+/master:extensions/browser/disable_reason.h
+type ExtensionDisableReasons []int
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (r *ExtensionDisableReasons) UnmarshalJSON(data []byte) error {
+	var mask int
+	if err := json.Unmarshal(data, &mask); err != nil {
+		return json.Unmarshal(data, (*[]int)(r))
+	}
+	*r = nil
+	for bit := 1; bit <= mask && bit > 0; bit <<= 1 {
+		if mask&bit != 0 {
+			*r = append(*r, bit)
+		}
+	}
+	return nil
+}
+
+// SessionPreferences contains settings for what is opened on startup.
+type SessionPreferences struct {
+	RestoreOnStartup RestoreOnStartup `json:"restore_on_startup,omitempty"`
+	StartupURLs      []string         `json:"startup_urls,omitempty"`
+}
+
+// PinnedTab is a tab pinned to the tab strip.
+// https://source.chromium.org/chromium/chromium/src/+/master:chrome/browser/ui/tabs/pinned_tab_codec.cc
+type PinnedTab struct {
+	URL string `json:"url"`
+}
+
+// AccountInfo is a Google account signed in to the profile.
+// https://source.chromium.org/chromium/chromium/src/+/master:components/signin/internal/identity_manager/account_tracker_service.cc
+type AccountInfo struct {
+	AccountID    string `json:"account_id"` // Gaia ID, or email in older profiles
+	Email        string `json:"email"`
+	FullName     string `json:"full_name"`
+	GivenName    string `json:"given_name"`
+	Gaia         string `json:"gaia"`
+	HostedDomain string `json:"hd"` // e.g. "NO_HOSTED_DOMAIN" or "example.com"
+	Locale       string `json:"locale"`
+	PictureURL   string `json:"picture_url"`
+}
+
+// WebAppsPreferences contains the settings for installed web apps.
+// https://source.chromium.org/chromium/chromium/src/+/master:chrome/browser/web_applications/web_app_prefs_utils.cc
+// https://source.chromium.org/chromium/chromium/src/+/master:chrome/browser/web_applications/externally_installed_web_app_prefs.cc
+type WebAppsPreferences struct {
+	WebAppIDs    map[string]WebAppPrefs    `json:"web_app_ids,omitempty"`   // key: app ID
+	ExtensionIDs map[string]ExternalWebApp `json:"extension_ids,omitempty"` // key: install URL
+}
+
+// WebAppPrefs contains the settings for a web app.
+type WebAppPrefs struct {
+	LatestInstallSource             *int  `json:"latest_web_app_install_source,omitempty"` // webapps::WebappInstallSource
+	FileHandlersEnabled             *bool `json:"file_handlers_enabled,omitempty"`
+	WasExternalAppUninstalledByUser bool  `json:"was_external_app_uninstalled_by_user,omitempty"`
+}
+
+// ExternalWebApp is a web app installed by policy or preinstalled,
+// keyed by the URL it was installed from.
+type ExternalWebApp struct {
+	ExtensionID   string `json:"extension_id"` // app ID
+	InstallSource int    `json:"install_source"`
+	IsPlaceholder bool   `json:"is_placeholder,omitempty"` // installed as a shortcut when the URL failed to load
+}
+
+// RestoreOnStartup is the action taken on startup.
+// https://source.chromium.org/chromium/chromium/src/+/master:chrome/browser/prefs/session_startup_pref.cc
+type RestoreOnStartup uint8
+
+// Values for RestoreOnStartup:
+const (
+	RestoreDefault RestoreOnStartup = 0 // unset or deprecated homepage; new tab page
+	RestoreLast    RestoreOnStartup = 1 // continue where you left off
+	RestoreURLs    RestoreOnStartup = 4 // open session.startup_urls
+	RestoreNewTab  RestoreOnStartup = 5 // open the new tab page
+)
+
+// ParsePreferences parses "Preferences" in a Chrome profile.
+func ParsePreferences(filename string) (*Preferences, error) {
+	var prefs Preferences
+	if err := jsonutil.DecodeFileAllowUnknownFields(filename, &prefs); err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+// ParseProfilePreferences parses "Preferences" in a Chrome profile,
+// with the extension settings in "Secure Preferences", when present,
+// overriding those in "Preferences". Files that fail to parse are
+// handled as by ParseProfilePreferencesOptions with the zero Options.
+func ParseProfilePreferences(profileDir string) (*Preferences, error) {
+	return ParseProfilePreferencesOptions(profileDir, browser.Options{})
+}
+
+// ParseProfilePreferencesOptions parses the preferences of a Chrome
+// profile and handles files that fail to parse by the error policy in
+// opts. When "Preferences" is skipped, the settings in "Secure
+// Preferences" are returned alone.
+func ParseProfilePreferencesOptions(profileDir string, opts browser.Options) (*Preferences, error) {
+	h := opts.Handler()
+	filename := filepath.Join(profileDir, "Preferences")
+	prefs, err := ParsePreferences(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	} else if err != nil {
+		if err := h.File(filename, err); err != nil {
+			return nil, err
+		}
+		prefs = &Preferences{}
+	}
+	filename = filepath.Join(profileDir, "Secure Preferences")
+	secure, err := ParsePreferences(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return prefs, h.Err()
+	} else if err != nil {
+		if err := h.File(filename, err); err != nil {
+			return nil, err
+		}
+		return prefs, h.Err()
+	}
+	if len(secure.Extensions.Settings) != 0 && prefs.Extensions.Settings == nil {
+		prefs.Extensions.Settings = make(map[string]ExtensionSettings, len(secure.Extensions.Settings))
+	}
+	for id, settings := range secure.Extensions.Settings {
+		prefs.Extensions.Settings[id] = settings
+	}
+	return prefs, h.Err()
+}
+
+// ContentSettingExceptions returns the content setting exceptions,
+// ordered by content type, then pattern pair.
+func (p *Preferences) ContentSettingExceptions() []ContentSettingRule {
+	var rules []ContentSettingRule
+	for typ, exceptions := range p.Profile.ContentSettings.Exceptions {
+		for patterns, e := range exceptions {
+			r := ContentSettingRule{Type: typ, Exception: e}
+			r.PrimaryPattern, r.SecondaryPattern = patterns, "*"
+			if i := strings.IndexByte(patterns, ','); i != -1 {
+				r.PrimaryPattern, r.SecondaryPattern = patterns[:i], patterns[i+1:]
+			}
+			var value int
+			if err := json.Unmarshal(e.Setting, &value); err == nil && value >= 0 && value <= 0xff {
+				r.Setting = ContentSettingValue(value)
+			} else {
+				r.IsWebsiteSetting = true
+			}
+			rules = append(rules, r)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Type != rules[j].Type {
+			return rules[i].Type < rules[j].Type
+		}
+		if rules[i].PrimaryPattern != rules[j].PrimaryPattern {
+			return rules[i].PrimaryPattern < rules[j].PrimaryPattern
+		}
+		return rules[i].SecondaryPattern < rules[j].SecondaryPattern
+	})
+	return rules
+}
+
+// StartupURLs returns the URLs opened on startup, when configured to
+// open a specific set of pages.
+func (p *Preferences) StartupURLs() []string {
+	if p.Session.RestoreOnStartup != RestoreURLs {
+		return nil
+	}
+	return p.Session.StartupURLs
+}
+
+func (r RestoreOnStartup) String() string {
+	switch r {
+	case RestoreDefault:
+		return "default"
+	case RestoreLast:
+		return "last"
+	case RestoreURLs:
+		return "urls"
+	case RestoreNewTab:
+		return "newtab"
+	default:
+		return fmt.Sprintf("restore_on_startup(%d)", uint8(r))
+	}
+}
+
+func (v ContentSettingValue) String() string {
+	switch v {
+	case ContentSettingDefault:
+		return "default"
+	case ContentSettingAllow:
+		return "allow"
+	case ContentSettingBlock:
+		return "block"
+	case ContentSettingAsk:
+		return "ask"
+	case ContentSettingSessionOnly:
+		return "session_only"
+	case ContentSettingDetectImportantContent:
+		return "detect_important_content"
+	default:
+		return fmt.Sprintf("content_setting(%d)", uint8(v))
+	}
+}
+
+func (l ExtensionLocation) String() string {
+	switch l {
+	case ExtensionLocationInvalid:
+		return "invalid"
+	case ExtensionLocationInternal:
+		return "internal"
+	case ExtensionLocationExternalPref:
+		return "external_pref"
+	case ExtensionLocationExternalRegistry:
+		return "external_registry"
+	case ExtensionLocationUnpacked:
+		return "unpacked"
+	case ExtensionLocationComponent:
+		return "component"
+	case ExtensionLocationExternalPrefDownload:
+		return "external_pref_download"
+	case ExtensionLocationExternalPolicyDownload:
+		return "external_policy_download"
+	case ExtensionLocationCommandLine:
+		return "command_line"
+	case ExtensionLocationExternalPolicy:
+		return "external_policy"
+	case ExtensionLocationExternalComponent:
+		return "external_component"
+	default:
+		return fmt.Sprintf("location(%d)", uint8(l))
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseProfilePreferences(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"Preferences": `{
+			"extensions": {"settings": {
+				"a": {"location": 1, "incognito": true},
+				"b": {"location": 4, "path": "/home/user/ext"}
+			}},
+			"profile": {"content_settings": {"exceptions": {
+				"popups": {"https://b.example:443,*": {"setting": 1}},
+				"cookies": {"[*.]a.example": {"setting": 2}, "https://a.example:443,https://b.example:443": {"setting": 1}},
+				"site_engagement": {"https://a.example:443,*": {"setting": {"rawScore": 1}}}
+			}}}
+		}`,
+		"Secure Preferences": `{
+			"extensions": {"settings": {"a": {"location": 1, "disable_reasons": 4097}}},
+			"protection": {"super_mac": "0123"}
+		}`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	prefs, err := ParseProfilePreferences(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantExts := map[string]ExtensionSettings{
+		"a": {Location: ExtensionLocationInternal, DisableReasons: ExtensionDisableReasons{1, 4096}},
+		"b": {Location: ExtensionLocationUnpacked, Path: "/home/user/ext"},
+	}
+	if !reflect.DeepEqual(prefs.Extensions.Settings, wantExts) {
+		t.Errorf("got extensions:\n%+v\nwant:\n%+v", prefs.Extensions.Settings, wantExts)
+	}
+
+	var got []string
+	for _, r := range prefs.ContentSettingExceptions() {
+		setting := r.Setting.String()
+		if r.IsWebsiteSetting {
+			setting = string(r.Exception.Setting)
+		}
+		got = append(got, r.Type+" "+r.PrimaryPattern+" "+r.SecondaryPattern+" "+setting)
+	}
+	want := []string{
+		"cookies [*.]a.example * block",
+		"cookies https://a.example:443 https://b.example:443 allow",
+		"popups https://b.example:443 * allow",
+		`site_engagement https://a.example:443 * {"rawScore": 1}`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got exceptions:\n%q\nwant:\n%q", got, want)
+	}
+}
@@ -0,0 +1,394 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+// PrefsSnapshot is the full contents of "Preferences", optionally
+// merged with "Secure Preferences", as decoded JSON values, so that
+// settings not modeled by Preferences can be compared.
+type PrefsSnapshot map[string]interface{}
+
+// ParsePrefsSnapshot parses "Preferences" or "Secure Preferences" in a
+// Chrome profile into a snapshot.
+func ParsePrefsSnapshot(filename string) (PrefsSnapshot, error) {
+	var prefs PrefsSnapshot
+	if err := jsonutil.DecodeFile(filename, &prefs); err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// ProfilePrefsSnapshot reads "Preferences" and "Secure Preferences" in
+// a Chrome profile and merges them. Extension settings and other
+// tamper-protected settings are stored in "Secure Preferences" on
+// Windows and macOS, which is missing on Linux. Files that fail to
+// parse are handled as by ProfilePrefsSnapshotOptions with the zero
+// Options.
+func ProfilePrefsSnapshot(profileDir string) (PrefsSnapshot, error) {
+	return ProfilePrefsSnapshotOptions(profileDir, browser.Options{})
+}
+
+// ProfilePrefsSnapshotOptions reads and merges the preferences of a
+// Chrome profile and handles files that fail to parse by the error
+// policy in opts.
+func ProfilePrefsSnapshotOptions(profileDir string, opts browser.Options) (PrefsSnapshot, error) {
+	h := opts.Handler()
+	filename := filepath.Join(profileDir, "Preferences")
+	prefs, err := ParsePrefsSnapshot(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	} else if err != nil {
+		if err := h.File(filename, err); err != nil {
+			return nil, err
+		}
+		prefs = make(PrefsSnapshot)
+	}
+	filename = filepath.Join(profileDir, "Secure Preferences")
+	secure, err := ParsePrefsSnapshot(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return prefs, h.Err()
+	} else if err != nil {
+		if err := h.File(filename, err); err != nil {
+			return nil, err
+		}
+		return prefs, h.Err()
+	}
+	mergePrefs(prefs, secure)
+	return prefs, h.Err()
+}
+
+// mergePrefs recursively merges src into dst, with values in src
+// taking precedence.
+func mergePrefs(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if sm, ok := v.(map[string]interface{}); ok {
+			if dm, ok := dst[k].(map[string]interface{}); ok {
+				mergePrefs(dm, sm)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+// Lookup returns the value of a preference by its dotted name, such as
+// "browser.show_home_button".
+func (p PrefsSnapshot) Lookup(name string) (interface{}, bool) {
+	var v interface{} = map[string]interface{}(p)
+	for _, key := range strings.Split(name, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// ChangeKind is the kind of a change between two snapshots.
+type ChangeKind uint8
+
+// Values for ChangeKind:
+const (
+	ChangeAdded ChangeKind = iota + 1
+	ChangeRemoved
+	ChangeModified
+)
+
+// PrefsDiff is the difference between two preferences snapshots.
+type PrefsDiff struct {
+	Settings        []SettingChange
+	ContentSettings []ContentSettingChange
+	Extensions      []ExtensionChange
+}
+
+// SettingChange is a change to a preference value. Old is nil when
+// added and New is nil when removed.
+type SettingChange struct {
+	Name     string // dotted preference name, e.g. "browser.show_home_button"
+	Kind     ChangeKind
+	Old, New interface{}
+}
+
+// ContentSettingChange is a change to a content setting exception for
+// a site, such as allowing notifications.
+type ContentSettingChange struct {
+	Type     string // content settings type, e.g. "notifications", "geolocation"
+	Pattern  string // primary and secondary patterns, e.g. "https://example.com:443,*"
+	Kind     ChangeKind
+	Old, New interface{} // value of "setting", e.g. 1 for allow or 2 for block
+}
+
+// ExtensionChange is an installed, uninstalled, or modified extension.
+// Old is nil when installed and New is nil when uninstalled.
+type ExtensionChange struct {
+	ID       string
+	Kind     ChangeKind
+	Old, New *ExtensionState
+}
+
+// ExtensionState is the state of an extension in
+// "extensions.settings".
+type ExtensionState struct {
+	Name           string
+	Version        string
+	State          int // 1 when enabled, 0 when disabled
+	DisableReasons int // bit set of disable_reason::DisableReason
+	Permissions    []string
+}
+
+const (
+	prefsContentSettings = "profile.content_settings.exceptions"
+	prefsExtensions      = "extensions.settings"
+)
+
+// diffExcluded are preference subtrees that are not reported as
+// setting changes, because they are reported separately or change on
+// every write.
+var diffExcluded = map[string]bool{
+	prefsContentSettings:   true,
+	prefsExtensions:        true,
+	"protection.macs":      true, // HMACs of protected settings
+	"protection.super_mac": true,
+}
+
+// DiffPrefs compares two preferences snapshots, such as from backups
+// taken at different times. Settings are ordered by name, content
+// settings by type and pattern, and extensions by ID.
+func DiffPrefs(old, new PrefsSnapshot) *PrefsDiff {
+	var d PrefsDiff
+	diffSettings(&d, "", old, new)
+	sort.Slice(d.Settings, func(i, j int) bool {
+		return d.Settings[i].Name < d.Settings[j].Name
+	})
+	diffContentSettings(&d, old, new)
+	diffExtensions(&d, old, new)
+	return &d
+}
+
+func diffSettings(d *PrefsDiff, prefix string, old, new map[string]interface{}) {
+	for k, ov := range old {
+		if nv, ok := new[k]; ok {
+			diffValue(d, prefix+k, ov, nv)
+		} else {
+			diffValue(d, prefix+k, ov, nil)
+		}
+	}
+	for k, nv := range new {
+		if _, ok := old[k]; !ok {
+			diffValue(d, prefix+k, nil, nv)
+		}
+	}
+}
+
+func diffValue(d *PrefsDiff, name string, old, new interface{}) {
+	if diffExcluded[name] {
+		return
+	}
+	om, oldIsMap := old.(map[string]interface{})
+	nm, newIsMap := new.(map[string]interface{})
+	if (oldIsMap || old == nil) && (newIsMap || new == nil) && (oldIsMap || newIsMap) {
+		diffSettings(d, name+".", om, nm)
+		return
+	}
+	switch {
+	case old == nil && new == nil:
+	case old == nil:
+		d.Settings = append(d.Settings, SettingChange{name, ChangeAdded, nil, new})
+	case new == nil:
+		d.Settings = append(d.Settings, SettingChange{name, ChangeRemoved, old, nil})
+	case !reflect.DeepEqual(old, new):
+		d.Settings = append(d.Settings, SettingChange{name, ChangeModified, old, new})
+	}
+}
+
+func diffContentSettings(d *PrefsDiff, old, new PrefsSnapshot) {
+	oldTypes := lookupMap(old, prefsContentSettings)
+	newTypes := lookupMap(new, prefsContentSettings)
+	for _, typ := range unionKeys(oldTypes, newTypes) {
+		oldExceptions, _ := oldTypes[typ].(map[string]interface{})
+		newExceptions, _ := newTypes[typ].(map[string]interface{})
+		for _, pattern := range unionKeys(oldExceptions, newExceptions) {
+			ov, oldOK := contentSetting(oldExceptions[pattern])
+			nv, newOK := contentSetting(newExceptions[pattern])
+			c := ContentSettingChange{Type: typ, Pattern: pattern, Old: ov, New: nv}
+			switch {
+			case !oldOK && !newOK:
+				continue
+			case !oldOK:
+				c.Kind = ChangeAdded
+			case !newOK:
+				c.Kind = ChangeRemoved
+			case !reflect.DeepEqual(ov, nv):
+				c.Kind = ChangeModified
+			default:
+				continue
+			}
+			d.ContentSettings = append(d.ContentSettings, c)
+		}
+	}
+}
+
+// contentSetting returns the setting of a content setting exception,
+// which is a dictionary with "setting" and "last_modified". Exceptions
+// without a setting, such as website metadata, are compared whole.
+func contentSetting(exception interface{}) (interface{}, bool) {
+	m, ok := exception.(map[string]interface{})
+	if !ok {
+		return exception, exception != nil
+	}
+	if setting, ok := m["setting"]; ok {
+		return setting, true
+	}
+	rest := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if k != "last_modified" {
+			rest[k] = v
+		}
+	}
+	return rest, true
+}
+
+func diffExtensions(d *PrefsDiff, old, new PrefsSnapshot) {
+	oldExts := lookupMap(old, prefsExtensions)
+	newExts := lookupMap(new, prefsExtensions)
+	for _, id := range unionKeys(oldExts, newExts) {
+		oe := extensionState(oldExts[id])
+		ne := extensionState(newExts[id])
+		c := ExtensionChange{ID: id, Old: oe, New: ne}
+		switch {
+		case oe == nil && ne == nil:
+			continue
+		case oe == nil:
+			c.Kind = ChangeAdded
+		case ne == nil:
+			c.Kind = ChangeRemoved
+		case !reflect.DeepEqual(oe, ne):
+			c.Kind = ChangeModified
+		default:
+			continue
+		}
+		d.Extensions = append(d.Extensions, c)
+	}
+}
+
+func extensionState(v interface{}) *ExtensionState {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	var e ExtensionState
+	if manifest, ok := m["manifest"].(map[string]interface{}); ok {
+		e.Name, _ = manifest["name"].(string)
+		e.Version, _ = manifest["version"].(string)
+	}
+	if state, ok := m["state"].(float64); ok {
+		e.State = int(state)
+	}
+	if reasons, ok := m["disable_reasons"].(float64); ok {
+		e.DisableReasons = int(reasons)
+	}
+	if granted, ok := m["granted_permissions"].(map[string]interface{}); ok {
+		for _, key := range []string{"api", "explicit_host", "scriptable_host"} {
+			perms, _ := granted[key].([]interface{})
+			for _, p := range perms {
+				if s, ok := p.(string); ok {
+					e.Permissions = append(e.Permissions, s)
+				}
+			}
+		}
+		sort.Strings(e.Permissions)
+	}
+	return &e
+}
+
+// AddedPermissions returns the permissions granted in New, but not in
+// Old.
+func (c *ExtensionChange) AddedPermissions() []string {
+	var old, new []string
+	if c.Old != nil {
+		old = c.Old.Permissions
+	}
+	if c.New != nil {
+		new = c.New.Permissions
+	}
+	return subtractStrings(new, old)
+}
+
+// RemovedPermissions returns the permissions granted in Old, but not
+// in New.
+func (c *ExtensionChange) RemovedPermissions() []string {
+	var old, new []string
+	if c.Old != nil {
+		old = c.Old.Permissions
+	}
+	if c.New != nil {
+		new = c.New.Permissions
+	}
+	return subtractStrings(old, new)
+}
+
+func subtractStrings(a, b []string) []string {
+	set := make(map[string]bool, len(b))
+	for _, s := range b {
+		set[s] = true
+	}
+	var diff []string
+	for _, s := range a {
+		if !set[s] {
+			diff = append(diff, s)
+		}
+	}
+	return diff
+}
+
+func lookupMap(p PrefsSnapshot, name string) map[string]interface{} {
+	v, _ := p.Lookup(name)
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	default:
+		return fmt.Sprintf("change_kind(%d)", uint8(k))
+	}
+}
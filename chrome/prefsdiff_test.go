@@ -0,0 +1,83 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const testPrefsOld = `{
+  "browser": {"show_home_button": false, "theme": {"color": 1}},
+  "extensions": {"settings": {
+    "aaaa": {"manifest": {"name": "Dark Mode", "version": "1.0"}, "state": 1,
+      "granted_permissions": {"api": ["storage"], "explicit_host": ["https://example.com/*"]}},
+    "bbbb": {"manifest": {"name": "Old", "version": "2.0"}, "state": 1}
+  }},
+  "profile": {"content_settings": {"exceptions": {
+    "notifications": {"https://example.com:443,*": {"last_modified": "13258080000000000", "setting": 1}}
+  }}},
+  "protection": {"macs": {"browser": {"show_home_button": "AAAA"}}}
+}`
+
+const testPrefsNew = `{
+  "browser": {"show_home_button": true, "theme": {}},
+  "extensions": {"settings": {
+    "aaaa": {"manifest": {"name": "Dark Mode", "version": "1.1"}, "state": 1,
+      "granted_permissions": {"api": ["storage", "tabs"], "explicit_host": []}},
+    "cccc": {"manifest": {"name": "New", "version": "1.0"}, "state": 0, "disable_reasons": 1}
+  }},
+  "profile": {"content_settings": {"exceptions": {
+    "notifications": {"https://example.com:443,*": {"last_modified": "13258166400000000", "setting": 1}},
+    "geolocation": {"https://maps.example.com:443,*": {"last_modified": "13258166400000000", "setting": 2}}
+  }}},
+  "protection": {"macs": {"browser": {"show_home_button": "BBBB"}}}
+}`
+
+func TestDiffPrefs(t *testing.T) {
+	var old, new PrefsSnapshot
+	if err := json.Unmarshal([]byte(testPrefsOld), &old); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(testPrefsNew), &new); err != nil {
+		t.Fatal(err)
+	}
+	d := DiffPrefs(old, new)
+
+	wantSettings := []SettingChange{
+		{"browser.show_home_button", ChangeModified, false, true},
+		{"browser.theme.color", ChangeRemoved, 1.0, nil},
+	}
+	if !reflect.DeepEqual(d.Settings, wantSettings) {
+		t.Errorf("settings:\ngot:  %v\nwant: %v", d.Settings, wantSettings)
+	}
+
+	wantContent := []ContentSettingChange{
+		{"geolocation", "https://maps.example.com:443,*", ChangeAdded, nil, 2.0},
+	}
+	if !reflect.DeepEqual(d.ContentSettings, wantContent) {
+		t.Errorf("content settings:\ngot:  %v\nwant: %v", d.ContentSettings, wantContent)
+	}
+
+	wantExtensions := []ExtensionChange{
+		{"aaaa", ChangeModified,
+			&ExtensionState{"Dark Mode", "1.0", 1, 0, []string{"https://example.com/*", "storage"}},
+			&ExtensionState{"Dark Mode", "1.1", 1, 0, []string{"storage", "tabs"}}},
+		{"bbbb", ChangeRemoved, &ExtensionState{"Old", "2.0", 1, 0, nil}, nil},
+		{"cccc", ChangeAdded, nil, &ExtensionState{"New", "1.0", 0, 1, nil}},
+	}
+	if !reflect.DeepEqual(d.Extensions, wantExtensions) {
+		t.Errorf("extensions:\ngot:  %v\nwant: %v", d.Extensions, wantExtensions)
+	}
+	if got := d.Extensions[0].AddedPermissions(); !reflect.DeepEqual(got, []string{"tabs"}) {
+		t.Errorf("added permissions: got: %v, want: [tabs]", got)
+	}
+	if got := d.Extensions[0].RemovedPermissions(); !reflect.DeepEqual(got, []string{"https://example.com/*"}) {
+		t.Errorf("removed permissions: got: %v, want: [https://example.com/*]", got)
+	}
+}
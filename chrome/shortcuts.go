@@ -0,0 +1,195 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Shortcuts schema:
+// https://source.chromium.org/chromium/chromium/src/+/master:components/omnibox/browser/shortcuts_database.cc
+// https://source.chromium.org/chromium/chromium/src/+/master:components/omnibox/browser/autocomplete_match_type.h
+//
+// When an omnibox suggestion is chosen, the text typed and the match are
+// stored in the omni_box_shortcuts table, so that the match is
+// suggested again for the same text.
+
+// Shortcut is an omnibox match chosen for typed text in the
+// "Shortcuts" database.
+type Shortcut struct {
+	ID               string // GUID
+	Text             string // text typed in the omnibox
+	FillIntoEdit     string // text shown in the omnibox for the match
+	URL              string
+	Contents         string
+	ContentsClass    string // styles of Contents, as "offset,style" pairs
+	Description      string
+	DescriptionClass string // styles of Description, as "offset,style" pairs
+	Transition       PageTransition
+	Type             MatchType
+	Keyword          string // keyword of the search engine, for searches
+	LastAccessTime   time.Time
+	NumberOfHits     int
+}
+
+// MatchType is the type of an omnibox match.
+type MatchType uint8
+
+// Values for MatchType:
+const (
+	MatchURLWhatYouTyped           MatchType = 0  // URL typed in full
+	MatchHistoryURL                MatchType = 1  // history item whose URL matches
+	MatchHistoryTitle              MatchType = 2  // history item whose title matches
+	MatchHistoryBody               MatchType = 3  // history item whose body matches
+	MatchHistoryKeyword            MatchType = 4  // history item whose keyword matches
+	MatchNavSuggest                MatchType = 5  // URL suggested by the search engine
+	MatchSearchWhatYouTyped        MatchType = 6  // search for the text typed
+	MatchSearchHistory             MatchType = 7  // past search
+	MatchSearchSuggest             MatchType = 8  // query suggested by the search engine
+	MatchSearchSuggestEntity       MatchType = 9  // entity suggested by the search engine
+	MatchSearchSuggestTail         MatchType = 10 // suggestion for the end of a query
+	MatchSearchSuggestPersonalized MatchType = 11 // past search suggested by the search engine
+	MatchSearchSuggestProfile      MatchType = 12 // contact suggested by the search engine
+	MatchSearchOtherEngine         MatchType = 13 // search with a non-default engine
+	MatchExtensionApp              MatchType = 14 // deprecated
+	MatchContact                   MatchType = 15 // deprecated
+	MatchBookmarkTitle             MatchType = 16 // bookmark whose title matches
+	MatchNavSuggestPersonalized    MatchType = 17 // past navigation suggested by the search engine
+	MatchCalculator                MatchType = 18 // calculator answer
+	MatchClipboardURL              MatchType = 19 // URL in the clipboard
+	MatchVoiceSuggest              MatchType = 20 // query from voice input
+	MatchPhysicalWeb               MatchType = 21 // deprecated
+	MatchPhysicalWebOverflow       MatchType = 22 // deprecated
+	MatchTabSearch                 MatchType = 23 // deprecated
+	MatchDocumentSuggestion        MatchType = 24 // document from a cloud drive
+	MatchPedal                     MatchType = 25 // browser action
+	MatchClipboardText             MatchType = 26 // text in the clipboard
+	MatchClipboardImage            MatchType = 27 // image in the clipboard
+)
+
+// ParseShortcuts parses the omnibox shortcuts in a "Shortcuts"
+// database, ordered by last access time, then ID. Databases written
+// before the transition, type, and keyword columns were added have
+// zero values for them.
+func ParseShortcuts(filename string) ([]Shortcut, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	cols, err := sqliteutil.Columns(db, "omni_box_shortcuts")
+	if err != nil {
+		return nil, fmt.Errorf("chrome: shortcuts: %w", err)
+	}
+	has := make(map[string]bool, len(cols))
+	for _, col := range cols {
+		has[col] = true
+	}
+	optional := func(col, zero string) string {
+		if has[col] {
+			return col
+		}
+		return zero
+	}
+	var shortcuts []Shortcut
+	err = sqliteutil.Query(db, `
+		SELECT id, text, fill_into_edit, url, contents, contents_class,
+			description, description_class, `+optional("transition", "0")+`,
+			`+optional("type", "0")+`, `+optional("keyword", "''")+`,
+			last_access_time, number_of_hits
+		FROM omni_box_shortcuts
+		ORDER BY last_access_time, id`, func(rows *sql.Rows) error {
+		var s Shortcut
+		var text, fill, contents, contentsClass, desc, descClass, keyword sql.NullString
+		var transition, accessed int64
+		if err := rows.Scan(&s.ID, &text, &fill, &s.URL, &contents, &contentsClass,
+			&desc, &descClass, &transition, &s.Type, &keyword, &accessed, &s.NumberOfHits); err != nil {
+			return err
+		}
+		s.Text, s.FillIntoEdit, s.Keyword = text.String, fill.String, keyword.String
+		s.Contents, s.ContentsClass = contents.String, contentsClass.String
+		s.Description, s.DescriptionClass = desc.String, descClass.String
+		s.Transition = PageTransition(uint32(transition))
+		var err error
+		if s.LastAccessTime, err = chromeTime(accessed); err != nil {
+			return err
+		}
+		shortcuts = append(shortcuts, s)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chrome: shortcuts: %w", err)
+	}
+	return shortcuts, nil
+}
+
+func (t MatchType) String() string {
+	switch t {
+	case MatchURLWhatYouTyped:
+		return "url-what-you-typed"
+	case MatchHistoryURL:
+		return "history-url"
+	case MatchHistoryTitle:
+		return "history-title"
+	case MatchHistoryBody:
+		return "history-body"
+	case MatchHistoryKeyword:
+		return "history-keyword"
+	case MatchNavSuggest:
+		return "navsuggest"
+	case MatchSearchWhatYouTyped:
+		return "search-what-you-typed"
+	case MatchSearchHistory:
+		return "search-history"
+	case MatchSearchSuggest:
+		return "search-suggest"
+	case MatchSearchSuggestEntity:
+		return "search-suggest-entity"
+	case MatchSearchSuggestTail:
+		return "search-suggest-tail"
+	case MatchSearchSuggestPersonalized:
+		return "search-suggest-personalized"
+	case MatchSearchSuggestProfile:
+		return "search-suggest-profile"
+	case MatchSearchOtherEngine:
+		return "search-other-engine"
+	case MatchExtensionApp:
+		return "extension-app"
+	case MatchContact:
+		return "contact"
+	case MatchBookmarkTitle:
+		return "bookmark-title"
+	case MatchNavSuggestPersonalized:
+		return "navsuggest-personalized"
+	case MatchCalculator:
+		return "search-calculator-answer"
+	case MatchClipboardURL:
+		return "clipboard-url"
+	case MatchVoiceSuggest:
+		return "voice-suggestion"
+	case MatchPhysicalWeb:
+		return "physical-web"
+	case MatchPhysicalWebOverflow:
+		return "physical-web-overflow"
+	case MatchTabSearch:
+		return "tab-search"
+	case MatchDocumentSuggestion:
+		return "document"
+	case MatchPedal:
+		return "pedal"
+	case MatchClipboardText:
+		return "clipboard-text"
+	case MatchClipboardImage:
+		return "clipboard-image"
+	default:
+		return fmt.Sprintf("match_type(%d)", uint8(t))
+	}
+}
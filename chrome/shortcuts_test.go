@@ -0,0 +1,80 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseShortcuts(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "Shortcuts")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// Transition 0x30000001 is a typed chain start and end.
+	for _, q := range []string{
+		`CREATE TABLE omni_box_shortcuts (id VARCHAR PRIMARY KEY, text VARCHAR, fill_into_edit VARCHAR,
+			url VARCHAR, contents VARCHAR, contents_class VARCHAR, description VARCHAR,
+			description_class VARCHAR, transition INTEGER, type INTEGER, keyword VARCHAR,
+			last_access_time INTEGER, number_of_hits INTEGER)`,
+		`INSERT INTO omni_box_shortcuts VALUES ('B5A1C2D3-0000-4000-8000-000000000002', 'gol',
+			'golang.org', 'https://golang.org/', 'golang.org', '0,1', 'The Go Programming Language',
+			'0,0', 805306369, 1, '', 13258087200000000, 3)`,
+		`INSERT INTO omni_box_shortcuts VALUES ('B5A1C2D3-0000-4000-8000-000000000001', 'wea',
+			'weather', 'https://www.google.com/search?q=weather', 'weather', '0,0',
+			'Google Search', '0,4', 5, 8, 'google.com', 13258083600000000, 1)`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	shortcuts, err := ParseShortcuts(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Shortcut{{
+		ID:               "B5A1C2D3-0000-4000-8000-000000000001",
+		Text:             "wea",
+		FillIntoEdit:     "weather",
+		URL:              "https://www.google.com/search?q=weather",
+		Contents:         "weather",
+		ContentsClass:    "0,0",
+		Description:      "Google Search",
+		DescriptionClass: "0,4",
+		Transition:       TransitionGenerated,
+		Type:             MatchSearchSuggest,
+		Keyword:          "google.com",
+		LastAccessTime:   time.Date(2021, 2, 18, 1, 0, 0, 0, time.UTC),
+		NumberOfHits:     1,
+	}, {
+		ID:               "B5A1C2D3-0000-4000-8000-000000000002",
+		Text:             "gol",
+		FillIntoEdit:     "golang.org",
+		URL:              "https://golang.org/",
+		Contents:         "golang.org",
+		ContentsClass:    "0,1",
+		Description:      "The Go Programming Language",
+		DescriptionClass: "0,0",
+		Transition:       0x30000001,
+		Type:             MatchHistoryURL,
+		LastAccessTime:   time.Date(2021, 2, 18, 2, 0, 0, 0, time.UTC),
+		NumberOfHits:     3,
+	}}
+	if !reflect.DeepEqual(shortcuts, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", shortcuts, want)
+	}
+	if s := want[0].Type.String(); s != "search-suggest" {
+		t.Errorf("got match type %q", s)
+	}
+}
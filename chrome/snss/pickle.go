@@ -0,0 +1,116 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package snss
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf16"
+)
+
+// Pickle format:
+// https://source.chromium.org/chromium/chromium/src/+/master:base/pickle.h
+//
+// A pickle starts with the 32-bit size of its payload. Values follow in
+// little endian, each padded to a multiple of 4 bytes. Bools are 32-bit
+// integers and strings are a 32-bit length, in bytes for UTF-8 and in
+// code units for UTF-16, followed by the data.
+
+// pickleReader reads values from a pickle. After an error, every method
+// returns zero and the error is kept.
+type pickleReader struct {
+	b   []byte
+	err error
+}
+
+func newPickleReader(b []byte) *pickleReader {
+	p := &pickleReader{b: b}
+	size := p.uint32()
+	if p.err == nil && uint64(size) > uint64(len(p.b)) {
+		p.err = fmt.Errorf("pickle: payload size %d exceeds data", size)
+	}
+	if p.err == nil {
+		p.b = p.b[:size]
+	}
+	return p
+}
+
+// next reads n bytes and skips the padding after them.
+func (p *pickleReader) next(n int) []byte {
+	if p.err != nil {
+		return nil
+	}
+	padded := (n + 3) &^ 3
+	if n < 0 || padded > len(p.b) {
+		p.err = errors.New("pickle: unexpected end of data")
+		return nil
+	}
+	b := p.b[:n]
+	p.b = p.b[padded:]
+	return b
+}
+
+func (p *pickleReader) uint32() uint32 {
+	if b := p.next(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (p *pickleReader) int32() int32 {
+	return int32(p.uint32())
+}
+
+func (p *pickleReader) int64() int64 {
+	return int64(p.uint64())
+}
+
+func (p *pickleReader) uint64() uint64 {
+	if b := p.next(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+// time reads a time in microseconds since the Windows epoch.
+func (p *pickleReader) time() time.Time {
+	t, err := chromeTime(p.int64())
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	return t
+}
+
+func (p *pickleReader) bool() bool {
+	return p.uint32() != 0
+}
+
+func (p *pickleReader) string() string {
+	n := int(int32(p.uint32()))
+	return string(p.next(n))
+}
+
+func (p *pickleReader) string16() string {
+	n := int(int32(p.uint32()))
+	b := p.next(2 * n)
+	if b == nil {
+		return ""
+	}
+	s := make([]uint16, n)
+	for i := range s {
+		s[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(s))
+}
+
+// more reports whether data remains, for fields added in later
+// versions.
+func (p *pickleReader) more() bool {
+	return p.err == nil && len(p.b) != 0
+}
@@ -0,0 +1,635 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package snss
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+)
+
+// Session commands:
+// https://source.chromium.org/chromium/chromium/src/+/master:components/sessions/core/session_service_commands.cc
+// https://source.chromium.org/chromium/chromium/src/+/master:components/sessions/core/serialized_navigation_entry.cc
+//
+// Windows and tabs are identified by 32-bit session IDs. Times are in
+// microseconds since 1601.
+const (
+	commandSetTabWindow                     = 0
+	commandSetTabIndexInWindow              = 2
+	commandTabNavigationPathPrunedFromBack  = 5
+	commandUpdateTabNavigation              = 6
+	commandSetSelectedNavigationIndex       = 7
+	commandSetSelectedTabInIndex            = 8
+	commandSetWindowType                    = 9
+	commandTabNavigationPathPrunedFromFront = 11
+	commandSetPinnedState                   = 12
+	commandSetExtensionAppID                = 13
+	commandSetWindowBounds3                 = 14
+	commandSetWindowAppName                 = 15
+	commandTabClosed                        = 16
+	commandWindowClosed                     = 17
+	commandSetTabUserAgentOverride          = 18
+	commandSessionStorageAssociated         = 19
+	commandSetActiveWindow                  = 20
+	commandLastActiveTime                   = 21
+	commandSetWindowWorkspace2              = 23
+	commandTabNavigationPathPruned          = 24
+	commandSetTabGroup                      = 25
+	commandSetTabGroupMetadata2             = 27
+	commandSetTabGUID                       = 28
+	commandSetTabUserAgentOverride2         = 29
+	commandSetTabData                       = 30
+	commandSetWindowUserTitle               = 31
+	commandSetWindowVisibleOnAllWorkspaces  = 32
+	commandAddTabExtraData                  = 33
+	commandAddWindowExtraData               = 34
+)
+
+// Session is a session rebuilt from the commands in a session file.
+type Session struct {
+	Windows      []Window                  // ordered by ID, which is the order they were opened
+	ActiveWindow int32                     // ID of the active window, or 0
+	TabGroups    []chrome.TabGroupMetadata // groups of the tabs, ordered by ID
+	Unknown      []Command                 // commands of unknown types, in order
+}
+
+// Window is a browser window in a session.
+type Window struct {
+	ID                     int32
+	Type                   WindowType
+	Bounds                 Bounds
+	ShowState              ShowState
+	Workspace              string
+	VisibleOnAllWorkspaces bool
+	AppName                string // for app windows
+	UserTitle              string // title set by the user
+	SelectedTab            int    // index in Tabs of the selected tab
+	Tabs                   []Tab  // ordered by visual index
+	ExtraData              map[string]string
+}
+
+// Bounds is the position and size of a window.
+type Bounds struct {
+	X, Y, Width, Height int32
+}
+
+// Tab is a tab in a window.
+type Tab struct {
+	ID                int32
+	Index             int // visual index in the window
+	Pinned            bool
+	GroupID           string // token of the tab group, in hexadecimal, when grouped
+	ExtensionAppID    string
+	UserAgentOverride string
+	GUID              string
+	LastActive        time.Time
+	Data              map[string]string
+	ExtraData         map[string]string
+	Selected          int          // index in Navigations of the current entry
+	Navigations       []Navigation // ordered by index
+}
+
+// Navigation is an entry in the back/forward history of a tab.
+type Navigation struct {
+	Index               int
+	URL                 string // virtual URL, as displayed
+	Title               string
+	PageState           []byte // serialized Blink page state
+	Transition          chrome.PageTransition
+	HasPostData         bool
+	ReferrerURL         string
+	OriginalRequestURL  string
+	OverridingUserAgent bool
+	Timestamp           time.Time
+	HTTPStatusCode      int
+	ReferrerPolicy      int
+	ExtendedInfo        map[string]string
+	TaskID              int64
+	ParentTaskID        int64
+	RootTaskID          int64
+}
+
+// WindowType is the type of a window.
+type WindowType uint8
+
+// Values for WindowType:
+const (
+	WindowNormal   WindowType = 0
+	WindowPopup    WindowType = 1
+	WindowApp      WindowType = 2
+	WindowDevTools WindowType = 3
+	WindowAppPopup WindowType = 4
+)
+
+// ShowState is the state of a window.
+type ShowState uint8
+
+// Values for ShowState:
+const (
+	ShowStateDefault    ShowState = 0
+	ShowStateNormal     ShowState = 1
+	ShowStateMinimized  ShowState = 2
+	ShowStateMaximized  ShowState = 3
+	ShowStateFullscreen ShowState = 5
+)
+
+// ParseSession reads a session file, such as "Current Session" or
+// "Sessions/Session_{time}", and rebuilds the session by replaying its
+// commands, as Chrome does when restoring it. Closed windows and tabs
+// are dropped, as are tabs without navigations and windows without
+// tabs.
+func ParseSession(filename string) (*Session, error) {
+	commands, err := readCommandsFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return RestoreSession(commands)
+}
+
+// sessionTab is a tab while its session is rebuilt.
+type sessionTab struct {
+	Tab
+	window  int32
+	current int // index of the current navigation
+}
+
+// RestoreSession rebuilds a session from its commands.
+func RestoreSession(commands []Command) (*Session, error) {
+	var s Session
+	windows := make(map[int32]*Window)
+	tabs := make(map[int32]*sessionTab)
+	groups := make(map[string]*chrome.TabGroupMetadata)
+	window := func(id int32) *Window {
+		w, ok := windows[id]
+		if !ok {
+			w = &Window{ID: id}
+			windows[id] = w
+		}
+		return w
+	}
+	tab := func(id int32) *sessionTab {
+		t, ok := tabs[id]
+		if !ok {
+			t = &sessionTab{Tab: Tab{ID: id}}
+			tabs[id] = t
+		}
+		return t
+	}
+
+	for i := range commands {
+		c := &commands[i]
+		b := c.Payload
+		switch c.ID {
+		case commandSetTabWindow:
+			if err := checkSize(c, 8); err != nil {
+				return nil, err
+			}
+			window(getInt32(b, 0))
+			tab(getInt32(b, 4)).window = getInt32(b, 0)
+		case commandSetTabIndexInWindow:
+			if err := checkSize(c, 8); err != nil {
+				return nil, err
+			}
+			tab(getInt32(b, 0)).Index = int(getInt32(b, 4))
+		case commandTabNavigationPathPrunedFromBack:
+			if err := checkSize(c, 8); err != nil {
+				return nil, err
+			}
+			t, keep := tab(getInt32(b, 0)), int(getInt32(b, 4))
+			navs := t.Navigations[:0]
+			for _, n := range t.Navigations {
+				if n.Index < keep {
+					navs = append(navs, n)
+				}
+			}
+			t.Navigations = navs
+		case commandTabNavigationPathPrunedFromFront:
+			if err := checkSize(c, 8); err != nil {
+				return nil, err
+			}
+			t, count := tab(getInt32(b, 0)), int(getInt32(b, 4))
+			t.prune(0, count)
+		case commandTabNavigationPathPruned:
+			if err := checkSize(c, 12); err != nil {
+				return nil, err
+			}
+			t := tab(getInt32(b, 0))
+			t.prune(int(getInt32(b, 4)), int(getInt32(b, 8)))
+		case commandUpdateTabNavigation:
+			p := newPickleReader(b)
+			id := p.int32()
+			n := readNavigation(p)
+			if p.err != nil {
+				return nil, commandError(c, p.err)
+			}
+			tab(id).updateNavigation(n)
+		case commandSetSelectedNavigationIndex:
+			if err := checkSize(c, 8); err != nil {
+				return nil, err
+			}
+			tab(getInt32(b, 0)).current = int(getInt32(b, 4))
+		case commandSetSelectedTabInIndex:
+			if err := checkSize(c, 8); err != nil {
+				return nil, err
+			}
+			window(getInt32(b, 0)).SelectedTab = int(getInt32(b, 4))
+		case commandSetWindowType:
+			if err := checkSize(c, 8); err != nil {
+				return nil, err
+			}
+			window(getInt32(b, 0)).Type = WindowType(getInt32(b, 4))
+		case commandSetPinnedState:
+			if err := checkSize(c, 5); err != nil {
+				return nil, err
+			}
+			tab(getInt32(b, 0)).Pinned = b[4] != 0
+		case commandSetWindowBounds3:
+			if err := checkSize(c, 24); err != nil {
+				return nil, err
+			}
+			w := window(getInt32(b, 0))
+			w.Bounds = Bounds{getInt32(b, 4), getInt32(b, 8), getInt32(b, 12), getInt32(b, 16)}
+			w.ShowState = ShowState(getInt32(b, 20))
+		case commandTabClosed, commandWindowClosed:
+			// The payload is the ID and the close time, aligned to 8
+			// bytes.
+			if err := checkSize(c, 16); err != nil {
+				return nil, err
+			}
+			if c.ID == commandTabClosed {
+				delete(tabs, getInt32(b, 0))
+			} else {
+				delete(windows, getInt32(b, 0))
+			}
+		case commandSetActiveWindow:
+			if err := checkSize(c, 4); err != nil {
+				return nil, err
+			}
+			s.ActiveWindow = getInt32(b, 0)
+		case commandLastActiveTime:
+			if err := checkSize(c, 16); err != nil {
+				return nil, err
+			}
+			t, err := getTime(b, 8)
+			if err != nil {
+				return nil, commandError(c, err)
+			}
+			tab(getInt32(b, 0)).LastActive = t
+		case commandSetWindowVisibleOnAllWorkspaces:
+			if err := checkSize(c, 5); err != nil {
+				return nil, err
+			}
+			window(getInt32(b, 0)).VisibleOnAllWorkspaces = b[4] != 0
+		case commandSetTabGroup:
+			id, group, err := parseSetTabGroupCommand(b)
+			if err != nil {
+				return nil, err
+			}
+			tab(id).GroupID = group
+		case commandSetTabGroupMetadata2:
+			m, err := parseTabGroupMetadataCommand(b)
+			if err != nil {
+				return nil, err
+			}
+			groups[m.ID] = m
+		case commandSetExtensionAppID, commandSetWindowAppName, commandSetTabUserAgentOverride,
+			commandSetTabUserAgentOverride2, commandSetWindowWorkspace2, commandSetTabGUID,
+			commandSetWindowUserTitle, commandSessionStorageAssociated:
+			p := newPickleReader(b)
+			id, str := p.int32(), p.string()
+			if p.err != nil {
+				return nil, commandError(c, p.err)
+			}
+			switch c.ID {
+			case commandSetExtensionAppID:
+				tab(id).ExtensionAppID = str
+			case commandSetWindowAppName:
+				window(id).AppName = str
+			case commandSetTabUserAgentOverride, commandSetTabUserAgentOverride2:
+				tab(id).UserAgentOverride = str
+			case commandSetWindowWorkspace2:
+				window(id).Workspace = str
+			case commandSetTabGUID:
+				tab(id).GUID = str
+			case commandSetWindowUserTitle:
+				window(id).UserTitle = str
+			}
+		case commandSetTabData:
+			p := newPickleReader(b)
+			t := tab(p.int32())
+			t.Data = readStringMap(p)
+			if p.err != nil {
+				return nil, commandError(c, p.err)
+			}
+		case commandAddTabExtraData, commandAddWindowExtraData:
+			p := newPickleReader(b)
+			id, key, value := p.int32(), p.string(), p.string()
+			if p.err != nil {
+				return nil, commandError(c, p.err)
+			}
+			if c.ID == commandAddTabExtraData {
+				t := tab(id)
+				t.ExtraData = setMap(t.ExtraData, key, value)
+			} else {
+				w := window(id)
+				w.ExtraData = setMap(w.ExtraData, key, value)
+			}
+		default:
+			s.Unknown = append(s.Unknown, *c)
+		}
+	}
+
+	for _, t := range tabs {
+		w, ok := windows[t.window]
+		if !ok || len(t.Navigations) == 0 {
+			continue
+		}
+		t.finish()
+		w.Tabs = append(w.Tabs, t.Tab)
+	}
+	ids := make([]int32, 0, len(windows))
+	for id, w := range windows {
+		if len(w.Tabs) != 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	used := make(map[string]bool)
+	for _, id := range ids {
+		w := windows[id]
+		sort.Slice(w.Tabs, func(i, j int) bool {
+			if w.Tabs[i].Index != w.Tabs[j].Index {
+				return w.Tabs[i].Index < w.Tabs[j].Index
+			}
+			return w.Tabs[i].ID < w.Tabs[j].ID
+		})
+		w.SelectedTab = clamp(w.SelectedTab, len(w.Tabs))
+		for _, t := range w.Tabs {
+			if t.GroupID != "" && !used[t.GroupID] {
+				used[t.GroupID] = true
+				m, ok := groups[t.GroupID]
+				if !ok {
+					m = &chrome.TabGroupMetadata{ID: t.GroupID}
+				}
+				s.TabGroups = append(s.TabGroups, *m)
+			}
+		}
+		s.Windows = append(s.Windows, *w)
+	}
+	sort.Slice(s.TabGroups, func(i, j int) bool {
+		return s.TabGroups[i].ID < s.TabGroups[j].ID
+	})
+	return &s, nil
+}
+
+// prune removes count navigations from index and shifts the later ones
+// down, as when entries are removed from the middle or, with index 0,
+// the front of the history.
+func (t *sessionTab) prune(index, count int) {
+	navs := t.Navigations[:0]
+	for _, n := range t.Navigations {
+		switch {
+		case n.Index < index:
+			navs = append(navs, n)
+		case n.Index >= index+count:
+			n.Index -= count
+			navs = append(navs, n)
+		}
+	}
+	t.Navigations = navs
+	switch {
+	case t.current >= index+count:
+		t.current -= count
+	case t.current >= index:
+		t.current = index - 1
+		if t.current < 0 {
+			t.current = 0
+		}
+	}
+}
+
+// updateNavigation replaces the navigation with the same index or adds
+// it.
+func (t *sessionTab) updateNavigation(n Navigation) {
+	for i := range t.Navigations {
+		if t.Navigations[i].Index == n.Index {
+			t.Navigations[i] = n
+			return
+		}
+	}
+	t.Navigations = append(t.Navigations, n)
+}
+
+// finish orders the navigations and selects the current one.
+func (t *sessionTab) finish() {
+	sort.Slice(t.Navigations, func(i, j int) bool {
+		return t.Navigations[i].Index < t.Navigations[j].Index
+	})
+	t.Selected = clamp(t.current, len(t.Navigations))
+	for i, n := range t.Navigations {
+		if n.Index == t.current {
+			t.Selected = i
+			break
+		}
+	}
+}
+
+// readNavigation reads a navigation entry from a pickle. Fields after
+// the transition were added in later versions and are read when
+// present.
+func readNavigation(p *pickleReader) Navigation {
+	n := Navigation{
+		Index:     int(p.int32()),
+		URL:       p.string(),
+		Title:     p.string16(),
+		PageState: []byte(p.string()),
+	}
+	n.Transition = chrome.PageTransition(p.uint32())
+	n.HasPostData = p.int32()&1 != 0
+	if len(n.PageState) == 0 {
+		n.PageState = nil
+	}
+	if !p.more() {
+		return n
+	}
+	n.ReferrerURL = p.string()
+	p.int32() // obsolete referrer policy
+	if p.more() {
+		n.OriginalRequestURL = p.string()
+		n.OverridingUserAgent = p.bool()
+	}
+	if p.more() {
+		n.Timestamp = p.time()
+	}
+	if p.more() {
+		p.string16() // obsolete search terms
+	}
+	if p.more() {
+		n.HTTPStatusCode = int(p.int32())
+	}
+	if p.more() {
+		n.ReferrerPolicy = int(p.int32())
+	}
+	if p.more() {
+		n.ExtendedInfo = readStringMap(p)
+	}
+	if p.more() {
+		n.TaskID, n.ParentTaskID, n.RootTaskID = p.int64(), p.int64(), p.int64()
+	}
+	return n
+}
+
+// readStringMap reads a count and the keys and values of a map of
+// strings, or nil when it is empty.
+func readStringMap(p *pickleReader) map[string]string {
+	n := int(p.int32())
+	if n < 0 || n > len(p.b)/8 {
+		if p.err == nil {
+			p.err = fmt.Errorf("pickle: invalid map size %d", n)
+		}
+		return nil
+	}
+	var m map[string]string
+	for i := 0; i < n; i++ {
+		key, value := p.string(), p.string()
+		m = setMap(m, key, value)
+	}
+	return m
+}
+
+func setMap(m map[string]string, key, value string) map[string]string {
+	if m == nil {
+		m = make(map[string]string)
+	}
+	m[key] = value
+	return m
+}
+
+// parseSetTabGroupCommand parses the payload of a command that assigns
+// a tab to a group, or removes it from its group. The payload is a
+// struct of the 32-bit tab ID and the 128-bit group token, each
+// aligned to 8 bytes, and whether the tab has a group.
+func parseSetTabGroupCommand(b []byte) (tabID int32, groupID string, err error) {
+	if len(b) < 25 {
+		return 0, "", fmt.Errorf("snss: set tab group command has length %d", len(b))
+	}
+	tabID = getInt32(b, 0)
+	if b[24] != 0 {
+		groupID = formatTabGroupToken(binary.LittleEndian.Uint64(b[8:]), binary.LittleEndian.Uint64(b[16:]))
+	}
+	return tabID, groupID, nil
+}
+
+// parseTabGroupMetadataCommand parses the pickled payload of a command
+// with the metadata of a tab group. The collapsed state and the saved
+// group GUID were added later and are read when present.
+func parseTabGroupMetadataCommand(b []byte) (*chrome.TabGroupMetadata, error) {
+	p := newPickleReader(b)
+	high, low := p.uint64(), p.uint64()
+	m := &chrome.TabGroupMetadata{
+		ID:    formatTabGroupToken(high, low),
+		Title: p.string16(),
+		Color: chrome.TabGroupColor(p.uint32()),
+	}
+	if p.more() {
+		m.Collapsed = p.bool()
+	}
+	if p.more() && p.bool() {
+		m.SavedGUID = p.string()
+	}
+	if p.err != nil {
+		return nil, fmt.Errorf("snss: tab group metadata command: %w", p.err)
+	}
+	return m, nil
+}
+
+// formatTabGroupToken formats a tab group token in hexadecimal.
+func formatTabGroupToken(high, low uint64) string {
+	return fmt.Sprintf("%016x%016x", high, low)
+}
+
+// checkSize checks that a command has a struct payload of at least n
+// bytes. Later versions may extend structs.
+func checkSize(c *Command, n int) error {
+	if len(c.Payload) < n {
+		return fmt.Errorf("snss: command %d has length %d, want %d", c.ID, len(c.Payload), n)
+	}
+	return nil
+}
+
+func commandError(c *Command, err error) error {
+	return fmt.Errorf("snss: command %d: %w", c.ID, err)
+}
+
+func getInt32(b []byte, offset int) int32 {
+	return int32(binary.LittleEndian.Uint32(b[offset:]))
+}
+
+// getTime reads a time in microseconds since the Windows epoch.
+func getTime(b []byte, offset int) (time.Time, error) {
+	return chromeTime(int64(binary.LittleEndian.Uint64(b[offset:])))
+}
+
+// chromeTime converts microseconds since the Windows epoch, as Chrome
+// stores times, and rejects negative times from corrupt files.
+func chromeTime(usec int64) (time.Time, error) {
+	if usec < 0 {
+		return time.Time{}, fmt.Errorf("negative time: %d", usec)
+	}
+	return timefmt.FromInt(usec, 0, timefmt.Micro, timefmt.Windows), nil
+}
+
+// clamp clamps an index into a list of length n, or returns 0 when it
+// is empty.
+func clamp(i, n int) int {
+	if i >= n {
+		i = n - 1
+	}
+	if i < 0 {
+		i = 0
+	}
+	return i
+}
+
+func (t WindowType) String() string {
+	switch t {
+	case WindowNormal:
+		return "normal"
+	case WindowPopup:
+		return "popup"
+	case WindowApp:
+		return "app"
+	case WindowDevTools:
+		return "devtools"
+	case WindowAppPopup:
+		return "app_popup"
+	default:
+		return fmt.Sprintf("window_type(%d)", uint8(t))
+	}
+}
+
+func (s ShowState) String() string {
+	switch s {
+	case ShowStateDefault:
+		return "default"
+	case ShowStateNormal:
+		return "normal"
+	case ShowStateMinimized:
+		return "minimized"
+	case ShowStateMaximized:
+		return "maximized"
+	case ShowStateFullscreen:
+		return "fullscreen"
+	default:
+		return fmt.Sprintf("show_state(%d)", uint8(s))
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package snss
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+)
+
+// pickleWriter writes values in the pickle format.
+type pickleWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *pickleWriter) int32(v int32) { binary.Write(&w.buf, binary.LittleEndian, v) }
+func (w *pickleWriter) int64(v int64) { binary.Write(&w.buf, binary.LittleEndian, v) }
+
+func (w *pickleWriter) string(s string) {
+	w.int32(int32(len(s)))
+	w.buf.WriteString(s)
+	w.pad()
+}
+
+func (w *pickleWriter) string16(s string) {
+	u := utf16.Encode([]rune(s))
+	w.int32(int32(len(u)))
+	binary.Write(&w.buf, binary.LittleEndian, u)
+	w.pad()
+}
+
+func (w *pickleWriter) pad() {
+	for w.buf.Len()%4 != 0 {
+		w.buf.WriteByte(0)
+	}
+}
+
+// bytes returns the pickle with its size header.
+func (w *pickleWriter) bytes() []byte {
+	b := make([]byte, 4, 4+w.buf.Len())
+	binary.LittleEndian.PutUint32(b, uint32(w.buf.Len()))
+	return append(b, w.buf.Bytes()...)
+}
+
+// structPayload encodes values as a little-endian struct. Padding must
+// be given explicitly.
+func structPayload(values ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	return buf.Bytes()
+}
+
+// navigationPayload encodes the pickled payload of a navigation
+// command, with the fields up to the timestamp.
+func navigationPayload(tabID int32, index int32, url, title string, timestamp int64) []byte {
+	var w pickleWriter
+	w.int32(tabID)
+	w.int32(index)
+	w.string(url)
+	w.string16(title)
+	w.string("") // page state
+	w.int32(int32(chrome.TransitionTyped))
+	w.int32(0) // type mask
+	w.string("https://referrer.example/")
+	w.int32(0) // obsolete referrer policy
+	w.string(url)
+	w.int32(0) // overriding user agent
+	w.int64(timestamp)
+	return w.bytes()
+}
+
+// writeSNSS writes commands to an SNSS file.
+func writeSNSS(t *testing.T, filename string, commands []Command) {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString(Signature)
+	binary.Write(&buf, binary.LittleEndian, int32(VersionWithMarker))
+	for _, c := range commands {
+		binary.Write(&buf, binary.LittleEndian, uint16(len(c.Payload)+1))
+		buf.WriteByte(c.ID)
+		buf.Write(c.Payload)
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseSession(t *testing.T) {
+	const t1, t2, t3 = 13255000000000000, 13255000001000000, 13255000002000000
+	var title pickleWriter
+	title.int32(1)
+	title.string("Research")
+	setGroup := structPayload(int32(11), int32(0), uint64(0x0123456789abcdef), uint64(0xfedcba9876543210), true, [7]byte{})
+	var group pickleWriter
+	group.int64(0x0123456789abcdef)
+	group.int64(-0x123456789abcdf0) // 0xfedcba9876543210
+	group.string16("Reading")
+	group.int32(int32(chrome.TabGroupBlue))
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, SessionsDir, "Session_13255000000000000")
+	writeSNSS(t, filename, []Command{
+		{commandSetTabWindow, structPayload(int32(1), int32(10))},
+		{commandSetTabIndexInWindow, structPayload(int32(10), int32(1))},
+		{commandUpdateTabNavigation, navigationPayload(10, 0, "https://example.com/", "Example", t1)},
+		{commandUpdateTabNavigation, navigationPayload(10, 1, "https://example.com/a", "A", t2)},
+		{commandUpdateTabNavigation, navigationPayload(10, 2, "https://example.com/b", "B", t3)},
+		{commandSetSelectedNavigationIndex, structPayload(int32(10), int32(1))},
+		{commandTabNavigationPathPrunedFromBack, structPayload(int32(10), int32(2))},
+		{commandSetTabWindow, structPayload(int32(1), int32(11))},
+		{commandSetTabIndexInWindow, structPayload(int32(11), int32(0))},
+		{commandSetPinnedState, structPayload(int32(11), true)},
+		{commandUpdateTabNavigation, navigationPayload(11, 0, "https://example.org/", "Org", t1)},
+		{commandSetTabGroup, setGroup},
+		{commandSetTabGroupMetadata2, group.bytes()},
+		{commandLastActiveTime, structPayload(int32(11), int32(0), int64(t3))},
+		{commandSetTabWindow, structPayload(int32(1), int32(12))},
+		{commandUpdateTabNavigation, navigationPayload(12, 0, "https://closed.example/", "Closed", t1)},
+		{commandTabClosed, structPayload(int32(12), int32(0), int64(t2))},
+		{commandSetTabWindow, structPayload(int32(2), int32(20))},
+		{commandUpdateTabNavigation, navigationPayload(20, 0, "https://closed.example/window", "Closed", t1)},
+		{commandWindowClosed, structPayload(int32(2), int32(0), int64(t2))},
+		{commandSetWindowBounds3, structPayload(int32(1), int32(10), int32(20), int32(800), int32(600), int32(ShowStateMaximized))},
+		{commandSetSelectedTabInIndex, structPayload(int32(1), int32(1))},
+		{commandSetWindowUserTitle, title.bytes()},
+		{commandSetActiveWindow, structPayload(int32(1))},
+		{99, []byte{1, 2, 3}},
+	})
+	// A command truncated by a crash ends the file.
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{20, 0, commandSetActiveWindow})
+	f.Close()
+
+	s, err := ParseSession(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nav := func(index int, url, title string, timestamp int64) Navigation {
+		return Navigation{
+			Index:              index,
+			URL:                url,
+			Title:              title,
+			Transition:         chrome.TransitionTyped,
+			ReferrerURL:        "https://referrer.example/",
+			OriginalRequestURL: url,
+			Timestamp:          timefmt.FromInt(timestamp, 0, timefmt.Micro, timefmt.Windows),
+		}
+	}
+	want := &Session{
+		Windows: []Window{{
+			ID:          1,
+			Bounds:      Bounds{10, 20, 800, 600},
+			ShowState:   ShowStateMaximized,
+			UserTitle:   "Research",
+			SelectedTab: 1,
+			Tabs: []Tab{{
+				ID:          11,
+				Pinned:      true,
+				GroupID:     "0123456789abcdeffedcba9876543210",
+				LastActive:  timefmt.FromInt(t3, 0, timefmt.Micro, timefmt.Windows),
+				Navigations: []Navigation{nav(0, "https://example.org/", "Org", t1)},
+			}, {
+				ID:       10,
+				Index:    1,
+				Selected: 1,
+				Navigations: []Navigation{
+					nav(0, "https://example.com/", "Example", t1),
+					nav(1, "https://example.com/a", "A", t2),
+				},
+			}},
+		}},
+		ActiveWindow: 1,
+		TabGroups: []chrome.TabGroupMetadata{{
+			ID:    "0123456789abcdeffedcba9876543210",
+			Title: "Reading",
+			Color: chrome.TabGroupBlue,
+		}},
+		Unknown: []Command{{99, []byte{1, 2, 3}}},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", s, want)
+	}
+
+	files, err := Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filename}; !reflect.DeepEqual(files, want) {
+		t.Errorf("got files %q, want %q", files, want)
+	}
+}
+
+func TestTabNavigationPathPruned(t *testing.T) {
+	tab := &sessionTab{current: 3}
+	for i := 0; i < 5; i++ {
+		tab.updateNavigation(Navigation{Index: i, URL: string(rune('a' + i))})
+	}
+	tab.prune(1, 2)
+	var urls []string
+	for _, n := range tab.Navigations {
+		urls = append(urls, n.URL)
+	}
+	if want := []string{"a", "d", "e"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("got %q, want %q", urls, want)
+	}
+	tab.finish()
+	if tab.Selected != 1 || tab.Navigations[1].Index != 1 {
+		t.Errorf("got selected %d in %+v", tab.Selected, tab.Navigations)
+	}
+}
+
+func TestTabGroupCommands(t *testing.T) {
+	setGroup := make([]byte, 32)
+	binary.LittleEndian.PutUint32(setGroup, 42)
+	binary.LittleEndian.PutUint64(setGroup[8:], 0x0123456789abcdef)
+	binary.LittleEndian.PutUint64(setGroup[16:], 0xfedcba9876543210)
+	setGroup[24] = 1
+	tabID, groupID, err := parseSetTabGroupCommand(setGroup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tabID != 42 || groupID != "0123456789abcdeffedcba9876543210" {
+		t.Errorf("got tab %d in group %q", tabID, groupID)
+	}
+	setGroup[24] = 0
+	if _, groupID, _ := parseSetTabGroupCommand(setGroup); groupID != "" {
+		t.Errorf("got group %q for ungrouped tab", groupID)
+	}
+
+	var p bytes.Buffer
+	write := func(v interface{}) { binary.Write(&p, binary.LittleEndian, v) }
+	write(uint64(0x0123456789abcdef))
+	write(uint64(0xfedcba9876543210))
+	title := utf16.Encode([]rune("Café"))
+	write(uint32(len(title)))
+	write(title)
+	write(uint32(chrome.TabGroupCyan))
+	write(uint32(1)) // collapsed
+	write(uint32(1)) // has saved GUID
+	write(uint32(36))
+	p.WriteString("0b8d9a3e-1c5e-4f0a-9d2b-6f1e2d3c4b5a")
+	pickle := make([]byte, 4, 4+p.Len())
+	binary.LittleEndian.PutUint32(pickle, uint32(p.Len()))
+	pickle = append(pickle, p.Bytes()...)
+
+	m, err := parseTabGroupMetadataCommand(pickle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &chrome.TabGroupMetadata{
+		ID:        "0123456789abcdeffedcba9876543210",
+		Title:     "Café",
+		Color:     chrome.TabGroupCyan,
+		Collapsed: true,
+		SavedGUID: "0b8d9a3e-1c5e-4f0a-9d2b-6f1e2d3c4b5a",
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", m, want)
+	}
+
+	// Older versions end after the color.
+	old := make([]byte, 4, 4+16+4+8+4)
+	binary.LittleEndian.PutUint32(old, 16+4+8+4)
+	old = append(old, pickle[4:4+16+4+8+4]...)
+	m, err = parseTabGroupMetadataCommand(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Title != "Café" || m.Collapsed || m.SavedGUID != "" {
+		t.Errorf("got %+v", m)
+	}
+}
+
+func TestNegativeTime(t *testing.T) {
+	const negative = -1 << 63
+	var window pickleWriter
+	window.int32(1)
+	window.int32(0)
+	window.int32(0)
+	window.int64(negative)
+	for _, tt := range []struct {
+		name     string
+		restore  func([]Command) (interface{}, error)
+		commands []Command
+	}{
+		{"last active time", restoreSession, []Command{
+			{ID: commandLastActiveTime, Payload: structPayload(int32(1), int32(0), int64(negative))},
+		}},
+		{"navigation timestamp", restoreSession, []Command{
+			{ID: commandUpdateTabNavigation, Payload: navigationPayload(1, 0, "https://a.example/", "A", negative)},
+		}},
+		{"closed tab", restoreTabs, []Command{
+			{ID: tabRestoreSelectedNavigationInTab, Payload: structPayload(int32(1), int32(0), int64(negative))},
+		}},
+		{"closed window", restoreTabs, []Command{
+			{ID: tabRestoreWindow, Payload: structPayload(int32(1), int32(0), int32(0), int32(0), int64(negative))},
+		}},
+		{"closed window pickle", restoreTabs, []Command{
+			{ID: tabRestoreWindow, Payload: window.bytes()},
+		}},
+	} {
+		if _, err := tt.restore(tt.commands); err == nil {
+			t.Errorf("%s: got no error for negative time", tt.name)
+		}
+	}
+}
+
+func restoreSession(commands []Command) (interface{}, error) { return RestoreSession(commands) }
+func restoreTabs(commands []Command) (interface{}, error)    { return RestoreTabs(commands) }
@@ -0,0 +1,153 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package snss reads the SNSS command files in which Chrome saves
+// sessions, so that the open windows, tabs, navigation entries, and tab
+// groups of a session and the recently closed tabs and windows can be
+// recovered.
+//
+// An SNSS file is the signature "SNSS" and a 32-bit version, followed
+// by commands, each in little endian. A command is its 16-bit size, an
+// 8-bit ID, and a payload of size-1 bytes. Payloads are either C
+// structs, with their fields aligned to their sizes, or pickles, in the
+// format of base::Pickle. A session is rebuilt by replaying its
+// commands in order, and Chrome periodically rewrites a file with only
+// the commands for the current state.
+// https://source.chromium.org/chromium/chromium/src/+/master:components/sessions/core/command_storage_backend.cc
+//
+// Before Chrome 86, sessions were saved in "Current Session" and "Last
+// Session" and closed tabs in "Current Tabs" and "Last Tabs" in a
+// profile. Since then, they are saved in the Sessions directory of a
+// profile, in files named "Session_{time}" and "Tabs_{time}".
+package snss
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Session files in a profile:
+const (
+	CurrentSessionFile = "Current Session"
+	LastSessionFile    = "Last Session"
+	CurrentTabsFile    = "Current Tabs"
+	LastTabsFile       = "Last Tabs"
+	SessionsDir        = "Sessions" // Session_{time} and Tabs_{time}, since Chrome 86
+)
+
+// Signature is the signature at the start of SNSS files.
+const Signature = "SNSS"
+
+// Versions of SNSS files:
+const (
+	Version1                    = 1
+	VersionEncrypted            = 2
+	VersionWithMarker           = 3 // marks the end of the initial state with a command
+	VersionEncryptedWithMarker  = 4
+	initialStateMarkerCommandID = 255
+)
+
+// Command is a command in an SNSS file.
+type Command struct {
+	ID      uint8
+	Payload []byte
+}
+
+// ReadCommands reads the commands in an SNSS file. The marker command
+// of VersionWithMarker is dropped. Encrypted files, which Chrome writes
+// only on some platforms, are unsupported. A command truncated by a
+// crash while writing ends the file, as in Chrome.
+func ReadCommands(r io.Reader) ([]Command, error) {
+	br := bufio.NewReader(r)
+	var header [8]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errors.New("snss: missing header")
+		}
+		return nil, err
+	}
+	if string(header[:4]) != Signature {
+		return nil, fmt.Errorf("snss: invalid signature %q", header[:4])
+	}
+	switch version := int32(binary.LittleEndian.Uint32(header[4:])); version {
+	case Version1, VersionWithMarker:
+	case VersionEncrypted, VersionEncryptedWithMarker:
+		return nil, errors.New("snss: encrypted files are unsupported")
+	default:
+		return nil, fmt.Errorf("snss: unsupported version %d", version)
+	}
+	var commands []Command
+	for {
+		var size [2]byte
+		if _, err := io.ReadFull(br, size[:]); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return commands, nil
+		} else if err != nil {
+			return nil, err
+		}
+		n := binary.LittleEndian.Uint16(size[:])
+		if n == 0 {
+			return nil, errors.New("snss: command without ID")
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(br, b); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return commands, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if b[0] == initialStateMarkerCommandID {
+			continue
+		}
+		commands = append(commands, Command{ID: b[0], Payload: b[1:]})
+	}
+}
+
+// readCommandsFile reads the commands in an SNSS file.
+func readCommandsFile(filename string) ([]Command, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadCommands(f)
+}
+
+// Files returns the session files in a Chrome profile: the files in
+// the Sessions directory, ordered by name, which orders them by time,
+// followed by the legacy files in the profile that exist.
+func Files(profileDir string) ([]string, error) {
+	var files []string
+	entries, err := os.ReadDir(filepath.Join(profileDir, SessionsDir))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, e := range entries {
+		if name := e.Name(); !e.IsDir() && (strings.HasPrefix(name, "Session_") || strings.HasPrefix(name, "Tabs_")) {
+			files = append(files, filepath.Join(profileDir, SessionsDir, name))
+		}
+	}
+	sort.Strings(files)
+	for _, name := range []string{CurrentSessionFile, LastSessionFile, CurrentTabsFile, LastTabsFile} {
+		if _, err := os.Stat(filepath.Join(profileDir, name)); err == nil {
+			files = append(files, filepath.Join(profileDir, name))
+		}
+	}
+	return files, nil
+}
+
+// IsTabRestore reports whether a session file holds recently closed
+// tabs and windows, to be read by ParseTabRestore, rather than a
+// session, to be read by ParseSession.
+func IsTabRestore(filename string) bool {
+	name := filepath.Base(filename)
+	return strings.HasPrefix(name, "Tabs_") || name == CurrentTabsFile || name == LastTabsFile
+}
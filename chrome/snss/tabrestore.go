@@ -0,0 +1,238 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package snss
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// Tab restore commands:
+// https://source.chromium.org/chromium/chromium/src/+/master:components/sessions/core/tab_restore_service_impl.cc
+//
+// A closed window is a window command followed by its tabs, and a
+// closed tab is a selected navigation command followed by its
+// navigations and properties. Commands for the properties of a tab or
+// window apply to the last one.
+const (
+	tabRestoreUpdateTabNavigation      = 1
+	tabRestoreRestoredEntry            = 2
+	tabRestoreWindow                   = 3
+	tabRestoreSelectedNavigationInTab  = 4
+	tabRestorePinnedState              = 5
+	tabRestoreSetExtensionAppID        = 6
+	tabRestoreSetWindowAppName         = 7
+	tabRestoreSetTabUserAgentOverride  = 8
+	tabRestoreGroup                    = 9
+	tabRestoreSetTabUserAgentOverride2 = 10
+	tabRestoreSetWindowUserTitle       = 11
+	tabRestoreCreateGroup              = 12
+	tabRestoreAddTabExtraData          = 13
+	tabRestoreAddWindowExtraData       = 14
+)
+
+// TabRestore is the recently closed tabs and windows in a tab restore
+// file.
+type TabRestore struct {
+	Entries []Entry   // in the order they were closed
+	Unknown []Command // commands of unknown types, in order
+}
+
+// Entry is a closed tab or window. Exactly one of Window and Tab is
+// set.
+type Entry struct {
+	ID     int32
+	Closed time.Time
+	Window *Window
+	Tab    *Tab
+}
+
+// ParseTabRestore reads a tab restore file, such as "Current Tabs" or
+// "Sessions/Tabs_{time}", with the recently closed tabs and windows.
+// Entries that were restored since are dropped, as are tabs without
+// navigations and windows without tabs.
+func ParseTabRestore(filename string) (*TabRestore, error) {
+	commands, err := readCommandsFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return RestoreTabs(commands)
+}
+
+// tabRestoreEntry is an entry while its file is read.
+type tabRestoreEntry struct {
+	Entry
+	tab  *sessionTab   // for tabs
+	tabs []*sessionTab // for windows
+}
+
+// RestoreTabs rebuilds the closed tabs and windows from the commands
+// in a tab restore file.
+func RestoreTabs(commands []Command) (*TabRestore, error) {
+	var tr TabRestore
+	var entries []*tabRestoreEntry
+	var window *tabRestoreEntry
+	var tab *sessionTab
+	remaining := 0 // tabs of window not yet read
+	for i := range commands {
+		c := &commands[i]
+		b := c.Payload
+		switch c.ID {
+		case tabRestoreWindow:
+			w, closed, numTabs, err := parseTabRestoreWindow(c)
+			if err != nil {
+				return nil, err
+			}
+			window = &tabRestoreEntry{Entry: Entry{ID: w.ID, Closed: closed, Window: w}}
+			entries = append(entries, window)
+			tab, remaining = nil, numTabs
+		case tabRestoreSelectedNavigationInTab:
+			if err := checkSize(c, 8); err != nil {
+				return nil, err
+			}
+			tab = &sessionTab{Tab: Tab{ID: getInt32(b, 0)}, current: int(getInt32(b, 4))}
+			if window != nil && remaining > 0 {
+				tab.Index = len(window.tabs)
+				window.tabs = append(window.tabs, tab)
+				remaining--
+			} else {
+				window = nil
+				e := &tabRestoreEntry{Entry: Entry{ID: tab.ID}, tab: tab}
+				if len(b) >= 16 {
+					t, err := getTime(b, 8)
+					if err != nil {
+						return nil, commandError(c, err)
+					}
+					e.Closed = t
+				}
+				entries = append(entries, e)
+			}
+		case tabRestoreUpdateTabNavigation:
+			p := newPickleReader(b)
+			p.int32() // tab ID
+			n := readNavigation(p)
+			if p.err != nil {
+				return nil, commandError(c, p.err)
+			}
+			if tab != nil {
+				tab.updateNavigation(n)
+			}
+		case tabRestoreRestoredEntry:
+			if err := checkSize(c, 4); err != nil {
+				return nil, err
+			}
+			id := getInt32(b, 0)
+			kept := entries[:0]
+			for _, e := range entries {
+				if e.ID != id {
+					kept = append(kept, e)
+				}
+			}
+			entries = kept
+		case tabRestorePinnedState:
+			if err := checkSize(c, 1); err != nil {
+				return nil, err
+			}
+			if tab != nil {
+				tab.Pinned = b[0] != 0
+			}
+		case tabRestoreSetExtensionAppID, tabRestoreSetWindowAppName, tabRestoreSetTabUserAgentOverride,
+			tabRestoreSetTabUserAgentOverride2, tabRestoreSetWindowUserTitle:
+			p := newPickleReader(b)
+			p.int32() // tab or window ID
+			str := p.string()
+			if p.err != nil {
+				return nil, commandError(c, p.err)
+			}
+			switch {
+			case c.ID == tabRestoreSetExtensionAppID && tab != nil:
+				tab.ExtensionAppID = str
+			case (c.ID == tabRestoreSetTabUserAgentOverride || c.ID == tabRestoreSetTabUserAgentOverride2) && tab != nil:
+				tab.UserAgentOverride = str
+			case c.ID == tabRestoreSetWindowAppName && window != nil:
+				window.Window.AppName = str
+			case c.ID == tabRestoreSetWindowUserTitle && window != nil:
+				window.Window.UserTitle = str
+			}
+		case tabRestoreAddTabExtraData, tabRestoreAddWindowExtraData:
+			p := newPickleReader(b)
+			p.int32() // tab or window ID
+			key, value := p.string(), p.string()
+			if p.err != nil {
+				return nil, commandError(c, p.err)
+			}
+			if c.ID == tabRestoreAddTabExtraData && tab != nil {
+				tab.ExtraData = setMap(tab.ExtraData, key, value)
+			} else if c.ID == tabRestoreAddWindowExtraData && window != nil {
+				window.Window.ExtraData = setMap(window.Window.ExtraData, key, value)
+			}
+		case tabRestoreGroup, tabRestoreCreateGroup:
+			// Closed tab groups are not restored.
+		default:
+			tr.Unknown = append(tr.Unknown, *c)
+		}
+	}
+
+	for _, e := range entries {
+		if e.Window != nil {
+			for _, t := range e.tabs {
+				if len(t.Navigations) != 0 {
+					t.finish()
+					e.Window.Tabs = append(e.Window.Tabs, t.Tab)
+				}
+			}
+			if len(e.Window.Tabs) == 0 {
+				continue
+			}
+			e.Window.SelectedTab = clamp(e.Window.SelectedTab, len(e.Window.Tabs))
+		} else {
+			if len(e.tab.Navigations) == 0 {
+				continue
+			}
+			e.tab.finish()
+			e.Tab = &e.tab.Tab
+		}
+		tr.Entries = append(tr.Entries, e.Entry)
+	}
+	return &tr, nil
+}
+
+// parseTabRestoreWindow parses the payload of a window command and
+// returns the window, its close time, and its number of tabs. Since
+// Chrome 80, the payload is a pickle, with the bounds, show state,
+// workspace, and type after the time. Before, it is a struct of the
+// ID, selected tab index, and number of tabs, and later the time,
+// aligned to 8 bytes.
+func parseTabRestoreWindow(c *Command) (w *Window, closed time.Time, numTabs int, err error) {
+	b := c.Payload
+	if len(b) >= 4 && int(binary.LittleEndian.Uint32(b)) == len(b)-4 {
+		p := newPickleReader(b)
+		w = &Window{ID: p.int32(), SelectedTab: int(p.int32())}
+		numTabs = int(p.int32())
+		closed = p.time()
+		if p.more() {
+			w.Bounds = Bounds{p.int32(), p.int32(), p.int32(), p.int32()}
+			w.ShowState = ShowState(p.int32())
+			w.Workspace = p.string()
+			w.Type = WindowType(p.int32())
+		}
+		if p.err != nil {
+			return nil, time.Time{}, 0, commandError(c, p.err)
+		}
+		return w, closed, numTabs, nil
+	}
+	if err := checkSize(c, 12); err != nil {
+		return nil, time.Time{}, 0, err
+	}
+	w = &Window{ID: getInt32(b, 0), SelectedTab: int(getInt32(b, 4))}
+	if len(b) >= 24 {
+		if closed, err = getTime(b, 16); err != nil {
+			return nil, time.Time{}, 0, commandError(c, err)
+		}
+	}
+	return w, closed, int(getInt32(b, 8)), nil
+}
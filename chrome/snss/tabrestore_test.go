@@ -0,0 +1,110 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package snss
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+)
+
+func TestParseTabRestore(t *testing.T) {
+	const t1, t2, t3 = 13255000000000000, 13255000001000000, 13255000002000000
+	var window pickleWriter
+	window.int32(1)  // ID
+	window.int32(1)  // selected tab index
+	window.int32(2)  // tabs
+	window.int64(t2) // closed
+	window.int32(0)  // bounds
+	window.int32(0)
+	window.int32(1024)
+	window.int32(768)
+	window.int32(int32(ShowStateNormal))
+	window.string("")
+	window.int32(int32(WindowNormal))
+	var appID pickleWriter
+	appID.int32(30)
+	appID.string("app")
+
+	filename := filepath.Join(t.TempDir(), CurrentTabsFile)
+	writeSNSS(t, filename, []Command{
+		{tabRestoreSelectedNavigationInTab, structPayload(int32(10), int32(0), int64(t1))},
+		{tabRestoreUpdateTabNavigation, navigationPayload(10, 0, "https://example.com/", "Example", t1)},
+		{tabRestorePinnedState, []byte{1}},
+		{tabRestoreWindow, window.bytes()},
+		{tabRestoreSelectedNavigationInTab, structPayload(int32(20), int32(1), int64(t2))},
+		{tabRestoreUpdateTabNavigation, navigationPayload(20, 0, "https://example.org/", "Org", t1)},
+		{tabRestoreUpdateTabNavigation, navigationPayload(20, 1, "https://example.org/a", "A", t2)},
+		{tabRestoreSelectedNavigationInTab, structPayload(int32(21), int32(0), int64(t2))},
+		{tabRestoreUpdateTabNavigation, navigationPayload(21, 0, "https://example.net/", "Net", t1)},
+		{tabRestoreSelectedNavigationInTab, structPayload(int32(30), int32(0), int64(t3))},
+		{tabRestoreUpdateTabNavigation, navigationPayload(30, 0, "https://restored.example/", "Restored", t3)},
+		{tabRestoreSetExtensionAppID, appID.bytes()},
+		{tabRestoreRestoredEntry, structPayload(int32(30))},
+		// Legacy window, without tabs with navigations.
+		{tabRestoreWindow, structPayload(int32(2), int32(0), int32(1))},
+		{tabRestoreSelectedNavigationInTab, structPayload(int32(40), int32(0))},
+	})
+
+	tr, err := ParseTabRestore(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nav := func(index int, url, title string, timestamp int64) Navigation {
+		return Navigation{
+			Index:              index,
+			URL:                url,
+			Title:              title,
+			Transition:         chrome.TransitionTyped,
+			ReferrerURL:        "https://referrer.example/",
+			OriginalRequestURL: url,
+			Timestamp:          timefmt.FromInt(timestamp, 0, timefmt.Micro, timefmt.Windows),
+		}
+	}
+	want := &TabRestore{
+		Entries: []Entry{{
+			ID:     10,
+			Closed: timefmt.FromInt(t1, 0, timefmt.Micro, timefmt.Windows),
+			Tab: &Tab{
+				ID:          10,
+				Pinned:      true,
+				Navigations: []Navigation{nav(0, "https://example.com/", "Example", t1)},
+			},
+		}, {
+			ID:     1,
+			Closed: timefmt.FromInt(t2, 0, timefmt.Micro, timefmt.Windows),
+			Window: &Window{
+				ID:          1,
+				Type:        WindowNormal,
+				Bounds:      Bounds{0, 0, 1024, 768},
+				ShowState:   ShowStateNormal,
+				SelectedTab: 1,
+				Tabs: []Tab{{
+					ID:       20,
+					Selected: 1,
+					Navigations: []Navigation{
+						nav(0, "https://example.org/", "Org", t1),
+						nav(1, "https://example.org/a", "A", t2),
+					},
+				}, {
+					ID:          21,
+					Index:       1,
+					Navigations: []Navigation{nav(0, "https://example.net/", "Net", t1)},
+				}},
+			},
+		}},
+	}
+	if !reflect.DeepEqual(tr, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", tr, want)
+	}
+	if !IsTabRestore(filename) || IsTabRestore(filepath.Join(SessionsDir, "Session_13255000000000000")) {
+		t.Error("IsTabRestore does not match file names")
+	}
+}
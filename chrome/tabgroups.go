@@ -0,0 +1,265 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/protoutil"
+)
+
+// Tab group formats:
+// https://source.chromium.org/chromium/chromium/src/+/master:components/sync/protocol/saved_tab_group_specifics.proto
+// https://source.chromium.org/chromium/chromium/src/+/master:components/saved_tab_groups/proto/saved_tab_group_data.proto
+// https://source.chromium.org/chromium/chromium/src/+/master:components/tab_groups/tab_group_color.h
+//
+// Saved tab groups are stored in the "Sync Data/LevelDB" database in a
+// profile, with keys "saved_tab_group-dt-{guid}". Each value is a
+// SavedTabGroupSpecifics, either directly or, in newer versions,
+// wrapped in a SavedTabGroupData with local data, that describes
+// either a group or a tab in a group. Tabs refer to their group by
+// GUID.
+//
+// Tab groups of open windows are stored in the session files, with a
+// command that assigns a tab to a group, identified by a 128-bit token,
+// and a command with the metadata of the group. They are read by
+// package snss.
+
+// SavedTabGroup is a tab group saved in a profile.
+type SavedTabGroup struct {
+	GUID     string
+	Title    string
+	Color    TabGroupColor
+	Position int64 // position in the saved tab groups bar, or -1 when unpinned
+	Created  time.Time
+	Updated  time.Time
+	Tabs     []SavedTab // ordered by position
+}
+
+// SavedTab is a tab in a saved tab group.
+type SavedTab struct {
+	GUID     string
+	URL      string
+	Title    string
+	Position int64
+	Created  time.Time
+	Updated  time.Time
+}
+
+// TabGroupColor is the color of a tab group.
+type TabGroupColor uint8
+
+// Values for TabGroupColor:
+const (
+	TabGroupGrey TabGroupColor = iota
+	TabGroupBlue
+	TabGroupRed
+	TabGroupYellow
+	TabGroupGreen
+	TabGroupPink
+	TabGroupPurple
+	TabGroupCyan
+	TabGroupOrange
+)
+
+// TabGroupMetadata is the metadata of a tab group in a session.
+type TabGroupMetadata struct {
+	ID        string // token, in hexadecimal
+	Title     string
+	Color     TabGroupColor
+	Collapsed bool
+	SavedGUID string // GUID of the saved tab group, when saved
+}
+
+// savedTabGroupPrefix is the key prefix of saved tab groups in the
+// sync LevelDB.
+const savedTabGroupPrefix = "saved_tab_group-dt-"
+
+var guidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ParseSavedTabGroups reads the saved tab groups in a Chrome profile,
+// ordered by position, with unpinned groups last, ordered by creation
+// time. Tabs whose group is missing are dropped. Malformed entries are
+// handled as by ParseSavedTabGroupsOptions with the zero Options.
+func ParseSavedTabGroups(profileDir string) ([]SavedTabGroup, error) {
+	return ParseSavedTabGroupsOptions(profileDir, browser.Options{})
+}
+
+// ParseSavedTabGroupsOptions reads the saved tab groups in a Chrome
+// profile and handles malformed entries, which are the records of the
+// database, by the error policy in opts.
+func ParseSavedTabGroupsOptions(profileDir string, opts browser.Options) ([]SavedTabGroup, error) {
+	h := opts.Handler()
+	groups := make(map[string]*SavedTabGroup)
+	tabs := make(map[string][]SavedTab) // key: group GUID
+	prefix := []byte(savedTabGroupPrefix)
+	db := filepath.Join(profileDir, "Sync Data", "LevelDB")
+	record, skipped := 0, false
+	err := walkLevelDB(db, prefix, func(key, value []byte) error {
+		record++
+		if skipped {
+			return nil
+		}
+		group, tab, groupGUID, err := parseSavedTabGroupEntry(value)
+		if err != nil {
+			skipFile, err := h.Record(db, record, fmt.Errorf("chrome: saved tab group %s: %w", key[len(prefix):], err))
+			if skipFile {
+				groups, tabs, skipped = make(map[string]*SavedTabGroup), make(map[string][]SavedTab), true
+			}
+			return err
+		}
+		if group != nil {
+			groups[group.GUID] = group
+		} else if tab != nil {
+			tabs[groupGUID] = append(tabs[groupGUID], *tab)
+		}
+		return nil
+	})
+	var e *browser.Error
+	if errors.As(err, &e) || errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	} else if err != nil {
+		if err := h.File(db, err); err != nil {
+			return nil, err
+		}
+		return nil, h.Err()
+	}
+
+	list := make([]SavedTabGroup, 0, len(groups))
+	for guid, g := range groups {
+		g.Tabs = tabs[guid]
+		sort.SliceStable(g.Tabs, func(i, j int) bool {
+			return g.Tabs[i].Position < g.Tabs[j].Position
+		})
+		list = append(list, *g)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		gi, gj := &list[i], &list[j]
+		if (gi.Position < 0) != (gj.Position < 0) {
+			return gj.Position < 0
+		}
+		if gi.Position != gj.Position {
+			return gi.Position < gj.Position
+		}
+		if !gi.Created.Equal(gj.Created) {
+			return gi.Created.Before(gj.Created)
+		}
+		return gi.GUID < gj.GUID
+	})
+	return list, h.Err()
+}
+
+// parseSavedTabGroupEntry parses a SavedTabGroupData or
+// SavedTabGroupSpecifics, which is either a group or a tab in the group
+// with the returned GUID.
+func parseSavedTabGroupEntry(b []byte) (*SavedTabGroup, *SavedTab, string, error) {
+	// Field 1 is the GUID in the specifics and the specifics in the
+	// wrapper.
+	specifics := b
+	err := protoutil.Walk(b, "SavedTabGroupData", func(f *protoutil.Field) error {
+		if f.Num == 1 && !guidPattern.Match(f.Bytes) {
+			specifics = f.Bytes
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	var guid string
+	var created, updated int64
+	var group *SavedTabGroup
+	var tab *SavedTab
+	var groupGUID string
+	err = protoutil.Walk(specifics, "SavedTabGroupSpecifics", func(f *protoutil.Field) error {
+		switch f.Num {
+		case 1:
+			guid = f.String()
+		case 2:
+			created = f.Int64()
+		case 3:
+			updated = f.Int64()
+		case 4:
+			group = &SavedTabGroup{Position: -1}
+			return protoutil.Walk(f.Bytes, "SavedTabGroup", func(f *protoutil.Field) error {
+				switch f.Num {
+				case 1:
+					group.Position = f.Int64()
+				case 2:
+					group.Title = f.String()
+				case 3:
+					// The proto enum starts with an unspecified value.
+					if f.Varint != 0 {
+						group.Color = TabGroupColor(f.Varint - 1)
+					}
+				}
+				return nil
+			})
+		case 5:
+			tab = &SavedTab{}
+			return protoutil.Walk(f.Bytes, "SavedTabGroupTab", func(f *protoutil.Field) error {
+				switch f.Num {
+				case 1:
+					groupGUID = f.String()
+				case 2:
+					tab.Position = f.Int64()
+				case 3:
+					tab.URL = f.String()
+				case 4:
+					tab.Title = f.String()
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, "", err
+	}
+	createdTime := timefmt.FromInt(created, 0, timefmt.Micro, timefmt.Windows)
+	updatedTime := timefmt.FromInt(updated, 0, timefmt.Micro, timefmt.Windows)
+	switch {
+	case group != nil:
+		group.GUID, group.Created, group.Updated = guid, createdTime, updatedTime
+	case tab != nil:
+		tab.GUID, tab.Created, tab.Updated = guid, createdTime, updatedTime
+	}
+	return group, tab, groupGUID, nil
+}
+
+func (c TabGroupColor) String() string {
+	switch c {
+	case TabGroupGrey:
+		return "grey"
+	case TabGroupBlue:
+		return "blue"
+	case TabGroupRed:
+		return "red"
+	case TabGroupYellow:
+		return "yellow"
+	case TabGroupGreen:
+		return "green"
+	case TabGroupPink:
+		return "pink"
+	case TabGroupPurple:
+		return "purple"
+	case TabGroupCyan:
+		return "cyan"
+	case TabGroupOrange:
+		return "orange"
+	default:
+		return fmt.Sprintf("color(%d)", uint8(c))
+	}
+}
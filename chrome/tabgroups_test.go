@@ -0,0 +1,112 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/syndtr/goleveldb/leveldb"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func savedTabGroupSpecifics(guid string, created int64, field protowire.Number, msg []byte) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, guid)
+	b = protowire.AppendTag(b, 2, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(created))
+	b = protowire.AppendTag(b, 3, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(created))
+	b = protowire.AppendTag(b, field, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func savedGroup(guid string, created int64, position int64, title string, color uint64) []byte {
+	var g []byte
+	if position >= 0 {
+		g = protowire.AppendTag(g, 1, protowire.VarintType)
+		g = protowire.AppendVarint(g, uint64(position))
+	}
+	g = protowire.AppendTag(g, 2, protowire.BytesType)
+	g = protowire.AppendString(g, title)
+	g = protowire.AppendTag(g, 3, protowire.VarintType)
+	g = protowire.AppendVarint(g, color)
+	return savedTabGroupSpecifics(guid, created, 4, g)
+}
+
+func savedTab(guid string, created int64, group string, position int64, url, title string) []byte {
+	var tab []byte
+	tab = protowire.AppendTag(tab, 1, protowire.BytesType)
+	tab = protowire.AppendString(tab, group)
+	tab = protowire.AppendTag(tab, 2, protowire.VarintType)
+	tab = protowire.AppendVarint(tab, uint64(position))
+	tab = protowire.AppendTag(tab, 3, protowire.BytesType)
+	tab = protowire.AppendString(tab, url)
+	tab = protowire.AppendTag(tab, 4, protowire.BytesType)
+	tab = protowire.AppendString(tab, title)
+	return savedTabGroupSpecifics(guid, created, 5, tab)
+}
+
+func TestParseSavedTabGroups(t *testing.T) {
+	const (
+		work   = "0b8d9a3e-1c5e-4f0a-9d2b-6f1e2d3c4b5a"
+		recipe = "5f2c1d0e-9a8b-4c7d-8e6f-1a2b3c4d5e6f"
+	)
+	// Newer versions wrap the specifics in SavedTabGroupData.
+	var wrapped []byte
+	wrapped = protowire.AppendTag(wrapped, 1, protowire.BytesType)
+	wrapped = protowire.AppendBytes(wrapped, savedTab("7a6b5c4d-3e2f-4a1b-9c8d-7e6f5a4b3c2d", 13258000000000000, work, 0, "https://mail.example.com/", "Mail"))
+	wrapped = protowire.AppendTag(wrapped, 2, protowire.BytesType)
+	wrapped = protowire.AppendBytes(wrapped, nil)
+
+	entries := map[string][]byte{
+		work:                                   savedGroup(work, 13258000000000000, 0, "Work", 2),
+		recipe:                                 savedGroup(recipe, 13257000000000000, -1, "Recipes", 9),
+		"1d2c3b4a-5e6f-4a7b-8c9d-0e1f2a3b4c5d": savedTab("1d2c3b4a-5e6f-4a7b-8c9d-0e1f2a3b4c5d", 13258000000000000, work, 1, "https://docs.example.com/", "Docs"),
+		"7a6b5c4d-3e2f-4a1b-9c8d-7e6f5a4b3c2d": wrapped,
+		"9f8e7d6c-5b4a-4c3d-8e2f-1a0b9c8d7e6f": savedTab("9f8e7d6c-5b4a-4c3d-8e2f-1a0b9c8d7e6f", 13257000000000000, recipe, 0, "https://food.example/", "Soup"),
+		"2b3c4d5e-6f7a-4b8c-9d0e-1f2a3b4c5d6e": savedTab("2b3c4d5e-6f7a-4b8c-9d0e-1f2a3b4c5d6e", 13257000000000000, "missing", 0, "https://orphan.example/", "Orphan"),
+	}
+	profile := t.TempDir()
+	db, err := leveldb.OpenFile(filepath.Join(profile, "Sync Data", "LevelDB"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for guid, value := range entries {
+		if err := db.Put([]byte(savedTabGroupPrefix+guid), value, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Put([]byte("saved_tab_group-md-"+work), []byte{0xff}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := ParseSavedTabGroups(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t1 := timefmt.FromInt(13258000000000000, 0, timefmt.Micro, timefmt.Windows)
+	t0 := timefmt.FromInt(13257000000000000, 0, timefmt.Micro, timefmt.Windows)
+	want := []SavedTabGroup{
+		{GUID: work, Title: "Work", Color: TabGroupBlue, Position: 0, Created: t1, Updated: t1, Tabs: []SavedTab{
+			{"7a6b5c4d-3e2f-4a1b-9c8d-7e6f5a4b3c2d", "https://mail.example.com/", "Mail", 0, t1, t1},
+			{"1d2c3b4a-5e6f-4a7b-8c9d-0e1f2a3b4c5d", "https://docs.example.com/", "Docs", 1, t1, t1},
+		}},
+		{GUID: recipe, Title: "Recipes", Color: TabGroupOrange, Position: -1, Created: t0, Updated: t0, Tabs: []SavedTab{
+			{"9f8e7d6c-5b4a-4c3d-8e2f-1a0b9c8d7e6f", "https://food.example/", "Soup", 0, t0, t0},
+		}},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", groups, want)
+	}
+}
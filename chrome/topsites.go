@@ -0,0 +1,102 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Top Sites schema:
+// https://source.chromium.org/chromium/chromium/src/+/master:components/history/core/browser/top_sites_database.cc
+//
+// The most visited sites, shown as tiles on the New Tab page, are
+// stored in the top_sites table. Before version 4 of the database, they
+// were stored in the thumbnails table, with a JPEG thumbnail of each
+// page and the metadata used to decide when to retake it.
+
+// TopSite is a most visited site in the "Top Sites" database.
+type TopSite struct {
+	URL       string
+	Rank      int // position of the tile, or -1 for forced sites
+	Title     string
+	Redirects []string   // redirect chain ending at URL, starting with the URL visited
+	Thumbnail *Thumbnail // nil since version 4
+}
+
+// Thumbnail is a thumbnail of a top site and its metadata.
+type Thumbnail struct {
+	Data          []byte  // JPEG image
+	BoringScore   float64 // 0 to 1, where 1 is a blank page
+	GoodClipping  bool
+	AtTop         bool // whether taken at the top of the page
+	LastUpdated   time.Time
+	LoadCompleted bool
+	LastForced    time.Time // zero unless the site was forced
+}
+
+// ParseTopSites parses the most visited sites in a "Top Sites"
+// database. Sites are ordered by rank, with forced sites first.
+func ParseTopSites(filename string) ([]TopSite, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	legacy, err := sqliteutil.HasTable(db, "thumbnails")
+	if err != nil {
+		return nil, fmt.Errorf("chrome: top sites: %w", err)
+	}
+	query := `
+		SELECT url, url_rank, title, redirects
+		FROM top_sites
+		ORDER BY url_rank, url`
+	if legacy {
+		query = `
+			SELECT url, url_rank, title, redirects, thumbnail, boring_score,
+				good_clipping, at_top, last_updated, load_completed, last_forced
+			FROM thumbnails
+			ORDER BY url_rank, url`
+	}
+	var sites []TopSite
+	err = sqliteutil.Query(db, query, func(rows *sql.Rows) error {
+		var s TopSite
+		var title, redirects sql.NullString
+		dest := []interface{}{&s.URL, &s.Rank, &title, &redirects}
+		var th Thumbnail
+		var updated, forced int64
+		if legacy {
+			dest = append(dest, &th.Data, &th.BoringScore,
+				&th.GoodClipping, &th.AtTop, &updated, &th.LoadCompleted, &forced)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		s.Title = title.String
+		s.Redirects = strings.Fields(redirects.String)
+		if legacy {
+			var err error
+			if th.LastUpdated, err = chromeTime(updated); err != nil {
+				return err
+			}
+			if th.LastForced, err = chromeTime(forced); err != nil {
+				return err
+			}
+			s.Thumbnail = &th
+		}
+		sites = append(sites, s)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chrome: top sites: %w", err)
+	}
+	return sites, nil
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTopSites(t *testing.T) {
+	dir := t.TempDir()
+	for _, test := range []struct {
+		name    string
+		queries []string
+		want    []TopSite
+	}{{
+		name: "current",
+		queries: []string{
+			`CREATE TABLE top_sites (url LONGVARCHAR PRIMARY KEY, url_rank INTEGER,
+				title LONGVARCHAR, redirects LONGVARCHAR)`,
+			`INSERT INTO top_sites VALUES ('https://example.org/', 1, 'Org', 'https://example.org/')`,
+			`INSERT INTO top_sites VALUES ('https://www.example.com/', 0, 'Example',
+				'http://example.com/ https://example.com/ https://www.example.com/')`,
+		},
+		want: []TopSite{
+			{URL: "https://www.example.com/", Title: "Example",
+				Redirects: []string{"http://example.com/", "https://example.com/", "https://www.example.com/"}},
+			{URL: "https://example.org/", Rank: 1, Title: "Org", Redirects: []string{"https://example.org/"}},
+		},
+	}, {
+		name: "legacy",
+		queries: []string{
+			`CREATE TABLE thumbnails (url LONGVARCHAR PRIMARY KEY, url_rank INTEGER, title LONGVARCHAR,
+				thumbnail BLOB, redirects LONGVARCHAR, boring_score DOUBLE DEFAULT 1.0,
+				good_clipping INTEGER DEFAULT 0, at_top INTEGER DEFAULT 0, last_updated INTEGER DEFAULT 0,
+				load_completed INTEGER DEFAULT 0, last_forced INTEGER DEFAULT 0)`,
+			`INSERT INTO thumbnails VALUES ('https://example.com/', 0, 'Example', X'FFD8FFD9',
+				'https://example.com/', 0.25, 1, 1, 13258087200000000, 1, 0)`,
+			`INSERT INTO thumbnails VALUES ('https://forced.example/', -1, '', NULL,
+				'https://forced.example/', 1.0, 0, 0, 0, 0, 13258083600000000)`,
+		},
+		want: []TopSite{
+			{URL: "https://forced.example/", Rank: -1, Redirects: []string{"https://forced.example/"},
+				Thumbnail: &Thumbnail{BoringScore: 1, LastForced: time.Date(2021, 2, 18, 1, 0, 0, 0, time.UTC)}},
+			{URL: "https://example.com/", Title: "Example", Redirects: []string{"https://example.com/"},
+				Thumbnail: &Thumbnail{Data: []byte{0xff, 0xd8, 0xff, 0xd9}, BoringScore: 0.25, GoodClipping: true,
+					AtTop: true, LastUpdated: time.Date(2021, 2, 18, 2, 0, 0, 0, time.UTC), LoadCompleted: true}},
+		},
+	}} {
+		filename := filepath.Join(dir, test.name)
+		db, err := sql.Open("sqlite3", filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, q := range test.queries {
+			if _, err := db.Exec(q); err != nil {
+				t.Fatal(err)
+			}
+		}
+		db.Close()
+
+		sites, err := ParseTopSites(filename)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(sites, test.want) {
+			t.Errorf("%s: got:\n%+v\nwant:\n%+v", test.name, sites, test.want)
+		}
+	}
+}
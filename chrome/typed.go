@@ -0,0 +1,132 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"sort"
+	"time"
+)
+
+// URLInput summarizes how the user reached a URL: by input in the
+// omnibox or by clicking, such as on a link or bookmark.
+type URLInput struct {
+	URLID      int64
+	URL        string
+	Title      string
+	TypedCount int       // typed_count of the URL, which outlives expired visits
+	Typed      int       // visits from omnibox input
+	Clicked    int       // other visits that started a navigation
+	LastTyped  time.Time // zero when no visits are from omnibox input
+	LastVisit  time.Time
+	Hidden     bool
+}
+
+// IsOmniboxInput reports whether the transition is input in the
+// omnibox: a typed URL, a search, or a keyword search.
+func (typ PageTransition) IsOmniboxInput() bool {
+	switch typ & TransitionCoreMask {
+	case TransitionTyped, TransitionGenerated, TransitionKeyword, TransitionKeywordGenerated:
+		return true
+	}
+	return typ&TransitionFromAddressBar != 0
+}
+
+// AnalyzeInput partitions URLs into those that the user typed, having a
+// nonzero typed_count or a visit from omnibox input, and those that
+// were only clicked. Redirects, reloads, back-forward navigations, and
+// subframes are not counted as visits, and hidden URLs that were not
+// typed, like subframes, are omitted. Typed URLs are ordered by typed
+// count, then URL, and clicked URLs by visit count, then URL.
+func AnalyzeInput(urls []HistoryURL, visits []HistoryVisit) (typed, clicked []URLInput) {
+	inputs := make(map[int64]*URLInput, len(urls))
+	for _, u := range urls {
+		inputs[u.ID] = &URLInput{
+			URLID:      u.ID,
+			URL:        u.URL,
+			Title:      u.Title,
+			TypedCount: u.TypedCount,
+			LastVisit:  u.LastVisitTime,
+			Hidden:     u.Hidden,
+		}
+	}
+	for _, v := range visits {
+		in, ok := inputs[v.URLID]
+		if !ok || !isNavigation(v.Transition) {
+			continue
+		}
+		if v.Transition.IsOmniboxInput() {
+			in.Typed++
+			if v.VisitTime.After(in.LastTyped) {
+				in.LastTyped = v.VisitTime
+			}
+		} else {
+			in.Clicked++
+		}
+	}
+	for _, u := range urls {
+		in := inputs[u.ID]
+		switch {
+		case in.TypedCount != 0 || in.Typed != 0:
+			typed = append(typed, *in)
+		case !in.Hidden && in.Clicked != 0:
+			clicked = append(clicked, *in)
+		}
+	}
+	sort.SliceStable(typed, func(i, j int) bool {
+		ti, tj := typed[i].typedTotal(), typed[j].typedTotal()
+		if ti != tj {
+			return ti > tj
+		}
+		return typed[i].URL < typed[j].URL
+	})
+	sort.SliceStable(clicked, func(i, j int) bool {
+		if clicked[i].Clicked != clicked[j].Clicked {
+			return clicked[i].Clicked > clicked[j].Clicked
+		}
+		return clicked[i].URL < clicked[j].URL
+	})
+	return typed, clicked
+}
+
+// AnalyzeInput partitions the URLs in the history into those that the
+// user typed and those that were only clicked, as by AnalyzeInput.
+func (h *History) AnalyzeInput() (typed, clicked []URLInput, err error) {
+	urls, err := h.URLs()
+	if err != nil {
+		return nil, nil, err
+	}
+	visits, err := h.Visits()
+	if err != nil {
+		return nil, nil, err
+	}
+	typed, clicked = AnalyzeInput(urls, visits)
+	return typed, clicked, nil
+}
+
+// typedTotal returns the larger of typed_count and the typed visits,
+// since visits expire and typed_count excludes some omnibox input, like
+// searches.
+func (in *URLInput) typedTotal() int {
+	if in.Typed > in.TypedCount {
+		return in.Typed
+	}
+	return in.TypedCount
+}
+
+// isNavigation reports whether a visit started a navigation by the
+// user, rather than continuing one, as with redirects, or repeating
+// one, as with reloads and back-forward navigations.
+func isNavigation(typ PageTransition) bool {
+	if typ&(TransitionIsRedirectMask|TransitionForwardBack) != 0 {
+		return false
+	}
+	switch typ & TransitionCoreMask {
+	case TransitionAutoSubframe, TransitionManualSubframe, TransitionReload:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeInput(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "History")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// Transitions: 0x30000000 is a chain start and end, 0x01000000 is
+	// back-forward, and 0xa0000000 is a server redirect chain end.
+	for _, q := range []string{
+		`CREATE TABLE urls (id INTEGER PRIMARY KEY AUTOINCREMENT, url LONGVARCHAR, title LONGVARCHAR,
+			visit_count INTEGER DEFAULT 0 NOT NULL, typed_count INTEGER DEFAULT 0 NOT NULL,
+			last_visit_time INTEGER NOT NULL, hidden INTEGER DEFAULT 0 NOT NULL)`,
+		`CREATE TABLE visits (id INTEGER PRIMARY KEY, url INTEGER NOT NULL, visit_time INTEGER NOT NULL,
+			from_visit INTEGER, transition INTEGER DEFAULT 0 NOT NULL, segment_id INTEGER,
+			visit_duration INTEGER DEFAULT 0 NOT NULL)`,
+		`INSERT INTO urls VALUES (1, 'https://example.com/', 'Example', 3, 1, 13258087200000000, 0)`,
+		`INSERT INTO urls VALUES (2, 'https://example.com/page', 'Page', 2, 0, 13258083600000000, 0)`,
+		`INSERT INTO urls VALUES (3, 'https://www.example.com/', 'Example', 1, 0, 13258080000000000, 0)`,
+		`INSERT INTO urls VALUES (4, 'https://ads.example.com/frame', '', 1, 0, 13258083600000000, 1)`,
+		`INSERT INTO urls VALUES (5, 'https://example.org/', 'Search', 1, 0, 13258090800000000, 0)`,
+		`INSERT INTO visits VALUES (1, 3, 13258080000000000, 0, 805306369, 0, 0)`,
+		`INSERT INTO visits VALUES (2, 1, 13258080000000000, 1, -1610612735, 0, 0)`,
+		`INSERT INTO visits VALUES (3, 2, 13258083600000000, 2, 805306368, 0, 0)`,
+		`INSERT INTO visits VALUES (4, 4, 13258083600000000, 3, 3, 0, 0)`,
+		`INSERT INTO visits VALUES (5, 2, 13258084000000000, 0, 822083584, 0, 0)`,
+		`INSERT INTO visits VALUES (6, 1, 13258087200000000, 0, 805306369, 0, 0)`,
+		`INSERT INTO visits VALUES (7, 5, 13258090800000000, 0, 805306373, 0, 0)`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h, err := OpenHistory(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	typed, clicked, err := h.AnalyzeInput()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hour := func(h int) time.Time { return time.Date(2021, 2, 18, h, 0, 0, 0, time.UTC) }
+	wantTyped := []URLInput{
+		{URLID: 1, URL: "https://example.com/", Title: "Example", TypedCount: 1, Typed: 1,
+			LastTyped: hour(2), LastVisit: hour(2)},
+		{URLID: 5, URL: "https://example.org/", Title: "Search", Typed: 1,
+			LastTyped: hour(3), LastVisit: hour(3)},
+		{URLID: 3, URL: "https://www.example.com/", Title: "Example", Typed: 1,
+			LastTyped: hour(0), LastVisit: hour(0)},
+	}
+	wantClicked := []URLInput{
+		{URLID: 2, URL: "https://example.com/page", Title: "Page", Clicked: 1, LastVisit: hour(1)},
+	}
+	if !reflect.DeepEqual(typed, wantTyped) {
+		t.Errorf("got typed:\n%+v\nwant:\n%+v", typed, wantTyped)
+	}
+	if !reflect.DeepEqual(clicked, wantClicked) {
+		t.Errorf("got clicked:\n%+v\nwant:\n%+v", clicked, wantClicked)
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Visited links formats:
+// https://source.chromium.org/chromium/chromium/src/+/master:components/visitedlink/browser/visitedlink_writer.cc
+// https://source.chromium.org/chromium/chromium/src/+/master:components/history/core/browser/visited_link_database.cc
+//
+// The legacy format is a hash table of URL fingerprints in the "Visited
+// Links" file, which is shared by every site, so that any page could
+// learn whether a link was visited. The file has a 24-byte header of
+// the signature "VLnk", the version, the number of used entries, the
+// table length, and an 8-byte salt, each in little endian, followed by
+// the table of 64-bit fingerprints, with 0 for empty entries. A
+// fingerprint is the first 8 bytes of the MD5 hash of the salt and the
+// canonical URL, and entries are placed by linear probing from the
+// fingerprint modulo the table length.
+//
+// Since Chrome 136, visited links are partitioned by the site of the
+// top-level frame and the origin of the frame with the link, so that
+// sites can only learn of visits to links from themselves. Partitioned
+// links are stored in the visited_links table of History and the hash
+// table, with a salt per origin, is only kept in memory.
+
+// VisitedLinksFile is the legacy hash table of visited links in a
+// profile.
+const VisitedLinksFile = "Visited Links"
+
+// VisitedLinksFormat is the format of the visited links in a profile.
+type VisitedLinksFormat uint8
+
+// Values for VisitedLinksFormat:
+const (
+	VisitedLinksNone        VisitedLinksFormat = 0 // no visited links
+	VisitedLinksLegacy      VisitedLinksFormat = 1 // "Visited Links" hash table
+	VisitedLinksPartitioned VisitedLinksFormat = 2 // visited_links table in History
+)
+
+// VisitedLinks is the visited links in a profile, in either format.
+type VisitedLinks struct {
+	Format      VisitedLinksFormat
+	Table       *VisitedLinkTable // nil when there is no "Visited Links" file
+	Partitioned []PartitionedVisitedLink
+}
+
+// VisitedLinkTable is the legacy hash table of the fingerprints of
+// visited URLs in "Visited Links". URLs cannot be recovered from their
+// fingerprints, but a URL can be checked with Contains.
+type VisitedLinkTable struct {
+	Version      uint32
+	Used         int // number of nonzero fingerprints
+	Salt         [8]byte
+	Fingerprints []uint64 // hash table, with 0 for empty entries
+}
+
+// PartitionedVisitedLink is a row in the visited_links table of
+// History, which is a link that was visited from a frame.
+type PartitionedVisitedLink struct {
+	ID          int64
+	LinkURLID   int64  // ID in the urls table
+	LinkURL     string // URL of the link
+	TopLevelURL string // site of the top-level frame, e.g. "https://example.com/"
+	FrameURL    string // origin of the frame with the link
+	VisitCount  int
+}
+
+const (
+	visitedLinksSignature  = "VLnk"
+	visitedLinksVersion    = 3
+	visitedLinksHeaderSize = 24
+)
+
+// ParseVisitedLinks parses a legacy "Visited Links" file.
+func ParseVisitedLinks(filename string) (*VisitedLinkTable, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < visitedLinksHeaderSize || string(data[:4]) != visitedLinksSignature {
+		return nil, errors.New("chrome: visited links: invalid signature")
+	}
+	t := &VisitedLinkTable{Version: binary.LittleEndian.Uint32(data[4:])}
+	if t.Version != visitedLinksVersion {
+		return nil, fmt.Errorf("chrome: visited links: unsupported version %d", t.Version)
+	}
+	used := binary.LittleEndian.Uint32(data[8:])
+	length := binary.LittleEndian.Uint32(data[12:])
+	copy(t.Salt[:], data[16:24])
+	data = data[visitedLinksHeaderSize:]
+	if uint64(length)*8 != uint64(len(data)) {
+		return nil, fmt.Errorf("chrome: visited links: table length %d does not match %d bytes", length, len(data))
+	}
+	t.Fingerprints = make([]uint64, length)
+	for i := range t.Fingerprints {
+		t.Fingerprints[i] = binary.LittleEndian.Uint64(data[i*8:])
+		if t.Fingerprints[i] != 0 {
+			t.Used++
+		}
+	}
+	if t.Used != int(used) {
+		return nil, fmt.Errorf("chrome: visited links: %d entries used, but header has %d", t.Used, used)
+	}
+	return t, nil
+}
+
+// Fingerprint returns the fingerprint of a URL with the salt of the
+// table. The URL must be canonical, as from url.URL.String for most
+// URLs, e.g. "https://example.com/", rather than "https://example.com".
+func (t *VisitedLinkTable) Fingerprint(url string) uint64 {
+	h := md5.New()
+	h.Write(t.Salt[:])
+	h.Write([]byte(url))
+	return binary.LittleEndian.Uint64(h.Sum(nil))
+}
+
+// Contains reports whether the fingerprint of a URL is in the table. As
+// the table only has fingerprints, false positives are possible, but
+// rare.
+func (t *VisitedLinkTable) Contains(url string) bool {
+	if len(t.Fingerprints) == 0 {
+		return false
+	}
+	fp := t.Fingerprint(url)
+	first := int(fp % uint64(len(t.Fingerprints)))
+	i := first
+	for {
+		switch t.Fingerprints[i] {
+		case 0:
+			return false
+		case fp:
+			return true
+		}
+		i++
+		if i == len(t.Fingerprints) {
+			i = 0
+		}
+		if i == first {
+			return false
+		}
+	}
+}
+
+// VisitedLinks returns the partitioned visited links, ordered by ID, or
+// nil when the database predates the visited_links table.
+func (h *History) VisitedLinks() ([]PartitionedVisitedLink, error) {
+	ok, err := sqliteutil.HasTable(h.db, "visited_links")
+	if err != nil || !ok {
+		return nil, err
+	}
+	var links []PartitionedVisitedLink
+	err = sqliteutil.Query(h.db, `
+		SELECT l.id, l.link_url_id, u.url, l.top_level_url, l.frame_url, l.visit_count
+		FROM visited_links l LEFT JOIN urls u ON l.link_url_id = u.id
+		ORDER BY l.id`, func(rows *sql.Rows) error {
+		var l PartitionedVisitedLink
+		var url sql.NullString
+		if err := rows.Scan(&l.ID, &l.LinkURLID, &url, &l.TopLevelURL,
+			&l.FrameURL, &l.VisitCount); err != nil {
+			return err
+		}
+		l.LinkURL = url.String
+		links = append(links, l)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chrome: visited links: %w", err)
+	}
+	return links, nil
+}
+
+// ProfileVisitedLinks reads the visited links of a Chrome profile from
+// "Visited Links" and the visited_links table of "History". Profiles
+// may have both while partitioning is rolled out, in which case the
+// format is partitioned when the visited_links table has any links.
+// Files that fail to parse are handled as by ProfileVisitedLinksOptions
+// with the zero Options.
+func ProfileVisitedLinks(profileDir string) (*VisitedLinks, error) {
+	return ProfileVisitedLinksOptions(profileDir, browser.Options{})
+}
+
+// ProfileVisitedLinksOptions reads the visited links of a Chrome
+// profile and handles files that fail to parse by the error policy in
+// opts.
+func ProfileVisitedLinksOptions(profileDir string, opts browser.Options) (*VisitedLinks, error) {
+	eh := opts.Handler()
+	var links VisitedLinks
+	filename := filepath.Join(profileDir, VisitedLinksFile)
+	t, err := ParseVisitedLinks(filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		if err := eh.File(filename, err); err != nil {
+			return nil, err
+		}
+	}
+	links.Table = t
+	filename = filepath.Join(profileDir, "History")
+	h, err := OpenHistory(filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		if err := eh.File(filename, err); err != nil {
+			return nil, err
+		}
+	}
+	if h != nil {
+		defer h.Close()
+		if links.Partitioned, err = h.VisitedLinks(); err != nil {
+			if err := eh.File(filename, err); err != nil {
+				return nil, err
+			}
+		}
+	}
+	switch {
+	case len(links.Partitioned) != 0:
+		links.Format = VisitedLinksPartitioned
+	case links.Table != nil:
+		links.Format = VisitedLinksLegacy
+	}
+	return &links, eh.Err()
+}
+
+func (f VisitedLinksFormat) String() string {
+	switch f {
+	case VisitedLinksNone:
+		return "none"
+	case VisitedLinksLegacy:
+		return "legacy"
+	case VisitedLinksPartitioned:
+		return "partitioned"
+	default:
+		return fmt.Sprintf("format(%d)", uint8(f))
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeVisitedLinks writes a "Visited Links" file with the URLs, which
+// are inserted by linear probing, as by Chrome.
+func writeVisitedLinks(t *testing.T, filename string, length int, urls ...string) {
+	table := &VisitedLinkTable{Salt: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}, Fingerprints: make([]uint64, length)}
+	for _, u := range urls {
+		fp := table.Fingerprint(u)
+		i := int(fp % uint64(length))
+		for table.Fingerprints[i] != 0 {
+			i = (i + 1) % length
+		}
+		table.Fingerprints[i] = fp
+	}
+	data := make([]byte, visitedLinksHeaderSize+8*length)
+	copy(data, visitedLinksSignature)
+	binary.LittleEndian.PutUint32(data[4:], visitedLinksVersion)
+	binary.LittleEndian.PutUint32(data[8:], uint32(len(urls)))
+	binary.LittleEndian.PutUint32(data[12:], uint32(length))
+	copy(data[16:], table.Salt[:])
+	for i, fp := range table.Fingerprints {
+		binary.LittleEndian.PutUint64(data[visitedLinksHeaderSize+8*i:], fp)
+	}
+	if err := ioutil.WriteFile(filename, data, 0o666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVisitedLinksLegacy(t *testing.T) {
+	dir := t.TempDir()
+	visited := []string{"https://example.com/", "https://example.com/a", "https://example.org/"}
+	writeVisitedLinks(t, filepath.Join(dir, VisitedLinksFile), 5, visited...)
+	links, err := ProfileVisitedLinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if links.Format != VisitedLinksLegacy || links.Table == nil || links.Partitioned != nil {
+		t.Fatalf("got format %v, table %v, and %d partitioned links", links.Format, links.Table != nil, len(links.Partitioned))
+	}
+	if links.Table.Used != 3 || links.Table.Salt != [8]byte{1, 2, 3, 4, 5, 6, 7, 8} {
+		t.Errorf("got %d used with salt %v", links.Table.Used, links.Table.Salt)
+	}
+	for _, u := range visited {
+		if !links.Table.Contains(u) {
+			t.Errorf("does not contain %q", u)
+		}
+	}
+	for _, u := range []string{"https://example.com", "https://example.net/"} {
+		if links.Table.Contains(u) {
+			t.Errorf("contains %q", u)
+		}
+	}
+
+	writeVisitedLinks(t, filepath.Join(dir, VisitedLinksFile), 0)
+	if _, err := ParseVisitedLinks(filepath.Join(dir, VisitedLinksFile)); err != nil {
+		t.Errorf("empty table: %v", err)
+	}
+}
+
+func TestVisitedLinksPartitioned(t *testing.T) {
+	dir := t.TempDir()
+	writeVisitedLinks(t, filepath.Join(dir, VisitedLinksFile), 4, "https://example.com/")
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "History"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE urls (id INTEGER PRIMARY KEY AUTOINCREMENT, url LONGVARCHAR, title LONGVARCHAR,
+			visit_count INTEGER DEFAULT 0 NOT NULL, typed_count INTEGER DEFAULT 0 NOT NULL,
+			last_visit_time INTEGER NOT NULL, hidden INTEGER DEFAULT 0 NOT NULL);
+		CREATE TABLE visited_links (id INTEGER PRIMARY KEY AUTOINCREMENT, link_url_id INTEGER NOT NULL,
+			top_level_url LONGVARCHAR NOT NULL, frame_url LONGVARCHAR NOT NULL,
+			visit_count INTEGER DEFAULT 0 NOT NULL);
+		INSERT INTO urls VALUES (1, 'https://example.com/a', 'A', 2, 0, 13258087200000000, 0);
+		INSERT INTO visited_links VALUES
+			(1, 1, 'https://example.com/', 'https://example.com/', 1),
+			(2, 1, 'https://example.org/', 'https://ads.example.net/', 1),
+			(3, 9, 'https://example.org/', 'https://example.org/', 1);
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	links, err := ProfileVisitedLinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if links.Format != VisitedLinksPartitioned || links.Table == nil {
+		t.Errorf("got format %v and table %v", links.Format, links.Table != nil)
+	}
+	want := []PartitionedVisitedLink{
+		{ID: 1, LinkURLID: 1, LinkURL: "https://example.com/a", TopLevelURL: "https://example.com/", FrameURL: "https://example.com/", VisitCount: 1},
+		{ID: 2, LinkURLID: 1, LinkURL: "https://example.com/a", TopLevelURL: "https://example.org/", FrameURL: "https://ads.example.net/", VisitCount: 1},
+		{ID: 3, LinkURLID: 9, TopLevelURL: "https://example.org/", FrameURL: "https://example.org/", VisitCount: 1},
+	}
+	if !reflect.DeepEqual(links.Partitioned, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", links.Partitioned, want)
+	}
+}
+
+func TestVisitedLinksNone(t *testing.T) {
+	links, err := ProfileVisitedLinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if links.Format != VisitedLinksNone || links.Format.String() != "none" {
+		t.Errorf("got format %v", links.Format)
+	}
+}
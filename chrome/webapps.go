@@ -0,0 +1,337 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/protoutil"
+)
+
+// Web app registry format:
+// https://source.chromium.org/chromium/chromium/src/+/master:chrome/browser/web_applications/proto/web_app.proto
+// https://source.chromium.org/chromium/chromium/src/+/master:components/sync/protocol/web_app_specifics.proto
+// https://source.chromium.org/chromium/chromium/src/+/master:chrome/browser/web_applications/web_app_icon_manager.cc
+//
+// Installed web apps (PWAs) are stored in the "Sync Data/LevelDB"
+// database in a profile, with keys "web_apps-dt-{app_id}" and
+// WebAppProto values. Their icons are in "Web Applications/Manifest
+// Resources/{app_id}/Icons/{size}.png". Per-app settings are in
+// web_apps.web_app_ids in Preferences.
+//
+// The registry gains fields frequently, so fields not listed here are
+// skipped rather than rejected.
+
+// WebApp is a web app installed in a profile.
+type WebApp struct {
+	ID                 string // app ID, derived from the start URL by WebAppID
+	Name               string
+	Description        string
+	LaunchURL          string
+	Scope              string
+	ThemeColor         uint32 // ARGB; zero when unset
+	DisplayMode        WebAppDisplayMode
+	UserDisplayMode    WebAppDisplayMode // chosen by the user: browser tab or window
+	Sources            WebAppSource
+	IsLocallyInstalled bool
+	IsInSyncInstall    bool
+	Icons              []WebAppIcon // icons in the manifest
+	IconFiles          []string     // paths of downloaded icons, by size
+	Prefs              *WebAppPrefs // settings in Preferences, if any
+}
+
+// WebAppIcon is an icon in a web app manifest.
+type WebAppIcon struct {
+	URL  string
+	Size int // width and height in pixels; zero when unknown
+}
+
+// WebAppDisplayMode is the display mode of a web app, as in the
+// manifest display member.
+type WebAppDisplayMode uint8
+
+// Values for WebAppDisplayMode:
+const (
+	DisplayUnspecified WebAppDisplayMode = iota
+	DisplayBrowser
+	DisplayMinimalUI
+	DisplayStandalone
+	DisplayFullscreen
+)
+
+// WebAppSource is a set of the sources that installed a web app. An
+// app is uninstalled when it has no sources.
+type WebAppSource uint8
+
+// Values for WebAppSource:
+const (
+	SourceSystem      WebAppSource = 1 << iota // system web app, e.g. Settings on Chrome OS
+	SourcePolicy                               // WebAppInstallForceList policy
+	SourceWebAppStore                          // installed by the user
+	SourceSync                                 // installed on another device
+	SourceDefault                              // preinstalled
+)
+
+// ParseWebApps reads the installed web apps in a Chrome profile. Apps
+// are ordered by ID. Apps with settings in Preferences or icons in "Web
+// Applications", but not in the registry, such as those installed by
+// older versions, are included with only their ID and those fields.
+// Errors are handled as by ParseWebAppsOptions with the zero Options.
+func ParseWebApps(profileDir string) ([]WebApp, error) {
+	return ParseWebAppsOptions(profileDir, browser.Options{})
+}
+
+// ParseWebAppsOptions reads the installed web apps in a Chrome profile
+// and handles malformed apps in the registry, which are its records,
+// and files that fail to parse by the error policy in opts.
+func ParseWebAppsOptions(profileDir string, opts browser.Options) ([]WebApp, error) {
+	h := opts.Handler()
+	apps := make(map[string]*WebApp)
+	prefix := []byte("web_apps-dt-")
+	registry := filepath.Join(profileDir, "Sync Data", "LevelDB")
+	record, skipped := 0, false
+	err := walkLevelDB(registry, prefix, func(key, value []byte) error {
+		record++
+		if skipped {
+			return nil
+		}
+		app, err := parseWebApp(value)
+		if err != nil {
+			skipFile, err := h.Record(registry, record, fmt.Errorf("chrome: web app %s: %w", key[len(prefix):], err))
+			if skipFile {
+				apps, skipped = make(map[string]*WebApp), true
+			}
+			return err
+		}
+		app.ID = string(key[len(prefix):])
+		apps[app.ID] = app
+		return nil
+	})
+	var e *browser.Error
+	if errors.As(err, &e) {
+		return nil, err
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		if err := h.File(registry, err); err != nil {
+			return nil, err
+		}
+		apps = make(map[string]*WebApp)
+	}
+	get := func(id string) *WebApp {
+		app, ok := apps[id]
+		if !ok {
+			app = &WebApp{ID: id}
+			apps[id] = app
+		}
+		return app
+	}
+
+	filename := filepath.Join(profileDir, "Preferences")
+	prefs, err := ParsePreferences(filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		if err := h.File(filename, err); err != nil {
+			return nil, err
+		}
+	}
+	if prefs != nil {
+		for id, p := range prefs.WebApps.WebAppIDs {
+			p := p
+			get(id).Prefs = &p
+		}
+	}
+
+	resources := filepath.Join(profileDir, "Web Applications", "Manifest Resources")
+	dirs, err := os.ReadDir(resources)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		if err := h.File(resources, err); err != nil {
+			return nil, err
+		}
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		icons := filepath.Join(resources, dir.Name(), "Icons")
+		files, err := webAppIconFiles(icons)
+		if err != nil {
+			if err := h.File(icons, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if len(files) != 0 {
+			get(dir.Name()).IconFiles = files
+		}
+	}
+
+	list := make([]WebApp, 0, len(apps))
+	for _, app := range apps {
+		list = append(list, *app)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, h.Err()
+}
+
+// webAppIconFiles lists the downloaded icons of an app, which are named
+// by size in pixels, ordered by size.
+func webAppIconFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	type icon struct {
+		size int
+		path string
+	}
+	var icons []icon
+	for _, e := range entries {
+		size, err := strconv.Atoi(strings.TrimSuffix(e.Name(), ".png"))
+		if e.IsDir() || err != nil {
+			continue
+		}
+		icons = append(icons, icon{size, filepath.Join(dir, e.Name())})
+	}
+	sort.Slice(icons, func(i, j int) bool { return icons[i].size < icons[j].size })
+	files := make([]string, len(icons))
+	for i, icon := range icons {
+		files[i] = icon.path
+	}
+	return files, nil
+}
+
+func parseWebApp(b []byte) (*WebApp, error) {
+	var app WebApp
+	err := protoutil.Walk(b, "WebAppProto", func(f *protoutil.Field) error {
+		var err error
+		switch f.Num {
+		case 1:
+			err = parseWebAppSpecifics(f.Bytes, &app)
+		case 2:
+			app.Name = f.String()
+		case 3:
+			app.ThemeColor = uint32(f.Varint)
+		case 4:
+			app.Description = f.String()
+		case 5:
+			app.DisplayMode = WebAppDisplayMode(f.Varint)
+		case 6:
+			app.Scope = f.String()
+		case 7:
+			err = parseWebAppSources(f.Bytes, &app.Sources)
+		case 8:
+			app.IsLocallyInstalled = f.Bool()
+		case 9:
+			app.IsInSyncInstall = f.Bool()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+// parseWebAppSpecifics parses the synced fields of a web app. The
+// registry fields, parsed after, take precedence.
+func parseWebAppSpecifics(b []byte, app *WebApp) error {
+	return protoutil.Walk(b, "WebAppSpecifics", func(f *protoutil.Field) error {
+		switch f.Num {
+		case 1:
+			app.LaunchURL = f.String()
+		case 2:
+			if app.Name == "" {
+				app.Name = f.String()
+			}
+		case 3:
+			app.UserDisplayMode = WebAppDisplayMode(f.Varint)
+		case 4:
+			if app.ThemeColor == 0 {
+				app.ThemeColor = uint32(f.Varint)
+			}
+		case 7:
+			if app.Scope == "" {
+				app.Scope = f.String()
+			}
+		case 8:
+			var icon WebAppIcon
+			err := protoutil.Walk(f.Bytes, "WebAppIconInfo", func(f *protoutil.Field) error {
+				switch f.Num {
+				case 1:
+					icon.Size = f.Int()
+				case 2:
+					icon.URL = f.String()
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			app.Icons = append(app.Icons, icon)
+		}
+		return nil
+	})
+}
+
+func parseWebAppSources(b []byte, sources *WebAppSource) error {
+	return protoutil.Walk(b, "SourcesProto", func(f *protoutil.Field) error {
+		if f.Num >= 1 && f.Num <= 5 && f.Bool() {
+			*sources |= 1 << (f.Num - 1)
+		}
+		return nil
+	})
+}
+
+// WebAppID returns the app ID of a web app with the given start URL,
+// or manifest ID in newer versions. Like extension IDs, it is the first
+// 128 bits of the SHA-256 hash, in hexadecimal with the digits a-p.
+func WebAppID(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return encodeID(sum[:16])
+}
+
+func (m WebAppDisplayMode) String() string {
+	switch m {
+	case DisplayUnspecified:
+		return "unspecified"
+	case DisplayBrowser:
+		return "browser"
+	case DisplayMinimalUI:
+		return "minimal-ui"
+	case DisplayStandalone:
+		return "standalone"
+	case DisplayFullscreen:
+		return "fullscreen"
+	default:
+		return fmt.Sprintf("display_mode(%d)", uint8(m))
+	}
+}
+
+func (s WebAppSource) String() string {
+	if s == 0 {
+		return "none"
+	}
+	var names []string
+	for i, name := range []string{"system", "policy", "web_app_store", "sync", "default"} {
+		if s&(1<<i) != 0 {
+			names = append(names, name)
+			s &^= 1 << i
+		}
+	}
+	if s != 0 {
+		names = append(names, fmt.Sprintf("source(%d)", uint8(s)))
+	}
+	return strings.Join(names, "|")
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestParseWebApp(t *testing.T) {
+	var icon []byte
+	icon = protowire.AppendTag(icon, 1, protowire.VarintType)
+	icon = protowire.AppendVarint(icon, 192)
+	icon = protowire.AppendTag(icon, 2, protowire.BytesType)
+	icon = protowire.AppendString(icon, "https://example.com/icon.png")
+
+	var sync []byte
+	sync = protowire.AppendTag(sync, 1, protowire.BytesType)
+	sync = protowire.AppendString(sync, "https://example.com/app")
+	sync = protowire.AppendTag(sync, 2, protowire.BytesType)
+	sync = protowire.AppendString(sync, "Synced name")
+	sync = protowire.AppendTag(sync, 3, protowire.VarintType)
+	sync = protowire.AppendVarint(sync, uint64(DisplayStandalone))
+	sync = protowire.AppendTag(sync, 8, protowire.BytesType)
+	sync = protowire.AppendBytes(sync, icon)
+
+	var sources []byte
+	sources = protowire.AppendTag(sources, 3, protowire.VarintType)
+	sources = protowire.AppendVarint(sources, 1)
+	sources = protowire.AppendTag(sources, 4, protowire.VarintType)
+	sources = protowire.AppendVarint(sources, 1)
+
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendBytes(b, sync)
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendString(b, "Example")
+	b = protowire.AppendTag(b, 3, protowire.VarintType)
+	b = protowire.AppendVarint(b, 0xff336699)
+	b = protowire.AppendTag(b, 5, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(DisplayMinimalUI))
+	b = protowire.AppendTag(b, 6, protowire.BytesType)
+	b = protowire.AppendString(b, "https://example.com/")
+	b = protowire.AppendTag(b, 7, protowire.BytesType)
+	b = protowire.AppendBytes(b, sources)
+	b = protowire.AppendTag(b, 8, protowire.VarintType)
+	b = protowire.AppendVarint(b, 1)
+	b = protowire.AppendTag(b, 99, protowire.VarintType) // newer field
+	b = protowire.AppendVarint(b, 1)
+
+	app, err := parseWebApp(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.Name != "Example" || app.LaunchURL != "https://example.com/app" || app.Scope != "https://example.com/" {
+		t.Errorf("got name %q, launch URL %q, and scope %q", app.Name, app.LaunchURL, app.Scope)
+	}
+	if app.ThemeColor != 0xff336699 {
+		t.Errorf("got theme color %#x, want 0xff336699", app.ThemeColor)
+	}
+	if app.DisplayMode != DisplayMinimalUI || app.UserDisplayMode != DisplayStandalone {
+		t.Errorf("got display mode %s and user display mode %s", app.DisplayMode, app.UserDisplayMode)
+	}
+	if app.Sources != SourceWebAppStore|SourceSync {
+		t.Errorf("got sources %s, want web_app_store|sync", app.Sources)
+	}
+	if !app.IsLocallyInstalled || app.IsInSyncInstall {
+		t.Errorf("got locally installed %t and in sync install %t", app.IsLocallyInstalled, app.IsInSyncInstall)
+	}
+	if len(app.Icons) != 1 || app.Icons[0] != (WebAppIcon{"https://example.com/icon.png", 192}) {
+		t.Errorf("got icons %v", app.Icons)
+	}
+}
+
+func TestParseWebApps(t *testing.T) {
+	dir := t.TempDir()
+	id := WebAppID("https://example.com/")
+	prefs := `{"web_apps":{"web_app_ids":{"` + id + `":{"latest_web_app_install_source":10}}}}`
+	if err := os.WriteFile(filepath.Join(dir, "Preferences"), []byte(prefs), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	icons := filepath.Join(dir, "Web Applications", "Manifest Resources", id, "Icons")
+	if err := os.MkdirAll(icons, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"192.png", "32.png", "512.png"} {
+		if err := os.WriteFile(filepath.Join(icons, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	apps, err := ParseWebApps(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apps) != 1 || apps[0].ID != id {
+		t.Fatalf("got apps %v", apps)
+	}
+	if p := apps[0].Prefs; p == nil || p.LatestInstallSource == nil || *p.LatestInstallSource != 10 {
+		t.Errorf("got prefs %+v", p)
+	}
+	want := []string{filepath.Join(icons, "32.png"), filepath.Join(icons, "192.png"), filepath.Join(icons, "512.png")}
+	if got := apps[0].IconFiles; len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("got icon files %v, want %v", got, want)
+	}
+}
+
+func TestWebAppID(t *testing.T) {
+	// The ID is computed the same way as for extensions, so the ID alphabet
+	// is limited to a-p.
+	id := WebAppID("https://example.com/")
+	if len(id) != 32 {
+		t.Fatalf("got ID %q of length %d, want 32", id, len(id))
+	}
+	for _, c := range id {
+		if c < 'a' || c > 'p' {
+			t.Errorf("got ID %q with digit %q outside a-p", id, c)
+			break
+		}
+	}
+	if WebAppID("https://example.com/") != id || WebAppID("https://example.org/") == id {
+		t.Error("ID not deterministic per URL")
+	}
+}
@@ -0,0 +1,482 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/secret"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Web Data schema:
+// https://source.chromium.org/chromium/chromium/src/+/master:components/search_engines/keyword_table.cc
+// https://source.chromium.org/chromium/chromium/src/+/master:components/autofill/core/browser/webdata/autofill_table.cc
+//
+// Times in the keywords table are Chrome times, but times in the
+// autofill tables are Unix seconds.
+
+// WebDataFile is the database in a profile with autofill data and
+// search engines.
+const WebDataFile = "Web Data"
+
+// Keyword is a search engine in the keywords table of Web Data. URL
+// templates use the OpenSearch syntax, with "{searchTerms}"
+// substituted by the query, and Chrome-specific parameters like
+// "{google:baseURL}".
+type Keyword struct {
+	ID                 int64
+	ShortName          string // name, e.g. "Google"
+	Keyword            string // e.g. "google.com"
+	FaviconURL         string
+	URL                string
+	SafeForAutoreplace bool // may be replaced by an engine of the same keyword
+	OriginatingURL     string
+	DateCreated        time.Time
+	UsageCount         int
+	InputEncodings     []string // e.g. "UTF-8"
+	SuggestURL         string
+	PrepopulateID      int // nonzero for engines bundled with Chrome
+	CreatedByPolicy    bool
+	LastModified       time.Time
+	SyncGUID           string
+	AlternateURLs      []string
+	NewTabURL          string
+	LastVisited        time.Time // zero before Chrome 69
+	IsActive           KeywordActive
+	StarterPackID      int // nonzero for built-in "@" keywords, like "@bookmarks"
+}
+
+// KeywordActive is whether a search engine is active for use in the
+// omnibox, since Chrome 97.
+type KeywordActive uint8
+
+// Values for KeywordActive:
+const (
+	KeywordActiveUnspecified KeywordActive = 0
+	KeywordActiveTrue        KeywordActive = 1
+	KeywordActiveFalse       KeywordActive = 2
+)
+
+// WebData is the autofill data and search engines in a Web Data
+// database. Tables that are not in the database, such as
+// autofill_profiles after Chrome 120 replaced it with local_addresses,
+// are nil.
+type WebData struct {
+	Autofill         []AutofillEntry
+	AutofillProfiles []AutofillProfile
+	CreditCards      []CreditCard
+	Keywords         []Keyword
+}
+
+// AutofillEntry is a value entered in a form field, in the autofill
+// table, which is suggested when filling fields of the same name.
+type AutofillEntry struct {
+	Name         string // name or id of the field
+	Value        string
+	DateCreated  time.Time
+	DateLastUsed time.Time
+	Count        int // times used
+}
+
+// AutofillProfile is a saved address in the autofill_profiles table,
+// with its names, emails, and phone numbers from the
+// autofill_profile_names, autofill_profile_emails, and
+// autofill_profile_phones tables.
+type AutofillProfile struct {
+	GUID              string
+	CompanyName       string
+	StreetAddress     string // lines separated by "\n"
+	DependentLocality string
+	City              string
+	State             string
+	Zipcode           string
+	SortingCode       string
+	CountryCode       string // ISO 3166-1 alpha-2 code, e.g. "US"
+	DateModified      time.Time
+	Origin            string // URL of the form or "chrome://settings"
+	LanguageCode      string
+	UseCount          int
+	UseDate           time.Time
+	Names             []AutofillName
+	Emails            []string
+	Phones            []string
+}
+
+// AutofillName is a name of an autofill profile.
+type AutofillName struct {
+	First  string
+	Middle string
+	Last   string
+	Full   string
+}
+
+// CreditCard is a saved credit card in the credit_cards table.
+type CreditCard struct {
+	GUID                string
+	NameOnCard          string
+	ExpirationMonth     int
+	ExpirationYear      int
+	CardNumberEncrypted []byte // encrypted with os_crypt; see DecryptNumber
+	DateModified        time.Time
+	Origin              string
+	UseCount            int
+	UseDate             time.Time
+	BillingAddressID    string // GUID of an autofill profile
+	Nickname            string
+}
+
+// ParseKeywords parses the search engines in the keywords table of a
+// Web Data database. Keywords are ordered by ID.
+func ParseKeywords(filename string) ([]Keyword, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return queryKeywords(db)
+}
+
+func queryKeywords(db *sql.DB) ([]Keyword, error) {
+	optional, err := optionalColumns(db, "keywords")
+	if err != nil {
+		return nil, fmt.Errorf("chrome: keywords: %w", err)
+	}
+	var keywords []Keyword
+	err = sqliteutil.Query(db, `
+		SELECT id, short_name, keyword, favicon_url, url, safe_for_autoreplace,
+			originating_url, date_created, usage_count, input_encodings,
+			suggest_url, prepopulate_id, created_by_policy, last_modified,
+			sync_guid, alternate_urls, new_tab_url, `+optional("last_visited", "0")+`,
+			`+optional("is_active", "0")+`, `+optional("starter_pack_id", "0")+`
+		FROM keywords
+		ORDER BY id`, func(rows *sql.Rows) error {
+		var k Keyword
+		var favicon, originating, encodings, suggest, guid, alternate, newTab sql.NullString
+		var created, modified, visited int64
+		if err := rows.Scan(&k.ID, &k.ShortName, &k.Keyword, &favicon, &k.URL, &k.SafeForAutoreplace,
+			&originating, &created, &k.UsageCount, &encodings,
+			&suggest, &k.PrepopulateID, &k.CreatedByPolicy, &modified,
+			&guid, &alternate, &newTab, &visited,
+			&k.IsActive, &k.StarterPackID); err != nil {
+			return err
+		}
+		k.FaviconURL = favicon.String
+		k.OriginatingURL = originating.String
+		k.SuggestURL = suggest.String
+		k.SyncGUID = guid.String
+		k.NewTabURL = newTab.String
+		if encodings.String != "" {
+			k.InputEncodings = strings.Split(encodings.String, ";")
+		}
+		if alternate.String != "" {
+			if err := json.Unmarshal([]byte(alternate.String), &k.AlternateURLs); err != nil {
+				return fmt.Errorf("keyword %d: alternate URLs: %w", k.ID, err)
+			}
+		}
+		var err error
+		if k.DateCreated, err = chromeTime(created); err != nil {
+			return err
+		}
+		if k.LastModified, err = chromeTime(modified); err != nil {
+			return err
+		}
+		if k.LastVisited, err = chromeTime(visited); err != nil {
+			return err
+		}
+		keywords = append(keywords, k)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chrome: keywords: %w", err)
+	}
+	return keywords, nil
+}
+
+// IsCustom reports whether the search engine was added by the user or
+// by site discovery, rather than bundled with Chrome or set by policy.
+func (k *Keyword) IsCustom() bool {
+	return k.PrepopulateID == 0 && k.StarterPackID == 0 && !k.CreatedByPolicy
+}
+
+// ParseWebData parses the autofill entries, autofill profiles, credit
+// cards, and search engines in a Web Data database.
+func ParseWebData(filename string) (*WebData, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var wd WebData
+	for _, t := range []struct {
+		table string
+		query func() error
+	}{
+		{"autofill", func() (err error) { wd.Autofill, err = queryAutofill(db); return }},
+		{"autofill_profiles", func() (err error) { wd.AutofillProfiles, err = queryAutofillProfiles(db); return }},
+		{"credit_cards", func() (err error) { wd.CreditCards, err = queryCreditCards(db); return }},
+		{"keywords", func() (err error) { wd.Keywords, err = queryKeywords(db); return }},
+	} {
+		ok, err := sqliteutil.HasTable(db, t.table)
+		if err != nil {
+			return nil, fmt.Errorf("chrome: %s: %w", t.table, err)
+		}
+		if !ok {
+			continue
+		}
+		if err := t.query(); err != nil {
+			return nil, err
+		}
+	}
+	return &wd, nil
+}
+
+// ParseAutofill parses the values entered in form fields in the
+// autofill table of a Web Data database. Entries are ordered by name
+// and value.
+func ParseAutofill(filename string) ([]AutofillEntry, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return queryAutofill(db)
+}
+
+func queryAutofill(db *sql.DB) ([]AutofillEntry, error) {
+	var entries []AutofillEntry
+	err := sqliteutil.Query(db, `
+		SELECT name, value, date_created, date_last_used, count
+		FROM autofill
+		ORDER BY name, value`, func(rows *sql.Rows) error {
+		var e AutofillEntry
+		var created, lastUsed int64
+		if err := rows.Scan(&e.Name, &e.Value, &created, &lastUsed, &e.Count); err != nil {
+			return err
+		}
+		e.DateCreated = timefmt.FromInt(created, 0, timefmt.Sec, timefmt.Unix)
+		e.DateLastUsed = timefmt.FromInt(lastUsed, 0, timefmt.Sec, timefmt.Unix)
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chrome: autofill: %w", err)
+	}
+	return entries, nil
+}
+
+// ParseAutofillProfiles parses the saved addresses in the
+// autofill_profiles table of a Web Data database. Profiles are ordered
+// by GUID.
+func ParseAutofillProfiles(filename string) ([]AutofillProfile, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return queryAutofillProfiles(db)
+}
+
+func queryAutofillProfiles(db *sql.DB) ([]AutofillProfile, error) {
+	optional, err := optionalColumns(db, "autofill_profiles")
+	if err != nil {
+		return nil, fmt.Errorf("chrome: autofill profiles: %w", err)
+	}
+	var profiles []AutofillProfile
+	index := make(map[string]int)
+	err = sqliteutil.Query(db, `
+		SELECT guid, company_name, street_address, dependent_locality, city,
+			state, zipcode, sorting_code, country_code, date_modified, origin,
+			`+optional("language_code", "''")+`, `+optional("use_count", "0")+`,
+			`+optional("use_date", "0")+`
+		FROM autofill_profiles
+		ORDER BY guid`, func(rows *sql.Rows) error {
+		var p AutofillProfile
+		var company, street, locality, city, state, zip, sorting, country, origin, lang sql.NullString
+		var modified, used int64
+		if err := rows.Scan(&p.GUID, &company, &street, &locality, &city,
+			&state, &zip, &sorting, &country, &modified, &origin,
+			&lang, &p.UseCount, &used); err != nil {
+			return err
+		}
+		p.CompanyName = company.String
+		p.StreetAddress = street.String
+		p.DependentLocality = locality.String
+		p.City = city.String
+		p.State = state.String
+		p.Zipcode = zip.String
+		p.SortingCode = sorting.String
+		p.CountryCode = country.String
+		p.Origin = origin.String
+		p.LanguageCode = lang.String
+		p.DateModified = timefmt.FromInt(modified, 0, timefmt.Sec, timefmt.Unix)
+		p.UseDate = timefmt.FromInt(used, 0, timefmt.Sec, timefmt.Unix)
+		index[p.GUID] = len(profiles)
+		profiles = append(profiles, p)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chrome: autofill profiles: %w", err)
+	}
+
+	// Names, emails, and phones are in tables keyed by the profile GUID,
+	// in the order that they were added.
+	ok, err := sqliteutil.HasTable(db, "autofill_profile_names")
+	if err == nil && ok {
+		err = queryAutofillNames(db, profiles, index)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("chrome: autofill_profile_names: %w", err)
+	}
+	for _, t := range []struct {
+		table, col string
+		field      func(p *AutofillProfile) *[]string
+	}{
+		{"autofill_profile_emails", "email", func(p *AutofillProfile) *[]string { return &p.Emails }},
+		{"autofill_profile_phones", "number", func(p *AutofillProfile) *[]string { return &p.Phones }},
+	} {
+		ok, err := sqliteutil.HasTable(db, t.table)
+		if err == nil && ok {
+			err = sqliteutil.Query(db, `
+				SELECT guid, `+t.col+` FROM `+t.table+` ORDER BY rowid`, func(rows *sql.Rows) error {
+				var guid string
+				var value sql.NullString
+				if err := rows.Scan(&guid, &value); err != nil {
+					return err
+				}
+				if i, ok := index[guid]; ok && value.String != "" {
+					field := t.field(&profiles[i])
+					*field = append(*field, value.String)
+				}
+				return nil
+			})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("chrome: %s: %w", t.table, err)
+		}
+	}
+	return profiles, nil
+}
+
+func queryAutofillNames(db *sql.DB, profiles []AutofillProfile, index map[string]int) error {
+	optional, err := optionalColumns(db, "autofill_profile_names")
+	if err != nil {
+		return err
+	}
+	return sqliteutil.Query(db, `
+		SELECT guid, first_name, middle_name, last_name, `+optional("full_name", "''")+`
+		FROM autofill_profile_names
+		ORDER BY rowid`, func(rows *sql.Rows) error {
+		var guid string
+		var first, middle, last, full sql.NullString
+		if err := rows.Scan(&guid, &first, &middle, &last, &full); err != nil {
+			return err
+		}
+		if i, ok := index[guid]; ok {
+			profiles[i].Names = append(profiles[i].Names,
+				AutofillName{first.String, middle.String, last.String, full.String})
+		}
+		return nil
+	})
+}
+
+// ParseCreditCards parses the saved credit cards in the credit_cards
+// table of a Web Data database. Cards are ordered by GUID.
+func ParseCreditCards(filename string) ([]CreditCard, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return queryCreditCards(db)
+}
+
+func queryCreditCards(db *sql.DB) ([]CreditCard, error) {
+	optional, err := optionalColumns(db, "credit_cards")
+	if err != nil {
+		return nil, fmt.Errorf("chrome: credit cards: %w", err)
+	}
+	var cards []CreditCard
+	err = sqliteutil.Query(db, `
+		SELECT guid, name_on_card, expiration_month, expiration_year,
+			card_number_encrypted, date_modified, origin,
+			`+optional("use_count", "0")+`, `+optional("use_date", "0")+`,
+			`+optional("billing_address_id", "''")+`, `+optional("nickname", "''")+`
+		FROM credit_cards
+		ORDER BY guid`, func(rows *sql.Rows) error {
+		var c CreditCard
+		var name, origin, billing, nickname sql.NullString
+		var modified, used int64
+		if err := rows.Scan(&c.GUID, &name, &c.ExpirationMonth, &c.ExpirationYear,
+			&c.CardNumberEncrypted, &modified, &origin,
+			&c.UseCount, &used, &billing, &nickname); err != nil {
+			return err
+		}
+		c.NameOnCard = name.String
+		c.Origin = origin.String
+		c.BillingAddressID = billing.String
+		c.Nickname = nickname.String
+		c.DateModified = timefmt.FromInt(modified, 0, timefmt.Sec, timefmt.Unix)
+		c.UseDate = timefmt.FromInt(used, 0, timefmt.Sec, timefmt.Unix)
+		cards = append(cards, c)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chrome: credit cards: %w", err)
+	}
+	return cards, nil
+}
+
+// DecryptNumber decrypts the card number with a key from kp.
+func (c *CreditCard) DecryptNumber(kp KeyProvider) (secret.Secret, error) {
+	if len(c.CardNumberEncrypted) == 0 {
+		return "", nil
+	}
+	plain, err := decryptOSCrypt(kp, c.CardNumberEncrypted)
+	if err != nil {
+		return "", err
+	}
+	return secret.Secret(plain), nil
+}
+
+// optionalColumns returns a function that selects a column of a table
+// when it exists, or else the default expression, for columns that
+// were added in later versions.
+func optionalColumns(db *sql.DB, table string) (func(col, def string) string, error) {
+	cols, err := sqliteutil.Columns(db, table)
+	if err != nil {
+		return nil, err
+	}
+	has := make(map[string]bool, len(cols))
+	for _, col := range cols {
+		has[col] = true
+	}
+	return func(col, def string) string {
+		if has[col] {
+			return col
+		}
+		return def
+	}, nil
+}
+
+func (active KeywordActive) String() string {
+	switch active {
+	case KeywordActiveUnspecified:
+		return "unspecified"
+	case KeywordActiveTrue:
+		return "true"
+	case KeywordActiveFalse:
+		return "false"
+	default:
+		return fmt.Sprintf("active(%d)", uint8(active))
+	}
+}
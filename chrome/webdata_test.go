@@ -0,0 +1,181 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package chrome
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseKeywords(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), WebDataFile)
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Schema before Chrome 97, without is_active and starter_pack_id
+	_, err = db.Exec(`
+		CREATE TABLE keywords (id INTEGER PRIMARY KEY, short_name VARCHAR NOT NULL,
+			keyword VARCHAR NOT NULL, favicon_url VARCHAR NOT NULL, url VARCHAR NOT NULL,
+			safe_for_autoreplace INTEGER, originating_url VARCHAR, date_created INTEGER DEFAULT 0,
+			usage_count INTEGER DEFAULT 0, input_encodings VARCHAR, suggest_url VARCHAR,
+			prepopulate_id INTEGER DEFAULT 0, created_by_policy INTEGER DEFAULT 0,
+			last_modified INTEGER DEFAULT 0, sync_guid VARCHAR, alternate_urls VARCHAR,
+			image_url VARCHAR, search_url_post_params VARCHAR, suggest_url_post_params VARCHAR,
+			image_url_post_params VARCHAR, new_tab_url VARCHAR, last_visited INTEGER DEFAULT 0);
+		INSERT INTO keywords VALUES
+			(2, 'Google', 'google.com', 'https://www.google.com/favicon.ico',
+				'{google:baseURL}search?q={searchTerms}', 1, '', 0, 0, 'UTF-8',
+				'{google:baseSuggestURL}search?q={searchTerms}', 1, 0, 0, 'guid-google',
+				'["{google:baseURL}#q={searchTerms}"]', '', '', '', '', '', 0),
+			(7, 'Wiki', 'w', '', 'https://en.wikipedia.org/w/index.php?search={searchTerms}', 0, NULL,
+				13258000000000000, 3, 'UTF-8;ISO-8859-1', NULL, 0, 0, 13258000001000000, 'guid-wiki',
+				'[]', NULL, NULL, NULL, NULL, NULL, 13258000002000000);
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keywords, err := ParseKeywords(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Keyword{{
+		ID:                 2,
+		ShortName:          "Google",
+		Keyword:            "google.com",
+		FaviconURL:         "https://www.google.com/favicon.ico",
+		URL:                "{google:baseURL}search?q={searchTerms}",
+		SafeForAutoreplace: true,
+		DateCreated:        chromeTestTime(0),
+		InputEncodings:     []string{"UTF-8"},
+		SuggestURL:         "{google:baseSuggestURL}search?q={searchTerms}",
+		PrepopulateID:      1,
+		LastModified:       chromeTestTime(0),
+		SyncGUID:           "guid-google",
+		AlternateURLs:      []string{"{google:baseURL}#q={searchTerms}"},
+		LastVisited:        chromeTestTime(0),
+	}, {
+		ID:             7,
+		ShortName:      "Wiki",
+		Keyword:        "w",
+		URL:            "https://en.wikipedia.org/w/index.php?search={searchTerms}",
+		DateCreated:    chromeTestTime(13258000000000000),
+		UsageCount:     3,
+		InputEncodings: []string{"UTF-8", "ISO-8859-1"},
+		LastModified:   chromeTestTime(13258000001000000),
+		SyncGUID:       "guid-wiki",
+		AlternateURLs:  []string{},
+		LastVisited:    chromeTestTime(13258000002000000),
+	}}
+	if !reflect.DeepEqual(keywords, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", keywords, want)
+	}
+	if keywords[0].IsCustom() || !keywords[1].IsCustom() {
+		t.Errorf("got custom %t and %t, want false and true", keywords[0].IsCustom(), keywords[1].IsCustom())
+	}
+}
+
+func TestParseWebData(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), WebDataFile)
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := bytes.Repeat([]byte{1}, 16)
+	number := encryptCBC("v10", key, []byte("4111111111111111"))
+	// Schema of Chrome 90, without keywords, and autofill_profile_names
+	// before full_name was added
+	_, err = db.Exec(`
+		CREATE TABLE autofill (name VARCHAR, value VARCHAR, value_lower VARCHAR,
+			date_created INTEGER DEFAULT 0, date_last_used INTEGER DEFAULT 0,
+			count INTEGER DEFAULT 1, PRIMARY KEY (name, value));
+		CREATE TABLE autofill_profiles (guid VARCHAR PRIMARY KEY, company_name VARCHAR,
+			street_address VARCHAR, dependent_locality VARCHAR, city VARCHAR, state VARCHAR,
+			zipcode VARCHAR, sorting_code VARCHAR, country_code VARCHAR,
+			date_modified INTEGER NOT NULL DEFAULT 0, origin VARCHAR DEFAULT '',
+			language_code VARCHAR, use_count INTEGER NOT NULL DEFAULT 0,
+			use_date INTEGER NOT NULL DEFAULT 0);
+		CREATE TABLE autofill_profile_names (guid VARCHAR, first_name VARCHAR,
+			middle_name VARCHAR, last_name VARCHAR);
+		CREATE TABLE autofill_profile_emails (guid VARCHAR, email VARCHAR);
+		CREATE TABLE autofill_profile_phones (guid VARCHAR, number VARCHAR);
+		CREATE TABLE credit_cards (guid VARCHAR PRIMARY KEY, name_on_card VARCHAR,
+			expiration_month INTEGER, expiration_year INTEGER, card_number_encrypted BLOB,
+			date_modified INTEGER NOT NULL DEFAULT 0, origin VARCHAR DEFAULT '',
+			use_count INTEGER NOT NULL DEFAULT 0, use_date INTEGER NOT NULL DEFAULT 0,
+			billing_address_id VARCHAR);
+		INSERT INTO autofill VALUES
+			('q', 'golang', 'golang', 1613610123, 1613610124, 2),
+			('email', 'jane@example.com', 'jane@example.com', 1613610000, 1613610000, 1);
+		INSERT INTO autofill_profiles VALUES
+			('p1', 'Example', '1 Main St
+Apt 2', '', 'Springfield', 'IL', '62701', '', 'US', 1613610125, 'chrome://settings', 'en', 4, 1613610126);
+		INSERT INTO autofill_profile_names VALUES ('p1', 'Jane', '', 'Doe'), ('p1', 'J', '', 'Doe'), ('p2', 'X', '', 'Y');
+		INSERT INTO autofill_profile_emails VALUES ('p1', 'jane@example.com'), ('p1', '');
+		INSERT INTO autofill_profile_phones VALUES ('p1', '+15555550100');
+		INSERT INTO credit_cards VALUES
+			('c1', 'Jane Doe', 12, 2025, ?, 1613610127, '', 1, 1613610128, 'p1');
+	`, number)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := ParseWebData(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec := func(s int64) time.Time { return time.Unix(s, 0).UTC() }
+	want := &WebData{
+		Autofill: []AutofillEntry{
+			{Name: "email", Value: "jane@example.com", DateCreated: sec(1613610000), DateLastUsed: sec(1613610000), Count: 1},
+			{Name: "q", Value: "golang", DateCreated: sec(1613610123), DateLastUsed: sec(1613610124), Count: 2},
+		},
+		AutofillProfiles: []AutofillProfile{{
+			GUID:          "p1",
+			CompanyName:   "Example",
+			StreetAddress: "1 Main St\nApt 2",
+			City:          "Springfield",
+			State:         "IL",
+			Zipcode:       "62701",
+			CountryCode:   "US",
+			DateModified:  sec(1613610125),
+			Origin:        "chrome://settings",
+			LanguageCode:  "en",
+			UseCount:      4,
+			UseDate:       sec(1613610126),
+			Names:         []AutofillName{{First: "Jane", Last: "Doe"}, {First: "J", Last: "Doe"}},
+			Emails:        []string{"jane@example.com"},
+			Phones:        []string{"+15555550100"},
+		}},
+		CreditCards: []CreditCard{{
+			GUID:                "c1",
+			NameOnCard:          "Jane Doe",
+			ExpirationMonth:     12,
+			ExpirationYear:      2025,
+			CardNumberEncrypted: number,
+			DateModified:        sec(1613610127),
+			UseCount:            1,
+			UseDate:             sec(1613610128),
+			BillingAddressID:    "p1",
+		}},
+	}
+	if !reflect.DeepEqual(wd, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", wd, want)
+	}
+	plain, err := wd.CreditCards[0].DecryptNumber(StaticKey(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.Reveal() != "4111111111111111" {
+		t.Errorf("got number %q", plain.Reveal())
+	}
+}
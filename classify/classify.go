@@ -0,0 +1,132 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package classify classifies URLs during analysis, so that reports can
+// exclude noise, such as ad, tracker, beacon, and redirect URLs, from
+// visit counts and group pages by category.
+//
+// Classifiers are composed with Chain. Classifiers registered with
+// Register are applied globally, by analyses that are not given a
+// classifier, through Default. Filter lists in Adblock Plus syntax
+// classify noise and user-provided rules assign categories.
+package classify
+
+import (
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// Result is the classification of a URL.
+type Result struct {
+	Noise      bool     // not a page of interest, e.g. an ad, tracker, beacon, or redirect
+	Categories []string // e.g. "news" or "shopping"; sorted and unique
+}
+
+// Classifier classifies URLs. Classify must be safe to call
+// concurrently.
+type Classifier interface {
+	Classify(u *url.URL) Result
+}
+
+// Func adapts a function to a Classifier.
+type Func func(u *url.URL) Result
+
+// Classify calls f(u).
+func (f Func) Classify(u *url.URL) Result { return f(u) }
+
+// Chain is a Classifier that merges the results of classifiers: a URL
+// is noise when any classifier finds it to be noise and has the
+// categories of all of them.
+type Chain []Classifier
+
+// Classify merges the results of the classifiers.
+func (c Chain) Classify(u *url.URL) Result {
+	var r Result
+	for _, classifier := range c {
+		r = merge(r, classifier.Classify(u))
+	}
+	return r
+}
+
+// URL parses and classifies a URL. Invalid URLs are not classified.
+func URL(c Classifier, rawURL string) Result {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Result{}
+	}
+	return c.Classify(u)
+}
+
+func merge(a, b Result) Result {
+	a.Noise = a.Noise || b.Noise
+	if len(b.Categories) == 0 {
+		return a
+	}
+	categories := append(append([]string(nil), a.Categories...), b.Categories...)
+	a.Categories = sortUnique(categories)
+	return a
+}
+
+func sortUnique(s []string) []string {
+	sort.Strings(s)
+	unique := s[:0]
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+var registry struct {
+	mu    sync.RWMutex
+	names []string
+	hooks map[string]Classifier
+}
+
+// Register registers a classifier to be applied globally, replacing
+// any registered with the same name. Classifiers are applied in the
+// order that they were first registered.
+func Register(name string, c Classifier) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if registry.hooks == nil {
+		registry.hooks = make(map[string]Classifier)
+	}
+	if _, ok := registry.hooks[name]; !ok {
+		registry.names = append(registry.names, name)
+	}
+	registry.hooks[name] = c
+}
+
+// Unregister removes the classifier registered with the name, if any.
+func Unregister(name string) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if _, ok := registry.hooks[name]; !ok {
+		return
+	}
+	delete(registry.hooks, name)
+	for i, n := range registry.names {
+		if n == name {
+			registry.names = append(registry.names[:i:i], registry.names[i+1:]...)
+			break
+		}
+	}
+}
+
+// Default returns the registered classifiers, as a Chain. Classifiers
+// registered later are not included.
+func Default() Chain {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	c := make(Chain, len(registry.names))
+	for i, name := range registry.names {
+		c[i] = registry.hooks[name]
+	}
+	return c
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package classify
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader(`
+# category  pattern
+news        example.com
+tech        example.com/tech/
+news        news.example.org
+noise       example.com/r/
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	beacons := Func(func(u *url.URL) Result {
+		return Result{Noise: strings.HasSuffix(u.Path, "/beacon"), Categories: []string{"web"}}
+	})
+	c := Chain{rules, beacons}
+	tests := []struct {
+		url  string
+		want Result
+	}{
+		{"https://www.example.com/tech/go", Result{Categories: []string{"news", "tech", "web"}}},
+		{"https://example.com/r/?u=https://example.org/", Result{Noise: true, Categories: []string{"news", "web"}}},
+		{"https://news.example.org/beacon", Result{Noise: true, Categories: []string{"news", "web"}}},
+		{"https://notexample.com/", Result{Categories: []string{"web"}}},
+	}
+	for _, tt := range tests {
+		if got := URL(c, tt.url); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("URL(%q) = %+v, want %+v", tt.url, got, tt.want)
+		}
+	}
+	if got := URL(c, "%zz"); !reflect.DeepEqual(got, Result{}) {
+		t.Errorf("got %+v for invalid URL", got)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	noise := Func(func(u *url.URL) Result { return Result{Noise: true} })
+	news := Func(func(u *url.URL) Result { return Result{Categories: []string{"news"}} })
+	Register("test-noise", noise)
+	Register("test-news", news)
+	defer Unregister("test-news")
+	if got := URL(Default(), "https://example.com/"); !reflect.DeepEqual(got, Result{true, []string{"news"}}) {
+		t.Errorf("got %+v", got)
+	}
+	Unregister("test-noise")
+	if got := URL(Default(), "https://example.com/"); !reflect.DeepEqual(got, Result{false, []string{"news"}}) {
+		t.Errorf("got %+v after unregistering", got)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package classify
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Filter list syntax:
+// https://help.eyeo.com/adblockplus/how-to-write-filters
+//
+// Only network filters that apply to a URL regardless of the page that
+// requested it are used: element hiding filters, regular expression
+// filters, and filters restricted to domains or with options that
+// rewrite requests are skipped. Resource type and party options are
+// ignored, since the URL of a tracker is noise however it was loaded.
+//
+// Filters anchored to a domain, like "||tracker.example^", which are
+// most of a typical list, are indexed by domain and match the rest of
+// the URL with a regular expression. Other filters match the whole URL.
+
+// FilterList classifies the URLs blocked by a filter list, such as
+// EasyList or EasyPrivacy, as noise. URLs that match an exception
+// filter are not noise.
+type FilterList struct {
+	block, allow filterSet
+}
+
+type filterSet struct {
+	domains map[string][]*regexp.Regexp // key: domain of a "||" filter; matches the URL after the host
+	generic []*regexp.Regexp            // matches the whole URL
+}
+
+// ignoredOptions are the filter options that do not restrict the URLs
+// that a filter matches.
+var ignoredOptions = map[string]bool{
+	"all": true, "css": true, "doc": true, "document": true, "font": true,
+	"frame": true, "image": true, "important": true, "media": true,
+	"object": true, "other": true, "ping": true, "popup": true,
+	"script": true, "stylesheet": true, "subdocument": true,
+	"websocket": true, "xhr": true, "xmlhttprequest": true,
+	"1p": true, "3p": true, "first-party": true, "third-party": true,
+	"match-case": true,
+}
+
+var optionsPattern = regexp.MustCompile(`^~?[a-z0-9-]+(=[^,]*)?(,~?[a-z0-9-]+(=[^,]*)?)*$`)
+
+// LoadFilterList reads a filter list file.
+func LoadFilterList(filename string) (*FilterList, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseFilterList(f)
+}
+
+// ParseFilterList parses a filter list. Unsupported filters are
+// skipped.
+func ParseFilterList(r io.Reader) (*FilterList, error) {
+	var l FilterList
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		if err := l.add(strings.TrimSpace(sc.Text())); err != nil {
+			return nil, fmt.Errorf("classify: filter list: line %d: %w", line, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("classify: filter list: %w", err)
+	}
+	return &l, nil
+}
+
+func (l *FilterList) add(f string) error {
+	if f == "" || f[0] == '!' || f[0] == '[' || isCosmeticFilter(f) {
+		return nil
+	}
+	set := &l.block
+	if strings.HasPrefix(f, "@@") {
+		set = &l.allow
+		f = f[2:]
+	}
+	if i := strings.LastIndexByte(f, '$'); i != -1 && optionsPattern.MatchString(f[i+1:]) {
+		for _, opt := range strings.Split(f[i+1:], ",") {
+			if !ignoredOptions[strings.TrimPrefix(opt, "~")] {
+				return nil
+			}
+		}
+		f = f[:i]
+	}
+	if len(f) >= 2 && f[0] == '/' && f[len(f)-1] == '/' {
+		return nil // regular expression
+	}
+	return set.add(f)
+}
+
+// isCosmeticFilter reports whether a filter hides elements or injects
+// scripts, rather than matching URLs.
+func isCosmeticFilter(f string) bool {
+	for _, sep := range []string{"##", "#@#", "#?#", "#$#", "#%#"} {
+		if strings.Contains(f, sep) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *filterSet) add(f string) error {
+	if strings.HasPrefix(f, "||") {
+		rest := f[2:]
+		i := 0
+		for i < len(rest) && isDomainChar(rest[i]) {
+			i++
+		}
+		domain := strings.ToLower(strings.TrimSuffix(rest[:i], "."))
+		if domain != "" && (i == len(rest) || strings.IndexByte("^/:|?", rest[i]) != -1) {
+			re, err := compileFilter(rest[i:], "^")
+			if err != nil {
+				return err
+			}
+			if s.domains == nil {
+				s.domains = make(map[string][]*regexp.Regexp)
+			}
+			s.domains[domain] = append(s.domains[domain], re)
+			return nil
+		}
+		re, err := compileFilter(rest, `^[a-z][a-z0-9+.-]*://(?:[^/?#]*\.)?`)
+		if err != nil {
+			return err
+		}
+		s.generic = append(s.generic, re)
+		return nil
+	}
+	prefix := ""
+	if strings.HasPrefix(f, "|") {
+		prefix, f = "^", f[1:]
+	}
+	if f == "" || f == "*" {
+		return nil // matches every URL
+	}
+	re, err := compileFilter(f, prefix)
+	if err != nil {
+		return err
+	}
+	s.generic = append(s.generic, re)
+	return nil
+}
+
+func isDomainChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '-'
+}
+
+// compileFilter compiles a filter pattern to a case-insensitive regular
+// expression with the given prefix. In patterns, "*" matches any
+// string, "^" matches a separator or the end of the URL, and a
+// trailing "|" anchors the end.
+func compileFilter(pattern, prefix string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?i)")
+	b.WriteString(prefix)
+	end := strings.HasSuffix(pattern, "|")
+	pattern = strings.TrimSuffix(pattern, "|")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '^':
+			b.WriteString(`(?:[^\w\-.%]|$)`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if end {
+		b.WriteByte('$')
+	}
+	return regexp.Compile(b.String())
+}
+
+// Classify classifies the URL as noise when it is blocked by the list.
+func (l *FilterList) Classify(u *url.URL) Result {
+	host := strings.ToLower(u.Hostname())
+	rest := u.EscapedPath()
+	if p := u.Port(); p != "" {
+		rest = ":" + p + rest
+	}
+	if u.RawQuery != "" || u.ForceQuery {
+		rest += "?" + u.RawQuery
+	}
+	full := *u
+	full.Fragment, full.RawFragment = "", ""
+	s := full.String()
+	return Result{Noise: l.block.match(host, rest, s) && !l.allow.match(host, rest, s)}
+}
+
+// match reports whether a filter in the set matches the URL, which has
+// the given host, the given part after the host, and the given string
+// form.
+func (s *filterSet) match(host, rest, full string) bool {
+	for d := host; d != ""; {
+		for _, re := range s.domains[d] {
+			if re.MatchString(rest) {
+				return true
+			}
+		}
+		i := strings.IndexByte(d, '.')
+		if i == -1 {
+			break
+		}
+		d = d[i+1:]
+	}
+	for _, re := range s.generic {
+		if re.MatchString(full) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package classify
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilterList(t *testing.T) {
+	l, err := ParseFilterList(strings.NewReader(`[Adblock Plus 2.0]
+! Title: Test list
+||tracker.example^
+||ads.example.com/banner/*
+||cdn.example.net^$script,third-party
+||partial.example.org^$domain=news.example
+|https://beacon.example/collect|
+/pixel.gif?
+@@||tracker.example/consent^
+example.com##.ad-banner
+/^https?:\/\/regex\.example\//
+||wild*card.example/track
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url   string
+		noise bool
+	}{
+		{"https://tracker.example/", true},
+		{"https://tracker.example", true},
+		{"https://sub.tracker.example:8443/t.js?id=1", true},
+		{"https://tracker.example/consent/ok", false}, // exception
+		{"https://nottracker.example/", false},        // not a subdomain
+		{"https://tracker.example.com/", false},       // "^" requires a separator
+		{"https://ads.example.com/banner/1.png", true},
+		{"https://ads.example.com/other/1.png", false},
+		{"https://cdn.example.net/lib.js", true}, // type and party options ignored
+		{"https://partial.example.org/", false},  // domain option skipped
+		{"https://beacon.example/collect", true},
+		{"https://beacon.example/collect?x=1", false}, // end anchor
+		{"https://site.example/img/pixel.gif?u=1", true},
+		{"https://example.com/", false},   // element hiding skipped
+		{"https://regex.example/", false}, // regular expression skipped
+		{"https://www.wildXcard.example/track/1", true},
+		{"https://tracker.example/#consent", true},
+	}
+	for _, tt := range tests {
+		if got := URL(l, tt.url).Noise; got != tt.noise {
+			t.Errorf("%s: got noise %t, want %t", tt.url, got, tt.noise)
+		}
+	}
+}
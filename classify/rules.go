@@ -0,0 +1,106 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package classify
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Rules assigns categories to URLs by user-provided rules. A rules file
+// has a rule per line of a category and a pattern, separated by
+// spaces, like:
+//
+//	# category  pattern
+//	news        example.com
+//	news        example.org/news/
+//	noise       redirect.example
+//
+// A pattern is a domain, which matches it and its subdomains,
+// optionally followed by a path prefix. Lines starting with "#" are
+// comments. URLs in the category NoiseCategory are noise.
+type Rules struct {
+	rules []rule
+}
+
+type rule struct {
+	Category string
+	Domain   string
+	Path     string // prefix, or empty for any path
+}
+
+// NoiseCategory is the category of rules that classify URLs as noise,
+// rather than assign a category.
+const NoiseCategory = "noise"
+
+// LoadRules reads a rules file.
+func LoadRules(filename string) (*Rules, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseRules(f)
+}
+
+// ParseRules parses rules.
+func ParseRules(r io.Reader) (*Rules, error) {
+	var rules Rules
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("classify: rules: line %d: want category and pattern", line)
+		}
+		domain, path := fields[1], ""
+		if i := strings.IndexByte(domain, '/'); i != -1 {
+			domain, path = domain[:i], domain[i:]
+		}
+		if domain == "" {
+			return nil, fmt.Errorf("classify: rules: line %d: pattern has no domain", line)
+		}
+		rules.rules = append(rules.rules, rule{fields[0], strings.ToLower(domain), path})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("classify: rules: %w", err)
+	}
+	return &rules, nil
+}
+
+// Classify assigns the categories of the rules that match the URL.
+func (rs *Rules) Classify(u *url.URL) Result {
+	var r Result
+	host := strings.ToLower(u.Hostname())
+	path := u.EscapedPath()
+	for _, rule := range rs.rules {
+		if !matchDomain(host, rule.Domain) || !strings.HasPrefix(path, rule.Path) {
+			continue
+		}
+		if rule.Category == NoiseCategory {
+			r.Noise = true
+		} else {
+			r.Categories = append(r.Categories, rule.Category)
+		}
+	}
+	if len(r.Categories) != 0 {
+		r.Categories = sortUnique(r.Categories)
+	}
+	return r
+}
+
+// matchDomain reports whether host is domain or a subdomain of it.
+func matchDomain(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
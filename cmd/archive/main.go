@@ -0,0 +1,398 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Command archive archives the browsing data of all Chrome and Firefox
+// profiles for the current user into a new timestamped directory.
+//
+// Usage:
+//
+//	archive [-o dir] [-machine name] [-firefox dir] [-chrome dir] [-forensic] [-onerror policy] [-order order] [-encrypt pub.pem]... [-sign key.pem]
+//	archive -merge [-o dir] [-spill dir] [-order order] [-encrypt pub.pem]... [-sign key.pem] archive...
+//	archive -decrypt key.pem archive...
+//	archive -verify [pub.pem] archive...
+//	archive -prune [-keep-years n] [-drop-domain domain]... [-vacuum] [-sign key.pem] archive...
+//	archive -migrate [-sign key.pem] archive...
+//	archive -import browserexport|promnesia|urllog [-o dir] [-machine name] [-order order] [-encrypt pub.pem]... [-sign key.pem] file
+//	archive -digest week|month [-date yyyy-mm-dd] [-collapse-redirects] [-order order] [-html] archive
+//
+// With no flags, profiles are read from the default locations and the
+// archive is written into the current directory, labeled with the host
+// name. Artifacts that fail to parse are listed on stderr and in the
+// manifest. With -onerror fail-fast, the first such artifact stops the
+// archive instead, and with -onerror skip-file, they are omitted.
+//
+// Visits and downloads are written ordered by time, then URL, so that
+// archives of the same data are identical. With -order input, they are
+// written in the order read instead. The same applies to -digest.
+//
+// With -forensic, deleted history is also recovered from the unused
+// pages of history databases and marked as recovered in history.jsonl.
+//
+// With -merge, archives collected on several machines are combined
+// into one, dropping records synced between them. For history too
+// large to merge in memory, -spill gives a directory for temporary
+// files.
+//
+// With -encrypt, the outputs are encrypted to an X25519 public key,
+// and with -sign, the manifest is signed with an Ed25519 private key,
+// for storing archives in cloud storage. Keys are PEM files, as
+// generated by OpenSSL:
+//
+//	openssl genpkey -algorithm X25519 -out key.pem
+//	openssl pkey -in key.pem -pubout -out pub.pem
+//	openssl genpkey -algorithm Ed25519 -out sign.pem
+//	openssl pkey -in sign.pem -pubout -out verify.pem
+//
+// -decrypt decrypts archives in place, so they can be read or merged,
+// and -verify checks their checksums and, given a key, signatures.
+//
+// With -prune, archives are pruned in place: visits and downloads older
+// than -keep-years and records in each -drop-domain are dropped, and
+// with -vacuum, the database is rebuilt so that they cannot be
+// recovered from it.
+//
+// With -migrate, archives written by older versions are upgraded in
+// place to the current version.
+//
+// With -import, history exported by another tool is written as an
+// archive, which can then be merged with others: a database saved by
+// browserexport or its JSON from "browserexport merge --json", a
+// Promnesia database, or a log of URLs with times.
+//
+// With -digest, a summary of the week or month containing -date, by
+// default the last complete one, is written to stdout in Markdown, or
+// with -html, in HTML. It compares visits with the prior period and
+// lists top sites, new domains, and searches. With -collapse-redirects,
+// redirect chains count as one visit.
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/archive"
+	"github.com/andrewarchi/browser/history"
+	"github.com/andrewarchi/browser/report"
+	"github.com/andrewarchi/browser/tools/browserexport"
+	"github.com/andrewarchi/browser/tools/promnesia"
+	"github.com/andrewarchi/browser/tools/urllog"
+)
+
+func main() {
+	out := flag.String("o", ".", "directory to create the archive in")
+	firefoxDir := flag.String("firefox", "", "Firefox root or profiles directory (default platform location)")
+	chromeDir := flag.String("chrome", "", "Chrome user data directory (default platform location)")
+	machine := flag.String("machine", "", "label for this machine (default host name)")
+	forensic := flag.Bool("forensic", false, "also recover deleted history from unused database pages")
+	var onError browser.ErrorPolicy
+	flag.Func("onerror", "handle artifacts that fail to parse by `policy`: collect, fail-fast, skip-record, or skip-file (default collect)", func(name string) error {
+		var err error
+		onError, err = browser.ParseErrorPolicy(name)
+		return err
+	})
+	var order history.Order
+	flag.Func("order", "write visits and downloads in `order`: time or input (default time)", func(name string) error {
+		var err error
+		order, err = history.ParseOrder(name)
+		return err
+	})
+	merge := flag.Bool("merge", false, "merge the archives given as arguments")
+	spill := flag.String("spill", "", "with -merge, spill history to temporary files in `dir` instead of holding it in memory")
+	var recipients []*ecdh.PublicKey
+	flag.Func("encrypt", "encrypt outputs to the X25519 public key in a PEM `file` (repeatable)", func(filename string) error {
+		key, err := readPublicKey(filename)
+		if err != nil {
+			return err
+		}
+		k, ok := key.(*ecdh.PublicKey)
+		if !ok || k.Curve() != ecdh.X25519() {
+			return errors.New("not an X25519 public key")
+		}
+		recipients = append(recipients, k)
+		return nil
+	})
+	sign := flag.String("sign", "", "sign the manifest with the Ed25519 private key in a PEM `file`")
+	decrypt := flag.String("decrypt", "", "decrypt the archives given as arguments with the X25519 private key in a PEM `file`")
+	verify := flag.Bool("verify", false, "verify the archives given as arguments, with the Ed25519 public key in the PEM file given first, if any")
+	prune := flag.Bool("prune", false, "apply a retention policy to the archives given as arguments")
+	keepYears := flag.Int("keep-years", 0, "with -prune, drop visits and downloads older than this many `years`")
+	var dropDomains []string
+	flag.Func("drop-domain", "with -prune, drop records in a `domain` and its subdomains (repeatable)", func(domain string) error {
+		dropDomains = append(dropDomains, domain)
+		return nil
+	})
+	importFormat := flag.String("import", "", "archive the history in the file given as an argument, exported in `format`: browserexport, promnesia, or urllog")
+	migrate := flag.Bool("migrate", false, "upgrade the archives given as arguments to the current version")
+	vacuum := flag.Bool("vacuum", false, "with -prune, rebuild the database to remove deleted rows")
+	digest := flag.String("digest", "", "write a digest of the archive given as an argument for a `period`: week or month")
+	date := flag.String("date", "", "with -digest, summarize the period containing this `date` (default the last complete period)")
+	collapse := flag.Bool("collapse-redirects", false, "with -digest, count redirect chains as one visit")
+	html := flag.Bool("html", false, "with -digest, write HTML instead of Markdown")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-o dir] [-machine name] [-firefox dir] [-chrome dir] [-forensic] [-onerror policy] [-order order] [-encrypt pub.pem]... [-sign key.pem]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s -merge [-o dir] [-spill dir] [-order order] [-encrypt pub.pem]... [-sign key.pem] archive...\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s -decrypt key.pem archive...\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s -verify [pub.pem] archive...\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s -prune [-keep-years n] [-drop-domain domain]... [-vacuum] [-sign key.pem] archive...\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s -migrate [-sign key.pem] archive...\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s -import browserexport|promnesia|urllog [-o dir] [-machine name] [-order order] [-encrypt pub.pem]... [-sign key.pem] file\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s -digest week|month [-date yyyy-mm-dd] [-collapse-redirects] [-order order] [-html] archive\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	withArgs := *merge || *decrypt != "" || *verify || *prune || *migrate || *importFormat != "" || *digest != ""
+	if withArgs != (flag.NArg() != 0) || (*digest != "" || *importFormat != "") && flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *digest != "" {
+		if err := writeDigest(flag.Arg(0), *digest, *date, *collapse, order, *html); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if *decrypt != "" {
+		key, err := readPrivateKey(*decrypt)
+		if err != nil {
+			fatal(err)
+		}
+		identity, ok := key.(*ecdh.PrivateKey)
+		if !ok || identity.Curve() != ecdh.X25519() {
+			fatal(fmt.Errorf("%s: not an X25519 private key", *decrypt))
+		}
+		for _, dir := range flag.Args() {
+			if err := archive.DecryptArchive(dir, identity); err != nil {
+				fatal(err)
+			}
+		}
+		return
+	}
+	if *verify {
+		dirs := flag.Args()
+		var key ed25519.PublicKey
+		if k, err := readPublicKey(dirs[0]); err == nil {
+			var ok bool
+			if key, ok = k.(ed25519.PublicKey); !ok {
+				fatal(fmt.Errorf("%s: not an Ed25519 public key", dirs[0]))
+			}
+			dirs = dirs[1:]
+		}
+		for _, dir := range dirs {
+			if err := archive.Verify(dir, key); err != nil {
+				fatal(err)
+			}
+			fmt.Printf("%s: OK\n", dir)
+		}
+		return
+	}
+	a := archive.Archiver{
+		FirefoxDir: *firefoxDir,
+		ChromeDir:  *chromeDir,
+		Machine:    *machine,
+		Forensic:   *forensic,
+		SpillDir:   *spill,
+		OnError:    onError,
+		Order:      order,
+		Recipients: recipients,
+	}
+	if *sign != "" {
+		key, err := readPrivateKey(*sign)
+		if err != nil {
+			fatal(err)
+		}
+		k, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			fatal(fmt.Errorf("%s: not an Ed25519 private key", *sign))
+		}
+		a.SigningKey = k
+	}
+	var m *archive.Manifest
+	var err error
+	if *prune {
+		r := archive.Retention{Domains: dropDomains, Vacuum: *vacuum}
+		if *keepYears > 0 {
+			r.Before = time.Now().AddDate(-*keepYears, 0, 0)
+		}
+		for _, dir := range flag.Args() {
+			m, err := a.Prune(dir, r)
+			if err != nil {
+				fatal(err)
+			}
+			rec := m.Retention[len(m.Retention)-1]
+			fmt.Printf("%s: dropped %d visits, %d downloads, and %d bookmarks\n", dir, rec.Visits, rec.Downloads, rec.Bookmarks)
+		}
+		return
+	}
+	if *migrate {
+		for _, dir := range flag.Args() {
+			before, err := archive.ReadManifest(dir)
+			if err != nil {
+				fatal(err)
+			}
+			m, err := a.Migrate(dir)
+			if err != nil {
+				fatal(err)
+			}
+			if len(m.Migrations) == len(before.Migrations) {
+				fmt.Printf("%s: already version %d\n", dir, m.Version)
+				continue
+			}
+			rec := m.Migrations[len(m.Migrations)-1]
+			fmt.Printf("%s: migrated from version %d to %d\n", dir, rec.From, rec.To)
+		}
+		return
+	}
+	if *importFormat != "" {
+		visits, err := readImport(*importFormat, flag.Arg(0))
+		if err != nil {
+			fatal(err)
+		}
+		m, err := a.Import(*out, flag.Arg(0), visits)
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Printf("Imported %d visits to %s\n", len(visits), m.Dir)
+		return
+	}
+	if *merge {
+		m, err = a.Merge(*out, flag.Args()...)
+	} else {
+		m, err = a.Archive(*out)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	var artifacts int
+	for _, p := range m.Profiles {
+		for _, art := range p.Artifacts {
+			artifacts++
+			if art.Error != "" {
+				fmt.Fprintf(os.Stderr, "%s: %s: %s\n", p.Path, art.Name, art.Error)
+			}
+		}
+	}
+	if *merge {
+		fmt.Printf("Merged %d archives with %d profiles to %s\n", len(m.Sources), len(m.Profiles), m.Dir)
+		return
+	}
+	fmt.Printf("Archived %d artifacts from %d profiles to %s\n", artifacts, len(m.Profiles), m.Dir)
+}
+
+// readImport reads the visits in a file exported by another tool.
+func readImport(format, filename string) ([]history.Visit, error) {
+	switch format {
+	case "browserexport":
+		if filepath.Ext(filename) != ".json" {
+			visits, err := browserexport.ParseDatabase(filename)
+			if err != nil {
+				return nil, err
+			}
+			return history.FromBrowserExport(visits), nil
+		}
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		visits, err := browserexport.ParseJSON(f)
+		if err != nil {
+			return nil, err
+		}
+		return history.FromBrowserExport(visits), nil
+	case "promnesia":
+		visits, err := promnesia.ParseDatabase(filename)
+		if err != nil {
+			return nil, err
+		}
+		return history.FromPromnesia(visits), nil
+	case "urllog":
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		entries, err := urllog.Parse(f, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		return history.FromURLLog(entries), nil
+	default:
+		return nil, fmt.Errorf("unknown import format %q", format)
+	}
+}
+
+func writeDigest(dir, unit, date string, collapse bool, order history.Order, html bool) error {
+	var periodOf func(t time.Time) report.Period
+	switch unit {
+	case "week":
+		periodOf = report.WeekOf
+	case "month":
+		periodOf = report.MonthOf
+	default:
+		return fmt.Errorf("unknown digest period %q", unit)
+	}
+	var p report.Period
+	if date != "" {
+		t, err := time.ParseInLocation("2006-01-02", date, time.Local)
+		if err != nil {
+			return err
+		}
+		p = periodOf(t)
+	} else {
+		p = periodOf(time.Now()).Prior()
+	}
+	visits, err := archive.ReadVisits(dir)
+	if err != nil {
+		return err
+	}
+	d := report.NewDigest(&report.Data{Visits: visits, CollapseRedirects: collapse, Order: order}, p)
+	if html {
+		return d.WriteHTML(os.Stdout)
+	}
+	return d.WriteMarkdown(os.Stdout)
+}
+
+func readPublicKey(filename string) (interface{}, error) {
+	block, err := readPEM(filename)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+func readPrivateKey(filename string) (interface{}, error) {
+	block, err := readPEM(filename)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParsePKCS8PrivateKey(block.Bytes)
+}
+
+func readPEM(filename string) (*pem.Block, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s: not a PEM file", filename)
+	}
+	return block, nil
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Command dead-bookmark-report checks every bookmarked link and lists
+// those that are no longer reachable. It is an example of reading
+// bookmarks from either browser and looking up URLs with the enrich
+// package.
+//
+// Usage:
+//
+//	dead-bookmark-report [-workers n] [-interval d] [-timeout d] file
+//
+// The file is the Bookmarks file of a Chrome profile, places.sqlite of
+// a Firefox profile, or a bookmarks HTML export. Each dead link is
+// written to stdout as its status, URL, and folder path, separated by
+// tabs, where the status is the HTTP status code or the request error.
+// Links are requested at most once per -interval for each host, so as
+// not to overload servers.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser/bookmark"
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/enrich"
+	"github.com/andrewarchi/browser/firefox"
+)
+
+func main() {
+	workers := flag.Int("workers", enrich.DefaultWorkers, "concurrent requests")
+	interval := flag.Duration("interval", enrich.DefaultInterval, "minimum time between requests to a host")
+	timeout := flag.Duration("timeout", 30*time.Second, "timeout for each request")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-workers n] [-interval d] [-timeout d] file\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	p := enrich.Pipeline{
+		Func:     enrich.CheckLink(&http.Client{Timeout: *timeout}),
+		Workers:  *workers,
+		Interval: *interval,
+	}
+	checked, dead, err := run(context.Background(), os.Stdout, flag.Arg(0), p)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "%d of %d links are dead\n", dead, checked)
+}
+
+// link is a bookmarked URL with the path of its folder.
+type link struct {
+	URL    string
+	Folder string
+}
+
+// run checks the links in the bookmarks file, writes the dead links to
+// w, and returns the number of links checked and found dead.
+func run(ctx context.Context, w io.Writer, filename string, p enrich.Pipeline) (checked, dead int, err error) {
+	entries, err := readBookmarks(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	var links []link
+	collectLinks(entries, nil, &links)
+	urls := make([]string, len(links))
+	for i, l := range links {
+		urls[i] = l.URL
+	}
+	for i, r := range p.Run(ctx, urls) {
+		var status string
+		if r.Err != nil {
+			status = r.Err.Error()
+		} else if s := r.Value.(*enrich.LinkStatus); !s.Alive {
+			status = strconv.Itoa(s.StatusCode)
+		} else {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", status, links[i].URL, links[i].Folder); err != nil {
+			return 0, 0, err
+		}
+		dead++
+	}
+	return len(links), dead, nil
+}
+
+// readBookmarks reads the bookmarks in a file, by its name.
+func readBookmarks(filename string) ([]bookmark.BookmarkEntry, error) {
+	switch ext := strings.ToLower(filepath.Ext(filename)); {
+	case ext == ".sqlite":
+		return firefox.ParsePlacesBookmarks(filename)
+	case ext == ".html" || ext == ".htm":
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return bookmark.ParseHTML(f)
+	default:
+		b, err := chrome.ParseBookmarks(filename)
+		if err != nil {
+			return nil, err
+		}
+		return b.Tree(), nil
+	}
+}
+
+// collectLinks appends the HTTP and HTTPS links in the entries to
+// links. Other schemes, such as javascript: bookmarklets, cannot be
+// checked.
+func collectLinks(entries []bookmark.BookmarkEntry, folder []string, links *[]link) {
+	for _, e := range entries {
+		switch e := e.(type) {
+		case *bookmark.BookmarkFolder:
+			collectLinks(e.Entries, append(folder, e.Title), links)
+		case *bookmark.Bookmark:
+			if strings.HasPrefix(e.URL, "http://") || strings.HasPrefix(e.URL, "https://") {
+				*links = append(*links, link{e.URL, strings.Join(folder, "/")})
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrewarchi/browser/bookmark"
+	"github.com/andrewarchi/browser/enrich"
+)
+
+func TestDeadBookmarkReport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/alive" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	var html bytes.Buffer
+	err := bookmark.WriteHTML(&html, []bookmark.BookmarkEntry{
+		&bookmark.BookmarkFolder{Title: "Bookmarks bar", Entries: []bookmark.BookmarkEntry{
+			&bookmark.Bookmark{Title: "Alive", URL: srv.URL + "/alive"},
+			&bookmark.BookmarkFolder{Title: "Old", Entries: []bookmark.BookmarkEntry{
+				&bookmark.Bookmark{Title: "Gone", URL: srv.URL + "/gone"},
+				&bookmark.Bookmark{Title: "Bookmarklet", URL: "javascript:void(0)"},
+			}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "bookmarks.html")
+	if err := ioutil.WriteFile(filename, html.Bytes(), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	p := enrich.Pipeline{Func: enrich.CheckLink(srv.Client()), Interval: -1}
+	checked, dead, err := run(context.Background(), &out, filename, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked != 2 || dead != 1 {
+		t.Errorf("%d of %d links dead, want 1 of 2", dead, checked)
+	}
+	want := "404\t" + srv.URL + "/gone\tBookmarks bar/Old\n"
+	if out.String() != want {
+		t.Errorf("got:\n%q\nwant:\n%q", out.String(), want)
+	}
+}
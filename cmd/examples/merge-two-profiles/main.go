@@ -0,0 +1,117 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Command merge-two-profiles merges the browsing history of two
+// profiles into one timeline, dropping visits that are in both, such as
+// those synced between them. It is an example of reading history from
+// either browser and merging it with the history package.
+//
+// Usage:
+//
+//	merge-two-profiles [-o output] profile profile
+//
+// Each profile is a Chrome profile directory, with a History database,
+// or a Firefox profile directory, with places.sqlite. The timeline is
+// written to stdout unless -o is given, in the JSON Lines format of
+// history.jsonl in archives.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/firefox"
+	"github.com/andrewarchi/browser/history"
+)
+
+func main() {
+	out := flag.String("o", "", "output file (default stdout)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-o output] profile profile\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	added, merged, err := run(w, flag.Arg(0), flag.Arg(1))
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "Merged %d visits into %d\n", added, merged)
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+// run writes the merged history of the profiles to w and returns the
+// number of visits read and written.
+func run(w io.Writer, profileDirs ...string) (added, merged int, err error) {
+	var m history.Merger
+	defer m.Close()
+	for _, dir := range profileDirs {
+		visits, err := readProfile(dir)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, v := range visits {
+			if err := m.Add(v); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+	added = m.Len()
+	enc := history.NewEncoder(w)
+	err = m.Each(func(v *history.Visit) error {
+		merged++
+		return enc.EncodeVisit(v)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return added, merged, enc.Flush()
+}
+
+// readProfile reads the history of a Chrome or Firefox profile.
+func readProfile(dir string) ([]history.Visit, error) {
+	if _, err := os.Stat(filepath.Join(dir, "History")); err == nil {
+		h, err := chrome.OpenHistory(filepath.Join(dir, "History"))
+		if err != nil {
+			return nil, err
+		}
+		defer h.Close()
+		visits, err := h.Visits()
+		if err != nil {
+			return nil, err
+		}
+		return history.FromChromeHistory(visits), nil
+	}
+	places := filepath.Join(dir, "places.sqlite")
+	if _, err := os.Stat(places); err == nil {
+		visits, err := firefox.ParsePlacesVisits(places)
+		if err != nil {
+			return nil, err
+		}
+		return history.FromFirefoxPlaces(visits), nil
+	}
+	return nil, fmt.Errorf("%s: not a Chrome or Firefox profile", dir)
+}
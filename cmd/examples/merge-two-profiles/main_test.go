@@ -0,0 +1,86 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/andrewarchi/browser/history"
+)
+
+func createDB(t *testing.T, filename, schema string) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(schema)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMergeTwoProfiles(t *testing.T) {
+	dir := t.TempDir()
+	chromeDir, firefoxDir := filepath.Join(dir, "Default"), filepath.Join(dir, "abcd1234.default")
+	// 2021-02-18 00:00:00 UTC, shared by both profiles, and an hour later
+	createDB(t, filepath.Join(chromeDir, "History"), `
+		CREATE TABLE urls (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR);
+		CREATE TABLE visits (id INTEGER PRIMARY KEY, url INTEGER NOT NULL, visit_time INTEGER NOT NULL,
+			from_visit INTEGER, transition INTEGER DEFAULT 0 NOT NULL, segment_id INTEGER,
+			visit_duration INTEGER DEFAULT 0 NOT NULL);
+		INSERT INTO urls VALUES (1, 'https://example.com/', 'Example'), (2, 'https://example.org/', 'Org');
+		INSERT INTO visits VALUES (1, 1, 13258080000000000, 0, 1, NULL, 0), (2, 2, 13258083600000000, 1, 0, NULL, 0);`)
+	createDB(t, filepath.Join(firefoxDir, "places.sqlite"), `
+		CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR, description TEXT);
+		CREATE TABLE moz_historyvisits (id INTEGER PRIMARY KEY, from_visit INTEGER, place_id INTEGER,
+			visit_date INTEGER, visit_type INTEGER, session INTEGER);
+		INSERT INTO moz_places VALUES (1, 'https://example.com/', 'Example', NULL), (2, 'https://example.net/', NULL, NULL);
+		INSERT INTO moz_historyvisits VALUES (1, 0, 1, 1613606400000000, 2, 0), (2, 1, 2, 1613610000000000, 1, 0);`)
+
+	var buf bytes.Buffer
+	added, merged, err := run(&buf, chromeDir, firefoxDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 4 || merged != 3 {
+		t.Errorf("merged %d visits into %d, want 4 into 3", added, merged)
+	}
+	var got []string
+	dec := history.NewDecoder(&buf)
+	for {
+		rec, err := dec.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		v := rec.Visit
+		got = append(got, v.Time.Format("15:04")+" "+v.URL+" "+v.Source)
+	}
+	want := []string{
+		"00:00 https://example.com/ chrome",
+		"01:00 https://example.net/ firefox",
+		"01:00 https://example.org/ chrome",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+
+	if _, _, err := run(&buf, dir); err == nil {
+		t.Error("no error for a directory that is not a profile")
+	}
+}
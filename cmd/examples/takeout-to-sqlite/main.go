@@ -0,0 +1,148 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Command takeout-to-sqlite converts the Chrome history and bookmarks
+// in a Google Takeout export to a SQLite database, for querying with
+// SQL. It is an example of reading Takeout exports with the takeout and
+// history packages.
+//
+// Usage:
+//
+//	takeout-to-sqlite -o output.sqlite takeout-20210218T150405Z-001.zip
+//
+// The database has a visits table, with times in microseconds since
+// 1970 and the name of the device that synced each visit, and a
+// bookmarks table, with the path of the folder of each bookmark. Files
+// in the export that fail to parse are listed on stderr and skipped.
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/bookmark"
+	"github.com/andrewarchi/browser/history"
+	"github.com/andrewarchi/browser/takeout"
+
+	_ "github.com/mattn/go-sqlite3" // register sqlite3 driver
+)
+
+func main() {
+	out := flag.String("o", "", "output database, which must not exist")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -o output.sqlite takeout-*-001.zip\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	visits, bookmarks, err := run(flag.Arg(0), *out)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d visits and %d bookmarks to %s\n", visits, bookmarks, *out)
+}
+
+const schema = `
+	CREATE TABLE visits (
+		id         INTEGER PRIMARY KEY,
+		url        TEXT NOT NULL,
+		title      TEXT NOT NULL,
+		time       INTEGER NOT NULL, -- microseconds since 1970
+		transition TEXT NOT NULL,    -- core type, e.g. "link"
+		device     TEXT NOT NULL     -- empty for this device
+	);
+	CREATE INDEX visits_url ON visits (url);
+	CREATE INDEX visits_time ON visits (time);
+	CREATE TABLE bookmarks (
+		id       INTEGER PRIMARY KEY,
+		url      TEXT NOT NULL,
+		title    TEXT NOT NULL,
+		folder   TEXT NOT NULL, -- e.g. "Bookmarks bar/News"
+		added    INTEGER        -- microseconds since 1970
+	);`
+
+// run converts the Takeout export to a new database and returns the
+// number of visits and bookmarks written.
+func run(filename, out string) (visits, bookmarks int, err error) {
+	data, err := takeout.ParseChrome(filename)
+	var errs browser.Errors
+	if errors.As(err, &errs) {
+		for _, err := range errs {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	} else if err != nil {
+		return 0, 0, err
+	}
+	if _, err := os.Stat(out); err == nil {
+		return 0, 0, fmt.Errorf("%s: already exists", out)
+	}
+	db, err := sql.Open("sqlite3", out)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(schema); err != nil {
+		return 0, 0, err
+	}
+
+	insertVisit, err := tx.Prepare(`INSERT INTO visits (url, title, time, transition, device) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer insertVisit.Close()
+	for _, v := range history.FromTakeout(data) {
+		if _, err := insertVisit.Exec(v.URL, v.Title, v.Time.UnixNano()/1e3,
+			v.Transition.String(), v.Device); err != nil {
+			return 0, 0, err
+		}
+		visits++
+	}
+
+	insertBookmark, err := tx.Prepare(`INSERT INTO bookmarks (url, title, folder, added) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer insertBookmark.Close()
+	var walk func(entries []bookmark.BookmarkEntry, folder []string) error
+	walk = func(entries []bookmark.BookmarkEntry, folder []string) error {
+		for _, e := range entries {
+			switch e := e.(type) {
+			case *bookmark.BookmarkFolder:
+				if err := walk(e.Entries, append(folder, e.Title)); err != nil {
+					return err
+				}
+			case *bookmark.Bookmark:
+				var added interface{}
+				if !e.AddDate.IsZero() {
+					added = e.AddDate.UnixNano() / 1e3
+				}
+				if _, err := insertBookmark.Exec(e.URL, e.Title, strings.Join(folder, "/"), added); err != nil {
+					return err
+				}
+				bookmarks++
+			}
+		}
+		return nil
+	}
+	if err := walk(data.Bookmarks, nil); err != nil {
+		return 0, 0, err
+	}
+	return visits, bookmarks, tx.Commit()
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/bookmark"
+)
+
+func TestTakeoutToSQLite(t *testing.T) {
+	dir := t.TempDir()
+	var html bytes.Buffer
+	err := bookmark.WriteHTML(&html, []bookmark.BookmarkEntry{
+		&bookmark.BookmarkFolder{Title: "Bookmarks bar", Entries: []bookmark.BookmarkEntry{
+			&bookmark.Bookmark{Title: "Example", URL: "https://example.com/", AddDate: time.Unix(1613606400, 0)},
+			&bookmark.BookmarkFolder{Title: "News", Entries: []bookmark.BookmarkEntry{
+				&bookmark.Bookmark{Title: "Org", URL: "https://example.org/"},
+			}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "takeout-20210218T150405Z-001.zip")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, file := range []struct{ name, data string }{
+		{"Takeout/Chrome/BrowserHistory.json", `{"Browser History": [
+			{"page_transition": "LINK", "title": "Example", "url": "https://example.com/", "client_id": "Y2xpZW50", "time_usec": 1613606400000000},
+			{"page_transition": "TYPED", "title": "Org", "url": "https://example.org/", "client_id": "", "time_usec": 1613610000000000}
+		]}`},
+		{"Takeout/Chrome/Device Information.json", `{"Device Info": [
+			{"cache_guid": "Y2xpZW50", "client_name": "Pixel 4", "device_type": "TYPE_PHONE", "sync_user_agent": "",
+				"chrome_version": "88.0.4324.152", "signin_scoped_device_id": "", "last_updated_timestamp": 1613606400000,
+				"sharing_fields": {}, "invalidation_fields": {}, "paask_fields": null}
+		]}`},
+		{"Takeout/Chrome/Bookmarks.html", html.String()},
+	} {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(file.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "takeout.sqlite")
+	visits, bookmarks, err := run(filename, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if visits != 2 || bookmarks != 2 {
+		t.Errorf("wrote %d visits and %d bookmarks, want 2 and 2", visits, bookmarks)
+	}
+	db, err := sql.Open("sqlite3", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	got := queryRows(t, db, `SELECT url, time, transition, device FROM visits ORDER BY id`)
+	want := []string{
+		"https://example.com/ 1613606400000000 link Pixel 4",
+		"https://example.org/ 1613610000000000 typed ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got visits:\n%q\nwant:\n%q", got, want)
+	}
+	got = queryRows(t, db, `SELECT url, folder, added FROM bookmarks ORDER BY id`)
+	want = []string{
+		"https://example.com/ Bookmarks bar 1613606400000000",
+		"https://example.org/ Bookmarks bar/News <nil>",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got bookmarks:\n%q\nwant:\n%q", got, want)
+	}
+
+	if _, _, err := run(filename, out); err == nil {
+		t.Error("no error for an existing database")
+	}
+}
+
+// queryRows returns the rows of a query, with columns joined by
+// spaces.
+func queryRows(t *testing.T, db *sql.DB, query string) []string {
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for rows.Next() {
+		vals := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			t.Fatal(err)
+		}
+		row := fmt.Sprint(vals[0])
+		for _, v := range vals[1:] {
+			row += " " + fmt.Sprint(v)
+		}
+		got = append(got, row)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
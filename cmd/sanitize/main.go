@@ -0,0 +1,67 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Command sanitize replaces private values in a browser artifact with
+// deterministic placeholders, so that it can be attached to a bug
+// report or added to the test corpus.
+//
+// Usage:
+//
+//	sanitize [-key key] [-o output] file
+//
+// JSON, mozLz4-compressed JSON, SQLite databases, bookmark HTML, and
+// line-oriented text are supported. Output is written to stdout unless
+// -o is given, which is required for SQLite databases.
+//
+// To add a sample to the corpus, write it into the artifact directory,
+// then regenerate the golden files:
+//
+//	sanitize -o firefox/testdata/corpus/times.json/v1.json times.json
+//	go test ./firefox -run TestCorpus -update
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/andrewarchi/browser/sanitize"
+)
+
+func main() {
+	key := flag.String("key", "", "secret mixed into placeholders")
+	out := flag.String("o", "", "output file (default stdout)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-key key] [-o output] file\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), *out, *key); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(filename, out, key string) error {
+	s := sanitize.Sanitizer{Key: []byte(key)}
+	if out != "" {
+		return s.File(filename, out)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	b, err := s.Bytes(data)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, filename)
+	}
+	_, err = os.Stdout.Write(b)
+	return err
+}
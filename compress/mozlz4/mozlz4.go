@@ -0,0 +1,174 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package mozlz4 implements reading and writing of Mozilla's mozLz4
+// format, which is used by Firefox for files such as
+// sessionstore.jsonlz4, bookmark backups (*.jsonlz4), search.json.mozlz4,
+// and addonStartup.json.lz4.
+//
+// A mozLz4 file is the magic number "mozLz40\0", the little-endian
+// uint32 size of the decompressed data, and a single LZ4 block, without
+// the LZ4 frame format. Since the whole block must be available to
+// decompress it, Reader and Writer buffer the entire contents.
+// https://searchfox.org/mozilla-central/source/toolkit/components/lz4/lz4.js
+package mozlz4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+// Magic is the magic number at the start of mozLz4 files.
+const Magic = "mozLz40\x00"
+
+const headerSize = len(Magic) + 4
+
+// IsMozLz4 reports whether b begins with the mozLz4 magic number.
+func IsMozLz4(b []byte) bool {
+	return bytes.HasPrefix(b, []byte(Magic))
+}
+
+// Decode decompresses mozLz4 data.
+func Decode(b []byte) ([]byte, error) {
+	if len(b) < headerSize {
+		return nil, errors.New("mozlz4: missing header")
+	}
+	if !IsMozLz4(b) {
+		return nil, fmt.Errorf("mozlz4: invalid magic number: %q", b[:len(Magic)])
+	}
+	size := binary.LittleEndian.Uint32(b[len(Magic):])
+	if size == 0 {
+		return []byte{}, nil
+	}
+
+	data := make([]byte, size)
+	n, err := lz4.UncompressBlock(b[headerSize:], data)
+	if err != nil {
+		return nil, fmt.Errorf("mozlz4: decompress: %w", err)
+	}
+	if n != int(size) {
+		return nil, fmt.Errorf("mozlz4: header size %d and decompressed size %d differ", size, n)
+	}
+	return data, nil
+}
+
+// Encode compresses data in mozLz4 format.
+func Encode(data []byte) ([]byte, error) {
+	if uint64(len(data)) > 1<<32-1 {
+		return nil, errors.New("mozlz4: data too large")
+	}
+	b := make([]byte, headerSize+lz4.CompressBlockBound(len(data)))
+	copy(b, Magic)
+	binary.LittleEndian.PutUint32(b[len(Magic):], uint32(len(data)))
+	n, err := lz4.CompressBlock(data, b[headerSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("mozlz4: compress: %w", err)
+	}
+	if n == 0 {
+		// CompressBlock returns 0 for empty or incompressible data,
+		// which must then be stored as a block of only literals.
+		n = storeLiterals(data, b[headerSize:])
+	}
+	return b[:headerSize+n], nil
+}
+
+// storeLiterals writes data as an LZ4 block containing a single
+// sequence of literals and returns the length of the block.
+func storeLiterals(data, dst []byte) int {
+	n := len(data)
+	i := 0
+	if n < 15 {
+		dst[i] = byte(n << 4)
+		i++
+	} else {
+		dst[i] = 0xf0
+		i++
+		for rem := n - 15; ; rem -= 255 {
+			if rem < 255 {
+				dst[i] = byte(rem)
+				i++
+				break
+			}
+			dst[i] = 255
+			i++
+		}
+	}
+	i += copy(dst[i:], data)
+	return i
+}
+
+// Reader is an io.Reader that decompresses mozLz4 data.
+type Reader struct {
+	r *bytes.Reader
+}
+
+// NewReader reads and decompresses all mozLz4 data from r and returns a
+// Reader for the decompressed data.
+func NewReader(r io.Reader) (*Reader, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data, err := Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{bytes.NewReader(data)}, nil
+}
+
+// Read reads decompressed data.
+func (r *Reader) Read(p []byte) (int, error) {
+	return r.r.Read(p)
+}
+
+// Len returns the number of unread bytes of decompressed data.
+func (r *Reader) Len() int {
+	return r.r.Len()
+}
+
+// Writer is an io.WriteCloser that compresses data in mozLz4 format.
+// Data is buffered until Close is called, which writes the compressed
+// data to the underlying writer.
+type Writer struct {
+	w      io.Writer
+	buf    bytes.Buffer
+	closed bool
+}
+
+// NewWriter returns a new Writer that writes compressed data to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write buffers uncompressed data.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("mozlz4: write after close")
+	}
+	return w.buf.Write(p)
+}
+
+// Close compresses the buffered data and writes it to the underlying
+// writer. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	b, err := Encode(w.buf.Bytes())
+	if err != nil {
+		return err
+	}
+	w.buf = bytes.Buffer{}
+	_, err = w.w.Write(b)
+	return err
+}
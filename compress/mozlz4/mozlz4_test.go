@@ -0,0 +1,68 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mozlz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	random := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(random)
+	tests := [][]byte{
+		{},
+		[]byte("a"),
+		[]byte(`{"version":["sessionrestore",1],"windows":[]}`),
+		[]byte(strings.Repeat(`{"url":"https://example.com/"},`, 100)),
+		random,
+	}
+	for i, data := range tests {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if !IsMozLz4(buf.Bytes()) {
+			t.Errorf("#%d: missing magic number", i)
+		}
+		r, err := NewReader(&buf)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if r.Len() != len(data) {
+			t.Errorf("#%d: got length: %d, want: %d", i, r.Len(), len(data))
+		}
+		got, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("#%d: got: %q, want: %q", i, got, data)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"mozLz40\x00\x01",
+		"mozLz41\x00\x01\x00\x00\x00\x10a",
+		"mozLz40\x00\x02\x00\x00\x00\x10a", // size mismatch
+	}
+	for i, tt := range tests {
+		if _, err := Decode([]byte(tt)); err == nil {
+			t.Errorf("#%d: error not returned for %q", i, tt)
+		}
+	}
+}
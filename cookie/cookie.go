@@ -0,0 +1,128 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package cookie provides a browser-independent model for cookies and
+// merges cookies from multiple browsers and profiles into one jar.
+package cookie
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/secret"
+)
+
+// Cookie is an HTTP cookie stored by a browser.
+type Cookie struct {
+	Host             string // e.g. "example.com" or ".example.com" for domain cookies
+	Name             string
+	Value            secret.Secret // redacted when printed; use Value.Reveal
+	Path             string        // e.g. "/"
+	OriginAttributes string        // Firefox partitioning (e.g. "^userContextId=1") or Chrome top-frame site, empty otherwise
+	Created          time.Time
+	Expires          time.Time // zero for session cookies
+	LastAccessed     time.Time
+	Secure           bool
+	HTTPOnly         bool
+	SameSite         SameSite
+	Source           string // browser and profile read from (e.g. "firefox/default-release")
+}
+
+// Key identifies a cookie within a jar. Two cookies with the same key
+// conflict and only one is kept.
+type Key struct {
+	Host             string
+	Name             string
+	Path             string
+	OriginAttributes string
+}
+
+// Key returns the key that identifies the cookie.
+func (c *Cookie) Key() Key {
+	return Key{c.Host, c.Name, c.Path, c.OriginAttributes}
+}
+
+// IsSession reports whether the cookie is deleted when the browser
+// session ends.
+func (c *Cookie) IsSession() bool {
+	return c.Expires.IsZero()
+}
+
+// FromChrome converts cookies from a Chrome profile, labeled with
+// source. Encrypted values are decrypted with keys from kp or, when kp
+// is nil, left empty.
+func FromChrome(cookies []chrome.Cookie, kp chrome.KeyProvider, source string) ([]Cookie, error) {
+	out := make([]Cookie, len(cookies))
+	for i, c := range cookies {
+		value := secret.Secret(c.Value)
+		if kp != nil {
+			v, err := c.DecryptValue(kp)
+			if err != nil {
+				return nil, fmt.Errorf("cookie: %s %s: %w", c.HostKey, c.Name, err)
+			}
+			value = v
+		}
+		var expires time.Time
+		if c.HasExpires && c.IsPersistent {
+			expires = c.ExpiresUTC
+		}
+		out[i] = Cookie{
+			Host:             c.HostKey,
+			Name:             c.Name,
+			Value:            value,
+			Path:             c.Path,
+			OriginAttributes: c.TopFrameSiteKey,
+			Created:          c.CreationUTC,
+			Expires:          expires,
+			LastAccessed:     c.LastAccessUTC,
+			Secure:           c.IsSecure,
+			HTTPOnly:         c.IsHTTPOnly,
+			SameSite:         fromChromeSameSite(c.SameSite),
+			Source:           source,
+		}
+	}
+	return out, nil
+}
+
+func fromChromeSameSite(s chrome.CookieSameSite) SameSite {
+	switch s {
+	case chrome.CookieSameSiteNoRestriction:
+		return SameSiteNone
+	case chrome.CookieSameSiteLax:
+		return SameSiteLax
+	case chrome.CookieSameSiteStrict:
+		return SameSiteStrict
+	default:
+		return SameSiteUnspecified
+	}
+}
+
+// SameSite is the SameSite attribute of a cookie.
+type SameSite uint8
+
+// Values for SameSite:
+const (
+	SameSiteUnspecified SameSite = iota
+	SameSiteNone
+	SameSiteLax
+	SameSiteStrict
+)
+
+func (s SameSite) String() string {
+	switch s {
+	case SameSiteUnspecified:
+		return "unspecified"
+	case SameSiteNone:
+		return "none"
+	case SameSiteLax:
+		return "lax"
+	case SameSiteStrict:
+		return "strict"
+	default:
+		return fmt.Sprintf("samesite(%d)", uint8(s))
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cookie
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/chrome"
+)
+
+func TestFromChrome(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	block, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(block)
+	nonce := make([]byte, gcm.NonceSize())
+	encrypted := gcm.Seal(append([]byte("v10"), nonce...), nonce, []byte("secret"), nil)
+
+	created := time.Date(2021, 2, 18, 0, 0, 0, 0, time.UTC)
+	expires := created.AddDate(1, 0, 0)
+	cookies := []chrome.Cookie{
+		{CreationUTC: created, HostKey: ".example.com", Name: "id", EncryptedValue: encrypted, Path: "/",
+			ExpiresUTC: expires, IsSecure: true, HasExpires: true, IsPersistent: true, SameSite: chrome.CookieSameSiteLax},
+		{CreationUTC: created, HostKey: "example.org", TopFrameSiteKey: "https://example.net", Name: "session",
+			Value: "plain", Path: "/", SameSite: chrome.CookieSameSiteUnspecified},
+	}
+	got, err := FromChrome(cookies, chrome.StaticKey(key), "chrome/Default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Cookie{
+		{Host: ".example.com", Name: "id", Value: "secret", Path: "/", Created: created, Expires: expires,
+			Secure: true, SameSite: SameSiteLax, Source: "chrome/Default"},
+		{Host: "example.org", Name: "session", Value: "plain", Path: "/", OriginAttributes: "https://example.net",
+			Created: created, SameSite: SameSiteUnspecified, Source: "chrome/Default"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%#v\nwant:\n%#v", got, want)
+	}
+	if _, err := FromChrome(cookies, chrome.StaticKey(bytes.Repeat([]byte{1}, 32)), ""); err == nil {
+		t.Error("decrypted with the wrong key")
+	}
+}
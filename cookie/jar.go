@@ -0,0 +1,161 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cookie
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Jar is a set of cookies merged from multiple sources, in which
+// conflicting cookies are resolved by a policy.
+type Jar struct {
+	policy     Policy
+	precedence map[string]int
+	cookies    map[Key]*Cookie
+	conflicts  []Conflict
+}
+
+// Policy decides which of two conflicting cookies is kept.
+type Policy uint8
+
+// Values for Policy:
+const (
+	// LatestExpiry keeps the cookie that expires last, with session
+	// cookies expiring before all others. Ties are broken by source
+	// precedence.
+	LatestExpiry Policy = iota
+	// SourcePrecedence keeps the cookie from the source with the highest
+	// precedence. Ties are broken by expiry.
+	SourcePrecedence
+)
+
+// Conflict records a cookie that was discarded in favor of another.
+type Conflict struct {
+	Kept      Cookie
+	Discarded Cookie
+}
+
+// NewJar returns an empty jar with the given policy. Sources are listed
+// in decreasing precedence; unlisted sources have the lowest
+// precedence.
+func NewJar(policy Policy, precedence ...string) *Jar {
+	prec := make(map[string]int, len(precedence))
+	for i, source := range precedence {
+		if _, ok := prec[source]; !ok {
+			prec[source] = len(precedence) - i
+		}
+	}
+	return &Jar{
+		policy:     policy,
+		precedence: prec,
+		cookies:    make(map[Key]*Cookie),
+	}
+}
+
+// Add adds a cookie to the jar, resolving any conflict with an existing
+// cookie by the policy.
+func (j *Jar) Add(c Cookie) {
+	key := c.Key()
+	old, ok := j.cookies[key]
+	if !ok {
+		j.cookies[key] = &c
+		return
+	}
+	if j.prefer(&c, old) {
+		j.conflicts = append(j.conflicts, Conflict{Kept: c, Discarded: *old})
+		j.cookies[key] = &c
+	} else {
+		j.conflicts = append(j.conflicts, Conflict{Kept: *old, Discarded: c})
+	}
+}
+
+// Merge adds all cookies to the jar.
+func (j *Jar) Merge(cookies []Cookie) {
+	for _, c := range cookies {
+		j.Add(c)
+	}
+}
+
+// prefer reports whether c should replace old. When the cookies are
+// equally preferred, the existing cookie is kept.
+func (j *Jar) prefer(c, old *Cookie) bool {
+	expiry := compareExpiry(c, old)
+	prec := j.precedence[c.Source] - j.precedence[old.Source]
+	switch j.policy {
+	case LatestExpiry:
+		if expiry != 0 {
+			return expiry > 0
+		}
+		return prec > 0
+	case SourcePrecedence:
+		if prec != 0 {
+			return prec > 0
+		}
+		return expiry > 0
+	default:
+		panic(fmt.Sprintf("cookie: illegal policy: %d", j.policy))
+	}
+}
+
+func compareExpiry(a, b *Cookie) int {
+	switch {
+	case a.IsSession() && b.IsSession():
+		return 0
+	case a.IsSession():
+		return -1
+	case b.IsSession():
+		return 1
+	case a.Expires.After(b.Expires):
+		return 1
+	case a.Expires.Before(b.Expires):
+		return -1
+	default:
+		return 0
+	}
+}
+
+// RemoveExpired removes cookies that have expired by the given time.
+// Session cookies are kept.
+func (j *Jar) RemoveExpired(now time.Time) {
+	for key, c := range j.cookies {
+		if !c.IsSession() && !c.Expires.After(now) {
+			delete(j.cookies, key)
+		}
+	}
+}
+
+// Len returns the number of cookies in the jar.
+func (j *Jar) Len() int { return len(j.cookies) }
+
+// Cookies returns the cookies in the jar, ordered by host, path, name,
+// then origin attributes.
+func (j *Jar) Cookies() []Cookie {
+	cookies := make([]Cookie, 0, len(j.cookies))
+	for _, c := range j.cookies {
+		cookies = append(cookies, *c)
+	}
+	sort.Slice(cookies, func(i, k int) bool {
+		a, b := &cookies[i], &cookies[k]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.OriginAttributes < b.OriginAttributes
+	})
+	return cookies
+}
+
+// Conflicts returns the conflicts resolved so far, in the order that
+// they occurred.
+func (j *Jar) Conflicts() []Conflict { return j.conflicts }
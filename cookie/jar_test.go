@@ -0,0 +1,59 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cookie
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJarMerge(t *testing.T) {
+	early := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	late := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	chrome := []Cookie{
+		{Host: "example.com", Name: "id", Path: "/", Value: "chrome", Expires: early, Source: "chrome"},
+		{Host: "example.com", Name: "session", Path: "/", Value: "chrome", Source: "chrome"},
+	}
+	firefox := []Cookie{
+		{Host: "example.com", Name: "id", Path: "/", Value: "firefox", Expires: late, Source: "firefox"},
+		{Host: "example.com", Name: "session", Path: "/", Value: "firefox", Source: "firefox"},
+		{Host: "example.org", Name: "id", Path: "/", Value: "firefox", Expires: early, Source: "firefox"},
+	}
+
+	tests := []struct {
+		policy Policy
+		want   []string // values of example.com id, session, example.org id
+	}{
+		{LatestExpiry, []string{"firefox", "chrome", "firefox"}},
+		{SourcePrecedence, []string{"chrome", "chrome", "firefox"}},
+	}
+	for i, test := range tests {
+		j := NewJar(test.policy, "chrome", "firefox")
+		j.Merge(chrome)
+		j.Merge(firefox)
+		cookies := j.Cookies()
+		if len(cookies) != len(test.want) {
+			t.Errorf("#%d: got %d cookies, want %d", i, len(cookies), len(test.want))
+			continue
+		}
+		for k, c := range cookies {
+			if c.Value.Reveal() != test.want[k] {
+				t.Errorf("#%d: cookie %s%s %s: got: %q want: %q", i, c.Host, c.Path, c.Name, c.Value.Reveal(), test.want[k])
+			}
+		}
+		if n := len(j.Conflicts()); n != 2 {
+			t.Errorf("#%d: got %d conflicts, want 2", i, n)
+		}
+	}
+
+	j := NewJar(LatestExpiry)
+	j.Merge(firefox)
+	j.RemoveExpired(early.Add(time.Hour))
+	if j.Len() != 2 {
+		t.Errorf("got %d cookies after removing expired, want 2", j.Len())
+	}
+}
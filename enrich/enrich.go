@@ -0,0 +1,197 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package enrich looks up information about URLs and other keys from
+// network services with a polite client: a bounded worker pool,
+// per-host rate limiting, caching, and cancellation.
+package enrich
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Func looks up information for a key, such as a URL.
+type Func func(ctx context.Context, key string) (interface{}, error)
+
+// Pipeline runs a lookup function over many keys.
+type Pipeline struct {
+	Func     Func
+	Workers  int                     // concurrent lookups; default 4
+	Interval time.Duration           // minimum time between lookups for the same host; default 1s, negative for none
+	Host     func(key string) string // rate limiting group for a key; default URLHost
+	Cache    Cache                   // optional
+}
+
+// Result is the result of a lookup.
+type Result struct {
+	Key    string
+	Value  interface{}
+	Err    error
+	Cached bool
+}
+
+// Defaults for Pipeline:
+const (
+	DefaultWorkers  = 4
+	DefaultInterval = time.Second
+)
+
+// Run looks up each key and returns the results in the order of the
+// keys. Duplicate keys are looked up once. Failed lookups are not
+// cached. When the context is cancelled, the remaining keys fail with
+// the context error.
+func (p *Pipeline) Run(ctx context.Context, keys []string) []Result {
+	workers := p.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	interval := p.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	host := p.Host
+	if host == nil {
+		host = URLHost
+	}
+	lim := &limiter{interval: interval, next: make(map[string]time.Time)}
+
+	results := make([]Result, len(keys))
+	first := make(map[string]int, len(keys))
+	var unique []int
+	for i, key := range keys {
+		results[i].Key = key
+		if _, ok := first[key]; !ok {
+			first[key] = i
+			unique = append(unique, i)
+		}
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = p.lookup(ctx, lim, host, keys[i])
+			}
+		}()
+	}
+	for _, i := range unique {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, key := range keys {
+		if j := first[key]; j != i {
+			results[i] = results[j]
+		}
+	}
+	return results
+}
+
+func (p *Pipeline) lookup(ctx context.Context, lim *limiter, host func(string) string, key string) Result {
+	r := Result{Key: key}
+	if p.Cache != nil {
+		if v, ok := p.Cache.Get(key); ok {
+			r.Value, r.Cached = v, true
+			return r
+		}
+	}
+	if err := lim.wait(ctx, host(key)); err != nil {
+		r.Err = err
+		return r
+	}
+	r.Value, r.Err = p.Func(ctx, key)
+	if r.Err == nil && p.Cache != nil {
+		p.Cache.Put(key, r.Value)
+	}
+	return r
+}
+
+// URLHost returns the host of a URL key, the scheme of an opaque URI
+// key like "chrome:{id}", or the key itself otherwise.
+func URLHost(key string) string {
+	if u, err := url.Parse(key); err == nil {
+		if u.Host != "" {
+			return u.Host
+		}
+		if u.Opaque != "" {
+			return u.Scheme
+		}
+	}
+	return key
+}
+
+// limiter spaces lookups to the same host by an interval.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     map[string]time.Time
+}
+
+func (l *limiter) wait(ctx context.Context, host string) error {
+	if l.interval < 0 {
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	now := time.Now()
+	t := l.next[host]
+	if t.Before(now) {
+		t = now
+	}
+	l.next[host] = t.Add(l.interval)
+	l.mu.Unlock()
+
+	d := time.Until(t)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Cache stores lookup results.
+type Cache interface {
+	Get(key string) (interface{}, bool)
+	Put(key string, value interface{})
+}
+
+// MemoryCache is a Cache in memory, safe for concurrent use.
+type MemoryCache struct {
+	mu sync.RWMutex
+	m  map[string]interface{}
+}
+
+// NewMemoryCache returns an empty MemoryCache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{m: make(map[string]interface{})}
+}
+
+// Get implements the Cache interface.
+func (c *MemoryCache) Get(key string) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v, ok := c.m[key]
+	return v, ok
+}
+
+// Put implements the Cache interface.
+func (c *MemoryCache) Put(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.m[key] = value
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package enrich
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	last := make(map[string]time.Time)
+	var tooSoon bool
+	p := &Pipeline{
+		Func: func(ctx context.Context, key string) (interface{}, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls[key]++
+			host := URLHost(key)
+			if t, ok := last[host]; ok && time.Since(t) < 15*time.Millisecond {
+				tooSoon = true
+			}
+			last[host] = time.Now()
+			if key == "https://b.example/fail" {
+				return nil, errors.New("fail")
+			}
+			return len(key), nil
+		},
+		Interval: 20 * time.Millisecond,
+		Cache:    NewMemoryCache(),
+	}
+	p.Cache.Put("https://a.example/cached", 0)
+	keys := []string{
+		"https://a.example/1",
+		"https://a.example/2",
+		"https://b.example/fail",
+		"https://a.example/1",
+		"https://a.example/cached",
+	}
+	results := p.Run(context.Background(), keys)
+	if tooSoon {
+		t.Error("rate limit not respected")
+	}
+	for i, r := range results {
+		if r.Key != keys[i] {
+			t.Errorf("#%d: got key %q, want %q", i, r.Key, keys[i])
+		}
+	}
+	if calls["https://a.example/1"] != 1 {
+		t.Errorf("duplicate key looked up %d times", calls["https://a.example/1"])
+	}
+	if calls["https://a.example/cached"] != 0 || !results[4].Cached {
+		t.Error("cached key looked up")
+	}
+	if results[2].Err == nil {
+		t.Error("error not returned")
+	}
+	if _, ok := p.Cache.Get("https://b.example/fail"); ok {
+		t.Error("failure cached")
+	}
+	if v, ok := p.Cache.Get("https://a.example/2"); !ok || v != len("https://a.example/2") {
+		t.Errorf("got cached %v, want %d", v, len("https://a.example/2"))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i, r := range p.Run(ctx, []string{"https://c.example/1", "https://c.example/2"}) {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("#%d: got error %v, want %v", i, r.Err, context.Canceled)
+		}
+	}
+}
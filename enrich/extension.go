@@ -0,0 +1,174 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package enrich
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+// Extension stores for ExtensionKey:
+const (
+	ChromeWebStore = "chrome"  // Chrome Web Store
+	AMO            = "firefox" // addons.mozilla.org
+)
+
+// ExtensionListing is the store listing of an extension.
+type ExtensionListing struct {
+	Store   string // ChromeWebStore or AMO
+	ID      string
+	Name    string
+	Version string // current version in the store
+	URL     string // listing page
+	Status  string // status reported by the store, e.g. "public", "noupdate"
+	Listed  bool   // false when removed or disabled; often a sign of malware
+}
+
+// ExtensionKey returns the key for looking up an extension with
+// LookupExtension, of the form "{store}:{id}". Keys for the same store
+// are rate limited together.
+func ExtensionKey(store, id string) string {
+	return store + ":" + id
+}
+
+// LookupExtension returns a Func that resolves an extension key, as
+// returned by ExtensionKey, to its store listing. Extensions missing
+// from the store have Listed false, rather than an error.
+func LookupExtension(client *http.Client) Func {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context, key string) (interface{}, error) {
+		i := strings.IndexByte(key, ':')
+		if i == -1 {
+			return nil, fmt.Errorf("enrich: malformed extension key: %q", key)
+		}
+		switch store, id := key[:i], key[i+1:]; store {
+		case ChromeWebStore:
+			return lookupChromeWebStore(ctx, client, id)
+		case AMO:
+			return lookupAMO(ctx, client, id)
+		default:
+			return nil, fmt.Errorf("enrich: unknown extension store: %q", store)
+		}
+	}
+}
+
+// chromeProdVersion is the browser version reported to the update
+// service, which omits extensions requiring a newer browser.
+const chromeProdVersion = "99.0"
+
+// lookupChromeWebStore queries the extension update service for the
+// current version, which is public unlike the store API, then the
+// listing page for the name.
+// https://developer.chrome.com/docs/apps/autoupdate/
+func lookupChromeWebStore(ctx context.Context, client *http.Client, id string) (*ExtensionListing, error) {
+	l := &ExtensionListing{
+		Store: ChromeWebStore,
+		ID:    id,
+		URL:   "https://chrome.google.com/webstore/detail/" + url.PathEscape(id),
+	}
+	q := url.Values{
+		"response":     {"updatecheck"},
+		"prodversion":  {chromeProdVersion},
+		"acceptformat": {"crx2,crx3"},
+		"x":            {"id=" + id + "&uc"},
+	}
+	resp, err := get(ctx, client, "https://clients2.google.com/service/update2/crx?"+q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var update struct {
+		App struct {
+			Status      string `xml:"status,attr"`
+			UpdateCheck struct {
+				Status  string `xml:"status,attr"`
+				Version string `xml:"version,attr"`
+			} `xml:"updatecheck"`
+		} `xml:"app"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, maxBodySize)).Decode(&update); err != nil {
+		return nil, fmt.Errorf("enrich: chrome web store: %w", err)
+	}
+	l.Status = update.App.UpdateCheck.Status
+	if update.App.Status != "ok" {
+		l.Status = update.App.Status
+	}
+	l.Version = update.App.UpdateCheck.Version
+	l.Listed = l.Version != ""
+	if !l.Listed {
+		return l, nil
+	}
+
+	resp, err = send(ctx, client, http.MethodGet, l.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		page, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+		if err != nil {
+			return nil, err
+		}
+		if m := ogTitle.FindSubmatch(page); m != nil {
+			l.Name = strings.TrimSuffix(html.UnescapeString(string(m[1])), " - Chrome Web Store")
+		}
+	}
+	return l, nil
+}
+
+var ogTitle = regexp.MustCompile(`<meta property="og:title" content="([^"]*)"`)
+
+// lookupAMO queries the addons.mozilla.org API.
+// https://addons-server.readthedocs.io/en/latest/topics/api/addons.html#detail
+func lookupAMO(ctx context.Context, client *http.Client, id string) (*ExtensionListing, error) {
+	l := &ExtensionListing{Store: AMO, ID: id}
+	resp, err := send(ctx, client, http.MethodGet, "https://addons.mozilla.org/api/v5/addons/addon/"+url.PathEscape(id)+"/")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusUnauthorized, http.StatusForbidden:
+		// Deleted, disabled, or never listed.
+		l.Status = resp.Status
+		return l, nil
+	default:
+		return nil, fmt.Errorf("enrich: amo: %s: %s", id, resp.Status)
+	}
+	var addon struct {
+		Name           map[string]string `json:"name"`
+		DefaultLocale  string            `json:"default_locale"`
+		CurrentVersion struct {
+			Version string `json:"version"`
+		} `json:"current_version"`
+		Status     string `json:"status"`
+		IsDisabled bool   `json:"is_disabled"`
+		URL        string `json:"url"`
+	}
+	if err := jsonutil.DecodeAllowUnknownFields(io.LimitReader(resp.Body, maxBodySize), &addon); err != nil {
+		return nil, fmt.Errorf("enrich: amo: %w", err)
+	}
+	l.Name = addon.Name[addon.DefaultLocale]
+	l.Version = addon.CurrentVersion.Version
+	l.URL = addon.URL
+	l.Status = addon.Status
+	l.Listed = addon.Status == "public" && !addon.IsDisabled
+	return l, nil
+}
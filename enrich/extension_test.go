@@ -0,0 +1,130 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestLookupExtension(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/service/update2/crx":
+			if r.URL.Query().Get("prodversion") != chromeProdVersion {
+				http.Error(w, "missing prodversion", http.StatusBadRequest)
+				return
+			}
+			switch r.URL.Query().Get("x") {
+			case "id=aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa&uc":
+				fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<gupdate xmlns="http://www.google.com/update2/response" protocol="2.0">
+ <app appid="aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa" cohort="1::" status="ok">
+  <updatecheck codebase="https://example.com/a.crx" status="ok" version="1.2.3"/>
+ </app>
+</gupdate>`)
+			case "id=bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb&uc":
+				fmt.Fprint(w, `<gupdate><app status="ok"><updatecheck status="noupdate"/></app></gupdate>`)
+			case "id=cccccccccccccccccccccccccccccccc&uc":
+				fmt.Fprint(w, `<gupdate><app status="error-unknownApplication"><updatecheck status="error-internal"/></app></gupdate>`)
+			default:
+				fmt.Fprint(w, `<gupdate`)
+			}
+		case "/webstore/detail/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa":
+			fmt.Fprint(w, `<html><head><meta property="og:title" content="Tabs &amp; Windows - Chrome Web Store"></head></html>`)
+		case "/api/v5/addons/addon/public@example.com/":
+			fmt.Fprint(w, `{"name": {"en-US": "Add-on", "de": "Erweiterung"}, "default_locale": "de",
+				"current_version": {"version": "3.1"}, "status": "public", "is_disabled": false,
+				"url": "https://addons.mozilla.org/addon/example/", "guid": "public@example.com"}`)
+		case "/api/v5/addons/addon/disabled@example.com/":
+			fmt.Fprint(w, `{"name": {"en-US": "Disabled"}, "default_locale": "en-US",
+				"current_version": {"version": "1.0"}, "status": "public", "is_disabled": true,
+				"url": "https://addons.mozilla.org/addon/disabled/"}`)
+		case "/api/v5/addons/addon/error@example.com/":
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	target, _ := url.Parse(srv.URL)
+	lookup := LookupExtension(&http.Client{Transport: rewriteTransport{target}})
+
+	for _, tt := range []struct {
+		store, id string
+		want      *ExtensionListing
+	}{
+		{ChromeWebStore, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", &ExtensionListing{
+			Store:   ChromeWebStore,
+			ID:      "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+			Name:    "Tabs & Windows",
+			Version: "1.2.3",
+			URL:     "https://chrome.google.com/webstore/detail/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+			Status:  "ok",
+			Listed:  true,
+		}},
+		{ChromeWebStore, "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", &ExtensionListing{
+			Store:  ChromeWebStore,
+			ID:     "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+			URL:    "https://chrome.google.com/webstore/detail/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+			Status: "noupdate",
+		}},
+		{ChromeWebStore, "cccccccccccccccccccccccccccccccc", &ExtensionListing{
+			Store:  ChromeWebStore,
+			ID:     "cccccccccccccccccccccccccccccccc",
+			URL:    "https://chrome.google.com/webstore/detail/cccccccccccccccccccccccccccccccc",
+			Status: "error-unknownApplication",
+		}},
+		{AMO, "public@example.com", &ExtensionListing{
+			Store:   AMO,
+			ID:      "public@example.com",
+			Name:    "Erweiterung",
+			Version: "3.1",
+			URL:     "https://addons.mozilla.org/addon/example/",
+			Status:  "public",
+			Listed:  true,
+		}},
+		{AMO, "disabled@example.com", &ExtensionListing{
+			Store:   AMO,
+			ID:      "disabled@example.com",
+			Name:    "Disabled",
+			Version: "1.0",
+			URL:     "https://addons.mozilla.org/addon/disabled/",
+			Status:  "public",
+		}},
+		{AMO, "deleted@example.com", &ExtensionListing{
+			Store:  AMO,
+			ID:     "deleted@example.com",
+			Status: "404 Not Found",
+		}},
+	} {
+		got, err := lookup(context.Background(), ExtensionKey(tt.store, tt.id))
+		if err != nil {
+			t.Errorf("%s: %v", tt.id, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got:\n%+v\nwant:\n%+v", tt.id, got, tt.want)
+		}
+	}
+
+	for _, key := range []string{
+		ExtensionKey(ChromeWebStore, "dddddddddddddddddddddddddddddddd"), // truncated XML
+		ExtensionKey(AMO, "error@example.com"),
+		ExtensionKey("edge", "eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"),
+		"malformed",
+	} {
+		if _, err := lookup(context.Background(), key); err == nil {
+			t.Errorf("%s: got no error", key)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+// UserAgent is sent with all requests.
+var UserAgent = "github.com/andrewarchi/browser"
+
+// maxBodySize limits the size of response bodies that are read.
+const maxBodySize = 1 << 20
+
+// LinkStatus is the result of checking a link.
+type LinkStatus struct {
+	StatusCode int
+	FinalURL   string // after redirects
+	Alive      bool   // 2xx status
+}
+
+// CheckLink returns a Func that requests a URL and reports its status.
+// HEAD is tried first, falling back to GET for servers that reject
+// HEAD.
+func CheckLink(client *http.Client) Func {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context, rawURL string) (interface{}, error) {
+		resp, err := do(ctx, client, http.MethodHead, rawURL)
+		if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+			resp, err = do(ctx, client, http.MethodGet, rawURL)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &LinkStatus{
+			StatusCode: resp.StatusCode,
+			FinalURL:   resp.Request.URL.String(),
+			Alive:      resp.StatusCode >= 200 && resp.StatusCode < 300,
+		}, nil
+	}
+}
+
+// Snapshot is the closest Wayback Machine capture of a URL.
+type Snapshot struct {
+	URL       string `json:"url"`
+	Timestamp string `json:"timestamp"` // e.g. "20210218000000"
+	Status    string `json:"status"`    // e.g. "200"
+	Available bool   `json:"available"`
+}
+
+// Wayback returns a Func that looks up the closest capture of a URL in
+// the Wayback Machine availability API. The value is nil when the URL
+// has not been archived. Every lookup is a request to archive.org, so
+// pipelines should rate limit it as one host, as WaybackPipeline does,
+// rather than by the host of each URL.
+func Wayback(client *http.Client) Func {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context, rawURL string) (interface{}, error) {
+		api := "https://archive.org/wayback/available?url=" + url.QueryEscape(rawURL)
+		resp, err := get(ctx, client, api)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		// Unlike local files, the API may add fields at any time.
+		var avail struct {
+			ArchivedSnapshots struct {
+				Closest *Snapshot `json:"closest"`
+			} `json:"archived_snapshots"`
+		}
+		if err := jsonutil.DecodeAllowUnknownFields(io.LimitReader(resp.Body, maxBodySize), &avail); err != nil {
+			return nil, fmt.Errorf("enrich: wayback: %w", err)
+		}
+		return avail.ArchivedSnapshots.Closest, nil
+	}
+}
+
+// WaybackPipeline returns a Pipeline that looks up URLs with Wayback
+// and rate limits all lookups as requests to archive.org. Other fields
+// of the Pipeline may be set before running it.
+func WaybackPipeline(client *http.Client) Pipeline {
+	return Pipeline{Func: Wayback(client), Host: waybackHost}
+}
+
+// waybackHost groups all Wayback lookups for rate limiting.
+func waybackHost(string) string {
+	return "archive.org"
+}
+
+// Favicon is an icon fetched from a site.
+type Favicon struct {
+	URL      string
+	MIMEType string
+	Data     []byte
+}
+
+// FetchFavicon returns a Func that fetches /favicon.ico from the origin
+// of a page URL.
+func FetchFavicon(client *http.Client) Func {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context, pageURL string) (interface{}, error) {
+		u, err := url.Parse(pageURL)
+		if err != nil {
+			return nil, err
+		}
+		icon := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/favicon.ico"}).String()
+		resp, err := get(ctx, client, icon)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+		if err != nil {
+			return nil, err
+		}
+		mime := resp.Header.Get("Content-Type")
+		if mime == "" {
+			mime = http.DetectContentType(data)
+		}
+		return &Favicon{URL: icon, MIMEType: mime, Data: data}, nil
+	}
+}
+
+// send sends a request with the User-Agent set.
+func send(ctx context.Context, client *http.Client, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	return client.Do(req)
+}
+
+// get requests a URL and fails on non-2xx statuses.
+func get(ctx context.Context, client *http.Client, rawURL string) (*http.Response, error) {
+	resp, err := send(ctx, client, http.MethodGet, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("enrich: %s: %s", rawURL, resp.Status)
+	}
+	return resp, nil
+}
+
+// do sends a request and discards the response body.
+func do(ctx context.Context, client *http.Client, method, rawURL string) (*http.Response, error) {
+	resp, err := send(ctx, client, method, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxBodySize))
+	resp.Body.Close()
+	return resp, nil
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWaybackPipeline(t *testing.T) {
+	var mu sync.Mutex
+	var times []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+		if r.URL.Path != "/wayback/available" {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.URL.Query().Get("url") {
+		case "https://a.example/":
+			fmt.Fprint(w, `{"url": "https://a.example/", "archived_snapshots": {"closest": {
+				"status": "200", "available": true, "timestamp": "20210218000000",
+				"url": "http://web.archive.org/web/20210218000000/https://a.example/"}}}`)
+		default:
+			fmt.Fprint(w, `{"archived_snapshots": {}}`)
+		}
+	}))
+	defer srv.Close()
+	target, _ := url.Parse(srv.URL)
+
+	// The URLs have different hosts, but all requests go to archive.org,
+	// so they are spaced by the interval.
+	const interval = 20 * time.Millisecond
+	p := WaybackPipeline(&http.Client{Transport: rewriteTransport{target}})
+	p.Interval = interval
+	results := p.Run(context.Background(), []string{"https://a.example/", "https://b.example/", "https://c.example/"})
+	for _, r := range results {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+	}
+	want := &Snapshot{
+		URL:       "http://web.archive.org/web/20210218000000/https://a.example/",
+		Timestamp: "20210218000000",
+		Status:    "200",
+		Available: true,
+	}
+	if got, _ := results[0].Value.(*Snapshot); !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got, want)
+	}
+	if got, _ := results[1].Value.(*Snapshot); got != nil {
+		t.Errorf("got snapshot %+v for unarchived URL", got)
+	}
+
+	if len(times) != 3 {
+		t.Fatalf("got %d requests, want 3", len(times))
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	for i := 1; i < len(times); i++ {
+		if d := times[i].Sub(times[i-1]); d < interval-5*time.Millisecond {
+			t.Errorf("requests %d and %d spaced by %v, want at least %v", i-1, i, d, interval)
+		}
+	}
+}
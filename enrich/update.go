@@ -0,0 +1,225 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// InstalledExtension is an extension installed in a browser profile.
+type InstalledExtension struct {
+	Profile string // profile directory or name
+	Store   string // ChromeWebStore or AMO
+	ID      string
+	Version string
+}
+
+// ExtensionUpdate is the status of an installed extension in its store.
+type ExtensionUpdate struct {
+	InstalledExtension
+	Listing  *ExtensionListing // nil when the lookup failed
+	Outdated bool              // a newer version is in the store
+	Yanked   bool              // the installed version was removed from the store
+	Err      error
+}
+
+// CheckExtensionUpdates compares the versions of installed extensions,
+// such as from every profile on a machine, to the current versions in
+// their stores. Each extension and version is looked up once, rate
+// limited per store by default. The Func of the pipeline is replaced.
+//
+// An extension is yanked when its store no longer lists it or, for
+// AMO, when the installed version was deleted or disabled, while other
+// versions remain. The Chrome Web Store does not expose past versions,
+// so only delisting is detected there.
+func CheckExtensionUpdates(ctx context.Context, p Pipeline, client *http.Client, installed []InstalledExtension) []ExtensionUpdate {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	lookup := LookupExtension(client)
+	p.Func = func(ctx context.Context, key string) (interface{}, error) {
+		if store, id, version, ok := splitVersionKey(key); ok {
+			return lookupAMOVersion(ctx, client, store, id, version)
+		}
+		return lookup(ctx, key)
+	}
+	if p.Host == nil {
+		p.Host = extensionStore
+	}
+
+	var keys []string
+	for _, ext := range installed {
+		keys = append(keys, ExtensionKey(ext.Store, ext.ID))
+	}
+	listings := make(map[string]Result)
+	for _, r := range p.Run(ctx, keys) {
+		listings[r.Key] = r
+	}
+
+	// Check the installed versions that differ from the listed version.
+	var versionKeys []string
+	for _, ext := range installed {
+		r := listings[ExtensionKey(ext.Store, ext.ID)]
+		if l, ok := r.Value.(*ExtensionListing); ok && ext.Store == AMO && l.Listed && l.Version != ext.Version {
+			versionKeys = append(versionKeys, versionKey(ext.Store, ext.ID, ext.Version))
+		}
+	}
+	versions := make(map[string]Result)
+	for _, r := range p.Run(ctx, versionKeys) {
+		versions[r.Key] = r
+	}
+
+	updates := make([]ExtensionUpdate, len(installed))
+	for i, ext := range installed {
+		u := ExtensionUpdate{InstalledExtension: ext}
+		r := listings[ExtensionKey(ext.Store, ext.ID)]
+		if r.Err != nil {
+			u.Err = r.Err
+		} else if l, ok := r.Value.(*ExtensionListing); ok {
+			u.Listing = l
+			if !l.Listed {
+				u.Yanked = true
+			} else {
+				u.Outdated = CompareVersions(ext.Version, l.Version) < 0
+				if v, ok := versions[versionKey(ext.Store, ext.ID, ext.Version)]; ok {
+					if v.Err != nil {
+						u.Err = v.Err
+					} else {
+						u.Yanked = !v.Value.(bool)
+					}
+				}
+			}
+		}
+		updates[i] = u
+	}
+	sort.SliceStable(updates, func(i, j int) bool {
+		ui, uj := &updates[i], &updates[j]
+		if ui.Store != uj.Store {
+			return ui.Store < uj.Store
+		}
+		if ui.ID != uj.ID {
+			return ui.ID < uj.ID
+		}
+		return ui.Profile < uj.Profile
+	})
+	return updates
+}
+
+// extensionStore returns the store of an extension key, to rate limit
+// lookups per store.
+func extensionStore(key string) string {
+	if i := strings.IndexByte(key, ':'); i != -1 {
+		return key[:i]
+	}
+	return key
+}
+
+// versionKey returns the key for looking up whether a version of an
+// extension is available, of the form "{store}:{id}:{version}". Neither
+// extension IDs nor versions contain colons.
+func versionKey(store, id, version string) string {
+	return ExtensionKey(store, id) + ":" + version
+}
+
+func splitVersionKey(key string) (store, id, version string, ok bool) {
+	i := strings.IndexByte(key, ':')
+	j := strings.LastIndexByte(key, ':')
+	if i == -1 || i == j {
+		return "", "", "", false
+	}
+	return key[:i], key[i+1 : j], key[j+1:], true
+}
+
+// lookupAMOVersion reports whether a version of an add-on is available
+// on addons.mozilla.org.
+// https://addons-server.readthedocs.io/en/latest/topics/api/addons.html#version-detail
+func lookupAMOVersion(ctx context.Context, client *http.Client, store, id, version string) (bool, error) {
+	if store != AMO {
+		return false, fmt.Errorf("enrich: versions not available for store: %q", store)
+	}
+	resp, err := do(ctx, client, http.MethodGet, "https://addons.mozilla.org/api/v5/addons/addon/"+
+		url.PathEscape(id)+"/versions/"+url.PathEscape(version)+"/")
+	if err != nil {
+		return false, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound, http.StatusUnauthorized, http.StatusForbidden:
+		return false, nil
+	default:
+		return false, fmt.Errorf("enrich: amo: %s %s: %s", id, version, resp.Status)
+	}
+}
+
+// CompareVersions compares dotted extension versions, like "1.10.2",
+// returning -1, 0, or 1. Parts are compared numerically by their
+// leading digits, then by any suffix, so that "1.0b2" precedes
+// "1.0b10"; missing parts are zero. Suffixes sort after a bare number,
+// which differs from Firefox, where "1.0b1" precedes "1.0".
+func CompareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var pa, pb string
+		if i < len(as) {
+			pa = as[i]
+		}
+		if i < len(bs) {
+			pb = bs[i]
+		}
+		if c := compareVersionPart(pa, pb); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareVersionPart(a, b string) int {
+	for a != "" || b != "" {
+		na, ra := leadingNumber(a)
+		nb, rb := leadingNumber(b)
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+		sa, ra := leadingNonDigits(ra)
+		sb, rb := leadingNonDigits(rb)
+		if sa != sb {
+			if sa < sb {
+				return -1
+			}
+			return 1
+		}
+		a, b = ra, rb
+	}
+	return 0
+}
+
+func leadingNumber(s string) (uint64, string) {
+	i := 0
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		i++
+	}
+	n, _ := strconv.ParseUint(s[:i], 10, 64)
+	return n, s[i:]
+}
+
+func leadingNonDigits(s string) (string, string) {
+	i := 0
+	for i < len(s) && (s[i] < '0' || '9' < s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// rewriteTransport sends all requests to a test server.
+type rewriteTransport struct{ target *url.URL }
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = t.target.Scheme, t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestCheckExtensionUpdates(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/service/update2/crx":
+			version := ""
+			if r.URL.Query().Get("x") == "id=aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa&uc" {
+				version = "2.0.1"
+			}
+			fmt.Fprintf(w, `<gupdate><app status="ok"><updatecheck status="ok" version="%s"/></app></gupdate>`, version)
+		case "/webstore/detail/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa":
+			fmt.Fprint(w, `<meta property="og:title" content="Example - Chrome Web Store">`)
+		case "/api/v5/addons/addon/addon@example.com/":
+			fmt.Fprint(w, `{"name": {"en-US": "Add-on"}, "default_locale": "en-US",
+				"current_version": {"version": "3.1"}, "status": "public", "url": "https://addons.mozilla.org/addon/example/"}`)
+		case "/api/v5/addons/addon/addon@example.com/versions/3.0/":
+			fmt.Fprint(w, `{}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	target, _ := url.Parse(srv.URL)
+	client := &http.Client{Transport: rewriteTransport{target}}
+
+	installed := []InstalledExtension{
+		{"Default", ChromeWebStore, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "2.0.1"},
+		{"Profile 1", ChromeWebStore, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "1.9"},
+		{"Profile 1", ChromeWebStore, "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "1.0"},
+		{"default-release", AMO, "addon@example.com", "3.0"},
+		{"dev-edition", AMO, "addon@example.com", "2.5"},
+	}
+	updates := CheckExtensionUpdates(context.Background(), Pipeline{Interval: -1}, client, installed)
+	want := []struct {
+		profile, id      string
+		outdated, yanked bool
+	}{
+		{"Default", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", false, false},
+		{"Profile 1", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", true, false},
+		{"Profile 1", "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", false, true},
+		{"default-release", "addon@example.com", true, false},
+		{"dev-edition", "addon@example.com", true, true},
+	}
+	if len(updates) != len(want) {
+		t.Fatalf("got %d updates, want %d", len(updates), len(want))
+	}
+	for i, u := range updates {
+		w := want[i]
+		if u.Err != nil {
+			t.Errorf("#%d: %v", i, u.Err)
+			continue
+		}
+		if u.Profile != w.profile || u.ID != w.id || u.Outdated != w.outdated || u.Yanked != w.yanked {
+			t.Errorf("#%d: got %s %s outdated=%t yanked=%t, want %s %s outdated=%t yanked=%t",
+				i, u.Profile, u.ID, u.Outdated, u.Yanked, w.profile, w.id, w.outdated, w.yanked)
+		}
+	}
+	if name := updates[0].Listing.Name; name != "Example" {
+		t.Errorf("got name %q, want %q", name, "Example")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.0.0", 0},
+		{"1.2", "1.10", -1},
+		{"2.0", "1.99.9", 1},
+		{"1.0b2", "1.0b10", -1},
+		{"1.0a1", "1.0b1", -1},
+		{"1.0", "1.0.1", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := CompareVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
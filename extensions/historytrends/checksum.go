@@ -0,0 +1,125 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package historytrends
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChecksumSuffix is appended to the filename of an export for its
+// sidecar checksum file. The sidecar is in sha256sum format, so it can
+// also be checked with "sha256sum -c".
+const ChecksumSuffix = ".sha256"
+
+// ErrChecksumMismatch is returned when an export does not match its
+// sidecar checksum.
+var ErrChecksumMismatch = errors.New("historytrends: checksum mismatch")
+
+// WriteChecksum computes the SHA-256 checksum of an export file and
+// writes it to the sidecar file beside it.
+func WriteChecksum(filename string) error {
+	sum, err := fileChecksum(filename)
+	if err != nil {
+		return err
+	}
+	return writeChecksumFile(filename, sum)
+}
+
+// VerifyChecksum checks an export file against its sidecar checksum.
+// When there is no sidecar, the error matches os.ErrNotExist.
+func VerifyChecksum(filename string) error {
+	want, err := readChecksumFile(filename)
+	if err != nil {
+		return err
+	}
+	got, err := fileChecksum(filename)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("%w: %s: got %x, want %x", ErrChecksumMismatch, filepath.Base(filename), got, want)
+	}
+	return nil
+}
+
+// OpenVerifiedReader opens an export for reading, like OpenReader,
+// after checking it against its sidecar checksum, which must exist.
+func OpenVerifiedReader(filename string) (*ReadCloser, error) {
+	if err := VerifyChecksum(filename); err != nil {
+		return nil, err
+	}
+	return OpenReader(filename)
+}
+
+// WriteFile writes the export to a file. When checksum is set, the
+// sidecar checksum file is written too.
+func (ex *Export) WriteFile(filename string, checksum bool) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	w, err := NewWriter(io.MultiWriter(f, h), ex.Type, ex.ExportTime)
+	if err == nil {
+		if err = w.WriteAll(ex.Visits); err == nil {
+			err = w.Flush()
+		}
+	}
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil || !checksum {
+		return err
+	}
+	return writeChecksumFile(filename, h.Sum(nil))
+}
+
+func fileChecksum(filename string) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func writeChecksumFile(filename string, sum []byte) error {
+	line := fmt.Sprintf("%x  %s\n", sum, filepath.Base(filename))
+	return ioutil.WriteFile(filename+ChecksumSuffix, []byte(line), 0o644)
+}
+
+// readChecksumFile reads the sidecar checksum of an export. The name
+// in the sidecar is not required to match, so that exports may be
+// renamed together with their sidecars.
+func readChecksumFile(filename string) ([]byte, error) {
+	b, err := ioutil.ReadFile(filename + ChecksumSuffix)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("historytrends: malformed checksum file: %s", filepath.Base(filename)+ChecksumSuffix)
+	}
+	sum, err := hex.DecodeString(fields[0])
+	if err != nil || len(sum) != sha256.Size {
+		return nil, fmt.Errorf("historytrends: malformed checksum in %s", filepath.Base(filename)+ChecksumSuffix)
+	}
+	return sum, nil
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package historytrends
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/chrome"
+)
+
+func TestChecksum(t *testing.T) {
+	ex := &Export{
+		Type:       ArchivedExport,
+		ExportTime: time.Date(2021, 2, 18, 0, 0, 0, 0, time.UTC),
+		Visits: []Visit{
+			{URL: "https://a.example/", VisitTime: time.Unix(1613610123, 456789000).UTC(), Transition: chrome.TransitionTyped, PageTitle: "A"},
+			{URL: "https://b.example/", VisitTime: time.Unix(1613610124, 0).UTC(), Transition: chrome.TransitionLink, PageTitle: "B"},
+		},
+	}
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "exported_archived_history_20210218.tsv")
+	if err := ex.WriteFile(filename, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyChecksum(filename); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenVerifiedReader(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.ReadAll()
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Visits, ex.Visits) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got.Visits, ex.Visits)
+	}
+
+	// Append a visit after the checksum was written.
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("https://c.example/\tU1613610125000\t0\tC\r\n")
+	f.Close()
+	if err := VerifyChecksum(filename); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("got error %v, want %v", err, ErrChecksumMismatch)
+	}
+	if _, err := OpenVerifiedReader(filename); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("got error %v, want %v", err, ErrChecksumMismatch)
+	}
+	if err := WriteChecksum(filename); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyChecksum(filename); err != nil {
+		t.Error(err)
+	}
+
+	unsummed := filepath.Join(dir, "exported_archived_history_20210219.tsv")
+	if err := ex.WriteFile(unsummed, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyChecksum(unsummed); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v, want %v", err, os.ErrNotExist)
+	}
+}
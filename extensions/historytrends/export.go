@@ -22,6 +22,7 @@ import (
 type Export struct {
 	Filename   string // filename of tsv within zip or as given
 	Type       ExportType
+	Version    Version // column layout
 	ExportTime time.Time
 	Visits     []Visit
 }
@@ -34,6 +35,7 @@ type Visit struct {
 	VisitTime  time.Time // UTC
 	Transition chrome.PageTransition
 	PageTitle  string
+	VisitID    int64 // Chrome visit ID, when recovered by RecoverTransitions
 }
 
 // ExportType is the format of export.
@@ -7,21 +7,26 @@
 package historytrends
 
 import (
-	"encoding/csv"
+	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/andrewarchi/archive"
+	"github.com/andrewarchi/browser"
 )
 
 // Reader reads a History Trends Unlimited browsing history export.
 type Reader struct {
-	cr       *csv.Reader
+	tr       tsvReader
 	typ      ExportType
+	version  Version   // detected on first record
 	filename string    // filename of tsv within zip or as given
 	time     time.Time // export time
 	tz       int       // timezone offset in seconds (analysis exports-only)
@@ -37,11 +42,8 @@ type ReadCloser struct {
 
 // NewReader returns a new Reader that reads from r.
 func NewReader(r io.Reader, exportTime time.Time) *Reader {
-	cr := csv.NewReader(r)
-	cr.Comma = '\t'
-	cr.LazyQuotes = true
 	return &Reader{
-		cr:   cr,
+		tr:   newTSVReader(r),
 		typ:  0, // detect on first record
 		time: exportTime,
 	}
@@ -61,12 +63,9 @@ func OpenReader(filename string) (*ReadCloser, error) {
 		return nil, err
 	}
 
-	cr := csv.NewReader(r)
-	cr.Comma = '\t'
-	cr.LazyQuotes = true
 	rc := &ReadCloser{
 		Reader: Reader{
-			cr:       cr,
+			tr:       newTSVReader(r),
 			typ:      typ,
 			filename: filepath.Base(name),
 			time:     exportTime,
@@ -149,48 +148,79 @@ func (r *Reader) Read() (*Visit, error) {
 
 func (r *Reader) read() (*Visit, error) {
 	r.record++
-	record, err := r.cr.Read()
+	record, err := r.tr.read()
 	if err != nil {
 		return nil, err
 	}
+	return r.parse(record)
+}
 
-	var typ ExportType
-	switch len(record) {
-	case 8:
-		typ = AnalysisExport
-	case 4, 3:
-		typ = ArchivedExport
-	default:
-		return nil, fmt.Errorf("record with %d fields is not an export", len(record))
-	}
-	if r.typ == 0 { // infer export type
-		r.typ = typ
-	} else if r.typ != typ {
-		return nil, fmt.Errorf("record with %d fields cannot be %s export", len(record), r.typ)
+func (r *Reader) parse(record []string) (*Visit, error) {
+	if r.version == 0 { // infer layout and export type
+		v, err := detectVersion(record)
+		if err != nil {
+			return nil, err
+		}
+		if r.typ != 0 && r.typ != v.Type() {
+			return nil, fmt.Errorf("record with %d fields cannot be %s export", len(record), r.typ)
+		}
+		r.version, r.typ = v, v.Type()
+	} else if len(record) != r.version.Fields() {
+		return nil, fmt.Errorf("record with %d fields cannot be %s export with %d fields",
+			len(record), r.version, r.version.Fields())
 	}
 
-	if typ == AnalysisExport {
+	switch r.version {
+	case Analysis:
 		return r.readAnalysisVisit(record[0], record[1], record[2], record[3],
 			record[4], record[5], record[6], record[7])
-	} else if len(record) == 3 {
+	case ArchivedNoTitle:
 		return r.readArchivedVisit(record[0], record[1], record[2], "")
+	default:
+		return r.readArchivedVisit(record[0], record[1], record[2], record[3])
 	}
-	return r.readArchivedVisit(record[0], record[1], record[2], record[3])
 }
 
-// ReadAll reads all visits in an export.
+// ReadAll reads all visits in an export and handles malformed records
+// as by ReadAllOptions with the zero Options.
 func (r *Reader) ReadAll() (*Export, error) {
+	return r.ReadAllOptions(browser.Options{})
+}
+
+// ReadAllOptions reads all visits in an export and handles malformed
+// records by the error policy in opts. An error reading the file skips
+// the whole export, unless the policy is browser.FailFast.
+func (r *Reader) ReadAllOptions(opts browser.Options) (*Export, error) {
+	h := opts.Handler()
 	var visits []Visit
 	for {
-		visit, err := r.Read()
+		r.record++
+		record, err := r.tr.read()
 		if err == io.EOF {
-			return &Export{r.filename, r.typ, r.time, visits}, nil
+			break
 		}
 		if err != nil {
-			return nil, err
+			if err := h.File(r.filename, fmt.Errorf("historytrends: %w", err)); err != nil {
+				return nil, err
+			}
+			visits = nil
+			break
+		}
+		visit, err := r.parse(record)
+		if err != nil {
+			skipFile, err := h.Record(r.filename, r.record, fmt.Errorf("historytrends: %w", err))
+			if err != nil {
+				return nil, err
+			}
+			if skipFile {
+				visits = nil
+				break
+			}
+			continue
 		}
 		visits = append(visits, *visit)
 	}
+	return &Export{r.filename, r.typ, r.version, r.time, visits}, h.Err()
 }
 
 // ExportTime returns the time of export. For analysis exports, the
@@ -198,6 +228,10 @@ func (r *Reader) ReadAll() (*Export, error) {
 // record. For archived exports, the timezone is always UTC.
 func (r *Reader) ExportTime() time.Time { return r.time }
 
+// Version returns the layout of the export. It is zero until the first
+// record is read.
+func (r *Reader) Version() Version { return r.version }
+
 // Close closes the underlying io.ReadCloser.
 func (r *ReadCloser) Close() error { return r.rc.Close() }
 
@@ -208,5 +242,69 @@ func normalizeTitle(title string) string {
 	// utils.formatTitle in utils.js replaces /[\t\r\n]/g, then
 	// /\s\s+/g with ' ', which overlooks non-repeated Unicode spaces
 	// (JavaScript \s matches Unicode spaces, unlike Go).
-	return spacePattern.ReplaceAllString(title, " ")
+	//
+	// Most titles are ASCII without repeated spaces, so the regexp is
+	// only needed otherwise.
+	for i := 0; i < len(title); i++ {
+		if c := title[i]; c >= utf8.RuneSelf || c == ' ' && i+1 < len(title) && title[i+1] == ' ' {
+			return spacePattern.ReplaceAllString(title, " ")
+		}
+	}
+	return title
+}
+
+// tsvReader reads tab-separated records, as written by History Trends
+// Unlimited. Unlike csv.Reader, fields are never quoted, so a title
+// starting with a quote is read literally. Blank lines are skipped.
+//
+// Each line is converted to a string once and the fields are
+// substrings of it, so reading a record makes a single allocation. The
+// line buffer and fields slice are reused, so the returned slice is
+// only valid until the next call to read.
+type tsvReader struct {
+	br     *bufio.Reader
+	line   []byte // for lines longer than the bufio.Reader buffer
+	fields []string
+}
+
+func newTSVReader(r io.Reader) tsvReader {
+	return tsvReader{br: bufio.NewReaderSize(r, 64<<10)}
+}
+
+func (r *tsvReader) read() ([]string, error) {
+	for {
+		line, err := r.br.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			r.line = append(r.line[:0], line...)
+			for err == bufio.ErrBufferFull {
+				line, err = r.br.ReadSlice('\n')
+				r.line = append(r.line, line...)
+			}
+			line = r.line
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line = bytes.TrimSuffix(line, []byte{'\n'})
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		if len(line) == 0 {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			continue
+		}
+
+		s := string(line)
+		fields := r.fields[:0]
+		for {
+			i := strings.IndexByte(s, '\t')
+			if i == -1 {
+				break
+			}
+			fields = append(fields, s[:i])
+			s = s[i+1:]
+		}
+		r.fields = append(fields, s)
+		return r.fields, nil
+	}
 }
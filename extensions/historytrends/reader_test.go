@@ -0,0 +1,120 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package historytrends
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/chrome"
+)
+
+func TestReader(t *testing.T) {
+	const data = "https://a.example/\tU1613610123456.789\t1\tA\r\n" +
+		"\r\n" +
+		"https://b.example/\tU1613610124000\t0\t\"Quoted\" title  with spaces\r\n" +
+		"https://c.example/\tU1613610125000\t805306376\t"
+	r := NewReader(strings.NewReader(data), time.Time{})
+	ex, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Visit{
+		{URL: "https://a.example/", VisitTime: time.Unix(1613610123, 456789000).UTC(), Transition: chrome.TransitionTyped, PageTitle: "A"},
+		{URL: "https://b.example/", VisitTime: time.Unix(1613610124, 0).UTC(), Transition: chrome.TransitionLink, PageTitle: `"Quoted" title with spaces`},
+		{URL: "https://c.example/", VisitTime: time.Unix(1613610125, 0).UTC(), Transition: 805306376},
+	}
+	if ex.Version != ArchivedUnixTime || !reflect.DeepEqual(ex.Visits, want) {
+		t.Errorf("got %s export:\n%v\nwant:\n%v", ex.Version, ex.Visits, want)
+	}
+
+	r = NewReader(strings.NewReader("https://a.example/\tU1613610123456\t1\tA\nhttps://b.example/\tU1613610123456\t1\n"), time.Time{})
+	if _, err := r.ReadAll(); err == nil || !strings.Contains(err.Error(), "record 2") {
+		t.Errorf("got error %v, want error for record 2", err)
+	}
+
+	const malformed = "https://a.example/\tU1613610123456\t1\tA\nhttps://b.example/\tU1613610123456\t1\nhttps://c.example/\tU1613610123456\t1\tC\n"
+	for _, tt := range []struct {
+		policy browser.ErrorPolicy
+		visits int
+		errs   int
+	}{
+		{browser.Collect, 2, 1},
+		{browser.SkipRecord, 2, 0},
+		{browser.SkipFile, 0, 0},
+	} {
+		ex, err := NewReader(strings.NewReader(malformed), time.Time{}).ReadAllOptions(browser.Options{OnError: tt.policy})
+		var errs browser.Errors
+		errors.As(err, &errs)
+		if (err != nil) != (tt.errs != 0) || len(errs) != tt.errs || len(ex.Visits) != tt.visits {
+			t.Errorf("%s: got %d visits and error %v, want %d visits and %d errors", tt.policy, len(ex.Visits), err, tt.visits, tt.errs)
+		}
+	}
+	_, err = NewReader(strings.NewReader(malformed), time.Time{}).ReadAllOptions(browser.Options{OnError: browser.FailFast})
+	if e := (*browser.Error)(nil); !errors.As(err, &e) || e.Record != 2 {
+		t.Errorf("got error %v, want error for record 2", err)
+	}
+}
+
+func TestReaderLongLine(t *testing.T) {
+	long := "https://a.example/?q=" + strings.Repeat("x", 100000)
+	data := long + "\tU1613610123456\t1\tA\r\nhttps://b.example/\tU1613610123456\t1\tB\r\n"
+	ex, err := NewReader(strings.NewReader(data), time.Time{}).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ex.Visits) != 2 || ex.Visits[0].URL != long || ex.Visits[1].PageTitle != "B" {
+		t.Errorf("got visits %v", ex.Visits)
+	}
+}
+
+// benchmarkExport generates an export of about size bytes.
+func benchmarkExport(typ ExportType, size int) []byte {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, typ, time.Time{}.In(time.UTC))
+	t := time.Date(2021, 2, 18, 0, 0, 0, 0, time.UTC)
+	for i := 0; buf.Len() < size; i++ {
+		w.Write(&Visit{
+			URL:        fmt.Sprintf("https://www.example.com/articles/%d?utm_source=feed&id=%d", i%5000, i),
+			VisitTime:  t.Add(time.Duration(i) * 1234567 * time.Microsecond),
+			Transition: chrome.TransitionLink,
+			PageTitle:  fmt.Sprintf("Article %d - Example News: an ordinary page title", i%5000),
+		})
+		if i%1024 == 0 {
+			w.Flush()
+		}
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+func BenchmarkReaderArchived(b *testing.B) { benchmarkReader(b, ArchivedExport) }
+func BenchmarkReaderAnalysis(b *testing.B) { benchmarkReader(b, AnalysisExport) }
+
+func benchmarkReader(b *testing.B, typ ExportType) {
+	data := benchmarkExport(typ, 64<<20)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := NewReader(bytes.NewReader(data), time.Time{})
+		for {
+			if _, err := r.Read(); err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package historytrends
+
+import (
+	"time"
+
+	"github.com/andrewarchi/browser/chrome"
+)
+
+// recoverTolerance is the maximum difference between the visit time in
+// an export and in the History database for the visits to be joined.
+// History Trends Unlimited receives visit times from the extension API
+// as floating point milliseconds, so the microseconds may be rounded.
+const recoverTolerance = time.Millisecond
+
+// RecoverTransitions joins visits against the visits in a Chrome
+// History database from the same machine to recover the full page
+// transition, including qualifiers, and the visit ID. Analysis exports
+// only contain the core transition type, so the qualifiers are
+// otherwise lost. Visits are joined by URL and visit time and only
+// updated when the core transition types agree. It returns the number
+// of visits updated.
+//
+// Chrome expires visits after 90 days, so only recent visits can be
+// recovered.
+func RecoverTransitions(visits []Visit, h *chrome.History) (int, error) {
+	dbVisits, err := h.Visits()
+	if err != nil {
+		return 0, err
+	}
+	byURL := make(map[string][]*chrome.HistoryVisit)
+	for i := range dbVisits {
+		v := &dbVisits[i]
+		byURL[v.URL] = append(byURL[v.URL], v)
+	}
+
+	n := 0
+	for i := range visits {
+		v := &visits[i]
+		var best *chrome.HistoryVisit
+		var bestDiff time.Duration
+		for _, dv := range byURL[v.URL] {
+			diff := dv.VisitTime.Sub(v.VisitTime)
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff <= recoverTolerance && (best == nil || diff < bestDiff) {
+				best, bestDiff = dv, diff
+			}
+		}
+		if best == nil || best.Transition&chrome.TransitionCoreMask != v.Transition&chrome.TransitionCoreMask {
+			continue
+		}
+		v.Transition = best.Transition
+		v.VisitID = best.ID
+		n++
+	}
+	return n, nil
+}
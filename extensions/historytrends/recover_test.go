@@ -0,0 +1,109 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package historytrends
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/chrome"
+)
+
+func TestRecoverTransitions(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "History")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// Times are microseconds since 1601; 13258083723456789 is
+	// 2021-02-18 01:02:03.456789 UTC. Transition 805306369 is typed
+	// with chain start and end qualifiers and -1610612736 is a link at
+	// the end of a server redirect chain.
+	for _, q := range []string{
+		`CREATE TABLE urls (id INTEGER PRIMARY KEY AUTOINCREMENT, url LONGVARCHAR, title LONGVARCHAR,
+			visit_count INTEGER DEFAULT 0 NOT NULL, typed_count INTEGER DEFAULT 0 NOT NULL,
+			last_visit_time INTEGER NOT NULL, hidden INTEGER DEFAULT 0 NOT NULL)`,
+		`CREATE TABLE visits (id INTEGER PRIMARY KEY, url INTEGER NOT NULL, visit_time INTEGER NOT NULL,
+			from_visit INTEGER, transition INTEGER DEFAULT 0 NOT NULL, segment_id INTEGER,
+			visit_duration INTEGER DEFAULT 0 NOT NULL)`,
+		`INSERT INTO urls VALUES (1, 'https://a.example/', 'A', 1, 1, 13258083723456789, 0)`,
+		`INSERT INTO urls VALUES (2, 'https://b.example/', 'B', 2, 0, 13258083725000000, 0)`,
+		`INSERT INTO urls VALUES (3, 'https://c.example/', 'C', 1, 0, 13258083727000000, 1)`,
+		`INSERT INTO visits VALUES (1, 1, 13258083723456789, 0, 805306369, NULL, 1500000)`,
+		`INSERT INTO visits VALUES (2, 2, 13258083724000900, 1, -1610612736, 0, 0)`,
+		`INSERT INTO visits VALUES (3, 2, 13258083724000200, 1, -1610612736, 0, 0)`,
+		`INSERT INTO visits VALUES (4, 3, 13258083726002000, 0, -1610612736, 0, 0)`,
+		`INSERT INTO visits VALUES (5, 1, 13258083727000000, 0, 805306369, 0, 0)`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h, err := chrome.OpenHistory(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	at := func(usec int64) time.Time { return time.Unix(0, usec*1000).UTC() }
+	urls, err := h.URLs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) != 3 || urls[0].TypedCount != 1 || !urls[0].LastVisitTime.Equal(at(1613610123456789)) || !urls[2].Hidden {
+		t.Errorf("got URLs %+v", urls)
+	}
+	dbVisits, err := h.Visits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for _, v := range dbVisits {
+		ids = append(ids, v.ID)
+	}
+	if want := []int64{1, 3, 2, 4, 5}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got visits ordered %v, want %v", ids, want)
+	}
+	if v := dbVisits[0]; v.URL != "https://a.example/" || v.Title != "A" || v.Transition != 805306369 ||
+		v.VisitDuration != 1500*time.Millisecond || !v.VisitTime.Equal(at(1613610123456789)) {
+		t.Errorf("got visit %+v", v)
+	}
+	if v := dbVisits[1]; v.Transition != 0xa0000000 || v.FromVisit != 1 {
+		t.Errorf("got redirect visit %+v", v)
+	}
+
+	// The export rounds times to milliseconds and has only core
+	// transitions: the first visit matches exactly, the second is
+	// nearest to visit 3, the third is over a millisecond apart, and the
+	// fourth has a different core transition.
+	const export = "https://a.example/\tU1613610123456.789\t1\tA\r\n" +
+		"https://b.example/\tU1613610124000\t0\tB\r\n" +
+		"https://c.example/\tU1613610126000\t0\tC\r\n" +
+		"https://a.example/\tU1613610127000\t0\tA\r\n"
+	ex, err := NewReader(strings.NewReader(export), time.Time{}).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := RecoverTransitions(ex.Visits, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Visit{
+		{URL: "https://a.example/", VisitTime: at(1613610123456789), Transition: 805306369, PageTitle: "A", VisitID: 1},
+		{URL: "https://b.example/", VisitTime: at(1613610124000000), Transition: 0xa0000000, PageTitle: "B", VisitID: 3},
+		{URL: "https://c.example/", VisitTime: at(1613610126000000), Transition: chrome.TransitionLink, PageTitle: "C"},
+		{URL: "https://a.example/", VisitTime: at(1613610127000000), Transition: chrome.TransitionLink, PageTitle: "A"},
+	}
+	if n != 2 || !reflect.DeepEqual(ex.Visits, want) {
+		t.Errorf("got %d recovered:\n%+v\nwant 2:\n%+v", n, ex.Visits, want)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package historytrends
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Version is the column layout of an export, which has changed across
+// versions of History Trends Unlimited. It is detected from the first
+// record of an export and all records must have the same layout.
+type Version uint8
+
+// Values for Version:
+const (
+	_ Version = iota
+	// ArchivedNoTitle is an archived export with URL, visit time, and
+	// transition, but no page title.
+	ArchivedNoTitle
+	// ArchivedWindowsTime is an archived export with visit times in
+	// Windows microseconds (< v1.4.1).
+	ArchivedWindowsTime
+	// ArchivedUnixTime is an archived export with visit times in Unix
+	// milliseconds, prefixed with "U" (>= v1.4.1).
+	ArchivedUnixTime
+	// Analysis is an analysis export with 8 columns.
+	Analysis
+)
+
+// detectVersion detects the layout of an export from its first record.
+func detectVersion(record []string) (Version, error) {
+	switch len(record) {
+	case 8:
+		return Analysis, nil
+	case 4:
+		if strings.HasPrefix(record[1], "U") {
+			return ArchivedUnixTime, nil
+		}
+		return ArchivedWindowsTime, nil
+	case 3:
+		return ArchivedNoTitle, nil
+	default:
+		return 0, fmt.Errorf("record with %d fields is not an export", len(record))
+	}
+}
+
+// Type returns the export type of the layout.
+func (v Version) Type() ExportType {
+	switch v {
+	case ArchivedNoTitle, ArchivedWindowsTime, ArchivedUnixTime:
+		return ArchivedExport
+	case Analysis:
+		return AnalysisExport
+	default:
+		return 0
+	}
+}
+
+// Fields returns the number of fields in each record of the layout.
+func (v Version) Fields() int {
+	switch v {
+	case ArchivedNoTitle:
+		return 3
+	case ArchivedWindowsTime, ArchivedUnixTime:
+		return 4
+	case Analysis:
+		return 8
+	default:
+		return 0
+	}
+}
+
+func (v Version) String() string {
+	switch v {
+	case ArchivedNoTitle:
+		return "archived without title"
+	case ArchivedWindowsTime:
+		return "archived with Windows time"
+	case ArchivedUnixTime:
+		return "archived with Unix time"
+	case Analysis:
+		return "analysis"
+	default:
+		return fmt.Sprintf("version(%d)", uint8(v))
+	}
+}
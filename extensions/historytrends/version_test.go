@@ -0,0 +1,53 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package historytrends
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectVersion(t *testing.T) {
+	for _, tt := range []struct {
+		record []string
+		want   Version
+	}{
+		{[]string{"https://a.example/", "13258083723456789", "1"}, ArchivedNoTitle},
+		{[]string{"https://a.example/", "13258083723456789", "1", "A"}, ArchivedWindowsTime},
+		{[]string{"https://a.example/", "U1613610123456.789", "1", "A"}, ArchivedUnixTime},
+		{[]string{"https://a.example/", "a.example", "a.example", "1613610123456.789", "2021-02-18 01:02:03.456", "4", "link", "A"}, Analysis},
+		{nil, 0},
+		{[]string{"https://a.example/"}, 0},
+		{[]string{"https://a.example/", "U1613610123456"}, 0},
+		{[]string{"https://a.example/", "U1613610123456", "1", "A", "extra"}, 0},
+	} {
+		got, err := detectVersion(tt.record)
+		if got != tt.want || (err != nil) != (tt.want == 0) {
+			t.Errorf("%q: got %s, %v, want %s", tt.record, got, err, tt.want)
+		}
+		if tt.want != 0 && got.Fields() != len(tt.record) {
+			t.Errorf("%q: %s has %d fields", tt.record, got, got.Fields())
+		}
+	}
+}
+
+func TestReaderMalformedFirstRecord(t *testing.T) {
+	for _, data := range []string{
+		"https://a.example/\r\n",
+		"https://a.example/\tU1613610123456\t1\tA\textra\r\nhttps://b.example/\tU1613610123456\t1\tB\r\n",
+	} {
+		r := NewReader(strings.NewReader(data), time.Time{})
+		v, err := r.Read()
+		if err == nil || !strings.Contains(err.Error(), "record 1") {
+			t.Errorf("%q: got visit %v and error %v, want error for record 1", data, v, err)
+		}
+		if r.Version() != 0 {
+			t.Errorf("%q: got %s export", data, r.Version())
+		}
+	}
+}
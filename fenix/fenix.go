@@ -0,0 +1,149 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package fenix parses the data of Firefox for Android (Fenix), which
+// stores history, bookmarks, and logins in the databases of Mozilla
+// application-services, rather than in a Gecko profile, and stores
+// telemetry and experiments with Glean and Nimbus.
+//
+// Data directories are found in a copy of the Android data directory,
+// such as a directory tree pulled from /data/data or an extracted adb
+// backup.
+package fenix
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Packages are the Android package names of Fenix, by channel.
+var Packages = map[string]string{
+	"org.mozilla.firefox":       "release",
+	"org.mozilla.firefox_beta":  "beta",
+	"org.mozilla.fenix":         "nightly",
+	"org.mozilla.fennec_aurora": "nightly", // before the nightly moved to org.mozilla.fenix
+}
+
+// App is the data directory of an installed Fenix package.
+type App struct {
+	Package   string // e.g. "org.mozilla.firefox"
+	Channel   string // "release", "beta", or "nightly"
+	Dir       string // app data directory
+	Databases string // directory of places.sqlite and logins2.sqlite
+	Files     string // directory of glean_data, nimbus_data, and the Gecko profile
+}
+
+// Layouts of the app data directory, relative to the search root. An
+// adb backup has the databases in db and the files in f, rather than
+// in databases and files.
+var layouts = []struct {
+	dir              string
+	databases, files string
+}{
+	{"data/data", "databases", "files"},
+	{"data/user/0", "databases", "files"},
+	{"data/data", "db", "f"},
+	{"apps", "db", "f"},
+	{".", "databases", "files"},
+	{".", "db", "f"},
+}
+
+// FindApps finds the data directories of Fenix packages under root,
+// which may be the root of an Android file system, a directory
+// containing package directories, an extracted adb backup, or a
+// package directory itself. Apps are ordered by directory.
+func FindApps(root string) ([]App, error) {
+	if _, err := os.Stat(root); err != nil {
+		return nil, err
+	}
+	var apps []App
+	seen := make(map[string]bool)
+	for _, l := range layouts {
+		var dirs []string
+		if l.dir == "." {
+			dirs = []string{filepath.Clean(root)} // root is a package directory
+		} else {
+			for pkg := range Packages {
+				dirs = append(dirs, filepath.Join(root, filepath.FromSlash(l.dir), pkg))
+			}
+		}
+		for _, dir := range dirs {
+			pkg := filepath.Base(filepath.Clean(dir))
+			channel, ok := Packages[pkg]
+			if !ok || seen[dir] {
+				continue
+			}
+			app := App{
+				Package:   pkg,
+				Channel:   channel,
+				Dir:       dir,
+				Databases: filepath.Join(dir, l.databases),
+				Files:     filepath.Join(dir, l.files),
+			}
+			if !isDir(app.Databases) && !isDir(app.Files) {
+				continue
+			}
+			seen[dir] = true
+			apps = append(apps, app)
+		}
+	}
+	sort.Slice(apps, func(i, j int) bool {
+		return apps[i].Dir < apps[j].Dir
+	})
+	return apps, nil
+}
+
+// PlacesPath returns the path of the application-services places
+// database of history and bookmarks.
+func (a *App) PlacesPath() string {
+	return filepath.Join(a.Databases, "places.sqlite")
+}
+
+// LoginsPath returns the path of the application-services logins
+// database. Before the logins were encrypted per field, Fenix stored
+// them in logins.sqlite, which is encrypted with SQLCipher and is not
+// read.
+func (a *App) LoginsPath() string {
+	return filepath.Join(a.Databases, "logins2.sqlite")
+}
+
+// GleanDir returns the path of the Glean data directory.
+func (a *App) GleanDir() string {
+	return filepath.Join(a.Files, "glean_data")
+}
+
+// NimbusDir returns the path of the Nimbus data directory.
+func (a *App) NimbusDir() string {
+	return filepath.Join(a.Files, "nimbus_data")
+}
+
+// GeckoProfiles returns the directories of the Gecko profiles of the
+// app, which hold cookies, site permissions, and other data of the
+// engine, in the same formats as in desktop Firefox, and which can be
+// read by the firefox package. Profiles are sorted.
+func (a *App) GeckoProfiles() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(a.Files, "mozilla"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var profiles []string
+	for _, e := range entries {
+		if e.IsDir() && strings.Contains(e.Name(), ".default") {
+			profiles = append(profiles, filepath.Join(a.Files, "mozilla", e.Name()))
+		}
+	}
+	return profiles, nil
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
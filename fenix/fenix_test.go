@@ -0,0 +1,69 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package fenix
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindApps(t *testing.T) {
+	root := t.TempDir()
+	dirs := []string{
+		"data/data/org.mozilla.firefox/databases",
+		"data/data/org.mozilla.firefox/files/mozilla/abcd1234.default",
+		"data/data/org.mozilla.firefox/files/mozilla/Crash Reports",
+		"data/data/org.mozilla.fenix/files/glean_data",
+		"data/data/com.example.other/databases",
+		"apps/org.mozilla.firefox_beta/db",
+		"apps/org.mozilla.firefox_beta/f",
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(filepath.Join(root, filepath.FromSlash(dir)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	apps, err := FindApps(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	join := func(elem ...string) string {
+		return filepath.Join(append([]string{root}, elem...)...)
+	}
+	want := []App{
+		{"org.mozilla.firefox_beta", "beta", join("apps", "org.mozilla.firefox_beta"),
+			join("apps", "org.mozilla.firefox_beta", "db"), join("apps", "org.mozilla.firefox_beta", "f")},
+		{"org.mozilla.fenix", "nightly", join("data", "data", "org.mozilla.fenix"),
+			join("data", "data", "org.mozilla.fenix", "databases"), join("data", "data", "org.mozilla.fenix", "files")},
+		{"org.mozilla.firefox", "release", join("data", "data", "org.mozilla.firefox"),
+			join("data", "data", "org.mozilla.firefox", "databases"), join("data", "data", "org.mozilla.firefox", "files")},
+	}
+	if !reflect.DeepEqual(apps, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", apps, want)
+	}
+
+	profiles, err := apps[2].GeckoProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantProfiles := []string{join("data", "data", "org.mozilla.firefox", "files", "mozilla", "abcd1234.default")}
+	if !reflect.DeepEqual(profiles, wantProfiles) {
+		t.Errorf("got %q, want %q", profiles, wantProfiles)
+	}
+
+	// A package directory itself
+	apps, err = FindApps(join("data", "data", "org.mozilla.fenix"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apps) != 1 || apps[0].Package != "org.mozilla.fenix" {
+		t.Errorf("got %+v, want org.mozilla.fenix", apps)
+	}
+}
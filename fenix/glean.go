@@ -0,0 +1,282 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package fenix
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/andrewarchi/browser"
+)
+
+// Glean storage format:
+// https://github.com/mozilla/glean/blob/main/glean-core/src/database/mod.rs
+// https://github.com/mozilla/glean/blob/main/glean-core/src/metrics/mod.rs
+// https://github.com/mozilla/glean/blob/main/glean-core/src/upload/directory.rs
+//
+// Glean keeps metrics in db/data.safe.bin, in a store for each
+// lifetime, keyed by "{storage}#{metric}", where the storage is
+// usually the name of the ping that the metric is sent in. Values are
+// the bincode serialization of the Metric enum. Pings that are
+// assembled, but not yet uploaded, are kept in pending_pings and
+// deletion_request as files of the upload path, the JSON payload, and,
+// optionally, the JSON metadata, on separate lines.
+
+// GleanMetric is a recorded metric.
+type GleanMetric struct {
+	Lifetime string // "ping", "application", or "user"
+	Storage  string // e.g. "metrics" or "glean_client_info"
+	ID       string // e.g. "browser.default_search_engine"
+	Type     GleanMetricType
+	Value    interface{} // bool, int64, string, []string, or, for other types, nil
+	Raw      []byte      // bincode serialization of the value
+}
+
+// GleanMetricType is the type of a metric, as the index of its variant
+// in the Metric enum.
+type GleanMetricType uint32
+
+// Values for GleanMetricType:
+const (
+	GleanBoolean GleanMetricType = iota
+	GleanCounter
+	GleanCustomDistributionExponential
+	GleanCustomDistributionLinear
+	GleanDatetime
+	GleanExperiment
+	GleanQuantity
+	GleanString
+	GleanStringList
+	GleanUUID
+	GleanTimespan
+	GleanTimingDistribution
+	GleanMemoryDistribution
+	GleanJWE
+	GleanRate
+	GleanURL
+	GleanText
+)
+
+// GleanPing is a ping that is pending upload.
+type GleanPing struct {
+	DocumentID string // file name
+	Path       string // e.g. "/submit/org-mozilla-firefox/metrics/1/{document_id}"
+	Payload    json.RawMessage
+	Metadata   json.RawMessage // optional, e.g. headers
+}
+
+// Glean directories:
+const (
+	gleanDBDir              = "db"
+	gleanPendingPingsDir    = "pending_pings"
+	gleanDeletionRequestDir = "deletion_request"
+)
+
+var gleanLifetimes = []string{"ping", "application", "user"}
+
+// ParseGleanMetrics reads the metrics in a Glean data directory,
+// ordered by lifetime, then by key. Malformed metrics are handled as by
+// ParseGleanMetricsOptions with the zero Options.
+func ParseGleanMetrics(dir string) ([]GleanMetric, error) {
+	return ParseGleanMetricsOptions(dir, browser.Options{})
+}
+
+// ParseGleanMetricsOptions reads the metrics in a Glean data directory
+// and handles malformed metrics, which are the records of the database,
+// by the error policy in opts.
+func ParseGleanMetricsOptions(dir string, opts browser.Options) ([]GleanMetric, error) {
+	h := opts.Handler()
+	filename := filepath.Join(dir, gleanDBDir, rkvSafeModeFile)
+	stores, err := readRkv(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	} else if err != nil {
+		if err := h.File(filename, err); err != nil {
+			return nil, err
+		}
+		return nil, h.Err()
+	}
+	var metrics []GleanMetric
+	record := 0
+	for _, lifetime := range gleanLifetimes {
+		store := stores[lifetime]
+		for _, key := range sortedKeys(store) {
+			record++
+			m, err := decodeGleanMetric(lifetime, key, store[key])
+			if err != nil {
+				skipFile, err := h.Record(filename, record, fmt.Errorf("fenix: glean metric %s: %w", key, err))
+				if err != nil {
+					return nil, err
+				} else if skipFile {
+					return nil, h.Err()
+				}
+				continue
+			}
+			metrics = append(metrics, *m)
+		}
+	}
+	return metrics, h.Err()
+}
+
+func decodeGleanMetric(lifetime, key string, value []byte) (*GleanMetric, error) {
+	b, err := decodeRkvValue(value, rkvBlob)
+	if err != nil {
+		return nil, err
+	}
+	m := &GleanMetric{Lifetime: lifetime, ID: key, Raw: b}
+	if i := strings.IndexByte(key, '#'); i != -1 {
+		m.Storage, m.ID = key[:i], key[i+1:]
+	}
+	if err := m.decode(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// decode decodes the type of the metric and its value, for types with
+// simple values.
+func (m *GleanMetric) decode() error {
+	d := &bincodeDecoder{b: m.Raw}
+	m.Type = GleanMetricType(d.u32())
+	switch m.Type {
+	case GleanBoolean:
+		m.Value = d.bool()
+	case GleanCounter:
+		m.Value = int64(d.i32())
+	case GleanQuantity:
+		m.Value = d.i64()
+	case GleanDatetime, GleanString, GleanUUID, GleanJWE, GleanURL, GleanText:
+		// Datetimes are serialized as RFC 3339 strings, followed by the
+		// time unit.
+		m.Value = d.str()
+	case GleanStringList:
+		n := d.len()
+		list := make([]string, 0, n)
+		for i := uint64(0); i < n && d.err == nil; i++ {
+			list = append(list, d.str())
+		}
+		m.Value = list
+	}
+	if d.err != nil {
+		m.Value = nil
+		return d.err
+	}
+	return nil
+}
+
+// ParseGleanPings reads the pings pending upload in a Glean data
+// directory, including deletion-request pings, ordered by path. Ping
+// files that fail to parse are handled as by ParseGleanPingsOptions with
+// the zero Options.
+func ParseGleanPings(dir string) ([]GleanPing, error) {
+	return ParseGleanPingsOptions(dir, browser.Options{})
+}
+
+// ParseGleanPingsOptions reads the pings pending upload in a Glean data
+// directory and handles ping files that fail to parse by the error
+// policy in opts.
+func ParseGleanPingsOptions(dir string, opts browser.Options) ([]GleanPing, error) {
+	h := opts.Handler()
+	var pings []GleanPing
+	for _, sub := range []string{gleanPendingPingsDir, gleanDeletionRequestDir} {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			filename := filepath.Join(dir, sub, e.Name())
+			p, err := parseGleanPing(filename)
+			if err != nil {
+				if err := h.File(filename, err); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			pings = append(pings, *p)
+		}
+	}
+	sort.SliceStable(pings, func(i, j int) bool {
+		return pings[i].Path < pings[j].Path
+	})
+	return pings, h.Err()
+}
+
+func parseGleanPing(filename string) (*GleanPing, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	p := &GleanPing{DocumentID: filepath.Base(filename)}
+	lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+	if len(lines) < 2 || len(lines) > 3 {
+		return nil, fmt.Errorf("fenix: glean ping %s: has %d lines", p.DocumentID, len(lines))
+	}
+	p.Path = string(lines[0])
+	if !json.Valid(lines[1]) {
+		return nil, fmt.Errorf("fenix: glean ping %s: invalid payload", p.DocumentID)
+	}
+	p.Payload = lines[1]
+	if len(lines) == 3 {
+		if !json.Valid(lines[2]) {
+			return nil, fmt.Errorf("fenix: glean ping %s: invalid metadata", p.DocumentID)
+		}
+		p.Metadata = lines[2]
+	}
+	return p, nil
+}
+
+func (t GleanMetricType) String() string {
+	switch t {
+	case GleanBoolean:
+		return "boolean"
+	case GleanCounter:
+		return "counter"
+	case GleanCustomDistributionExponential:
+		return "custom_distribution_exponential"
+	case GleanCustomDistributionLinear:
+		return "custom_distribution_linear"
+	case GleanDatetime:
+		return "datetime"
+	case GleanExperiment:
+		return "experiment"
+	case GleanQuantity:
+		return "quantity"
+	case GleanString:
+		return "string"
+	case GleanStringList:
+		return "string_list"
+	case GleanUUID:
+		return "uuid"
+	case GleanTimespan:
+		return "timespan"
+	case GleanTimingDistribution:
+		return "timing_distribution"
+	case GleanMemoryDistribution:
+		return "memory_distribution"
+	case GleanJWE:
+		return "jwe"
+	case GleanRate:
+		return "rate"
+	case GleanURL:
+		return "url"
+	case GleanText:
+		return "text"
+	default:
+		return fmt.Sprintf("metric(%d)", uint32(t))
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package fenix
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/andrewarchi/browser"
+)
+
+func TestParseGleanMetrics(t *testing.T) {
+	dir := t.TempDir()
+	variant := func(typ GleanMetricType, data ...byte) []byte {
+		return rkvValue(rkvBlob, append([]byte{byte(typ), 0, 0, 0}, data...))
+	}
+	str := func(s string) []byte { return appendBytes(nil, []byte(s)) }
+	writeRkv(t, filepath.Join(dir, "db"), rkvStores{
+		"ping": {
+			"metrics#events.total_uri_count": variant(GleanCounter, 42, 0, 0, 0),
+			"metrics#search.default_engine":  variant(GleanString, str("google")...),
+		},
+		"application": {
+			"glean_client_info#client_id": variant(GleanUUID, str("c0ffee00-0000-4000-8000-000000000000")...),
+		},
+		"user": {
+			"glean_client_info#first_run_date": variant(GleanDatetime, append(str("2021-02-18+00:00"), 2, 0, 0, 0)...),
+			"metrics#preferences.enabled":      variant(GleanBoolean, 1),
+			"metrics#addons.enabled_addons":    variant(GleanStringList, append(appendU64(nil, 2), append(str("a@example.com"), str("b@example.com")...)...)...),
+			"metrics#perf.startup":             variant(GleanTimingDistribution, 0, 0, 0, 0, 0, 0, 0, 0),
+		},
+	})
+
+	metrics, err := ParseGleanMetrics(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type metric struct {
+		Lifetime, Storage, ID string
+		Type                  GleanMetricType
+		Value                 interface{}
+	}
+	var got []metric
+	for _, m := range metrics {
+		got = append(got, metric{m.Lifetime, m.Storage, m.ID, m.Type, m.Value})
+	}
+	want := []metric{
+		{"ping", "metrics", "events.total_uri_count", GleanCounter, int64(42)},
+		{"ping", "metrics", "search.default_engine", GleanString, "google"},
+		{"application", "glean_client_info", "client_id", GleanUUID, "c0ffee00-0000-4000-8000-000000000000"},
+		{"user", "glean_client_info", "first_run_date", GleanDatetime, "2021-02-18+00:00"},
+		{"user", "metrics", "addons.enabled_addons", GleanStringList, []string{"a@example.com", "b@example.com"}},
+		{"user", "metrics", "perf.startup", GleanTimingDistribution, nil},
+		{"user", "metrics", "preferences.enabled", GleanBoolean, true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got, want)
+	}
+}
+
+func TestParseGleanPings(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"pending_pings", "deletion_request"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		"pending_pings/8f4b":    "/submit/org-mozilla-firefox/metrics/1/8f4b\n{\"ping_info\":{\"seq\":3}}\n{\"headers\":{\"X-Debug-ID\":\"test\"}}\n",
+		"deletion_request/1a2c": "/submit/org-mozilla-firefox/deletion-request/1/1a2c\n{\"ping_info\":{\"seq\":0}}\n",
+		"pending_pings/5e6f":    "/submit/org-mozilla-firefox/metrics/1/5e6f\n{\"ping_info\":\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pings, err := ParseGleanPings(dir)
+	var errs browser.Errors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].File != filepath.Join(dir, "pending_pings", "5e6f") {
+		t.Fatalf("got error %v, want error for 5e6f", err)
+	}
+	want := []GleanPing{
+		{"1a2c", "/submit/org-mozilla-firefox/deletion-request/1/1a2c", json.RawMessage(`{"ping_info":{"seq":0}}`), nil},
+		{"8f4b", "/submit/org-mozilla-firefox/metrics/1/8f4b", json.RawMessage(`{"ping_info":{"seq":3}}`), json.RawMessage(`{"headers":{"X-Debug-ID":"test"}}`)},
+	}
+	if !reflect.DeepEqual(pings, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", pings, want)
+	}
+
+	pings, err = ParseGleanPingsOptions(dir, browser.Options{OnError: browser.SkipFile})
+	if err != nil || !reflect.DeepEqual(pings, want) {
+		t.Errorf("skip-file: got %+v, %v", pings, err)
+	}
+	pings, err = ParseGleanPingsOptions(dir, browser.Options{OnError: browser.FailFast})
+	if err == nil || errors.As(err, &errs) || pings != nil {
+		t.Errorf("fail-fast: got %+v, %v, want first error only", pings, err)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package fenix
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Logins database schema:
+// https://github.com/mozilla/application-services/blob/main/components/logins/src/schema.rs
+// https://github.com/mozilla/application-services/blob/main/components/support/jwcrypto/src/lib.rs
+//
+// Local logins are in loginsL and logins downloaded by sync are in
+// loginsM, the mirror. A local row overrides the mirror row with the
+// same GUID and deleted local rows are kept as tombstones until they
+// are synced. The username and password are in secFields as a JWE in
+// compact serialization, encrypted with AES-256-GCM by a key that the
+// app keeps encrypted by the Android Keystore.
+
+// Login is a saved login for a site.
+type Login struct {
+	GUID                string
+	Origin              string // e.g. "https://example.com"
+	HTTPRealm           string // for HTTP authentication
+	FormActionOrigin    string // for form logins
+	UsernameField       string
+	PasswordField       string
+	TimesUsed           int64
+	TimeCreated         time.Time
+	TimeLastUsed        time.Time
+	TimePasswordChanged time.Time
+	SecFields           string // encrypted username and password
+	Synced              bool   // read from the mirror
+}
+
+// SecureFields are the decrypted fields of a login.
+type SecureFields struct {
+	Username string `json:"u"`
+	Password string `json:"p"`
+}
+
+// ParseLogins reads the logins in logins2.sqlite, excluding deleted
+// logins, ordered by origin, then by GUID.
+func ParseLogins(filename string) ([]Login, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var logins []Login
+	err = sqliteutil.Query(db, `
+		SELECT guid, origin, httpRealm, formActionOrigin, usernameField, passwordField,
+			timesUsed, timeCreated, timeLastUsed, timePasswordChanged, secFields, 0
+		FROM loginsL WHERE NOT is_deleted
+		UNION ALL
+		SELECT guid, origin, httpRealm, formActionOrigin, usernameField, passwordField,
+			timesUsed, timeCreated, timeLastUsed, timePasswordChanged, secFields, 1
+		FROM loginsM WHERE NOT is_overridden AND guid NOT IN (SELECT guid FROM loginsL)
+		ORDER BY origin, guid`, func(rows *sql.Rows) error {
+		var l Login
+		var realm, action, userField, passField, secFields sql.NullString
+		var created, lastUsed, changed sql.NullInt64
+		if err := rows.Scan(&l.GUID, &l.Origin, &realm, &action, &userField, &passField,
+			&l.TimesUsed, &created, &lastUsed, &changed, &secFields, &l.Synced); err != nil {
+			return err
+		}
+		l.HTTPRealm, l.FormActionOrigin = realm.String, action.String
+		l.UsernameField, l.PasswordField = userField.String, passField.String
+		l.SecFields = secFields.String
+		l.TimeCreated = fromMilli(created)
+		l.TimeLastUsed = fromMilli(lastUsed)
+		l.TimePasswordChanged = fromMilli(changed)
+		logins = append(logins, l)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fenix: logins: %w", err)
+	}
+	return logins, nil
+}
+
+// Decrypt decrypts the username and password of the login with the
+// encryption key of the logins store, which is a JSON Web Key, as
+// stored by the app.
+func (l *Login) Decrypt(key string) (*SecureFields, error) {
+	k, err := parseJWK(key)
+	if err != nil {
+		return nil, fmt.Errorf("fenix: login %s: %w", l.GUID, err)
+	}
+	plaintext, err := decryptJWE(l.SecFields, k)
+	if err != nil {
+		return nil, fmt.Errorf("fenix: login %s: %w", l.GUID, err)
+	}
+	var fields SecureFields
+	if err := json.Unmarshal(plaintext, &fields); err != nil {
+		return nil, fmt.Errorf("fenix: login %s: %w", l.GUID, err)
+	}
+	return &fields, nil
+}
+
+// parseJWK parses a symmetric JSON Web Key.
+func parseJWK(key string) ([]byte, error) {
+	var jwk struct {
+		Kty string `json:"kty"`
+		K   string `json:"k"`
+	}
+	if err := json.Unmarshal([]byte(key), &jwk); err != nil {
+		return nil, fmt.Errorf("key: %w", err)
+	}
+	if jwk.Kty != "oct" {
+		return nil, fmt.Errorf("key: unsupported key type %q", jwk.Kty)
+	}
+	k, err := base64.RawURLEncoding.DecodeString(jwk.K)
+	if err != nil {
+		return nil, fmt.Errorf("key: %w", err)
+	}
+	if len(k) != 32 {
+		return nil, fmt.Errorf("key: length %d is not 32", len(k))
+	}
+	return k, nil
+}
+
+// decryptJWE decrypts a JWE in compact serialization with direct
+// encryption by AES-256-GCM. The additional authenticated data is the
+// encoded protected header.
+func decryptJWE(jwe string, key []byte) ([]byte, error) {
+	parts := strings.Split(jwe, ".")
+	if len(parts) != 5 {
+		return nil, errors.New("jwe: not in compact serialization")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Enc string `json:"enc"`
+	}
+	var b [4][]byte
+	for i, p := range []string{parts[0], parts[2], parts[3], parts[4]} {
+		var err error
+		if b[i], err = base64.RawURLEncoding.DecodeString(p); err != nil {
+			return nil, fmt.Errorf("jwe: %w", err)
+		}
+	}
+	if err := json.Unmarshal(b[0], &header); err != nil {
+		return nil, fmt.Errorf("jwe: header: %w", err)
+	}
+	if header.Alg != "dir" || header.Enc != "A256GCM" {
+		return nil, fmt.Errorf("jwe: unsupported algorithm %s with %s", header.Alg, header.Enc)
+	}
+	if parts[1] != "" {
+		return nil, errors.New("jwe: encrypted key with direct encryption")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("jwe: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("jwe: %w", err)
+	}
+	iv, ciphertext, tag := b[1], b[2], b[3]
+	if len(iv) != gcm.NonceSize() {
+		return nil, fmt.Errorf("jwe: IV length %d is not %d", len(iv), gcm.NonceSize())
+	}
+	plaintext, err := gcm.Open(nil, iv, append(ciphertext, tag...), []byte(parts[0]))
+	if err != nil {
+		return nil, fmt.Errorf("jwe: %w", err)
+	}
+	return plaintext, nil
+}
+
+func fromMilli(ms sql.NullInt64) time.Time {
+	if !ms.Valid || ms.Int64 == 0 {
+		return time.Time{}
+	}
+	return timefmt.FromInt(ms.Int64, 0, timefmt.Milli, timefmt.Unix)
+}
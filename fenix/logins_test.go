@@ -0,0 +1,111 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package fenix
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"database/sql"
+	"encoding/base64"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// encryptJWE encrypts plaintext as a JWE in compact serialization with
+// direct encryption by AES-256-GCM.
+func encryptJWE(t *testing.T, plaintext string, key, iv []byte) string {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"dir","enc":"A256GCM"}`))
+	sealed := gcm.Seal(nil, iv, []byte(plaintext), []byte(header))
+	n := len(sealed) - gcm.Overhead()
+	return header + ".." + enc.EncodeToString(iv) + "." + enc.EncodeToString(sealed[:n]) + "." + enc.EncodeToString(sealed[n:])
+}
+
+func TestParseLogins(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	jwk := `{"kty":"oct","k":"` + base64.RawURLEncoding.EncodeToString(key) + `"}`
+	secFields := encryptJWE(t, `{"u":"alice","p":"hunter2"}`, key, make([]byte, 12))
+
+	filename := filepath.Join(t.TempDir(), "logins2.sqlite")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE loginsL (id INTEGER PRIMARY KEY AUTOINCREMENT, origin TEXT NOT NULL, httpRealm TEXT,
+			formActionOrigin TEXT, usernameField TEXT, passwordField TEXT, timesUsed INTEGER NOT NULL DEFAULT 0,
+			timeCreated INTEGER NOT NULL, timeLastUsed INTEGER, timePasswordChanged INTEGER NOT NULL,
+			secFields TEXT, guid TEXT NOT NULL UNIQUE, local_modified INTEGER,
+			is_deleted TINYINT NOT NULL DEFAULT 0, sync_status TINYINT NOT NULL DEFAULT 0);
+		CREATE TABLE loginsM (id INTEGER PRIMARY KEY AUTOINCREMENT, origin TEXT NOT NULL, httpRealm TEXT,
+			formActionOrigin TEXT, usernameField TEXT, passwordField TEXT, timesUsed INTEGER NOT NULL DEFAULT 0,
+			timeCreated INTEGER NOT NULL, timeLastUsed INTEGER, timePasswordChanged INTEGER NOT NULL,
+			secFields TEXT, guid TEXT NOT NULL UNIQUE, server_modified INTEGER NOT NULL,
+			is_overridden TINYINT NOT NULL DEFAULT 0);
+		INSERT INTO loginsL (origin, formActionOrigin, usernameField, passwordField, timesUsed,
+			timeCreated, timeLastUsed, timePasswordChanged, secFields, guid, is_deleted) VALUES
+			('https://example.com', 'https://example.com', 'user', 'pass', 3,
+				1613649600000, 1613649700000, 1613649600000, '` + secFields + `', 'loginLocal01', 0),
+			('https://deleted.example', NULL, NULL, NULL, 0, 0, NULL, 0, NULL, 'loginDelete1', 1),
+			('https://a.example', 'https://a.example', '', '', 1,
+				1613649600000, NULL, 1613649600000, '` + secFields + `', 'loginShared1', 0);
+		INSERT INTO loginsM (origin, httpRealm, timesUsed, timeCreated, timeLastUsed,
+			timePasswordChanged, secFields, guid, server_modified, is_overridden) VALUES
+			('https://a.example', NULL, 0, 1613600000000, NULL, 1613600000000, '` + secFields + `', 'loginShared1', 0, 1),
+			('https://b.example', 'Intranet', 2, 1613600000000, 1613600000000, 1613600000000, '` + secFields + `', 'loginMirror1', 0, 0);
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logins, err := ParseLogins(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Login{
+		{GUID: "loginShared1", Origin: "https://a.example", FormActionOrigin: "https://a.example",
+			TimesUsed: 1, TimeCreated: ms(1613649600000), TimePasswordChanged: ms(1613649600000),
+			SecFields: secFields},
+		{GUID: "loginMirror1", Origin: "https://b.example", HTTPRealm: "Intranet",
+			TimesUsed: 2, TimeCreated: ms(1613600000000), TimeLastUsed: ms(1613600000000),
+			TimePasswordChanged: ms(1613600000000), SecFields: secFields, Synced: true},
+		{GUID: "loginLocal01", Origin: "https://example.com", FormActionOrigin: "https://example.com",
+			UsernameField: "user", PasswordField: "pass", TimesUsed: 3,
+			TimeCreated: ms(1613649600000), TimeLastUsed: ms(1613649700000),
+			TimePasswordChanged: ms(1613649600000), SecFields: secFields},
+	}
+	if !reflect.DeepEqual(logins, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", logins, want)
+	}
+
+	fields, err := logins[0].Decrypt(jwk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&SecureFields{"alice", "hunter2"}); !reflect.DeepEqual(fields, want) {
+		t.Errorf("got %+v, want %+v", fields, want)
+	}
+	key[0] ^= 1
+	badJWK := `{"kty":"oct","k":"` + base64.RawURLEncoding.EncodeToString(key) + `"}`
+	if _, err := logins[0].Decrypt(badJWK); err == nil {
+		t.Error("expected error with wrong key")
+	}
+}
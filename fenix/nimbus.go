@@ -0,0 +1,208 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package fenix
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+// Nimbus database format:
+// https://github.com/mozilla/application-services/blob/main/components/nimbus/src/persistence.rs
+// https://github.com/mozilla/application-services/blob/main/components/nimbus/src/enrollment.rs
+//
+// Nimbus keeps the experiments downloaded from Remote Settings in the
+// experiments store and the state of the client in each of them in the
+// enrollments store, both keyed by experiment slug, with JSON values.
+
+// Nimbus is the experiment state of an app.
+type Nimbus struct {
+	Experiments []NimbusExperiment // ordered by slug
+	Enrollments []NimbusEnrollment // ordered by slug
+}
+
+// NimbusExperiment is an experiment or rollout definition. Only the
+// fields that identify the experiment and its branches are decoded.
+type NimbusExperiment struct {
+	Slug                  string         `json:"slug"`
+	AppName               string         `json:"appName"`
+	AppID                 string         `json:"appId"`
+	Channel               string         `json:"channel"`
+	UserFacingName        string         `json:"userFacingName"`
+	UserFacingDescription string         `json:"userFacingDescription"`
+	IsEnrollmentPaused    bool           `json:"isEnrollmentPaused"`
+	IsRollout             bool           `json:"isRollout,omitempty"`
+	FeatureIDs            []string       `json:"featureIds"`
+	Branches              []NimbusBranch `json:"branches"`
+	Targeting             string         `json:"targeting,omitempty"`
+	StartDate             string         `json:"startDate,omitempty"` // e.g. "2021-02-18"
+	EndDate               string         `json:"endDate,omitempty"`
+}
+
+// NimbusBranch is a branch of an experiment.
+type NimbusBranch struct {
+	Slug  string `json:"slug"`
+	Ratio int    `json:"ratio"`
+}
+
+// NimbusEnrollment is the state of the client in an experiment. Status
+// is the name of the state, such as "Enrolled", "NotEnrolled",
+// "Disqualified", "WasEnrolled", or "Error".
+type NimbusEnrollment struct {
+	Slug   string
+	Status string
+	Branch string // for Enrolled, Disqualified, and WasEnrolled
+	Reason string // e.g. "Qualified" or "NotTargeted"
+}
+
+// Nimbus stores:
+const (
+	nimbusExperimentsStore = "experiments"
+	nimbusEnrollmentsStore = "enrollments"
+)
+
+// ParseNimbus reads the experiments and enrollments in a Nimbus data
+// directory. Malformed experiments and enrollments are handled as by
+// ParseNimbusOptions with the zero Options.
+func ParseNimbus(dir string) (*Nimbus, error) {
+	return ParseNimbusOptions(dir, browser.Options{})
+}
+
+// ParseNimbusOptions reads the experiments and enrollments in a Nimbus
+// data directory and handles malformed experiments and enrollments,
+// which are the records of the database, by the error policy in opts.
+func ParseNimbusOptions(dir string, opts browser.Options) (*Nimbus, error) {
+	h := opts.Handler()
+	filename := filepath.Join(dir, rkvSafeModeFile)
+	stores, err := readRkv(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	} else if err != nil {
+		if err := h.File(filename, err); err != nil {
+			return nil, err
+		}
+		return &Nimbus{}, h.Err()
+	}
+	var n Nimbus
+	record := 0
+	for _, key := range sortedKeys(stores[nimbusExperimentsStore]) {
+		record++
+		e, err := decodeNimbusExperiment(stores[nimbusExperimentsStore][key])
+		if err != nil {
+			skipFile, err := h.Record(filename, record, fmt.Errorf("fenix: nimbus experiment %s: %w", key, err))
+			if err != nil {
+				return nil, err
+			} else if skipFile {
+				return &Nimbus{}, h.Err()
+			}
+			continue
+		}
+		n.Experiments = append(n.Experiments, *e)
+	}
+	for _, key := range sortedKeys(stores[nimbusEnrollmentsStore]) {
+		record++
+		b, err := decodeRkvValue(stores[nimbusEnrollmentsStore][key], rkvJSON)
+		var e *NimbusEnrollment
+		if err == nil {
+			e, err = decodeNimbusEnrollment(b)
+		}
+		if err != nil {
+			skipFile, err := h.Record(filename, record, fmt.Errorf("fenix: nimbus enrollment %s: %w", key, err))
+			if err != nil {
+				return nil, err
+			} else if skipFile {
+				return &Nimbus{}, h.Err()
+			}
+			continue
+		}
+		n.Enrollments = append(n.Enrollments, *e)
+	}
+	return &n, h.Err()
+}
+
+func decodeNimbusExperiment(value []byte) (*NimbusExperiment, error) {
+	b, err := decodeRkvValue(value, rkvJSON)
+	if err != nil {
+		return nil, err
+	}
+	var e NimbusExperiment
+	if err := jsonutil.DecodeAllowUnknownFields(bytes.NewReader(b), &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// decodeNimbusEnrollment decodes an ExperimentEnrollment. Its status is
+// an externally tagged enum, as serialized by serde: an object with the
+// name of the state as its only key or, for states without fields, the
+// name as a string.
+func decodeNimbusEnrollment(b []byte) (*NimbusEnrollment, error) {
+	var raw struct {
+		Slug   string          `json:"slug"`
+		Status json.RawMessage `json:"status"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	e := &NimbusEnrollment{Slug: raw.Slug}
+	if len(raw.Status) != 0 && raw.Status[0] == '"' {
+		if err := json.Unmarshal(raw.Status, &e.Status); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	var status map[string]struct {
+		Branch string          `json:"branch"`
+		Reason json.RawMessage `json:"reason"`
+	}
+	if err := json.Unmarshal(raw.Status, &status); err != nil {
+		return nil, err
+	}
+	if len(status) != 1 {
+		return nil, fmt.Errorf("status has %d states", len(status))
+	}
+	for name, s := range status {
+		e.Status, e.Branch = name, s.Branch
+		if len(s.Reason) != 0 {
+			e.Reason = enumName(s.Reason)
+		}
+	}
+	return e, nil
+}
+
+// enumName returns the variant name of a serde enum, which is either a
+// string or an object with a single key.
+func enumName(b json.RawMessage) string {
+	var name string
+	if json.Unmarshal(b, &name) == nil {
+		return name
+	}
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(b, &obj) == nil {
+		for name := range obj {
+			return name
+		}
+	}
+	return ""
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package fenix
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNimbus(t *testing.T) {
+	dir := t.TempDir()
+	writeRkv(t, dir, rkvStores{
+		"experiments": {
+			"new-onboarding": rkvValue(rkvJSON, []byte(`{"schemaVersion":"1.0.0","slug":"new-onboarding","appName":"fenix","appId":"org.mozilla.firefox","channel":"release","userFacingName":"New Onboarding","userFacingDescription":"Tests onboarding.","isEnrollmentPaused":false,"featureIds":["onboarding"],"bucketConfig":{"randomizationUnit":"nimbus_id","namespace":"onboarding","start":0,"count":1000,"total":10000},"branches":[{"slug":"control","ratio":1},{"slug":"treatment","ratio":1}],"targeting":"true","startDate":"2021-02-18","endDate":null,"proposedEnrollment":7}`)),
+		},
+		"enrollments": {
+			"new-onboarding": rkvValue(rkvJSON, []byte(`{"slug":"new-onboarding","status":{"Enrolled":{"enrollment_id":"6f0b6a4b-1f5c-4c55-9a4b-3c9f3f1e1c2d","reason":"Qualified","branch":"treatment"}}}`)),
+			"old-study":      rkvValue(rkvJSON, []byte(`{"slug":"old-study","status":{"NotEnrolled":{"reason":{"NotSelected":{}}}}}`)),
+		},
+		"meta": {"db_version": {rkvU64, 2, 0, 0, 0, 0, 0, 0, 0}},
+	})
+
+	n, err := ParseNimbus(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Nimbus{
+		Experiments: []NimbusExperiment{{
+			Slug:                  "new-onboarding",
+			AppName:               "fenix",
+			AppID:                 "org.mozilla.firefox",
+			Channel:               "release",
+			UserFacingName:        "New Onboarding",
+			UserFacingDescription: "Tests onboarding.",
+			FeatureIDs:            []string{"onboarding"},
+			Branches:              []NimbusBranch{{"control", 1}, {"treatment", 1}},
+			Targeting:             "true",
+			StartDate:             "2021-02-18",
+		}},
+		Enrollments: []NimbusEnrollment{
+			{Slug: "new-onboarding", Status: "Enrolled", Branch: "treatment", Reason: "Qualified"},
+			{Slug: "old-study", Status: "NotEnrolled", Reason: "NotSelected"},
+		},
+	}
+	if !reflect.DeepEqual(n, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", n, want)
+	}
+}
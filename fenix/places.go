@@ -0,0 +1,258 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package fenix
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/andrewarchi/browser/bookmark"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Places database schema:
+// https://github.com/mozilla/application-services/blob/main/components/places/sql/create_shared_schema.sql
+//
+// The schema is derived from desktop places.sqlite, but times are in
+// milliseconds, rather than microseconds, visits from other devices
+// are stored with is_local unset, and tags are in moz_tags and
+// moz_tags_relation, rather than in folders.
+
+// Types of moz_bookmarks rows:
+const (
+	placesBookmark  = 1
+	placesFolder    = 2
+	placesSeparator = 3
+)
+
+// GUIDs of the places roots. There is no tags root.
+const (
+	RootGUID    = "root________"
+	MenuGUID    = "menu________"
+	ToolbarGUID = "toolbar_____"
+	UnfiledGUID = "unfiled_____"
+	MobileGUID  = "mobile______"
+)
+
+// rootTitles are the displayed titles of the roots, which are stored
+// empty.
+var rootTitles = map[string]string{
+	MenuGUID:    "Bookmarks Menu",
+	ToolbarGUID: "Bookmarks Toolbar",
+	UnfiledGUID: "Other Bookmarks",
+	MobileGUID:  "Mobile Bookmarks",
+}
+
+// Visit is a page visit in places.sqlite.
+type Visit struct {
+	URL       string
+	Title     string
+	GUID      string // place GUID
+	VisitDate time.Time
+	VisitType VisitType
+	Local     bool // false for visits synced from other devices
+}
+
+// VisitType is the transition of a visit.
+type VisitType uint8
+
+// Values for VisitType:
+const (
+	VisitLink              VisitType = 1
+	VisitTyped             VisitType = 2
+	VisitBookmark          VisitType = 3
+	VisitEmbed             VisitType = 4
+	VisitRedirectPermanent VisitType = 5
+	VisitRedirectTemporary VisitType = 6
+	VisitDownload          VisitType = 7
+	VisitFramedLink        VisitType = 8
+	VisitReload            VisitType = 9
+)
+
+// ParseHistory reads the visits in places.sqlite, ordered by time.
+func ParseHistory(filename string) ([]Visit, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var visits []Visit
+	err = sqliteutil.Query(db, `
+		SELECT p.url, p.title, p.guid, v.visit_date, v.visit_type, v.is_local
+		FROM moz_historyvisits v JOIN moz_places p ON v.place_id = p.id
+		ORDER BY v.visit_date, v.id`, func(rows *sql.Rows) error {
+		var v Visit
+		var title sql.NullString
+		var date int64
+		if err := rows.Scan(&v.URL, &title, &v.GUID, &date, &v.VisitType, &v.Local); err != nil {
+			return err
+		}
+		v.Title = title.String
+		v.VisitDate = timefmt.FromInt(date, 0, timefmt.Milli, timefmt.Unix)
+		visits = append(visits, v)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fenix: places history: %w", err)
+	}
+	return visits, nil
+}
+
+type placesItem struct {
+	ID, Type, Parent, Position int64
+	Title, GUID, URL, Keyword  string
+	DateAdded, LastModified    int64
+	Children                   []*placesItem
+}
+
+// ParseBookmarks reads the bookmarks in places.sqlite. The returned
+// entries are the menu, toolbar, other, and mobile roots, in order,
+// with their GUIDs.
+func ParseBookmarks(filename string) ([]bookmark.BookmarkEntry, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	items := make(map[int64]*placesItem)
+	var all []*placesItem
+	err = sqliteutil.Query(db, `
+		SELECT b.id, b.type, b.parent, b.position, b.title, b.guid,
+			b.dateAdded, b.lastModified, p.url,
+			(SELECT k.keyword FROM moz_keywords k WHERE k.place_id = b.fk ORDER BY k.keyword LIMIT 1)
+		FROM moz_bookmarks b LEFT JOIN moz_places p ON b.fk = p.id`, func(rows *sql.Rows) error {
+		var it placesItem
+		var parent sql.NullInt64
+		var title, url, keyword sql.NullString
+		if err := rows.Scan(&it.ID, &it.Type, &parent, &it.Position, &title, &it.GUID,
+			&it.DateAdded, &it.LastModified, &url, &keyword); err != nil {
+			return err
+		}
+		it.Parent = parent.Int64
+		it.Title, it.URL, it.Keyword = title.String, url.String, keyword.String
+		items[it.ID] = &it
+		all = append(all, &it)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fenix: places bookmarks: %w", err)
+	}
+
+	tags := make(map[string][]string) // key: URL
+	err = sqliteutil.Query(db, `
+		SELECT p.url, t.tag
+		FROM moz_tags_relation r
+			JOIN moz_tags t ON r.tag_id = t.id
+			JOIN moz_places p ON r.place_id = p.id
+		ORDER BY t.tag`, func(rows *sql.Rows) error {
+		var url, tag string
+		if err := rows.Scan(&url, &tag); err != nil {
+			return err
+		}
+		tags[url] = append(tags[url], tag)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fenix: places tags: %w", err)
+	}
+
+	var root *placesItem
+	for _, it := range all {
+		if it.GUID == RootGUID {
+			root = it
+			continue
+		}
+		parent, ok := items[it.Parent]
+		if !ok {
+			return nil, fmt.Errorf("fenix: places bookmark %s has missing parent %d", it.GUID, it.Parent)
+		}
+		parent.Children = append(parent.Children, it)
+	}
+	if root == nil {
+		return nil, fmt.Errorf("fenix: places bookmarks have no root")
+	}
+	for _, it := range all {
+		sort.SliceStable(it.Children, func(i, j int) bool {
+			return it.Children[i].Position < it.Children[j].Position
+		})
+	}
+
+	entries := make([]bookmark.BookmarkEntry, len(root.Children))
+	for i, it := range root.Children {
+		entries[i] = it.entry(tags)
+	}
+	return entries, nil
+}
+
+func (it *placesItem) entry(tags map[string][]string) bookmark.BookmarkEntry {
+	added := timefmt.FromInt(it.DateAdded, 0, timefmt.Milli, timefmt.Unix)
+	modified := timefmt.FromInt(it.LastModified, 0, timefmt.Milli, timefmt.Unix)
+	switch it.Type {
+	case placesBookmark:
+		return &bookmark.Bookmark{
+			Title:        it.Title,
+			URL:          it.URL,
+			GUID:         it.GUID,
+			AddDate:      added,
+			LastModified: modified,
+			Tags:         tags[it.URL],
+			Keyword:      it.Keyword,
+		}
+	case placesSeparator:
+		return &bookmark.BookmarkSeparator{
+			GUID:         it.GUID,
+			AddDate:      added,
+			LastModified: modified,
+		}
+	default:
+		title := it.Title
+		if t, ok := rootTitles[it.GUID]; ok && title == "" {
+			title = t
+		}
+		f := &bookmark.BookmarkFolder{
+			Title:        title,
+			GUID:         it.GUID,
+			AddDate:      added,
+			LastModified: modified,
+			Entries:      make([]bookmark.BookmarkEntry, 0, len(it.Children)),
+		}
+		for _, child := range it.Children {
+			f.Entries = append(f.Entries, child.entry(tags))
+		}
+		return f
+	}
+}
+
+func (t VisitType) String() string {
+	switch t {
+	case VisitLink:
+		return "link"
+	case VisitTyped:
+		return "typed"
+	case VisitBookmark:
+		return "bookmark"
+	case VisitEmbed:
+		return "embed"
+	case VisitRedirectPermanent:
+		return "redirect_permanent"
+	case VisitRedirectTemporary:
+		return "redirect_temporary"
+	case VisitDownload:
+		return "download"
+	case VisitFramedLink:
+		return "framed_link"
+	case VisitReload:
+		return "reload"
+	default:
+		return fmt.Sprintf("visit(%d)", uint8(t))
+	}
+}
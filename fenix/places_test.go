@@ -0,0 +1,116 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package fenix
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/bookmark"
+)
+
+func ms(n int64) time.Time {
+	return time.Unix(n/1000, n%1000*1e6).UTC()
+}
+
+func createPlaces(t *testing.T) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "places.sqlite")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url LONGVARCHAR NOT NULL, title LONGVARCHAR,
+			url_hash INTEGER NOT NULL DEFAULT 0, frecency INTEGER NOT NULL DEFAULT -1,
+			visit_count_local INTEGER NOT NULL DEFAULT 0, visit_count_remote INTEGER NOT NULL DEFAULT 0,
+			last_visit_date_local INTEGER NOT NULL DEFAULT 0, last_visit_date_remote INTEGER NOT NULL DEFAULT 0,
+			guid TEXT NOT NULL UNIQUE, foreign_count INTEGER NOT NULL DEFAULT 0, hidden INTEGER NOT NULL DEFAULT 0);
+		CREATE TABLE moz_historyvisits (id INTEGER PRIMARY KEY, is_local INTEGER NOT NULL,
+			from_visit INTEGER, place_id INTEGER NOT NULL, visit_date INTEGER NOT NULL, visit_type INTEGER NOT NULL);
+		CREATE TABLE moz_bookmarks (id INTEGER PRIMARY KEY, fk INTEGER DEFAULT NULL, type INTEGER NOT NULL,
+			parent INTEGER, position INTEGER NOT NULL, title TEXT, dateAdded INTEGER NOT NULL DEFAULT 0,
+			lastModified INTEGER NOT NULL DEFAULT 0, guid TEXT NOT NULL UNIQUE,
+			syncStatus INTEGER NOT NULL DEFAULT 0, syncChangeCounter INTEGER NOT NULL DEFAULT 1);
+		CREATE TABLE moz_keywords (place_id INTEGER PRIMARY KEY, keyword TEXT NOT NULL UNIQUE);
+		CREATE TABLE moz_tags (id INTEGER PRIMARY KEY, tag TEXT UNIQUE NOT NULL, lastModified INTEGER NOT NULL);
+		CREATE TABLE moz_tags_relation (tag_id INTEGER NOT NULL, place_id INTEGER NOT NULL,
+			PRIMARY KEY (tag_id, place_id));
+
+		INSERT INTO moz_places (id, url, title, guid) VALUES
+			(1, 'https://example.com/', 'Example', 'placeExample'),
+			(2, 'https://mozilla.org/', NULL, 'placeMozilla');
+		INSERT INTO moz_historyvisits VALUES
+			(1, 1, NULL, 1, 1613649600000, 2),
+			(2, 0, NULL, 2, 1613649500000, 1),
+			(3, 1, 1, 2, 1613649700000, 1);
+		INSERT INTO moz_bookmarks (id, fk, type, parent, position, title, dateAdded, lastModified, guid) VALUES
+			(1, NULL, 2, NULL, 0, '', 1613600000000, 1613600000000, 'root________'),
+			(2, NULL, 2, 1, 0, '', 1613600000000, 1613600000000, 'menu________'),
+			(3, NULL, 2, 1, 1, '', 1613600000000, 1613600000000, 'toolbar_____'),
+			(4, NULL, 2, 1, 2, '', 1613600000000, 1613600000000, 'unfiled_____'),
+			(5, NULL, 2, 1, 3, '', 1613600000000, 1613600000000, 'mobile______'),
+			(6, 2, 1, 5, 1, 'Mozilla', 1613649800000, 1613649900000, 'bookmarkMoz1'),
+			(7, 1, 1, 5, 0, 'Example', 1613649600000, 1613649600000, 'bookmarkExa1'),
+			(8, NULL, 3, 2, 0, NULL, 1613649600000, 1613649600000, 'separator001');
+		INSERT INTO moz_keywords VALUES (2, 'moz');
+		INSERT INTO moz_tags VALUES (1, 'web', 0), (2, 'browser', 0);
+		INSERT INTO moz_tags_relation VALUES (1, 2), (2, 2);
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestParseHistory(t *testing.T) {
+	visits, err := ParseHistory(createPlaces(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Visit{
+		{"https://mozilla.org/", "", "placeMozilla", ms(1613649500000), VisitLink, false},
+		{"https://example.com/", "Example", "placeExample", ms(1613649600000), VisitTyped, true},
+		{"https://mozilla.org/", "", "placeMozilla", ms(1613649700000), VisitLink, true},
+	}
+	if !reflect.DeepEqual(visits, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", visits, want)
+	}
+}
+
+func TestParseBookmarks(t *testing.T) {
+	entries, err := ParseBookmarks(createPlaces(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := ms(1613600000000)
+	want := []bookmark.BookmarkEntry{
+		&bookmark.BookmarkFolder{Title: "Bookmarks Menu", GUID: MenuGUID, AddDate: root, LastModified: root,
+			Entries: []bookmark.BookmarkEntry{
+				&bookmark.BookmarkSeparator{GUID: "separator001", AddDate: ms(1613649600000), LastModified: ms(1613649600000)},
+			}},
+		&bookmark.BookmarkFolder{Title: "Bookmarks Toolbar", GUID: ToolbarGUID, AddDate: root, LastModified: root,
+			Entries: []bookmark.BookmarkEntry{}},
+		&bookmark.BookmarkFolder{Title: "Other Bookmarks", GUID: UnfiledGUID, AddDate: root, LastModified: root,
+			Entries: []bookmark.BookmarkEntry{}},
+		&bookmark.BookmarkFolder{Title: "Mobile Bookmarks", GUID: MobileGUID, AddDate: root, LastModified: root,
+			Entries: []bookmark.BookmarkEntry{
+				&bookmark.Bookmark{Title: "Example", URL: "https://example.com/", GUID: "bookmarkExa1",
+					AddDate: ms(1613649600000), LastModified: ms(1613649600000)},
+				&bookmark.Bookmark{Title: "Mozilla", URL: "https://mozilla.org/", GUID: "bookmarkMoz1",
+					AddDate: ms(1613649800000), LastModified: ms(1613649900000),
+					Tags: []string{"browser", "web"}, Keyword: "moz"},
+			}},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", entries, want)
+	}
+}
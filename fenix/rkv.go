@@ -0,0 +1,204 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package fenix
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+// rkv safe mode format:
+// https://github.com/mozilla/rkv/blob/main/src/backend/impl_safe/environment.rs
+// https://github.com/mozilla/rkv/blob/main/src/backend/impl_safe/snapshot.rs
+// https://github.com/mozilla/rkv/blob/main/src/value.rs
+//
+// Glean and Nimbus store their data with rkv in safe mode, which keeps
+// the databases in memory and writes them to data.safe.bin, rather than
+// using LMDB. The file is the bincode serialization of a map from the
+// optional name of each database to its flags and its sorted map of
+// keys to values. When rkv is built with duplicate keys, each value is
+// instead a sorted set of values. Bincode writes integers in little
+// endian, lengths as 64-bit integers, and options with a one-byte tag.
+//
+// Values are written by rkv with a one-byte type tag, followed by the
+// bincode serialization of the value.
+
+// rkvSafeModeFile is the name of the file of an rkv safe mode
+// environment.
+const rkvSafeModeFile = "data.safe.bin"
+
+// Types of rkv values:
+const (
+	rkvBool    = 1
+	rkvU64     = 2
+	rkvI64     = 3
+	rkvF64     = 4
+	rkvInstant = 5
+	rkvUUID    = 6
+	rkvStr     = 7
+	rkvJSON    = 8
+	rkvBlob    = 9
+)
+
+// rkvStores is a map from database name to the keys and values in it.
+// The default database has the empty name.
+type rkvStores map[string]map[string][]byte
+
+// readRkv reads the databases in an rkv safe mode file.
+func readRkv(filename string) (rkvStores, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	// The serialization depends on whether rkv was built with duplicate
+	// keys, so try both. Only one consumes the file exactly.
+	stores, err := decodeRkv(data, false)
+	if err != nil {
+		var err2 error
+		if stores, err2 = decodeRkv(data, true); err2 != nil {
+			return nil, fmt.Errorf("fenix: rkv %s: %w", filename, err)
+		}
+	}
+	return stores, nil
+}
+
+func decodeRkv(data []byte, dupSort bool) (rkvStores, error) {
+	d := &bincodeDecoder{b: data}
+	n := d.len()
+	stores := make(rkvStores)
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		var name string
+		if d.u8() == 1 {
+			name = d.str()
+		}
+		d.u32() // flags
+		entries := d.len()
+		store := make(map[string][]byte)
+		for j := uint64(0); j < entries && d.err == nil; j++ {
+			key := d.bytes()
+			if dupSort {
+				// Keep the last of the duplicate values, which none of
+				// the databases read here use.
+				values := d.len()
+				for k := uint64(0); k < values && d.err == nil; k++ {
+					store[string(key)] = d.bytes()
+				}
+			} else {
+				store[string(key)] = d.bytes()
+			}
+		}
+		stores[name] = store
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(d.b) != 0 {
+		return nil, fmt.Errorf("%d trailing bytes", len(d.b))
+	}
+	return stores, nil
+}
+
+// decodeRkvValue decodes an rkv value of the given type.
+func decodeRkvValue(b []byte, typ byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	if b[0] != typ {
+		return nil, fmt.Errorf("value has type %d, not %d", b[0], typ)
+	}
+	d := &bincodeDecoder{b: b[1:]}
+	v := d.bytes()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(d.b) != 0 {
+		return nil, fmt.Errorf("%d trailing bytes in value", len(d.b))
+	}
+	return v, nil
+}
+
+// bincodeDecoder decodes values in the default bincode format. After an
+// error, every method returns zero and the error is kept.
+type bincodeDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *bincodeDecoder) next(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(len(d.b)) {
+		d.err = errors.New("unexpected end of data")
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *bincodeDecoder) u8() uint8 {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *bincodeDecoder) u32() uint32 {
+	if b := d.next(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *bincodeDecoder) u64() uint64 {
+	if b := d.next(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *bincodeDecoder) i32() int32 { return int32(d.u32()) }
+func (d *bincodeDecoder) i64() int64 { return int64(d.u64()) }
+
+func (d *bincodeDecoder) f64() float64 { return math.Float64frombits(d.u64()) }
+
+func (d *bincodeDecoder) bool() bool {
+	switch d.u8() {
+	case 0:
+		return false
+	case 1:
+		return true
+	default:
+		if d.err == nil {
+			d.err = errors.New("invalid bool")
+		}
+		return false
+	}
+}
+
+// len reads a length and checks that it does not exceed the remaining
+// data, assuming every element is at least one byte.
+func (d *bincodeDecoder) len() uint64 {
+	n := d.u64()
+	if d.err == nil && n > uint64(len(d.b)) {
+		d.err = fmt.Errorf("length %d exceeds data", n)
+		return 0
+	}
+	return n
+}
+
+func (d *bincodeDecoder) bytes() []byte {
+	return d.next(d.len())
+}
+
+func (d *bincodeDecoder) str() string {
+	return string(d.bytes())
+}
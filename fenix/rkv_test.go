@@ -0,0 +1,103 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package fenix
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// encodeRkv encodes databases in the rkv safe mode format.
+func encodeRkv(stores rkvStores, dupSort bool) []byte {
+	var b []byte
+	b = appendU64(b, uint64(len(stores)))
+	for _, name := range sortedStoreNames(stores) {
+		if name == "" {
+			b = append(b, 0)
+		} else {
+			b = append(b, 1)
+			b = appendBytes(b, []byte(name))
+		}
+		b = append(b, 0, 0, 0, 0) // flags
+		store := stores[name]
+		b = appendU64(b, uint64(len(store)))
+		for _, key := range sortedKeys(store) {
+			b = appendBytes(b, []byte(key))
+			if dupSort {
+				b = appendU64(b, 1)
+			}
+			b = appendBytes(b, store[key])
+		}
+	}
+	return b
+}
+
+// rkvValue encodes an rkv value of the given type.
+func rkvValue(typ byte, data []byte) []byte {
+	return appendBytes([]byte{typ}, data)
+}
+
+func appendU64(b []byte, n uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], n)
+	return append(b, buf[:]...)
+}
+
+func appendBytes(b, data []byte) []byte {
+	return append(appendU64(b, uint64(len(data))), data...)
+}
+
+func sortedStoreNames(stores rkvStores) []string {
+	names := make([]string, 0, len(stores))
+	for name := range stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeRkv(t *testing.T, dir string, stores rkvStores) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, rkvSafeModeFile), encodeRkv(stores, false), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadRkv(t *testing.T) {
+	want := rkvStores{
+		"":     {"k": rkvValue(rkvStr, []byte("default"))},
+		"meta": {"db_version": {rkvU64, 2, 0, 0, 0, 0, 0, 0, 0}, "empty": rkvValue(rkvBlob, nil)},
+	}
+	for _, dupSort := range []bool{false, true} {
+		filename := filepath.Join(t.TempDir(), rkvSafeModeFile)
+		if err := os.WriteFile(filename, encodeRkv(want, dupSort), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readRkv(filename)
+		if err != nil {
+			t.Fatalf("dupSort=%t: %v", dupSort, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("dupSort=%t: got:\n%+v\nwant:\n%+v", dupSort, got, want)
+		}
+	}
+
+	filename := filepath.Join(t.TempDir(), rkvSafeModeFile)
+	if err := os.WriteFile(filename, encodeRkv(want, false)[:20], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readRkv(filename); err == nil {
+		t.Error("expected error for truncated file")
+	}
+}
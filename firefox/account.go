@@ -0,0 +1,83 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"github.com/andrewarchi/browser/jsonutil"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+)
+
+// SignedInUser is the Firefox Account signed in for Sync, from
+// signedInUser.json in a Firefox profile.
+// https://searchfox.org/mozilla-central/source/services/fxaccounts/FxAccountsStorage.jsm
+type SignedInUser struct {
+	Version     int         `json:"version"` // e.g. 1
+	AccountData AccountData `json:"accountData"`
+}
+
+// AccountData identifies a Firefox Account. Session tokens, OAuth
+// tokens, and keys are not decoded.
+type AccountData struct {
+	UID          string                       `json:"uid"`
+	Email        string                       `json:"email"`
+	Verified     bool                         `json:"verified"`
+	AuthAt       *timefmt.UnixSec             `json:"authAt,omitempty"` // time of sign-in
+	Device       *AccountDevice               `json:"device,omitempty"`
+	OAuthTokens  map[string]AccountOAuthToken `json:"oauthTokens,omitempty"` // key: sorted scopes
+	ProfileCache *AccountProfileCache         `json:"profileCache,omitempty"`
+	Profile      *AccountProfile              `json:"profile,omitempty"` // older versions
+}
+
+// AccountDevice is the registration of the profile as a device of the
+// account. The device name is kept in prefs; see SyncState.
+type AccountDevice struct {
+	ID                     string   `json:"id"`
+	RegistrationVersion    int      `json:"registrationVersion,omitempty"`
+	RegisteredCommandsKeys []string `json:"registeredCommandsKeys,omitempty"` // e.g. "https://identity.mozilla.com/cmd/open-uri"
+}
+
+// AccountOAuthToken is a cached OAuth token. Only its scope is decoded
+// and the token itself is dropped.
+type AccountOAuthToken struct {
+	Scope string `json:"scope"` // e.g. "profile https://identity.mozilla.com/apps/oldsync"
+}
+
+// AccountProfileCache is the cached profile fetched from the Firefox
+// Accounts profile server.
+type AccountProfileCache struct {
+	Profile *AccountProfile `json:"profile"`
+	ETag    string          `json:"etag,omitempty"`
+}
+
+// AccountProfile is the cached profile of a Firefox Account.
+type AccountProfile struct {
+	UID         string `json:"uid"`
+	Email       string `json:"email"`
+	DisplayName string `json:"displayName,omitempty"`
+	Avatar      string `json:"avatar,omitempty"`
+}
+
+// ParseSignedInUser parses signedInUser.json in a Firefox profile.
+// Secrets, such as session and OAuth tokens and keys, are ignored.
+func ParseSignedInUser(filename string) (*SignedInUser, error) {
+	var user SignedInUser
+	if err := jsonutil.DecodeFileAllowUnknownFields(filename, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// DisplayName returns the display name of the account, if cached.
+func (a *AccountData) DisplayName() string {
+	if a.ProfileCache != nil && a.ProfileCache.Profile != nil {
+		return a.ProfileCache.Profile.DisplayName
+	}
+	if a.Profile != nil {
+		return a.Profile.DisplayName
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"sort"
+
+	"github.com/andrewarchi/browser/jsonutil"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+)
+
+// Add-on startup state format:
+// https://searchfox.org/mozilla-central/source/toolkit/mozapps/extensions/internal/XPIProvider.jsm
+//
+// addonStartup.json.lz4 is the state of installed add-ons that Firefox
+// reads at startup, before extensions.json, to detect changes in each
+// install location. It is mozLz4-compressed JSON.
+
+// AddonStartup is the add-on startup state, keyed by install location
+// (e.g. "app-profile").
+type AddonStartup map[string]*AddonStartupLocation
+
+// AddonStartupLocation is an install location and its add-ons.
+type AddonStartupLocation struct {
+	Path                      string                        `json:"path,omitempty"` // directory of the location; empty for app-builtin
+	Addons                    map[string]*AddonStartupState `json:"addons"`         // key: add-on ID
+	StaticAddons              jsonutil.UnknownObj           `json:"staticAddons"`
+	CheckStartupModifications bool                          `json:"checkStartupModifications,omitempty"`
+}
+
+// AddonStartupState is the startup state of an add-on.
+type AddonStartupState struct {
+	Enabled             bool                 `json:"enabled"`
+	LastModifiedTime    timefmt.UnixMilli    `json:"lastModifiedTime"`
+	Path                string               `json:"path"` // relative to the location path, or absolute
+	Version             string               `json:"version"`
+	RootURI             string               `json:"rootURI"` // e.g. "jar:file:///.../extensions/id.xpi!/"
+	RunInSafeMode       bool                 `json:"runInSafeMode"`
+	SignedState         *SignedState         `json:"signedState,omitempty"` // nil when signing is not required
+	SignedDate          timefmt.UnixMilli    `json:"signedDate,omitempty"`
+	TelemetryKey        string               `json:"telemetryKey"`   // e.g. "id:1.0"
+	Type                string               `json:"type,omitempty"` // omitted for extensions
+	EnableShims         bool                 `json:"enableShims,omitempty"`
+	StartupData         *StartupData         `json:"startupData,omitempty"`
+	Dependencies        []string             `json:"dependencies"`
+	RecommendationState *RecommendationState `json:"recommendationState"`
+	Loader              jsonutil.UnknownType `json:"loader"`
+}
+
+// ParseAddonStartup parses addonStartup.json.lz4 in a Firefox profile.
+func ParseAddonStartup(filename string) (AddonStartup, error) {
+	var startup AddonStartup
+	if err := jsonutil.DecodeMozLz4File(filename, &startup); err != nil {
+		return nil, err
+	}
+	return startup, nil
+}
+
+// AddonStartupMismatch is a difference between the state of an add-on
+// in addonStartup.json.lz4 and in extensions.json.
+type AddonStartupMismatch struct {
+	ID         string
+	Location   string
+	Field      string      // "present", "enabled", "version", "signedState", or "rootURI"
+	Startup    interface{} // value in addonStartup.json.lz4
+	Extensions interface{} // value in extensions.json
+}
+
+// CompareAddonStartup cross-validates the add-on startup state against
+// extensions.json, which Firefox keeps consistent. Add-ons are matched
+// by ID and location. An add-on is enabled in the startup state when it
+// is active in extensions.json. Disagreements, which indicate that a
+// file is stale or was edited, are ordered by location, ID, and field.
+func CompareAddonStartup(startup AddonStartup, extensions *Extensions) []AddonStartupMismatch {
+	type key struct{ location, id string }
+	addons := make(map[key]*Addon)
+	for i := range extensions.Addons {
+		a := &extensions.Addons[i]
+		if a.ID != nil {
+			addons[key{a.Location, a.ID.String()}] = a
+		}
+	}
+
+	var mismatches []AddonStartupMismatch
+	add := func(location, id, field string, s, e interface{}) {
+		mismatches = append(mismatches, AddonStartupMismatch{id, location, field, s, e})
+	}
+	for location, loc := range startup {
+		for id, s := range loc.Addons {
+			k := key{location, id}
+			a, ok := addons[k]
+			if !ok {
+				add(location, id, "present", true, false)
+				continue
+			}
+			delete(addons, k)
+			if s.Enabled != a.Active {
+				add(location, id, "enabled", s.Enabled, a.Active)
+			}
+			if s.Version != a.Version {
+				add(location, id, "version", s.Version, a.Version)
+			}
+			if !equalSignedState(s.SignedState, a.SignedState) {
+				add(location, id, "signedState", s.SignedState, a.SignedState)
+			}
+			if s.RootURI != a.RootURI && a.RootURI != "" {
+				add(location, id, "rootURI", s.RootURI, a.RootURI)
+			}
+		}
+	}
+	for k := range addons {
+		add(k.location, k.id, "present", false, true)
+	}
+	sort.Slice(mismatches, func(i, j int) bool {
+		a, b := &mismatches[i], &mismatches[j]
+		if a.Location != b.Location {
+			return a.Location < b.Location
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Field < b.Field
+	})
+	return mismatches
+}
+
+func equalSignedState(a, b *SignedState) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/andrewarchi/browser/compress/mozlz4"
+)
+
+func TestAddonStartup(t *testing.T) {
+	const data = `{
+  "app-profile": {
+    "path": "/home/user/.mozilla/firefox/a.default/extensions",
+    "addons": {
+      "uBlock0@raymondhill.net": {
+        "enabled": true, "lastModifiedTime": 1613610123000, "path": "uBlock0@raymondhill.net.xpi",
+        "version": "1.33.2", "rootURI": "jar:file:///home/user/.mozilla/firefox/a.default/extensions/uBlock0@raymondhill.net.xpi!/",
+        "runInSafeMode": false, "signedState": 2, "signedDate": 1612345678000,
+        "telemetryKey": "uBlock0%40raymondhill.net:1.33.2", "dependencies": [], "recommendationState": null, "loader": null
+      },
+      "stale@example.com": {
+        "enabled": false, "lastModifiedTime": 1613610123000, "path": "stale@example.com.xpi", "version": "1.0",
+        "rootURI": "jar:file:///stale.xpi!/", "runInSafeMode": false, "signedState": 2,
+        "telemetryKey": "stale%40example.com:1.0", "dependencies": [], "recommendationState": null, "loader": null
+      }
+    },
+    "staticAddons": {},
+    "checkStartupModifications": true
+  },
+  "app-builtin": {
+    "addons": {
+      "default-theme@mozilla.org": {
+        "enabled": true, "lastModifiedTime": 0, "path": "", "version": "1.1",
+        "rootURI": "resource://default-theme/", "runInSafeMode": true, "type": "theme",
+        "telemetryKey": "default-theme%40mozilla.org:1.1", "dependencies": [], "recommendationState": null, "loader": null
+      }
+    },
+    "staticAddons": {}
+  }
+}`
+	b, err := mozlz4.Encode([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "addonStartup.json.lz4")
+	if err := os.WriteFile(filename, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	startup, err := ParseAddonStartup(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ublock := startup[LocationProfile].Addons["uBlock0@raymondhill.net"]
+	if ublock == nil || !ublock.Enabled || ublock.SignedState == nil || *ublock.SignedState != SignedSigned {
+		t.Errorf("got uBlock Origin state %+v", ublock)
+	}
+
+	var extensions Extensions
+	if err := json.Unmarshal([]byte(`{"schemaVersion": 33, "addons": [
+		{"id": "uBlock0@raymondhill.net", "version": "1.33.3", "active": true, "signedState": 2, "location": "app-profile",
+		 "rootURI": "jar:file:///home/user/.mozilla/firefox/a.default/extensions/uBlock0@raymondhill.net.xpi!/"},
+		{"id": "default-theme@mozilla.org", "version": "1.1", "active": true, "location": "app-builtin", "type": "theme"},
+		{"id": "new@example.com", "version": "2.0", "active": true, "location": "app-profile"}
+	]}`), &extensions); err != nil {
+		t.Fatal(err)
+	}
+	got := CompareAddonStartup(startup, &extensions)
+	want := []AddonStartupMismatch{
+		{"new@example.com", LocationProfile, "present", false, true},
+		{"stale@example.com", LocationProfile, "present", true, false},
+		{"uBlock0@raymondhill.net", LocationProfile, "version", "1.33.2", "1.33.3"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
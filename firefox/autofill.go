@@ -0,0 +1,93 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"github.com/andrewarchi/browser/jsonutil"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+)
+
+// AutofillProfiles contains the saved addresses and credit cards in
+// autofill-profiles.json. Deleted records are kept as tombstones with
+// only their GUID and modification time, so that deletions are synced.
+// https://searchfox.org/mozilla-central/source/toolkit/components/formautofill/FormAutofillStorageBase.jsm
+type AutofillProfiles struct {
+	Version     int                  `json:"version"` // e.g. 1
+	Addresses   []AutofillAddress    `json:"addresses"`
+	CreditCards []AutofillCreditCard `json:"creditCards"`
+}
+
+// AutofillAddress is a saved address. Names are split into given,
+// additional, and family names before address schema version 2, which
+// stores the full name.
+type AutofillAddress struct {
+	GUID             string            `json:"guid"`
+	Version          int               `json:"version,omitempty"` // address schema version, e.g. 1
+	Deleted          bool              `json:"deleted,omitempty"`
+	Name             string            `json:"name,omitempty"`
+	GivenName        string            `json:"given-name,omitempty"`
+	AdditionalName   string            `json:"additional-name,omitempty"`
+	FamilyName       string            `json:"family-name,omitempty"`
+	Organization     string            `json:"organization,omitempty"`
+	StreetAddress    string            `json:"street-address,omitempty"` // lines separated by "\n"
+	AddressLevel3    string            `json:"address-level3,omitempty"` // e.g. dependent locality
+	AddressLevel2    string            `json:"address-level2,omitempty"` // e.g. city
+	AddressLevel1    string            `json:"address-level1,omitempty"` // e.g. state or province
+	PostalCode       string            `json:"postal-code,omitempty"`
+	Country          string            `json:"country,omitempty"` // ISO 3166-1 alpha-2 code, e.g. "US"
+	Tel              string            `json:"tel,omitempty"`     // E.164, e.g. "+15555550100"
+	Email            string            `json:"email,omitempty"`
+	TimeCreated      timefmt.UnixMilli `json:"timeCreated"`
+	TimeLastUsed     timefmt.UnixMilli `json:"timeLastUsed"`
+	TimeLastModified timefmt.UnixMilli `json:"timeLastModified"`
+	TimesUsed        int               `json:"timesUsed"`
+	Sync             *AutofillSync     `json:"_sync,omitempty"`
+}
+
+// AutofillCreditCard is a saved credit card. The number is encrypted
+// with a key in the operating system keystore and only its last four
+// digits are kept in plaintext.
+type AutofillCreditCard struct {
+	GUID              string            `json:"guid"`
+	Version           int               `json:"version,omitempty"` // credit card schema version, e.g. 3
+	Deleted           bool              `json:"deleted,omitempty"`
+	CCName            string            `json:"cc-name,omitempty"`
+	CCNumber          string            `json:"cc-number,omitempty"`           // masked, e.g. "************1234"
+	CCNumberEncrypted string            `json:"cc-number-encrypted,omitempty"` // base64-encoded
+	CCExpMonth        int               `json:"cc-exp-month,omitempty"`
+	CCExpYear         int               `json:"cc-exp-year,omitempty"`
+	CCType            string            `json:"cc-type,omitempty"` // network, e.g. "visa" or "mastercard"
+	TimeCreated       timefmt.UnixMilli `json:"timeCreated"`
+	TimeLastUsed      timefmt.UnixMilli `json:"timeLastUsed"`
+	TimeLastModified  timefmt.UnixMilli `json:"timeLastModified"`
+	TimesUsed         int               `json:"timesUsed"`
+	Sync              *AutofillSync     `json:"_sync,omitempty"`
+}
+
+// AutofillSync is the sync metadata of an autofill record.
+type AutofillSync struct {
+	ChangeCounter int `json:"changeCounter"` // local changes not yet synced
+}
+
+// ParseAutofillProfiles parses autofill-profiles.json in a Firefox
+// profile.
+func ParseAutofillProfiles(filename string) (*AutofillProfiles, error) {
+	var profiles AutofillProfiles
+	if err := jsonutil.DecodeFile(filename, &profiles); err != nil {
+		return nil, err
+	}
+	return &profiles, nil
+}
+
+// LastFour returns the last four digits of the card number, from the
+// masked number.
+func (c *AutofillCreditCard) LastFour() string {
+	if len(c.CCNumber) < 4 {
+		return c.CCNumber
+	}
+	return c.CCNumber[len(c.CCNumber)-4:]
+}
@@ -0,0 +1,463 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/jsonutil"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+)
+
+// Add-on blocklist:
+// https://searchfox.org/mozilla-central/source/toolkit/mozapps/extensions/Blocklist.jsm
+//
+// The add-on blocklist is distributed with Remote Settings in the
+// "blocklists/addons" collection. Versions of Firefox before the
+// blocklist was stored in IndexedDB cached the collection in
+// blocklist-addons.json and the legacy XML blocklist in blocklist.xml
+// in the profile.
+
+// BlocklistState is the blocklist state of an add-on, from
+// nsIBlocklistService.
+type BlocklistState uint8
+
+// Values for BlocklistState:
+const (
+	BlocklistNotBlocked         BlocklistState = 0
+	BlocklistSoftBlocked        BlocklistState = 1 // disabled, but can be re-enabled by the user
+	BlocklistBlocked            BlocklistState = 2
+	BlocklistOutdated           BlocklistState = 3
+	BlocklistVulnerableUpdate   BlocklistState = 4
+	BlocklistVulnerableNoUpdate BlocklistState = 5
+)
+
+const (
+	blocklistDefaultSeverity = 3
+	blocklistLevel           = 2 // extensions.blocklist.level
+	firefoxAppID             = "{ec8030f7-c20a-464f-9b0e-13a3a9e97384}"
+	toolkitAppID             = "toolkit@mozilla.org"
+)
+
+// SignedState is the signature state of an add-on, from
+// AddonManager.SIGNEDSTATE_*.
+type SignedState int8
+
+// Values for SignedState:
+const (
+	SignedBroken      SignedState = -2 // signature is invalid
+	SignedUnknown     SignedState = -1 // signature could not be checked
+	SignedMissing     SignedState = 0  // add-on is unsigned
+	SignedPreliminary SignedState = 1  // preliminarily reviewed
+	SignedSigned      SignedState = 2  // fully reviewed
+	SignedSystem      SignedState = 3  // system add-on
+	SignedPrivileged  SignedState = 4  // privileged add-on
+)
+
+// BlockedAddon is an entry in the add-on blocklist, which blocks
+// versions of add-ons matching a GUID.
+type BlockedAddon struct {
+	ID           string            `json:"id"` // record ID
+	GUID         string            `json:"guid"`
+	BlockID      string            `json:"blockID"`           // e.g. "i1234"
+	Enabled      *bool             `json:"enabled,omitempty"` // enabled when nil
+	VersionRange []BlockedVersions `json:"versionRange"`
+	Prefs        []string          `json:"prefs"` // preferences reset when blocked
+	Details      BlockDetails      `json:"details"`
+	Schema       int64             `json:"schema,omitempty"`
+	LastModified timefmt.UnixMilli `json:"last_modified"`
+	guidRE       *regexp.Regexp    // compiled when GUID is a regular expression
+	guidErr      error             // error compiling GUID
+}
+
+// BlockedVersions is a range of blocked versions of an add-on. Empty
+// versions are unbounded.
+type BlockedVersions struct {
+	MinVersion        string                     `json:"minVersion"`
+	MaxVersion        string                     `json:"maxVersion"`
+	Severity          int                        `json:"severity"` // 0 or 1 for soft blocks and 3 for hard blocks
+	TargetApplication []BlockedTargetApplication `json:"targetApplication"`
+}
+
+// BlockedTargetApplication restricts a blocked version range to
+// versions of an application.
+type BlockedTargetApplication struct {
+	GUID       string `json:"guid"` // e.g. "{ec8030f7-c20a-464f-9b0e-13a3a9e97384}" for Firefox
+	MinVersion string `json:"minVersion"`
+	MaxVersion string `json:"maxVersion"`
+}
+
+// BlockDetails describes the reason for a block.
+type BlockDetails struct {
+	Bug     string `json:"bug"` // URL of bug
+	Who     string `json:"who"`
+	Why     string `json:"why"`
+	Name    string `json:"name"`
+	Created string `json:"created"` // e.g. "2019-05-06T16:27:12Z"
+}
+
+// ParseAddonBlocklist parses blocklist-addons.json in a Firefox
+// profile, which caches the Remote Settings add-on blocklist.
+func ParseAddonBlocklist(filename string) ([]BlockedAddon, error) {
+	var blocklist struct {
+		Data []BlockedAddon `json:"data"`
+	}
+	if err := jsonutil.DecodeFile(filename, &blocklist); err != nil {
+		return nil, err
+	}
+	return blocklist.Data, nil
+}
+
+// ParseBlocklistXML parses the add-on entries of blocklist.xml in a
+// Firefox profile.
+func ParseBlocklistXML(filename string) ([]BlockedAddon, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var blocklist struct {
+		EmItems []struct {
+			BlockID      string   `xml:"blockID,attr"`
+			ID           string   `xml:"id,attr"`
+			Prefs        []string `xml:"prefs>pref"`
+			VersionRange []struct {
+				MinVersion        string `xml:"minVersion,attr"`
+				MaxVersion        string `xml:"maxVersion,attr"`
+				Severity          string `xml:"severity,attr"`
+				TargetApplication []struct {
+					ID           string `xml:"id,attr"`
+					VersionRange []struct {
+						MinVersion string `xml:"minVersion,attr"`
+						MaxVersion string `xml:"maxVersion,attr"`
+					} `xml:"versionRange"`
+				} `xml:"targetApplication"`
+			} `xml:"versionRange"`
+		} `xml:"emItems>emItem"`
+	}
+	if err := xml.NewDecoder(f).Decode(&blocklist); err != nil {
+		return nil, fmt.Errorf("firefox: %s: %w", filename, err)
+	}
+	addons := make([]BlockedAddon, len(blocklist.EmItems))
+	for i, item := range blocklist.EmItems {
+		a := BlockedAddon{GUID: item.ID, BlockID: item.BlockID, Prefs: item.Prefs}
+		for _, vr := range item.VersionRange {
+			v := BlockedVersions{MinVersion: vr.MinVersion, MaxVersion: vr.MaxVersion, Severity: blocklistDefaultSeverity}
+			if vr.Severity != "" {
+				severity, err := strconv.Atoi(vr.Severity)
+				if err != nil {
+					return nil, fmt.Errorf("firefox: %s: block %s: severity: %w", filename, item.BlockID, err)
+				}
+				v.Severity = severity
+			}
+			for _, app := range vr.TargetApplication {
+				for _, avr := range app.VersionRange {
+					v.TargetApplication = append(v.TargetApplication, BlockedTargetApplication{
+						GUID:       app.ID,
+						MinVersion: avr.MinVersion,
+						MaxVersion: avr.MaxVersion,
+					})
+				}
+			}
+			a.VersionRange = append(a.VersionRange, v)
+		}
+		addons[i] = a
+	}
+	return addons, nil
+}
+
+// ProfileAddonBlocklist reads the add-on blocklist cached in a Firefox
+// profile from blocklist-addons.json or, if missing, blocklist.xml. A
+// blocklist that fails to parse is handled as by
+// ProfileAddonBlocklistOptions with the zero Options.
+func ProfileAddonBlocklist(profileDir string) ([]BlockedAddon, error) {
+	return ProfileAddonBlocklistOptions(profileDir, browser.Options{})
+}
+
+// ProfileAddonBlocklistOptions reads the add-on blocklist cached in a
+// Firefox profile and handles a blocklist that fails to parse by the
+// error policy in opts.
+func ProfileAddonBlocklistOptions(profileDir string, opts browser.Options) ([]BlockedAddon, error) {
+	filename := filepath.Join(profileDir, "blocklist-addons.json")
+	blocklist, err := ParseAddonBlocklist(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		filename = filepath.Join(profileDir, "blocklist.xml")
+		blocklist, err = ParseBlocklistXML(filename)
+	}
+	if err == nil || errors.Is(err, os.ErrNotExist) {
+		return blocklist, err
+	}
+	h := opts.Handler()
+	if err := h.File(filename, err); err != nil {
+		return nil, err
+	}
+	return nil, h.Err()
+}
+
+// Matches reports whether the entry blocks the add-on with the given ID
+// and version and, if it does, the resulting blocklist state. Version
+// ranges restricted to other applications are ignored, but the version
+// of Firefox is not checked.
+func (b *BlockedAddon) Matches(id, version string) (BlocklistState, bool) {
+	if b.Enabled != nil && !*b.Enabled || !b.matchesGUID(id) {
+		return BlocklistNotBlocked, false
+	}
+	for _, vr := range b.VersionRange {
+		if !vr.appliesToFirefox() {
+			continue
+		}
+		if vr.MinVersion != "" && compareVersions(version, vr.MinVersion) < 0 ||
+			vr.MaxVersion != "" && compareVersions(version, vr.MaxVersion) > 0 {
+			continue
+		}
+		if vr.Severity >= blocklistLevel {
+			return BlocklistBlocked, true
+		}
+		return BlocklistSoftBlocked, true
+	}
+	return BlocklistNotBlocked, false
+}
+
+// matchesGUID reports whether the entry matches the add-on ID. GUIDs
+// of the form "/pattern/" are regular expressions matching many IDs.
+func (b *BlockedAddon) matchesGUID(id string) bool {
+	if len(b.GUID) >= 2 && b.GUID[0] == '/' && b.GUID[len(b.GUID)-1] == '/' {
+		if b.guidRE == nil && b.guidErr == nil {
+			b.guidRE, b.guidErr = regexp.Compile(b.GUID[1 : len(b.GUID)-1])
+		}
+		return b.guidErr == nil && b.guidRE.MatchString(id)
+	}
+	// UUIDs are compared case-insensitively.
+	if strings.HasPrefix(b.GUID, "{") {
+		return strings.EqualFold(b.GUID, id)
+	}
+	return b.GUID == id
+}
+
+func (vr *BlockedVersions) appliesToFirefox() bool {
+	if len(vr.TargetApplication) == 0 {
+		return true
+	}
+	for _, app := range vr.TargetApplication {
+		if app.GUID == firefoxAppID || app.GUID == toolkitAppID {
+			return true
+		}
+	}
+	return false
+}
+
+// AddonStatus is an installed add-on that is blocklisted or has an
+// invalid signature.
+type AddonStatus struct {
+	Addon          *Addon
+	BlocklistState BlocklistState  // state recorded in extensions.json
+	Blocks         []*BlockedAddon // matching entries in the cached blocklist
+	BlockState     BlocklistState  // most severe state of Blocks
+	SignedState    *SignedState    // nil when signing is not required
+}
+
+// IsBlocked reports whether the add-on is hard or soft blocked, either
+// by the state recorded by Firefox or by the cached blocklist.
+func (s *AddonStatus) IsBlocked() bool {
+	return s.BlocklistState.IsBlocked() || s.BlockState.IsBlocked()
+}
+
+// HasInvalidSignature reports whether the add-on requires a signature,
+// but is unsigned or its signature is broken or could not be verified.
+func (s *AddonStatus) HasInvalidSignature() bool {
+	return s.SignedState != nil && *s.SignedState <= SignedMissing
+}
+
+// BlocklistReport reports the installed add-ons which are blocklisted,
+// either as recorded in extensions.json or by matching entries in the
+// cached blocklist, or which have invalid signatures. The blocklist may
+// be nil to use only the state in extensions.json. Add-ons are ordered
+// as in extensions.json.
+func (e *Extensions) BlocklistReport(blocklist []BlockedAddon) []AddonStatus {
+	var report []AddonStatus
+	for i := range e.Addons {
+		a := &e.Addons[i]
+		s := AddonStatus{Addon: a, BlocklistState: a.BlocklistState, SignedState: a.SignedState}
+		if a.ID != nil {
+			id := a.ID.String()
+			for j := range blocklist {
+				if state, ok := blocklist[j].Matches(id, a.Version); ok {
+					s.Blocks = append(s.Blocks, &blocklist[j])
+					if state > s.BlockState {
+						s.BlockState = state
+					}
+				}
+			}
+		}
+		if s.IsBlocked() || s.HasInvalidSignature() {
+			report = append(report, s)
+		}
+	}
+	return report
+}
+
+// IsBlocked reports whether the state is a hard or soft block.
+func (state BlocklistState) IsBlocked() bool {
+	return state == BlocklistSoftBlocked || state == BlocklistBlocked
+}
+
+// compareVersions compares two toolkit version strings, returning -1,
+// 0, or 1. Versions are dot-separated parts, each of the form
+// {number}{string}{number}{extra}, where "*" is infinity and a "+"
+// string increments the number and is treated as "pre".
+// https://searchfox.org/mozilla-central/source/xpcom/base/nsVersionComparator.cpp
+func compareVersions(a, b string) int {
+	for a != "" || b != "" {
+		var pa, pb string
+		pa, a = splitVersionPart(a)
+		pb, b = splitVersionPart(b)
+		if c := compareVersionParts(parseVersionPart(pa), parseVersionPart(pb)); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func splitVersionPart(v string) (part, rest string) {
+	if i := strings.IndexByte(v, '.'); i >= 0 {
+		return v[:i], v[i+1:]
+	}
+	return v, ""
+}
+
+type versionPart struct {
+	A int64
+	B string
+	C int64
+	D string
+}
+
+func parseVersionPart(part string) versionPart {
+	var p versionPart
+	if part == "" {
+		return p
+	}
+	if part == "*" {
+		p.A = 1<<63 - 1
+		return p
+	}
+	p.A, part = parseVersionInt(part)
+	if strings.HasPrefix(part, "+") {
+		p.A++
+		p.B = "pre"
+		return p
+	}
+	i := strings.IndexAny(part, "+-0123456789")
+	if i < 0 {
+		p.B = part
+		return p
+	}
+	p.B = part[:i]
+	p.C, p.D = parseVersionInt(part[i:])
+	return p
+}
+
+func parseVersionInt(s string) (int64, string) {
+	i := 0
+	if i < len(s) && (s[i] == '-' || s[i] == '+') {
+		i++
+	}
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		i++
+	}
+	n, err := strconv.ParseInt(s[:i], 10, 64)
+	if err != nil {
+		return 0, s[i:]
+	}
+	return n, s[i:]
+}
+
+func compareVersionParts(p, q versionPart) int {
+	if c := compareInts(p.A, q.A); c != 0 {
+		return c
+	}
+	if c := compareVersionStrings(p.B, q.B); c != 0 {
+		return c
+	}
+	if c := compareInts(p.C, q.C); c != 0 {
+		return c
+	}
+	return compareVersionStrings(p.D, q.D)
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareVersionStrings compares version strings, where an empty
+// string is greater than any non-empty string, so that "1.0pre" is
+// before "1.0".
+func compareVersionStrings(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+func (state BlocklistState) String() string {
+	switch state {
+	case BlocklistNotBlocked:
+		return "not_blocked"
+	case BlocklistSoftBlocked:
+		return "soft_blocked"
+	case BlocklistBlocked:
+		return "blocked"
+	case BlocklistOutdated:
+		return "outdated"
+	case BlocklistVulnerableUpdate:
+		return "vulnerable_update_available"
+	case BlocklistVulnerableNoUpdate:
+		return "vulnerable_no_update"
+	default:
+		return fmt.Sprintf("blocklist_state(%d)", uint8(state))
+	}
+}
+
+func (state SignedState) String() string {
+	switch state {
+	case SignedBroken:
+		return "broken"
+	case SignedUnknown:
+		return "unknown"
+	case SignedMissing:
+		return "missing"
+	case SignedPreliminary:
+		return "preliminary"
+	case SignedSigned:
+		return "signed"
+	case SignedSystem:
+		return "system"
+	case SignedPrivileged:
+		return "privileged"
+	default:
+		return fmt.Sprintf("signed_state(%d)", int8(state))
+	}
+}
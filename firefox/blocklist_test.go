@@ -0,0 +1,136 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrewarchi/browser/jsonutil/uuid"
+)
+
+func TestCompareVersions(t *testing.T) {
+	// Ascending groups of equal versions from nsIVersionComparator.
+	versions := [][]string{
+		{"1.0pre1"}, {"1.0pre2"}, {"1.0", "1.0.0", "1.0.0.0"},
+		{"1.1pre", "1.1pre0", "1.0+"}, {"1.1pre1a"}, {"1.1pre1"},
+		{"1.1pre10a"}, {"1.1pre10"}, {"1.1"}, {"1.1.0.1"}, {"1.1.1"},
+		{"1.1.*"}, {"1.*"}, {"2.0"}, {"2.1"}, {"3.0.-1"}, {"3.0"},
+	}
+	for i, group1 := range versions {
+		for j, group2 := range versions {
+			want := compareInts(int64(i), int64(j))
+			for _, v1 := range group1 {
+				for _, v2 := range group2 {
+					if got := compareVersions(v1, v2); got != want {
+						t.Errorf("compare %q and %q: got: %d, want: %d", v1, v2, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestBlocklistReport(t *testing.T) {
+	disabled := false
+	signed, missing := SignedSigned, SignedMissing
+	blocklist := []BlockedAddon{
+		{GUID: "bad@example.com", BlockID: "i1", VersionRange: []BlockedVersions{
+			{MinVersion: "1.0", MaxVersion: "1.*", Severity: 3},
+		}},
+		{GUID: "/^(soft|other)@example\\.com$/", BlockID: "i2", VersionRange: []BlockedVersions{
+			{Severity: 1},
+		}},
+		{GUID: "{3550F703-E582-4d05-9A08-453D09BDFDC6}", BlockID: "i3", VersionRange: []BlockedVersions{
+			{Severity: 3, TargetApplication: []BlockedTargetApplication{{GUID: "{3550f703-e582-4d05-9a08-453d09bdfdc6}"}}},
+		}},
+		{GUID: "off@example.com", BlockID: "i4", Enabled: &disabled, VersionRange: []BlockedVersions{{Severity: 3}}},
+	}
+	e := &Extensions{Addons: []Addon{
+		{ID: &uuid.Firefox{ID: "bad@example.com"}, Version: "1.5", SignedState: &signed},
+		{ID: &uuid.Firefox{ID: "bad@example.com"}, Version: "2.0", SignedState: &signed},
+		{ID: &uuid.Firefox{ID: "soft@example.com"}, Version: "1", SignedState: &signed},
+		{ID: &uuid.Firefox{ID: "{3550f703-e582-4d05-9a08-453d09bdfdc6}"}, Version: "1", SignedState: &signed},
+		{ID: &uuid.Firefox{ID: "off@example.com"}, Version: "1", SignedState: &signed},
+		{ID: &uuid.Firefox{ID: "unsigned@example.com"}, Version: "1", SignedState: &missing},
+		{ID: &uuid.Firefox{ID: "builtin@mozilla.org"}, Version: "1"},
+		{ID: &uuid.Firefox{ID: "recorded@example.com"}, Version: "1", BlocklistState: BlocklistBlocked},
+	}}
+	want := []struct {
+		Version    string
+		BlockState BlocklistState
+		Blocks     int
+		Invalid    bool
+	}{
+		{"1.5", BlocklistBlocked, 1, false},
+		{"1", BlocklistSoftBlocked, 1, false},
+		{"1", BlocklistNotBlocked, 0, true},
+		{"1", BlocklistNotBlocked, 0, false},
+	}
+	ids := []string{"bad@example.com", "soft@example.com", "unsigned@example.com", "recorded@example.com"}
+	report := e.BlocklistReport(blocklist)
+	if len(report) != len(want) {
+		t.Fatalf("got %d add-ons, want %d", len(report), len(want))
+	}
+	for i, s := range report {
+		w := want[i]
+		if id := s.Addon.ID.String(); id != ids[i] || s.Addon.Version != w.Version {
+			t.Errorf("#%d: got add-on %s %s, want %s %s", i, id, s.Addon.Version, ids[i], w.Version)
+		}
+		if s.BlockState != w.BlockState || len(s.Blocks) != w.Blocks || s.HasInvalidSignature() != w.Invalid {
+			t.Errorf("#%d: got: %s, %d blocks, invalid %t, want: %s, %d blocks, invalid %t",
+				i, s.BlockState, len(s.Blocks), s.HasInvalidSignature(), w.BlockState, w.Blocks, w.Invalid)
+		}
+		if !s.IsBlocked() && !s.HasInvalidSignature() {
+			t.Errorf("#%d: reported add-on is neither blocked nor invalid", i)
+		}
+	}
+}
+
+func TestParseBlocklistXML(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "blocklist.xml")
+	data := `<?xml version="1.0"?>
+<blocklist xmlns="http://www.mozilla.org/2006/addons-blocklist" lastupdate="1600000000000">
+  <emItems>
+    <emItem blockID="i1" id="bad@example.com">
+      <prefs><pref>browser.startup.homepage</pref></prefs>
+      <versionRange minVersion="0" maxVersion="*" severity="1">
+        <targetApplication id="{ec8030f7-c20a-464f-9b0e-13a3a9e97384}">
+          <versionRange minVersion="57.0" maxVersion="*"/>
+        </targetApplication>
+      </versionRange>
+    </emItem>
+    <emItem blockID="i2" id="/^bad\d+@example\.com$/">
+      <versionRange/>
+    </emItem>
+  </emItems>
+</blocklist>
+`
+	if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	blocklist, err := ProfileAddonBlocklist(filepath.Dir(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocklist) != 2 {
+		t.Fatalf("got %d entries, want 2", len(blocklist))
+	}
+	b := blocklist[0]
+	if b.GUID != "bad@example.com" || len(b.Prefs) != 1 || len(b.VersionRange) != 1 ||
+		b.VersionRange[0].Severity != 1 || len(b.VersionRange[0].TargetApplication) != 1 ||
+		b.VersionRange[0].TargetApplication[0].MinVersion != "57.0" {
+		t.Errorf("got entry %+v", b)
+	}
+	if state, ok := blocklist[0].Matches("bad@example.com", "3.2"); !ok || state != BlocklistSoftBlocked {
+		t.Errorf("got state %s, %t, want soft block", state, ok)
+	}
+	if state, ok := blocklist[1].Matches("bad42@example.com", "1.0"); !ok || state != BlocklistBlocked {
+		t.Errorf("got state %s, %t, want block", state, ok)
+	}
+}
@@ -9,17 +9,23 @@ package firefox
 import (
 	"encoding/base64"
 	"fmt"
+	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/bookmark"
 	"github.com/andrewarchi/browser/jsonutil"
 	"github.com/andrewarchi/browser/jsonutil/timefmt"
 )
 
 // BookmarkBackup is a backup of Firefox bookmarks.
 type BookmarkBackup struct {
+	Filename   string    // path of backup file
 	Date       time.Time // date of backup
 	Count      int       // number of entries
 	Hash       []byte    // hash of json contents
@@ -34,16 +40,38 @@ type BookmarkBackupEntry struct {
 	Index        int                   `json:"index"` // index of child
 	DateAdded    timefmt.UnixMicro     `json:"dateAdded"`
 	LastModified timefmt.UnixMicro     `json:"lastModified"`
-	ID           int                   `json:"id"`       // sequential (e.g. 0, 1, 2)
-	TypeCode     int                   `json:"typeCode"` // place: 1, place-container: 2
-	Type         string                `json:"type"`     // "text/x-moz-place", "text/x-moz-place-container"
-	Root         string                `json:"root,omitempty"`
+	ID           int                   `json:"id"`             // sequential (e.g. 0, 1, 2)
+	TypeCode     int                   `json:"typeCode"`       // place: 1, place-container: 2, place-separator: 3
+	Type         string                `json:"type"`           // "text/x-moz-place", "text/x-moz-place-container", "text/x-moz-place-separator"
+	Root         string                `json:"root,omitempty"` // e.g. "placesRoot", "bookmarksMenuFolder", "toolbarFolder"
 	Children     []BookmarkBackupEntry `json:"children,omitempty"`
 	IconURI      string                `json:"iconuri,omitempty"`
 	URI          string                `json:"uri,omitempty"`
+	Tags         string                `json:"tags,omitempty"` // comma-separated
+	Keyword      string                `json:"keyword,omitempty"`
+	PostData     string                `json:"postData,omitempty"`
+	Charset      string                `json:"charset,omitempty"`
+	Annos        []BookmarkBackupAnno  `json:"annos,omitempty"`
 }
 
-// TODO handle all kinds of entry types from Firefox source.
+// BookmarkBackupAnno is an annotation on an entry in a bookmark backup,
+// such as "bookmarkProperties/description". Annotations were removed
+// from places in Firefox 71, but remain in older backups.
+type BookmarkBackupAnno struct {
+	Name     string      `json:"name"`
+	Flags    int         `json:"flags"`
+	Expires  int         `json:"expires"`
+	MIMEType string      `json:"mimeType,omitempty"`
+	Type     int         `json:"type,omitempty"`
+	Value    interface{} `json:"value"` // string or number
+}
+
+// Values for BookmarkBackupEntry.TypeCode:
+const (
+	bookmarkBackupPlace     = 1
+	bookmarkBackupContainer = 2
+	bookmarkBackupSeparator = 3
+)
 
 // ParseBookmarkBackup parses a bookmarks file within bookmarkbackups in
 // a Firefox profile.
@@ -87,7 +115,7 @@ func GetBookmarkBackupMetadata(filename string) (*BookmarkBackup, error) {
 	if len(matches) != 5 {
 		return nil, fmt.Errorf("firefox: filename is not a bookmark backup: %q", base)
 	}
-	var meta BookmarkBackup
+	meta := BookmarkBackup{Filename: filename}
 	var err error
 	meta.Date, err = time.ParseInLocation("2006-01-02", matches[1], time.Local)
 	if err != nil {
@@ -112,3 +140,163 @@ func GetBookmarkBackupMetadata(filename string) (*BookmarkBackup, error) {
 	meta.Compressed = matches[4] == "jsonlz4"
 	return &meta, err
 }
+
+// ListBookmarkBackups lists the bookmark backups in bookmarkbackups in a
+// Firefox profile, oldest first, without reading them. Files that are
+// not backups are skipped. Backups with invalid names are handled as by
+// ListBookmarkBackupsOptions with the zero Options.
+func ListBookmarkBackups(profileDir string) ([]*BookmarkBackup, error) {
+	return ListBookmarkBackupsOptions(profileDir, browser.Options{})
+}
+
+// ListBookmarkBackupsOptions lists the bookmark backups in a Firefox
+// profile and handles backups with invalid names by the error policy in
+// opts.
+func ListBookmarkBackupsOptions(profileDir string, opts browser.Options) ([]*BookmarkBackup, error) {
+	h := opts.Handler()
+	dir := filepath.Join(profileDir, "bookmarkbackups")
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []*BookmarkBackup
+	for _, f := range files {
+		if f.IsDir() || !bookmarkBackupPattern.MatchString(f.Name()) {
+			continue
+		}
+		filename := filepath.Join(dir, f.Name())
+		backup, err := GetBookmarkBackupMetadata(filename)
+		if err != nil {
+			if err := h.File(filename, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		backups = append(backups, backup)
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].Date.Before(backups[j].Date)
+	})
+	return backups, h.Err()
+}
+
+// NewestBookmarkBackup parses the most recent bookmark backup in
+// a Firefox profile. Backups with invalid names are handled as by
+// NewestBookmarkBackupOptions with the zero Options.
+func NewestBookmarkBackup(profileDir string) (*BookmarkBackup, error) {
+	return NewestBookmarkBackupOptions(profileDir, browser.Options{})
+}
+
+// NewestBookmarkBackupOptions parses the most recent bookmark backup in
+// a Firefox profile and handles backups with invalid names by the error
+// policy in opts.
+func NewestBookmarkBackupOptions(profileDir string, opts browser.Options) (*BookmarkBackup, error) {
+	h := opts.Handler()
+	backups, err := ListBookmarkBackupsOptions(profileDir, opts)
+	if err := h.Merge(err); err != nil {
+		return nil, err
+	}
+	if len(backups) == 0 {
+		return nil, fmt.Errorf("firefox: no bookmark backups: %w", os.ErrNotExist)
+	}
+	backup, err := ParseBookmarkBackup(backups[len(backups)-1].Filename)
+	if err != nil {
+		return nil, err
+	}
+	return backup, h.Err()
+}
+
+// Attributes in the bookmark model for bookmark backup fields without a
+// corresponding field. Annotations are kept as attributes named by the
+// annotation.
+const (
+	attrCharset  = "last_charset"
+	attrPostData = "post_data"
+)
+
+// Tree converts the backup to the bookmark model. The returned entries
+// are the menu, toolbar, other, and mobile roots, in order, as with
+// ParsePlacesBookmarks. Children are ordered by index.
+func (b *BookmarkBackup) Tree() []bookmark.BookmarkEntry {
+	if b.Bookmarks == nil {
+		return nil
+	}
+	return b.Bookmarks.tree()
+}
+
+func (e *BookmarkBackupEntry) tree() []bookmark.BookmarkEntry {
+	children := make([]*BookmarkBackupEntry, len(e.Children))
+	for i := range e.Children {
+		children[i] = &e.Children[i]
+	}
+	sort.SliceStable(children, func(i, j int) bool {
+		return children[i].Index < children[j].Index
+	})
+	entries := make([]bookmark.BookmarkEntry, 0, len(children))
+	for _, child := range children {
+		if child.GUID == PlacesTagsGUID {
+			continue
+		}
+		entries = append(entries, child.entry())
+	}
+	return entries
+}
+
+func (e *BookmarkBackupEntry) entry() bookmark.BookmarkEntry {
+	var attrs []bookmark.Attr
+	if e.Charset != "" {
+		attrs = append(attrs, bookmark.Attr{Key: attrCharset, Val: e.Charset})
+	}
+	if e.PostData != "" {
+		attrs = append(attrs, bookmark.Attr{Key: attrPostData, Val: e.PostData})
+	}
+	for _, anno := range e.Annos {
+		var val string
+		switch v := anno.Value.(type) {
+		case string:
+			val = v
+		case float64:
+			val = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			val = fmt.Sprint(v)
+		}
+		attrs = append(attrs, bookmark.Attr{Key: anno.Name, Val: val})
+	}
+	switch e.TypeCode {
+	case bookmarkBackupPlace:
+		var tags []string
+		if e.Tags != "" {
+			tags = strings.Split(e.Tags, ",")
+		}
+		return &bookmark.Bookmark{
+			Title:        e.Title,
+			URL:          e.URI,
+			GUID:         e.GUID,
+			AddDate:      e.DateAdded.Time,
+			LastModified: e.LastModified.Time,
+			IconURI:      e.IconURI,
+			Tags:         tags,
+			Keyword:      e.Keyword,
+			Attrs:        attrs,
+		}
+	case bookmarkBackupSeparator:
+		return &bookmark.BookmarkSeparator{
+			GUID:         e.GUID,
+			AddDate:      e.DateAdded.Time,
+			LastModified: e.LastModified.Time,
+		}
+	default:
+		title := e.Title
+		if t, ok := placesRootTitles[e.GUID]; ok && title == "" {
+			title = t
+		}
+		return &bookmark.BookmarkFolder{
+			Title:        title,
+			GUID:         e.GUID,
+			AddDate:      e.DateAdded.Time,
+			LastModified: e.LastModified.Time,
+			Attrs:        attrs,
+			Entries:      e.tree(),
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/andrewarchi/browser/bookmark"
+)
+
+const testBookmarkBackup = `{"guid":"root________","title":"","index":0,"dateAdded":1613659445000000,"lastModified":1613659445000000,"id":1,"typeCode":2,"type":"text/x-moz-place-container","root":"placesRoot","children":[
+{"guid":"toolbar_____","title":"toolbar","index":1,"dateAdded":1613659445000000,"lastModified":1613659445000000,"id":3,"typeCode":2,"type":"text/x-moz-place-container","root":"toolbarFolder","children":[
+{"guid":"ssssssssssss","title":"","index":1,"dateAdded":1613659445000000,"lastModified":1613659445000000,"id":6,"typeCode":3,"type":"text/x-moz-place-separator"},
+{"guid":"aaaaaaaaaaaa","title":"A","index":0,"dateAdded":1613659445000000,"lastModified":1613659445000000,"id":5,"typeCode":1,"type":"text/x-moz-place","uri":"https://a.example/","tags":"x,y","keyword":"a","charset":"UTF-8",
+"annos":[{"name":"bookmarkProperties/description","flags":0,"expires":4,"value":"Site A"}]}]},
+{"guid":"menu________","title":"menu","index":0,"dateAdded":1613659445000000,"lastModified":1613659445000000,"id":2,"typeCode":2,"type":"text/x-moz-place-container","root":"bookmarksMenuFolder"}]}`
+
+func TestBookmarkBackups(t *testing.T) {
+	profile := t.TempDir()
+	dir := filepath.Join(profile, "bookmarkbackups")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"bookmarks-2021-02-18_3_AAAAAAAAAAAAAAAAAAAAAA==.json",
+		"bookmarks-2021-02-17_2.json",
+		"bookmarks-2021-02-19.json.tmp",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(testBookmarkBackup), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := ListBookmarkBackups(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 || backups[0].Count != 2 || backups[1].Count != 3 {
+		t.Fatalf("got backups %+v", backups)
+	}
+	backup, err := NewestBookmarkBackup(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup.Filename != backups[1].Filename || len(backup.Hash) != 16 {
+		t.Errorf("got newest backup %+v", backup)
+	}
+
+	tree := backup.Tree()
+	if len(tree) != 2 {
+		t.Fatalf("got %d roots, want 2", len(tree))
+	}
+	menu := tree[0].(*bookmark.BookmarkFolder)
+	toolbar := tree[1].(*bookmark.BookmarkFolder)
+	if menu.GUID != PlacesMenuGUID || toolbar.GUID != PlacesToolbarGUID || len(toolbar.Entries) != 2 {
+		t.Fatalf("got roots %+v, %+v", menu, toolbar)
+	}
+	a, ok := toolbar.Entries[0].(*bookmark.Bookmark)
+	if !ok {
+		t.Fatalf("got toolbar entry %T, want bookmark", toolbar.Entries[0])
+	}
+	want := &bookmark.Bookmark{
+		Title:        "A",
+		URL:          "https://a.example/",
+		GUID:         "aaaaaaaaaaaa",
+		AddDate:      a.AddDate,
+		LastModified: a.LastModified,
+		Tags:         []string{"x", "y"},
+		Keyword:      "a",
+		Attrs: []bookmark.Attr{
+			{Key: "last_charset", Val: "UTF-8"},
+			{Key: "bookmarkProperties/description", Val: "Site A"},
+		},
+	}
+	if !reflect.DeepEqual(a, want) {
+		t.Errorf("got bookmark:\n%+v\nwant:\n%+v", a, want)
+	}
+	if a.AddDate.Unix() != 1613659445 {
+		t.Errorf("got date added %v", a.AddDate)
+	}
+	if s, ok := toolbar.Entries[1].(*bookmark.BookmarkSeparator); !ok || s.GUID != "ssssssssssss" {
+		t.Errorf("got toolbar entry %+v, want separator", toolbar.Entries[1])
+	}
+}
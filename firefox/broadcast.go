@@ -0,0 +1,40 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import "github.com/andrewarchi/browser/jsonutil"
+
+// BroadcastListeners contains the push broadcast subscriptions in
+// broadcast-listeners.json, which are used by Remote Settings to learn
+// of changes.
+// https://searchfox.org/mozilla-central/source/dom/push/PushBroadcastService.jsm
+type BroadcastListeners struct {
+	Version   int                          `json:"version"`   // e.g. 1
+	Listeners map[string]BroadcastListener `json:"listeners"` // key: broadcast ID, e.g. "remote-settings/monitor_changes"
+}
+
+// BroadcastListener is a subscription to a broadcast.
+type BroadcastListener struct {
+	Version    string              `json:"version"` // last seen version, e.g. "\"1612345678901\""
+	SourceInfo BroadcastSourceInfo `json:"sourceInfo"`
+}
+
+// BroadcastSourceInfo identifies the handler of a broadcast.
+type BroadcastSourceInfo struct {
+	ModuleURI  string `json:"moduleURI"`  // e.g. "resource://services-settings/remote-settings.js"
+	SymbolName string `json:"symbolName"` // e.g. "remoteSettingsBroadcastHandler"
+}
+
+// ParseBroadcastListeners parses broadcast-listeners.json in a Firefox
+// profile.
+func ParseBroadcastListeners(filename string) (*BroadcastListeners, error) {
+	var listeners BroadcastListeners
+	if err := jsonutil.DecodeFile(filename, &listeners); err != nil {
+		return nil, err
+	}
+	return &listeners, nil
+}
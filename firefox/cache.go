@@ -0,0 +1,378 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser"
+)
+
+// Cache format:
+// https://searchfox.org/mozilla-central/source/netwerk/cache2/CacheIndex.h
+// https://searchfox.org/mozilla-central/source/netwerk/cache2/CacheFileMetadata.h
+// https://searchfox.org/mozilla-central/source/netwerk/cache2/CacheFileUtils.cpp
+//
+// The HTTP cache is in the cache2 directory of the local profile
+// directory, which is separate from the profile on Windows and macOS
+// (e.g. ~/.cache/mozilla/firefox/{profile} on Linux). Each entry is a
+// file in cache2/entries, named by the uppercase hexadecimal SHA-1
+// hash of its key. The file has the cached data, then the metadata,
+// then the 32-bit offset of the metadata. cache2/index has a record
+// for each entry with its frecency, flags, and size, so that the cache
+// can be managed without opening the entries. All integers are big
+// endian.
+//
+// The key of an entry is a list of tags, each a letter with an
+// optional value and terminated by a comma, then a colon and the URL.
+
+// CacheIndex is the index of the cache entries in cache2/index.
+type CacheIndex struct {
+	Version   uint32
+	Timestamp time.Time // last written
+	Dirty     bool      // not written cleanly on shutdown
+	KBWritten uint32    // since the index was last built
+	Records   []CacheIndexRecord
+}
+
+// CacheIndexRecord is the record of an entry in the cache index.
+type CacheIndexRecord struct {
+	Hash            string // SHA-1 hash of the key, in uppercase hexadecimal
+	Frecency        uint32
+	OriginAttrsHash uint64
+	OnStartTime     uint16 // milliseconds from the request to the response start
+	OnStopTime      uint16 // milliseconds from the request to the response end
+	ContentType     CacheContentType
+	Flags           uint32
+}
+
+// CacheContentType is the type of the content of a cache entry.
+type CacheContentType uint8
+
+// Values for CacheContentType:
+const (
+	CacheContentUnknown CacheContentType = iota
+	CacheContentOther
+	CacheContentJavaScript
+	CacheContentImage
+	CacheContentMedia
+	CacheContentStylesheet
+	CacheContentWasm
+)
+
+// Masks of CacheIndexRecord.Flags:
+const (
+	CacheInitialized uint32 = 0x80000000
+	CacheAnonymous   uint32 = 0x40000000
+	CacheRemoved     uint32 = 0x20000000
+	CacheDirty       uint32 = 0x10000000
+	CacheFresh       uint32 = 0x08000000
+	CachePinned      uint32 = 0x04000000
+	CacheHasAltData  uint32 = 0x02000000
+	CacheFileSize    uint32 = 0x00ffffff // size of the entry file in KiB
+)
+
+// CacheEntry is the metadata of a cache entry in cache2/entries.
+type CacheEntry struct {
+	Hash             string // file name
+	Key              string // e.g. "a,:https://example.com/"
+	URL              string
+	OriginAttributes *OriginAttributes
+	Anonymous        bool // loaded without credentials
+	IDEnhance        string
+	FetchCount       uint32
+	LastFetched      time.Time
+	LastModified     time.Time
+	Expiration       time.Time // zero when there is no expiration
+	Frecency         uint32
+	Flags            uint32
+	Elements         map[string]string // e.g. "request-method" and "response-head"
+	DataSize         int64             // size of the cached data, which is not read
+	Index            *CacheIndexRecord // record in the index, if any
+}
+
+const (
+	cacheIndexVersion    = 0xa
+	cacheIndexHeaderSize = 16
+	cacheIndexRecordSize = 41
+	cacheChunkSize       = 256 * 1024
+	cacheMetadataVersion = 3
+	cacheNoExpiration    = 0xffffffff
+)
+
+// ParseCacheIndex parses cache2/index in a local profile directory.
+func ParseCacheIndex(filename string) (*CacheIndex, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < cacheIndexHeaderSize+4 {
+		return nil, fmt.Errorf("firefox: cache index: too short")
+	}
+	be := binary.BigEndian
+	idx := &CacheIndex{
+		Version:   be.Uint32(data),
+		Timestamp: time.Unix(int64(be.Uint32(data[4:])), 0).UTC(),
+		Dirty:     be.Uint32(data[8:]) != 0,
+		KBWritten: be.Uint32(data[12:]),
+	}
+	if idx.Version != cacheIndexVersion {
+		return nil, fmt.Errorf("firefox: cache index: unsupported version %d", idx.Version)
+	}
+	records := data[cacheIndexHeaderSize : len(data)-4] // trailing hash
+	if len(records)%cacheIndexRecordSize != 0 {
+		return nil, fmt.Errorf("firefox: cache index: records length %d not a multiple of %d", len(records), cacheIndexRecordSize)
+	}
+	idx.Records = make([]CacheIndexRecord, 0, len(records)/cacheIndexRecordSize)
+	for b := records; len(b) != 0; b = b[cacheIndexRecordSize:] {
+		idx.Records = append(idx.Records, CacheIndexRecord{
+			Hash:            strings.ToUpper(hex.EncodeToString(b[:20])),
+			Frecency:        be.Uint32(b[20:]),
+			OriginAttrsHash: be.Uint64(b[24:]),
+			OnStartTime:     be.Uint16(b[32:]),
+			OnStopTime:      be.Uint16(b[34:]),
+			ContentType:     CacheContentType(b[36]),
+			Flags:           be.Uint32(b[37:]),
+		})
+	}
+	return idx, nil
+}
+
+// FileSize returns the size of the entry file in bytes, rounded up to
+// a KiB.
+func (r *CacheIndexRecord) FileSize() int64 {
+	return int64(r.Flags&CacheFileSize) * 1024
+}
+
+// ParseCacheEntry parses the metadata of an entry file in
+// cache2/entries. The cached data is skipped.
+func ParseCacheEntry(filename string) (*CacheEntry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	e, err := readCacheEntry(f, fi.Size())
+	if err != nil {
+		return nil, fmt.Errorf("firefox: cache entry %s: %w", filepath.Base(filename), err)
+	}
+	e.Hash = filepath.Base(filename)
+	return e, nil
+}
+
+func readCacheEntry(r io.ReaderAt, size int64) (*CacheEntry, error) {
+	be := binary.BigEndian
+	var buf [4]byte
+	if size < 4 {
+		return nil, errors.New("too short")
+	}
+	if _, err := r.ReadAt(buf[:], size-4); err != nil {
+		return nil, err
+	}
+	offset := int64(be.Uint32(buf[:]))
+	if offset > size-4 {
+		return nil, fmt.Errorf("metadata offset %d exceeds file", offset)
+	}
+	meta := make([]byte, size-4-offset)
+	if _, err := r.ReadAt(meta, offset); err != nil {
+		return nil, err
+	}
+
+	// The metadata starts with its hash and the hash of each chunk of
+	// data.
+	chunks := (offset + cacheChunkSize - 1) / cacheChunkSize
+	headerStart := 4 + 2*chunks
+	if int64(len(meta)) < headerStart+28 {
+		return nil, errors.New("metadata too short")
+	}
+	h := meta[headerStart:]
+	version := be.Uint32(h)
+	if version == 0 || version > cacheMetadataVersion {
+		return nil, fmt.Errorf("unsupported metadata version %d", version)
+	}
+	e := &CacheEntry{
+		FetchCount:   be.Uint32(h[4:]),
+		LastFetched:  time.Unix(int64(be.Uint32(h[8:])), 0).UTC(),
+		LastModified: time.Unix(int64(be.Uint32(h[12:])), 0).UTC(),
+		Frecency:     be.Uint32(h[16:]),
+		DataSize:     offset,
+	}
+	if exp := be.Uint32(h[20:]); exp != cacheNoExpiration {
+		e.Expiration = time.Unix(int64(exp), 0).UTC()
+	}
+	keySize := int(be.Uint32(h[24:]))
+	rest := h[28:]
+	// Flags were added in version 2.
+	if version >= 2 {
+		if len(rest) < 4 {
+			return nil, errors.New("metadata too short")
+		}
+		e.Flags = be.Uint32(rest)
+		rest = rest[4:]
+	}
+	if len(rest) < keySize+1 || rest[keySize] != 0 {
+		return nil, errors.New("key exceeds metadata")
+	}
+	e.Key = string(rest[:keySize])
+	if err := e.parseKey(); err != nil {
+		return nil, err
+	}
+
+	elems := rest[keySize+1:]
+	if len(elems) != 0 {
+		if elems[len(elems)-1] != 0 {
+			return nil, errors.New("elements not terminated")
+		}
+		parts := bytes.Split(elems[:len(elems)-1], []byte{0})
+		if len(parts)%2 != 0 {
+			return nil, errors.New("element without value")
+		}
+		e.Elements = make(map[string]string, len(parts)/2)
+		for i := 0; i < len(parts); i += 2 {
+			e.Elements[string(parts[i])] = string(parts[i+1])
+		}
+	}
+	return e, nil
+}
+
+// parseKey parses the tags and URL of the entry key. Commas in tag
+// values are escaped by doubling.
+func (e *CacheEntry) parseKey() error {
+	key := e.Key
+	for len(key) != 0 {
+		tag := key[0]
+		key = key[1:]
+		if tag == ':' {
+			e.URL = key
+			return nil
+		}
+		var value strings.Builder
+		for {
+			i := strings.IndexByte(key, ',')
+			if i == -1 {
+				return fmt.Errorf("key tag %q not terminated", tag)
+			}
+			value.WriteString(key[:i])
+			key = key[i+1:]
+			if !strings.HasPrefix(key, ",") {
+				break
+			}
+			value.WriteByte(',')
+			key = key[1:]
+		}
+		switch tag {
+		case 'O':
+			attrs, err := ParseOriginAttributes(value.String())
+			if err != nil {
+				return err
+			}
+			e.OriginAttributes = attrs
+		case 'a':
+			e.Anonymous = true
+		case '~':
+			e.IDEnhance = value.String()
+		}
+	}
+	return errors.New("key has no URL")
+}
+
+// ParseCache reads the entries in a cache2 directory and joins them
+// with their records in the index, if it exists. Entries are ordered
+// by last fetch time, most recent first, then by hash. Files that fail
+// to parse are handled as by ParseCacheOptions with the zero Options.
+func ParseCache(cacheDir string) ([]CacheEntry, error) {
+	return ParseCacheOptions(cacheDir, browser.Options{})
+}
+
+// ParseCacheOptions reads the entries in a cache2 directory and handles
+// files that fail to parse by the error policy in opts. When the index
+// is skipped, entries are not joined with records.
+func ParseCacheOptions(cacheDir string, opts browser.Options) ([]CacheEntry, error) {
+	h := opts.Handler()
+	records := make(map[string]*CacheIndexRecord)
+	filename := filepath.Join(cacheDir, "index")
+	idx, err := ParseCacheIndex(filename)
+	if err == nil {
+		for i := range idx.Records {
+			records[idx.Records[i].Hash] = &idx.Records[i]
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		if err := h.File(filename, err); err != nil {
+			return nil, err
+		}
+	}
+
+	files, err := os.ReadDir(filepath.Join(cacheDir, "entries"))
+	if err != nil {
+		return nil, err
+	}
+	var entries []CacheEntry
+	for _, fi := range files {
+		if fi.IsDir() {
+			continue
+		}
+		filename := filepath.Join(cacheDir, "entries", fi.Name())
+		e, err := ParseCacheEntry(filename)
+		if err != nil {
+			if err := h.File(filename, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		e.Index = records[e.Hash]
+		entries = append(entries, *e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].LastFetched.Equal(entries[j].LastFetched) {
+			return entries[i].LastFetched.After(entries[j].LastFetched)
+		}
+		return entries[i].Hash < entries[j].Hash
+	})
+	return entries, h.Err()
+}
+
+// CacheKeyHash returns the name of the entry file of a cache key.
+func CacheKeyHash(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func (t CacheContentType) String() string {
+	switch t {
+	case CacheContentUnknown:
+		return "unknown"
+	case CacheContentOther:
+		return "other"
+	case CacheContentJavaScript:
+		return "javascript"
+	case CacheContentImage:
+		return "image"
+	case CacheContentMedia:
+		return "media"
+	case CacheContentStylesheet:
+		return "stylesheet"
+	case CacheContentWasm:
+		return "wasm"
+	default:
+		return fmt.Sprintf("content(%d)", uint8(t))
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCache(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "entries"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		key1 = "a,:https://example.com/script.js"
+		key2 = "O^userContextId=2,~1620000000,:https://example.org/"
+	)
+	hash1, hash2 := CacheKeyHash(key1), CacheKeyHash(key2)
+	writeCacheEntry(t, filepath.Join(dir, "entries", hash1), make([]byte, 300*1024), 3,
+		[8]uint32{3, 4, 1620000100, 1620000000, 50, 0xffffffff, 0, 1},
+		key1, "request-method", "GET", "response-head", "HTTP/1.1 200 OK\r\n")
+	writeCacheEntry(t, filepath.Join(dir, "entries", hash2), []byte("<html>"), 1,
+		[8]uint32{1, 1, 1620000200, 1620000200, 10, 1620086600, 0, 0},
+		key2)
+
+	var index bytes.Buffer
+	binary.Write(&index, binary.BigEndian, [4]uint32{0xa, 1620000300, 0, 12})
+	sum, _ := hex.DecodeString(hash1)
+	index.Write(sum)
+	binary.Write(&index, binary.BigEndian, struct {
+		Frecency        uint32
+		OriginAttrsHash uint64
+		OnStart, OnStop uint16
+		ContentType     uint8
+		Flags           uint32
+	}{50, 0, 12, 34, uint8(CacheContentJavaScript), CacheInitialized | CacheAnonymous | 301})
+	binary.Write(&index, binary.BigEndian, uint32(0))
+	if err := os.WriteFile(filepath.Join(dir, "index"), index.Bytes(), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ParseCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := &CacheIndexRecord{
+		Hash:        hash1,
+		Frecency:    50,
+		OnStartTime: 12,
+		OnStopTime:  34,
+		ContentType: CacheContentJavaScript,
+		Flags:       CacheInitialized | CacheAnonymous | 301,
+	}
+	want := []CacheEntry{{
+		Hash:             hash2,
+		Key:              key2,
+		URL:              "https://example.org/",
+		OriginAttributes: &OriginAttributes{UserContextID: 2},
+		IDEnhance:        "1620000000",
+		FetchCount:       1,
+		LastFetched:      time.Unix(1620000200, 0).UTC(),
+		LastModified:     time.Unix(1620000200, 0).UTC(),
+		Expiration:       time.Unix(1620086600, 0).UTC(),
+		Frecency:         10,
+		DataSize:         6,
+	}, {
+		Hash:         hash1,
+		Key:          key1,
+		URL:          "https://example.com/script.js",
+		Anonymous:    true,
+		FetchCount:   4,
+		LastFetched:  time.Unix(1620000100, 0).UTC(),
+		LastModified: time.Unix(1620000000, 0).UTC(),
+		Frecency:     50,
+		Flags:        1,
+		Elements:     map[string]string{"request-method": "GET", "response-head": "HTTP/1.1 200 OK\r\n"},
+		DataSize:     300 * 1024,
+		Index:        record,
+	}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", entries, want)
+	}
+	if size := record.FileSize(); size != 301*1024 {
+		t.Errorf("got file size %d, want %d", size, 301*1024)
+	}
+}
+
+// writeCacheEntry writes a cache entry file with the given data and
+// metadata header, with the key size filled in.
+func writeCacheEntry(t *testing.T, filename string, data []byte, version uint32, header [8]uint32, key string, elements ...string) {
+	t.Helper()
+	var b bytes.Buffer
+	b.Write(data)
+	binary.Write(&b, binary.BigEndian, uint32(0)) // metadata hash
+	for i := 0; i < (len(data)+cacheChunkSize-1)/cacheChunkSize; i++ {
+		binary.Write(&b, binary.BigEndian, uint16(0)) // chunk hash
+	}
+	header[0] = version
+	header[6] = uint32(len(key))
+	if version < 2 {
+		binary.Write(&b, binary.BigEndian, header[:7])
+	} else {
+		binary.Write(&b, binary.BigEndian, header)
+	}
+	b.WriteString(key)
+	b.WriteByte(0)
+	for _, elem := range elements {
+		b.WriteString(elem)
+		b.WriteByte(0)
+	}
+	binary.Write(&b, binary.BigEndian, uint32(len(data)))
+	if err := os.WriteFile(filename, b.Bytes(), 0o666); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bufio"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// CertOverride is a certificate exception added by the user to accept
+// an invalid certificate for a host.
+// https://searchfox.org/mozilla-central/source/security/manager/ssl/nsCertOverrideService.cpp
+type CertOverride struct {
+	Host             string
+	Port             int
+	OriginAttributes *OriginAttributes
+	HashAlgorithm    string           // OID of the fingerprint hash, e.g. "OID.2.16.840.1.101.3.4.2.1" for SHA-256
+	Fingerprint      string           // colon-separated uppercase hex
+	Bits             CertOverrideBits // errors overridden; zero since Firefox 103
+	DBKey            *CertDBKey       // reference to the certificate; nil since Firefox 103
+}
+
+// CertOverrideBits are the certificate errors overridden by an
+// exception.
+type CertOverrideBits uint8
+
+// Values for CertOverrideBits:
+const (
+	CertOverrideUntrusted CertOverrideBits = 1 << iota // "U": untrusted issuer
+	CertOverrideMismatch                               // "M": domain mismatch
+	CertOverrideTime                                   // "T": expired or not yet valid
+)
+
+// CertDBKey is a serialized reference to a certificate in the NSS
+// certificate database by its issuer and serial number.
+type CertDBKey struct {
+	ModuleID uint32 // unused and zero
+	SlotID   uint32 // unused and zero
+	Serial   []byte
+	Issuer   []byte // DER-encoded distinguished name
+}
+
+// ParseCertOverrides parses cert_override.txt in a Firefox profile.
+// Lines starting with "#" are comments and each other line is
+// "{host}:{port}[:{origin attributes}]\t{hash OID}\t{fingerprint}\t{bits}\t{db key}",
+// where bits and db key are empty or omitted in newer versions.
+func ParseCertOverrides(filename string) ([]CertOverride, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var overrides []CertOverride
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		text := s.Text()
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		o, err := parseCertOverride(text)
+		if err != nil {
+			return nil, fmt.Errorf("firefox: cert override: line %d: %w", line, err)
+		}
+		overrides = append(overrides, *o)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return overrides, nil
+}
+
+func parseCertOverride(line string) (*CertOverride, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) < 3 || len(fields) > 5 {
+		return nil, fmt.Errorf("%d fields", len(fields))
+	}
+	hostPort, suffix := fields[0], ""
+	if i := strings.LastIndexByte(hostPort, ':'); i != -1 && (i == len(hostPort)-1 || hostPort[i+1] == '^') {
+		hostPort, suffix = hostPort[:i], hostPort[i+1:]
+	}
+	host, portStr, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("port: %w", err)
+	}
+	attrs, err := ParseOriginAttributes(suffix)
+	if err != nil {
+		return nil, err
+	}
+	o := &CertOverride{
+		Host:             host,
+		Port:             int(port),
+		OriginAttributes: attrs,
+		HashAlgorithm:    fields[1],
+		Fingerprint:      fields[2],
+	}
+	if len(fields) > 3 {
+		for _, c := range fields[3] {
+			switch c {
+			case 'U':
+				o.Bits |= CertOverrideUntrusted
+			case 'M':
+				o.Bits |= CertOverrideMismatch
+			case 'T':
+				o.Bits |= CertOverrideTime
+			default:
+				return nil, fmt.Errorf("unknown override bit %q", c)
+			}
+		}
+	}
+	if len(fields) > 4 && fields[4] != "" {
+		key, err := parseCertDBKey(fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("db key: %w", err)
+		}
+		o.DBKey = key
+	}
+	return o, nil
+}
+
+// parseCertDBKey decodes a base64 db key, which is the big-endian
+// module ID, slot ID, serial length, and issuer length, each 32 bits,
+// followed by the serial and issuer.
+func parseCertDBKey(s string) (*CertDBKey, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 16 {
+		return nil, fmt.Errorf("%d bytes", len(b))
+	}
+	key := &CertDBKey{
+		ModuleID: binary.BigEndian.Uint32(b),
+		SlotID:   binary.BigEndian.Uint32(b[4:]),
+	}
+	serialLen := uint64(binary.BigEndian.Uint32(b[8:]))
+	issuerLen := uint64(binary.BigEndian.Uint32(b[12:]))
+	if uint64(len(b)-16) != serialLen+issuerLen {
+		return nil, fmt.Errorf("%d bytes for serial of %d bytes and issuer of %d bytes", len(b)-16, serialLen, issuerLen)
+	}
+	key.Serial = b[16 : 16+serialLen]
+	key.Issuer = b[16+serialLen:]
+	return key, nil
+}
+
+// IssuerName decodes the issuer distinguished name.
+func (key *CertDBKey) IssuerName() (*pkix.Name, error) {
+	var rdns pkix.RDNSequence
+	rest, err := asn1.Unmarshal(key.Issuer, &rdns)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("firefox: cert db key: %d bytes after issuer", len(rest))
+	}
+	var name pkix.Name
+	name.FillFromRDNSequence(&rdns)
+	return &name, nil
+}
+
+func (bits CertOverrideBits) String() string {
+	var b strings.Builder
+	if bits&CertOverrideMismatch != 0 {
+		b.WriteByte('M')
+	}
+	if bits&CertOverrideUntrusted != 0 {
+		b.WriteByte('U')
+	}
+	if bits&CertOverrideTime != 0 {
+		b.WriteByte('T')
+	}
+	return b.String()
+}
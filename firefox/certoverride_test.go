@@ -0,0 +1,66 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseCertOverrides(t *testing.T) {
+	issuer, err := asn1.Marshal(pkix.Name{CommonName: "Example CA", Organization: []string{"Example"}}.ToRDNSequence())
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial := []byte{0x01, 0x02, 0x03}
+	key := make([]byte, 16, 16+len(serial)+len(issuer))
+	binary.BigEndian.PutUint32(key[8:], uint32(len(serial)))
+	binary.BigEndian.PutUint32(key[12:], uint32(len(issuer)))
+	key = append(append(key, serial...), issuer...)
+
+	const sha256 = "OID.2.16.840.1.101.3.4.2.1"
+	const fp = "AB:CD:EF:01"
+	data := "# PSM Certificate Override Settings file\n" +
+		"# This is a generated file!  Do not edit.\n" +
+		"self-signed.example:443\t" + sha256 + "\t" + fp + "\tMUT\t" + base64.StdEncoding.EncodeToString(key) + "\n" +
+		"[::1]:8443:^privateBrowsingId=1\t" + sha256 + "\t" + fp + "\tU\t\n" +
+		"localhost:8080:\t" + sha256 + "\t" + fp + "\n"
+	filename := filepath.Join(t.TempDir(), "cert_override.txt")
+	if err := os.WriteFile(filename, []byte(data), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	overrides, err := ParseCertOverrides(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CertOverride{
+		{"self-signed.example", 443, &OriginAttributes{}, sha256, fp,
+			CertOverrideMismatch | CertOverrideUntrusted | CertOverrideTime,
+			&CertDBKey{Serial: serial, Issuer: issuer}},
+		{"::1", 8443, &OriginAttributes{PrivateBrowsingID: 1}, sha256, fp, CertOverrideUntrusted, nil},
+		{"localhost", 8080, &OriginAttributes{}, sha256, fp, 0, nil},
+	}
+	if !reflect.DeepEqual(overrides, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", overrides, want)
+	}
+	if bits := overrides[0].Bits.String(); bits != "MUT" {
+		t.Errorf("got bits %q, want MUT", bits)
+	}
+	name, err := overrides[0].DBKey.IssuerName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := name.String(); s != "CN=Example CA,O=Example" {
+		t.Errorf("got issuer %q", s)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/andrewarchi/browser/iniutil"
+	"gopkg.in/ini.v1"
+)
+
+// compatibility.ini format:
+// https://searchfox.org/mozilla-central/source/toolkit/xre/nsAppRunner.cpp
+// (WriteVersion, CheckCompatibility)
+//
+// Firefox writes compatibility.ini at startup when the version differs,
+// so it records the last version to run the profile and its install
+// location:
+//
+//	[Compatibility]
+//	LastVersion=85.0_20210118153634/20210118153634
+//	LastOSABI=Linux_x86_64-gcc3
+//	LastPlatformDir=/usr/lib/firefox
+//	LastAppDir=/usr/lib/firefox/browser
+
+// Compatibility contains the last version of Firefox to use a profile,
+// in compatibility.ini.
+type Compatibility struct {
+	LastVersion      string // e.g. "85.0_20210118153634/20210118153634"
+	LastOSABI        string // e.g. "Linux_x86_64-gcc3", "WINNT_x86_64-msvc", or "Darwin_aarch64-gcc3"
+	LastPlatformDir  string // directory of the Gecko platform, e.g. "/usr/lib/firefox"
+	LastAppDir       string // directory of the application, e.g. "/usr/lib/firefox/browser"
+	InvalidateCaches bool   // startup caches are cleared at the next start
+}
+
+// ParseCompatibility parses compatibility.ini in a Firefox profile.
+func ParseCompatibility(filename string) (*Compatibility, error) {
+	f, err := ini.Load(filename)
+	if err != nil {
+		return nil, err
+	}
+	var c Compatibility
+	for _, section := range f.Sections() {
+		switch name := section.Name(); name {
+		case "DEFAULT":
+			if len(section.KeyStrings()) != 0 {
+				return nil, errors.New("firefox: root section has bare keys")
+			}
+		case "Compatibility":
+			if err := iniutil.Decode(section, &c); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("firefox: unknown section: %q", name)
+		}
+	}
+	return &c, nil
+}
+
+// Version splits LastVersion into the application version and the
+// build IDs of the application and platform, which are timestamps,
+// e.g. "85.0", "20210118153634", and "20210118153634". When
+// LastVersion is not in that form, such as "Safe Mode", it is returned
+// as the version.
+func (c *Compatibility) Version() (version, appBuildID, platformBuildID string) {
+	i := strings.LastIndexByte(c.LastVersion, '_')
+	if i == -1 {
+		return c.LastVersion, "", ""
+	}
+	version, build := c.LastVersion[:i], c.LastVersion[i+1:]
+	appBuildID, platformBuildID = build, ""
+	if j := strings.IndexByte(build, '/'); j != -1 {
+		appBuildID, platformBuildID = build[:j], build[j+1:]
+	}
+	return version, appBuildID, platformBuildID
+}
@@ -6,7 +6,23 @@
 
 package firefox
 
-import "github.com/andrewarchi/browser/jsonutil"
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+// Contextual identities format:
+// https://searchfox.org/mozilla-central/source/toolkit/components/contextualidentity/ContextualIdentityService.jsm
+// https://searchfox.org/mozilla-central/source/caps/OriginAttributes.cpp
+//
+// Containers, added by the Multi-Account Containers extension or the
+// privacy.userContext.enabled pref, are identified by userContextId,
+// which appears in the origin attributes of cookies, storage, tabs, and
+// downloads.
 
 // Containers contains containers contained in containers.json.
 type Containers struct {
@@ -17,14 +33,65 @@ type Containers struct {
 
 // ContainerIdentity is a container definition.
 type ContainerIdentity struct {
-	UserContextID int64  `json:"userContextId"`
-	Public        bool   `json:"public"`
-	Icon          string `json:"icon"`  // e.g. "circle"
-	Color         string `json:"color"` // e.g. "blue"
-	L10nID        string `json:"l10nID,omitempty"`
-	AccessKey     string `json:"accessKey,omitempty"`
-	TelemetryID   int64  `json:"telemetryId,omitempty"`
-	Name          string `json:"name,omitempty"`
+	UserContextID int64          `json:"userContextId"`
+	Public        bool           `json:"public"` // false for internal containers
+	Icon          ContainerIcon  `json:"icon"`
+	Color         ContainerColor `json:"color"`
+	L10nID        string         `json:"l10nID,omitempty"` // name of a default container, e.g. "userContextPersonal.label"
+	AccessKey     string         `json:"accessKey,omitempty"`
+	TelemetryID   int64          `json:"telemetryId,omitempty"`
+	Name          string         `json:"name,omitempty"` // name of a user-defined container
+}
+
+// Values for UserContextID:
+const (
+	NoUserContextID            int64 = 0          // not in a container
+	ThumbnailUserContextID     int64 = 4294967295 // internal; used for page thumbnails
+	WebExtStorageUserContextID int64 = 4294967294 // internal; used for extension storage.local
+)
+
+// ContainerIcon is the icon of a container.
+type ContainerIcon string
+
+// Values for ContainerIcon:
+const (
+	IconFingerprint ContainerIcon = "fingerprint"
+	IconBriefcase   ContainerIcon = "briefcase"
+	IconDollar      ContainerIcon = "dollar"
+	IconCart        ContainerIcon = "cart"
+	IconCircle      ContainerIcon = "circle"
+	IconGift        ContainerIcon = "gift"
+	IconVacation    ContainerIcon = "vacation"
+	IconFood        ContainerIcon = "food"
+	IconFruit       ContainerIcon = "fruit"
+	IconPet         ContainerIcon = "pet"
+	IconTree        ContainerIcon = "tree"
+	IconChill       ContainerIcon = "chill"
+	IconFence       ContainerIcon = "fence"
+)
+
+// ContainerColor is the color of a container.
+type ContainerColor string
+
+// Values for ContainerColor:
+const (
+	ColorBlue      ContainerColor = "blue"
+	ColorTurquoise ContainerColor = "turquoise"
+	ColorGreen     ContainerColor = "green"
+	ColorYellow    ContainerColor = "yellow"
+	ColorOrange    ContainerColor = "orange"
+	ColorRed       ContainerColor = "red"
+	ColorPink      ContainerColor = "pink"
+	ColorPurple    ContainerColor = "purple"
+	ColorToolbar   ContainerColor = "toolbar"
+)
+
+// containerL10nNames are the en-US names of the default containers.
+var containerL10nNames = map[string]string{
+	"userContextPersonal.label": "Personal",
+	"userContextWork.label":     "Work",
+	"userContextBanking.label":  "Banking",
+	"userContextShopping.label": "Shopping",
 }
 
 // ParseContainers parses containers.json in a Firefox profile.
@@ -35,3 +102,120 @@ func ParseContainers(filename string) (*Containers, error) {
 	}
 	return &containers, nil
 }
+
+// Identity returns the container with the given user context ID, or
+// nil if there is none.
+func (c *Containers) Identity(userContextID int64) *ContainerIdentity {
+	for i := range c.Identities {
+		if c.Identities[i].UserContextID == userContextID {
+			return &c.Identities[i]
+		}
+	}
+	return nil
+}
+
+// Resolve returns the container in an origin attributes suffix, such
+// as "^userContextId=1", or nil if the suffix has no container or it
+// is not defined.
+func (c *Containers) Resolve(suffix string) (*ContainerIdentity, error) {
+	attrs, err := ParseOriginAttributes(suffix)
+	if err != nil {
+		return nil, err
+	}
+	if attrs.UserContextID == NoUserContextID {
+		return nil, nil
+	}
+	return c.Identity(attrs.UserContextID), nil
+}
+
+// DisplayName returns the name of the container as shown in the UI.
+// Default containers are named by their localization ID, in en-US.
+func (id *ContainerIdentity) DisplayName() string {
+	if id.Name != "" {
+		return id.Name
+	}
+	if name, ok := containerL10nNames[id.L10nID]; ok {
+		return name
+	}
+	return id.L10nID
+}
+
+// OriginAttributes are the attributes that isolate an origin, such as
+// the container or private browsing session.
+type OriginAttributes struct {
+	UserContextID             int64
+	PrivateBrowsingID         int64
+	FirstPartyDomain          string
+	GeckoViewSessionContextID string
+	PartitionKey              string // e.g. "(https,example.com)"
+}
+
+// ParseOriginAttributes parses an origin attributes suffix, such as
+// "^userContextId=1&privateBrowsingId=1". An empty suffix has the
+// default attributes.
+func ParseOriginAttributes(suffix string) (*OriginAttributes, error) {
+	var attrs OriginAttributes
+	if suffix == "" {
+		return &attrs, nil
+	}
+	if suffix[0] != '^' {
+		return nil, fmt.Errorf("firefox: origin attributes not prefixed with ^: %q", suffix)
+	}
+	for _, param := range strings.Split(suffix[1:], "&") {
+		eq := strings.IndexByte(param, '=')
+		if eq == -1 {
+			return nil, fmt.Errorf("firefox: origin attribute without value: %q", param)
+		}
+		key := param[:eq]
+		value, err := url.QueryUnescape(param[eq+1:])
+		if err != nil {
+			return nil, fmt.Errorf("firefox: origin attribute %s: %w", key, err)
+		}
+		switch key {
+		case "userContextId", "privateBrowsingId":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("firefox: origin attribute %s: %w", key, err)
+			}
+			if key == "userContextId" {
+				attrs.UserContextID = n
+			} else {
+				attrs.PrivateBrowsingID = n
+			}
+		case "firstPartyDomain":
+			attrs.FirstPartyDomain = value
+		case "geckoViewUserContextId":
+			attrs.GeckoViewSessionContextID = value
+		case "partitionKey":
+			attrs.PartitionKey = value
+		default:
+			return nil, fmt.Errorf("firefox: unknown origin attribute: %q", key)
+		}
+	}
+	return &attrs, nil
+}
+
+// String formats the attributes as a suffix, in the order Firefox
+// writes them.
+func (attrs *OriginAttributes) String() string {
+	var params []string
+	if attrs.UserContextID != 0 {
+		params = append(params, "userContextId="+strconv.FormatInt(attrs.UserContextID, 10))
+	}
+	if attrs.PrivateBrowsingID != 0 {
+		params = append(params, "privateBrowsingId="+strconv.FormatInt(attrs.PrivateBrowsingID, 10))
+	}
+	if attrs.FirstPartyDomain != "" {
+		params = append(params, "firstPartyDomain="+url.QueryEscape(attrs.FirstPartyDomain))
+	}
+	if attrs.GeckoViewSessionContextID != "" {
+		params = append(params, "geckoViewUserContextId="+url.QueryEscape(attrs.GeckoViewSessionContextID))
+	}
+	if attrs.PartitionKey != "" {
+		params = append(params, "partitionKey="+url.QueryEscape(attrs.PartitionKey))
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return "^" + strings.Join(params, "&")
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import "testing"
+
+func TestParseOriginAttributes(t *testing.T) {
+	for i, tt := range []struct {
+		Suffix string
+		Want   OriginAttributes
+	}{
+		{"", OriginAttributes{}},
+		{"^userContextId=5", OriginAttributes{UserContextID: 5}},
+		{"^userContextId=1&privateBrowsingId=1", OriginAttributes{UserContextID: 1, PrivateBrowsingID: 1}},
+		{"^firstPartyDomain=example.com", OriginAttributes{FirstPartyDomain: "example.com"}},
+		{"^partitionKey=%28https%2Cexample.com%29", OriginAttributes{PartitionKey: "(https,example.com)"}},
+	} {
+		got, err := ParseOriginAttributes(tt.Suffix)
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if *got != tt.Want {
+			t.Errorf("#%d: got: %+v, want: %+v", i, *got, tt.Want)
+		}
+		if s := got.String(); s != tt.Suffix {
+			t.Errorf("#%d: got suffix: %q, want: %q", i, s, tt.Suffix)
+		}
+	}
+	for _, suffix := range []string{"userContextId=1", "^userContextId", "^userContextId=x", "^unknown=1"} {
+		if _, err := ParseOriginAttributes(suffix); err == nil {
+			t.Errorf("%q: error not returned", suffix)
+		}
+	}
+}
+
+func TestContainersResolve(t *testing.T) {
+	c := &Containers{Identities: []ContainerIdentity{
+		{UserContextID: 1, Public: true, Icon: IconFingerprint, Color: ColorBlue, L10nID: "userContextPersonal.label"},
+		{UserContextID: 5, Public: true, Icon: IconBriefcase, Color: ColorRed, Name: "Clients"},
+	}}
+	for i, tt := range []struct {
+		Suffix string
+		Want   string
+	}{
+		{"", ""},
+		{"^userContextId=1", "Personal"},
+		{"^userContextId=5&firstPartyDomain=example.com", "Clients"},
+		{"^userContextId=9", ""},
+	} {
+		id, err := c.Resolve(tt.Suffix)
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		var got string
+		if id != nil {
+			got = id.DisplayName()
+		}
+		if got != tt.Want {
+			t.Errorf("#%d: got: %q, want: %q", i, got, tt.Want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Content preferences database schema:
+// https://searchfox.org/mozilla-central/source/toolkit/components/contentprefs/ContentPrefService2.jsm
+//
+// Each row of prefs has the value of a setting for a group, which is a
+// site, or for no group, which is global. Sites are named by host, e.g.
+// "example.com", or by scheme for local files, "file:///". Timestamps
+// are in seconds, with a fractional part, since 1970, and zero for
+// prefs set before Firefox 20.
+
+// ContentPrefsFile is the database of per-site settings in a profile.
+const ContentPrefsFile = "content-prefs.sqlite"
+
+// Settings in content-prefs.sqlite:
+const (
+	ContentPrefZoom         = "browser.content.full-zoom" // page zoom factor, e.g. 1.1
+	ContentPrefDownloadDir  = "browser.download.lastDir"  // last directory a file was downloaded to
+	ContentPrefUploadDir    = "browser.upload.lastDir"    // last directory a file was uploaded from
+	ContentPrefSpellcheck   = "spellcheck.lang"           // spell check dictionary, e.g. "en-US"
+	ContentPrefCookieBanner = "cookiebanner"              // cookie banner handling mode, a CookieBannerMode
+)
+
+// CookieBannerMode is how cookie banners are handled, globally in the
+// cookiebanners.service.mode preference, or for a site in the
+// cookiebanner content pref.
+// https://searchfox.org/mozilla-central/source/toolkit/components/cookiebanners/nsICookieBannerService.idl
+type CookieBannerMode uint8
+
+// Values for CookieBannerMode:
+const (
+	CookieBannerDisabled       CookieBannerMode = 0
+	CookieBannerReject         CookieBannerMode = 1 // reject when possible
+	CookieBannerRejectOrAccept CookieBannerMode = 2 // reject, or accept when there is no reject option
+)
+
+// ContentPrefs contains the per-site and global settings in
+// content-prefs.sqlite in a Firefox profile.
+type ContentPrefs struct {
+	Global []ContentPref      // ordered by setting
+	Sites  []SiteContentPrefs // ordered by site
+}
+
+// SiteContentPrefs is the settings for a site.
+type SiteContentPrefs struct {
+	Site            string        // e.g. "example.com" or "file:///"
+	Zoom            float64       // zero when unset
+	LastDownloadDir string        // empty when unset
+	Prefs           []ContentPref // every setting, including those above, ordered by setting
+}
+
+// ContentPref is the value of a setting.
+type ContentPref struct {
+	Setting  string      // e.g. "browser.content.full-zoom"
+	Value    interface{} // int64, float64, string, or nil
+	Modified time.Time   // zero for prefs set before Firefox 20
+}
+
+// ParseContentPrefs parses content-prefs.sqlite in a Firefox profile.
+func ParseContentPrefs(filename string) (*ContentPrefs, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var prefs ContentPrefs
+	err = sqliteutil.Query(db, `
+		SELECT g.name, s.name, p.value, p.timestamp
+		FROM prefs p
+		JOIN settings s ON p.settingID = s.id
+		LEFT JOIN groups g ON p.groupID = g.id
+		ORDER BY p.groupID IS NOT NULL, g.name, s.name, p.id`, func(rows *sql.Rows) error {
+		var group sql.NullString
+		var p ContentPref
+		var timestamp sql.NullFloat64
+		if err := rows.Scan(&group, &p.Setting, &p.Value, &timestamp); err != nil {
+			return err
+		}
+		if b, ok := p.Value.([]byte); ok {
+			p.Value = string(b)
+		}
+		if timestamp.Float64 != 0 {
+			sec, frac := math.Modf(timestamp.Float64)
+			p.Modified = time.Unix(int64(sec), int64(math.Round(frac*1e3))*1e6).UTC()
+		}
+		if !group.Valid {
+			prefs.Global = append(prefs.Global, p)
+			return nil
+		}
+		if n := len(prefs.Sites); n == 0 || prefs.Sites[n-1].Site != group.String {
+			prefs.Sites = append(prefs.Sites, SiteContentPrefs{Site: group.String})
+		}
+		site := &prefs.Sites[len(prefs.Sites)-1]
+		site.Prefs = append(site.Prefs, p)
+		switch p.Setting {
+		case ContentPrefZoom:
+			switch v := p.Value.(type) {
+			case float64:
+				site.Zoom = v
+			case int64:
+				site.Zoom = float64(v)
+			}
+		case ContentPrefDownloadDir:
+			site.LastDownloadDir, _ = p.Value.(string)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firefox: content prefs: %w", err)
+	}
+	return &prefs, nil
+}
+
+// CookieBannerModes returns the cookie banner handling modes of sites
+// that override the global mode, keyed by site.
+func (prefs *ContentPrefs) CookieBannerModes() map[string]CookieBannerMode {
+	modes := make(map[string]CookieBannerMode)
+	for _, s := range prefs.Sites {
+		for _, p := range s.Prefs {
+			if v, ok := p.Value.(int64); ok && p.Setting == ContentPrefCookieBanner {
+				modes[s.Site] = CookieBannerMode(v)
+			}
+		}
+	}
+	return modes
+}
+
+// SiteZoom returns the zoom factor of a site, or the global default
+// zoom when it is not set, or 1 when neither is set.
+func (prefs *ContentPrefs) SiteZoom(site string) float64 {
+	for _, s := range prefs.Sites {
+		if s.Site == site && s.Zoom != 0 {
+			return s.Zoom
+		}
+	}
+	for _, p := range prefs.Global {
+		if p.Setting != ContentPrefZoom {
+			continue
+		}
+		switch v := p.Value.(type) {
+		case float64:
+			return v
+		case int64:
+			return float64(v)
+		}
+	}
+	return 1
+}
+
+func (m CookieBannerMode) String() string {
+	switch m {
+	case CookieBannerDisabled:
+		return "disabled"
+	case CookieBannerReject:
+		return "reject"
+	case CookieBannerRejectOrAccept:
+		return "reject_or_accept"
+	default:
+		return fmt.Sprintf("mode(%d)", uint8(m))
+	}
+}
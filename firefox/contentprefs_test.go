@@ -0,0 +1,75 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseContentPrefs(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "content-prefs.sqlite")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE groups (id INTEGER PRIMARY KEY, name TEXT NOT NULL);
+		CREATE TABLE settings (id INTEGER PRIMARY KEY, name TEXT NOT NULL);
+		CREATE TABLE prefs (id INTEGER PRIMARY KEY, groupID INTEGER REFERENCES groups(id),
+			settingID INTEGER NOT NULL REFERENCES settings(id), value BLOB, timestamp INTEGER NOT NULL DEFAULT 0);
+		INSERT INTO groups VALUES (1, 'example.com'), (2, 'file:///'), (3, 'example.org');
+		INSERT INTO settings VALUES (1, 'browser.content.full-zoom'), (2, 'browser.download.lastDir'), (3, 'spellcheck.lang'), (4, 'cookiebanner');
+		INSERT INTO prefs VALUES
+			(1, 1, 1, 1.1, 1613610123.5),
+			(2, 1, 2, '/home/user/Downloads', 1613610124),
+			(3, 2, 1, 2, 0),
+			(4, NULL, 2, '/home/user', 1613610125),
+			(5, 3, 3, 'en-US', 1613610126),
+			(6, 3, 4, 0, 1613610127);
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefs, err := ParseContentPrefs(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unix := func(sec, msec int64) time.Time { return time.Unix(sec, msec*1e6).UTC() }
+	want := &ContentPrefs{
+		Global: []ContentPref{{ContentPrefDownloadDir, "/home/user", unix(1613610125, 0)}},
+		Sites: []SiteContentPrefs{
+			{"example.com", 1.1, "/home/user/Downloads", []ContentPref{
+				{ContentPrefZoom, 1.1, unix(1613610123, 500)},
+				{ContentPrefDownloadDir, "/home/user/Downloads", unix(1613610124, 0)},
+			}},
+			{"example.org", 0, "", []ContentPref{
+				{ContentPrefCookieBanner, int64(0), unix(1613610127, 0)},
+				{ContentPrefSpellcheck, "en-US", unix(1613610126, 0)},
+			}},
+			{"file:///", 2, "", []ContentPref{{ContentPrefZoom, int64(2), time.Time{}}}},
+		},
+	}
+	if !reflect.DeepEqual(prefs, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", prefs, want)
+	}
+	wantModes := map[string]CookieBannerMode{"example.org": CookieBannerDisabled}
+	if modes := prefs.CookieBannerModes(); !reflect.DeepEqual(modes, wantModes) {
+		t.Errorf("got cookie banner modes %v, want %v", modes, wantModes)
+	}
+	if zoom := prefs.SiteZoom("example.org"); zoom != 1 {
+		t.Errorf("got zoom %v for example.org, want 1", zoom)
+	}
+	if zoom := prefs.SiteZoom("file:///"); zoom != 2 {
+		t.Errorf("got zoom %v for file:///, want 2", zoom)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Cookies database schema:
+// https://searchfox.org/mozilla-central/source/netwerk/cookie/CookiePersistentStorage.cpp
+//
+// Each row of moz_cookies is a cookie for a host, which is prefixed
+// with "." for domain cookies. The expiry is in seconds since 1970 and
+// the access and creation times in microseconds. Session cookies are
+// not stored here, but in the session store. originAttributes was added
+// in Firefox 50 and sameSite in Firefox 63; they are read when present.
+
+// CookiesFile is the database of cookies in a profile.
+const CookiesFile = "cookies.sqlite"
+
+// Cookie is a row in moz_cookies.
+type Cookie struct {
+	ID               int64
+	OriginAttributes *OriginAttributes
+	Name             string
+	Value            string
+	Host             string // e.g. "example.com" or ".example.com" for domain cookies
+	Path             string
+	Expiry           time.Time
+	LastAccessed     time.Time
+	CreationTime     time.Time
+	IsSecure         bool
+	IsHTTPOnly       bool
+	SameSite         CookieSameSite
+}
+
+// CookieSameSite is the SameSite attribute of a cookie.
+// https://searchfox.org/mozilla-central/source/netwerk/cookie/nsICookie.idl
+type CookieSameSite uint8
+
+// Values for CookieSameSite:
+const (
+	CookieSameSiteNone   CookieSameSite = 0
+	CookieSameSiteLax    CookieSameSite = 1
+	CookieSameSiteStrict CookieSameSite = 2
+)
+
+// ParseCookies parses cookies.sqlite in a Firefox profile. Cookies are
+// ordered by host, origin attributes, name, and path.
+func ParseCookies(filename string) ([]Cookie, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	cols, err := sqliteutil.Columns(db, "moz_cookies")
+	if err != nil {
+		return nil, fmt.Errorf("firefox: cookies: %w", err)
+	}
+	originAttributes, sameSite := "''", "0"
+	for _, col := range cols {
+		switch col {
+		case "originAttributes":
+			originAttributes = col
+		case "sameSite":
+			sameSite = col
+		}
+	}
+	var cookies []Cookie
+	err = sqliteutil.Query(db, `
+		SELECT id, `+originAttributes+`, name, value, host, path, expiry,
+			lastAccessed, creationTime, isSecure, isHttpOnly, `+sameSite+`
+		FROM moz_cookies
+		ORDER BY host, 2, name, path`, func(rows *sql.Rows) error {
+		var c Cookie
+		var suffix, name, value sql.NullString
+		var expiry, accessed, created sql.NullInt64
+		var secure, httpOnly, sameSite sql.NullInt64
+		if err := rows.Scan(&c.ID, &suffix, &name, &value, &c.Host, &c.Path, &expiry,
+			&accessed, &created, &secure, &httpOnly, &sameSite); err != nil {
+			return err
+		}
+		if expiry.Int64 < 0 || accessed.Int64 < 0 || created.Int64 < 0 {
+			return fmt.Errorf("cookie %d: negative time", c.ID)
+		}
+		attrs, err := ParseOriginAttributes(suffix.String)
+		if err != nil {
+			return err
+		}
+		c.OriginAttributes = attrs
+		c.Name = name.String
+		c.Value = value.String
+		c.Expiry = timefmt.FromInt(expiry.Int64, 0, timefmt.Sec, timefmt.Unix)
+		c.LastAccessed = timefmt.FromInt(accessed.Int64, 0, timefmt.Micro, timefmt.Unix)
+		c.CreationTime = timefmt.FromInt(created.Int64, 0, timefmt.Micro, timefmt.Unix)
+		c.IsSecure = secure.Int64 != 0
+		c.IsHTTPOnly = httpOnly.Int64 != 0
+		c.SameSite = CookieSameSite(sameSite.Int64)
+		cookies = append(cookies, c)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firefox: cookies: %w", err)
+	}
+	return cookies, nil
+}
+
+func (s CookieSameSite) String() string {
+	switch s {
+	case CookieSameSiteNone:
+		return "none"
+	case CookieSameSiteLax:
+		return "lax"
+	case CookieSameSiteStrict:
+		return "strict"
+	default:
+		return fmt.Sprintf("samesite(%d)", uint8(s))
+	}
+}
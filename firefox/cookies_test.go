@@ -0,0 +1,91 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCookies(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "cookies.sqlite")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`
+		CREATE TABLE moz_cookies (id INTEGER PRIMARY KEY, originAttributes TEXT NOT NULL DEFAULT '',
+			name TEXT, value TEXT, host TEXT, path TEXT, expiry INTEGER, lastAccessed INTEGER,
+			creationTime INTEGER, isSecure INTEGER, isHttpOnly INTEGER, inBrowserElement INTEGER DEFAULT 0,
+			sameSite INTEGER DEFAULT 0, rawSameSite INTEGER DEFAULT 0, schemeMap INTEGER DEFAULT 0);
+		INSERT INTO moz_cookies VALUES
+			(1, '', 'sid', 'abc', '.example.com', '/', 1645146000, 1613613600000000,
+				1613610000000000, 1, 1, 0, 1, 1, 2),
+			(2, '^userContextId=2', 'sid', 'def', '.example.com', '/', 1645146000, 1613613600000000,
+				1613610000000000, 0, 0, 0, 2, 2, 1),
+			(3, '', 'pref', 'dark', 'a.example', '/app', 1645146000, 1613613600000000,
+				1613610000000000, 0, 0, 0, 0, 0, 1);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookies, err := ParseCookies(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiry := time.Unix(1645146000, 0).UTC()
+	accessed := time.Unix(1613613600, 0).UTC()
+	created := time.Unix(1613610000, 0).UTC()
+	want := []Cookie{
+		{1, &OriginAttributes{}, "sid", "abc", ".example.com", "/", expiry, accessed, created, true, true, CookieSameSiteLax},
+		{2, &OriginAttributes{UserContextID: 2}, "sid", "def", ".example.com", "/", expiry, accessed, created, false, false, CookieSameSiteStrict},
+		{3, &OriginAttributes{}, "pref", "dark", "a.example", "/app", expiry, accessed, created, false, false, CookieSameSiteNone},
+	}
+	if !reflect.DeepEqual(cookies, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", cookies, want)
+	}
+
+	// Older schemas have neither originAttributes nor sameSite.
+	old := filepath.Join(dir, "old.sqlite")
+	db2, err := sql.Open("sqlite3", old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+	_, err = db2.Exec(`
+		CREATE TABLE moz_cookies (id INTEGER PRIMARY KEY, baseDomain TEXT, appId INTEGER DEFAULT 0,
+			inBrowserElement INTEGER DEFAULT 0, name TEXT, value TEXT, host TEXT, path TEXT,
+			expiry INTEGER, lastAccessed INTEGER, creationTime INTEGER, isSecure INTEGER, isHttpOnly INTEGER);
+		INSERT INTO moz_cookies VALUES
+			(1, 'example.com', 0, 0, 'sid', 'abc', '.example.com', '/', 1645146000, 1613613600000000,
+				1613610000000000, 1, 0);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies, err = ParseCookies(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []Cookie{
+		{1, &OriginAttributes{}, "sid", "abc", ".example.com", "/", expiry, accessed, created, true, false, CookieSameSiteNone},
+	}
+	if !reflect.DeepEqual(cookies, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", cookies, want)
+	}
+
+	if _, err := db2.Exec(`UPDATE moz_cookies SET expiry = -1`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseCookies(old); err == nil {
+		t.Error("expected error for negative expiry")
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"testing"
+
+	"github.com/andrewarchi/browser/internal/golden"
+)
+
+// corpusParsers parses the samples in testdata/corpus, keyed by the
+// name of the file in a profile.
+var corpusParsers = map[string]golden.ParseFunc{
+	"addons.json":                        func(f string) (interface{}, error) { return ParseAddons(f) },
+	"broadcast-listeners.json":           func(f string) (interface{}, error) { return ParseBroadcastListeners(f) },
+	"compatibility.ini":                  func(f string) (interface{}, error) { return ParseCompatibility(f) },
+	"containers.json":                    func(f string) (interface{}, error) { return ParseContainers(f) },
+	"enumerate_devices.txt":              func(f string) (interface{}, error) { return ParseEnumerateDevices(f) },
+	"extension-preferences.json":         func(f string) (interface{}, error) { return ParseExtensionPreferences(f) },
+	"extension-settings.json":            func(f string) (interface{}, error) { return ParseExtensionSettings(f) },
+	"extensions.json":                    func(f string) (interface{}, error) { return ParseExtensions(f) },
+	"handlers.json":                      func(f string) (interface{}, error) { return ParseHandlers(f) },
+	"places.sqlite":                      func(f string) (interface{}, error) { return ParsePlacesBookmarks(f) },
+	"policies.json":                      func(f string) (interface{}, error) { return ParsePolicies(f) },
+	"sessionstore.js":                    func(f string) (interface{}, error) { return ParseSession(f) },
+	"sessionstore.jsonlz4":               func(f string) (interface{}, error) { return ParseSession(f) },
+	"shield-preference-experiments.json": func(f string) (interface{}, error) { return ParsePreferenceExperiments(f) },
+	"signedInUser.json":                  func(f string) (interface{}, error) { return ParseSignedInUser(f) },
+	"times.json":                         func(f string) (interface{}, error) { return ParseTimes(f) },
+	"xulstore.json":                      func(f string) (interface{}, error) { return ParseXULStore(f) },
+}
+
+func TestCorpus(t *testing.T) {
+	golden.Corpus(t, "testdata/corpus", corpusParsers)
+}
@@ -0,0 +1,458 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/jsonutil"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Download is a file download, read from any of the download stores
+// that Firefox has used.
+type Download struct {
+	SourceURL  string
+	Referrer   string
+	TargetPath string // local path
+	MIMEType   string
+	StartTime  time.Time
+	EndTime    time.Time // zero when unknown
+	CurrBytes  int64
+	MaxBytes   int64 // -1 when unknown
+	State      DownloadState
+	GUID       string // downloads.sqlite only
+	Store      string // "downloads.json", "downloads.sqlite", or "places.sqlite"
+}
+
+// DownloadState is the state of a download, using the values from the
+// legacy nsIDownloadManager.
+type DownloadState int8
+
+// Values for DownloadState:
+const (
+	DownloadNotStarted      DownloadState = -1
+	DownloadDownloading     DownloadState = 0
+	DownloadFinished        DownloadState = 1
+	DownloadFailed          DownloadState = 2
+	DownloadCanceled        DownloadState = 3
+	DownloadPaused          DownloadState = 4
+	DownloadQueued          DownloadState = 5
+	DownloadBlockedParental DownloadState = 6
+	DownloadScanning        DownloadState = 7
+	DownloadDirty           DownloadState = 8
+	DownloadBlockedPolicy   DownloadState = 9
+)
+
+// DownloadList is the list of session downloads in downloads.json, as
+// serialized by DownloadStore.jsm.
+type DownloadList struct {
+	List []DownloadEntry `json:"list"`
+}
+
+// DownloadEntry is a download in downloads.json. Source and target are
+// serialized as strings when they have no properties other than the URL
+// or path.
+type DownloadEntry struct {
+	Source               DownloadSource  `json:"source"`
+	Target               DownloadTarget  `json:"target"`
+	StartTime            time.Time       `json:"startTime"`
+	Succeeded            bool            `json:"succeeded,omitempty"`
+	Canceled             bool            `json:"canceled,omitempty"`
+	Error                *DownloadError  `json:"error,omitempty"`
+	TotalBytes           int64           `json:"totalBytes,omitempty"`
+	HasPartialData       bool            `json:"hasPartialData,omitempty"`
+	HasBlockedData       bool            `json:"hasBlockedData,omitempty"`
+	TryToKeepPartialData bool            `json:"tryToKeepPartialData,omitempty"`
+	LauncherPath         string          `json:"launcherPath,omitempty"`
+	LaunchWhenSucceeded  bool            `json:"launchWhenSucceeded,omitempty"`
+	ContentType          string          `json:"contentType,omitempty"`
+	Saver                json.RawMessage `json:"saver,omitempty"` // "copy" or object
+}
+
+// DownloadSource is the source of a download.
+type DownloadSource struct {
+	URL               string `json:"url"`
+	IsPrivate         bool   `json:"isPrivate,omitempty"`
+	Referrer          string `json:"referrer,omitempty"`
+	ReferrerInfo      string `json:"referrerInfo,omitempty"` // serialized nsIReferrerInfo
+	AdjustChannel     bool   `json:"adjustChannel,omitempty"`
+	AllowHTTPStatus   bool   `json:"allowHttpStatus,omitempty"`
+	UserContextID     int64  `json:"userContextId,omitempty"`
+	BrowsingContextID int64  `json:"browsingContextId,omitempty"`
+	Cookie            string `json:"cookie,omitempty"`
+}
+
+// DownloadTarget is the target file of a download.
+type DownloadTarget struct {
+	Path         string `json:"path"`
+	PartFilePath string `json:"partFilePath,omitempty"`
+	Exists       bool   `json:"exists,omitempty"`
+	Size         int64  `json:"size,omitempty"`
+}
+
+// DownloadError is the error that stopped a download.
+type DownloadError struct {
+	Result                             int64  `json:"result,omitempty"`
+	Message                            string `json:"message,omitempty"`
+	BecauseSourceFailed                bool   `json:"becauseSourceFailed,omitempty"`
+	BecauseTargetFailed                bool   `json:"becauseTargetFailed,omitempty"`
+	BecauseBlocked                     bool   `json:"becauseBlocked,omitempty"`
+	BecauseBlockedByParentalControls   bool   `json:"becauseBlockedByParentalControls,omitempty"`
+	BecauseBlockedByReputationCheck    bool   `json:"becauseBlockedByReputationCheck,omitempty"`
+	BecauseBlockedByRuntimePermissions bool   `json:"becauseBlockedByRuntimePermissions,omitempty"`
+	ReputationCheckVerdict             string `json:"reputationCheckVerdict,omitempty"`
+	LocalizedReason                    string `json:"localizedReason,omitempty"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (s *DownloadSource) UnmarshalJSON(data []byte) error {
+	if len(data) != 0 && data[0] == '"' {
+		*s = DownloadSource{}
+		return json.Unmarshal(data, &s.URL)
+	}
+	type source DownloadSource
+	return jsonutil.Decode(bytes.NewReader(data), (*source)(s))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (t *DownloadTarget) UnmarshalJSON(data []byte) error {
+	if len(data) != 0 && data[0] == '"' {
+		*t = DownloadTarget{}
+		return json.Unmarshal(data, &t.Path)
+	}
+	type target DownloadTarget
+	return jsonutil.Decode(bytes.NewReader(data), (*target)(t))
+}
+
+// ParseDownloadList parses downloads.json in a Firefox profile.
+func ParseDownloadList(filename string) (*DownloadList, error) {
+	var list DownloadList
+	if err := jsonutil.DecodeFile(filename, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Downloads converts the entries in downloads.json.
+func (l *DownloadList) Downloads() []Download {
+	downloads := make([]Download, len(l.List))
+	for i, e := range l.List {
+		state := DownloadDownloading
+		switch {
+		case e.Succeeded:
+			state = DownloadFinished
+		case e.Canceled:
+			state = DownloadCanceled
+		case e.Error != nil && e.Error.BecauseBlockedByParentalControls:
+			state = DownloadBlockedParental
+		case e.Error != nil && e.Error.BecauseBlocked:
+			state = DownloadBlockedPolicy
+		case e.Error != nil:
+			state = DownloadFailed
+		}
+		size := e.TotalBytes
+		if size == 0 {
+			size = -1
+		}
+		downloads[i] = Download{
+			SourceURL:  e.Source.URL,
+			Referrer:   e.Source.Referrer,
+			TargetPath: e.Target.Path,
+			MIMEType:   e.ContentType,
+			StartTime:  e.StartTime.UTC(),
+			CurrBytes:  e.Target.Size,
+			MaxBytes:   size,
+			State:      state,
+			Store:      "downloads.json",
+		}
+	}
+	return downloads
+}
+
+// ParseDownloadsSQLite parses the moz_downloads table in
+// downloads.sqlite, which was used by Firefox 25 and earlier.
+func ParseDownloadsSQLite(filename string) ([]Download, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var downloads []Download
+	err = sqliteutil.Query(db, `
+		SELECT source, target, startTime, endTime, state, referrer,
+			currBytes, maxBytes, mimeType, guid
+		FROM moz_downloads
+		ORDER BY startTime, id`, func(rows *sql.Rows) error {
+		var d Download
+		var target string
+		var start int64
+		var end sql.NullInt64 // NULL for unfinished downloads in some versions
+		var referrer, mimeType, guid sql.NullString
+		if err := rows.Scan(&d.SourceURL, &target, &start, &end, &d.State,
+			&referrer, &d.CurrBytes, &d.MaxBytes, &mimeType, &guid); err != nil {
+			return err
+		}
+		path, err := fileURIPath(target)
+		if err != nil {
+			return err
+		}
+		if start < 0 || end.Int64 < 0 {
+			return fmt.Errorf("download %q: negative time", d.SourceURL)
+		}
+		d.TargetPath = path
+		d.StartTime = timefmt.FromInt(start, 0, timefmt.Micro, timefmt.Unix)
+		if end.Valid {
+			d.EndTime = timefmt.FromInt(end.Int64, 0, timefmt.Micro, timefmt.Unix)
+		}
+		d.Referrer = referrer.String
+		d.MIMEType = mimeType.String
+		d.GUID = guid.String
+		d.Store = "downloads.sqlite"
+		downloads = append(downloads, d)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firefox: downloads: %w", err)
+	}
+	return downloads, nil
+}
+
+// placesDownloadMeta is the downloads/metaData annotation in
+// places.sqlite, as written by DownloadHistory.jsm.
+type placesDownloadMeta struct {
+	State    *DownloadState `json:"state"`
+	EndTime  int64          `json:"endTime"`  // milliseconds
+	FileSize *int64         `json:"fileSize"` // finished downloads only
+}
+
+// ParsePlacesDownloads parses the download history in places.sqlite,
+// which Firefox 26 and later keep as page annotations on the source
+// URL: downloads/destinationFileURI for the target and
+// downloads/metaData for the state, end time, and file size. The start
+// time is of the first download visit to the source URL or, when it has
+// expired, of the annotation. Downloads are ordered by start time.
+func ParsePlacesDownloads(filename string) ([]Download, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	if ok, err := sqliteutil.HasTable(db, "moz_annos"); err != nil || !ok {
+		return nil, err
+	}
+
+	var downloads []Download
+	err = sqliteutil.Query(db, `
+		SELECT p.url, dest.content, meta.content,
+			coalesce((SELECT min(v.visit_date) FROM moz_historyvisits v
+				WHERE v.place_id = p.id AND v.visit_type = ?), dest.dateAdded)
+		FROM moz_annos dest
+		JOIN moz_anno_attributes dest_name ON dest_name.id = dest.anno_attribute_id
+		JOIN moz_places p ON p.id = dest.place_id
+		LEFT JOIN (
+			SELECT a.place_id, a.content FROM moz_annos a
+			JOIN moz_anno_attributes n ON n.id = a.anno_attribute_id
+			WHERE n.name = 'downloads/metaData'
+		) meta ON meta.place_id = p.id
+		WHERE dest_name.name = 'downloads/destinationFileURI'
+		ORDER BY 4, p.id`, func(rows *sql.Rows) error {
+		var d Download
+		var target string
+		var metaData sql.NullString
+		var start int64
+		if err := rows.Scan(&d.SourceURL, &target, &metaData, &start); err != nil {
+			return err
+		}
+		path, err := fileURIPath(target)
+		if err != nil {
+			return err
+		}
+		d.TargetPath = path
+		d.StartTime = timefmt.FromInt(start, 0, timefmt.Micro, timefmt.Unix)
+		d.MaxBytes = -1
+		d.State = DownloadFinished // before Firefox 38, only finished downloads were recorded
+		if metaData.Valid {
+			var meta placesDownloadMeta
+			if err := json.Unmarshal([]byte(metaData.String), &meta); err != nil {
+				return fmt.Errorf("%s: metadata: %w", d.SourceURL, err)
+			}
+			if meta.State != nil {
+				d.State = *meta.State
+			}
+			if meta.EndTime > 0 {
+				d.EndTime = timefmt.FromInt(meta.EndTime, 0, timefmt.Milli, timefmt.Unix)
+			}
+			if meta.FileSize != nil {
+				d.CurrBytes = *meta.FileSize
+				d.MaxBytes = *meta.FileSize
+			}
+		}
+		d.Store = "places.sqlite"
+		downloads = append(downloads, d)
+		return nil
+	}, VisitDownload)
+	if err != nil {
+		return nil, fmt.Errorf("firefox: places downloads: %w", err)
+	}
+	return downloads, nil
+}
+
+// ProfileDownloads reads all download stores in a Firefox profile and
+// merges them into one download history, ordered by start time.
+// Downloads recorded in multiple stores with the same source URL,
+// target path, and start time to the second are merged, filling in
+// fields missing from the earlier store. Missing stores are skipped and
+// stores that fail to parse are handled as by ProfileDownloadsOptions
+// with the zero Options.
+func ProfileDownloads(profileDir string) ([]Download, error) {
+	return ProfileDownloadsOptions(profileDir, browser.Options{})
+}
+
+// ProfileDownloadsOptions reads and merges the download stores in a
+// Firefox profile and handles stores that fail to parse by the error
+// policy in opts.
+func ProfileDownloadsOptions(profileDir string, opts browser.Options) ([]Download, error) {
+	h := opts.Handler()
+	var all []Download
+	for _, store := range []struct {
+		name  string
+		parse func(filename string) ([]Download, error)
+	}{
+		{"downloads.json", func(filename string) ([]Download, error) {
+			list, err := ParseDownloadList(filename)
+			if err != nil {
+				return nil, err
+			}
+			return list.Downloads(), nil
+		}},
+		{"places.sqlite", ParsePlacesDownloads},
+		{"downloads.sqlite", ParseDownloadsSQLite},
+	} {
+		filename := filepath.Join(profileDir, store.name)
+		downloads, err := store.parse(filename)
+		if err == nil {
+			all = append(all, downloads...)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			if err := h.File(filename, err); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return MergeDownloads(all), h.Err()
+}
+
+// MergeDownloads merges duplicate downloads from multiple stores and
+// orders them by start time.
+func MergeDownloads(downloads []Download) []Download {
+	type key struct {
+		source, target string
+		start          int64
+	}
+	index := make(map[key]int)
+	var merged []Download
+	for _, d := range downloads {
+		k := key{d.SourceURL, d.TargetPath, d.StartTime.Unix()}
+		i, ok := index[k]
+		if !ok {
+			index[k] = len(merged)
+			merged = append(merged, d)
+			continue
+		}
+		m := &merged[i]
+		if m.Referrer == "" {
+			m.Referrer = d.Referrer
+		}
+		if m.MIMEType == "" {
+			m.MIMEType = d.MIMEType
+		}
+		if m.EndTime.IsZero() {
+			m.EndTime = d.EndTime
+		}
+		if m.MaxBytes < 0 {
+			m.MaxBytes = d.MaxBytes
+		}
+		if m.CurrBytes == 0 {
+			m.CurrBytes = d.CurrBytes
+		}
+		if m.GUID == "" {
+			m.GUID = d.GUID
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].StartTime.Before(merged[j].StartTime)
+	})
+	return merged
+}
+
+// fileURIPath converts a file URI to a local path. A Windows path, like
+// file:///C:/Users, keeps its drive letter, whichever OS reads it.
+func fileURIPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("firefox: not a file URI: %q", uri)
+	}
+	path := u.Path
+	if isDrivePath(path) {
+		path = path[1:] // /C:/Users -> C:/Users
+	}
+	return filepath.FromSlash(path), nil
+}
+
+// isDrivePath reports whether a URI path starts with a Windows drive,
+// as in /C:/ or /C: alone.
+func isDrivePath(path string) bool {
+	if len(path) < 3 || path[0] != '/' || path[2] != ':' || len(path) > 3 && path[3] != '/' {
+		return false
+	}
+	c := path[1]
+	return 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z'
+}
+
+func (s DownloadState) String() string {
+	switch s {
+	case DownloadNotStarted:
+		return "not_started"
+	case DownloadDownloading:
+		return "downloading"
+	case DownloadFinished:
+		return "finished"
+	case DownloadFailed:
+		return "failed"
+	case DownloadCanceled:
+		return "canceled"
+	case DownloadPaused:
+		return "paused"
+	case DownloadQueued:
+		return "queued"
+	case DownloadBlockedParental:
+		return "blocked_parental"
+	case DownloadScanning:
+		return "scanning"
+	case DownloadDirty:
+		return "dirty"
+	case DownloadBlockedPolicy:
+		return "blocked_policy"
+	default:
+		return fmt.Sprintf("download_state(%d)", int8(s))
+	}
+}
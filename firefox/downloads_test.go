@@ -0,0 +1,183 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+func TestDownloadList(t *testing.T) {
+	const data = `{"list":[
+		{"source":"https://example.com/a.zip","target":"/tmp/a.zip","startTime":"2014-01-02T03:04:05.678Z","succeeded":true,"totalBytes":10},
+		{"source":{"url":"https://example.com/b.zip","referrer":"https://example.com/"},"target":{"path":"/tmp/b.zip","partFilePath":"/tmp/b.zip.part"},"startTime":"2014-01-02T03:04:06.000Z","error":{"result":2152398850}}
+	]}`
+	var list DownloadList
+	if err := jsonutil.Decode(strings.NewReader(data), &list); err != nil {
+		t.Fatal(err)
+	}
+	downloads := list.Downloads()
+	if len(downloads) != 2 {
+		t.Fatalf("got %d downloads, want 2", len(downloads))
+	}
+	a, b := downloads[0], downloads[1]
+	if a.SourceURL != "https://example.com/a.zip" || a.TargetPath != "/tmp/a.zip" || a.State != DownloadFinished || a.MaxBytes != 10 {
+		t.Errorf("got %+v", a)
+	}
+	if b.Referrer != "https://example.com/" || b.TargetPath != "/tmp/b.zip" || b.State != DownloadFailed || b.MaxBytes != -1 {
+		t.Errorf("got %+v", b)
+	}
+
+	merged := MergeDownloads(append(downloads, Download{
+		SourceURL:  a.SourceURL,
+		TargetPath: a.TargetPath,
+		StartTime:  a.StartTime,
+		MIMEType:   "application/zip",
+	}))
+	if len(merged) != 2 || merged[0].MIMEType != "application/zip" {
+		t.Errorf("got merged %+v", merged)
+	}
+}
+
+func TestParsePlacesDownloads(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "places.sqlite")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url LONGVARCHAR);
+		CREATE TABLE moz_historyvisits (id INTEGER PRIMARY KEY, place_id INTEGER, visit_date INTEGER, visit_type INTEGER);
+		CREATE TABLE moz_anno_attributes (id INTEGER PRIMARY KEY, name VARCHAR(32) UNIQUE NOT NULL);
+		CREATE TABLE moz_annos (id INTEGER PRIMARY KEY, place_id INTEGER NOT NULL, anno_attribute_id INTEGER,
+			content LONGVARCHAR, flags INTEGER DEFAULT 0, expiration INTEGER DEFAULT 0, type INTEGER DEFAULT 0,
+			dateAdded INTEGER DEFAULT 0, lastModified INTEGER DEFAULT 0);
+		INSERT INTO moz_places VALUES (1, 'https://example.com/a.zip'), (2, 'https://example.com/b.zip');
+		INSERT INTO moz_historyvisits VALUES
+			(1, 1, 1388631845000000, 1), (2, 1, 1388631846000000, 7), (3, 1, 1388631900000000, 7);
+		INSERT INTO moz_anno_attributes VALUES (1, 'downloads/destinationFileURI'), (2, 'downloads/metaData');
+		INSERT INTO moz_annos (place_id, anno_attribute_id, content, dateAdded) VALUES
+			(1, 1, 'file:///tmp/a.zip', 1388631847000000),
+			(1, 2, '{"state":1,"endTime":1388631850000,"fileSize":10}', 1388631850000000),
+			(2, 1, 'file:///tmp/b.zip', 1388631800000000),
+			(2, 2, '{"state":3,"endTime":1388631801000}', 1388631801000000);
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloads, err := ParsePlacesDownloads(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usec := func(us int64) time.Time { return time.Unix(0, us*1e3).UTC() }
+	want := []Download{{
+		SourceURL:  "https://example.com/b.zip",
+		TargetPath: filepath.FromSlash("/tmp/b.zip"),
+		StartTime:  usec(1388631800000000),
+		EndTime:    usec(1388631801000000),
+		MaxBytes:   -1,
+		State:      DownloadCanceled,
+		Store:      "places.sqlite",
+	}, {
+		SourceURL:  "https://example.com/a.zip",
+		TargetPath: filepath.FromSlash("/tmp/a.zip"),
+		StartTime:  usec(1388631846000000),
+		EndTime:    usec(1388631850000000),
+		CurrBytes:  10,
+		MaxBytes:   10,
+		State:      DownloadFinished,
+		Store:      "places.sqlite",
+	}}
+	if !reflect.DeepEqual(downloads, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", downloads, want)
+	}
+}
+
+func TestParseDownloadsSQLite(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "downloads.sqlite")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE moz_downloads (id INTEGER PRIMARY KEY, name TEXT, source TEXT, target TEXT,
+			tempPath TEXT, startTime INTEGER, endTime INTEGER, state INTEGER, referrer TEXT,
+			entityID TEXT, currBytes INTEGER NOT NULL DEFAULT 0, maxBytes INTEGER NOT NULL DEFAULT -1,
+			mimeType TEXT, preferredApplication TEXT, preferredAction INTEGER NOT NULL DEFAULT 0,
+			autoResume INTEGER NOT NULL DEFAULT 0, guid TEXT);
+		INSERT INTO moz_downloads (id, source, target, startTime, endTime, state, referrer,
+			currBytes, maxBytes, mimeType, guid) VALUES
+			(1, 'https://example.com/a.zip', 'file:///C:/Users/me/Downloads/a.zip', 1388631846000000,
+				1388631850000000, 1, 'https://example.com/', 10, 10, 'application/zip', 'aaaaaaaaaaaa'),
+			(2, 'https://example.com/b.zip', 'file:///tmp/b.zip', 1388631900000000, NULL, 0, NULL,
+				5, 20, NULL, NULL);
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloads, err := ParseDownloadsSQLite(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usec := func(us int64) time.Time { return time.Unix(0, us*1e3).UTC() }
+	want := []Download{{
+		SourceURL:  "https://example.com/a.zip",
+		Referrer:   "https://example.com/",
+		TargetPath: filepath.FromSlash("C:/Users/me/Downloads/a.zip"),
+		MIMEType:   "application/zip",
+		StartTime:  usec(1388631846000000),
+		EndTime:    usec(1388631850000000),
+		CurrBytes:  10,
+		MaxBytes:   10,
+		State:      DownloadFinished,
+		GUID:       "aaaaaaaaaaaa",
+		Store:      "downloads.sqlite",
+	}, {
+		SourceURL:  "https://example.com/b.zip",
+		TargetPath: filepath.FromSlash("/tmp/b.zip"),
+		StartTime:  usec(1388631900000000),
+		CurrBytes:  5,
+		MaxBytes:   20,
+		State:      DownloadDownloading,
+		Store:      "downloads.sqlite",
+	}}
+	if !reflect.DeepEqual(downloads, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", downloads, want)
+	}
+}
+
+func TestFileURIPath(t *testing.T) {
+	for _, tt := range []struct {
+		uri, want string
+	}{
+		{"file:///tmp/a.zip", "/tmp/a.zip"},
+		{"file:///C:/Users/me/a.zip", "C:/Users/me/a.zip"},
+		{"file:///d:/a.zip", "d:/a.zip"},
+		{"file:///C:", "C:"},
+		{"file:///1:/a.zip", "/1:/a.zip"},
+		{"file:///ab:/a.zip", "/ab:/a.zip"},
+		{"file:///C:a.zip", "/C:a.zip"},
+	} {
+		got, err := fileURIPath(tt.uri)
+		if err != nil {
+			t.Errorf("%s: %v", tt.uri, err)
+		} else if want := filepath.FromSlash(tt.want); got != want {
+			t.Errorf("%s: got %q, want %q", tt.uri, got, want)
+		}
+	}
+	if _, err := fileURIPath("https://example.com/a.zip"); err == nil {
+		t.Error("expected error for non-file URI")
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"time"
+
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+// PreferenceExperiments contains the Normandy (formerly Shield)
+// preference experiments in shield-preference-experiments.json.
+// https://searchfox.org/mozilla-central/source/toolkit/components/normandy/lib/PreferenceExperiments.jsm
+type PreferenceExperiments struct {
+	Experiments map[string]PreferenceExperiment `json:"experiments"`         // key: slug
+	Version     int                             `json:"__version,omitempty"` // migration version, e.g. 5
+}
+
+// PreferenceExperiment is an experiment that changes preferences.
+type PreferenceExperiment struct {
+	Slug                   string                          `json:"slug"`
+	Branch                 string                          `json:"branch"`
+	Expired                bool                            `json:"expired"`
+	LastSeen               time.Time                       `json:"lastSeen"`
+	Preferences            map[string]ExperimentPreference `json:"preferences"`
+	ExperimentType         string                          `json:"experimentType"` // e.g. "exp", "pref-test"
+	UserFacingName         string                          `json:"userFacingName,omitempty"`
+	UserFacingDescription  string                          `json:"userFacingDescription,omitempty"`
+	EnrollmentID           string                          `json:"enrollmentId,omitempty"`
+	ActionName             string                          `json:"actionName,omitempty"` // e.g. "PreferenceExperimentAction"
+	TemporaryErrorDeadline string                          `json:"temporaryErrorDeadline,omitempty"`
+
+	// Single preference format before migration 3.
+	PreferenceName          string      `json:"preferenceName,omitempty"`
+	PreferenceValue         interface{} `json:"preferenceValue,omitempty"`
+	PreferenceType          string      `json:"preferenceType,omitempty"`
+	PreviousPreferenceValue interface{} `json:"previousPreferenceValue,omitempty"`
+	PreferenceBranchType    string      `json:"preferenceBranchType,omitempty"`
+}
+
+// ExperimentPreference is a preference changed by an experiment.
+type ExperimentPreference struct {
+	PreferenceValue         interface{} `json:"preferenceValue"`
+	PreferenceType          string      `json:"preferenceType"` // "string", "integer", or "boolean"
+	PreviousPreferenceValue interface{} `json:"previousPreferenceValue"`
+	PreferenceBranchType    string      `json:"preferenceBranchType"` // "default" or "user"
+	Overridden              bool        `json:"overridden,omitempty"` // user changed the preference
+}
+
+// ParsePreferenceExperiments parses shield-preference-experiments.json
+// in a Firefox profile.
+func ParsePreferenceExperiments(filename string) (*PreferenceExperiments, error) {
+	var experiments PreferenceExperiments
+	if err := jsonutil.DecodeFile(filename, &experiments); err != nil {
+		return nil, err
+	}
+	return &experiments, nil
+}
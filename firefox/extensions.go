@@ -7,9 +7,20 @@
 package firefox
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/andrewarchi/browser/jsonutil"
 	"github.com/andrewarchi/browser/jsonutil/timefmt"
 	"github.com/andrewarchi/browser/jsonutil/uuid"
+	"github.com/andrewarchi/browser/manifest"
 )
 
 // ExtensionSettings contains preferences and commands set by extensions
@@ -55,6 +66,16 @@ func ParseExtensionSettings(filename string) (*ExtensionSettings, error) {
 	return &settings, nil
 }
 
+// WriteExtensionSettings writes settings in extension-settings.json
+// format, which ParseExtensionSettings reads back unchanged. Keys of
+// maps are ordered by name.
+func WriteExtensionSettings(w io.Writer, settings *ExtensionSettings) error {
+	if err := writeJSONFile(w, settings); err != nil {
+		return fmt.Errorf("firefox: extension settings: %w", err)
+	}
+	return nil
+}
+
 // ExtensionPermissions lists additional permissions granted to an
 // extension in extension-preferences.json.
 type ExtensionPermissions struct {
@@ -77,17 +98,28 @@ type Extensions struct {
 	Addons        []Addon `json:"addons"`
 }
 
+// Range of extensions.json schema versions that ParseExtensions
+// supports. Versions before currentExtensionsSchema may contain legacy
+// fields that were since removed, so they are decoded leniently.
+const (
+	minExtensionsSchema     = 27 // Firefox 61
+	currentExtensionsSchema = 33 // Firefox 85
+	maxExtensionsSchema     = 35
+)
+
 type Addon struct {
 	ID                     *uuid.Firefox          `json:"id"`
-	SyncGUID               *uuid.UUID             `json:"syncGUID"` // "{xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx}"
-	Version                string                 `json:"version"`  // addon version
-	Type                   string                 `json:"type"`     // "extension", "theme", "locale", "dictionary"
+	SyncGUID               *uuid.GUID             `json:"syncGUID"`
+	Version                string                 `json:"version"` // addon version
+	Type                   string                 `json:"type"`    // "extension", "theme", "locale", "dictionary"
 	Loader                 jsonutil.UnknownType   `json:"loader"`
-	UpdateURL              string                 `json:"updateURL"`
-	OptionsURL             string                 `json:"optionsURL"`
+	UpdateURL              *string                `json:"updateURL"`
+	InstallOrigins         []string               `json:"installOrigins,omitempty"`  // since schema 34
+	ManifestVersion        int                    `json:"manifestVersion,omitempty"` // since schema 35
+	OptionsURL             *string                `json:"optionsURL"`
 	OptionsType            int                    `json:"optionsType"`
 	OptionsBrowserStyle    bool                   `json:"optionsBrowserStyle"`
-	AboutURL               string                 `json:"aboutURL"`
+	AboutURL               *string                `json:"aboutURL"`
 	DefaultLocale          Locale                 `json:"defaultLocale"`
 	Visible                bool                   `json:"visible"`
 	Active                 bool                   `json:"active"`
@@ -100,14 +132,14 @@ type Addon struct {
 	Path                   string                 `json:"path"`
 	Skinnable              bool                   `json:"skinnable"`
 	SourceURI              string                 `json:"sourceURI"`
-	ReleaseNotesURI        string                 `json:"releaseNotesURI"`
+	ReleaseNotesURI        *string                `json:"releaseNotesURI"`
 	SoftDisabled           bool                   `json:"softDisabled"`
 	ForeignInstall         bool                   `json:"foreignInstall"`
 	StrictCompatibility    bool                   `json:"strictCompatibility"`
 	Locales                []Locale               `json:"locales"`
 	TargetApplications     []TargetApplication    `json:"targetApplications"`
 	TargetPlatforms        []jsonutil.UnknownType `json:"targetPlatforms"`
-	SignedState            int                    `json:"signedState,omitempty"` // e.g. 2
+	SignedState            *SignedState           `json:"signedState,omitempty"` // nil when signing is not required
 	SignedDate             timefmt.UnixMilli      `json:"signedDate"`
 	Seen                   bool                   `json:"seen"`
 	Dependencies           []interface{}          `json:"dependencies"`
@@ -115,33 +147,42 @@ type Addon struct {
 	UserPermissions        *ExtensionPermissions  `json:"userPermissions"`
 	OptionalPermissions    *ExtensionPermissions  `json:"optionalPermissions"`
 	Icons                  map[int]string         `json:"icons"` // key: icon size, value: path
-	IconURL                string                 `json:"iconURL"`
-	BlocklistState         int                    `json:"blocklistState"` // e.g. 2
-	BlocklistURL           string                 `json:"blocklistURL"`
+	IconURL                *string                `json:"iconURL"`
+	BlocklistState         BlocklistState         `json:"blocklistState"`
+	BlocklistURL           *string                `json:"blocklistURL"`
 	StartupData            *StartupData           `json:"startupData"`
 	Hidden                 bool                   `json:"hidden"`
 	InstallTelemetryInfo   *InstallTelemetryInfo  `json:"installTelemetryInfo"`
 	RecommendationState    *RecommendationState   `json:"recommendationState"`
 	RootURI                string                 `json:"rootURI"`
 	Location               string                 `json:"location"` // e.g. "app-builtin", "app-profile", "app-system-addons", "app-system-defaults", "app-system-local"
+
+	// Legacy fields in schemas before 33, from when Firefox supported
+	// bootstrapped and legacy add-ons.
+	Bootstrap               bool `json:"bootstrap,omitempty"`
+	MultiprocessCompatible  bool `json:"multiprocessCompatible,omitempty"`
+	HasEmbeddedWebExtension bool `json:"hasEmbeddedWebExtension,omitempty"`
+	MPCOptedOut             bool `json:"mpcOptedOut,omitempty"`
+	IsSystem                bool `json:"isSystem,omitempty"`
+	IsWebExtension          bool `json:"isWebExtension,omitempty"`
 }
 
 // Locale contains addon information in a locale.
 type Locale struct {
 	Name         string               `json:"name"` // Addon name
-	Description  string               `json:"description,omitempty"`
-	Creator      string               `json:"creator,omitempty"`
-	HomepageURL  string               `json:"homepageURL,omitempty"`
+	Description  *string              `json:"description"`
+	Creator      *string              `json:"creator"`
+	HomepageURL  *string              `json:"homepageURL"`
 	Developers   jsonutil.UnknownType `json:"developers"`
 	Translators  jsonutil.UnknownType `json:"translators"`
 	Contributors jsonutil.UnknownType `json:"contributors"`
-	Locales      []string             `json:"locales"`
+	Locales      []string             `json:"locales,omitempty"` // only in Addon.Locales
 }
 
 type TargetApplication struct {
-	ID         string `json:"id"` // e.g. "toolkit@mozilla.org"
-	MinVersion string `json:"minVersion"`
-	MaxVersion string `json:"maxVersion"`
+	ID         string  `json:"id"` // e.g. "toolkit@mozilla.org"
+	MinVersion string  `json:"minVersion"`
+	MaxVersion *string `json:"maxVersion"`
 }
 
 type StartupData struct {
@@ -171,11 +212,191 @@ type RecommendationState struct {
 	States         []string          `json:"states"` // e.g. "line", "recommended", "recommended-android"
 }
 
-// ParseExtensions parses extensions.json in a Firefox profile.
+// ParseExtensions parses extensions.json in a Firefox profile. Schema
+// versions 27 through 35 are supported. Fields are decoded strictly
+// from the current schema onward and leniently before, and rootURI,
+// which older schemas lack, is derived from the path.
 func ParseExtensions(filename string) (*Extensions, error) {
-	var extensions Extensions
-	if err := jsonutil.DecodeFile(filename, &extensions); err != nil {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
 		return nil, err
 	}
+	var schema struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(b, &schema); err != nil {
+		return nil, fmt.Errorf("firefox: extensions: %w", err)
+	}
+	v := schema.SchemaVersion
+	if v < minExtensionsSchema || v > maxExtensionsSchema {
+		return nil, fmt.Errorf("firefox: extensions: unsupported schema version %d", v)
+	}
+	var extensions Extensions
+	if v >= currentExtensionsSchema {
+		err = jsonutil.Decode(bytes.NewReader(b), &extensions)
+	} else {
+		err = jsonutil.DecodeAllowUnknownFields(bytes.NewReader(b), &extensions)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("firefox: extensions: %w", err)
+	}
+	for i := range extensions.Addons {
+		a := &extensions.Addons[i]
+		if a.RootURI == "" && a.Path != "" {
+			a.RootURI = addonRootURI(a.Path)
+		}
+	}
 	return &extensions, nil
 }
+
+// addonRootURI returns the URI of the root of an add-on, either a
+// directory or the inside of an XPI, as computed by XPIInternal.jsm.
+func addonRootURI(path string) string {
+	u := (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)})
+	if !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path // Windows drive
+	}
+	if strings.HasSuffix(strings.ToLower(path), ".xpi") {
+		return "jar:" + u.String() + "!/"
+	}
+	return strings.TrimSuffix(u.String(), "/") + "/"
+}
+
+// WriteExtensions writes extensions in extensions.json format, which
+// ParseExtensions reads back unchanged. Fields are in the order that
+// Firefox writes them. Firefox rewrites extensions.json on exit, so it
+// should not be running when the file is replaced.
+func WriteExtensions(w io.Writer, extensions *Extensions) error {
+	if err := writeJSONFile(w, extensions); err != nil {
+		return fmt.Errorf("firefox: extensions: %w", err)
+	}
+	return nil
+}
+
+// Add-on types in extensions.json:
+const (
+	AddonExtension  = "extension"
+	AddonTheme      = "theme"
+	AddonLocale     = "locale"
+	AddonDictionary = "dictionary"
+	AddonSitePerm   = "sitepermission"
+)
+
+// Install locations of add-ons. Builtin and system locations are
+// shipped with Firefox, the profile and temporary locations are
+// installed by the user, and the rest are sideloaded by other
+// applications.
+// https://searchfox.org/mozilla-central/source/toolkit/mozapps/extensions/internal/XPIProvider.jsm
+const (
+	LocationProfile        = "app-profile"
+	LocationTemporary      = "app-temporary"
+	LocationBuiltin        = "app-builtin"
+	LocationSystemAddons   = "app-system-addons"
+	LocationSystemDefaults = "app-system-defaults"
+	LocationSystemLocal    = "app-system-local"
+	LocationSystemShare    = "app-system-share"
+	LocationSystemUser     = "app-system-user"
+	LocationGlobal         = "app-global"
+	LocationWinRegUser     = "winreg-app-user"
+	LocationWinRegGlobal   = "winreg-app-global"
+)
+
+// IsBuiltin reports whether the add-on is shipped with Firefox, either
+// built in or as a system add-on.
+func (a *Addon) IsBuiltin() bool {
+	switch a.Location {
+	case LocationBuiltin, LocationSystemAddons, LocationSystemDefaults:
+		return true
+	}
+	return false
+}
+
+// IsUserInstalled reports whether the add-on was installed by the user
+// into the profile.
+func (a *Addon) IsUserInstalled() bool {
+	return a.Location == LocationProfile || a.Location == LocationTemporary
+}
+
+// IsSideloaded reports whether the add-on was installed outside of
+// Firefox, such as by another application or the system package
+// manager.
+func (a *Addon) IsSideloaded() bool {
+	return !a.IsBuiltin() && !a.IsUserInstalled()
+}
+
+// IsTheme reports whether the add-on is a theme.
+func (a *Addon) IsTheme() bool { return a.Type == AddonTheme }
+
+// IsExtension reports whether the add-on is an extension.
+func (a *Addon) IsExtension() bool { return a.Type == AddonExtension }
+
+// IsDictionary reports whether the add-on is a spell checking
+// dictionary.
+func (a *Addon) IsDictionary() bool { return a.Type == AddonDictionary }
+
+// IsLocale reports whether the add-on is a language pack.
+func (a *Addon) IsLocale() bool { return a.Type == AddonLocale }
+
+// ReadManifest reads the manifest.json of the add-on from its path,
+// which is either an XPI file or, for unpacked add-ons, a directory.
+func (a *Addon) ReadManifest() (*manifest.Manifest, error) {
+	fi, err := os.Stat(a.Path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return manifest.ParseFile(filepath.Join(a.Path, "manifest.json"))
+	}
+	x, err := OpenXPI(a.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer x.Close()
+	return x.Manifest()
+}
+
+// PermissionRisk scores the permissions and origins requested by the
+// add-on, as recorded in extensions.json, without reading its manifest.
+// Optional permissions granted later are in extension-preferences.json.
+func (a *Addon) PermissionRisk() manifest.Risk {
+	if a.UserPermissions == nil {
+		return manifest.ScoreRisk(manifest.Permissions{})
+	}
+	return manifest.ScoreRisk(manifest.NewPermissions(a.UserPermissions.Permissions, a.UserPermissions.Origins))
+}
+
+// Filter returns the add-ons for which keep returns true.
+func (e *Extensions) Filter(keep func(a *Addon) bool) []Addon {
+	var addons []Addon
+	for i := range e.Addons {
+		if keep(&e.Addons[i]) {
+			addons = append(addons, e.Addons[i])
+		}
+	}
+	return addons
+}
+
+// UserInstalled returns the add-ons installed by the user into the
+// profile.
+func (e *Extensions) UserInstalled() []Addon {
+	return e.Filter((*Addon).IsUserInstalled)
+}
+
+// Builtin returns the add-ons shipped with Firefox.
+func (e *Extensions) Builtin() []Addon {
+	return e.Filter((*Addon).IsBuiltin)
+}
+
+// UserExtensions returns the extensions installed by the user, which
+// excludes themes, dictionaries, language packs, and builtin or
+// sideloaded add-ons.
+func (e *Extensions) UserExtensions() []Addon {
+	return e.Filter(func(a *Addon) bool {
+		return a.IsExtension() && a.IsUserInstalled()
+	})
+}
+
+// ByType returns the add-ons of a type, such as AddonTheme.
+func (e *Extensions) ByType(typ string) []Addon {
+	return e.Filter(func(a *Addon) bool { return a.Type == typ })
+}
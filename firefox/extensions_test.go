@@ -0,0 +1,178 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/andrewarchi/browser/jsonutil/uuid"
+	"github.com/andrewarchi/browser/manifest"
+)
+
+func TestExtensionsFilter(t *testing.T) {
+	e := &Extensions{Addons: []Addon{
+		{Version: "0", Type: AddonExtension, Location: LocationProfile},
+		{Version: "1", Type: AddonTheme, Location: LocationProfile},
+		{Version: "2", Type: AddonExtension, Location: LocationBuiltin},
+		{Version: "3", Type: AddonTheme, Location: LocationBuiltin},
+		{Version: "4", Type: AddonExtension, Location: LocationSystemAddons},
+		{Version: "5", Type: AddonDictionary, Location: LocationSystemLocal},
+		{Version: "6", Type: AddonExtension, Location: LocationTemporary},
+	}}
+	tests := []struct {
+		Name string
+		Got  []Addon
+		Want string
+	}{
+		{"UserInstalled", e.UserInstalled(), "016"},
+		{"Builtin", e.Builtin(), "234"},
+		{"UserExtensions", e.UserExtensions(), "06"},
+		{"Themes", e.ByType(AddonTheme), "13"},
+		{"Sideloaded", e.Filter((*Addon).IsSideloaded), "5"},
+	}
+	for i, tt := range tests {
+		var got string
+		for _, a := range tt.Got {
+			got += a.Version
+		}
+		if got != tt.Want {
+			t.Errorf("#%d: %s: got: %q, want: %q", i, tt.Name, got, tt.Want)
+		}
+	}
+}
+
+func TestAddonManifest(t *testing.T) {
+	dir := t.TempDir()
+	xpi := filepath.Join(dir, "tabtool@example.com.xpi")
+	f, err := os.Create(xpi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(`{"manifest_version": 2, "name": "Tab Tool", "version": "1.0",
+		"permissions": ["tabs", "clipboardRead"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Addon{Path: xpi, UserPermissions: &ExtensionPermissions{
+		Permissions: []string{"tabs", "clipboardRead"},
+		Origins:     []string{"<all_urls>"},
+	}}
+	m, err := a.ReadManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "Tab Tool" || m.Risk().Score != 4 {
+		t.Errorf("got manifest %+v", m)
+	}
+	if r := a.PermissionRisk(); r.Score != 9 || r.Level != manifest.RiskHigh {
+		t.Errorf("got risk %+v", r)
+	}
+}
+
+func TestWriteExtensionsRoundTrip(t *testing.T) {
+	tests := []struct {
+		filename string
+		parse    func(filename string) (interface{}, error)
+		write    func(w io.Writer, v interface{}) error
+	}{
+		{
+			"testdata/corpus/extensions.json/firefox-85.json",
+			func(filename string) (interface{}, error) { return ParseExtensions(filename) },
+			func(w io.Writer, v interface{}) error { return WriteExtensions(w, v.(*Extensions)) },
+		},
+		{
+			"testdata/corpus/extension-settings.json/firefox-85.json",
+			func(filename string) (interface{}, error) { return ParseExtensionSettings(filename) },
+			func(w io.Writer, v interface{}) error { return WriteExtensionSettings(w, v.(*ExtensionSettings)) },
+		},
+	}
+	for _, tt := range tests {
+		v, err := tt.parse(tt.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got bytes.Buffer
+		if err := tt.write(&got, v); err != nil {
+			t.Fatal(err)
+		}
+		// The corpus is indented, but otherwise as written by Firefox.
+		orig, err := os.ReadFile(tt.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want bytes.Buffer
+		if err := json.Compact(&want, orig); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", tt.filename, got.Bytes(), want.Bytes())
+		}
+	}
+}
+
+func TestParseExtensionsSchemas(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, data string
+		want       *Extensions
+		wantErr    bool
+	}{
+		{"legacy", `{"schemaVersion": 28, "addons": [{"id": "legacy@example.com", "version": "1.0",
+			"bootstrap": true, "multiprocessCompatible": true, "hasBinaryComponents": false,
+			"path": "/profile/extensions/legacy@example.com.xpi", "location": "app-profile"}]}`,
+			&Extensions{SchemaVersion: 28, Addons: []Addon{{
+				ID: &uuid.Firefox{ID: "legacy@example.com"}, Version: "1.0",
+				Bootstrap: true, MultiprocessCompatible: true,
+				Path:     "/profile/extensions/legacy@example.com.xpi",
+				RootURI:  "jar:file:///profile/extensions/legacy@example.com.xpi!/",
+				Location: LocationProfile,
+			}}}, false},
+		{"newer", `{"schemaVersion": 35, "addons": [{"id": "new@example.com", "version": "2.0",
+			"installOrigins": ["https://example.com"], "manifestVersion": 3,
+			"path": "/profile/extensions/new", "rootURI": "file:///profile/extensions/new/", "location": "app-profile"}]}`,
+			&Extensions{SchemaVersion: 35, Addons: []Addon{{
+				ID: &uuid.Firefox{ID: "new@example.com"}, Version: "2.0",
+				InstallOrigins: []string{"https://example.com"}, ManifestVersion: 3,
+				Path: "/profile/extensions/new", RootURI: "file:///profile/extensions/new/",
+				Location: LocationProfile,
+			}}}, false},
+		{"unknown field", `{"schemaVersion": 33, "addons": [{"id": "a@example.com", "bootstrapped": true}]}`, nil, true},
+		{"unknown schema", `{"schemaVersion": 99, "addons": []}`, nil, true},
+	}
+	for _, tt := range tests {
+		filename := filepath.Join(dir, tt.name+".json")
+		if err := os.WriteFile(filename, []byte(tt.data), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseExtensions(filename)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got:\n%+v\nwant:\n%+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Favicons database schema:
+// https://searchfox.org/mozilla-central/source/toolkit/components/places/nsPlacesTables.h
+// https://searchfox.org/mozilla-central/source/toolkit/components/places/FaviconHelpers.cpp
+//
+// Each row of moz_icons is an icon at a single size, mapped to pages
+// in moz_pages_w_icons by moz_icons_to_pages. Root icons, such as
+// /favicon.ico, are not mapped to pages and apply to every page on the
+// origin without its own icon.
+
+// Favicons is an open favicons.sqlite database in a Firefox profile.
+type Favicons struct {
+	db *sql.DB
+}
+
+// Favicon is an icon at a single size.
+type Favicon struct {
+	ID      int64
+	URL     string // URL of the icon
+	Width   int    // width and height in pixels; FaviconVectorWidth for SVG
+	Root    bool   // icon at the root of the origin, e.g. /favicon.ico
+	Color   int64  // dominant color as 0xRRGGBB, or -1 when unknown
+	Expires time.Time
+	Data    []byte // encoded image, usually PNG or SVG
+}
+
+// FaviconVectorWidth is the width of vector icons, which fit any size.
+const FaviconVectorWidth = 65535
+
+// OpenFavicons opens favicons.sqlite in a Firefox profile.
+func OpenFavicons(filename string) (*Favicons, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &Favicons{db}, nil
+}
+
+// Close closes the database.
+func (f *Favicons) Close() error { return f.db.Close() }
+
+// Lookup returns the icons mapped to a page URL, ordered by width. When
+// the page has no icons, the root icons of its origin are returned.
+func (f *Favicons) Lookup(pageURL string) ([]Favicon, error) {
+	icons, err := f.query(`
+		SELECT i.id, i.icon_url, i.width, i.root, i.color, i.expire_ms, i.data
+		FROM moz_pages_w_icons p
+		JOIN moz_icons_to_pages ip ON ip.page_id = p.id
+		JOIN moz_icons i ON i.id = ip.icon_id
+		WHERE p.page_url = ?
+		ORDER BY i.width, i.id`, pageURL)
+	if err != nil || len(icons) != 0 {
+		return icons, err
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil || u.Host == "" {
+		return nil, nil
+	}
+	root := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/favicon.ico"}).String()
+	return f.query(`
+		SELECT id, icon_url, width, root, color, expire_ms, data
+		FROM moz_icons
+		WHERE root = 1 AND icon_url = ?
+		ORDER BY width, id`, root)
+}
+
+func (f *Favicons) query(query string, args ...interface{}) ([]Favicon, error) {
+	var icons []Favicon
+	err := sqliteutil.Query(f.db, query, func(rows *sql.Rows) error {
+		var icon Favicon
+		var color sql.NullInt64
+		var expires int64
+		if err := rows.Scan(&icon.ID, &icon.URL, &icon.Width, &icon.Root, &color, &expires, &icon.Data); err != nil {
+			return err
+		}
+		icon.Color = -1
+		if color.Valid {
+			icon.Color = color.Int64
+		}
+		icon.Expires = timefmt.FromInt(expires, 0, timefmt.Milli, timefmt.Unix)
+		icons = append(icons, icon)
+		return nil
+	}, args...)
+	if err != nil {
+		return nil, fmt.Errorf("firefox: favicons: %w", err)
+	}
+	return icons, nil
+}
+
+// Icon returns the best icon for a page URL at the requested size in
+// pixels: the smallest icon at least as large as size, or else the
+// largest icon, as chosen by Firefox. It returns nil when the page has
+// no icon.
+func (f *Favicons) Icon(pageURL string, size int) (*Favicon, error) {
+	icons, err := f.Lookup(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	var best *Favicon
+	for i := range icons {
+		icon := &icons[i]
+		if best == nil || betterFavicon(icon, best, size) {
+			best = icon
+		}
+	}
+	return best, nil
+}
+
+func betterFavicon(icon, best *Favicon, size int) bool {
+	if (icon.Width >= size) != (best.Width >= size) {
+		return icon.Width >= size
+	}
+	if icon.Width >= size {
+		return icon.Width < best.Width
+	}
+	return icon.Width > best.Width
+}
+
+// MIMEType returns the MIME type of the icon data, e.g. "image/png" or
+// "image/svg+xml".
+func (icon *Favicon) MIMEType() string {
+	data := bytes.TrimLeft(icon.Data, " \t\r\n")
+	if bytes.HasPrefix(data, []byte("<svg")) ||
+		bytes.HasPrefix(data, []byte("<?xml")) && bytes.Contains(data, []byte("<svg")) {
+		return "image/svg+xml"
+	}
+	return http.DetectContentType(icon.Data)
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestFavicons(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "favicons.sqlite")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE moz_icons (id INTEGER PRIMARY KEY, icon_url TEXT NOT NULL, fixed_icon_url_hash INTEGER NOT NULL,
+			width INTEGER NOT NULL DEFAULT 0, root INTEGER NOT NULL DEFAULT 0, color INTEGER,
+			expire_ms INTEGER NOT NULL DEFAULT 0, data BLOB);
+		CREATE TABLE moz_pages_w_icons (id INTEGER PRIMARY KEY, page_url TEXT NOT NULL, page_url_hash INTEGER NOT NULL);
+		CREATE TABLE moz_icons_to_pages (page_id INTEGER NOT NULL, icon_id INTEGER NOT NULL, expire_ms INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (page_id, icon_id));
+		INSERT INTO moz_icons VALUES
+			(1, 'https://a.example/icon.png', 0, 16, 0, NULL, 1613610123000, x'89504e470d0a1a0a'),
+			(2, 'https://a.example/icon.png', 0, 32, 0, 16777215, 1613610123000, x'89504e470d0a1a0a'),
+			(3, 'https://a.example/icon.svg', 0, 65535, 0, NULL, 1613610123000, '<svg xmlns="http://www.w3.org/2000/svg"/>'),
+			(4, 'https://b.example/favicon.ico', 0, 16, 1, NULL, 1613610123000, x'89504e470d0a1a0a');
+		INSERT INTO moz_pages_w_icons VALUES (1, 'https://a.example/', 0), (2, 'https://a.example/svg', 0);
+		INSERT INTO moz_icons_to_pages VALUES (1, 1, 0), (1, 2, 0), (2, 3, 0);
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenFavicons(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tests := []struct {
+		Page string
+		Size int
+		ID   int64
+		MIME string
+	}{
+		{"https://a.example/", 16, 1, "image/png"},
+		{"https://a.example/", 20, 2, "image/png"},
+		{"https://a.example/", 64, 2, "image/png"},
+		{"https://a.example/svg", 64, 3, "image/svg+xml"},
+		{"https://b.example/page", 32, 4, "image/png"},
+		{"https://c.example/", 16, 0, ""},
+	}
+	for i, tt := range tests {
+		icon, err := f.Icon(tt.Page, tt.Size)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if icon == nil {
+			if tt.ID != 0 {
+				t.Errorf("#%d: got: no icon, want: %d", i, tt.ID)
+			}
+			continue
+		}
+		if icon.ID != tt.ID || icon.MIMEType() != tt.MIME {
+			t.Errorf("#%d: got: %d %s, want: %d %s", i, icon.ID, icon.MIMEType(), tt.ID, tt.MIME)
+		}
+	}
+	icons, err := f.Lookup("https://a.example/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icons) != 2 || icons[0].Color != -1 || icons[1].Color != 0xffffff || icons[0].Expires.Unix() != 1613610123 {
+		t.Errorf("got icons %+v", icons)
+	}
+}
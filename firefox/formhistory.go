@@ -0,0 +1,104 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Form history database schema:
+// https://searchfox.org/mozilla-central/source/toolkit/components/satchel/FormHistory.jsm
+//
+// Entries removed by the user are deleted from moz_formhistory and
+// their GUIDs recorded in moz_deleted_formhistory, so that the removal
+// can be synced.
+
+// FormHistory contains the values entered into form fields, which are
+// suggested by autofill, from formhistory.sqlite in a Firefox profile.
+type FormHistory struct {
+	Entries []FormHistoryEntry
+	Deleted []DeletedFormHistoryEntry
+}
+
+// FormHistoryEntry is a value entered into a form field.
+type FormHistoryEntry struct {
+	ID        int64
+	FieldName string // name or ID of the field, e.g. "q", "searchbar-history"
+	Value     string
+	TimesUsed int
+	FirstUsed time.Time
+	LastUsed  time.Time
+	GUID      string
+}
+
+// DeletedFormHistoryEntry is a form history entry removed by the user.
+type DeletedFormHistoryEntry struct {
+	ID          int64
+	TimeDeleted time.Time
+	GUID        string
+}
+
+// ParseFormHistory parses formhistory.sqlite in a Firefox profile.
+// Entries are ordered by first use.
+func ParseFormHistory(filename string) (*FormHistory, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var h FormHistory
+	err = sqliteutil.Query(db, `
+		SELECT id, fieldname, value, timesUsed, firstUsed, lastUsed, guid
+		FROM moz_formhistory
+		ORDER BY firstUsed, id`, func(rows *sql.Rows) error {
+		var e FormHistoryEntry
+		var timesUsed, firstUsed, lastUsed sql.NullInt64
+		var guid sql.NullString
+		if err := rows.Scan(&e.ID, &e.FieldName, &e.Value, &timesUsed, &firstUsed, &lastUsed, &guid); err != nil {
+			return err
+		}
+		e.TimesUsed = int(timesUsed.Int64)
+		e.FirstUsed = timefmt.FromInt(firstUsed.Int64, 0, timefmt.Micro, timefmt.Unix)
+		e.LastUsed = timefmt.FromInt(lastUsed.Int64, 0, timefmt.Micro, timefmt.Unix)
+		e.GUID = guid.String
+		h.Entries = append(h.Entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firefox: form history: %w", err)
+	}
+
+	// moz_deleted_formhistory was added in Firefox 4.
+	if ok, err := sqliteutil.HasTable(db, "moz_deleted_formhistory"); err != nil || !ok {
+		return &h, err
+	}
+	err = sqliteutil.Query(db, `
+		SELECT id, timeDeleted, guid
+		FROM moz_deleted_formhistory
+		ORDER BY timeDeleted, id`, func(rows *sql.Rows) error {
+		var e DeletedFormHistoryEntry
+		var timeDeleted sql.NullInt64
+		var guid sql.NullString
+		if err := rows.Scan(&e.ID, &timeDeleted, &guid); err != nil {
+			return err
+		}
+		e.TimeDeleted = timefmt.FromInt(timeDeleted.Int64, 0, timefmt.Micro, timefmt.Unix)
+		e.GUID = guid.String
+		h.Deleted = append(h.Deleted, e)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firefox: form history: %w", err)
+	}
+	return &h, nil
+}
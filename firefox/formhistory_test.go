@@ -0,0 +1,51 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFormHistory(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "formhistory.sqlite")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE moz_formhistory (id INTEGER PRIMARY KEY, fieldname TEXT NOT NULL, value TEXT NOT NULL,
+			timesUsed INTEGER, firstUsed INTEGER, lastUsed INTEGER, guid TEXT);
+		CREATE TABLE moz_deleted_formhistory (id INTEGER PRIMARY KEY, timeDeleted INTEGER, guid TEXT);
+		INSERT INTO moz_formhistory VALUES
+			(1, 'searchbar-history', 'golang', 3, 1613610124000000, 1613610125000000, 'bbbbbbbbbbbb'),
+			(2, 'q', 'firefox', 1, 1613610123000000, 1613610123000000, 'aaaaaaaaaaaa');
+		INSERT INTO moz_deleted_formhistory VALUES (1, 1613610126000000, 'cccccccccccc');
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := ParseFormHistory(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &FormHistory{
+		Entries: []FormHistoryEntry{
+			{2, "q", "firefox", 1, time.Unix(1613610123, 0).UTC(), time.Unix(1613610123, 0).UTC(), "aaaaaaaaaaaa"},
+			{1, "searchbar-history", "golang", 3, time.Unix(1613610124, 0).UTC(), time.Unix(1613610125, 0).UTC(), "bbbbbbbbbbbb"},
+		},
+		Deleted: []DeletedFormHistoryEntry{{1, time.Unix(1613610126, 0).UTC(), "cccccccccccc"}},
+	}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", h, want)
+	}
+}
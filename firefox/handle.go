@@ -0,0 +1,324 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/bookmark"
+)
+
+// ProfileHandle exposes the files in a Firefox profile by what they
+// contain, rather than by file name. Each file is parsed on first use
+// and the result, or error, is cached until Close. It is safe for
+// concurrent use.
+//
+// A file that does not exist yields an error matching os.ErrNotExist.
+// Other errors are handled by the error policy of the Options that the
+// profile was opened with, and the data that was read is returned with
+// the collected errors.
+type ProfileHandle struct {
+	dir  string
+	opts browser.Options
+
+	mu     sync.Mutex
+	cache  map[string]*cachedFile
+	closed bool
+}
+
+type cachedFile struct {
+	once sync.Once
+	v    interface{}
+	err  error
+}
+
+// ErrProfileClosed is returned by a ProfileHandle after Close.
+var ErrProfileClosed = errors.New("firefox: profile closed")
+
+// OpenProfile opens a Firefox profile directory with the zero Options.
+func OpenProfile(profileDir string) (*ProfileHandle, error) {
+	return OpenProfileOptions(profileDir, browser.Options{})
+}
+
+// OpenProfileOptions opens a Firefox profile directory and handles
+// errors in its files by the error policy in opts.
+func OpenProfileOptions(profileDir string, opts browser.Options) (*ProfileHandle, error) {
+	fi, err := os.Stat(profileDir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("firefox: profile is not a directory: %s", profileDir)
+	}
+	return &ProfileHandle{dir: profileDir, opts: opts, cache: make(map[string]*cachedFile)}, nil
+}
+
+// Dir returns the path of the profile.
+func (p *ProfileHandle) Dir() string {
+	return p.dir
+}
+
+// Close releases the cached results. Later calls return
+// ErrProfileClosed.
+func (p *ProfileHandle) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.cache = nil
+	return nil
+}
+
+// load returns the cached result for key, calling parse on first use.
+func (p *ProfileHandle) load(key string, parse func(dir string) (interface{}, error)) (interface{}, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrProfileClosed
+	}
+	c, ok := p.cache[key]
+	if !ok {
+		c = &cachedFile{}
+		p.cache[key] = c
+	}
+	p.mu.Unlock()
+	c.once.Do(func() { c.v, c.err = parse(p.dir) })
+	return c.v, c.err
+}
+
+// loadFile returns the cached result of parsing a file in the profile,
+// keyed by its name. Other parsers of the same file use load.
+func (p *ProfileHandle) loadFile(name string, parse func(filename string) (interface{}, error)) (interface{}, error) {
+	return p.load(name, func(dir string) (interface{}, error) {
+		filename := filepath.Join(dir, name)
+		v, err := parse(filename)
+		return p.handleFile(filename, v, err)
+	})
+}
+
+// handleFile applies the error policy to the result of parsing a single
+// file. A file that does not exist is not handled.
+func (p *ProfileHandle) handleFile(filename string, v interface{}, err error) (interface{}, error) {
+	if err == nil || errors.Is(err, os.ErrNotExist) {
+		return v, err
+	}
+	h := p.opts.Handler()
+	if err := h.File(filename, err); err != nil {
+		return nil, err
+	}
+	return v, h.Err()
+}
+
+// Metadata returns the age and last version of the profile, from
+// times.json and compatibility.ini.
+func (p *ProfileHandle) Metadata() (*ProfileMetadata, error) {
+	v, err := p.load("metadata", func(dir string) (interface{}, error) { return ParseProfileMetadataOptions(dir, p.opts) })
+	x, _ := v.(*ProfileMetadata)
+	return x, err
+}
+
+// Prefs returns the preferences in prefs.js and user.js.
+func (p *ProfileHandle) Prefs() (Prefs, error) {
+	v, err := p.load("prefs.js", func(dir string) (interface{}, error) { return ProfilePrefsOptions(dir, p.opts) })
+	x, _ := v.(Prefs)
+	return x, err
+}
+
+// Extensions returns the installed add-ons in extensions.json.
+func (p *ProfileHandle) Extensions() (*Extensions, error) {
+	v, err := p.loadFile("extensions.json", func(f string) (interface{}, error) { return ParseExtensions(f) })
+	x, _ := v.(*Extensions)
+	return x, err
+}
+
+// ExtensionPreferences returns the permissions granted to extensions
+// in extension-preferences.json.
+func (p *ProfileHandle) ExtensionPreferences() (map[string]ExtensionPermissions, error) {
+	v, err := p.loadFile("extension-preferences.json", func(f string) (interface{}, error) { return ParseExtensionPreferences(f) })
+	x, _ := v.(map[string]ExtensionPermissions)
+	return x, err
+}
+
+// Addons returns the AMO metadata of add-ons in addons.json.
+func (p *ProfileHandle) Addons() (*Addons, error) {
+	v, err := p.loadFile("addons.json", func(f string) (interface{}, error) { return ParseAddons(f) })
+	x, _ := v.(*Addons)
+	return x, err
+}
+
+// Bookmarks returns the bookmarks in places.sqlite.
+func (p *ProfileHandle) Bookmarks() ([]bookmark.BookmarkEntry, error) {
+	v, err := p.loadFile("places.sqlite", func(f string) (interface{}, error) { return ParsePlacesBookmarks(f) })
+	x, _ := v.([]bookmark.BookmarkEntry)
+	return x, err
+}
+
+// History returns the history visits in places.sqlite.
+func (p *ProfileHandle) History() ([]Visit, error) {
+	v, err := p.load("history", func(dir string) (interface{}, error) {
+		filename := filepath.Join(dir, "places.sqlite")
+		v, err := ParsePlacesVisits(filename)
+		return p.handleFile(filename, v, err)
+	})
+	x, _ := v.([]Visit)
+	return x, err
+}
+
+// InputHistory returns the text typed in the address bar and the
+// results chosen for it, from places.sqlite.
+func (p *ProfileHandle) InputHistory() ([]InputHistory, error) {
+	v, err := p.load("input-history", func(dir string) (interface{}, error) {
+		filename := filepath.Join(dir, "places.sqlite")
+		v, err := ParseInputHistory(filename)
+		return p.handleFile(filename, v, err)
+	})
+	x, _ := v.([]InputHistory)
+	return x, err
+}
+
+// Cookies returns the cookies in cookies.sqlite.
+func (p *ProfileHandle) Cookies() ([]Cookie, error) {
+	v, err := p.loadFile(CookiesFile, func(f string) (interface{}, error) { return ParseCookies(f) })
+	x, _ := v.([]Cookie)
+	return x, err
+}
+
+// Downloads returns the downloads, from whichever of downloads.json,
+// downloads.sqlite, and places.sqlite the profile uses.
+func (p *ProfileHandle) Downloads() ([]Download, error) {
+	v, err := p.load("downloads", func(dir string) (interface{}, error) { return ProfileDownloadsOptions(dir, p.opts) })
+	x, _ := v.([]Download)
+	return x, err
+}
+
+// Session returns the most recent session, as chosen by SessionFiles.
+func (p *ProfileHandle) Session() (*Session, error) {
+	v, err := p.load("session", func(dir string) (interface{}, error) {
+		files, err := SessionFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("firefox: no session files: %w", os.ErrNotExist)
+		}
+		v, err := ParseSession(files[0].Path)
+		return p.handleFile(files[0].Path, v, err)
+	})
+	x, _ := v.(*Session)
+	return x, err
+}
+
+// Containers returns the contextual identities in containers.json.
+func (p *ProfileHandle) Containers() (*Containers, error) {
+	v, err := p.loadFile("containers.json", func(f string) (interface{}, error) { return ParseContainers(f) })
+	x, _ := v.(*Containers)
+	return x, err
+}
+
+// ContentPrefs returns the per-site preferences in content-prefs.sqlite.
+func (p *ProfileHandle) ContentPrefs() (*ContentPrefs, error) {
+	v, err := p.loadFile(ContentPrefsFile, func(f string) (interface{}, error) { return ParseContentPrefs(f) })
+	x, _ := v.(*ContentPrefs)
+	return x, err
+}
+
+// FormHistory returns the form history in formhistory.sqlite.
+func (p *ProfileHandle) FormHistory() (*FormHistory, error) {
+	v, err := p.loadFile("formhistory.sqlite", func(f string) (interface{}, error) { return ParseFormHistory(f) })
+	x, _ := v.(*FormHistory)
+	return x, err
+}
+
+// Handlers returns the protocol and content handlers in handlers.json.
+func (p *ProfileHandle) Handlers() (*Handlers, error) {
+	v, err := p.loadFile("handlers.json", func(f string) (interface{}, error) { return ParseHandlers(f) })
+	x, _ := v.(*Handlers)
+	return x, err
+}
+
+// Logins returns the encrypted saved logins in logins.json.
+func (p *ProfileHandle) Logins() (*Logins, error) {
+	v, err := p.loadFile("logins.json", func(f string) (interface{}, error) { return ParseLogins(f) })
+	x, _ := v.(*Logins)
+	return x, err
+}
+
+// Notifications returns the notifications in notificationstore.json.
+func (p *ProfileHandle) Notifications() (NotificationStore, error) {
+	v, err := p.loadFile("notificationstore.json", func(f string) (interface{}, error) { return ParseNotificationStore(f) })
+	x, _ := v.(NotificationStore)
+	return x, err
+}
+
+// Permissions returns the per-site permissions in permissions.sqlite.
+func (p *ProfileHandle) Permissions() ([]Permission, error) {
+	v, err := p.loadFile(PermissionsFile, func(f string) (interface{}, error) { return ParsePermissions(f) })
+	x, _ := v.([]Permission)
+	return x, err
+}
+
+// Protections returns the counts of blocked content in
+// protections.sqlite.
+func (p *ProfileHandle) Protections() ([]ProtectionEvent, error) {
+	v, err := p.loadFile("protections.sqlite", func(f string) (interface{}, error) { return ParseProtections(f) })
+	x, _ := v.([]ProtectionEvent)
+	return x, err
+}
+
+// SearchEngines returns the search engines in search.json.mozlz4.
+func (p *ProfileHandle) SearchEngines() (*SearchEngines, error) {
+	v, err := p.loadFile("search.json.mozlz4", func(f string) (interface{}, error) { return ParseSearchEngines(f) })
+	x, _ := v.(*SearchEngines)
+	return x, err
+}
+
+// ServiceWorkers returns the service worker registrations in
+// serviceworker.txt.
+func (p *ProfileHandle) ServiceWorkers() ([]ServiceWorker, error) {
+	v, err := p.loadFile("serviceworker.txt", func(f string) (interface{}, error) { return ParseServiceWorkers(f) })
+	x, _ := v.([]ServiceWorker)
+	return x, err
+}
+
+// SiteSecurity returns the HSTS and HPKP state of sites.
+func (p *ProfileHandle) SiteSecurity() ([]SiteSecurity, error) {
+	v, err := p.load("site-security", func(dir string) (interface{}, error) { return ProfileSiteSecurityOptions(dir, p.opts) })
+	x, _ := v.([]SiteSecurity)
+	return x, err
+}
+
+// SyncState returns the Firefox account and Sync state.
+func (p *ProfileHandle) SyncState() (*SyncState, error) {
+	v, err := p.load("sync", func(dir string) (interface{}, error) { return ProfileSyncStateOptions(dir, p.opts) })
+	x, _ := v.(*SyncState)
+	return x, err
+}
+
+// Telemetry returns the telemetry client state and saved pings.
+func (p *ProfileHandle) Telemetry() (*Telemetry, error) {
+	v, err := p.load("telemetry", func(dir string) (interface{}, error) { return ProfileTelemetryOptions(dir, p.opts) })
+	x, _ := v.(*Telemetry)
+	return x, err
+}
+
+// LocalStorage returns the localStorage items of sites.
+func (p *ProfileHandle) LocalStorage() ([]LocalStorageItem, error) {
+	v, err := p.load("local-storage", func(dir string) (interface{}, error) { return ProfileLocalStorageOptions(dir, p.opts) })
+	x, _ := v.([]LocalStorageItem)
+	return x, err
+}
+
+// XULStore returns the window and UI state in xulstore.json.
+func (p *ProfileHandle) XULStore() (*XULStore, error) {
+	v, err := p.loadFile("xulstore.json", func(f string) (interface{}, error) { return ParseXULStore(f) })
+	x, _ := v.(*XULStore)
+	return x, err
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser"
+)
+
+func TestProfileHandle(t *testing.T) {
+	dir := t.TempDir()
+	times := filepath.Join(dir, "times.json")
+	if err := os.WriteFile(times, []byte(`{"created": 1613610123000, "firstUse": null}`), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "prefs.js"), []byte(`user_pref("browser.startup.page", 3);`+"\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := OpenProfile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := p.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Unix(1613610123, 0).UTC(); !meta.Created.Equal(want) {
+		t.Errorf("got created %v, want %v", meta.Created, want)
+	}
+	prefs, err := p.Prefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := prefs["browser.startup.page"]; !ok {
+		t.Errorf("pref missing: %v", prefs)
+	}
+
+	// Results are cached, even when the file changes.
+	if err := os.Remove(times); err != nil {
+		t.Fatal(err)
+	}
+	meta2, err := p.Metadata()
+	if err != nil || meta2 != meta {
+		t.Errorf("got %v, %v, want cached result", meta2, err)
+	}
+
+	if _, err := p.Extensions(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v, want %v", err, os.ErrNotExist)
+	}
+	if _, err := p.Session(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v, want %v", err, os.ErrNotExist)
+	}
+	if _, err := p.History(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v, want %v", err, os.ErrNotExist)
+	}
+	if _, err := p.Cookies(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v, want %v", err, os.ErrNotExist)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Prefs(); err != ErrProfileClosed {
+		t.Errorf("got error %v, want %v", err, ErrProfileClosed)
+	}
+
+	if _, err := OpenProfile(times); err == nil {
+		t.Error("expected error for missing profile")
+	}
+
+	// Files that fail to parse are handled by the error policy.
+	containers := filepath.Join(dir, "containers.json")
+	if err := os.WriteFile(containers, []byte(`{"version": 4, "identities": [`), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	p, err = OpenProfile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs browser.Errors
+	if _, err := p.Containers(); !errors.As(err, &errs) || len(errs) != 1 || errs[0].File != containers {
+		t.Errorf("got error %v, want error for containers.json", err)
+	}
+	p, err = OpenProfileOptions(dir, browser.Options{OnError: browser.SkipFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, err := p.Containers(); c != nil || err != nil {
+		t.Errorf("got %v, %v, want skipped file", c, err)
+	}
+	if _, err := p.Extensions(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v, want %v", err, os.ErrNotExist)
+	}
+}
@@ -6,7 +6,18 @@
 
 package firefox
 
-import "github.com/andrewarchi/browser/jsonutil"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+// Handlers file format:
+// https://searchfox.org/mozilla-central/source/uriloader/exthandler/HandlerService.js
+// https://searchfox.org/mozilla-central/source/netwerk/mime/nsIMIMEInfo.idl
 
 // Handlers registers handlers for MIME types and URI schemes.
 type Handlers struct {
@@ -18,27 +29,46 @@ type Handlers struct {
 // MimeType registers an action to perform for a MIME type and assigns
 // file extensions to that MIME type.
 type MimeType struct {
-	Action     int      `json:"action"` // e.g. 0, 3
-	Ask        bool     `json:"ask,omitempty"`
-	Extensions []string `json:"extensions,omitempty"` // e.g. "jpg", "jpeg"
+	Action     HandlerAction    `json:"action"`
+	Ask        bool             `json:"ask,omitempty"`
+	Handlers   []*SchemeHandler `json:"handlers,omitempty"`   // first is the preferred handler; nil for the default
+	Extensions []string         `json:"extensions,omitempty"` // e.g. "jpg", "jpeg"
 }
 
 // Scheme registers an action to perform for a URI scheme and a list of
 // handler applications.
 type Scheme struct {
-	Action    int              `json:"action"` // e.g. 2, 4
+	Action    HandlerAction    `json:"action"`
 	Ask       bool             `json:"ask,omitempty"`
 	StubEntry bool             `json:"stubEntry,omitempty"` // true when handler unchanged from default
-	Handlers  []*SchemeHandler `json:"handlers,omitempty"`
+	Handlers  []*SchemeHandler `json:"handlers,omitempty"`  // first is the preferred handler; nil for the default
 }
 
-// SchemeHandler is an application that can handle a URI scheme.
+// SchemeHandler is an application that can handle a MIME type or URI
+// scheme.
 type SchemeHandler struct {
-	Name        string `json:"name"`
-	Path        string `json:"path,omitempty"`        // for local apps
-	URITemplate string `json:"uriTemplate,omitempty"` // for web apps
+	Name          string `json:"name"`
+	Path          string `json:"path,omitempty"`          // for local apps
+	URITemplate   string `json:"uriTemplate,omitempty"`   // for web apps, with %s for the URI
+	Service       string `json:"service,omitempty"`       // for D-Bus handlers
+	Method        string `json:"method,omitempty"`        // for D-Bus handlers
+	ObjectPath    string `json:"objectPath,omitempty"`    // for D-Bus handlers
+	DBusInterface string `json:"dBusInterface,omitempty"` // for D-Bus handlers
 }
 
+// HandlerAction is the action performed for a MIME type or URI scheme,
+// as in nsIHandlerInfo.
+type HandlerAction uint8
+
+// Values for HandlerAction:
+const (
+	ActionSaveToDisk       HandlerAction = 0
+	ActionAlwaysAsk        HandlerAction = 1 // obsolete; ask is used instead
+	ActionUseHelperApp     HandlerAction = 2
+	ActionHandleInternally HandlerAction = 3
+	ActionUseSystemDefault HandlerAction = 4
+)
+
 // ParseHandlers parses handlers.json in a Firefox profile.
 func ParseHandlers(filename string) (*Handlers, error) {
 	var handlers Handlers
@@ -47,3 +77,43 @@ func ParseHandlers(filename string) (*Handlers, error) {
 	}
 	return &handlers, nil
 }
+
+// WriteHandlers writes handlers in handlers.json format, which
+// ParseHandlers reads back unchanged. Like Firefox, it writes compact
+// JSON without HTML escaping. Keys are ordered by name.
+func WriteHandlers(w io.Writer, h *Handlers) error {
+	if err := writeJSONFile(w, h); err != nil {
+		return fmt.Errorf("firefox: handlers: %w", err)
+	}
+	return nil
+}
+
+// writeJSONFile writes v as compact JSON without HTML escaping or a
+// trailing newline, as JSONFile.jsm does.
+func writeJSONFile(w io.Writer, v interface{}) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := w.Write(bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}))
+	return err
+}
+
+func (a HandlerAction) String() string {
+	switch a {
+	case ActionSaveToDisk:
+		return "save_to_disk"
+	case ActionAlwaysAsk:
+		return "always_ask"
+	case ActionUseHelperApp:
+		return "use_helper_app"
+	case ActionHandleInternally:
+		return "handle_internally"
+	case ActionUseSystemDefault:
+		return "use_system_default"
+	default:
+		return fmt.Sprintf("action(%d)", uint8(a))
+	}
+}
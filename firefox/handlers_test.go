@@ -0,0 +1,54 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteHandlers(t *testing.T) {
+	h, err := ParseHandlers("testdata/corpus/handlers.json/firefox-85.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := h.Schemes["mailto"].Action; got != ActionUseSystemDefault {
+		t.Errorf("got mailto action %s, want %s", got, ActionUseSystemDefault)
+	}
+	h.MIMETypes["application/x-example"] = MimeType{
+		Action:     ActionUseHelperApp,
+		Handlers:   []*SchemeHandler{{Name: "Example", Path: "/usr/bin/example"}},
+		Extensions: []string{"example"},
+	}
+	h.Schemes["web+example"] = Scheme{
+		Action:   ActionUseHelperApp,
+		Ask:      true,
+		Handlers: []*SchemeHandler{nil, {Name: "Example", URITemplate: "https://example.com/?uri=%s&x=<y>"}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteHandlers(&buf, h); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("?uri=%s&x=<y>")) {
+		t.Errorf("URI template escaped:\n%s", buf.Bytes())
+	}
+	filename := filepath.Join(t.TempDir(), "handlers.json")
+	if err := os.WriteFile(filename, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseHandlers(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, h) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got, h)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Visit is a visit to a URL in moz_historyvisits in places.sqlite.
+type Visit struct {
+	ID        int64
+	URL       string
+	Title     string
+	VisitDate time.Time // zero when not recorded
+	VisitType VisitType
+	FromVisit int64 // ID of the referring visit, or 0
+}
+
+// VisitType is the transition of a visit in moz_historyvisits.
+type VisitType uint8
+
+// Values for VisitType:
+const (
+	VisitLink              VisitType = 1
+	VisitTyped             VisitType = 2
+	VisitBookmark          VisitType = 3
+	VisitEmbed             VisitType = 4 // not stored since Firefox 11
+	VisitRedirectPermanent VisitType = 5
+	VisitRedirectTemporary VisitType = 6
+	VisitDownload          VisitType = 7
+	VisitFramedLink        VisitType = 8
+	VisitReload            VisitType = 9
+)
+
+// ParsePlacesVisits parses the history visits in places.sqlite in a
+// Firefox profile, joining moz_historyvisits with moz_places. Visits
+// are ordered by date, then ID.
+func ParsePlacesVisits(filename string) ([]Visit, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var visits []Visit
+	err = sqliteutil.Query(db, `
+		SELECT v.id, p.url, p.title, v.visit_date, v.visit_type, v.from_visit
+		FROM moz_historyvisits v JOIN moz_places p ON p.id = v.place_id
+		ORDER BY v.visit_date, v.id`, func(rows *sql.Rows) error {
+		var v Visit
+		var title sql.NullString
+		var date, visitType, from sql.NullInt64
+		if err := rows.Scan(&v.ID, &v.URL, &title, &date, &visitType, &from); err != nil {
+			return err
+		}
+		if date.Int64 < 0 {
+			return fmt.Errorf("visit %d: negative visit date", v.ID)
+		}
+		v.Title = title.String
+		if date.Valid {
+			v.VisitDate = timefmt.FromInt(date.Int64, 0, timefmt.Micro, timefmt.Unix)
+		}
+		v.VisitType = VisitType(visitType.Int64)
+		v.FromVisit = from.Int64
+		visits = append(visits, v)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firefox: places visits: %w", err)
+	}
+	return visits, nil
+}
+
+func (t VisitType) String() string {
+	switch t {
+	case VisitLink:
+		return "link"
+	case VisitTyped:
+		return "typed"
+	case VisitBookmark:
+		return "bookmark"
+	case VisitEmbed:
+		return "embed"
+	case VisitRedirectPermanent:
+		return "redirect_permanent"
+	case VisitRedirectTemporary:
+		return "redirect_temporary"
+	case VisitDownload:
+		return "download"
+	case VisitFramedLink:
+		return "framed_link"
+	case VisitReload:
+		return "reload"
+	default:
+		return fmt.Sprintf("visittype(%d)", uint8(t))
+	}
+}
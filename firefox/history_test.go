@@ -0,0 +1,60 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlacesVisits(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "places.sqlite")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`
+		CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR,
+			visit_count INTEGER DEFAULT 0, hidden INTEGER DEFAULT 0 NOT NULL, typed INTEGER DEFAULT 0 NOT NULL,
+			last_visit_date INTEGER);
+		CREATE TABLE moz_historyvisits (id INTEGER PRIMARY KEY, from_visit INTEGER, place_id INTEGER,
+			visit_date INTEGER, visit_type INTEGER, session INTEGER);
+		INSERT INTO moz_places VALUES
+			(1, 'https://example.com/', 'Example', 2, 0, 1, 1613613600000000),
+			(2, 'https://example.com/page', NULL, 1, 0, 0, 1613610100000000);
+		INSERT INTO moz_historyvisits VALUES
+			(1, 0, 1, 1613610000000000, 2, 0),
+			(3, 0, 1, 1613613600000000, 9, 0),
+			(2, 1, 2, 1613610100000000, 1, 0);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	visits, err := ParsePlacesVisits(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Visit{
+		{1, "https://example.com/", "Example", time.Unix(1613610000, 0).UTC(), VisitTyped, 0},
+		{2, "https://example.com/page", "", time.Unix(1613610100, 0).UTC(), VisitLink, 1},
+		{3, "https://example.com/", "Example", time.Unix(1613613600, 0).UTC(), VisitReload, 0},
+	}
+	if !reflect.DeepEqual(visits, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", visits, want)
+	}
+
+	if _, err := db.Exec(`INSERT INTO moz_historyvisits VALUES (4, 0, 2, -1, 1, 0)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParsePlacesVisits(filename); err == nil {
+		t.Error("expected error for negative visit date")
+	}
+}
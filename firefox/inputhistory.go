@@ -0,0 +1,196 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// InputHistory is a row in moz_inputhistory, which maps text typed in
+// the address bar to the result that was chosen for it, to rank that
+// result first when the text is typed again.
+type InputHistory struct {
+	Input    string // typed text, lowercased
+	URL      string
+	Title    string
+	UseCount float64 // decays daily
+}
+
+// PlacesKeyword is a row in moz_keywords, a keyword assigned to a
+// bookmark to open its URL from the address bar, with "%s" substituted
+// by the text after the keyword.
+type PlacesKeyword struct {
+	Keyword  string
+	URL      string
+	PostData string // form data to POST, when not a GET URL
+}
+
+// PlacesInput summarizes how the user reached a URL: by input in the
+// address bar or by clicking, such as on a link or bookmark. It
+// corresponds to chrome.URLInput.
+type PlacesInput struct {
+	PlaceID       int64
+	URL           string
+	Title         string
+	Typed         bool     // typed flag of moz_places, which outlives expired visits
+	Inputs        []string // typed text for which the URL was chosen, from moz_inputhistory
+	TypedVisits   int
+	ClickedVisits int       // link and bookmark visits
+	LastTyped     time.Time // zero when no visits are typed
+	LastVisit     time.Time
+	Hidden        bool
+}
+
+// ParseInputHistory parses moz_inputhistory in places.sqlite in a
+// Firefox profile, ordered by input, then by use count, descending.
+func ParseInputHistory(filename string) ([]InputHistory, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var history []InputHistory
+	err = sqliteutil.Query(db, `
+		SELECT i.input, p.url, p.title, i.use_count
+		FROM moz_inputhistory i JOIN moz_places p ON p.id = i.place_id
+		ORDER BY i.input, i.use_count DESC, p.url`, func(rows *sql.Rows) error {
+		var h InputHistory
+		var title sql.NullString
+		if err := rows.Scan(&h.Input, &h.URL, &title, &h.UseCount); err != nil {
+			return err
+		}
+		h.Title = title.String
+		history = append(history, h)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firefox: input history: %w", err)
+	}
+	return history, nil
+}
+
+// ParsePlacesKeywords parses moz_keywords in places.sqlite in a Firefox
+// profile, ordered by keyword.
+func ParsePlacesKeywords(filename string) ([]PlacesKeyword, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var keywords []PlacesKeyword
+	err = sqliteutil.Query(db, `
+		SELECT k.keyword, p.url, k.post_data
+		FROM moz_keywords k JOIN moz_places p ON p.id = k.place_id
+		ORDER BY k.keyword`, func(rows *sql.Rows) error {
+		var k PlacesKeyword
+		var postData sql.NullString
+		if err := rows.Scan(&k.Keyword, &k.URL, &postData); err != nil {
+			return err
+		}
+		k.PostData = postData.String
+		keywords = append(keywords, k)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firefox: keywords: %w", err)
+	}
+	return keywords, nil
+}
+
+// AnalyzePlacesInput partitions the URLs in places.sqlite into those
+// that the user typed, having the typed flag, a typed visit, or input
+// history, and those that were only clicked, having a link or bookmark
+// visit. Hidden URLs that were not typed, like framed pages, are
+// omitted. Typed URLs are ordered by typed visits, then URL, and
+// clicked URLs by clicked visits, then URL, as with
+// chrome.AnalyzeInput.
+func AnalyzePlacesInput(filename string) (typed, clicked []PlacesInput, err error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer db.Close()
+	var inputs []PlacesInput
+	index := make(map[int64]int)
+	err = sqliteutil.Query(db, `
+		SELECT p.id, p.url, p.title, p.typed, p.hidden, p.last_visit_date,
+			count(CASE WHEN v.visit_type = ?1 THEN 1 END),
+			count(CASE WHEN v.visit_type IN (?2, ?3) THEN 1 END),
+			max(CASE WHEN v.visit_type = ?1 THEN v.visit_date END)
+		FROM moz_places p LEFT JOIN moz_historyvisits v ON v.place_id = p.id
+		GROUP BY p.id
+		ORDER BY p.id`, func(rows *sql.Rows) error {
+		var in PlacesInput
+		var title sql.NullString
+		var lastVisit, lastTyped sql.NullInt64
+		if err := rows.Scan(&in.PlaceID, &in.URL, &title, &in.Typed, &in.Hidden, &lastVisit,
+			&in.TypedVisits, &in.ClickedVisits, &lastTyped); err != nil {
+			return err
+		}
+		in.Title = title.String
+		if lastVisit.Int64 > 0 {
+			in.LastVisit = timefmt.FromInt(lastVisit.Int64, 0, timefmt.Micro, timefmt.Unix)
+		}
+		if lastTyped.Int64 > 0 {
+			in.LastTyped = timefmt.FromInt(lastTyped.Int64, 0, timefmt.Micro, timefmt.Unix)
+		}
+		index[in.PlaceID] = len(inputs)
+		inputs = append(inputs, in)
+		return nil
+	}, VisitTyped, VisitLink, VisitBookmark)
+	if err != nil {
+		return nil, nil, fmt.Errorf("firefox: places input: %w", err)
+	}
+	if ok, err := sqliteutil.HasTable(db, "moz_inputhistory"); err != nil {
+		return nil, nil, err
+	} else if ok {
+		err = sqliteutil.Query(db, `
+			SELECT place_id, input FROM moz_inputhistory
+			ORDER BY place_id, use_count DESC, input`, func(rows *sql.Rows) error {
+			var id int64
+			var input string
+			if err := rows.Scan(&id, &input); err != nil {
+				return err
+			}
+			if i, ok := index[id]; ok {
+				inputs[i].Inputs = append(inputs[i].Inputs, input)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("firefox: input history: %w", err)
+		}
+	}
+
+	for _, in := range inputs {
+		switch {
+		case in.Typed || in.TypedVisits != 0 || len(in.Inputs) != 0:
+			typed = append(typed, in)
+		case !in.Hidden && in.ClickedVisits != 0:
+			clicked = append(clicked, in)
+		}
+	}
+	sort.SliceStable(typed, func(i, j int) bool {
+		if typed[i].TypedVisits != typed[j].TypedVisits {
+			return typed[i].TypedVisits > typed[j].TypedVisits
+		}
+		return typed[i].URL < typed[j].URL
+	})
+	sort.SliceStable(clicked, func(i, j int) bool {
+		if clicked[i].ClickedVisits != clicked[j].ClickedVisits {
+			return clicked[i].ClickedVisits > clicked[j].ClickedVisits
+		}
+		return clicked[i].URL < clicked[j].URL
+	})
+	return typed, clicked, nil
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlacesInput(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "places.sqlite")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`
+		CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR,
+			visit_count INTEGER DEFAULT 0, hidden INTEGER DEFAULT 0 NOT NULL, typed INTEGER DEFAULT 0 NOT NULL,
+			last_visit_date INTEGER);
+		CREATE TABLE moz_historyvisits (id INTEGER PRIMARY KEY, from_visit INTEGER, place_id INTEGER,
+			visit_date INTEGER, visit_type INTEGER, session INTEGER);
+		CREATE TABLE moz_inputhistory (place_id INTEGER NOT NULL, input LONGVARCHAR NOT NULL,
+			use_count INTEGER, PRIMARY KEY (place_id, input));
+		CREATE TABLE moz_keywords (id INTEGER PRIMARY KEY, keyword TEXT UNIQUE, place_id INTEGER, post_data TEXT);
+		INSERT INTO moz_places VALUES
+			(1, 'https://example.com/', 'Example', 2, 0, 1, 1613613600000000),
+			(2, 'https://example.com/page', 'Page', 2, 0, 0, 1613617200000000),
+			(3, 'https://ads.example.com/frame', NULL, 1, 1, 0, 1613613600000000),
+			(4, 'https://wiki.example.org/', 'Wiki', 0, 0, 0, NULL),
+			(5, 'https://search.example/?q=%s', 'Search', 0, 0, 0, NULL);
+		INSERT INTO moz_historyvisits VALUES
+			(1, 0, 1, 1613610000000000, 2, 0),
+			(2, 1, 2, 1613610100000000, 1, 0),
+			(3, 2, 3, 1613610100000000, 8, 0),
+			(4, 0, 1, 1613613600000000, 2, 0),
+			(5, 0, 2, 1613617200000000, 3, 0);
+		INSERT INTO moz_inputhistory VALUES (4, 'wi', 1.5), (4, 'wiki', 0.8), (1, 'ex', 2);
+		INSERT INTO moz_keywords VALUES (1, 's', 5, NULL), (2, 'p', 2, 'q=%s');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := ParseInputHistory(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHistory := []InputHistory{
+		{"ex", "https://example.com/", "Example", 2},
+		{"wi", "https://wiki.example.org/", "Wiki", 1.5},
+		{"wiki", "https://wiki.example.org/", "Wiki", 0.8},
+	}
+	if !reflect.DeepEqual(history, wantHistory) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", history, wantHistory)
+	}
+
+	keywords, err := ParsePlacesKeywords(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantKeywords := []PlacesKeyword{
+		{"p", "https://example.com/page", "q=%s"},
+		{"s", "https://search.example/?q=%s", ""},
+	}
+	if !reflect.DeepEqual(keywords, wantKeywords) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", keywords, wantKeywords)
+	}
+
+	typed, clicked, err := AnalyzePlacesInput(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usec := func(us int64) time.Time { return time.Unix(0, us*1e3).UTC() }
+	wantTyped := []PlacesInput{
+		{PlaceID: 1, URL: "https://example.com/", Title: "Example", Typed: true, Inputs: []string{"ex"},
+			TypedVisits: 2, LastTyped: usec(1613613600000000), LastVisit: usec(1613613600000000)},
+		{PlaceID: 4, URL: "https://wiki.example.org/", Title: "Wiki", Inputs: []string{"wi", "wiki"}},
+	}
+	wantClicked := []PlacesInput{
+		{PlaceID: 2, URL: "https://example.com/page", Title: "Page", ClickedVisits: 2, LastVisit: usec(1613617200000000)},
+	}
+	if !reflect.DeepEqual(typed, wantTyped) {
+		t.Errorf("got typed:\n%+v\nwant:\n%+v", typed, wantTyped)
+	}
+	if !reflect.DeepEqual(clicked, wantClicked) {
+		t.Errorf("got clicked:\n%+v\nwant:\n%+v", clicked, wantClicked)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/andrewarchi/browser"
+)
+
+// ProfileFile is a top-level file or directory in a Firefox profile.
+type ProfileFile struct {
+	Name  string
+	Dir   bool
+	Size  int64 // zero for directories
+	Known bool  // parsed by this package
+}
+
+// knownProfileFiles are the patterns, in filepath.Match syntax, of the
+// top-level files and directories in a profile that are parsed by this
+// package.
+var knownProfileFiles = []string{
+	"SiteSecurityServiceState.txt",
+	"addonStartup.json.lz4",
+	"addons.json",
+	"autofill-profiles.json",
+	"blocklist-addons.json",
+	"blocklist.xml",
+	"bookmarkbackups",
+	"broadcast-listeners.json",
+	"cert_override.txt",
+	"compatibility.ini",
+	"containers.json",
+	"content-prefs.sqlite",
+	"datareporting",
+	"downloads.json",
+	"downloads.sqlite",
+	"enumerate_devices.txt",
+	"extension-preferences.json",
+	"extension-settings.json",
+	"extensions.json",
+	"favicons.sqlite",
+	"formhistory.sqlite",
+	"handlers.json",
+	"key4.db",
+	"logins.json",
+	"notificationstore.json",
+	"permissions.sqlite",
+	"places.sqlite",
+	"prefs.js",
+	"protections.sqlite",
+	"saved-telemetry-pings",
+	"search.json.mozlz4",
+	"serviceworker.txt",
+	"sessionstore-backups",
+	"sessionstore.js",
+	"sessionstore.jsonlz4",
+	"shield-preference-experiments.json",
+	"signedInUser.json",
+	"site_security_service_state.bin",
+	"storage",
+	"storage-sync-v2.sqlite",
+	"storage.sqlite",
+	"times.json",
+	"user.js",
+	"weave",
+	"webappsstore.sqlite",
+	"xulstore.json",
+}
+
+// InventoryProfile lists the top-level files and directories in a
+// Firefox profile, ordered by name, and reports which are parsed by
+// this package. Files that fail to stat are handled as by
+// InventoryProfileOptions with the zero Options.
+func InventoryProfile(profileDir string) ([]ProfileFile, error) {
+	return InventoryProfileOptions(profileDir, browser.Options{})
+}
+
+// InventoryProfileOptions lists the top-level files and directories in
+// a Firefox profile and handles files that fail to stat by the error
+// policy in opts.
+func InventoryProfileOptions(profileDir string, opts browser.Options) ([]ProfileFile, error) {
+	h := opts.Handler()
+	entries, err := os.ReadDir(profileDir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]ProfileFile, 0, len(entries))
+	for _, e := range entries {
+		f := ProfileFile{Name: e.Name(), Dir: e.IsDir()}
+		if !f.Dir {
+			fi, err := e.Info()
+			if err != nil {
+				if err := h.File(filepath.Join(profileDir, e.Name()), err); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			f.Size = fi.Size()
+		}
+		for _, pattern := range knownProfileFiles {
+			if ok, _ := filepath.Match(pattern, f.Name); ok {
+				f.Known = true
+				break
+			}
+		}
+		files = append(files, f)
+	}
+	return files, h.Err()
+}
@@ -0,0 +1,358 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+
+	"github.com/andrewarchi/browser/secret"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// NSS key database format:
+// https://searchfox.org/mozilla-central/source/security/nss/lib/softoken/sdb.c
+// https://searchfox.org/mozilla-central/source/security/nss/lib/softoken/lowpbe.c
+// https://searchfox.org/mozilla-central/source/security/nss/lib/pk11wrap/pk11sdr.c
+//
+// key4.db is an SQLite database. The "password" row of the metaData
+// table contains the global salt (item1) and the encryption of
+// "password-check" (item2), which verifies the primary password. The
+// nssPrivate table contains the encrypted keys (a11) by key ID (a102).
+// Both are encrypted with a key derived from the global salt and the
+// primary password, which is empty when not set. Older versions use
+// PKCS#12 PBE with SHA-1 and 3DES and newer versions use PBES2 with
+// PBKDF2-HMAC-SHA256 and AES-256.
+//
+// Logins are encrypted by the Secret Decoder Ring (SDR) with the key
+// from nssPrivate matching the key ID in each value.
+
+// ErrPrimaryPassword is returned when the primary password for a key
+// database is incorrect.
+var ErrPrimaryPassword = errors.New("firefox: incorrect primary password")
+
+// KeyDB holds the decrypted keys from key4.db, which are used to
+// decrypt saved logins.
+type KeyDB struct {
+	keys map[string][]byte // key: key ID
+}
+
+var (
+	oidPBESHA1TripleDESCBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 5, 1, 3}
+	oidPBES2               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1        = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256      = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC           = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC          = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+// pbeEncrypted is a value encrypted with a password-based encryption
+// scheme.
+type pbeEncrypted struct {
+	Algorithm  pkix.AlgorithmIdentifier
+	Ciphertext []byte
+}
+
+// pbeSHA1Params are the parameters of pbeWithSHA1AndTripleDES-CBC.
+type pbeSHA1Params struct {
+	Salt       []byte
+	Iterations int
+}
+
+// pbes2Params are the parameters of PBES2 from PKCS #5.
+type pbes2Params struct {
+	KDF    pkix.AlgorithmIdentifier
+	Cipher pkix.AlgorithmIdentifier
+}
+
+// pbkdf2Params are the parameters of PBKDF2 from PKCS #5.
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// sdrEncrypted is a value encrypted by the Secret Decoder Ring.
+type sdrEncrypted struct {
+	KeyID      []byte
+	Algorithm  pkix.AlgorithmIdentifier // parameters are the IV
+	Ciphertext []byte
+}
+
+// UnlockKeyDB reads key4.db in a Firefox profile and decrypts its keys
+// with the primary password, which is empty when not set. The database
+// may be locked while Firefox is running. The legacy key3.db, which is
+// a Berkeley DB database, is not supported.
+func UnlockKeyDB(filename, primaryPassword string) (*KeyDB, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var globalSalt, check []byte
+	if err := db.QueryRow(`SELECT item1, item2 FROM metaData WHERE id = 'password'`).Scan(&globalSalt, &check); err != nil {
+		return nil, fmt.Errorf("firefox: key db: %w", err)
+	}
+	password := []byte(primaryPassword)
+	plain, err := decryptPBE(check, globalSalt, password)
+	if err != nil {
+		return nil, fmt.Errorf("firefox: key db: password check: %w", err)
+	}
+	// An incorrect password yields garbage, rather than an error.
+	if plain, err = unpad(plain); err != nil || !bytes.Equal(plain, []byte("password-check")) {
+		return nil, ErrPrimaryPassword
+	}
+
+	k := &KeyDB{keys: make(map[string][]byte)}
+	err = sqliteutil.Query(db, `SELECT a11, a102 FROM nssPrivate`, func(rows *sql.Rows) error {
+		var encrypted, id []byte
+		if err := rows.Scan(&encrypted, &id); err != nil {
+			return err
+		}
+		key, err := decryptPBE(encrypted, globalSalt, password)
+		if err != nil {
+			return fmt.Errorf("key %x: %w", id, err)
+		}
+		if unpadded, err := unpad(key); err == nil {
+			key = unpadded
+		}
+		k.keys[string(id)] = key
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firefox: key db: %w", err)
+	}
+	return k, nil
+}
+
+// Decrypt decrypts a base64-encoded value encrypted by the Secret
+// Decoder Ring, such as Login.EncryptedPassword.
+func (k *KeyDB) Decrypt(encrypted string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("firefox: decrypt: %w", err)
+	}
+	var v sdrEncrypted
+	if err := unmarshalDER(b, &v); err != nil {
+		return "", fmt.Errorf("firefox: decrypt: %w", err)
+	}
+	key, ok := k.keys[string(v.KeyID)]
+	if !ok {
+		return "", fmt.Errorf("firefox: decrypt: no key with ID %x", v.KeyID)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(v.Algorithm.Parameters.FullBytes, &iv); err != nil {
+		return "", fmt.Errorf("firefox: decrypt: IV: %w", err)
+	}
+	var plain []byte
+	switch alg := v.Algorithm.Algorithm; {
+	case alg.Equal(oidDESEDE3CBC):
+		if len(key) < 24 {
+			return "", errors.New("firefox: decrypt: 3DES key too short")
+		}
+		plain, err = decryptCBC(des.NewTripleDESCipher, key[:24], iv, v.Ciphertext)
+	case alg.Equal(oidAES256CBC):
+		if len(key) < 32 {
+			return "", errors.New("firefox: decrypt: AES key too short")
+		}
+		plain, err = decryptCBC(aes.NewCipher, key[:32], iv, v.Ciphertext)
+	default:
+		return "", fmt.Errorf("firefox: decrypt: unsupported algorithm %s", alg)
+	}
+	if err == nil {
+		plain, err = unpad(plain)
+	}
+	if err != nil {
+		return "", fmt.Errorf("firefox: decrypt: %w", err)
+	}
+	return string(plain), nil
+}
+
+// DecryptLogin decrypts the username and password of a login.
+func (k *KeyDB) DecryptLogin(l *Login) (username string, password secret.Secret, err error) {
+	decrypt := k.Decrypt
+	switch l.EncType {
+	case EncTypeSDR:
+	case EncTypeBase64:
+		decrypt = func(s string) (string, error) {
+			b, err := base64.StdEncoding.DecodeString(s)
+			return string(b), err
+		}
+	default:
+		return "", "", fmt.Errorf("firefox: login %d: unsupported encryption type %d", l.ID, l.EncType)
+	}
+	if username, err = decrypt(l.EncryptedUsername); err != nil {
+		return "", "", fmt.Errorf("firefox: login %d: username: %w", l.ID, err)
+	}
+	plain, err := decrypt(l.EncryptedPassword)
+	if err != nil {
+		return "", "", fmt.Errorf("firefox: login %d: password: %w", l.ID, err)
+	}
+	return username, secret.Secret(plain), nil
+}
+
+// decryptPBE decrypts a value in key4.db. The plaintext is returned
+// with its padding.
+func decryptPBE(b, globalSalt, password []byte) ([]byte, error) {
+	var v pbeEncrypted
+	if err := unmarshalDER(b, &v); err != nil {
+		return nil, err
+	}
+	params := v.Algorithm.Parameters.FullBytes
+	switch alg := v.Algorithm.Algorithm; {
+	case alg.Equal(oidPBESHA1TripleDESCBC):
+		var p pbeSHA1Params
+		if err := unmarshalDER(params, &p); err != nil {
+			return nil, err
+		}
+		key, iv := nssPBEKey(globalSalt, password, p.Salt)
+		return decryptCBC(des.NewTripleDESCipher, key, iv, v.Ciphertext)
+
+	case alg.Equal(oidPBES2):
+		var p pbes2Params
+		if err := unmarshalDER(params, &p); err != nil {
+			return nil, err
+		}
+		if !p.KDF.Algorithm.Equal(oidPBKDF2) {
+			return nil, fmt.Errorf("unsupported key derivation function %s", p.KDF.Algorithm)
+		}
+		var kdf pbkdf2Params
+		if err := unmarshalDER(p.KDF.Parameters.FullBytes, &kdf); err != nil {
+			return nil, err
+		}
+		prf := sha1.New
+		if kdf.PRF.Algorithm.Equal(oidHMACWithSHA256) {
+			prf = sha256.New
+		} else if len(kdf.PRF.Algorithm) != 0 && !kdf.PRF.Algorithm.Equal(oidHMACWithSHA1) {
+			return nil, fmt.Errorf("unsupported PRF %s", kdf.PRF.Algorithm)
+		}
+		if !p.Cipher.Algorithm.Equal(oidAES256CBC) {
+			return nil, fmt.Errorf("unsupported cipher %s", p.Cipher.Algorithm)
+		}
+		keyLen := kdf.KeyLength
+		if keyLen == 0 {
+			keyLen = 32
+		}
+		// The password is hashed with the global salt before key
+		// derivation.
+		h := sha1.Sum(append(append([]byte{}, globalSalt...), password...))
+		key := pbkdf2(prf, h[:], kdf.Salt, kdf.Iterations, keyLen)
+		// NSS uses the DER encoding of the IV, which is an octet string
+		// of 14 bytes, as the IV of 16 bytes.
+		iv := p.Cipher.Parameters.FullBytes
+		return decryptCBC(aes.NewCipher, key, iv, v.Ciphertext)
+
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %s", alg)
+	}
+}
+
+// nssPBEKey derives the 3DES key and IV for pbeWithSHA1AndTripleDES-CBC
+// as NSS does, which differs from PKCS #12.
+func nssPBEKey(globalSalt, password, entrySalt []byte) (key, iv []byte) {
+	hp := sha1.Sum(append(append([]byte{}, globalSalt...), password...))
+	pes := make([]byte, 20)
+	copy(pes, entrySalt)
+	chp := sha1.Sum(append(hp[:], entrySalt...))
+	mac := func(data ...[]byte) []byte {
+		m := hmac.New(sha1.New, chp[:])
+		for _, d := range data {
+			m.Write(d)
+		}
+		return m.Sum(nil)
+	}
+	k1 := mac(pes, entrySalt)
+	tk := mac(pes)
+	k2 := mac(tk, entrySalt)
+	k := append(k1, k2...)
+	return k[:24], k[len(k)-8:]
+}
+
+// pbkdf2 derives a key with PBKDF2 from RFC 8018.
+func pbkdf2(prf func() hash.Hash, password, salt []byte, iterations, keyLen int) []byte {
+	m := hmac.New(prf, password)
+	var key []byte
+	var block [4]byte
+	for i := uint32(1); len(key) < keyLen; i++ {
+		m.Reset()
+		m.Write(salt)
+		binary.BigEndian.PutUint32(block[:], i)
+		m.Write(block[:])
+		u := m.Sum(nil)
+		t := append([]byte{}, u...)
+		for n := 1; n < iterations; n++ {
+			m.Reset()
+			m.Write(u)
+			u = m.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+func decryptCBC(newCipher func(key []byte) (cipher.Block, error), key, iv, ciphertext []byte) ([]byte, error) {
+	block, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, fmt.Errorf("IV has length %d, want %d", len(iv), block.BlockSize())
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%block.BlockSize() != 0 {
+		return nil, errors.New("ciphertext is not a multiple of the block size")
+	}
+	plain := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, ciphertext)
+	return plain, nil
+}
+
+// unpad removes PKCS #7 padding.
+func unpad(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, errors.New("invalid padding")
+	}
+	n := int(b[len(b)-1])
+	if n == 0 || n > aes.BlockSize || n > len(b) {
+		return nil, errors.New("invalid padding")
+	}
+	for _, c := range b[len(b)-n:] {
+		if int(c) != n {
+			return nil, errors.New("invalid padding")
+		}
+	}
+	return b[:len(b)-n], nil
+}
+
+func unmarshalDER(b []byte, v interface{}) error {
+	rest, err := asn1.Unmarshal(b, v)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errors.New("trailing data after ASN.1 value")
+	}
+	return nil
+}
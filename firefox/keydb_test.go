@@ -0,0 +1,164 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestPBKDF2(t *testing.T) {
+	// RFC 7914, section 11.
+	got := pbkdf2(sha256.New, []byte("passwd"), []byte("salt"), 1, 64)
+	want := []byte{
+		0x55, 0xac, 0x04, 0x6e, 0x56, 0xe3, 0x08, 0x9f, 0xec, 0x16, 0x91, 0xc2, 0x25, 0x44, 0xb6, 0x05,
+		0xf9, 0x41, 0x85, 0x21, 0x6d, 0xde, 0x04, 0x65, 0xe6, 0x8b, 0x9d, 0x57, 0xc2, 0x0d, 0xac, 0xbc,
+		0x49, 0xca, 0x9c, 0xcc, 0xf1, 0x79, 0xb6, 0x45, 0x99, 0x16, 0x64, 0xb3, 0x9d, 0x77, 0xef, 0x31,
+		0x7c, 0x71, 0xb8, 0x45, 0xb1, 0xe3, 0x0b, 0xd5, 0x09, 0x11, 0x20, 0x41, 0xd3, 0xa1, 0x97, 0x83,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got: %x, want: %x", got, want)
+	}
+}
+
+var testKeyID = []byte("\xf8\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01")
+
+func TestUnlockKeyDB(t *testing.T) {
+	globalSalt := []byte("0123456789abcdefghij")
+	key := []byte("0123456789abcdefghijklmn") // 3DES key
+	for _, pbes2 := range []bool{false, true} {
+		filename := filepath.Join(t.TempDir(), "key4.db")
+		db, err := sql.Open("sqlite3", filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encrypt := encryptPBESHA1
+		if pbes2 {
+			encrypt = encryptPBES2
+		}
+		for _, stmt := range []string{
+			`CREATE TABLE metaData (id PRIMARY KEY UNIQUE ON CONFLICT REPLACE, item1, item2)`,
+			`CREATE TABLE nssPrivate (id PRIMARY KEY UNIQUE ON CONFLICT ABORT, a11, a102)`,
+		} {
+			if _, err := db.Exec(stmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := db.Exec(`INSERT INTO metaData VALUES ('password', ?, ?)`,
+			globalSalt, encrypt(t, globalSalt, []byte("secret"), []byte("password-check"))); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(`INSERT INTO nssPrivate VALUES (1, ?, ?)`,
+			encrypt(t, globalSalt, []byte("secret"), key), testKeyID); err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+
+		if _, err := UnlockKeyDB(filename, "wrong"); !errors.Is(err, ErrPrimaryPassword) {
+			t.Errorf("pbes2=%t: got error %v for wrong password", pbes2, err)
+		}
+		k, err := UnlockKeyDB(filename, "secret")
+		if err != nil {
+			t.Fatalf("pbes2=%t: %v", pbes2, err)
+		}
+		login := Login{
+			ID:                1,
+			EncryptedUsername: encryptSDR(t, key, "someone@example.com"),
+			EncryptedPassword: encryptSDR(t, key, "hunter2"),
+			EncType:           EncTypeSDR,
+		}
+		username, password, err := k.DecryptLogin(&login)
+		if err != nil {
+			t.Fatalf("pbes2=%t: %v", pbes2, err)
+		}
+		if username != "someone@example.com" || password.Reveal() != "hunter2" {
+			t.Errorf("pbes2=%t: got username %q and password %q", pbes2, username, password.Reveal())
+		}
+	}
+}
+
+func pad(b []byte, blockSize int) []byte {
+	n := blockSize - len(b)%blockSize
+	return append(append([]byte{}, b...), bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+func encryptCBC(t *testing.T, block cipher.Block, iv, plain []byte) []byte {
+	plain = pad(plain, block.BlockSize())
+	ciphertext := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plain)
+	return ciphertext
+}
+
+func marshal(t *testing.T, v interface{}) []byte {
+	b, err := asn1.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func encryptPBESHA1(t *testing.T, globalSalt, password, plain []byte) []byte {
+	entrySalt := []byte("entrysaltentrysalt..")
+	key, iv := nssPBEKey(globalSalt, password, entrySalt)
+	block, err := des.NewTripleDESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return marshal(t, pbeEncrypted{
+		Algorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPBESHA1TripleDESCBC,
+			Parameters: asn1.RawValue{FullBytes: marshal(t, pbeSHA1Params{entrySalt, 1})},
+		},
+		Ciphertext: encryptCBC(t, block, iv, plain),
+	})
+}
+
+func encryptPBES2(t *testing.T, globalSalt, password, plain []byte) []byte {
+	salt := bytes.Repeat([]byte{0x5a}, 32)
+	h := sha1.Sum(append(append([]byte{}, globalSalt...), password...))
+	key := pbkdf2(sha256.New, h[:], salt, 10000, 32)
+	ivParam := marshal(t, []byte("fourteen bytes"))
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kdf := pbkdf2Params{salt, 10000, 32, pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue}}
+	return marshal(t, pbeEncrypted{
+		Algorithm: pkix.AlgorithmIdentifier{
+			Algorithm: oidPBES2,
+			Parameters: asn1.RawValue{FullBytes: marshal(t, pbes2Params{
+				KDF:    pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: marshal(t, kdf)}},
+				Cipher: pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+			})},
+		},
+		Ciphertext: encryptCBC(t, block, ivParam, plain),
+	})
+}
+
+func encryptSDR(t *testing.T, key []byte, plain string) string {
+	iv := []byte("initvect")
+	block, err := des.NewTripleDESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(marshal(t, sdrEncrypted{
+		KeyID:      testKeyID,
+		Algorithm:  pkix.AlgorithmIdentifier{Algorithm: oidDESEDE3CBC, Parameters: asn1.RawValue{FullBytes: marshal(t, iv)}},
+		Ciphertext: encryptCBC(t, block, iv, []byte(plain)),
+	}))
+}
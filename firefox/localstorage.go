@@ -0,0 +1,271 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/sqliteutil"
+	"github.com/golang/snappy"
+)
+
+// localStorage reference:
+// https://searchfox.org/mozilla-central/source/dom/storage/StorageDBThread.cpp
+// https://searchfox.org/mozilla-central/source/dom/localstorage/ActorsParent.cpp
+//
+// Before Firefox 92, localStorage was kept for all origins in
+// webappsstore.sqlite, keyed by the reversed origin. It is now kept in
+// storage/default/{origin}/ls/data.sqlite for each origin, which is
+// managed by the quota manager. The webappsstore.sqlite of upgraded
+// profiles may remain with stale data.
+
+// LocalStorageItem is a key and value in the localStorage of an origin.
+type LocalStorageItem struct {
+	Origin           string // e.g. "https://example.com"
+	OriginAttributes *OriginAttributes
+	Key              string
+	Value            string
+	LastAccessTime   time.Time // zero when not recorded
+	Store            string    // file read from, relative to the profile
+}
+
+// ParseWebappsStore parses the legacy webappsstore.sqlite database in a
+// Firefox profile. Items are ordered by origin, then key.
+func ParseWebappsStore(filename string) ([]LocalStorageItem, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var items []LocalStorageItem
+	err = sqliteutil.Query(db, `
+		SELECT originAttributes, originKey, key, value
+		FROM webappsstore2`, func(rows *sql.Rows) error {
+		var suffix, originKey sql.NullString
+		var item LocalStorageItem
+		if err := rows.Scan(&suffix, &originKey, &item.Key, &item.Value); err != nil {
+			return err
+		}
+		origin, err := DecodeOriginKey(originKey.String)
+		if err != nil {
+			return err
+		}
+		attrs, err := ParseOriginAttributes(suffix.String)
+		if err != nil {
+			return err
+		}
+		item.Origin = origin
+		item.OriginAttributes = attrs
+		item.Store = "webappsstore.sqlite"
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firefox: webappsstore: %w", err)
+	}
+	sortLocalStorage(items)
+	return items, nil
+}
+
+// DecodeOriginKey decodes a reversed origin key in webappsstore.sqlite,
+// "{reversed host}.:{scheme}[:{port}]", into an origin. For example,
+// "moc.elpmaxe.:https:443" is "https://example.com". Default ports are
+// omitted.
+func DecodeOriginKey(key string) (string, error) {
+	parts := strings.Split(key, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return "", fmt.Errorf("firefox: invalid origin key: %q", key)
+	}
+	reversed := []byte(parts[0])
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+	host := strings.TrimPrefix(string(reversed), ".")
+	scheme := parts[1]
+	origin := scheme + "://" + host
+	if len(parts) == 3 && parts[2] != "" {
+		port, err := strconv.ParseUint(parts[2], 10, 16)
+		if err != nil {
+			return "", fmt.Errorf("firefox: invalid origin key port: %q", key)
+		}
+		if !(scheme == "http" && port == 80 || scheme == "https" && port == 443) {
+			origin += ":" + parts[2]
+		}
+	}
+	return origin, nil
+}
+
+// Values of conversion_type and compression_type in ls/data.sqlite:
+const (
+	lsConversionNone    = 0
+	lsConversionUTF16   = 1 // converted from UTF-16 to UTF-8
+	lsCompressionNone   = 0
+	lsCompressionSnappy = 1
+)
+
+// ParseLocalStorageData parses a localStorage database,
+// storage/default/{origin}/ls/data.sqlite, in a Firefox profile. Items
+// are ordered by key.
+func ParseLocalStorageData(filename string) ([]LocalStorageItem, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var fullOrigin string
+	if err := db.QueryRow(`SELECT origin FROM database`).Scan(&fullOrigin); err != nil {
+		return nil, fmt.Errorf("firefox: localStorage: origin: %w", err)
+	}
+	origin, suffix := fullOrigin, ""
+	if i := strings.IndexByte(fullOrigin, '^'); i != -1 {
+		origin, suffix = fullOrigin[:i], fullOrigin[i:]
+	}
+	attrs, err := ParseOriginAttributes(suffix)
+	if err != nil {
+		return nil, err
+	}
+
+	cols, err := sqliteutil.Columns(db, "data")
+	if err != nil {
+		return nil, fmt.Errorf("firefox: localStorage: %w", err)
+	}
+	// Older schemas have a compressed flag instead of the conversion and
+	// compression types.
+	query := `SELECT key, value, conversion_type, compression_type, last_access_time FROM data`
+	for _, col := range cols {
+		if col == "compressed" {
+			query = `SELECT key, value, 1, compressed, lastAccessTime FROM data`
+		}
+	}
+	store := filepath.ToSlash(filename)
+	if i := strings.LastIndex(store, "storage/default/"); i != -1 {
+		store = store[i:]
+	}
+	var items []LocalStorageItem
+	err = sqliteutil.Query(db, query, func(rows *sql.Rows) error {
+		var key string
+		var value []byte
+		var conversion, compression, lastAccess int64
+		if err := rows.Scan(&key, &value, &conversion, &compression, &lastAccess); err != nil {
+			return err
+		}
+		switch compression {
+		case lsCompressionNone:
+		case lsCompressionSnappy:
+			var err error
+			if value, err = snappy.Decode(nil, value); err != nil {
+				return fmt.Errorf("key %q: %w", key, err)
+			}
+		default:
+			return fmt.Errorf("key %q: unknown compression type %d", key, compression)
+		}
+		// Unconverted values are ASCII, so both are UTF-8.
+		if conversion != lsConversionNone && conversion != lsConversionUTF16 {
+			return fmt.Errorf("key %q: unknown conversion type %d", key, conversion)
+		}
+		if lastAccess < 0 {
+			return fmt.Errorf("key %q: negative access time", key)
+		}
+		items = append(items, LocalStorageItem{
+			Origin:           origin,
+			OriginAttributes: attrs,
+			Key:              key,
+			Value:            string(value),
+			LastAccessTime:   timefmt.FromInt(lastAccess, 0, timefmt.Micro, timefmt.Unix),
+			Store:            store,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firefox: localStorage: %w", err)
+	}
+	sortLocalStorage(items)
+	return items, nil
+}
+
+// ProfileLocalStorage reads the localStorage of all origins in a
+// Firefox profile from the quota manager storage and from the legacy
+// webappsstore.sqlite. Legacy items for an origin and key also in the
+// quota manager storage are dropped. Items are ordered by origin, then
+// origin attributes, then key. Databases that fail to parse are handled
+// as by ProfileLocalStorageOptions with the zero Options.
+func ProfileLocalStorage(profileDir string) ([]LocalStorageItem, error) {
+	return ProfileLocalStorageOptions(profileDir, browser.Options{})
+}
+
+// ProfileLocalStorageOptions reads the localStorage of all origins in
+// a Firefox profile and handles databases that fail to parse by the
+// error policy in opts.
+func ProfileLocalStorageOptions(profileDir string, opts browser.Options) ([]LocalStorageItem, error) {
+	h := opts.Handler()
+	var items []LocalStorageItem
+	dirs, err := os.ReadDir(filepath.Join(profileDir, "storage", "default"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		filename := filepath.Join(profileDir, "storage", "default", dir.Name(), "ls", "data.sqlite")
+		originItems, err := ParseLocalStorageData(filename)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			if err := h.File(filename, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		items = append(items, originItems...)
+	}
+	filename := filepath.Join(profileDir, "webappsstore.sqlite")
+	legacy, err := ParseWebappsStore(filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		if err := h.File(filename, err); err != nil {
+			return nil, err
+		}
+	}
+	seen := make(map[string]bool, len(items))
+	for i := range items {
+		seen[items[i].key()] = true
+	}
+	for _, item := range legacy {
+		if !seen[item.key()] {
+			items = append(items, item)
+		}
+	}
+	sortLocalStorage(items)
+	return items, h.Err()
+}
+
+func (item *LocalStorageItem) key() string {
+	return item.Origin + item.OriginAttributes.String() + "\x00" + item.Key
+}
+
+func sortLocalStorage(items []LocalStorageItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := &items[i], &items[j]
+		if a.Origin != b.Origin {
+			return a.Origin < b.Origin
+		}
+		if sa, sb := a.OriginAttributes.String(), b.OriginAttributes.String(); sa != sb {
+			return sa < sb
+		}
+		return a.Key < b.Key
+	})
+}
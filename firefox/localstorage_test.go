@@ -0,0 +1,68 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+)
+
+func TestProfileLocalStorage(t *testing.T) {
+	profile := t.TempDir()
+	exec := func(filename, query string, args ...interface{}) {
+		if err := os.MkdirAll(filepath.Dir(filename), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		db, err := sql.Open("sqlite3", filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		if _, err := db.Exec(query, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exec(filepath.Join(profile, "webappsstore.sqlite"), `
+		CREATE TABLE webappsstore2 (originAttributes TEXT, originKey TEXT, scope TEXT, key TEXT, value TEXT);
+		INSERT INTO webappsstore2 VALUES
+			('', 'moc.elpmaxe.:https:443', '', 'theme', 'stale'),
+			('', 'moc.elpmaxe.:https:443', '', 'legacy', 'old'),
+			('^userContextId=2', 'gro.elpmaxe.:http:8080', '', 'k', 'v')`)
+	long := strings.Repeat("compressible ", 20)
+	exec(filepath.Join(profile, "storage", "default", "https+++example.com", "ls", "data.sqlite"), `
+		CREATE TABLE database (origin TEXT NOT NULL, usage INTEGER NOT NULL DEFAULT 0,
+			last_vacuum_time INTEGER NOT NULL DEFAULT 0, last_analyze_time INTEGER NOT NULL DEFAULT 0,
+			last_vacuum_size INTEGER NOT NULL DEFAULT 0);
+		CREATE TABLE data (key TEXT PRIMARY KEY, utf16_length INTEGER NOT NULL, conversion_type INTEGER NOT NULL,
+			compression_type INTEGER NOT NULL, last_access_time INTEGER NOT NULL DEFAULT 0, value BLOB NOT NULL);
+		INSERT INTO database (origin) VALUES ('https://example.com');
+		INSERT INTO data VALUES ('theme', 4, 1, 0, 1613610123000000, CAST('dark' AS BLOB)), ('long', 260, 1, 1, 0, ?)`,
+		snappy.Encode(nil, []byte(long)))
+
+	items, err := ProfileLocalStorage(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const lsStore = "storage/default/https+++example.com/ls/data.sqlite"
+	none := &OriginAttributes{}
+	want := []LocalStorageItem{
+		{"http://example.org:8080", &OriginAttributes{UserContextID: 2}, "k", "v", time.Time{}, "webappsstore.sqlite"},
+		{"https://example.com", none, "legacy", "old", time.Time{}, "webappsstore.sqlite"},
+		{"https://example.com", none, "long", long, time.Time{}, lsStore},
+		{"https://example.com", none, "theme", "dark", time.Unix(1613610123, 0).UTC(), lsStore},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", items, want)
+	}
+}
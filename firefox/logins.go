@@ -0,0 +1,87 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"fmt"
+
+	"github.com/andrewarchi/browser/jsonutil"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+)
+
+// Logins contains saved logins in logins.json. Usernames and passwords
+// are encrypted with a key stored in key4.db, which can be decrypted
+// with UnlockKeyDB.
+// https://searchfox.org/mozilla-central/source/toolkit/components/passwordmgr/LoginStore.jsm
+type Logins struct {
+	NextID                           int64                           `json:"nextId"`
+	Logins                           []Login                         `json:"logins"`
+	PotentiallyVulnerablePasswords   []VulnerablePassword            `json:"potentiallyVulnerablePasswords"`
+	DismissedBreachAlertsByLoginGUID map[string]DismissedBreachAlert `json:"dismissedBreachAlertsByLoginGUID"` // key: login GUID
+	Version                          int                             `json:"version"`                          // e.g. 3
+}
+
+// Login is a saved login for a site.
+type Login struct {
+	ID                     int64             `json:"id"`
+	Hostname               string            `json:"hostname"`      // origin, e.g. "https://example.com"
+	HTTPRealm              string            `json:"httpRealm"`     // for HTTP authentication, otherwise null
+	FormSubmitURL          string            `json:"formSubmitURL"` // origin for form logins, otherwise null
+	UsernameField          string            `json:"usernameField"`
+	PasswordField          string            `json:"passwordField"`
+	EncryptedUsername      string            `json:"encryptedUsername"` // base64-encoded
+	EncryptedPassword      string            `json:"encryptedPassword"` // base64-encoded
+	GUID                   string            `json:"guid"`              // e.g. "{xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx}"
+	EncType                EncType           `json:"encType"`
+	TimeCreated            timefmt.UnixMilli `json:"timeCreated"`
+	TimeLastUsed           timefmt.UnixMilli `json:"timeLastUsed"`
+	TimePasswordChanged    timefmt.UnixMilli `json:"timePasswordChanged"`
+	TimesUsed              int               `json:"timesUsed"`
+	SyncCounter            int               `json:"syncCounter,omitempty"`
+	EverSynced             bool              `json:"everSynced,omitempty"`
+	EncryptedUnknownFields string            `json:"encryptedUnknownFields,omitempty"` // fields from newer versions, synced
+}
+
+// VulnerablePassword is a saved password that was found in a breach.
+type VulnerablePassword struct {
+	EncryptedPassword string `json:"encryptedPassword"`
+}
+
+// DismissedBreachAlert records that the user dismissed a breach alert
+// for a login.
+type DismissedBreachAlert struct {
+	TimeBreachAlertDismissed timefmt.UnixMilli `json:"timeBreachAlertDismissed"`
+}
+
+// EncType is the encryption of a login's username and password.
+type EncType uint8
+
+// Values for EncType:
+const (
+	EncTypeBase64 EncType = 0 // obsolete; base64-encoded plaintext
+	EncTypeSDR    EncType = 1 // encrypted by Secret Decoder Ring
+)
+
+// ParseLogins parses logins.json in a Firefox profile.
+func ParseLogins(filename string) (*Logins, error) {
+	var logins Logins
+	if err := jsonutil.DecodeFile(filename, &logins); err != nil {
+		return nil, err
+	}
+	return &logins, nil
+}
+
+func (typ EncType) String() string {
+	switch typ {
+	case EncTypeBase64:
+		return "base64"
+	case EncTypeSDR:
+		return "sdr"
+	default:
+		return fmt.Sprintf("enc_type(%d)", uint8(typ))
+	}
+}
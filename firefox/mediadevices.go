@@ -0,0 +1,63 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OriginKey is a per-origin key used to derive the device IDs exposed
+// to a site by navigator.mediaDevices.enumerateDevices, so that IDs are
+// stable for an origin but differ between origins.
+type OriginKey struct {
+	Key    string    // base64-encoded random bytes
+	Time   time.Time // creation time
+	Origin string    // origin with attributes suffix, e.g. "https://example.com^userContextId=1"
+}
+
+// ParseEnumerateDevices parses enumerate_devices.txt in a Firefox
+// profile. The first line is the format version and each following
+// line is "{key} {seconds} {origin}".
+// https://searchfox.org/mozilla-central/source/dom/media/systemservices/MediaParent.cpp
+func ParseEnumerateDevices(filename string) ([]OriginKey, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	if !s.Scan() {
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	if version := s.Text(); version != "1" {
+		return nil, fmt.Errorf("firefox: enumerate devices: unsupported version %q", version)
+	}
+	var keys []OriginKey
+	for line := 2; s.Scan(); line++ {
+		fields := strings.SplitN(s.Text(), " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("firefox: enumerate devices: line %d has %d fields", line, len(fields))
+		}
+		sec, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("firefox: enumerate devices: line %d: %w", line, err)
+		}
+		keys = append(keys, OriginKey{Key: fields[0], Time: time.Unix(sec, 0).UTC(), Origin: fields[2]})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/andrewarchi/browser/jsonutil"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+)
+
+// Notification store format:
+// https://searchfox.org/mozilla-central/source/dom/notification/NotificationDB.jsm
+//
+// Notifications shown by sites are persisted until they are closed, so
+// that they can be retrieved by Notification.get and
+// ServiceWorkerRegistration.getNotifications.
+
+// NotificationStore contains the notifications in notificationstore.json,
+// keyed by origin, then by notification ID.
+type NotificationStore map[string]map[string]Notification
+
+// Notification is a web notification shown by a site.
+type Notification struct {
+	ID                             string            `json:"id"`
+	Title                          string            `json:"title"`
+	Dir                            string            `json:"dir,omitempty"` // "auto", "ltr", or "rtl"
+	Lang                           string            `json:"lang,omitempty"`
+	Body                           string            `json:"body,omitempty"`
+	Tag                            string            `json:"tag,omitempty"`
+	Icon                           string            `json:"icon,omitempty"`
+	Data                           string            `json:"data,omitempty"` // serialized with the structured clone algorithm
+	MozBehavior                    json.RawMessage   `json:"mozbehavior,omitempty"`
+	RequireInteraction             bool              `json:"requireInteraction,omitempty"`
+	Silent                         bool              `json:"silent,omitempty"`
+	Vibrate                        []int             `json:"vibrate,omitempty"`
+	ServiceWorkerRegistrationScope string            `json:"serviceWorkerRegistrationScope,omitempty"`
+	Timestamp                      timefmt.UnixMilli `json:"timestamp"`
+	AlertName                      string            `json:"alertName,omitempty"`
+	Origin                         string            `json:"origin"` // e.g. "https://example.com"
+}
+
+// ParseNotificationStore parses notificationstore.json in a Firefox
+// profile.
+func ParseNotificationStore(filename string) (NotificationStore, error) {
+	var store NotificationStore
+	if err := jsonutil.DecodeFileAllowUnknownFields(filename, &store); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// List returns the notifications in the store, ordered by timestamp,
+// then by origin and ID.
+func (store NotificationStore) List() []Notification {
+	var notifications []Notification
+	for _, byID := range store {
+		for _, n := range byID {
+			notifications = append(notifications, n)
+		}
+	}
+	sort.Slice(notifications, func(i, j int) bool {
+		ni, nj := &notifications[i], &notifications[j]
+		if !ni.Timestamp.Equal(nj.Timestamp.Time) {
+			return ni.Timestamp.Before(nj.Timestamp.Time)
+		}
+		if ni.Origin != nj.Origin {
+			return ni.Origin < nj.Origin
+		}
+		return ni.ID < nj.ID
+	})
+	return notifications
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+)
+
+func TestParseNotificationStore(t *testing.T) {
+	data := `{
+  "https://mail.example.com": {
+    "{5a0b1c2d-3e4f-4a5b-8c6d-7e8f9a0b1c2d}": {"id": "{5a0b1c2d-3e4f-4a5b-8c6d-7e8f9a0b1c2d}",
+      "title": "New message", "dir": "auto", "lang": "", "body": "Lunch?", "tag": "inbox",
+      "icon": "https://mail.example.com/icon.png", "data": "", "mozbehavior": {"noscreen": false},
+      "requireInteraction": false, "silent": false, "vibrate": [200, 100],
+      "serviceWorkerRegistrationScope": "https://mail.example.com/", "timestamp": 1613610124000,
+      "alertName": "https://mail.example.com#inbox", "origin": "https://mail.example.com"}
+  },
+  "https://chat.example.org": {
+    "{6a0b1c2d-3e4f-4a5b-8c6d-7e8f9a0b1c2d}": {"id": "{6a0b1c2d-3e4f-4a5b-8c6d-7e8f9a0b1c2d}",
+      "title": "Ping", "body": "", "timestamp": 1613610123000, "origin": "https://chat.example.org"}
+  }
+}`
+	filename := filepath.Join(t.TempDir(), "notificationstore.json")
+	if err := os.WriteFile(filename, []byte(data), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	store, err := ParseNotificationStore(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msec := func(ms int64) timefmt.UnixMilli { return timefmt.UnixMilli{Time: time.Unix(0, ms*1e6).UTC()} }
+	want := []Notification{{
+		ID:        "{6a0b1c2d-3e4f-4a5b-8c6d-7e8f9a0b1c2d}",
+		Title:     "Ping",
+		Timestamp: msec(1613610123000),
+		Origin:    "https://chat.example.org",
+	}, {
+		ID:                             "{5a0b1c2d-3e4f-4a5b-8c6d-7e8f9a0b1c2d}",
+		Title:                          "New message",
+		Dir:                            "auto",
+		Body:                           "Lunch?",
+		Tag:                            "inbox",
+		Icon:                           "https://mail.example.com/icon.png",
+		MozBehavior:                    []byte(`{"noscreen": false}`),
+		Vibrate:                        []int{200, 100},
+		ServiceWorkerRegistrationScope: "https://mail.example.com/",
+		Timestamp:                      msec(1613610124000),
+		AlertName:                      "https://mail.example.com#inbox",
+		Origin:                         "https://mail.example.com",
+	}}
+	got := store.List()
+	for i := range got {
+		if i < len(want) && got[i].Timestamp.Equal(want[i].Timestamp.Time) {
+			got[i].Timestamp = want[i].Timestamp
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got, want)
+	}
+}
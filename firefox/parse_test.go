@@ -29,14 +29,40 @@ func TestParse(t *testing.T) {
 			continue
 		}
 
+		addonStartup := filepath.Join(profile, "addonStartup.json.lz4")
+		_, err = ParseAddonStartup(addonStartup)
+		checkError(t, addonStartup, err)
+
 		addons := filepath.Join(profile, "addons.json")
 		_, err = ParseAddons(addons)
 		checkError(t, addons, err)
 
+		autofillProfiles := filepath.Join(profile, "autofill-profiles.json")
+		_, err = ParseAutofillProfiles(autofillProfiles)
+		checkError(t, autofillProfiles, err)
+
+		_, err = ProfileAddonBlocklist(profile)
+		checkError(t, filepath.Join(profile, "blocklist-addons.json"), err)
+
+		broadcastListeners := filepath.Join(profile, "broadcast-listeners.json")
+		_, err = ParseBroadcastListeners(broadcastListeners)
+		checkError(t, broadcastListeners, err)
+
+		certOverrides := filepath.Join(profile, "cert_override.txt")
+		_, err = ParseCertOverrides(certOverrides)
+		checkError(t, certOverrides, err)
+
 		containers := filepath.Join(profile, "containers.json")
 		_, err = ParseContainers(containers)
 		checkError(t, containers, err)
 
+		_, err = ProfileDownloads(profile)
+		checkError(t, filepath.Join(profile, "downloads.json"), err)
+
+		enumerateDevices := filepath.Join(profile, "enumerate_devices.txt")
+		_, err = ParseEnumerateDevices(enumerateDevices)
+		checkError(t, enumerateDevices, err)
+
 		extensions := filepath.Join(profile, "extensions.json")
 		_, err = ParseExtensions(extensions)
 		checkError(t, extensions, err)
@@ -49,22 +75,119 @@ func TestParse(t *testing.T) {
 		_, err = ParseExtensionSettings(extensionSettings)
 		checkError(t, extensionSettings, err)
 
+		favicons := filepath.Join(profile, "favicons.sqlite")
+		if f, err := OpenFavicons(favicons); err != nil {
+			checkError(t, favicons, err)
+		} else {
+			_, err = f.Icon("https://www.mozilla.org/", 16)
+			checkError(t, favicons, err)
+			f.Close()
+		}
+
+		formHistory := filepath.Join(profile, "formhistory.sqlite")
+		_, err = ParseFormHistory(formHistory)
+		checkError(t, formHistory, err)
+
 		handlers := filepath.Join(profile, "handlers.json")
 		_, err = ParseHandlers(handlers)
 		checkError(t, handlers, err)
 
+		logins := filepath.Join(profile, "logins.json")
+		_, err = ParseLogins(logins)
+		checkError(t, logins, err)
+
+		places := filepath.Join(profile, "places.sqlite")
+		_, err = ParsePlacesBookmarks(places)
+		checkError(t, places, err)
+
+		_, err = ParseInputHistory(places)
+		checkError(t, places, err)
+
+		_, err = ParsePlacesKeywords(places)
+		checkError(t, places, err)
+
+		_, err = ProfilePrefs(profile)
+		checkError(t, filepath.Join(profile, "prefs.js"), err)
+
+		_, err = ProfileUIState(profile)
+		checkError(t, filepath.Join(profile, "prefs.js"), err)
+
+		preferenceExperiments := filepath.Join(profile, "shield-preference-experiments.json")
+		_, err = ParsePreferenceExperiments(preferenceExperiments)
+		checkError(t, preferenceExperiments, err)
+
+		notificationStore := filepath.Join(profile, "notificationstore.json")
+		_, err = ParseNotificationStore(notificationStore)
+		checkError(t, notificationStore, err)
+
+		protections := filepath.Join(profile, "protections.sqlite")
+		_, err = ParseProtections(protections)
+		checkError(t, protections, err)
+
+		serviceWorkers := filepath.Join(profile, "serviceworker.txt")
+		_, err = ParseServiceWorkers(serviceWorkers)
+		checkError(t, serviceWorkers, err)
+
+		sessionFiles, err := SessionFiles(profile)
+		checkError(t, filepath.Join(profile, "sessionstore-backups"), err)
+		for _, f := range sessionFiles {
+			_, err = ParseSession(f.Path)
+			checkError(t, f.Path, err)
+		}
+
+		storageCache := filepath.Join(profile, "storage.sqlite")
+		_, err = ParseStorageCache(storageCache)
+		checkError(t, storageCache, err)
+
+		_, err = ScanStorage(profile)
+		checkError(t, filepath.Join(profile, "storage"), err)
+
 		times := filepath.Join(profile, "times.json")
 		_, err = ParseTimes(times)
 		checkError(t, times, err)
 
-		bookmarkBackups, err := filepath.Glob(filepath.Join(profile, "bookmarkbackups", "bookmarks-*.jsonlz4"))
-		if err != nil {
-			t.Error(err)
-			continue
+		compatibility := filepath.Join(profile, "compatibility.ini")
+		_, err = ParseCompatibility(compatibility)
+		checkError(t, compatibility, err)
+
+		contentPrefs := filepath.Join(profile, "content-prefs.sqlite")
+		_, err = ParseContentPrefs(contentPrefs)
+		checkError(t, contentPrefs, err)
+
+		_, err = ProfileSiteSecurity(profile)
+		checkError(t, filepath.Join(profile, SiteSecurityBinaryFile), err)
+
+		_, err = ProfileSyncState(profile)
+		checkError(t, filepath.Join(profile, "signedInUser.json"), err)
+
+		_, err = ProfileStorageSync(profile)
+		checkError(t, filepath.Join(profile, "storage-sync-v2.sqlite"), err)
+
+		_, err = ProfileLocalStorage(profile)
+		checkError(t, filepath.Join(profile, "webappsstore.sqlite"), err)
+
+		telemetry, err := ProfileTelemetry(profile)
+		checkError(t, filepath.Join(profile, "datareporting"), err)
+		if telemetry != nil {
+			for _, f := range telemetry.Pings {
+				_, err = ParseTelemetryPing(f.Path)
+				checkError(t, f.Path, err)
+			}
 		}
+
+		searchEngines := filepath.Join(profile, SearchEnginesFile)
+		_, err = ParseSearchEngines(searchEngines)
+		checkError(t, searchEngines, err)
+
+		xulStore := filepath.Join(profile, "xulstore.json")
+		_, err = ParseXULStore(xulStore)
+		checkError(t, xulStore, err)
+
+		bookmarkBackups, err := ListBookmarkBackups(profile)
+		checkError(t, filepath.Join(profile, "bookmarkbackups"), err)
 		for _, bookmarkBackup := range bookmarkBackups {
-			_, err = ParseBookmarkBackup(bookmarkBackup)
-			checkError(t, bookmarkBackup, err)
+			_, err = ParseBookmarkBackup(bookmarkBackup.Filename)
+			checkError(t, bookmarkBackup.Filename, err)
 		}
 	}
 }
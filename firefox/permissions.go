@@ -0,0 +1,159 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Permissions database schema:
+// https://searchfox.org/mozilla-central/source/extensions/permissions/PermissionManager.cpp
+//
+// Each row of moz_perms is a permission of a type for an origin, which
+// may have an origin attributes suffix, e.g.
+// "https://example.com^privateBrowsingId=1". Times are in milliseconds
+// since 1970.
+
+// PermissionsFile is the database of per-site permissions in a profile.
+const PermissionsFile = "permissions.sqlite"
+
+// Permission is a row in moz_perms.
+type Permission struct {
+	ID               int64
+	Origin           string // e.g. "https://example.com"
+	OriginAttributes *OriginAttributes
+	Type             string // e.g. PermissionTrackingProtection
+	Permission       PermissionAction
+	ExpireType       PermissionExpireType
+	ExpireTime       time.Time // zero unless ExpireType is PermissionExpireTime
+	Modified         time.Time
+}
+
+// Types of permissions in permissions.sqlite that override privacy
+// settings for a site:
+const (
+	PermissionCookie                = "cookie"                   // cookies allowed, blocked, or cleared on exit
+	PermissionTrackingProtection    = "trackingprotection"       // Enhanced Tracking Protection disabled
+	PermissionTrackingProtectionPB  = "trackingprotection-pb"    // disabled in private windows, before Firefox 80
+	PermissionHTTPSOnlyLoadInsecure = "https-only-load-insecure" // HTTPS-Only Mode exception
+)
+
+// PermissionAction is the value of a permission.
+// https://searchfox.org/mozilla-central/source/netwerk/base/nsIPermissionManager.idl
+type PermissionAction uint32
+
+// Values for PermissionAction:
+const (
+	PermissionUnknown PermissionAction = 0
+	PermissionAllow   PermissionAction = 1
+	PermissionDeny    PermissionAction = 2
+	PermissionPrompt  PermissionAction = 3
+
+	// PermissionCookieSession allows cookies until the session ends,
+	// for the cookie type.
+	PermissionCookieSession PermissionAction = 8
+	// PermissionHTTPSOnlyAllowSession allows insecure loads until the
+	// session ends, for the https-only-load-insecure type.
+	PermissionHTTPSOnlyAllowSession PermissionAction = 9
+	// PermissionHTTPSFirstAllow allows insecure loads in HTTPS-First
+	// Mode, for the https-only-load-insecure type.
+	PermissionHTTPSFirstAllow PermissionAction = 10
+)
+
+// PermissionExpireType is when a permission expires.
+type PermissionExpireType uint8
+
+// Values for PermissionExpireType:
+const (
+	PermissionExpireNever   PermissionExpireType = 0
+	PermissionExpireSession PermissionExpireType = 1
+	PermissionExpireTime    PermissionExpireType = 2
+	PermissionExpirePolicy  PermissionExpireType = 3 // set by enterprise policy
+)
+
+// ParsePermissions parses permissions.sqlite in a Firefox profile.
+// Permissions are ordered by type, then origin.
+func ParsePermissions(filename string) ([]Permission, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var perms []Permission
+	err = sqliteutil.Query(db, `
+		SELECT id, origin, type, permission, expireType, expireTime, modificationTime
+		FROM moz_perms
+		ORDER BY type, origin, id`, func(rows *sql.Rows) error {
+		var p Permission
+		var expires, modified int64
+		if err := rows.Scan(&p.ID, &p.Origin, &p.Type, &p.Permission,
+			&p.ExpireType, &expires, &modified); err != nil {
+			return err
+		}
+		var suffix string
+		if caret := strings.IndexByte(p.Origin, '^'); caret != -1 {
+			p.Origin, suffix = p.Origin[:caret], p.Origin[caret:]
+		}
+		attrs, err := ParseOriginAttributes(suffix)
+		if err != nil {
+			return err
+		}
+		p.OriginAttributes = attrs
+		if p.ExpireType == PermissionExpireTime {
+			p.ExpireTime = timefmt.FromInt(expires, 0, timefmt.Milli, timefmt.Unix)
+		}
+		p.Modified = timefmt.FromInt(modified, 0, timefmt.Milli, timefmt.Unix)
+		perms = append(perms, p)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firefox: permissions: %w", err)
+	}
+	return perms, nil
+}
+
+func (a PermissionAction) String() string {
+	switch a {
+	case PermissionUnknown:
+		return "unknown"
+	case PermissionAllow:
+		return "allow"
+	case PermissionDeny:
+		return "deny"
+	case PermissionPrompt:
+		return "prompt"
+	case PermissionCookieSession:
+		return "session"
+	case PermissionHTTPSOnlyAllowSession:
+		return "allow_session"
+	case PermissionHTTPSFirstAllow:
+		return "https_first_allow"
+	default:
+		return fmt.Sprintf("permission(%d)", uint32(a))
+	}
+}
+
+func (t PermissionExpireType) String() string {
+	switch t {
+	case PermissionExpireNever:
+		return "never"
+	case PermissionExpireSession:
+		return "session"
+	case PermissionExpireTime:
+		return "time"
+	case PermissionExpirePolicy:
+		return "policy"
+	default:
+		return fmt.Sprintf("expire(%d)", uint8(t))
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePermissions(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), PermissionsFile)
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE moz_perms (id INTEGER PRIMARY KEY, origin TEXT, type TEXT, permission INTEGER,
+			expireType INTEGER, expireTime INTEGER, modificationTime INTEGER);
+		INSERT INTO moz_perms VALUES
+			(1, 'https://example.com', 'trackingprotection', 1, 0, 0, 1613610123000),
+			(2, 'https://example.org^privateBrowsingId=1', 'trackingprotection', 1, 1, 0, 1613610124000),
+			(3, 'http://example.net', 'https-only-load-insecure', 9, 2, 1613700000000, 1613610125000),
+			(4, 'https://example.com^userContextId=2', 'cookie', 8, 0, 0, 1613610126000);
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	perms, err := ParsePermissions(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msec := func(ms int64) time.Time { return time.Unix(0, ms*1e6).UTC() }
+	want := []Permission{
+		{ID: 4, Origin: "https://example.com", OriginAttributes: &OriginAttributes{UserContextID: 2},
+			Type: PermissionCookie, Permission: PermissionCookieSession, Modified: msec(1613610126000)},
+		{ID: 3, Origin: "http://example.net", OriginAttributes: &OriginAttributes{},
+			Type: PermissionHTTPSOnlyLoadInsecure, Permission: PermissionHTTPSOnlyAllowSession,
+			ExpireType: PermissionExpireTime, ExpireTime: msec(1613700000000), Modified: msec(1613610125000)},
+		{ID: 1, Origin: "https://example.com", OriginAttributes: &OriginAttributes{},
+			Type: PermissionTrackingProtection, Permission: PermissionAllow, Modified: msec(1613610123000)},
+		{ID: 2, Origin: "https://example.org", OriginAttributes: &OriginAttributes{PrivateBrowsingID: 1},
+			Type: PermissionTrackingProtection, Permission: PermissionAllow,
+			ExpireType: PermissionExpireSession, Modified: msec(1613610124000)},
+	}
+	if !reflect.DeepEqual(perms, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", perms, want)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/andrewarchi/browser/bookmark"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Places database schema:
+// https://searchfox.org/mozilla-central/source/toolkit/components/places/nsPlacesTables.h
+// https://searchfox.org/mozilla-central/source/toolkit/components/places/Bookmarks.jsm
+//
+// Bookmarks, folders, and separators are rows of moz_bookmarks, ordered
+// within their parent by position. URLs are in moz_places and keywords
+// in moz_keywords. Tags are folders within the tags root, each
+// containing a bookmark for every tagged URL.
+
+// Types of moz_bookmarks rows:
+const (
+	placesBookmark  = 1
+	placesFolder    = 2
+	placesSeparator = 3
+)
+
+// GUIDs of the places roots.
+const (
+	PlacesRootGUID    = "root________"
+	PlacesMenuGUID    = "menu________"
+	PlacesToolbarGUID = "toolbar_____"
+	PlacesUnfiledGUID = "unfiled_____"
+	PlacesMobileGUID  = "mobile______"
+	PlacesTagsGUID    = "tags________"
+)
+
+// placesRootTitles are the displayed titles of the roots, which are
+// localized by Firefox and stored empty in newer profiles.
+var placesRootTitles = map[string]string{
+	PlacesMenuGUID:    "Bookmarks Menu",
+	PlacesToolbarGUID: "Bookmarks Toolbar",
+	PlacesUnfiledGUID: "Other Bookmarks",
+	PlacesMobileGUID:  "Mobile Bookmarks",
+}
+
+type placesItem struct {
+	ID, Type, Parent, Position int64
+	Title, GUID, URL, Keyword  string
+	DateAdded, LastModified    int64
+	Children                   []*placesItem
+}
+
+// ParsePlacesBookmarks reads the bookmarks in places.sqlite in a
+// Firefox profile. The returned entries are the menu, toolbar, other,
+// and mobile roots, in order, with their GUIDs. Tags are attached to
+// the bookmarks with tagged URLs, rather than returned as folders.
+func ParsePlacesBookmarks(filename string) ([]bookmark.BookmarkEntry, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	items := make(map[int64]*placesItem)
+	var all []*placesItem
+	err = sqliteutil.Query(db, `
+		SELECT b.id, b.type, b.parent, b.position, b.title, b.guid,
+			b.dateAdded, b.lastModified, p.url,
+			(SELECT k.keyword FROM moz_keywords k WHERE k.place_id = b.fk ORDER BY k.id LIMIT 1)
+		FROM moz_bookmarks b LEFT JOIN moz_places p ON b.fk = p.id`, func(rows *sql.Rows) error {
+		var it placesItem
+		var title, url, keyword sql.NullString
+		var dateAdded, lastModified sql.NullInt64
+		if err := rows.Scan(&it.ID, &it.Type, &it.Parent, &it.Position, &title, &it.GUID,
+			&dateAdded, &lastModified, &url, &keyword); err != nil {
+			return err
+		}
+		it.Title, it.URL, it.Keyword = title.String, url.String, keyword.String
+		it.DateAdded, it.LastModified = dateAdded.Int64, lastModified.Int64
+		items[it.ID] = &it
+		all = append(all, &it)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firefox: places bookmarks: %w", err)
+	}
+
+	var root *placesItem
+	for _, it := range all {
+		if it.GUID == PlacesRootGUID {
+			root = it
+			continue
+		}
+		parent, ok := items[it.Parent]
+		if !ok {
+			return nil, fmt.Errorf("firefox: places bookmark %s has missing parent %d", it.GUID, it.Parent)
+		}
+		parent.Children = append(parent.Children, it)
+	}
+	if root == nil {
+		return nil, fmt.Errorf("firefox: places bookmarks have no root")
+	}
+	for _, it := range all {
+		sort.SliceStable(it.Children, func(i, j int) bool {
+			return it.Children[i].Position < it.Children[j].Position
+		})
+	}
+
+	// Tags are the titles of folders in the tags root.
+	tags := make(map[string][]string) // key: URL
+	for _, root := range root.Children {
+		if root.GUID != PlacesTagsGUID {
+			continue
+		}
+		for _, tag := range root.Children {
+			for _, b := range tag.Children {
+				if b.URL != "" {
+					tags[b.URL] = append(tags[b.URL], tag.Title)
+				}
+			}
+		}
+	}
+
+	var entries []bookmark.BookmarkEntry
+	for _, it := range root.Children {
+		if it.GUID == PlacesTagsGUID {
+			continue
+		}
+		entries = append(entries, it.entry(tags))
+	}
+	return entries, nil
+}
+
+func (it *placesItem) entry(tags map[string][]string) bookmark.BookmarkEntry {
+	added := timefmt.FromInt(it.DateAdded, 0, timefmt.Micro, timefmt.Unix)
+	modified := timefmt.FromInt(it.LastModified, 0, timefmt.Micro, timefmt.Unix)
+	switch it.Type {
+	case placesBookmark:
+		return &bookmark.Bookmark{
+			Title:        it.Title,
+			URL:          it.URL,
+			GUID:         it.GUID,
+			AddDate:      added,
+			LastModified: modified,
+			Tags:         tags[it.URL],
+			Keyword:      it.Keyword,
+		}
+	case placesSeparator:
+		return &bookmark.BookmarkSeparator{
+			GUID:         it.GUID,
+			AddDate:      added,
+			LastModified: modified,
+		}
+	default:
+		title := it.Title
+		if t, ok := placesRootTitles[it.GUID]; ok && title == "" {
+			title = t
+		}
+		f := &bookmark.BookmarkFolder{
+			Title:        title,
+			GUID:         it.GUID,
+			AddDate:      added,
+			LastModified: modified,
+			Entries:      make([]bookmark.BookmarkEntry, 0, len(it.Children)),
+		}
+		for _, child := range it.Children {
+			f.Entries = append(f.Entries, child.entry(tags))
+		}
+		return f
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrewarchi/browser/bookmark"
+)
+
+func TestParsePlacesBookmarks(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "places.sqlite")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR);
+		CREATE TABLE moz_keywords (id INTEGER PRIMARY KEY, keyword TEXT UNIQUE, place_id INTEGER, post_data TEXT);
+		CREATE TABLE moz_bookmarks (id INTEGER PRIMARY KEY, type INTEGER, fk INTEGER DEFAULT NULL,
+			parent INTEGER, position INTEGER, title LONGVARCHAR, keyword_id INTEGER, folder_type TEXT,
+			dateAdded INTEGER, lastModified INTEGER, guid TEXT, syncStatus INTEGER, syncChangeCounter INTEGER);
+		INSERT INTO moz_places VALUES (1, 'https://a.example/', 'A'), (2, 'https://b.example/', 'B');
+		INSERT INTO moz_keywords VALUES (1, 'a', 1, NULL);
+		INSERT INTO moz_bookmarks (id, type, fk, parent, position, title, dateAdded, lastModified, guid) VALUES
+			(1, 2, NULL, 0, 0, '', 1613610123000000, 1613610123000000, 'root________'),
+			(2, 2, NULL, 1, 0, '', 1613610123000000, 1613610123000000, 'menu________'),
+			(3, 2, NULL, 1, 1, '', 1613610123000000, 1613610123000000, 'toolbar_____'),
+			(4, 2, NULL, 1, 2, '', 1613610123000000, 1613610123000000, 'tags________'),
+			(5, 1, 2, 3, 2, 'B', 1613610123000000, 1613610123000000, 'bbbbbbbbbbbb'),
+			(6, 3, NULL, 3, 1, NULL, 1613610123000000, 1613610123000000, 'ssssssssssss'),
+			(7, 1, 1, 3, 0, 'A', 1613610123000000, 1613610124000000, 'aaaaaaaaaaaa'),
+			(8, 2, NULL, 4, 0, 'news', 1613610123000000, 1613610123000000, 'tttttttttttt'),
+			(9, 1, 1, 8, 0, NULL, 1613610123000000, 1613610123000000, 'tagtagtagtag');
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ParsePlacesBookmarks(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d roots, want 2", len(entries))
+	}
+	menu := entries[0].(*bookmark.BookmarkFolder)
+	toolbar := entries[1].(*bookmark.BookmarkFolder)
+	if menu.GUID != PlacesMenuGUID || menu.Title != "Bookmarks Menu" || len(menu.Entries) != 0 {
+		t.Errorf("got menu %+v", menu)
+	}
+	if toolbar.GUID != PlacesToolbarGUID || len(toolbar.Entries) != 3 {
+		t.Fatalf("got toolbar %+v", toolbar)
+	}
+	a, ok := toolbar.Entries[0].(*bookmark.Bookmark)
+	if !ok || a.URL != "https://a.example/" || a.Keyword != "a" ||
+		len(a.Tags) != 1 || a.Tags[0] != "news" || a.LastModified.Unix() != 1613610124 {
+		t.Errorf("got entry 0 %+v", toolbar.Entries[0])
+	}
+	if s, ok := toolbar.Entries[1].(*bookmark.BookmarkSeparator); !ok || s.GUID != "ssssssssssss" {
+		t.Errorf("got entry 1 %+v", toolbar.Entries[1])
+	}
+	if b, ok := toolbar.Entries[2].(*bookmark.Bookmark); !ok || b.Title != "B" || b.Tags != nil {
+		t.Errorf("got entry 2 %+v", toolbar.Entries[2])
+	}
+}
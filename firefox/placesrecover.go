@@ -0,0 +1,152 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// moz_places schema:
+// https://searchfox.org/mozilla-central/source/toolkit/components/places/nsPlacesTables.h
+//
+// Columns are id (stored as the row ID), url, title, rev_host,
+// visit_count, hidden, typed, frecency, last_visit_date, guid, and
+// columns that vary by version.
+
+// RecoveredPlace is a moz_places row carved from the unallocated pages
+// of places.sqlite or its write-ahead log. It is forensic data: it may
+// be a deleted row or an older version of a live row, and its table is
+// inferred from the shape of the row, so it is unreliable.
+type RecoveredPlace struct {
+	ID            int64
+	URL           string
+	Title         string
+	RevHost       string // reversed host with a trailing dot, e.g. "moc.elpmaxe."
+	VisitCount    int
+	Hidden        bool
+	Typed         bool
+	Frecency      int
+	LastVisitDate time.Time // zero when never visited
+	GUID          string
+	Source        sqliteutil.RecordSource // where the row was found
+}
+
+// Range of plausible visit times, 1990 to 2100, in microseconds since
+// 1970, for distinguishing moz_places rows from other tables.
+const (
+	minRecoveredPlacesTime = 631152000000000
+	maxRecoveredPlacesTime = 4102444800000000
+)
+
+// RecoverPlaces carves deleted URLs from moz_places in places.sqlite
+// and an uncheckpointed -wal file, such as after history is cleared.
+// Firefox builds SQLite with secure_delete, which zeroes freed pages,
+// so rows are mostly recovered from the write-ahead log while Firefox
+// is running or after it exits uncleanly. Rows that match the live
+// database are omitted and the latest version of each other row is
+// kept. Places are ordered by ID.
+func RecoverPlaces(filename string) ([]RecoveredPlace, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	live := make(map[int64]string)
+	err = sqliteutil.Query(db, `SELECT id, url FROM moz_places`, func(rows *sql.Rows) error {
+		var id int64
+		var url sql.NullString
+		if err := rows.Scan(&id, &url); err != nil {
+			return err
+		}
+		live[id] = url.String
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firefox: places: %w", err)
+	}
+	records, err := sqliteutil.Recover(filename)
+	if err != nil {
+		return nil, fmt.Errorf("firefox: places: %w", err)
+	}
+
+	type placeKey struct {
+		id  int64
+		url string
+	}
+	var places []RecoveredPlace
+	seen := make(map[placeKey]int) // index in places
+	for _, r := range records {
+		p, ok := recoveredPlace(&r)
+		if !ok {
+			continue
+		}
+		if url, ok := live[p.ID]; ok && url == p.URL {
+			continue
+		}
+		k := placeKey{p.ID, p.URL}
+		if i, ok := seen[k]; ok {
+			if p.LastVisitDate.After(places[i].LastVisitDate) {
+				places[i] = p
+			}
+			continue
+		}
+		seen[k] = len(places)
+		places = append(places, p)
+	}
+	sort.SliceStable(places, func(i, j int) bool { return places[i].ID < places[j].ID })
+	return places, nil
+}
+
+// recoveredPlace converts a record with the shape of a row in
+// moz_places. The reversed host distinguishes it from rows with URLs in
+// other tables, such as moz_origins.
+func recoveredPlace(r *sqliteutil.RecoveredRecord) (RecoveredPlace, bool) {
+	var p RecoveredPlace
+	v := r.Values
+	if len(v) < 10 || v[0] != nil {
+		return p, false
+	}
+	url, ok1 := v[1].(string)
+	visitCount, ok2 := v[4].(int64)
+	hidden, ok3 := v[5].(int64)
+	typed, ok4 := v[6].(int64)
+	frecency, ok5 := v[7].(int64)
+	title, _ := v[2].(string)
+	revHost, _ := v[3].(string)
+	guid, _ := v[9].(string)
+	lastVisit, _ := v[8].(int64)
+	if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 || !strings.Contains(url, ":") ||
+		v[3] != nil && !strings.HasSuffix(revHost, ".") ||
+		v[9] != nil && len(guid) != 12 ||
+		lastVisit != 0 && (lastVisit < minRecoveredPlacesTime || lastVisit > maxRecoveredPlacesTime) {
+		return p, false
+	}
+	var t time.Time
+	if lastVisit != 0 {
+		t = timefmt.FromInt(lastVisit, 0, timefmt.Micro, timefmt.Unix)
+	}
+	return RecoveredPlace{
+		ID:            r.RowID,
+		URL:           url,
+		Title:         title,
+		RevHost:       revHost,
+		VisitCount:    int(visitCount),
+		Hidden:        hidden != 0,
+		Typed:         typed != 0,
+		Frecency:      int(frecency),
+		LastVisitDate: t,
+		GUID:          guid,
+		Source:        r.Source,
+	}, true
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+func TestRecoverPlaces(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "places.sqlite")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	for _, q := range []string{
+		`PRAGMA journal_mode = WAL`,
+		`PRAGMA wal_autocheckpoint = 0`,
+		`CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR,
+			rev_host LONGVARCHAR, visit_count INTEGER DEFAULT 0, hidden INTEGER DEFAULT 0 NOT NULL,
+			typed INTEGER DEFAULT 0 NOT NULL, frecency INTEGER DEFAULT -1 NOT NULL, last_visit_date INTEGER,
+			guid TEXT, foreign_count INTEGER DEFAULT 0 NOT NULL, url_hash INTEGER DEFAULT 0 NOT NULL,
+			description TEXT, preview_image_url TEXT, origin_id INTEGER)`,
+		`CREATE TABLE moz_origins (id INTEGER PRIMARY KEY, prefix TEXT NOT NULL, host TEXT NOT NULL,
+			frecency INTEGER NOT NULL)`,
+		`INSERT INTO moz_origins VALUES (1, 'https://', 'example.com', 100)`,
+		`INSERT INTO moz_places VALUES (1, 'https://example.com/', 'Example', 'moc.elpmaxe.', 1, 0, 0, 100,
+			1613606400000000, 'abcdefghijkl', 0, 1, NULL, NULL, 1)`,
+		`INSERT INTO moz_places VALUES (2, 'https://example.com/secret', 'Secret', 'moc.elpmaxe.', 2, 0, 1, 200,
+			1613610000000000, 'mnopqrstuvwx', 0, 2, NULL, NULL, 1)`,
+		`DELETE FROM moz_places WHERE id = 2`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	places, err := RecoverPlaces(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(places) != 1 {
+		t.Fatalf("got places %+v", places)
+	}
+	p := places[0]
+	want := time.Date(2021, 2, 18, 1, 0, 0, 0, time.UTC)
+	if p.ID != 2 || p.URL != "https://example.com/secret" || p.Title != "Secret" || p.RevHost != "moc.elpmaxe." ||
+		p.VisitCount != 2 || !p.Typed || p.Frecency != 200 || !p.LastVisitDate.Equal(want) ||
+		p.GUID != "mnopqrstuvwx" || p.Source != sqliteutil.SourceWAL {
+		t.Errorf("got place %+v", p)
+	}
+}
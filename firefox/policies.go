@@ -0,0 +1,166 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+// Enterprise policies are read from distribution/policies.json in the
+// installation directory or, on Linux, /etc/firefox/policies.
+// https://github.com/mozilla/policy-templates
+// https://searchfox.org/mozilla-central/source/browser/components/enterprisepolicies/schemas/policies-schema.json
+
+// PolicyFiles are the system-wide locations of policies.json, in
+// addition to distribution/policies.json in the installation directory.
+var PolicyFiles = []string{
+	"/etc/firefox/policies/policies.json",
+}
+
+// Policies contains enterprise policies in policies.json. Most of the
+// hundreds of policies are kept raw; accessors decode those commonly
+// needed for auditing.
+type Policies struct {
+	Policies map[string]json.RawMessage `json:"policies"` // key: policy name, e.g. "DisableTelemetry"
+}
+
+// HomepagePolicy is the Homepage policy.
+type HomepagePolicy struct {
+	URL        string   `json:"URL,omitempty"`
+	Locked     bool     `json:"Locked,omitempty"`
+	Additional []string `json:"Additional,omitempty"`
+	StartPage  string   `json:"StartPage,omitempty"` // "none", "homepage", "previous-session", or "homepage-locked"
+}
+
+// ExtensionPolicy is the policy for an extension, or for all extensions
+// with the key "*", in the ExtensionSettings policy.
+type ExtensionPolicy struct {
+	InstallationMode               string   `json:"installation_mode,omitempty"` // "allowed", "blocked", "force_installed", or "normal_installed"
+	InstallURL                     string   `json:"install_url,omitempty"`
+	BlockedInstallMessage          string   `json:"blocked_install_message,omitempty"`
+	InstallSources                 []string `json:"install_sources,omitempty"`
+	AllowedTypes                   []string `json:"allowed_types,omitempty"`
+	RestrictedDomains              []string `json:"restricted_domains,omitempty"`
+	UpdatesDisabled                bool     `json:"updates_disabled,omitempty"`
+	DefaultArea                    string   `json:"default_area,omitempty"` // "navbar" or "menupanel"
+	PrivateBrowsing                bool     `json:"private_browsing,omitempty"`
+	TemporarilyAllowWeakSignatures bool     `json:"temporarily_allow_weak_signatures,omitempty"`
+}
+
+// ExtensionsPolicy is the older Extensions policy.
+type ExtensionsPolicy struct {
+	Install   []string `json:"Install,omitempty"`   // URLs or paths
+	Uninstall []string `json:"Uninstall,omitempty"` // IDs
+	Locked    []string `json:"Locked,omitempty"`    // IDs
+}
+
+// ParsePolicies parses policies.json.
+func ParsePolicies(filename string) (*Policies, error) {
+	var policies Policies
+	if err := jsonutil.DecodeFile(filename, &policies); err != nil {
+		return nil, err
+	}
+	return &policies, nil
+}
+
+// Names returns the names of the set policies in order.
+func (p *Policies) Names() []string {
+	names := make([]string, 0, len(p.Policies))
+	for name := range p.Policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DisabledFeatures returns the names of the Disable* policies that are
+// set to true, e.g. "DisableTelemetry", in order.
+func (p *Policies) DisabledFeatures() []string {
+	var disabled []string
+	for _, name := range p.Names() {
+		if strings.HasPrefix(name, "Disable") && bytes.Equal(bytes.TrimSpace(p.Policies[name]), []byte("true")) {
+			disabled = append(disabled, name)
+		}
+	}
+	return disabled
+}
+
+// Homepage returns the Homepage policy, or nil when it is not set.
+func (p *Policies) Homepage() (*HomepagePolicy, error) {
+	var h *HomepagePolicy
+	if err := p.decode("Homepage", &h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// ExtensionSettings returns the ExtensionSettings policy, keyed by
+// extension ID or "*" for the default.
+func (p *Policies) ExtensionSettings() (map[string]ExtensionPolicy, error) {
+	var settings map[string]ExtensionPolicy
+	if err := p.decode("ExtensionSettings", &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// Extensions returns the Extensions policy, or nil when it is not set.
+func (p *Policies) Extensions() (*ExtensionsPolicy, error) {
+	var e *ExtensionsPolicy
+	if err := p.decode("Extensions", &e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// BlockedExtensions returns the IDs of extensions that are blocked by
+// ExtensionSettings or uninstalled by Extensions, in order. The ID "*"
+// means that all extensions not otherwise allowed are blocked.
+func (p *Policies) BlockedExtensions() ([]string, error) {
+	settings, err := p.ExtensionSettings()
+	if err != nil {
+		return nil, err
+	}
+	e, err := p.Extensions()
+	if err != nil {
+		return nil, err
+	}
+	blocked := make(map[string]struct{})
+	for id, s := range settings {
+		if s.InstallationMode == "blocked" {
+			blocked[id] = struct{}{}
+		}
+	}
+	if e != nil {
+		for _, id := range e.Uninstall {
+			blocked[id] = struct{}{}
+		}
+	}
+	ids := make([]string, 0, len(blocked))
+	for id := range blocked {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (p *Policies) decode(name string, v interface{}) error {
+	raw, ok := p.Policies[name]
+	if !ok {
+		return nil
+	}
+	if err := jsonutil.Decode(bytes.NewReader(raw), v); err != nil {
+		return fmt.Errorf("firefox: policy %s: %w", name, err)
+	}
+	return nil
+}
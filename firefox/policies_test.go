@@ -0,0 +1,140 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPolicies(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "policies.json")
+	if err := os.WriteFile(filename, []byte(`{
+  "policies": {
+    "DisableTelemetry": true,
+    "DisablePocket": false,
+    "DisableFirefoxStudies": true,
+    "DontCheckDefaultBrowser": true,
+    "Homepage": {
+      "URL": "https://intranet.example/",
+      "Locked": true,
+      "Additional": ["https://wiki.example/"],
+      "StartPage": "homepage"
+    },
+    "ExtensionSettings": {
+      "*": {"installation_mode": "blocked", "blocked_install_message": "Ask IT"},
+      "ublock0@raymondhill.net": {"installation_mode": "force_installed",
+        "install_url": "https://addons.mozilla.org/firefox/downloads/latest/ublock-origin/latest.xpi"},
+      "bad@example.com": {"installation_mode": "blocked"}
+    },
+    "Extensions": {
+      "Install": ["https://example.com/addon.xpi"],
+      "Uninstall": ["old@example.com", "bad@example.com"],
+      "Locked": ["ublock0@raymondhill.net"]
+    }
+  }
+}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := ParsePolicies(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantNames := []string{"DisableFirefoxStudies", "DisablePocket", "DisableTelemetry",
+		"DontCheckDefaultBrowser", "ExtensionSettings", "Extensions", "Homepage"}
+	if names := p.Names(); !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("got names %q, want %q", names, wantNames)
+	}
+	// DisablePocket is set to false, so Pocket is not disabled.
+	wantDisabled := []string{"DisableFirefoxStudies", "DisableTelemetry"}
+	if disabled := p.DisabledFeatures(); !reflect.DeepEqual(disabled, wantDisabled) {
+		t.Errorf("got disabled %q, want %q", disabled, wantDisabled)
+	}
+
+	home, err := p.Homepage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHome := &HomepagePolicy{
+		URL:        "https://intranet.example/",
+		Locked:     true,
+		Additional: []string{"https://wiki.example/"},
+		StartPage:  "homepage",
+	}
+	if !reflect.DeepEqual(home, wantHome) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", home, wantHome)
+	}
+
+	settings, err := p.ExtensionSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSettings := map[string]ExtensionPolicy{
+		"*": {InstallationMode: "blocked", BlockedInstallMessage: "Ask IT"},
+		"ublock0@raymondhill.net": {InstallationMode: "force_installed",
+			InstallURL: "https://addons.mozilla.org/firefox/downloads/latest/ublock-origin/latest.xpi"},
+		"bad@example.com": {InstallationMode: "blocked"},
+	}
+	if !reflect.DeepEqual(settings, wantSettings) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", settings, wantSettings)
+	}
+
+	ext, err := p.Extensions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantExt := &ExtensionsPolicy{
+		Install:   []string{"https://example.com/addon.xpi"},
+		Uninstall: []string{"old@example.com", "bad@example.com"},
+		Locked:    []string{"ublock0@raymondhill.net"},
+	}
+	if !reflect.DeepEqual(ext, wantExt) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", ext, wantExt)
+	}
+
+	// Blocked IDs are from both policies, without duplicates.
+	blocked, err := p.BlockedExtensions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBlocked := []string{"*", "bad@example.com", "old@example.com"}
+	if !reflect.DeepEqual(blocked, wantBlocked) {
+		t.Errorf("got blocked %q, want %q", blocked, wantBlocked)
+	}
+}
+
+func TestPoliciesUnset(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "policies.json")
+	if err := os.WriteFile(filename, []byte(`{"policies": {"DisableTelemetry": false}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := ParsePolicies(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disabled := p.DisabledFeatures(); len(disabled) != 0 {
+		t.Errorf("got disabled %q, want none", disabled)
+	}
+	if home, err := p.Homepage(); home != nil || err != nil {
+		t.Errorf("got %+v, %v, want no Homepage policy", home, err)
+	}
+	if ext, err := p.Extensions(); ext != nil || err != nil {
+		t.Errorf("got %+v, %v, want no Extensions policy", ext, err)
+	}
+	if blocked, err := p.BlockedExtensions(); len(blocked) != 0 || err != nil {
+		t.Errorf("got %q, %v, want no blocked extensions", blocked, err)
+	}
+
+	// A policy of the wrong type is an error.
+	p.Policies["Homepage"] = []byte(`"https://example.com/"`)
+	if _, err := p.Homepage(); err == nil {
+		t.Error("expected error for Homepage of wrong type")
+	}
+}
@@ -0,0 +1,528 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/andrewarchi/browser"
+)
+
+// Preferences file format:
+// https://searchfox.org/mozilla-central/source/modules/libpref/parser/src/lib.rs
+//
+// prefs.js, user.js, and default preference files are a sequence of
+// calls of pref, sticky_pref, or user_pref with a name and a boolean,
+// integer, or string value. Default values set by pref may have the
+// attributes locked and sticky. Comments are "//", "#", or "/* */".
+
+// Prefs are preferences by name.
+type Prefs map[string]*Preference
+
+// Preference is a preference with a default or user value. Values are
+// bool, int, or string and are nil when unset.
+type Preference struct {
+	Default interface{} // set by pref or sticky_pref
+	User    interface{} // set by user_pref
+	Locked  bool        // the default value is used, even if a user value is set
+	Sticky  bool        // a user value equal to the default value is kept
+}
+
+// Value returns the effective value of the preference.
+func (p *Preference) Value() interface{} {
+	if p.User != nil && !p.Locked {
+		return p.User
+	}
+	return p.Default
+}
+
+// ParsePrefs parses prefs.js or user.js in a Firefox profile, or a
+// default preferences file such as greprefs.js.
+func ParsePrefs(filename string) (Prefs, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return DecodePrefs(f)
+}
+
+// ProfilePrefs reads prefs.js and user.js in a Firefox profile. Values
+// in user.js override those in prefs.js, as when Firefox starts. Files
+// that fail to parse are handled as by ProfilePrefsOptions with the
+// zero Options.
+func ProfilePrefs(profileDir string) (Prefs, error) {
+	return ProfilePrefsOptions(profileDir, browser.Options{})
+}
+
+// ProfilePrefsOptions reads the prefs of a Firefox profile and handles
+// files that fail to parse by the error policy in opts. When prefs.js
+// is skipped, the prefs in user.js are returned alone.
+func ProfilePrefsOptions(profileDir string, opts browser.Options) (Prefs, error) {
+	h := opts.Handler()
+	filename := filepath.Join(profileDir, "prefs.js")
+	prefs, err := ParsePrefs(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	} else if err != nil {
+		if err := h.File(filename, err); err != nil {
+			return nil, err
+		}
+		prefs = make(Prefs)
+	}
+	filename = filepath.Join(profileDir, "user.js")
+	user, err := ParsePrefs(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return prefs, h.Err()
+	} else if err != nil {
+		if err := h.File(filename, err); err != nil {
+			return nil, err
+		}
+		return prefs, h.Err()
+	}
+	for name, p := range user {
+		if q, ok := prefs[name]; ok {
+			if p.Default != nil {
+				q.Default, q.Locked, q.Sticky = p.Default, p.Locked, p.Sticky
+			}
+			if p.User != nil {
+				q.User = p.User
+			}
+		} else {
+			prefs[name] = p
+		}
+	}
+	return prefs, h.Err()
+}
+
+// DecodePrefs parses preferences from r. Later values for a name
+// override earlier ones.
+func DecodePrefs(r io.Reader) (Prefs, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := prefsParser{data: data, line: 1}
+	prefs := make(Prefs)
+	for {
+		if err := p.parsePref(prefs); err == io.EOF {
+			return prefs, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("firefox: prefs: line %d: %w", p.line, err)
+		}
+	}
+}
+
+type prefsParser struct {
+	data []byte
+	pos  int
+	line int
+}
+
+type prefsToken uint8
+
+const (
+	tokenEOF prefsToken = iota
+	tokenIdent
+	tokenString
+	tokenInt
+	tokenPunct
+)
+
+func (p *prefsParser) parsePref(prefs Prefs) error {
+	typ, fn, err := p.next()
+	if err != nil {
+		return err
+	}
+	if typ == tokenEOF {
+		return io.EOF
+	}
+	if typ != tokenIdent || fn != "pref" && fn != "sticky_pref" && fn != "user_pref" {
+		return fmt.Errorf("expected pref, sticky_pref, or user_pref, got %q", fn)
+	}
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	typ, name, err := p.next()
+	if err != nil {
+		return err
+	}
+	if typ != tokenString {
+		return fmt.Errorf("expected pref name string, got %q", name)
+	}
+	if err := p.expect(","); err != nil {
+		return err
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return fmt.Errorf("pref %q: %w", name, err)
+	}
+
+	var locked, sticky bool
+	for {
+		typ, tok, err := p.next()
+		if err != nil {
+			return err
+		}
+		if typ == tokenPunct && tok == ")" {
+			break
+		}
+		if typ != tokenPunct || tok != "," {
+			return fmt.Errorf("pref %q: expected ',' or ')', got %q", name, tok)
+		}
+		typ, attr, err := p.next()
+		if err != nil {
+			return err
+		}
+		if typ != tokenIdent || attr != "locked" && attr != "sticky" {
+			return fmt.Errorf("pref %q: expected locked or sticky, got %q", name, attr)
+		}
+		if fn == "user_pref" {
+			return fmt.Errorf("pref %q: user_pref does not allow attributes", name)
+		}
+		if attr == "locked" {
+			locked = true
+		} else {
+			sticky = true
+		}
+	}
+	if err := p.expect(";"); err != nil {
+		return err
+	}
+
+	pref, ok := prefs[name]
+	if !ok {
+		pref = &Preference{}
+		prefs[name] = pref
+	}
+	if fn == "user_pref" {
+		pref.User = value
+	} else {
+		pref.Default = value
+		pref.Locked = locked
+		pref.Sticky = sticky || fn == "sticky_pref"
+	}
+	return nil
+}
+
+func (p *prefsParser) parseValue() (interface{}, error) {
+	typ, tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case typ == tokenString:
+		return tok, nil
+	case typ == tokenIdent && tok == "true":
+		return true, nil
+	case typ == tokenIdent && tok == "false":
+		return false, nil
+	case typ == tokenInt:
+		return p.parseInt(tok, false)
+	case typ == tokenPunct && (tok == "-" || tok == "+"):
+		typ, num, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if typ != tokenInt {
+			return nil, fmt.Errorf("expected integer after %q, got %q", tok, num)
+		}
+		return p.parseInt(num, tok == "-")
+	}
+	return nil, fmt.Errorf("expected value, got %q", tok)
+}
+
+// parseInt parses an integer value, which must fit in 32 bits.
+func (p *prefsParser) parseInt(num string, neg bool) (interface{}, error) {
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err == nil && neg {
+		n = -n
+	}
+	if err != nil || n < -1<<31 || n > 1<<31-1 {
+		return nil, fmt.Errorf("integer out of range: %s", num)
+	}
+	return int(n), nil
+}
+
+func (p *prefsParser) expect(punct string) error {
+	typ, tok, err := p.next()
+	if err != nil {
+		return err
+	}
+	if typ != tokenPunct || tok != punct {
+		return fmt.Errorf("expected %q, got %q", punct, tok)
+	}
+	return nil
+}
+
+// next scans the next token, skipping whitespace and comments.
+func (p *prefsParser) next() (prefsToken, string, error) {
+	if err := p.skipSpace(); err != nil {
+		return tokenEOF, "", err
+	}
+	if p.pos >= len(p.data) {
+		return tokenEOF, "", nil
+	}
+	c := p.data[p.pos]
+	switch {
+	case c == '"' || c == '\'':
+		s, err := p.scanString(c)
+		return tokenString, s, err
+	case '0' <= c && c <= '9':
+		start := p.pos
+		for p.pos < len(p.data) && '0' <= p.data[p.pos] && p.data[p.pos] <= '9' {
+			p.pos++
+		}
+		return tokenInt, string(p.data[start:p.pos]), nil
+	case 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_':
+		start := p.pos
+		for p.pos < len(p.data) && isIdentByte(p.data[p.pos]) {
+			p.pos++
+		}
+		return tokenIdent, string(p.data[start:p.pos]), nil
+	case strings.IndexByte("(),;-+", c) >= 0:
+		p.pos++
+		return tokenPunct, string(c), nil
+	}
+	return tokenEOF, "", fmt.Errorf("unexpected character %q", c)
+}
+
+func isIdentByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_'
+}
+
+func (p *prefsParser) skipSpace() error {
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case c == '\n':
+			p.line++
+			p.pos++
+		case c == '\r':
+			// CR and CRLF are line endings.
+			p.pos++
+			if p.pos >= len(p.data) || p.data[p.pos] != '\n' {
+				p.line++
+			}
+		case c == ' ' || c == '\t':
+			p.pos++
+		case c == '#' || c == '/' && p.peek(1) == '/':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+		case c == '/' && p.peek(1) == '*':
+			end := strings.Index(string(p.data[p.pos+2:]), "*/")
+			if end < 0 {
+				return errors.New("unterminated /* comment")
+			}
+			comment := p.data[p.pos : p.pos+2+end+2]
+			p.line += strings.Count(string(comment), "\n")
+			p.pos += len(comment)
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (p *prefsParser) peek(n int) byte {
+	if p.pos+n < len(p.data) {
+		return p.data[p.pos+n]
+	}
+	return 0
+}
+
+// scanString scans a string quoted with " or '. Escapes are \", \',
+// \\, \n, \r, \xHH, and \uHHHH, where UTF-16 surrogates must be paired.
+func (p *prefsParser) scanString(quote byte) (string, error) {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.data) {
+			return "", errors.New("unterminated string")
+		}
+		c := p.data[p.pos]
+		switch c {
+		case quote:
+			p.pos++
+			return b.String(), nil
+		case 0:
+			return "", errors.New("NUL in string")
+		case '\n':
+			p.line++
+		case '\r':
+			if p.peek(1) != '\n' {
+				p.line++
+			}
+		case '\\':
+			p.pos++
+			if p.pos >= len(p.data) {
+				return "", errors.New("unterminated string")
+			}
+			switch e := p.data[p.pos]; e {
+			case '"', '\'', '\\':
+				b.WriteByte(e)
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 'x':
+				r, err := p.scanHex(2)
+				if err != nil {
+					return "", err
+				}
+				if r == 0 {
+					return "", errors.New(`\x00 in string`)
+				}
+				b.WriteRune(r)
+			case 'u':
+				r, err := p.scanHex(4)
+				if err != nil {
+					return "", err
+				}
+				if utf16.IsSurrogate(r) {
+					if r >= 0xdc00 || p.peek(1) != '\\' || p.peek(2) != 'u' {
+						return "", fmt.Errorf(`unpaired surrogate \u%04x`, r)
+					}
+					p.pos += 2
+					r2, err := p.scanHex(4)
+					if err != nil {
+						return "", err
+					}
+					if r = utf16.DecodeRune(r, r2); r == utf8.RuneError {
+						return "", fmt.Errorf(`invalid surrogate pair \u%04x`, r2)
+					}
+				}
+				if r == 0 {
+					return "", errors.New(`\u0000 in string`)
+				}
+				b.WriteRune(r)
+			default:
+				return "", fmt.Errorf("invalid escape %q", `\`+string(e))
+			}
+			p.pos++
+			continue
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+}
+
+// scanHex scans n hex digits following the current position and leaves
+// the position at the last digit.
+func (p *prefsParser) scanHex(n int) (rune, error) {
+	if p.pos+n >= len(p.data) {
+		return 0, errors.New("truncated escape")
+	}
+	v, err := strconv.ParseUint(string(p.data[p.pos+1:p.pos+1+n]), 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid escape %q", `\`+string(p.data[p.pos:p.pos+1+n]))
+	}
+	p.pos += n
+	return rune(v), nil
+}
+
+const prefsHeader = `// Mozilla User Preferences
+
+// DO NOT EDIT THIS FILE.
+//
+// If you make changes to this file while the application is running,
+// the changes will be overwritten when the application exits.
+//
+// To change a preference value, you can either:
+// - modify it via the UI (e.g. via about:config in the browser); or
+// - set it within a user.js file in your profile.
+
+`
+
+// WritePrefs writes preferences in prefs.js format, ordered by name,
+// with the header written by Firefox. Default values are written with
+// pref and user values with user_pref.
+func WritePrefs(w io.Writer, prefs Prefs) error {
+	names := make([]string, 0, len(prefs))
+	for name := range prefs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	bw := bufio.NewWriter(w)
+	bw.WriteString(prefsHeader)
+	for _, name := range names {
+		p := prefs[name]
+		if p.Default != nil {
+			v, err := formatPrefValue(p.Default)
+			if err != nil {
+				return fmt.Errorf("firefox: prefs: pref %q: %w", name, err)
+			}
+			fmt.Fprintf(bw, "pref(%s, %s", quotePref(name), v)
+			if p.Locked {
+				bw.WriteString(", locked")
+			}
+			if p.Sticky {
+				bw.WriteString(", sticky")
+			}
+			bw.WriteString(");\n")
+		}
+		if p.User != nil {
+			v, err := formatPrefValue(p.User)
+			if err != nil {
+				return fmt.Errorf("firefox: prefs: pref %q: %w", name, err)
+			}
+			fmt.Fprintf(bw, "user_pref(%s, %s);\n", quotePref(name), v)
+		}
+	}
+	return bw.Flush()
+}
+
+func formatPrefValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		if v < -1<<31 || v > 1<<31-1 {
+			return "", fmt.Errorf("integer out of range: %d", v)
+		}
+		return strconv.Itoa(v), nil
+	case string:
+		if strings.IndexByte(v, 0) >= 0 {
+			return "", errors.New("NUL in string")
+		}
+		return quotePref(v), nil
+	}
+	return "", fmt.Errorf("unsupported value type %T", v)
+}
+
+// quotePref quotes a string as Firefox does, escaping only backslash,
+// double quote, and line endings.
+func quotePref(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/andrewarchi/browser"
+)
+
+func TestDecodePrefs(t *testing.T) {
+	data := `// Mozilla User Preferences
+# hash comment
+/* block
+   comment */
+user_pref("browser.startup.page", 3);
+user_pref('app.update.lastUpdateTime.addon-background-update-timer', 1612345678);
+user_pref("browser.newtabpage.enabled", false);
+user_pref("quotes", "a \"b\" 'c' \\ \n\r");
+user_pref("escapes", "\x41é😀");
+pref("general.config.obscure_value", -0, locked);
+pref("min", -2147483648, sticky, locked);
+sticky_pref("browser.sticky", true);
+pref("browser.startup.page", 1);
+user_pref("overridden", 1);
+user_pref("overridden", "two");
+`
+	prefs, err := DecodePrefs(strings.NewReader(strings.ReplaceAll(data, "\n", "\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Prefs{
+		"browser.startup.page": {Default: 1, User: 3},
+		"app.update.lastUpdateTime.addon-background-update-timer": {User: 1612345678},
+		"browser.newtabpage.enabled":                              {User: false},
+		"quotes":                                                  {User: "a \"b\" 'c' \\ \n\r"},
+		"escapes":                                                 {User: "Aé😀"},
+		"general.config.obscure_value":                            {Default: 0, Locked: true},
+		"min":                                                     {Default: -2147483648, Locked: true, Sticky: true},
+		"browser.sticky":                                          {Default: true, Sticky: true},
+		"overridden":                                              {User: "two"},
+	}
+	if !reflect.DeepEqual(prefs, want) {
+		for name, p := range prefs {
+			if !reflect.DeepEqual(p, want[name]) {
+				t.Errorf("%s: got: %+v, want: %+v", name, p, want[name])
+			}
+		}
+		t.Errorf("got %d prefs, want %d", len(prefs), len(want))
+	}
+	if v := prefs["browser.startup.page"].Value(); v != 3 {
+		t.Errorf("got value %v, want 3", v)
+	}
+
+	var buf bytes.Buffer
+	if err := WritePrefs(&buf, prefs); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "pref(\"min\", -2147483648, locked, sticky);\n") {
+		t.Errorf("missing attributes in:\n%s", buf.String())
+	}
+	roundTrip, err := DecodePrefs(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(roundTrip, prefs) {
+		t.Errorf("round trip differs:\ngot:  %v\nwant: %v", roundTrip, prefs)
+	}
+}
+
+func TestDecodePrefsErrors(t *testing.T) {
+	tests := []struct {
+		Data string
+		Err  string
+	}{
+		{`user_pref("a", 1)`, `firefox: prefs: line 1: expected ";", got ""`},
+		{"\n\nuser_pref(\"a\", 1, locked);", `firefox: prefs: line 3: pref "a": user_pref does not allow attributes`},
+		{`pref("a", 2147483648);`, `firefox: prefs: line 1: pref "a": integer out of range: 2147483648`},
+		{`pref("a", "\q");`, `firefox: prefs: line 1: pref "a": invalid escape "\\q"`},
+		{`pref("a", "\ud83d");`, `firefox: prefs: line 1: pref "a": unpaired surrogate \ud83d`},
+		{`pref("a", 'b);`, `firefox: prefs: line 1: pref "a": unterminated string`},
+		{`lockPref("a", 1);`, `firefox: prefs: line 1: expected pref, sticky_pref, or user_pref, got "lockPref"`},
+		{"/* a\n", `firefox: prefs: line 1: unterminated /* comment`},
+	}
+	for i, tt := range tests {
+		_, err := DecodePrefs(strings.NewReader(tt.Data))
+		if err == nil || err.Error() != tt.Err {
+			t.Errorf("#%d: got: %v, want: %s", i, err, tt.Err)
+		}
+	}
+}
+
+func TestProfilePrefsOptions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "prefs.js"), []byte(`user_pref("a", 1);`+"\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	user := filepath.Join(dir, "user.js")
+	if err := os.WriteFile(user, []byte(`user_pref("b", 2)`), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, policy := range []browser.ErrorPolicy{browser.Collect, browser.SkipRecord, browser.SkipFile} {
+		prefs, err := ProfilePrefsOptions(dir, browser.Options{OnError: policy})
+		var errs browser.Errors
+		if policy == browser.Collect {
+			if !errors.As(err, &errs) || len(errs) != 1 || errs[0].File != user {
+				t.Errorf("%s: got error %v, want error for user.js", policy, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: got error %v", policy, err)
+		}
+		if _, ok := prefs["a"]; !ok || len(prefs) != 1 {
+			t.Errorf("%s: got prefs %v, want prefs.js only", policy, prefs)
+		}
+	}
+	prefs, err := ProfilePrefsOptions(dir, browser.Options{OnError: browser.FailFast})
+	var e *browser.Error
+	if !errors.As(err, &e) || e.File != user || prefs != nil {
+		t.Errorf("fail-fast: got %v, %v, want error for user.js", prefs, err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "prefs.js")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProfilePrefs(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v, want %v", err, os.ErrNotExist)
+	}
+}
@@ -15,9 +15,12 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/andrewarchi/browser"
 	"github.com/andrewarchi/browser/iniutil"
 	"gopkg.in/ini.v1"
 )
@@ -51,6 +54,184 @@ func ProfilesDir() (string, error) {
 	}
 }
 
+// Dir returns the path for the Firefox root directory, which contains
+// profiles.ini and installs.ini. On Linux, it is the same as
+// ProfilesDir.
+func Dir() (string, error) {
+	if runtime.GOOS == "windows" {
+		if appdata := os.Getenv("AppData"); appdata != "" {
+			return appdata + `\Mozilla\Firefox`, nil
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	switch runtime.GOOS {
+	case "windows":
+		return home + `\AppData\Roaming\Mozilla\Firefox`, nil
+	case "darwin":
+		return home + "/Library/Application Support/Firefox", nil
+	case "linux":
+		return home + "/.mozilla/firefox", nil
+	default:
+		return "", fmt.Errorf("firefox: unsupported GOOS: %s", runtime.GOOS)
+	}
+}
+
+// ProfileEntry is a profile listed in profiles.ini, with its directory
+// resolved and its defaults and lock status.
+type ProfileEntry struct {
+	ID             int    // sequential (e.g. 0, 1, 2)
+	Name           string // e.g. "default", "default-release"
+	Dir            string // absolute path
+	Default        bool   // default profile for installs without a dedicated profile
+	InstallDefault bool   // default profile of an install, in installs.ini
+	InstallLocked  bool   // install is locked to this profile
+	Locked         bool   // in use by a running Firefox
+}
+
+// Profiles lists the Firefox profiles for the current user from
+// profiles.ini and installs.ini in the Firefox root.
+func Profiles() ([]ProfileEntry, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	return ListProfiles(dir)
+}
+
+// ListProfiles lists the profiles in profiles.ini and installs.ini in a
+// Firefox root, ordered by ID. installs.ini is optional.
+//
+// Lock status is best effort: on Linux, a running Firefox holds the
+// "lock" symlink, which remains after a crash, and on Windows, it
+// opens "parent.lock" exclusively. On macOS, profiles are never
+// reported as locked.
+func ListProfiles(firefoxDir string) ([]ProfileEntry, error) {
+	info, err := ParseProfiles(firefoxDir)
+	if err != nil {
+		return nil, err
+	}
+	installs, err := ParseInstalls(firefoxDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	installs = append(info.Installs, installs...)
+
+	profiles := make([]ProfileEntry, len(info.Profiles))
+	for i, p := range info.Profiles {
+		e := ProfileEntry{
+			ID:      p.ID,
+			Name:    p.Name,
+			Dir:     p.AbsPath(firefoxDir),
+			Default: p.Default,
+		}
+		for _, install := range installs {
+			if install.Default == p.Path {
+				e.InstallDefault = true
+				e.InstallLocked = e.InstallLocked || install.Locked
+			}
+		}
+		e.Locked = isProfileLocked(e.Dir)
+		profiles[i] = e
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].ID < profiles[j].ID
+	})
+	return profiles, nil
+}
+
+func isProfileLocked(profileDir string) bool {
+	switch runtime.GOOS {
+	case "windows":
+		f, err := os.OpenFile(filepath.Join(profileDir, "parent.lock"), os.O_RDWR, 0)
+		if err != nil {
+			return !errors.Is(err, os.ErrNotExist)
+		}
+		f.Close()
+		return false
+	case "darwin":
+		return false
+	default:
+		_, err := os.Lstat(filepath.Join(profileDir, "lock"))
+		return err == nil
+	}
+}
+
+// ProfileMetadata is the age of a profile, from times.json, and the
+// last version of Firefox to use it, from compatibility.ini.
+type ProfileMetadata struct {
+	Created         time.Time
+	FirstUse        time.Time // zero until the first session after creation
+	Reset           time.Time // zero unless the profile was refreshed
+	Version         string    // e.g. "85.0"
+	AppBuildID      string    // e.g. "20210118153634"
+	PlatformBuildID string
+	OSABI           string // e.g. "Linux_x86_64-gcc3"
+	PlatformDir     string // directory of the Firefox install
+	AppDir          string
+}
+
+// ParseProfileMetadata reads times.json and compatibility.ini in a
+// Firefox profile. Either may be missing, in which case its fields are
+// zero, but not both. Files that fail to parse are handled as by
+// ParseProfileMetadataOptions with the zero Options.
+func ParseProfileMetadata(profileDir string) (*ProfileMetadata, error) {
+	return ParseProfileMetadataOptions(profileDir, browser.Options{})
+}
+
+// ParseProfileMetadataOptions reads the metadata of a Firefox profile
+// and handles files that fail to parse by the error policy in opts. The
+// fields of a skipped file are zero.
+func ParseProfileMetadataOptions(profileDir string, opts browser.Options) (*ProfileMetadata, error) {
+	h := opts.Handler()
+	timesFile := filepath.Join(profileDir, "times.json")
+	times, err := ParseTimes(timesFile)
+	compatFile := filepath.Join(profileDir, "compatibility.ini")
+	compat, err2 := ParseCompatibility(compatFile)
+	if errors.Is(err, os.ErrNotExist) && errors.Is(err2, os.ErrNotExist) {
+		return nil, err
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		if err := h.File(timesFile, err); err != nil {
+			return nil, err
+		}
+		times = nil
+	}
+	if err2 != nil && !errors.Is(err2, os.ErrNotExist) {
+		if err := h.File(compatFile, err2); err != nil {
+			return nil, err
+		}
+		compat = nil
+	}
+	var meta ProfileMetadata
+	if times != nil {
+		meta.Created = times.Created.Time
+		meta.FirstUse = times.FirstUse.Time
+		if times.Reset != nil {
+			meta.Reset = times.Reset.Time
+		}
+	}
+	if compat != nil {
+		meta.Version, meta.AppBuildID, meta.PlatformBuildID = compat.Version()
+		meta.OSABI = compat.LastOSABI
+		meta.PlatformDir = compat.LastPlatformDir
+		meta.AppDir = compat.LastAppDir
+	}
+	return &meta, h.Err()
+}
+
+// Age returns the time since the profile was created, or zero when the
+// creation time is unknown.
+func (m *ProfileMetadata) Age(now time.Time) time.Duration {
+	if m.Created.IsZero() {
+		return 0
+	}
+	return now.Sub(m.Created)
+}
+
 // ProfileInfo contains Firefox profiles and installs.
 type ProfileInfo struct {
 	StartWithLastProfile bool
@@ -0,0 +1,147 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestListProfiles(t *testing.T) {
+	dir := t.TempDir()
+	profilesINI := `[Install308046B0AF4A39CB]
+Default=Profiles/def456.default-release
+Locked=1
+
+[Profile1]
+Name=default
+IsRelative=1
+Path=Profiles/abc123.default
+Default=1
+
+[Profile0]
+Name=default-release
+IsRelative=1
+Path=Profiles/def456.default-release
+
+[General]
+StartWithLastProfile=1
+Version=2
+`
+	installsINI := `[308046B0AF4A39CB]
+Default=Profiles/def456.default-release
+Locked=1
+`
+	for name, data := range map[string]string{"profiles.ini": profilesINI, "installs.ini": installsINI} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	release := filepath.Join(dir, "Profiles", "def456.default-release")
+	if err := os.MkdirAll(release, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	locked := runtime.GOOS == "linux"
+	if locked {
+		if err := os.Symlink("127.0.1.1:+12345", filepath.Join(release, "lock")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	profiles, err := ListProfiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ProfileEntry{
+		{ID: 0, Name: "default-release", Dir: release, InstallDefault: true, InstallLocked: true, Locked: locked},
+		{ID: 1, Name: "default", Dir: filepath.Join(dir, "Profiles", "abc123.default"), Default: true},
+	}
+	if !reflect.DeepEqual(profiles, want) {
+		t.Errorf("got:  %+v\nwant: %+v", profiles, want)
+	}
+
+	if _, err := ListProfiles(filepath.Join(dir, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v for missing profiles.ini", err)
+	}
+}
+
+func TestParseProfileMetadata(t *testing.T) {
+	dir := t.TempDir()
+	timesJSON := `{"created":1612345678901,"firstUse":1612345680000,"reset":1612400000000}`
+	compatibilityINI := `[Compatibility]
+LastVersion=85.0_20210118153634/20210118153634
+LastOSABI=Linux_x86_64-gcc3
+LastPlatformDir=/usr/lib/firefox
+LastAppDir=/usr/lib/firefox/browser
+InvalidateCaches=1
+`
+	for name, data := range map[string]string{"times.json": timesJSON, "compatibility.ini": compatibilityINI} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	meta, err := ParseProfileMetadata(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ProfileMetadata{
+		Created:         time.Unix(1612345678, 901e6),
+		FirstUse:        time.Unix(1612345680, 0),
+		Reset:           time.Unix(1612400000, 0),
+		Version:         "85.0",
+		AppBuildID:      "20210118153634",
+		PlatformBuildID: "20210118153634",
+		OSABI:           "Linux_x86_64-gcc3",
+		PlatformDir:     "/usr/lib/firefox",
+		AppDir:          "/usr/lib/firefox/browser",
+	}
+	if !meta.Created.Equal(want.Created) || !meta.FirstUse.Equal(want.FirstUse) || !meta.Reset.Equal(want.Reset) {
+		t.Errorf("got times %v, %v, %v, want %v, %v, %v", meta.Created, meta.FirstUse, meta.Reset, want.Created, want.FirstUse, want.Reset)
+	}
+	meta.Created, meta.FirstUse, meta.Reset = want.Created, want.FirstUse, want.Reset
+	if !reflect.DeepEqual(meta, want) {
+		t.Errorf("got:  %+v\nwant: %+v", meta, want)
+	}
+	if age := meta.Age(want.Created.Add(time.Hour)); age != time.Hour {
+		t.Errorf("got age %v, want 1h", age)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "times.json")); err != nil {
+		t.Fatal(err)
+	}
+	if meta, err := ParseProfileMetadata(dir); err != nil || !meta.Created.IsZero() || meta.Version != "85.0" {
+		t.Errorf("without times.json: got %+v, %v", meta, err)
+	}
+	if err := os.Remove(filepath.Join(dir, "compatibility.ini")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseProfileMetadata(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v for empty profile", err)
+	}
+}
+
+func TestCompatibilityVersion(t *testing.T) {
+	tests := []struct {
+		LastVersion, Version, AppBuildID, PlatformBuildID string
+	}{
+		{"85.0_20210118153634/20210118153634", "85.0", "20210118153634", "20210118153634"},
+		{"78.7.0esr_20210118151801/20210118151801", "78.7.0esr", "20210118151801", "20210118151801"},
+		{"Safe Mode", "Safe Mode", "", ""},
+	}
+	for i, tt := range tests {
+		c := &Compatibility{LastVersion: tt.LastVersion}
+		version, app, platform := c.Version()
+		if version != tt.Version || app != tt.AppBuildID || platform != tt.PlatformBuildID {
+			t.Errorf("#%d: got: %q, %q, %q\nwant: %q, %q, %q", i, version, app, platform, tt.Version, tt.AppBuildID, tt.PlatformBuildID)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Protections database format:
+// https://searchfox.org/mozilla-central/source/toolkit/components/antitracking/TrackingDBService.jsm
+//
+// Enhanced Tracking Protection counts the content that it blocks per
+// day, for the protections dashboard at about:protections. Only the
+// counts are persisted: the content blocking log of which sites loaded
+// which blocked resources is kept per tab in memory and is not written
+// to the profile.
+
+// ProtectionEvent is a row in the events table of protections.sqlite,
+// the number of resources of a type blocked on a day.
+type ProtectionEvent struct {
+	Type  ProtectionType
+	Count int
+	Date  time.Time // UTC day
+}
+
+// ProtectionType is a category of blocked content.
+type ProtectionType uint8
+
+// Values for ProtectionType:
+const (
+	ProtectionTrackers        ProtectionType = 1 // tracking content
+	ProtectionTrackingCookies ProtectionType = 2 // cross-site tracking cookies
+	ProtectionCryptominers    ProtectionType = 3
+	ProtectionFingerprinters  ProtectionType = 4
+	ProtectionSocialTrackers  ProtectionType = 5 // social media trackers
+)
+
+// ProtectionsDay is the number of resources blocked on a day, by type.
+type ProtectionsDay struct {
+	Date            time.Time
+	Trackers        int
+	TrackingCookies int
+	Cryptominers    int
+	Fingerprinters  int
+	SocialTrackers  int
+}
+
+// ParseProtections parses the events in protections.sqlite in a
+// Firefox profile, ordered by date, then by type.
+func ParseProtections(filename string) ([]ProtectionEvent, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var events []ProtectionEvent
+	// The timestamp column is declared as DATE, so it is cast to keep
+	// the driver from converting it.
+	err = sqliteutil.Query(db, `
+		SELECT type, count, CAST(timestamp AS TEXT) FROM events
+		ORDER BY timestamp, type, id`, func(rows *sql.Rows) error {
+		var e ProtectionEvent
+		var date string
+		if err := rows.Scan(&e.Type, &e.Count, &date); err != nil {
+			return err
+		}
+		t, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return err
+		}
+		e.Date = t
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firefox: protections: %w", err)
+	}
+	return events, nil
+}
+
+// SummarizeProtections totals events by day, ordered by date, as shown
+// in the protections dashboard. Events of unknown types are skipped.
+func SummarizeProtections(events []ProtectionEvent) []ProtectionsDay {
+	var days []ProtectionsDay
+	index := make(map[time.Time]int)
+	for _, e := range events {
+		i, ok := index[e.Date]
+		if !ok {
+			i = len(days)
+			index[e.Date] = i
+			days = append(days, ProtectionsDay{Date: e.Date})
+		}
+		d := &days[i]
+		switch e.Type {
+		case ProtectionTrackers:
+			d.Trackers += e.Count
+		case ProtectionTrackingCookies:
+			d.TrackingCookies += e.Count
+		case ProtectionCryptominers:
+			d.Cryptominers += e.Count
+		case ProtectionFingerprinters:
+			d.Fingerprinters += e.Count
+		case ProtectionSocialTrackers:
+			d.SocialTrackers += e.Count
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date.Before(days[j].Date) })
+	return days
+}
+
+// Total returns the number of resources blocked on the day.
+func (d *ProtectionsDay) Total() int {
+	return d.Trackers + d.TrackingCookies + d.Cryptominers + d.Fingerprinters + d.SocialTrackers
+}
+
+func (typ ProtectionType) String() string {
+	switch typ {
+	case ProtectionTrackers:
+		return "trackers"
+	case ProtectionTrackingCookies:
+		return "tracking_cookies"
+	case ProtectionCryptominers:
+		return "cryptominers"
+	case ProtectionFingerprinters:
+		return "fingerprinters"
+	case ProtectionSocialTrackers:
+		return "social_trackers"
+	default:
+		return fmt.Sprintf("protection(%d)", uint8(typ))
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseProtections(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "protections.sqlite")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE events (id INTEGER PRIMARY KEY, type INTEGER NOT NULL, count INTEGER NOT NULL, timestamp DATE);
+		INSERT INTO events VALUES
+			(1, 1, 12, '2021-02-18'),
+			(2, 2, 3, '2021-02-18'),
+			(3, 4, 1, '2021-02-17'),
+			(4, 3, 2, '2021-02-18'),
+			(5, 5, 7, '2021-02-17'),
+			(6, 9, 1, '2021-02-17');
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := ParseProtections(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feb17 := time.Date(2021, 2, 17, 0, 0, 0, 0, time.UTC)
+	feb18 := time.Date(2021, 2, 18, 0, 0, 0, 0, time.UTC)
+	want := []ProtectionEvent{
+		{ProtectionFingerprinters, 1, feb17},
+		{ProtectionSocialTrackers, 7, feb17},
+		{9, 1, feb17},
+		{ProtectionTrackers, 12, feb18},
+		{ProtectionTrackingCookies, 3, feb18},
+		{ProtectionCryptominers, 2, feb18},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", events, want)
+	}
+
+	days := SummarizeProtections(events)
+	wantDays := []ProtectionsDay{
+		{Date: feb17, Fingerprinters: 1, SocialTrackers: 7},
+		{Date: feb18, Trackers: 12, TrackingCookies: 3, Cryptominers: 2},
+	}
+	if !reflect.DeepEqual(days, wantDays) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", days, wantDays)
+	}
+	if total := days[1].Total(); total != 17 {
+		t.Errorf("got total %d, want 17", total)
+	}
+}
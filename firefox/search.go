@@ -0,0 +1,163 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/andrewarchi/browser/compress/mozlz4"
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+// SearchEngines contains the search engines in search.json.mozlz4,
+// which is written by the search service. Its format changes often
+// between versions, so only the fields needed to identify an engine
+// and its URLs are decoded.
+// https://searchfox.org/mozilla-central/source/toolkit/components/search/SearchSettings.jsm
+type SearchEngines struct {
+	Version  int               `json:"version"` // e.g. 6
+	Engines  []SearchEngine    `json:"engines"`
+	MetaData SearchEnginesMeta `json:"metaData"`
+}
+
+// SearchEnginesMeta is the search service state, including the
+// default engine.
+type SearchEnginesMeta struct {
+	UseSavedOrder          bool   `json:"useSavedOrder,omitempty"`
+	Locale                 string `json:"locale,omitempty"` // e.g. "en-US"
+	Region                 string `json:"region,omitempty"` // e.g. "US"
+	Channel                string `json:"channel,omitempty"`
+	AppDefaultEngineID     string `json:"appDefaultEngineId,omitempty"`
+	DefaultEngineID        string `json:"defaultEngineId,omitempty"`
+	PrivateDefaultEngineID string `json:"privateDefaultEngineId,omitempty"`
+	Current                string `json:"current,omitempty"` // name of the default engine, before version 6
+}
+
+// SearchEngine is an installed search engine. App-provided engines
+// are bundled with Firefox and others are added by the user, by
+// OpenSearch discovery, or by extensions.
+type SearchEngine struct {
+	ID             string            `json:"id,omitempty"`
+	Name           string            `json:"_name"`
+	Description    string            `json:"description,omitempty"`
+	LoadPath       string            `json:"_loadPath,omitempty"` // e.g. "[user]" or "[addon]{id}"
+	IconURL        string            `json:"_iconURL,omitempty"`
+	IsAppProvided  bool              `json:"_isAppProvided,omitempty"`
+	ExtensionID    string            `json:"_extensionID,omitempty"`
+	QueryCharset   string            `json:"queryCharset,omitempty"` // e.g. "UTF-8"
+	DefinedAliases []string          `json:"_definedAliases,omitempty"`
+	MetaData       SearchEngineMeta  `json:"_metaData"`
+	URLs           []SearchEngineURL `json:"_urls"`
+}
+
+// SearchEngineMeta holds the user customizations of an engine.
+type SearchEngineMeta struct {
+	Alias  string `json:"alias,omitempty"` // keyword set by the user
+	Order  int    `json:"order,omitempty"`
+	Hidden bool   `json:"hidden,omitempty"`
+}
+
+// SearchEngineURL is a URL template of an engine. Templates use the
+// OpenSearch syntax, with "{searchTerms}" substituted by the query.
+type SearchEngineURL struct {
+	Template string                 `json:"template"`
+	Type     string                 `json:"type,omitempty"`   // e.g. SearchTypeHTML
+	Method   string                 `json:"method,omitempty"` // "GET" or "POST"
+	Rels     []string               `json:"rels,omitempty"`
+	Params   []SearchEngineURLParam `json:"params,omitempty"`
+}
+
+// SearchEngineURLParam is a query parameter of a URL template.
+type SearchEngineURLParam struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	Purpose string `json:"purpose,omitempty"` // e.g. "searchbar" or "keyword"
+}
+
+// Values for SearchEngineURL.Type:
+const (
+	SearchTypeHTML        = "text/html"
+	SearchTypeSuggestJSON = "application/x-suggestions+json"
+)
+
+// SearchEnginesFile is the search engine settings file in a profile.
+const SearchEnginesFile = "search.json.mozlz4"
+
+// ParseSearchEngines parses search.json.mozlz4 in a Firefox profile.
+// Uncompressed files, such as from decompressing it with a tool, are
+// also supported.
+func ParseSearchEngines(filename string) (*SearchEngines, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if mozlz4.IsMozLz4(b) {
+		if b, err = mozlz4.Decode(b); err != nil {
+			return nil, err
+		}
+	}
+	var engines SearchEngines
+	if err := jsonutil.DecodeAllowUnknownFields(bytes.NewReader(b), &engines); err != nil {
+		return nil, fmt.Errorf("firefox: search engines: %w", err)
+	}
+	return &engines, nil
+}
+
+// Alias returns the keyword of the engine: the alias set by the user,
+// or else the first alias defined by the engine, such as "@google".
+func (e *SearchEngine) Alias() string {
+	if e.MetaData.Alias != "" {
+		return e.MetaData.Alias
+	}
+	if len(e.DefinedAliases) != 0 {
+		return e.DefinedAliases[0]
+	}
+	return ""
+}
+
+// SearchURL returns the GET URL template of the given type, with its
+// parameters appended to the query. Parameters with a purpose, which
+// vary by the entry point of the search, are omitted. It returns ""
+// when the engine has no such URL.
+func (e *SearchEngine) SearchURL(typ string) string {
+	for _, u := range e.URLs {
+		t := u.Type
+		if t == "" {
+			t = SearchTypeHTML
+		}
+		if t != typ || (u.Method != "" && !strings.EqualFold(u.Method, "GET")) {
+			continue
+		}
+		template := u.Template
+		sep := "?"
+		if strings.Contains(template, "?") {
+			sep = "&"
+		}
+		for _, p := range u.Params {
+			if p.Purpose != "" {
+				continue
+			}
+			template += sep + url.QueryEscape(p.Name) + "=" + escapeTemplateValue(p.Value)
+			sep = "&"
+		}
+		return template
+	}
+	return ""
+}
+
+// escapeTemplateValue escapes a parameter value, preserving the braces
+// of OpenSearch parameters like "{searchTerms}".
+func escapeTemplateValue(value string) string {
+	if strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}") {
+		return value
+	}
+	return url.QueryEscape(value)
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrewarchi/browser/compress/mozlz4"
+)
+
+func TestParseSearchEngines(t *testing.T) {
+	const data = `{
+		"version": 6,
+		"engines": [
+			{"id": "google@search.mozilla.orgdefault", "_name": "Google", "_isAppProvided": true,
+				"_metaData": {"order": 1}, "_definedAliases": ["@google"],
+				"_urls": [{"template": "https://www.google.com/search", "rels": [], "resultDomain": "google.com",
+					"params": [{"name": "client", "value": "firefox-b-d", "purpose": "searchbar"},
+						{"name": "q", "value": "{searchTerms}"}]}]},
+			{"id": "6f2b7a7e-1f3b-4c1e-9f57-4b8a0c2e5d11", "_name": "Wiki", "_loadPath": "[user]",
+				"_metaData": {"alias": "w", "order": 2},
+				"_urls": [{"template": "https://en.wikipedia.org/w/index.php?search={searchTerms}", "method": "GET"},
+					{"template": "https://en.wikipedia.org/w/api.php?action=opensearch&search={searchTerms}",
+						"type": "application/x-suggestions+json"}]},
+			{"_name": "Post", "_urls": [{"template": "https://example.com/search", "method": "POST",
+				"params": [{"name": "q", "value": "{searchTerms}"}]}]}
+		],
+		"metaData": {"useSavedOrder": true, "locale": "en-US", "region": "US",
+			"defaultEngineId": "google@search.mozilla.orgdefault", "defaultEngineIdHash": "abc="}
+	}`
+	b, err := mozlz4.Encode([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), SearchEnginesFile)
+	if err := os.WriteFile(filename, b, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	s, err := ParseSearchEngines(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Engines) != 3 || s.MetaData.DefaultEngineID != "google@search.mozilla.orgdefault" {
+		t.Fatalf("got %+v", s)
+	}
+	google, wiki, post := &s.Engines[0], &s.Engines[1], &s.Engines[2]
+	if got, want := google.SearchURL(SearchTypeHTML), "https://www.google.com/search?q={searchTerms}"; got != want {
+		t.Errorf("got Google URL %q, want %q", got, want)
+	}
+	if got := google.Alias(); got != "@google" {
+		t.Errorf("got Google alias %q, want %q", got, "@google")
+	}
+	if got := wiki.Alias(); got != "w" {
+		t.Errorf("got Wiki alias %q, want %q", got, "w")
+	}
+	if got, want := wiki.SearchURL(SearchTypeSuggestJSON), "https://en.wikipedia.org/w/api.php?action=opensearch&search={searchTerms}"; got != want {
+		t.Errorf("got Wiki suggest URL %q, want %q", got, want)
+	}
+	if got := post.SearchURL(SearchTypeHTML); got != "" {
+		t.Errorf("got POST URL %q, want none", got)
+	}
+}
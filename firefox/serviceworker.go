@@ -0,0 +1,176 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+)
+
+// ServiceWorker is a service worker registration in serviceworker.txt.
+// https://searchfox.org/mozilla-central/source/dom/serviceworkers/ServiceWorkerRegistrar.cpp
+type ServiceWorker struct {
+	OriginAttributes        *OriginAttributes
+	Scope                   string // e.g. "https://example.com/"
+	ScriptURL               string // e.g. "https://example.com/sw.js"
+	HandlesFetch            bool
+	CacheName               string // UUID of the script cache
+	UpdateViaCache          ServiceWorkerUpdateViaCache
+	Installed               time.Time // zero when unknown
+	Activated               time.Time // zero when unknown
+	LastUpdate              time.Time // last update check; zero when unknown
+	NavigationPreload       bool      // since version 9
+	NavigationPreloadHeader string    // since version 9
+}
+
+// ServiceWorkerUpdateViaCache is whether the HTTP cache is used when
+// checking a service worker script and its imports for updates.
+type ServiceWorkerUpdateViaCache uint8
+
+// Values for ServiceWorkerUpdateViaCache:
+const (
+	UpdateViaCacheImports ServiceWorkerUpdateViaCache = 0
+	UpdateViaCacheAll     ServiceWorkerUpdateViaCache = 1
+	UpdateViaCacheNone    ServiceWorkerUpdateViaCache = 2
+)
+
+// ParseServiceWorkers parses serviceworker.txt in a Firefox profile.
+// The first line is the version and each registration is a line per
+// field followed by a line of "#". Versions 8 and later are supported
+// and fields added after version 9 are skipped.
+func ParseServiceWorkers(filename string) ([]ServiceWorker, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	if !s.Scan() {
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	version, err := strconv.Atoi(s.Text())
+	if err != nil {
+		return nil, fmt.Errorf("firefox: service workers: version: %w", err)
+	}
+	if version < 8 {
+		return nil, fmt.Errorf("firefox: service workers: unsupported version %d", version)
+	}
+	var workers []ServiceWorker
+	var fields []string
+	for line := 2; s.Scan(); line++ {
+		if s.Text() != "#" {
+			fields = append(fields, s.Text())
+			continue
+		}
+		w, err := parseServiceWorker(fields, version)
+		if err != nil {
+			return nil, fmt.Errorf("firefox: service workers: line %d: %w", line, err)
+		}
+		workers = append(workers, *w)
+		fields = fields[:0]
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(fields) != 0 {
+		return nil, fmt.Errorf("firefox: service workers: unterminated registration")
+	}
+	return workers, nil
+}
+
+// parseServiceWorker parses the fields of a registration: the origin
+// attributes suffix, scope, script URL, handles fetch, cache name,
+// update via cache in hex, installed, activated, and last update times
+// in microseconds, then, since version 9, navigation preload enabled
+// and header.
+func parseServiceWorker(fields []string, version int) (*ServiceWorker, error) {
+	n := 9
+	if version >= 9 {
+		n = 11
+	}
+	if len(fields) < n || version < 10 && len(fields) != n {
+		return nil, fmt.Errorf("%d fields", len(fields))
+	}
+	attrs, err := ParseOriginAttributes(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	w := &ServiceWorker{
+		OriginAttributes: attrs,
+		Scope:            fields[1],
+		ScriptURL:        fields[2],
+		CacheName:        fields[4],
+	}
+	if w.HandlesFetch, err = parseServiceWorkerBool(fields[3]); err != nil {
+		return nil, fmt.Errorf("handles fetch: %w", err)
+	}
+	updateViaCache, err := strconv.ParseUint(fields[5], 16, 8)
+	if err != nil {
+		return nil, fmt.Errorf("update via cache: %w", err)
+	}
+	w.UpdateViaCache = ServiceWorkerUpdateViaCache(updateViaCache)
+	for i, t := range []*time.Time{&w.Installed, &w.Activated, &w.LastUpdate} {
+		usec, err := strconv.ParseInt(fields[6+i], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("time: %w", err)
+		}
+		if usec > 0 {
+			*t = timefmt.FromInt(usec, 0, timefmt.Micro, timefmt.Unix)
+		}
+	}
+	if version >= 9 {
+		if w.NavigationPreload, err = parseServiceWorkerBool(fields[9]); err != nil {
+			return nil, fmt.Errorf("navigation preload: %w", err)
+		}
+		w.NavigationPreloadHeader = fields[10]
+	}
+	return w, nil
+}
+
+func parseServiceWorkerBool(s string) (bool, error) {
+	switch s {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("not a bool: %q", s)
+	}
+}
+
+// Origin returns the origin of the scope of the registration, e.g.
+// "https://example.com".
+func (w *ServiceWorker) Origin() string {
+	u, err := url.Parse(w.Scope)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func (cache ServiceWorkerUpdateViaCache) String() string {
+	switch cache {
+	case UpdateViaCacheImports:
+		return "imports"
+	case UpdateViaCacheAll:
+		return "all"
+	case UpdateViaCacheNone:
+		return "none"
+	default:
+		return fmt.Sprintf("update_via_cache(%d)", uint8(cache))
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseServiceWorkers(t *testing.T) {
+	data := "9\n" +
+		"\nhttps://example.com/\nhttps://example.com/sw.js\ntrue\n" +
+		"{0c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f}\n0\n1613610123000000\n1613610124000000\n1613696523000000\n" +
+		"false\n\n#\n" +
+		"^userContextId=2\nhttps://app.example.org/app/\nhttps://app.example.org/app/worker.js\nfalse\n" +
+		"{1c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f}\n2\n0\n0\n1613696523000000\n" +
+		"true\nnav\n#\n"
+	filename := filepath.Join(t.TempDir(), "serviceworker.txt")
+	if err := os.WriteFile(filename, []byte(data), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	workers, err := ParseServiceWorkers(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usec := func(us int64) time.Time { return time.Unix(0, us*1e3).UTC() }
+	want := []ServiceWorker{{
+		OriginAttributes: &OriginAttributes{},
+		Scope:            "https://example.com/",
+		ScriptURL:        "https://example.com/sw.js",
+		HandlesFetch:     true,
+		CacheName:        "{0c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f}",
+		UpdateViaCache:   UpdateViaCacheImports,
+		Installed:        usec(1613610123000000),
+		Activated:        usec(1613610124000000),
+		LastUpdate:       usec(1613696523000000),
+	}, {
+		OriginAttributes:        &OriginAttributes{UserContextID: 2},
+		Scope:                   "https://app.example.org/app/",
+		ScriptURL:               "https://app.example.org/app/worker.js",
+		CacheName:               "{1c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f}",
+		UpdateViaCache:          UpdateViaCacheNone,
+		LastUpdate:              usec(1613696523000000),
+		NavigationPreload:       true,
+		NavigationPreloadHeader: "nav",
+	}}
+	if !reflect.DeepEqual(workers, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", workers, want)
+	}
+	if origin := workers[1].Origin(); origin != "https://app.example.org" {
+		t.Errorf("got origin %q", origin)
+	}
+
+	if err := os.WriteFile(filename, []byte("9\n\nhttps://example.com/\n#\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseServiceWorkers(filename); err == nil {
+		t.Error("expected error for truncated registration")
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/andrewarchi/browser/compress/mozlz4"
+	"github.com/andrewarchi/browser/jsonutil"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+)
+
+// Session store format:
+// https://searchfox.org/mozilla-central/source/browser/components/sessionstore/SessionStore.jsm
+// https://searchfox.org/mozilla-central/source/browser/components/sessionstore/SessionFile.jsm
+//
+// The session is written to sessionstore-backups/recovery.jsonlz4
+// while running, with the previous write kept as recovery.baklz4. On
+// shutdown, it is written to sessionstore.jsonlz4, which is moved to
+// previous.jsonlz4 on the next startup. A copy is kept as
+// upgrade.jsonlz4-{build ID} after each update. Before Firefox 56,
+// files were uncompressed with the extensions .js and .bak.
+//
+// The session contains many fields that vary by version and are only
+// meaningful to Firefox, such as serialized principals and docshell
+// state, so only the fields describing windows, tabs, history, and
+// form data are decoded.
+
+// Session is a browser session in sessionstore.jsonlz4.
+type Session struct {
+	Version        []interface{}   `json:"version"` // e.g. ["sessionrestore", 1]
+	Windows        []SessionWindow `json:"windows"`
+	ClosedWindows  []SessionWindow `json:"_closedWindows"`
+	SelectedWindow int             `json:"selectedWindow"` // index from 1
+	Session        SessionInfo     `json:"session"`
+	Cookies        []SessionCookie `json:"cookies,omitempty"`
+}
+
+// SessionInfo contains session timing.
+type SessionInfo struct {
+	LastUpdate    timefmt.UnixMilli `json:"lastUpdate"`
+	StartTime     timefmt.UnixMilli `json:"startTime"`
+	RecentCrashes int               `json:"recentCrashes"`
+}
+
+// SessionWindow is a browser window.
+type SessionWindow struct {
+	Tabs       []SessionTab      `json:"tabs"`
+	Selected   int               `json:"selected"` // index from 1
+	ClosedTabs []ClosedTab       `json:"_closedTabs"`
+	Width      int               `json:"width"`
+	Height     int               `json:"height"`
+	ScreenX    int               `json:"screenX"`
+	ScreenY    int               `json:"screenY"`
+	SizeMode   string            `json:"sizemode"` // e.g. "normal", "maximized", "minimized"
+	ZIndex     int               `json:"zIndex,omitempty"`
+	Title      string            `json:"title,omitempty"`    // closed windows only
+	ClosedAt   timefmt.UnixMilli `json:"closedAt,omitempty"` // closed windows only
+	IsPrivate  bool              `json:"isPrivate,omitempty"`
+	IsPopup    bool              `json:"isPopup,omitempty"`
+	Groups     []SessionTabGroup `json:"groups,omitempty"` // since Firefox 131
+	ExtData    map[string]string `json:"extData,omitempty"`
+}
+
+// SessionTabGroup is a group of tabs in a window.
+type SessionTabGroup struct {
+	ID        string `json:"id"` // e.g. "1727000000000-42"
+	Name      string `json:"name"`
+	Color     string `json:"color"` // e.g. "blue"
+	Collapsed bool   `json:"collapsed"`
+}
+
+// SessionTab is a tab and its navigation history.
+type SessionTab struct {
+	Entries        []SessionEntry    `json:"entries"`
+	Index          int               `json:"index"` // current entry, indexed from 1
+	LastAccessed   timefmt.UnixMilli `json:"lastAccessed"`
+	Pinned         bool              `json:"pinned,omitempty"`
+	Hidden         bool              `json:"hidden"`
+	Muted          bool              `json:"muted,omitempty"`
+	UserContextID  int               `json:"userContextId"`     // container
+	GroupID        string            `json:"groupId,omitempty"` // tab group in SessionWindow.Groups
+	Image          string            `json:"image,omitempty"`
+	UserTypedValue string            `json:"userTypedValue,omitempty"` // unsubmitted address bar text
+	FormData       *SessionFormData  `json:"formdata,omitempty"`
+	Scroll         *SessionScroll    `json:"scroll,omitempty"`
+	ExtData        map[string]string `json:"extData,omitempty"`
+}
+
+// SessionEntry is an entry in the back-forward history of a tab.
+type SessionEntry struct {
+	URL                string         `json:"url"`
+	Title              string         `json:"title,omitempty"`
+	ID                 int            `json:"ID"`
+	DocshellUUID       string         `json:"docshellUUID,omitempty"`
+	OriginalURI        string         `json:"originalURI,omitempty"`
+	ResultPrincipalURI string         `json:"resultPrincipalURI,omitempty"`
+	ContentType        string         `json:"contentType,omitempty"`
+	HasUserInteraction bool           `json:"hasUserInteraction"`
+	Persist            bool           `json:"persist"`
+	CacheKey           int            `json:"cacheKey,omitempty"`
+	Children           []SessionEntry `json:"children,omitempty"` // frames
+}
+
+// SessionFormData is text entered into forms in a page, keyed by
+// element ID or XPath.
+type SessionFormData struct {
+	URL       string                 `json:"url"`
+	ID        map[string]interface{} `json:"id,omitempty"`
+	XPath     map[string]interface{} `json:"xpath,omitempty"`
+	InnerHTML string                 `json:"innerHTML,omitempty"` // contenteditable documents
+	Children  []*SessionFormData     `json:"children,omitempty"`  // frames
+}
+
+// SessionScroll is the scroll position of a page and its frames.
+type SessionScroll struct {
+	Scroll   string           `json:"scroll,omitempty"` // e.g. "0,1200"
+	Children []*SessionScroll `json:"children,omitempty"`
+}
+
+// ClosedTab is a recently closed tab that can be reopened.
+type ClosedTab struct {
+	State    SessionTab        `json:"state"`
+	Title    string            `json:"title"`
+	Image    string            `json:"image,omitempty"`
+	Pos      int               `json:"pos"`
+	ClosedAt timefmt.UnixMilli `json:"closedAt"`
+	ClosedID int               `json:"closedId"`
+}
+
+// SessionCookie is a session cookie, which has no expiry and would
+// otherwise be lost on restart.
+type SessionCookie struct {
+	Host             string                 `json:"host"`
+	Name             string                 `json:"name"`
+	Value            string                 `json:"value"`
+	Path             string                 `json:"path"`
+	Secure           bool                   `json:"secure,omitempty"`
+	HTTPOnly         bool                   `json:"httponly,omitempty"`
+	SameSite         int                    `json:"sameSite,omitempty"`
+	OriginAttributes map[string]interface{} `json:"originAttributes,omitempty"`
+}
+
+// Current returns the current entry in the history of a tab, or nil
+// when the tab has no entries.
+func (t *SessionTab) Current() *SessionEntry {
+	i := t.Index - 1
+	if i < 0 || i >= len(t.Entries) {
+		if len(t.Entries) == 0 {
+			return nil
+		}
+		i = len(t.Entries) - 1
+	}
+	return &t.Entries[i]
+}
+
+// ParseSession parses a session file, such as sessionstore.jsonlz4 or
+// a file in sessionstore-backups, in a Firefox profile. Both
+// mozLz4-compressed and uncompressed files are supported.
+func ParseSession(filename string) (*Session, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if mozlz4.IsMozLz4(b) {
+		if b, err = mozlz4.Decode(b); err != nil {
+			return nil, err
+		}
+	}
+	var session Session
+	if err := jsonutil.DecodeAllowUnknownFields(bytes.NewReader(b), &session); err != nil {
+		return nil, fmt.Errorf("firefox: session %s: %w", filepath.Base(filename), err)
+	}
+	return &session, nil
+}
+
+// WriteSession writes a session in sessionstore.jsonlz4 format. Only
+// the decoded fields are written, so it is suited to building sessions
+// to restore, rather than to rewriting sessions in place.
+func WriteSession(w io.Writer, s *Session) error {
+	var buf bytes.Buffer
+	if err := writeJSONFile(&buf, s); err != nil {
+		return fmt.Errorf("firefox: session: %w", err)
+	}
+	b, err := mozlz4.Encode(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("firefox: session: %w", err)
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// SessionFile is a session file in a Firefox profile.
+type SessionFile struct {
+	Path string
+	Kind string // "sessionstore", "recovery", "recovery-backup", "previous", or "upgrade"
+}
+
+// SessionFiles lists the session files in a Firefox profile, from most
+// to least recent in purpose: the clean shutdown session, the running
+// session and its backup, the previous session, then upgrade backups
+// by name.
+func SessionFiles(profileDir string) ([]SessionFile, error) {
+	var files []SessionFile
+	add := func(kind string, names ...string) {
+		for _, name := range names {
+			path := filepath.Join(profileDir, name)
+			if _, err := os.Stat(path); err == nil {
+				files = append(files, SessionFile{path, kind})
+			}
+		}
+	}
+	const backups = "sessionstore-backups"
+	add("sessionstore", "sessionstore.jsonlz4", "sessionstore.js")
+	add("recovery", filepath.Join(backups, "recovery.jsonlz4"), filepath.Join(backups, "recovery.js"))
+	add("recovery-backup", filepath.Join(backups, "recovery.baklz4"), filepath.Join(backups, "recovery.bak"))
+	add("previous", filepath.Join(backups, "previous.jsonlz4"), filepath.Join(backups, "previous.js"))
+
+	entries, err := os.ReadDir(filepath.Join(profileDir, backups))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	var upgrades []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "upgrade.js") {
+			upgrades = append(upgrades, filepath.Join(backups, e.Name()))
+		}
+	}
+	sort.Strings(upgrades)
+	add("upgrade", upgrades...)
+	return files, nil
+}
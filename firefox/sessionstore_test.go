@@ -0,0 +1,87 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrewarchi/browser/compress/mozlz4"
+)
+
+const testSession = `{
+  "version": ["sessionrestore", 1],
+  "windows": [{
+    "tabs": [{
+      "entries": [
+        {"url": "https://a.example/", "title": "A", "ID": 1, "persist": true, "hasUserInteraction": true},
+        {"url": "https://b.example/", "title": "B", "ID": 2, "persist": true, "hasUserInteraction": false,
+         "triggeringPrincipal_base64": "e30="}
+      ],
+      "index": 1, "lastAccessed": 1613610123456, "hidden": false, "userContextId": 1,
+      "formdata": {"url": "https://a.example/", "id": {"q": "search"}},
+      "attributes": {}
+    }],
+    "selected": 1, "_closedTabs": [], "width": 800, "height": 600, "screenX": 0, "screenY": 0, "sizemode": "normal"
+  }],
+  "_closedWindows": [],
+  "selectedWindow": 1,
+  "session": {"lastUpdate": 1613610123456, "startTime": 1613610000000, "recentCrashes": 0},
+  "global": {}
+}`
+
+func TestParseSession(t *testing.T) {
+	data := []byte(testSession)
+	b, err := mozlz4.Encode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"sessionstore.jsonlz4", "sessionstore-backups/recovery.js"} {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		contents := b
+		if filepath.Ext(name) == ".js" {
+			contents = data
+		}
+		if err := ioutil.WriteFile(filename, contents, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := SessionFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Kind != "sessionstore" || files[1].Kind != "recovery" {
+		t.Fatalf("got files %v", files)
+	}
+	for _, f := range files {
+		s, err := ParseSession(f.Path)
+		if err != nil {
+			t.Fatalf("%s: %v", f.Kind, err)
+		}
+		if len(s.Windows) != 1 || len(s.Windows[0].Tabs) != 1 {
+			t.Fatalf("%s: got %d windows", f.Kind, len(s.Windows))
+		}
+		tab := &s.Windows[0].Tabs[0]
+		if cur := tab.Current(); cur == nil || cur.URL != "https://a.example/" {
+			t.Errorf("%s: got current entry %v", f.Kind, cur)
+		}
+		if tab.FormData == nil || tab.FormData.ID["q"] != "search" {
+			t.Errorf("%s: got form data %v", f.Kind, tab.FormData)
+		}
+		if tab.LastAccessed.UnixNano() != 1613610123456000000 {
+			t.Errorf("%s: got last accessed %s", f.Kind, tab.LastAccessed)
+		}
+	}
+}
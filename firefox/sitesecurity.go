@@ -0,0 +1,328 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser"
+)
+
+// SiteSecurity is the HSTS or HPKP state of a host, as noted from the
+// Strict-Transport-Security and Public-Key-Pins headers it sent.
+// https://searchfox.org/mozilla-central/source/security/manager/ssl/nsSiteSecurityService.cpp
+type SiteSecurity struct {
+	Host              string
+	OriginAttributes  *OriginAttributes // user context is always default
+	Type              SiteSecurityType
+	Score             int       // number of days the entry was accessed
+	LastAccessed      time.Time // day of last access
+	Expires           time.Time
+	State             SiteSecurityState
+	IncludeSubdomains bool
+	Source            HSTSSource // HSTS only
+	Pins              []string   // HPKP only; base64 SHA-256 hashes of the pinned public keys
+	Store             string     // file read from, e.g. "SiteSecurityServiceState.txt"
+}
+
+// SiteSecurityType is the header that a site security entry records.
+type SiteSecurityType string
+
+// Values for SiteSecurityType:
+const (
+	SiteSecurityHSTS SiteSecurityType = "HSTS"
+	SiteSecurityHPKP SiteSecurityType = "HPKP" // removed in Firefox 72
+)
+
+// SiteSecurityState is the state of a site security entry.
+type SiteSecurityState uint8
+
+// Values for SiteSecurityState:
+const (
+	SiteSecurityUnset    SiteSecurityState = 0
+	SiteSecuritySet      SiteSecurityState = 1
+	SiteSecurityKnockout SiteSecurityState = 2 // overrides a preloaded entry
+	SiteSecurityNegative SiteSecurityState = 3 // preloaded as not HSTS
+)
+
+// HSTSSource is how an HSTS entry was learned.
+type HSTSSource uint8
+
+// Values for HSTSSource:
+const (
+	HSTSSourceUnknown HSTSSource = 0
+	HSTSSourcePreload HSTSSource = 1
+	HSTSSourceOrganic HSTSSource = 2 // from a response header
+	HSTSSourcePriming HSTSSource = 3 // from HSTS priming, removed in Firefox 59
+)
+
+// Site security state files in a profile:
+const (
+	SiteSecurityTextFile   = "SiteSecurityServiceState.txt"
+	SiteSecurityBinaryFile = "site_security_service_state.bin"
+)
+
+// ParseSiteSecurityState parses SiteSecurityServiceState.txt in a
+// Firefox profile, which was replaced by
+// site_security_service_state.bin in Firefox 115. Each line is
+// "{host}{origin attributes}:{type}\t{score}\t{days}\t{value}", where
+// days is the day of last access since the Unix epoch.
+// https://searchfox.org/mozilla-central/source/security/manager/ssl/DataStorage.cpp
+func ParseSiteSecurityState(filename string) ([]SiteSecurity, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []SiteSecurity
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		if s.Text() == "" {
+			continue
+		}
+		fields := strings.Split(s.Text(), "\t")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("firefox: site security: line %d has %d fields", line, len(fields))
+		}
+		score, err := strconv.ParseUint(fields[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("firefox: site security: line %d: score: %w", line, err)
+		}
+		days, err := strconv.ParseInt(fields[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("firefox: site security: line %d: last accessed: %w", line, err)
+		}
+		e, err := parseSiteSecurity(fields[0], fields[3], int(score), days)
+		if err != nil {
+			return nil, fmt.Errorf("firefox: site security: line %d: %w", line, err)
+		}
+		e.Store = SiteSecurityTextFile
+		entries = append(entries, *e)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Layout of site_security_service_state.bin, which is an array of
+// fixed-size slots. Each slot is a big-endian checksum, score, and day
+// of last access since the Unix epoch, each 16 bits, followed by the
+// key and value, padded with NUL. The checksum is the XOR of the other
+// fields as big-endian 16-bit words. Empty slots are zero.
+// https://searchfox.org/mozilla-central/source/security/manager/ssl/data_storage/src/lib.rs
+const (
+	siteSecurityKeyLen   = 256
+	siteSecurityValueLen = 24
+	siteSecuritySlotLen  = 6 + siteSecurityKeyLen + siteSecurityValueLen
+)
+
+// ParseSiteSecurityStateBin parses site_security_service_state.bin in
+// a Firefox profile.
+func ParseSiteSecurityStateBin(filename string) ([]SiteSecurity, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(b)%siteSecuritySlotLen != 0 {
+		return nil, fmt.Errorf("firefox: site security: size %d not a multiple of the slot size %d", len(b), siteSecuritySlotLen)
+	}
+	var entries []SiteSecurity
+	for off := 0; off < len(b); off += siteSecuritySlotLen {
+		slot := b[off : off+siteSecuritySlotLen]
+		key := slot[6 : 6+siteSecurityKeyLen]
+		if key[0] == 0 {
+			continue
+		}
+		var sum uint16
+		for i := 2; i < len(slot); i += 2 {
+			sum ^= binary.BigEndian.Uint16(slot[i:])
+		}
+		if checksum := binary.BigEndian.Uint16(slot); checksum != sum {
+			return nil, fmt.Errorf("firefox: site security: slot %d: checksum %#04x, want %#04x", off/siteSecuritySlotLen, checksum, sum)
+		}
+		score := binary.BigEndian.Uint16(slot[2:])
+		days := binary.BigEndian.Uint16(slot[4:])
+		value := slot[6+siteSecurityKeyLen:]
+		e, err := parseSiteSecurity(string(trimNUL(key)), string(trimNUL(value)), int(score), int64(days))
+		if err != nil {
+			return nil, fmt.Errorf("firefox: site security: slot %d: %w", off/siteSecuritySlotLen, err)
+		}
+		e.Store = SiteSecurityBinaryFile
+		entries = append(entries, *e)
+	}
+	return entries, nil
+}
+
+// ProfileSiteSecurity reads the site security state of a Firefox
+// profile from both site_security_service_state.bin and the
+// SiteSecurityServiceState.txt it was migrated from, which may remain.
+// Entries in the text file for keys also in the binary file are
+// dropped. Missing files are skipped and files that fail to parse are
+// handled as by ProfileSiteSecurityOptions with the zero Options.
+func ProfileSiteSecurity(profileDir string) ([]SiteSecurity, error) {
+	return ProfileSiteSecurityOptions(profileDir, browser.Options{})
+}
+
+// ProfileSiteSecurityOptions reads the site security state of a Firefox
+// profile and handles files that fail to parse by the error policy in
+// opts.
+func ProfileSiteSecurityOptions(profileDir string, opts browser.Options) ([]SiteSecurity, error) {
+	h := opts.Handler()
+	filename := filepath.Join(profileDir, SiteSecurityBinaryFile)
+	entries, err := ParseSiteSecurityStateBin(filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		if err := h.File(filename, err); err != nil {
+			return nil, err
+		}
+	}
+	filename = filepath.Join(profileDir, SiteSecurityTextFile)
+	legacy, err := ParseSiteSecurityState(filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		if err := h.File(filename, err); err != nil {
+			return nil, err
+		}
+	}
+	seen := make(map[string]bool, len(entries))
+	for i := range entries {
+		seen[entries[i].key()] = true
+	}
+	for _, e := range legacy {
+		if !seen[e.key()] {
+			entries = append(entries, e)
+		}
+	}
+	return entries, h.Err()
+}
+
+// parseSiteSecurity parses the key and value of an entry. HSTS values
+// are "{expires},{state},{include subdomains}[,{source}]" and HPKP
+// values are "{expires},{state},{include subdomains},{pins}", where
+// expires is in milliseconds since the Unix epoch and pins are
+// concatenated.
+func parseSiteSecurity(key, value string, score int, days int64) (*SiteSecurity, error) {
+	colon := strings.LastIndexByte(key, ':')
+	if colon == -1 {
+		return nil, fmt.Errorf("key without type: %q", key)
+	}
+	host, typ := key[:colon], SiteSecurityType(key[colon+1:])
+	if typ != SiteSecurityHSTS && typ != SiteSecurityHPKP {
+		return nil, fmt.Errorf("unknown type %q", typ)
+	}
+	var suffix string
+	if caret := strings.IndexByte(host, '^'); caret != -1 {
+		host, suffix = host[:caret], host[caret:]
+	}
+	attrs, err := ParseOriginAttributes(suffix)
+	if err != nil {
+		return nil, err
+	}
+	e := &SiteSecurity{
+		Host:             host,
+		OriginAttributes: attrs,
+		Type:             typ,
+		Score:            score,
+		LastAccessed:     time.Unix(days*24*60*60, 0).UTC(),
+	}
+
+	fields := strings.Split(value, ",")
+	if len(fields) < 3 || len(fields) > 4 || typ == SiteSecurityHPKP && len(fields) != 4 {
+		return nil, fmt.Errorf("%s value has %d fields: %q", typ, len(fields), value)
+	}
+	expires, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("expires: %w", err)
+	}
+	e.Expires = time.Unix(0, expires*int64(time.Millisecond)).UTC()
+	state, err := strconv.ParseUint(fields[1], 10, 8)
+	if err != nil || state > uint64(SiteSecurityNegative) {
+		return nil, fmt.Errorf("invalid state %q", fields[1])
+	}
+	e.State = SiteSecurityState(state)
+	switch fields[2] {
+	case "0":
+	case "1":
+		e.IncludeSubdomains = true
+	default:
+		return nil, fmt.Errorf("invalid include subdomains %q", fields[2])
+	}
+	if len(fields) == 4 {
+		if typ == SiteSecurityHSTS {
+			source, err := strconv.ParseUint(fields[3], 10, 8)
+			if err != nil || source > uint64(HSTSSourcePriming) {
+				return nil, fmt.Errorf("invalid source %q", fields[3])
+			}
+			e.Source = HSTSSource(source)
+		} else {
+			const pinLen = 44 // base64 SHA-256
+			pins := fields[3]
+			if len(pins)%pinLen != 0 {
+				return nil, fmt.Errorf("pins not a multiple of %d bytes: %q", pinLen, pins)
+			}
+			for i := 0; i < len(pins); i += pinLen {
+				e.Pins = append(e.Pins, pins[i:i+pinLen])
+			}
+		}
+	}
+	return e, nil
+}
+
+func (e *SiteSecurity) key() string {
+	return e.Host + e.OriginAttributes.String() + ":" + string(e.Type)
+}
+
+// Active reports whether the entry enforces HTTPS or pins for its host
+// at time t.
+func (e *SiteSecurity) Active(t time.Time) bool {
+	return e.State == SiteSecuritySet && t.Before(e.Expires)
+}
+
+func trimNUL(b []byte) []byte {
+	if i := bytes.IndexByte(b, 0); i != -1 {
+		return b[:i]
+	}
+	return b
+}
+
+func (s SiteSecurityState) String() string {
+	switch s {
+	case SiteSecurityUnset:
+		return "unset"
+	case SiteSecuritySet:
+		return "set"
+	case SiteSecurityKnockout:
+		return "knockout"
+	case SiteSecurityNegative:
+		return "negative"
+	default:
+		return fmt.Sprintf("state(%d)", uint8(s))
+	}
+}
+
+func (s HSTSSource) String() string {
+	switch s {
+	case HSTSSourceUnknown:
+		return "unknown"
+	case HSTSSourcePreload:
+		return "preload"
+	case HSTSSourceOrganic:
+		return "organic"
+	case HSTSSourcePriming:
+		return "priming"
+	default:
+		return fmt.Sprintf("source(%d)", uint8(s))
+	}
+}
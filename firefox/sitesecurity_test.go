@@ -0,0 +1,77 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfileSiteSecurity(t *testing.T) {
+	dir := t.TempDir()
+	pin1 := strings.Repeat("A", 43) + "="
+	pin2 := strings.Repeat("B", 43) + "="
+	text := "example.com:HSTS\t5\t18676\t1645567899123,1,1,2\n" +
+		"example.org^privateBrowsingId=1:HSTS\t1\t18677\t1645567899000,2,0\n" +
+		"example.net:HPKP\t2\t18600\t1625567899000,1,0," + pin1 + pin2 + "\n"
+	if err := os.WriteFile(filepath.Join(dir, SiteSecurityTextFile), []byte(text), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	bin := make([]byte, 3*siteSecuritySlotLen)
+	putSlot := func(slot []byte, score, days uint16, key, value string) {
+		binary.BigEndian.PutUint16(slot[2:], score)
+		binary.BigEndian.PutUint16(slot[4:], days)
+		copy(slot[6:], key)
+		copy(slot[6+siteSecurityKeyLen:], value)
+		var sum uint16
+		for i := 2; i < len(slot); i += 2 {
+			sum ^= binary.BigEndian.Uint16(slot[i:])
+		}
+		binary.BigEndian.PutUint16(slot, sum)
+	}
+	putSlot(bin, 7, 19000, "example.com:HSTS", "1672531200000,1,0")
+	putSlot(bin[2*siteSecuritySlotLen:], 1, 19001, "sub.example.com:HSTS", "1672617600000,1,1")
+	if err := os.WriteFile(filepath.Join(dir, SiteSecurityBinaryFile), bin, 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ProfileSiteSecurity(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(days int64) time.Time { return time.Unix(days*86400, 0).UTC() }
+	msec := func(ms int64) time.Time { return time.Unix(0, ms*1e6).UTC() }
+	want := []SiteSecurity{
+		{Host: "example.com", OriginAttributes: &OriginAttributes{}, Type: SiteSecurityHSTS, Score: 7, LastAccessed: day(19000),
+			Expires: msec(1672531200000), State: SiteSecuritySet, Store: SiteSecurityBinaryFile},
+		{Host: "sub.example.com", OriginAttributes: &OriginAttributes{}, Type: SiteSecurityHSTS, Score: 1, LastAccessed: day(19001),
+			Expires: msec(1672617600000), State: SiteSecuritySet, IncludeSubdomains: true, Store: SiteSecurityBinaryFile},
+		{Host: "example.org", OriginAttributes: &OriginAttributes{PrivateBrowsingID: 1}, Type: SiteSecurityHSTS, Score: 1, LastAccessed: day(18677),
+			Expires: msec(1645567899000), State: SiteSecurityKnockout, Store: SiteSecurityTextFile},
+		{Host: "example.net", OriginAttributes: &OriginAttributes{}, Type: SiteSecurityHPKP, Score: 2, LastAccessed: day(18600),
+			Expires: msec(1625567899000), State: SiteSecuritySet, Pins: []string{pin1, pin2}, Store: SiteSecurityTextFile},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", entries, want)
+	}
+	if !entries[0].Active(msec(1672531199999)) || entries[0].Active(msec(1672531200000)) || entries[2].Active(day(18677)) {
+		t.Errorf("wrong Active result")
+	}
+
+	bin[10] ^= 1
+	if err := os.WriteFile(filepath.Join(dir, SiteSecurityBinaryFile), bin, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseSiteSecurityStateBin(filepath.Join(dir, SiteSecurityBinaryFile)); err == nil {
+		t.Errorf("no error for checksum mismatch")
+	}
+}
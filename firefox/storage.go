@@ -0,0 +1,239 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Quota manager storage layout:
+// https://searchfox.org/mozilla-central/source/dom/quota/ActorsParent.cpp
+//
+// Each origin has a directory storage/{repository}/{sanitized origin}
+// containing a .metadata-v2 file and a directory per client (e.g. "idb"
+// for IndexedDB, "cache" for DOM Cache, "ls" for localStorage). The
+// storage.sqlite file in the profile root caches origin usage, so that
+// the directories need not be scanned on startup.
+
+// StorageOrigin is the storage usage of an origin in the quota
+// manager.
+type StorageOrigin struct {
+	Repository  string           // "default", "persistent", "temporary", or "permanent"
+	Dir         string           // sanitized origin directory name
+	Metadata    *StorageMetadata // nil when .metadata-v2 is missing
+	Usage       int64            // total bytes of all clients
+	ClientUsage map[string]int64 // key: client directory (e.g. "idb", "cache", "ls"), value: bytes
+}
+
+// StorageMetadata is the contents of a .metadata-v2 file in an origin
+// directory.
+type StorageMetadata struct {
+	Timestamp time.Time // last access time
+	Persisted bool
+	Suffix    string // origin attributes (e.g. "^userContextId=1")
+	Group     string // eTLD+1 group (e.g. "https://example.com")
+	Origin    string // e.g. "https://www.example.com"
+}
+
+// CachedOrigin is the cached usage of an origin in storage.sqlite.
+type CachedOrigin struct {
+	Repository     string
+	Origin         string
+	Group          string
+	ClientUsages   string // raw serialized usage per client
+	Usage          int64
+	LastAccessTime time.Time
+	Accessed       bool
+	Persisted      bool
+}
+
+var storageRepositories = []string{"default", "persistent", "temporary", "permanent"}
+
+// ScanStorage reports the storage used by each origin in the storage
+// directory of a Firefox profile. Origins are ordered by descending
+// usage. Origins that fail to scan are handled as by ScanStorageOptions
+// with the zero Options.
+func ScanStorage(profileDir string) ([]StorageOrigin, error) {
+	return ScanStorageOptions(profileDir, browser.Options{})
+}
+
+// ScanStorageOptions reports the storage used by each origin in a
+// Firefox profile and handles origins that fail to scan by the error
+// policy in opts.
+func ScanStorageOptions(profileDir string, opts browser.Options) ([]StorageOrigin, error) {
+	h := opts.Handler()
+	var origins []StorageOrigin
+	for _, repo := range storageRepositories {
+		dirs, err := os.ReadDir(filepath.Join(profileDir, "storage", repo))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range dirs {
+			if !dir.IsDir() {
+				continue
+			}
+			originDir := filepath.Join(profileDir, "storage", repo, dir.Name())
+			o, err := scanStorageOrigin(originDir)
+			if err != nil {
+				if err := h.File(originDir, err); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			o.Repository = repo
+			origins = append(origins, *o)
+		}
+	}
+	sort.SliceStable(origins, func(i, j int) bool {
+		return origins[i].Usage > origins[j].Usage
+	})
+	return origins, h.Err()
+}
+
+func scanStorageOrigin(dir string) (*StorageOrigin, error) {
+	o := &StorageOrigin{Dir: filepath.Base(dir), ClientUsage: make(map[string]int64)}
+	meta, err := ParseStorageMetadata(filepath.Join(dir, ".metadata-v2"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	o.Metadata = meta
+
+	clients, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, client := range clients {
+		if !client.IsDir() {
+			continue
+		}
+		var size int64
+		err := filepath.WalkDir(filepath.Join(dir, client.Name()), func(path string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += fi.Size()
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		o.ClientUsage[client.Name()] = size
+		o.Usage += size
+	}
+	return o, nil
+}
+
+// ParseStorageMetadata parses a .metadata-v2 file in an origin
+// directory within the storage directory of a Firefox profile.
+func ParseStorageMetadata(filename string) (*StorageMetadata, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	br := bytes.NewReader(data)
+
+	// Serialized with nsIBinaryOutputStream in big endian.
+	var header struct {
+		Timestamp int64
+		Persisted bool
+		Reserved1 uint32
+		Reserved2 uint32
+	}
+	if err := binary.Read(br, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("firefox: storage metadata: %w", err)
+	}
+	if header.Timestamp < 0 {
+		return nil, fmt.Errorf("firefox: storage metadata: negative timestamp: %d", header.Timestamp)
+	}
+	var meta StorageMetadata
+	meta.Timestamp = timefmt.FromInt(header.Timestamp, 0, timefmt.Micro, timefmt.Unix)
+	meta.Persisted = header.Persisted
+	for _, s := range []*string{&meta.Suffix, &meta.Group, &meta.Origin} {
+		if *s, err = readStringZ(br); err != nil {
+			return nil, fmt.Errorf("firefox: storage metadata: %w", err)
+		}
+	}
+	var isApp bool // unused
+	if err := binary.Read(br, binary.BigEndian, &isApp); err != nil {
+		return nil, fmt.Errorf("firefox: storage metadata: %w", err)
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		return nil, errors.New("firefox: storage metadata: trailing data")
+	}
+	return &meta, nil
+}
+
+// readStringZ reads a string written by nsIBinaryOutputStream.WriteStringZ,
+// which is prefixed by its length.
+func readStringZ(r *bytes.Reader) (string, error) {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return "", err
+	}
+	if int64(n) > int64(r.Len()) {
+		return "", fmt.Errorf("string length %d exceeds data", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// ParseStorageCache parses the cached origin usage in storage.sqlite
+// in a Firefox profile.
+func ParseStorageCache(filename string) ([]CachedOrigin, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var origins []CachedOrigin
+	err = sqliteutil.Query(db, `
+		SELECT r.name, o.origin, o.group_, o.client_usages, o.usage,
+			o.last_access_time, o.accessed, o.persisted
+		FROM origin o JOIN repository r ON o.repository_id = r.id
+		ORDER BY o.usage DESC, o.origin`, func(rows *sql.Rows) error {
+		var o CachedOrigin
+		var lastAccess int64
+		if err := rows.Scan(&o.Repository, &o.Origin, &o.Group, &o.ClientUsages,
+			&o.Usage, &lastAccess, &o.Accessed, &o.Persisted); err != nil {
+			return err
+		}
+		if lastAccess < 0 {
+			return fmt.Errorf("origin %s: negative last access time: %d", o.Origin, lastAccess)
+		}
+		o.LastAccessTime = timefmt.FromInt(lastAccess, 0, timefmt.Micro, timefmt.Unix)
+		origins = append(origins, o)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firefox: storage cache: %w", err)
+	}
+	return origins, nil
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser"
+)
+
+// storageMetadata encodes a .metadata-v2 file.
+func storageMetadata(timestamp int64, persisted bool, strs ...string) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, timestamp)
+	binary.Write(&buf, binary.BigEndian, persisted)
+	binary.Write(&buf, binary.BigEndian, [2]uint32{})
+	for _, s := range strs {
+		binary.Write(&buf, binary.BigEndian, uint32(len(s)))
+		buf.WriteString(s)
+	}
+	buf.WriteByte(0) // isApp
+	return buf.Bytes()
+}
+
+func TestScanStorage(t *testing.T) {
+	profile := t.TempDir()
+	files := map[string][]byte{
+		"storage/default/https+++example.com/.metadata-v2": storageMetadata(1613610123456789, true,
+			"", "https://example.com", "https://example.com"),
+		"storage/default/https+++example.com/idb/1.sqlite":      make([]byte, 300),
+		"storage/default/https+++example.com/ls/data.sqlite":    make([]byte, 100),
+		"storage/temporary/https+++cdn.example.org/cache/a.tmp": make([]byte, 50),
+		"storage/default/https+++bad.example/.metadata-v2":      storageMetadata(math.MinInt64, false, "", "", ""),
+	}
+	for name, data := range files {
+		path := filepath.Join(profile, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	origins, err := ScanStorage(profile)
+	var errs browser.Errors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].File != filepath.Join(profile, "storage", "default", "https+++bad.example") {
+		t.Errorf("got error %v, want error for bad.example", err)
+	}
+	want := []StorageOrigin{
+		{
+			Repository: "default",
+			Dir:        "https+++example.com",
+			Metadata: &StorageMetadata{
+				Timestamp: time.Unix(1613610123, 456789000).UTC(),
+				Persisted: true,
+				Group:     "https://example.com",
+				Origin:    "https://example.com",
+			},
+			Usage:       400,
+			ClientUsage: map[string]int64{"idb": 300, "ls": 100},
+		},
+		{
+			Repository:  "temporary",
+			Dir:         "https+++cdn.example.org",
+			Usage:       50,
+			ClientUsage: map[string]int64{"cache": 50},
+		},
+	}
+	if !reflect.DeepEqual(origins, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", origins, want)
+	}
+}
+
+func TestParseStorageMetadataErrors(t *testing.T) {
+	dir := t.TempDir()
+	huge := storageMetadata(0, false)
+	huge = append(huge[:len(huge)-1], 0xff, 0xff, 0xff, 0xff)
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"negative timestamp", storageMetadata(-1, false, "", "", "")},
+		{"string exceeds data", huge},
+		{"truncated header", []byte{0, 0, 0}},
+		{"trailing data", append(storageMetadata(0, false, "", "", ""), 0)},
+	} {
+		filename := filepath.Join(dir, ".metadata-v2")
+		if err := os.WriteFile(filename, tt.data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if meta, err := ParseStorageMetadata(filename); err == nil {
+			t.Errorf("%s: got %+v, want error", tt.name, meta)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// storage-sync-v2.sqlite schema:
+// https://github.com/mozilla/application-services/blob/main/components/webext-storage/sql/create_schema.sql
+//
+// The storage_sync_data table holds the local storage.sync area of
+// each extension as a JSON object, with NULL for a deletion that is not
+// yet synced. The storage_sync_mirror table holds the data last synced
+// from the server.
+
+// ExtensionStorage is the storage.sync area of a WebExtension.
+type ExtensionStorage struct {
+	ExtensionID       string
+	Name              string                     // name in extensions.json, when resolved
+	Data              map[string]json.RawMessage // nil when deleted
+	Deleted           bool                       // cleared locally and not yet synced
+	SyncChangeCounter int                        // local changes not yet synced
+	Mirror            map[string]json.RawMessage // last synced from the server, if any
+}
+
+// ParseStorageSync parses storage-sync-v2.sqlite in a Firefox profile,
+// which backs the storage.sync WebExtension API. Extensions are ordered
+// by ID.
+func ParseStorageSync(filename string) ([]ExtensionStorage, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var storage []ExtensionStorage
+	index := make(map[string]int)
+	err = sqliteutil.Query(db, `
+		SELECT ext_id, data, sync_change_counter
+		FROM storage_sync_data
+		ORDER BY ext_id`, func(rows *sql.Rows) error {
+		var s ExtensionStorage
+		var data sql.NullString
+		if err := rows.Scan(&s.ExtensionID, &data, &s.SyncChangeCounter); err != nil {
+			return err
+		}
+		if !data.Valid {
+			s.Deleted = true
+		} else if err := json.Unmarshal([]byte(data.String), &s.Data); err != nil {
+			return fmt.Errorf("%s: %w", s.ExtensionID, err)
+		}
+		index[s.ExtensionID] = len(storage)
+		storage = append(storage, s)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firefox: storage sync: %w", err)
+	}
+
+	if ok, err := sqliteutil.HasTable(db, "storage_sync_mirror"); err != nil || !ok {
+		return storage, err
+	}
+	err = sqliteutil.Query(db, `
+		SELECT ext_id, data
+		FROM storage_sync_mirror
+		WHERE data IS NOT NULL`, func(rows *sql.Rows) error {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return err
+		}
+		i, ok := index[id]
+		if !ok {
+			i = len(storage)
+			index[id] = i
+			storage = append(storage, ExtensionStorage{ExtensionID: id})
+		}
+		if err := json.Unmarshal([]byte(data), &storage[i].Mirror); err != nil {
+			return fmt.Errorf("%s: mirror: %w", id, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firefox: storage sync: %w", err)
+	}
+	sort.SliceStable(storage, func(i, j int) bool {
+		return storage[i].ExtensionID < storage[j].ExtensionID
+	})
+	return storage, nil
+}
+
+// ProfileStorageSync reads the storage.sync data of a Firefox profile
+// and resolves the names of the extensions from extensions.json, when
+// it exists. Files that fail to parse are handled as by
+// ProfileStorageSyncOptions with the zero Options.
+func ProfileStorageSync(profileDir string) ([]ExtensionStorage, error) {
+	return ProfileStorageSyncOptions(profileDir, browser.Options{})
+}
+
+// ProfileStorageSyncOptions reads the storage.sync data of a Firefox
+// profile and handles files that fail to parse by the error policy in
+// opts. When extensions.json is skipped, names are not resolved.
+func ProfileStorageSyncOptions(profileDir string, opts browser.Options) ([]ExtensionStorage, error) {
+	h := opts.Handler()
+	filename := filepath.Join(profileDir, "storage-sync-v2.sqlite")
+	storage, err := ParseStorageSync(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	} else if err != nil {
+		if err := h.File(filename, err); err != nil {
+			return nil, err
+		}
+		return nil, h.Err()
+	}
+	filename = filepath.Join(profileDir, "extensions.json")
+	extensions, err := ParseExtensions(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return storage, nil
+	} else if err != nil {
+		if err := h.File(filename, err); err != nil {
+			return nil, err
+		}
+		return storage, h.Err()
+	}
+	ResolveExtensionNames(storage, extensions)
+	return storage, nil
+}
+
+// ResolveExtensionNames sets the name of each extension from its
+// default locale in extensions.json.
+func ResolveExtensionNames(storage []ExtensionStorage, extensions *Extensions) {
+	for i := range storage {
+		if a := extensions.Addon(storage[i].ExtensionID); a != nil {
+			storage[i].Name = a.DefaultLocale.Name
+		}
+	}
+}
+
+// Addon returns the add-on with the given ID, or nil if it is not
+// installed.
+func (e *Extensions) Addon(id string) *Addon {
+	for i := range e.Addons {
+		if a := &e.Addons[i]; a.ID != nil && a.ID.String() == id {
+			return a
+		}
+	}
+	return nil
+}
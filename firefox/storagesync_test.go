@@ -0,0 +1,59 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestProfileStorageSync(t *testing.T) {
+	profile := t.TempDir()
+	db, err := sql.Open("sqlite3", filepath.Join(profile, "storage-sync-v2.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE storage_sync_data (ext_id TEXT NOT NULL PRIMARY KEY, data TEXT,
+			sync_change_counter INTEGER NOT NULL DEFAULT 1);
+		CREATE TABLE storage_sync_mirror (guid TEXT NOT NULL PRIMARY KEY, ext_id TEXT NOT NULL UNIQUE, data TEXT);
+		INSERT INTO storage_sync_data VALUES
+			('addon@example.com', '{"theme":"dark","count":2}', 1),
+			('{01234567-89ab-cdef-0123-456789abcdef}', NULL, 1);
+		INSERT INTO storage_sync_mirror VALUES
+			('guid1', 'addon@example.com', '{"theme":"light"}'),
+			('guid2', 'removed@example.com', '{"a":null}'),
+			('guid3', 'tombstone@example.com', NULL);
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	extensions := `{"schemaVersion":33,"addons":[{"id":"addon@example.com","defaultLocale":{"name":"Example"}}]}`
+	if err := os.WriteFile(filepath.Join(profile, "extensions.json"), []byte(extensions), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	storage, err := ProfileStorageSync(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ExtensionStorage{
+		{ExtensionID: "addon@example.com", Name: "Example", SyncChangeCounter: 1,
+			Data:   map[string]json.RawMessage{"theme": json.RawMessage(`"dark"`), "count": json.RawMessage(`2`)},
+			Mirror: map[string]json.RawMessage{"theme": json.RawMessage(`"light"`)}},
+		{ExtensionID: "removed@example.com", Mirror: map[string]json.RawMessage{"a": json.RawMessage(`null`)}},
+		{ExtensionID: "{01234567-89ab-cdef-0123-456789abcdef}", Deleted: true, SyncChangeCounter: 1},
+	}
+	if !reflect.DeepEqual(storage, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", storage, want)
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser"
+)
+
+// Sync reference:
+// https://searchfox.org/mozilla-central/source/services/sync/modules/service.js
+// https://searchfox.org/mozilla-central/source/services/common/logmanager.js
+//
+// The Firefox Account is kept in signedInUser.json and the Sync
+// settings in prefs. When a sync fails, or succeeds with log-on-success
+// enabled, the log is written to
+// weave/logs/{reason}-{prefix}-{milliseconds}.txt, where the reason is
+// "error" or "success" and the prefix is e.g. "sync".
+
+// SyncState is the Firefox Sync state of a profile.
+type SyncState struct {
+	User       *SignedInUser   // nil when not signed in
+	DeviceName string          // identity.fxaccounts.account.device.name
+	Username   string          // services.sync.username
+	ClientGUID string          // services.sync.client.GUID
+	LastSync   time.Time       // services.sync.lastSync; zero when never synced
+	Engines    map[string]bool // services.sync.engine.{name}, when set; e.g. "bookmarks"
+	Logs       []SyncLogFile   // oldest first
+}
+
+// SyncLogFile is a log file in weave/logs.
+type SyncLogFile struct {
+	Path   string
+	Reason string    // "error" or "success"
+	Prefix string    // e.g. "sync"
+	Time   time.Time // time written
+}
+
+// SyncLogEntry is a message in a Sync log file.
+type SyncLogEntry struct {
+	Time    time.Time
+	Logger  string // e.g. "Sync.Service"
+	Level   string // e.g. "ERROR", "WARN", "INFO", "DEBUG", or "TRACE"
+	Message string // may span several lines, such as for stack traces
+}
+
+// ProfileSyncState reads the Sync state of a Firefox profile from
+// signedInUser.json, prefs, and weave/logs. Missing files are skipped
+// and files that fail to parse are handled as by
+// ProfileSyncStateOptions with the zero Options.
+func ProfileSyncState(profileDir string) (*SyncState, error) {
+	return ProfileSyncStateOptions(profileDir, browser.Options{})
+}
+
+// ProfileSyncStateOptions reads the Sync state of a Firefox profile and
+// handles files that fail to parse by the error policy in opts.
+func ProfileSyncStateOptions(profileDir string, opts browser.Options) (*SyncState, error) {
+	h := opts.Handler()
+	var s SyncState
+	filename := filepath.Join(profileDir, "signedInUser.json")
+	user, err := ParseSignedInUser(filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		if err := h.File(filename, err); err != nil {
+			return nil, err
+		}
+	}
+	s.User = user
+	prefs, err := ProfilePrefsOptions(profileDir, opts)
+	if err := h.Merge(err); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err := s.readPrefs(prefs); err != nil {
+		if err := h.File(filepath.Join(profileDir, "prefs.js"), err); err != nil {
+			return nil, err
+		}
+	}
+	if s.Logs, err = ListSyncLogs(profileDir); err != nil {
+		return nil, err
+	}
+	return &s, h.Err()
+}
+
+// jsDateLayout is the layout of Date.prototype.toString, without the
+// trailing time zone name, e.g. " (Pacific Standard Time)".
+const jsDateLayout = "Mon Jan 02 2006 15:04:05 GMT-0700"
+
+func (s *SyncState) readPrefs(prefs Prefs) error {
+	getString := func(name string, v *string) error {
+		if p, ok := prefs[name]; ok {
+			str, ok := p.Value().(string)
+			if !ok {
+				return fmt.Errorf("firefox: pref %s: not a string: %v", name, p.Value())
+			}
+			*v = str
+		}
+		return nil
+	}
+	var lastSync string
+	for _, pref := range []struct {
+		name string
+		v    *string
+	}{
+		{"identity.fxaccounts.account.device.name", &s.DeviceName},
+		{"services.sync.username", &s.Username},
+		{"services.sync.client.GUID", &s.ClientGUID},
+		{"services.sync.lastSync", &lastSync},
+	} {
+		if err := getString(pref.name, pref.v); err != nil {
+			return err
+		}
+	}
+	if lastSync != "" {
+		if i := strings.Index(lastSync, " ("); i != -1 {
+			lastSync = lastSync[:i]
+		}
+		t, err := time.Parse(jsDateLayout, lastSync)
+		if err != nil {
+			return fmt.Errorf("firefox: pref services.sync.lastSync: %w", err)
+		}
+		s.LastSync = t
+	}
+	for name, p := range prefs {
+		engine := strings.TrimPrefix(name, "services.sync.engine.")
+		if engine == name || strings.Contains(engine, ".") {
+			continue
+		}
+		enabled, ok := p.Value().(bool)
+		if !ok {
+			return fmt.Errorf("firefox: pref %s: not a bool: %v", name, p.Value())
+		}
+		if s.Engines == nil {
+			s.Engines = make(map[string]bool)
+		}
+		s.Engines[engine] = enabled
+	}
+	return nil
+}
+
+// ListSyncLogs lists the log files in weave/logs in a Firefox profile,
+// oldest first. Files not named like a log are skipped.
+func ListSyncLogs(profileDir string) ([]SyncLogFile, error) {
+	dir := filepath.Join(profileDir, "weave", "logs")
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var logs []SyncLogFile
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".txt")
+		parts := strings.Split(name, "-")
+		if entry.IsDir() || name == entry.Name() || len(parts) != 3 ||
+			parts[0] != "error" && parts[0] != "success" {
+			continue
+		}
+		ms, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		logs = append(logs, SyncLogFile{
+			Path:   filepath.Join(dir, entry.Name()),
+			Reason: parts[0],
+			Prefix: parts[1],
+			Time:   time.Unix(0, ms*int64(time.Millisecond)).UTC(),
+		})
+	}
+	sort.SliceStable(logs, func(i, j int) bool {
+		return logs[i].Time.Before(logs[j].Time)
+	})
+	return logs, nil
+}
+
+// ParseSyncLog parses a log file in weave/logs. Each message is
+// "{milliseconds}\t{logger}\t{level}\t{message}" and lines that do not
+// start a message continue the previous one.
+func ParseSyncLog(filename string) ([]SyncLogEntry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	var entries []SyncLogEntry
+	for line := 1; s.Scan(); line++ {
+		text := s.Text()
+		fields := strings.SplitN(text, "\t", 4)
+		var ms int64
+		ok := len(fields) == 4
+		if ok {
+			var err error
+			ms, err = strconv.ParseInt(fields[0], 10, 64)
+			ok = err == nil
+		}
+		if !ok {
+			if len(entries) == 0 {
+				return nil, fmt.Errorf("firefox: sync log: line %d: not a log message", line)
+			}
+			e := &entries[len(entries)-1]
+			e.Message += "\n" + text
+			continue
+		}
+		entries = append(entries, SyncLogEntry{
+			Time:    time.Unix(0, ms*int64(time.Millisecond)).UTC(),
+			Logger:  fields[1],
+			Level:   fields[2],
+			Message: fields[3],
+		})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProfileSyncState(t *testing.T) {
+	profile := t.TempDir()
+	logs := filepath.Join(profile, "weave", "logs")
+	if err := os.MkdirAll(logs, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"signedInUser.json": `{"version": 1, "accountData": {
+			"uid": "0123456789abcdef0123456789abcdef", "email": "user@example.com", "verified": true,
+			"authAt": 1613610123, "sessionToken": "secret", "kSync": "secret",
+			"device": {"id": "fedcba9876543210", "registrationVersion": 2,
+				"registeredCommandsKeys": ["https://identity.mozilla.com/cmd/open-uri"]},
+			"oauthTokens": {"profile": {"token": "secret", "scope": "profile"}}}}`,
+		"prefs.js": `user_pref("identity.fxaccounts.account.device.name", "Firefox on laptop");
+user_pref("services.sync.client.GUID", "Ab3dEf6hIj9k");
+user_pref("services.sync.engine.history", false);
+user_pref("services.sync.engine.passwords", true);
+user_pref("services.sync.engine.addresses.available", true);
+user_pref("services.sync.lastSync", "Thu Feb 18 2021 17:02:03 GMT-0800 (Pacific Standard Time)");
+user_pref("services.sync.username", "user@example.com");
+`,
+		"weave/logs/error-sync-1613610124000.txt": "1613610123000\tSync.Service\tINFO\tStarting sync\n" +
+			"1613610123500\tSync.Engine.Bookmarks\tERROR\tSync failed: Error\n  at sync@engines.js:1:2\n",
+		"weave/logs/success-sync-1613610120000.txt": "1613610119000\tSync.Service\tINFO\tSync completed\n",
+		"weave/logs/notes.txt":                      "",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(profile, name), []byte(data), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := ProfileSyncState(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msec := func(ms int64) time.Time { return time.Unix(0, ms*1e6).UTC() }
+	account := s.User.AccountData
+	if account.AuthAt == nil || !account.AuthAt.Equal(time.Unix(1613610123, 0)) ||
+		account.Device == nil || account.Device.ID != "fedcba9876543210" ||
+		!reflect.DeepEqual(account.OAuthTokens, map[string]AccountOAuthToken{"profile": {Scope: "profile"}}) {
+		t.Errorf("got account %+v", account)
+	}
+	s.User = nil
+	want := &SyncState{
+		DeviceName: "Firefox on laptop",
+		Username:   "user@example.com",
+		ClientGUID: "Ab3dEf6hIj9k",
+		LastSync:   time.Date(2021, 2, 18, 17, 2, 3, 0, time.FixedZone("", -8*60*60)),
+		Engines:    map[string]bool{"history": false, "passwords": true},
+		Logs: []SyncLogFile{
+			{Path: filepath.Join(logs, "success-sync-1613610120000.txt"), Reason: "success", Prefix: "sync", Time: msec(1613610120000)},
+			{Path: filepath.Join(logs, "error-sync-1613610124000.txt"), Reason: "error", Prefix: "sync", Time: msec(1613610124000)},
+		},
+	}
+	if !s.LastSync.Equal(want.LastSync) {
+		t.Errorf("got last sync %v, want %v", s.LastSync, want.LastSync)
+	}
+	s.LastSync = want.LastSync
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", s, want)
+	}
+
+	entries, err := ParseSyncLog(want.Logs[1].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEntries := []SyncLogEntry{
+		{Time: msec(1613610123000), Logger: "Sync.Service", Level: "INFO", Message: "Starting sync"},
+		{Time: msec(1613610123500), Logger: "Sync.Engine.Bookmarks", Level: "ERROR", Message: "Sync failed: Error\n  at sync@engines.js:1:2"},
+	}
+	if !reflect.DeepEqual(entries, wantEntries) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", entries, wantEntries)
+	}
+}
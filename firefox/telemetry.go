@@ -0,0 +1,245 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/compress/mozlz4"
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+// Telemetry reference:
+// https://firefox-source-docs.mozilla.org/toolkit/components/telemetry/concepts/archiving.html
+// https://firefox-source-docs.mozilla.org/toolkit/components/telemetry/data/common-ping.html
+// https://firefox-source-docs.mozilla.org/toolkit/components/telemetry/data/environment.html
+//
+// Pings that have not yet been sent are kept in saved-telemetry-pings/{id}
+// as JSON. Sent pings are archived in
+// datareporting/archived/{yyyy-MM}/{milliseconds}.{id}.{type}.jsonlz4,
+// compressed with mozLz4. The client ID is kept in
+// datareporting/state.json.
+
+// Telemetry is the Telemetry state of a profile.
+type Telemetry struct {
+	State *TelemetryState     // nil when datareporting/state.json is missing
+	Pings []TelemetryPingFile // pending pings first, then archived pings, oldest first
+}
+
+// TelemetryState is the client state in datareporting/state.json.
+type TelemetryState struct {
+	ClientID          string `json:"clientID"`
+	EcosystemClientID string `json:"ecosystemClientId,omitempty"`
+	ProfileGroupID    string `json:"profileGroupId,omitempty"`
+}
+
+// TelemetryPingFile is a ping file, either pending or archived.
+type TelemetryPingFile struct {
+	Path     string
+	ID       string
+	Type     string    // e.g. "main" or "event"; empty for pending pings
+	Time     time.Time // creation time; zero for pending pings
+	Archived bool      // in datareporting/archived, rather than saved-telemetry-pings
+}
+
+// TelemetryPing is the common envelope of a ping. The payload is
+// specific to the ping type and is kept as is.
+type TelemetryPing struct {
+	Type         string                `json:"type"` // e.g. "main", "event", or "crash"
+	ID           string                `json:"id"`
+	CreationDate time.Time             `json:"creationDate"`
+	Version      int                   `json:"version"` // e.g. 4
+	ClientID     string                `json:"clientId,omitempty"`
+	Application  TelemetryApplication  `json:"application"`
+	Environment  *TelemetryEnvironment `json:"environment,omitempty"`
+	Payload      json.RawMessage       `json:"payload,omitempty"`
+}
+
+// TelemetryApplication identifies the build that sent a ping.
+type TelemetryApplication struct {
+	Architecture    string `json:"architecture"` // e.g. "x86-64"
+	BuildID         string `json:"buildId"`      // e.g. "20210211001234"
+	Name            string `json:"name"`         // e.g. "Firefox"
+	Version         string `json:"version"`      // e.g. "85.0.2"
+	DisplayVersion  string `json:"displayVersion,omitempty"`
+	Vendor          string `json:"vendor"`
+	PlatformVersion string `json:"platformVersion"`
+	XPCOMABI        string `json:"xpcomAbi"` // e.g. "x86_64-gcc3"
+	Channel         string `json:"channel"`  // e.g. "release"
+}
+
+// TelemetryEnvironment is a summary of the environment of a ping. Only
+// the fields useful to identify the profile and system are decoded.
+type TelemetryEnvironment struct {
+	Build    TelemetryBuild    `json:"build"`
+	Settings TelemetrySettings `json:"settings"`
+	Profile  TelemetryProfile  `json:"profile"`
+	System   TelemetrySystem   `json:"system"`
+}
+
+// TelemetryBuild is the build of the environment.
+type TelemetryBuild struct {
+	ApplicationName string `json:"applicationName"`
+	Architecture    string `json:"architecture"`
+	BuildID         string `json:"buildId"`
+	Version         string `json:"version"`
+	Vendor          string `json:"vendor"`
+	DisplayVersion  string `json:"displayVersion,omitempty"`
+	PlatformVersion string `json:"platformVersion"`
+}
+
+// TelemetrySettings is the settings of the environment.
+type TelemetrySettings struct {
+	DefaultSearchEngine string `json:"defaultSearchEngine,omitempty"`
+	IsDefaultBrowser    *bool  `json:"isDefaultBrowser"`
+	Locale              string `json:"locale"` // e.g. "en-US"
+	TelemetryEnabled    bool   `json:"telemetryEnabled"`
+	Update              struct {
+		Channel string `json:"channel"`
+		Enabled bool   `json:"enabled"`
+	} `json:"update"`
+}
+
+// TelemetryProfile is the profile of the environment. Dates are in days
+// since the Unix epoch.
+type TelemetryProfile struct {
+	CreationDate int  `json:"creationDate"`
+	ResetDate    *int `json:"resetDate,omitempty"`
+	FirstUseDate *int `json:"firstUseDate,omitempty"`
+}
+
+// TelemetrySystem is the system of the environment.
+type TelemetrySystem struct {
+	MemoryMB int `json:"memoryMB"`
+	OS       struct {
+		Name    string `json:"name"`    // e.g. "Linux", "Darwin", or "Windows_NT"
+		Version string `json:"version"` // e.g. "5.10.15"
+		Locale  string `json:"locale"`
+	} `json:"os"`
+	CPU struct {
+		Count  int    `json:"count"`
+		Cores  int    `json:"cores"`
+		Vendor string `json:"vendor"`
+	} `json:"cpu"`
+}
+
+// ProfileTelemetry reads the Telemetry state and lists the ping files
+// of a Firefox profile. Missing files are skipped and a state that fails
+// to parse is handled as by ProfileTelemetryOptions with the zero
+// Options.
+func ProfileTelemetry(profileDir string) (*Telemetry, error) {
+	return ProfileTelemetryOptions(profileDir, browser.Options{})
+}
+
+// ProfileTelemetryOptions reads the Telemetry state and lists the ping
+// files of a Firefox profile and handles a state that fails to parse by
+// the error policy in opts.
+func ProfileTelemetryOptions(profileDir string, opts browser.Options) (*Telemetry, error) {
+	h := opts.Handler()
+	var t Telemetry
+	filename := filepath.Join(profileDir, "datareporting", "state.json")
+	state, err := ParseTelemetryState(filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		if err := h.File(filename, err); err != nil {
+			return nil, err
+		}
+	}
+	t.State = state
+	if t.Pings, err = ListTelemetryPings(profileDir); err != nil {
+		return nil, err
+	}
+	return &t, h.Err()
+}
+
+// ParseTelemetryState parses datareporting/state.json in a Firefox
+// profile.
+func ParseTelemetryState(filename string) (*TelemetryState, error) {
+	var state TelemetryState
+	if err := jsonutil.DecodeFileAllowUnknownFields(filename, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// ListTelemetryPings lists the pending pings in saved-telemetry-pings,
+// ordered by ID, then the archived pings in datareporting/archived,
+// oldest first. Files not named like a ping are skipped.
+func ListTelemetryPings(profileDir string) ([]TelemetryPingFile, error) {
+	var pings []TelemetryPingFile
+	pending := filepath.Join(profileDir, "saved-telemetry-pings")
+	entries, err := os.ReadDir(pending)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.Contains(entry.Name(), ".") {
+			continue
+		}
+		pings = append(pings, TelemetryPingFile{
+			Path: filepath.Join(pending, entry.Name()),
+			ID:   entry.Name(),
+		})
+	}
+
+	archived, err := filepath.Glob(filepath.Join(profileDir, "datareporting", "archived", "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+	n := len(pings)
+	for _, path := range archived {
+		// {milliseconds}.{id}.{type}.json or .jsonlz4
+		parts := strings.Split(filepath.Base(path), ".")
+		if len(parts) != 4 || parts[3] != "json" && parts[3] != "jsonlz4" {
+			continue
+		}
+		ms, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		pings = append(pings, TelemetryPingFile{
+			Path:     path,
+			ID:       parts[1],
+			Type:     parts[2],
+			Time:     time.Unix(0, ms*int64(time.Millisecond)).UTC(),
+			Archived: true,
+		})
+	}
+	sort.SliceStable(pings[n:], func(i, j int) bool {
+		return pings[n+i].Time.Before(pings[n+j].Time)
+	})
+	return pings, nil
+}
+
+// ParseTelemetryPing parses a pending or archived ping. Archived pings
+// are decompressed from mozLz4.
+func ParseTelemetryPing(filename string) (*TelemetryPing, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if mozlz4.IsMozLz4(b) {
+		if b, err = mozlz4.Decode(b); err != nil {
+			return nil, err
+		}
+	}
+	var ping TelemetryPing
+	if err := jsonutil.DecodeAllowUnknownFields(bytes.NewReader(b), &ping); err != nil {
+		return nil, fmt.Errorf("firefox: telemetry ping: %w", err)
+	}
+	return &ping, nil
+}
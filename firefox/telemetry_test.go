@@ -0,0 +1,92 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/compress/mozlz4"
+)
+
+func TestProfileTelemetry(t *testing.T) {
+	profile := t.TempDir()
+	month := filepath.Join(profile, "datareporting", "archived", "2021-02")
+	pending := filepath.Join(profile, "saved-telemetry-pings")
+	for _, dir := range []string{month, pending} {
+		if err := os.MkdirAll(dir, 0o777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ping := `{"type": "main", "id": "4ffd2a3c-0c7e-4f4e-9d3a-1f5c3a1b2c3d",
+		"creationDate": "2021-02-18T23:02:03.456Z", "version": 4,
+		"clientId": "c1d2e3f4-a5b6-4c7d-8e9f-0a1b2c3d4e5f",
+		"application": {"architecture": "x86-64", "buildId": "20210211001234", "name": "Firefox",
+			"version": "85.0.2", "vendor": "Mozilla", "platformVersion": "85.0.2",
+			"xpcomAbi": "x86_64-gcc3", "channel": "release"},
+		"environment": {
+			"build": {"applicationName": "Firefox", "version": "85.0.2"},
+			"settings": {"defaultSearchEngine": "google-b-d", "isDefaultBrowser": true, "locale": "en-US",
+				"telemetryEnabled": false, "update": {"channel": "release", "enabled": true}},
+			"profile": {"creationDate": 18000, "firstUseDate": 18001},
+			"system": {"memoryMB": 16000, "os": {"name": "Linux", "version": "5.10.15", "locale": "en-US"},
+				"cpu": {"count": 8, "cores": 4, "vendor": "GenuineIntel"}},
+			"addons": {}},
+		"payload": {"info": {"reason": "shutdown"}}}`
+	compressed, err := mozlz4.Encode([]byte(ping))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"datareporting/state.json": []byte(`{"clientID": "c1d2e3f4-a5b6-4c7d-8e9f-0a1b2c3d4e5f", "ecosystemClientId": "e1"}`),
+		"datareporting/archived/2021-02/1613689323456.4ffd2a3c-0c7e-4f4e-9d3a-1f5c3a1b2c3d.main.jsonlz4":  compressed,
+		"datareporting/archived/2021-02/1613600000000.0a0b0c0d-0e0f-4a1b-8c2d-3e4f5a6b7c8d.event.jsonlz4": compressed,
+		"datareporting/archived/2021-02/notes.txt":                                                        nil,
+		"saved-telemetry-pings/9a8b7c6d-5e4f-4a3b-9c2d-1e0f9a8b7c6d":                                      []byte(ping),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(profile, name), data, 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tel, err := ProfileTelemetry(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msec := func(ms int64) time.Time { return time.Unix(0, ms*1e6).UTC() }
+	want := &Telemetry{
+		State: &TelemetryState{ClientID: "c1d2e3f4-a5b6-4c7d-8e9f-0a1b2c3d4e5f", EcosystemClientID: "e1"},
+		Pings: []TelemetryPingFile{
+			{Path: filepath.Join(pending, "9a8b7c6d-5e4f-4a3b-9c2d-1e0f9a8b7c6d"), ID: "9a8b7c6d-5e4f-4a3b-9c2d-1e0f9a8b7c6d"},
+			{Path: filepath.Join(month, "1613600000000.0a0b0c0d-0e0f-4a1b-8c2d-3e4f5a6b7c8d.event.jsonlz4"),
+				ID: "0a0b0c0d-0e0f-4a1b-8c2d-3e4f5a6b7c8d", Type: "event", Time: msec(1613600000000), Archived: true},
+			{Path: filepath.Join(month, "1613689323456.4ffd2a3c-0c7e-4f4e-9d3a-1f5c3a1b2c3d.main.jsonlz4"),
+				ID: "4ffd2a3c-0c7e-4f4e-9d3a-1f5c3a1b2c3d", Type: "main", Time: msec(1613689323456), Archived: true},
+		},
+	}
+	if !reflect.DeepEqual(tel, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", tel, want)
+	}
+
+	for _, f := range []string{want.Pings[0].Path, want.Pings[2].Path} {
+		p, err := ParseTelemetryPing(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env := p.Environment
+		if p.Type != "main" || !p.CreationDate.Equal(msec(1613689323456)) ||
+			p.ClientID != want.State.ClientID || p.Application.BuildID != "20210211001234" ||
+			env == nil || env.Settings.IsDefaultBrowser == nil || !*env.Settings.IsDefaultBrowser ||
+			env.Profile.CreationDate != 18000 || env.System.OS.Name != "Linux" || env.System.CPU.Cores != 4 ||
+			string(p.Payload) != `{"info": {"reason": "shutdown"}}` {
+			t.Errorf("%s: got %+v", f, p)
+		}
+	}
+}
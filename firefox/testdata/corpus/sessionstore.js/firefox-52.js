@@ -0,0 +1,47 @@
+{
+  "version": [
+    "sessionrestore",
+    1
+  ],
+  "windows": [
+    {
+      "tabs": [
+        {
+          "entries": [
+            {
+              "url": "https://host-d04e470a.example/679d293c",
+              "title": "text-a82eba8a",
+              "charset": "UTF-8",
+              "ID": 5,
+              "docshellUUID": "{2f0d2a3e-0a8b-4b33-9f2a-0e8bd0d6e2e0}",
+              "triggeringPrincipal_base64": "{\"3\":{}}",
+              "hasUserInteraction": true,
+              "persist": true
+            }
+          ],
+          "lastAccessed": 1612345678901,
+          "hidden": false,
+          "attributes": {},
+          "userContextId": 0,
+          "index": 1,
+          "image": "https://host-d04e470a.example/4607c2ae"
+        }
+      ],
+      "selected": 1,
+      "_closedTabs": [],
+      "width": 1280,
+      "height": 800,
+      "screenX": 0,
+      "screenY": 0,
+      "sizemode": "normal"
+    }
+  ],
+  "selectedWindow": 1,
+  "_closedWindows": [],
+  "session": {
+    "lastUpdate": 1612345678901,
+    "startTime": 1612340000000,
+    "recentCrashes": 0
+  },
+  "global": {}
+}
@@ -11,10 +11,14 @@ import (
 	"github.com/andrewarchi/browser/jsonutil/timefmt"
 )
 
+// times.json format:
+// https://searchfox.org/mozilla-central/source/toolkit/modules/ProfileAge.jsm
+
 // Times contains installation times in times.json.
 type Times struct {
-	Created  timefmt.UnixMilli `json:"created"`
-	FirstUse timefmt.UnixMilli `json:"firstUse"`
+	Created  timefmt.UnixMilli  `json:"created"`
+	FirstUse timefmt.UnixMilli  `json:"firstUse"`        // zero until the first session after creation
+	Reset    *timefmt.UnixMilli `json:"reset,omitempty"` // when the profile was last refreshed
 }
 
 // ParseTimes parses times.json in a Firefox profile.
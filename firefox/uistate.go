@@ -0,0 +1,188 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+// UI state preferences:
+// https://searchfox.org/mozilla-central/source/browser/components/newtab/lib/NewTabUtils.jsm
+// https://searchfox.org/mozilla-central/source/browser/components/ssb/SiteSpecificBrowserService.jsm
+// https://searchfox.org/mozilla-central/source/browser/modules/WindowsJumpLists.jsm
+// https://searchfox.org/mozilla-central/source/uriloader/exthandler/nsExternalHelperAppService.cpp
+//
+// Pinned top sites, site-specific browser (SSB) and Windows taskbar
+// settings, and external protocol handler settings are kept in prefs.js.
+// Installed SSBs, in Firefox 73 to 85, were stored in the kvstore
+// database in the ssb directory, which is not parsed.
+
+// UIState is the window and taskbar integration state of a profile.
+type UIState struct {
+	PinnedSites      []*PinnedSite                   // by position on the new tab page; nil for empty slots
+	SSBEnabled       bool                            // browser.ssb.enabled
+	Taskbar          TaskbarPrefs                    // Windows only
+	ProtocolHandlers map[string]*ProtocolHandlerPref // key: URI scheme, e.g. "mailto"
+}
+
+// PinnedSite is a site pinned to the top sites of the new tab page.
+type PinnedSite struct {
+	URL                 string `json:"url"`
+	Label               string `json:"label,omitempty"`
+	BaseDomain          string `json:"baseDomain,omitempty"`
+	SearchTopSite       bool   `json:"searchTopSite,omitempty"` // search shortcut, e.g. for "amazon"
+	SearchVendor        string `json:"searchVendor,omitempty"`
+	CustomScreenshotURL string `json:"customScreenshotURL,omitempty"`
+}
+
+// TaskbarPrefs are the Windows taskbar settings.
+type TaskbarPrefs struct {
+	JumpListEnabled  bool // browser.taskbar.lists.enabled
+	FrequentEnabled  bool // browser.taskbar.lists.frequent.enabled
+	RecentEnabled    bool // browser.taskbar.lists.recent.enabled
+	TasksEnabled     bool // browser.taskbar.lists.tasks.enabled
+	PreviewsEnabled  bool // browser.taskbar.previews.enable
+	MaxListItemCount int  // browser.taskbar.lists.maxListItemCount
+}
+
+// ProtocolHandlerPref is the external protocol handler settings for a
+// URI scheme in network.protocol-handler.{setting}.{scheme} prefs. A
+// nil setting is not set.
+type ProtocolHandlerPref struct {
+	External     *bool   // handled by an external application
+	Expose       *bool   // handled internally
+	WarnExternal *bool   // prompt before opening the external application
+	App          *string // path of the external application
+}
+
+// ProfileUIState reads the UI state in the prefs of a Firefox profile.
+// Prefs files that fail to parse are handled as by
+// ProfileUIStateOptions with the zero Options.
+func ProfileUIState(profileDir string) (*UIState, error) {
+	return ProfileUIStateOptions(profileDir, browser.Options{})
+}
+
+// ProfileUIStateOptions reads the UI state in the prefs of a Firefox
+// profile and handles prefs files that fail to parse by the error
+// policy in opts.
+func ProfileUIStateOptions(profileDir string, opts browser.Options) (*UIState, error) {
+	h := opts.Handler()
+	prefs, err := ProfilePrefsOptions(profileDir, opts)
+	if err := h.Merge(err); err != nil {
+		return nil, err
+	}
+	s, err := PrefsUIState(prefs)
+	if err != nil {
+		return nil, err
+	}
+	return s, h.Err()
+}
+
+// PrefsUIState extracts the UI state from prefs. Prefs that are not set
+// have the Firefox default value.
+func PrefsUIState(prefs Prefs) (*UIState, error) {
+	s := &UIState{
+		Taskbar: TaskbarPrefs{
+			JumpListEnabled:  true,
+			FrequentEnabled:  true,
+			RecentEnabled:    false,
+			TasksEnabled:     true,
+			PreviewsEnabled:  false,
+			MaxListItemCount: 7,
+		},
+		ProtocolHandlers: make(map[string]*ProtocolHandlerPref),
+	}
+	var err error
+	getBool := func(name string, v *bool) {
+		if p, ok := prefs[name]; ok && err == nil {
+			b, ok := p.Value().(bool)
+			if !ok {
+				err = fmt.Errorf("firefox: pref %s: not a bool: %v", name, p.Value())
+			}
+			*v = b
+		}
+	}
+	getBool("browser.ssb.enabled", &s.SSBEnabled)
+	getBool("browser.taskbar.lists.enabled", &s.Taskbar.JumpListEnabled)
+	getBool("browser.taskbar.lists.frequent.enabled", &s.Taskbar.FrequentEnabled)
+	getBool("browser.taskbar.lists.recent.enabled", &s.Taskbar.RecentEnabled)
+	getBool("browser.taskbar.lists.tasks.enabled", &s.Taskbar.TasksEnabled)
+	getBool("browser.taskbar.previews.enable", &s.Taskbar.PreviewsEnabled)
+	if p, ok := prefs["browser.taskbar.lists.maxListItemCount"]; ok && err == nil {
+		n, ok := p.Value().(int)
+		if !ok {
+			err = fmt.Errorf("firefox: pref browser.taskbar.lists.maxListItemCount: not an int: %v", p.Value())
+		}
+		s.Taskbar.MaxListItemCount = n
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p, ok := prefs["browser.newtabpage.pinned"]; ok {
+		pinned, ok := p.Value().(string)
+		if !ok {
+			return nil, fmt.Errorf("firefox: pref browser.newtabpage.pinned: not a string: %v", p.Value())
+		}
+		if err := jsonutil.Decode(strings.NewReader(pinned), &s.PinnedSites); err != nil {
+			return nil, fmt.Errorf("firefox: pref browser.newtabpage.pinned: %w", err)
+		}
+	}
+
+	names := make([]string, 0, len(prefs))
+	for name := range prefs {
+		if strings.HasPrefix(name, "network.protocol-handler.") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		setting := strings.TrimPrefix(name, "network.protocol-handler.")
+		dot := strings.IndexByte(setting, '.')
+		if dot == -1 {
+			continue // e.g. network.protocol-handler.expose-all
+		}
+		setting, scheme := setting[:dot], setting[dot+1:]
+		h := s.ProtocolHandlers[scheme]
+		if h == nil {
+			h = &ProtocolHandlerPref{}
+		}
+		v := prefs[name].Value()
+		var b *bool
+		switch setting {
+		case "external", "expose", "warn-external":
+			bv, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("firefox: pref %s: not a bool: %v", name, v)
+			}
+			b = &bv
+		case "app":
+			sv, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("firefox: pref %s: not a string: %v", name, v)
+			}
+			h.App = &sv
+		default:
+			continue // e.g. network.protocol-handler.external-default
+		}
+		switch setting {
+		case "external":
+			h.External = b
+		case "expose":
+			h.Expose = b
+		case "warn-external":
+			h.WarnExternal = b
+		}
+		s.ProtocolHandlers[scheme] = h
+	}
+	return s, nil
+}
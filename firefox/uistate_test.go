@@ -0,0 +1,61 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPrefsUIState(t *testing.T) {
+	data := `user_pref("browser.newtabpage.pinned", "[{\"url\":\"https://example.com/\",\"label\":\"Example\"},null,{\"url\":\"https://www.amazon.com\",\"label\":\"@amazon\",\"searchTopSite\":true}]");
+user_pref("browser.ssb.enabled", true);
+user_pref("browser.taskbar.lists.recent.enabled", true);
+user_pref("browser.taskbar.lists.maxListItemCount", 10);
+user_pref("network.protocol-handler.expose-all", true);
+user_pref("network.protocol-handler.external.mailto", true);
+user_pref("network.protocol-handler.warn-external.mailto", false);
+user_pref("network.protocol-handler.app.zoommtg", "/usr/bin/zoom");
+`
+	prefs, err := DecodePrefs(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := PrefsUIState(prefs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yes, no, zoom := true, false, "/usr/bin/zoom"
+	want := &UIState{
+		PinnedSites: []*PinnedSite{
+			{URL: "https://example.com/", Label: "Example"},
+			nil,
+			{URL: "https://www.amazon.com", Label: "@amazon", SearchTopSite: true},
+		},
+		SSBEnabled: true,
+		Taskbar: TaskbarPrefs{
+			JumpListEnabled:  true,
+			FrequentEnabled:  true,
+			RecentEnabled:    true,
+			TasksEnabled:     true,
+			MaxListItemCount: 10,
+		},
+		ProtocolHandlers: map[string]*ProtocolHandlerPref{
+			"mailto":  {External: &yes, WarnExternal: &no},
+			"zoommtg": {App: &zoom},
+		},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", s, want)
+	}
+
+	prefs["browser.ssb.enabled"].User = "yes"
+	if _, err := PrefsUIState(prefs); err == nil {
+		t.Error("non-bool pref not rejected")
+	}
+}
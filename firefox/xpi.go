@@ -0,0 +1,204 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/andrewarchi/browser/manifest"
+)
+
+// XPI format:
+// https://searchfox.org/mozilla-central/source/security/manager/ssl/AppSignatureVerification.cpp
+//
+// An XPI is a ZIP archive. Signed XPIs contain a JAR manifest,
+// META-INF/manifest.mf, with the digest of every file, and a PKCS#7
+// signature, META-INF/mozilla.rsa, and, for newer signatures, a COSE
+// signature, META-INF/cose.sig, of the manifest.
+
+// XPI is an open Firefox add-on package.
+type XPI struct {
+	Zip *zip.Reader
+
+	closer io.Closer
+}
+
+// OpenXPI opens an .xpi file, such as the Path of an add-on in
+// extensions.json. The file is closed by XPI.Close.
+func OpenXPI(filename string) (*XPI, error) {
+	z, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, fmt.Errorf("firefox: xpi: %w", err)
+	}
+	return &XPI{Zip: &z.Reader, closer: z}, nil
+}
+
+// Close closes the file opened by OpenXPI.
+func (x *XPI) Close() error {
+	if x.closer == nil {
+		return nil
+	}
+	return x.closer.Close()
+}
+
+// Manifest reads the manifest.json in the package.
+func (x *XPI) Manifest() (*manifest.Manifest, error) {
+	return manifest.ReadZip(x.Zip)
+}
+
+// Files returns the names of the files in the package, sorted.
+func (x *XPI) Files() []string {
+	return manifest.ZipFiles(x.Zip)
+}
+
+// CompareDir compares the files in the package to an unpacked add-on
+// directory.
+func (x *XPI) CompareDir(dir string) (*manifest.FileDiff, error) {
+	return manifest.CompareDir(x.Zip, dir)
+}
+
+// IsSigned reports whether the package contains a signature.
+func (x *XPI) IsSigned() bool {
+	return x.file("META-INF/mozilla.rsa") != nil || x.file("META-INF/cose.sig") != nil
+}
+
+// VerifyDigests checks that every file in the package is listed in
+// META-INF/manifest.mf with a matching SHA-1 or SHA-256 digest. The
+// signatures of the manifest itself are not verified, so this detects
+// files that were modified after signing, but not a forged manifest.
+func (x *XPI) VerifyDigests() error {
+	mf := x.file("META-INF/manifest.mf")
+	if mf == nil {
+		return errors.New("firefox: xpi: no META-INF/manifest.mf")
+	}
+	b, err := readZipFile(mf)
+	if err != nil {
+		return fmt.Errorf("firefox: xpi: %w", err)
+	}
+	digests, err := parseJARManifest(b)
+	if err != nil {
+		return fmt.Errorf("firefox: xpi: manifest.mf: %w", err)
+	}
+	for _, f := range x.Zip.File {
+		if strings.HasSuffix(f.Name, "/") || strings.HasPrefix(f.Name, "META-INF/") {
+			continue
+		}
+		d, ok := digests[f.Name]
+		if !ok {
+			return fmt.Errorf("firefox: xpi: %s not in manifest.mf", f.Name)
+		}
+		delete(digests, f.Name)
+		b, err := readZipFile(f)
+		if err != nil {
+			return fmt.Errorf("firefox: xpi: %w", err)
+		}
+		if d.sha256 != nil {
+			sum := sha256.Sum256(b)
+			if !bytes.Equal(sum[:], d.sha256) {
+				return fmt.Errorf("firefox: xpi: %s: SHA-256 digest mismatch", f.Name)
+			}
+		} else {
+			sum := sha1.Sum(b)
+			if !bytes.Equal(sum[:], d.sha1) {
+				return fmt.Errorf("firefox: xpi: %s: SHA-1 digest mismatch", f.Name)
+			}
+		}
+	}
+	for name := range digests {
+		return fmt.Errorf("firefox: xpi: %s in manifest.mf, but not in package", name)
+	}
+	return nil
+}
+
+func (x *XPI) file(name string) *zip.File {
+	for _, f := range x.Zip.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+type jarDigest struct {
+	sha1, sha256 []byte
+}
+
+// parseJARManifest parses the per-file sections of a JAR manifest,
+// which are separated by blank lines and consist of "Name:" and
+// digest attributes. Lines longer than 72 bytes are continued on lines
+// starting with a space.
+func parseJARManifest(b []byte) (map[string]jarDigest, error) {
+	b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+	b = bytes.ReplaceAll(b, []byte("\n "), nil)
+	digests := make(map[string]jarDigest)
+	var name string
+	var d jarDigest
+	flush := func() error {
+		if name == "" {
+			return nil
+		}
+		if d.sha1 == nil && d.sha256 == nil {
+			return fmt.Errorf("no digest for %s", name)
+		}
+		digests[name] = d
+		name, d = "", jarDigest{}
+		return nil
+	}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := s.Text()
+		if line == "" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		i := strings.Index(line, ": ")
+		if i == -1 {
+			return nil, fmt.Errorf("malformed line %q", line)
+		}
+		key, value := line[:i], line[i+2:]
+		var err error
+		switch key {
+		case "Name":
+			name = value
+		case "SHA1-Digest":
+			d.sha1, err = base64.StdEncoding.DecodeString(value)
+		case "SHA256-Digest":
+			d.sha256, err = base64.StdEncoding.DecodeString(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return digests, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
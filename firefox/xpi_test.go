@@ -0,0 +1,130 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeXPI(t *testing.T, filename string, files map[string]string, mf string) {
+	t.Helper()
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	add := func(name, data string) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"manifest.json", "background.js"} {
+		add(name, files[name])
+	}
+	if mf != "" {
+		add("META-INF/manifest.mf", mf)
+		add("META-INF/mozilla.rsa", "")
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestXPI(t *testing.T) {
+	files := map[string]string{
+		"manifest.json": `{"manifest_version": 2, "name": "Example", "version": "1.0",
+			"browser_specific_settings": {"gecko": {"id": "example@example.com"}}}`,
+		"background.js": "browser.runtime.onInstalled.addListener(() => {});\n",
+	}
+	sha1Sum := sha1.Sum([]byte(files["manifest.json"]))
+	sha256Sum := sha256.Sum256([]byte(files["background.js"]))
+	mf := fmt.Sprintf("Manifest-Version: 1.0\r\n\r\n"+
+		"Name: manifest.json\r\nSHA1-Digest: %s\r\n\r\n"+
+		"Name: background.js\r\nSHA1-Digest: AAAA\r\nSHA256-Digest: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sha1Sum[:]),
+		base64.StdEncoding.EncodeToString(sha256Sum[:]))
+
+	dir := t.TempDir()
+	signed := filepath.Join(dir, "signed.xpi")
+	writeXPI(t, signed, files, mf)
+	x, err := OpenXPI(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.Close()
+	if !x.IsSigned() {
+		t.Error("expected signed")
+	}
+	if err := x.VerifyDigests(); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+	m, err := x.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := m.GeckoID(); id != "example@example.com" {
+		t.Errorf("got ID %q", id)
+	}
+	want := []string{"META-INF/manifest.mf", "META-INF/mozilla.rsa", "background.js", "manifest.json"}
+	if got := x.Files(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got files %q, want %q", got, want)
+	}
+
+	tampered := filepath.Join(dir, "tampered.xpi")
+	files["background.js"] = "fetch('https://tracker.example/');\n"
+	writeXPI(t, tampered, files, mf)
+	x2, err := OpenXPI(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x2.Close()
+	if err := x2.VerifyDigests(); err == nil || !strings.Contains(err.Error(), "background.js: SHA-256") {
+		t.Errorf("got error %v, want digest mismatch", err)
+	}
+
+	unsigned := filepath.Join(dir, "unsigned.xpi")
+	writeXPI(t, unsigned, files, "")
+	x3, err := OpenXPI(unsigned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x3.Close()
+	if x3.IsSigned() {
+		t.Error("expected unsigned")
+	}
+	if err := x3.VerifyDigests(); err == nil {
+		t.Error("expected error for missing manifest.mf")
+	}
+}
+
+func TestParseJARManifestContinuation(t *testing.T) {
+	sum := sha1.Sum(nil)
+	name := "content/" + strings.Repeat("x", 70) + ".js"
+	line := "Name: " + name
+	mf := line[:72] + "\n " + line[72:] + "\nSHA1-Digest: " + base64.StdEncoding.EncodeToString(sum[:]) + "\n"
+	digests, err := parseJARManifest([]byte(mf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := digests[name]; !ok || len(digests) != 1 {
+		t.Errorf("got %v, want entry for %q", digests, name)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+// xulstore.json format:
+// https://searchfox.org/mozilla-central/source/toolkit/components/xulstore/XULStore.jsm
+// https://searchfox.org/mozilla-central/source/xpfe/appshell/AppWindow.cpp
+//
+// XULStore persists attributes of elements in chrome documents, keyed
+// by document URL, element ID, and attribute name, with all values as
+// strings:
+//
+//	{"chrome://browser/content/browser.xhtml": {
+//	  "main-window": {"screenX": "0", "screenY": "27", "width": "1280", "height": "1414", "sizemode": "normal"},
+//	  "sidebar-box": {"sidebarcommand": "viewBookmarksSidebar", "width": "283", "style": "width: 283px;"},
+//	  "PersonalToolbar": {"collapsed": "false"}}}
+
+// BrowserDocumentURL is the document of the main browser window, which
+// was browser.xul before Firefox 69.
+const BrowserDocumentURL = "chrome://browser/content/browser.xhtml"
+
+// XULStore is the persisted state of windows and UI elements in
+// xulstore.json.
+type XULStore struct {
+	// Documents holds every attribute, keyed by document URL, element
+	// ID, and attribute name.
+	Documents map[string]map[string]map[string]string
+	Windows   []XULWindow  // ordered by document and ID
+	Toolbars  []XULToolbar // ordered by document and ID
+	Sidebars  []XULSidebar // ordered by document
+}
+
+// XULWindow is the persisted geometry of a window. Fields are zero when
+// not persisted.
+type XULWindow struct {
+	Document string
+	ID       string // e.g. "main-window"
+	ScreenX  int
+	ScreenY  int
+	Width    int
+	Height   int
+	SizeMode string // "normal", "maximized", "fullscreen", or "minimized"
+}
+
+// XULToolbar is the persisted visibility of a toolbar.
+type XULToolbar struct {
+	Document  string
+	ID        string // e.g. "PersonalToolbar" or "toolbar-menubar"
+	Collapsed bool   // hidden, for the bookmarks toolbar
+	AutoHide  bool   // shown only when Alt is pressed, for the menu bar
+}
+
+// XULSidebar is the persisted state of the sidebar in a window.
+type XULSidebar struct {
+	Document    string
+	Command     string // selected sidebar, e.g. "viewBookmarksSidebar" or "viewHistorySidebar"
+	Width       int
+	PositionEnd bool   // shown on the right
+	Title       string // e.g. "Bookmarks"
+}
+
+// ParseXULStore parses xulstore.json in a Firefox profile.
+func ParseXULStore(filename string) (*XULStore, error) {
+	var docs map[string]map[string]map[string]string
+	if err := jsonutil.DecodeFile(filename, &docs); err != nil {
+		return nil, err
+	}
+	s := &XULStore{Documents: docs}
+	urls := make([]string, 0, len(docs))
+	for doc := range docs {
+		urls = append(urls, doc)
+	}
+	sort.Strings(urls)
+	for _, doc := range urls {
+		elems := docs[doc]
+		ids := make([]string, 0, len(elems))
+		for id := range elems {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			if err := s.addElement(doc, id, elems[id]); err != nil {
+				return nil, fmt.Errorf("firefox: xulstore: %s#%s: %w", doc, id, err)
+			}
+		}
+		if sidebar, ok := elems["sidebar-box"]; ok {
+			sb := XULSidebar{
+				Document:    doc,
+				Command:     sidebar["sidebarcommand"],
+				PositionEnd: sidebar["positionend"] == "true",
+				Title:       elems["sidebar-title"]["value"],
+			}
+			if w, ok := sidebar["width"]; ok {
+				n, err := strconv.Atoi(w)
+				if err != nil {
+					return nil, fmt.Errorf("firefox: xulstore: %s#sidebar-box: width: %w", doc, err)
+				}
+				sb.Width = n
+			}
+			s.Sidebars = append(s.Sidebars, sb)
+		}
+	}
+	return s, nil
+}
+
+// addElement adds an element that is a window or a toolbar.
+func (s *XULStore) addElement(doc, id string, attrs map[string]string) error {
+	_, sizeMode := attrs["sizemode"]
+	_, screenX := attrs["screenX"]
+	if sizeMode || screenX {
+		w := XULWindow{Document: doc, ID: id, SizeMode: attrs["sizemode"]}
+		for _, f := range []struct {
+			attr string
+			v    *int
+		}{{"screenX", &w.ScreenX}, {"screenY", &w.ScreenY}, {"width", &w.Width}, {"height", &w.Height}} {
+			v, ok := attrs[f.attr]
+			if !ok {
+				continue
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", f.attr, err)
+			}
+			*f.v = n
+		}
+		s.Windows = append(s.Windows, w)
+	}
+	collapsed, hasCollapsed := attrs["collapsed"]
+	autoHide, hasAutoHide := attrs["autohide"]
+	if (hasCollapsed || hasAutoHide) && id != "sidebar-box" {
+		s.Toolbars = append(s.Toolbars, XULToolbar{
+			Document:  doc,
+			ID:        id,
+			Collapsed: collapsed == "true",
+			AutoHide:  autoHide == "true",
+		})
+	}
+	return nil
+}
+
+// MainWindow returns the persisted geometry of the main browser window,
+// or nil if none is persisted.
+func (s *XULStore) MainWindow() *XULWindow {
+	for i, w := range s.Windows {
+		if (w.Document == BrowserDocumentURL || w.Document == "chrome://browser/content/browser.xul") && w.ID == "main-window" {
+			return &s.Windows[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package firefox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseXULStore(t *testing.T) {
+	s, err := ParseXULStore("testdata/corpus/xulstore.json/firefox-85.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const places = "chrome://browser/content/places/places.xhtml"
+	wantWindows := []XULWindow{
+		{BrowserDocumentURL, "main-window", 4, 27, 1280, 1414, "maximized"},
+		{places, "places", 100, 100, 800, 500, "normal"},
+	}
+	if !reflect.DeepEqual(s.Windows, wantWindows) {
+		t.Errorf("got windows:\n%+v\nwant:\n%+v", s.Windows, wantWindows)
+	}
+	wantToolbars := []XULToolbar{
+		{BrowserDocumentURL, "PersonalToolbar", false, false},
+		{BrowserDocumentURL, "toolbar-menubar", false, true},
+	}
+	if !reflect.DeepEqual(s.Toolbars, wantToolbars) {
+		t.Errorf("got toolbars:\n%+v\nwant:\n%+v", s.Toolbars, wantToolbars)
+	}
+	wantSidebars := []XULSidebar{{BrowserDocumentURL, "viewBookmarksSidebar", 283, false, "Bookmarks"}}
+	if !reflect.DeepEqual(s.Sidebars, wantSidebars) {
+		t.Errorf("got sidebars:\n%+v\nwant:\n%+v", s.Sidebars, wantSidebars)
+	}
+	if w := s.MainWindow(); w == nil || w.Width != 1280 {
+		t.Errorf("got main window %+v", w)
+	}
+	if got := s.Documents[places]["placesContentTitle"]["ordinal"]; got != "1" {
+		t.Errorf("got ordinal %q, want \"1\"", got)
+	}
+}
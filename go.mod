@@ -1,13 +1,24 @@
 module github.com/andrewarchi/browser
 
-go 1.15
+go 1.20
 
 require (
 	github.com/PuerkitoBio/goquery v1.6.1
 	github.com/andrewarchi/archive v0.0.0-20210205094453-9a6f6fa5022b
-	github.com/andybalholm/cascadia v1.2.0 // indirect
+	github.com/golang/snappy v0.0.0-20180518054509-2e65f85255db
+	github.com/klauspost/pgzip v1.2.5
+	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/pierrec/lz4/v4 v4.1.3
-	github.com/smartystreets/goconvey v1.6.4 // indirect
+	github.com/syndtr/goleveldb v1.0.0
 	golang.org/x/net v0.0.0-20210119194325-5f4716e94777
+	google.golang.org/protobuf v1.25.0
 	gopkg.in/ini.v1 v1.62.0
+	howett.net/plist v0.0.0-20201203080718-1454fab16a06
+)
+
+require (
+	github.com/andybalholm/cascadia v1.2.0 // indirect
+	github.com/klauspost/compress v1.11.7 // indirect
+	github.com/smartystreets/goconvey v1.6.4 // indirect
+	github.com/ulikunitz/xz v0.5.10 // indirect
 )
@@ -0,0 +1,134 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package history
+
+import (
+	"sort"
+	"time"
+)
+
+// Dedup collapses duplicate visits read from several sources, such as
+// the same visit in a Chrome profile, Takeout, and a History Trends
+// Unlimited export. Visits are duplicates when they have the same URL
+// and times that are equal at the coarser precision of the two. Of
+// duplicates, the visit with the finer precision is kept, then the one
+// with the higher trust, then the earlier one in visits, and its empty
+// title and device are filled from the others. The result is ordered
+// by time, then URL, with ties in the order of visits.
+func Dedup(visits []Visit) []Visit {
+	sorted := make([]indexed, len(visits))
+	for i, v := range visits {
+		sorted[i] = indexed{v, i}
+	}
+	kept := dedupIndexed(sorted)
+	deduped := make([]Visit, len(kept))
+	for i, v := range kept {
+		deduped[i] = v.Visit
+	}
+	return deduped
+}
+
+// indexed is a visit with its index in the input, which orders visits
+// with equal times.
+type indexed struct {
+	Visit
+	index int
+}
+
+// dedupIndexed collapses duplicate visits like Dedup and returns the
+// kept visits ordered by time, URL, and index. The order of sorted is
+// changed.
+func dedupIndexed(sorted []indexed) []indexed {
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].URL != sorted[j].URL {
+			return sorted[i].URL < sorted[j].URL
+		}
+		if !sorted[i].Time.Equal(sorted[j].Time) {
+			return sorted[i].Time.Before(sorted[j].Time)
+		}
+		return sorted[i].index < sorted[j].index
+	})
+
+	kept := sorted[:0]
+	group := 0 // start of the visits to the current URL in kept
+	for _, v := range sorted {
+		if len(kept) == group || kept[group].URL != v.URL {
+			group = len(kept)
+			kept = append(kept, v)
+			continue
+		}
+		dup := -1
+		for i := len(kept) - 1; i >= group && v.Time.Sub(kept[i].Time) < maxPrecision; i-- {
+			if sameTime(&kept[i].Visit, &v.Visit) {
+				dup = i
+				break
+			}
+		}
+		if dup == -1 {
+			kept = append(kept, v)
+			continue
+		}
+		k := &kept[dup]
+		index := k.index
+		if v.index < index {
+			index = v.index
+		}
+		if preferVisit(&v.Visit, &k.Visit, v.index < k.index) {
+			v.Visit, k.Visit = k.Visit, v.Visit
+		}
+		k.index = index
+		if k.Title == "" {
+			k.Title = v.Title
+		}
+		if k.Device == "" {
+			k.Device = v.Device
+		}
+	}
+
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].before(&kept[j])
+	})
+	return kept
+}
+
+// before reports whether v is ordered before w in deduplicated output.
+func (v *indexed) before(w *indexed) bool {
+	if !v.Time.Equal(w.Time) || v.URL != w.URL {
+		return visitBefore(&v.Visit, &w.Visit)
+	}
+	return v.index < w.index
+}
+
+// maxPrecision is the duration of the coarsest precision.
+const maxPrecision = 24 * time.Hour
+
+// sameTime reports whether the times of two visits are equal at the
+// coarser precision of the two. Times of unknown precision must be
+// equal exactly.
+func sameTime(a, b *Visit) bool {
+	p := a.Precision.Duration()
+	if d := b.Precision.Duration(); d > p {
+		p = d
+	}
+	if p == 0 {
+		return a.Time.Equal(b.Time)
+	}
+	return a.Time.Truncate(p).Equal(b.Time.Truncate(p))
+}
+
+// preferVisit reports whether visit a is preferred over its duplicate
+// b. first is whether a is earlier in the input.
+func preferVisit(a, b *Visit, first bool) bool {
+	pa, pb := a.Precision.Duration(), b.Precision.Duration()
+	if pa != pb {
+		return pa != 0 && (pb == 0 || pa < pb)
+	}
+	if a.Trust != b.Trust {
+		return a.Trust > b.Trust
+	}
+	return first
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDedup(t *testing.T) {
+	at := time.Date(2021, 2, 18, 1, 2, 3, 456789000, time.UTC)
+	visits := []Visit{
+		{URL: "https://example.com/", Time: at.Truncate(time.Millisecond), Title: "Example", Source: SourceHistoryTrends, Precision: PrecisionMilli, Trust: TrustExport},
+		{URL: "https://example.com/", Time: at, Source: SourceChrome, Precision: PrecisionMicro, Trust: TrustBrowser},
+		{URL: "https://example.com/", Time: at, Source: SourceTakeout, Device: "laptop", Precision: PrecisionMicro, Trust: TrustExport},
+		{URL: "https://example.com/", Time: at.Add(time.Millisecond), Source: SourceChrome, Precision: PrecisionMicro, Trust: TrustBrowser},
+		{URL: "https://example.org/", Time: at.Add(-time.Hour), Source: SourceChrome, Precision: PrecisionMicro, Trust: TrustBrowser},
+		{URL: "https://example.org/", Time: at.Add(-time.Hour).Truncate(24 * time.Hour), Source: "bookmarks", Precision: PrecisionDay},
+		{URL: "https://example.net/", Time: at},
+		{URL: "https://example.net/", Time: at.Add(time.Nanosecond)},
+	}
+	want := []Visit{
+		{URL: "https://example.org/", Time: at.Add(-time.Hour), Source: SourceChrome, Precision: PrecisionMicro, Trust: TrustBrowser},
+		{URL: "https://example.com/", Time: at, Title: "Example", Source: SourceChrome, Device: "laptop", Precision: PrecisionMicro, Trust: TrustBrowser},
+		{URL: "https://example.net/", Time: at},
+		{URL: "https://example.net/", Time: at.Add(time.Nanosecond)},
+		{URL: "https://example.com/", Time: at.Add(time.Millisecond), Source: SourceChrome, Precision: PrecisionMicro, Trust: TrustBrowser},
+	}
+	if got := Dedup(visits); !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
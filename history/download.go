@@ -0,0 +1,166 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package history
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/andrewarchi/browser/firefox"
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+// Download is a file download in browsing history, independent of the
+// browser that it was read from.
+type Download struct {
+	URL           string // final URL, after redirects
+	Referrer      string
+	TargetPath    string // local path of the downloaded file
+	MIMEType      string
+	StartTime     time.Time
+	EndTime       time.Time
+	ReceivedBytes int64
+	TotalBytes    int64 // -1 when unknown
+	State         DownloadState
+	Source        string
+	File          *DownloadFile // set by enrichment
+}
+
+// DownloadFile is the state of a downloaded file on disk, relative to
+// when it was checked.
+type DownloadFile struct {
+	Exists    bool
+	Size      int64
+	ModTime   time.Time
+	SHA256    jsonutil.Hex // nil when not hashed
+	CheckTime time.Time
+}
+
+// DownloadState is the state of a download.
+type DownloadState uint8
+
+// Values for DownloadState:
+const (
+	DownloadUnknown DownloadState = iota
+	DownloadInProgress
+	DownloadComplete
+	DownloadCancelled
+	DownloadInterrupted
+)
+
+// FileCheck enriches downloads with the state of their target files.
+type FileCheck struct {
+	Hash    bool  // compute SHA-256 of existing files
+	MaxSize int64 // skip hashing files larger than this, when positive
+}
+
+// Check stats the target file of a download and optionally hashes it,
+// setting d.File. A missing file is not an error.
+func (fc FileCheck) Check(d *Download) error {
+	f := &DownloadFile{CheckTime: time.Now()}
+	d.File = f
+	if d.TargetPath == "" {
+		return nil
+	}
+	fi, err := os.Stat(d.TargetPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("history: download target is a directory: %q", d.TargetPath)
+	}
+	f.Exists = true
+	f.Size = fi.Size()
+	f.ModTime = fi.ModTime()
+	if fc.Hash && (fc.MaxSize <= 0 || f.Size <= fc.MaxSize) {
+		f.SHA256, err = hashFile(d.TargetPath)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckAll checks the target files of all downloads.
+func (fc FileCheck) CheckAll(downloads []Download) error {
+	for i := range downloads {
+		if err := fc.Check(&downloads[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hashFile(filename string) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func (s DownloadState) String() string {
+	switch s {
+	case DownloadUnknown:
+		return "unknown"
+	case DownloadInProgress:
+		return "in_progress"
+	case DownloadComplete:
+		return "complete"
+	case DownloadCancelled:
+		return "cancelled"
+	case DownloadInterrupted:
+		return "interrupted"
+	default:
+		return fmt.Sprintf("download_state(%d)", uint8(s))
+	}
+}
+
+// FromFirefoxDownloads converts downloads read from a Firefox profile.
+func FromFirefoxDownloads(downloads []firefox.Download) []Download {
+	converted := make([]Download, len(downloads))
+	for i, d := range downloads {
+		var state DownloadState
+		switch d.State {
+		case firefox.DownloadDownloading, firefox.DownloadPaused,
+			firefox.DownloadQueued, firefox.DownloadScanning:
+			state = DownloadInProgress
+		case firefox.DownloadFinished:
+			state = DownloadComplete
+		case firefox.DownloadCanceled:
+			state = DownloadCancelled
+		case firefox.DownloadFailed, firefox.DownloadBlockedParental,
+			firefox.DownloadBlockedPolicy, firefox.DownloadDirty:
+			state = DownloadInterrupted
+		}
+		converted[i] = Download{
+			URL:           d.SourceURL,
+			Referrer:      d.Referrer,
+			TargetPath:    d.TargetPath,
+			MIMEType:      d.MIMEType,
+			StartTime:     d.StartTime,
+			EndTime:       d.EndTime,
+			ReceivedBytes: d.CurrBytes,
+			TotalBytes:    d.MaxBytes,
+			State:         state,
+			Source:        SourceFirefox,
+		}
+	}
+	return converted
+}
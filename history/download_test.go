@@ -0,0 +1,72 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package history
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileCheck(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		filename := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(filename, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	original := sha256.Sum256([]byte("release 1.0\n"))
+	downloads := []Download{
+		{URL: "https://example.com/missing.zip", TargetPath: filepath.Join(dir, "missing.zip"), TotalBytes: 10},
+		{URL: "https://example.com/same.txt", TargetPath: write("same.txt", "release 1.0\n"), TotalBytes: 12},
+		{URL: "https://example.com/truncated.txt", TargetPath: write("truncated.txt", "rel"), TotalBytes: 12},
+		{URL: "https://example.com/modified.txt", TargetPath: write("modified.txt", "release 1.1\n"), TotalBytes: 12},
+		{URL: "https://example.com/unsaved"},
+	}
+	if err := (FileCheck{Hash: true}).CheckAll(downloads); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range downloads {
+		if d.File == nil || d.File.CheckTime.IsZero() {
+			t.Fatalf("%s: not checked: %+v", d.URL, d.File)
+		}
+	}
+
+	if f := downloads[0].File; f.Exists || f.SHA256 != nil {
+		t.Errorf("missing file: got %+v", f)
+	}
+	if f := downloads[1].File; !f.Exists || f.Size != 12 || !bytes.Equal(f.SHA256, original[:]) || f.ModTime.IsZero() {
+		t.Errorf("unchanged file: got %+v", f)
+	}
+	if f := downloads[2].File; !f.Exists || f.Size != 3 || f.Size == downloads[2].TotalBytes {
+		t.Errorf("truncated file: got size %d, want 3", f.Size)
+	}
+	if f := downloads[3].File; f.Size != 12 || len(f.SHA256) != sha256.Size || bytes.Equal(f.SHA256, original[:]) {
+		t.Errorf("modified file: got hash %x, want a hash other than %x", f.SHA256, original)
+	}
+	if f := downloads[4].File; f.Exists {
+		t.Errorf("download without target: got %+v", f)
+	}
+
+	// Files larger than MaxSize are not hashed.
+	d := Download{TargetPath: downloads[1].TargetPath}
+	if err := (FileCheck{Hash: true, MaxSize: 4}).Check(&d); err != nil {
+		t.Fatal(err)
+	}
+	if !d.File.Exists || d.File.SHA256 != nil {
+		t.Errorf("got %+v, want unhashed file", d.File)
+	}
+
+	d = Download{TargetPath: dir}
+	if err := (FileCheck{}).Check(&d); err == nil {
+		t.Error("no error for a directory")
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package history
+
+// Interner deduplicates strings, so that equal strings share one copy.
+// When decoding many visits, each has its own copy of its URL, title,
+// source, and device, though most URLs are visited many times and there
+// are few sources and devices, so interning them uses several times
+// less memory. The zero value is ready to use.
+type Interner struct {
+	strings map[string]string
+	size    int
+}
+
+// Intern returns the copy of s that is held by the Interner, adding s
+// when it is new.
+func (in *Interner) Intern(s string) string {
+	if s == "" {
+		return ""
+	}
+	if t, ok := in.strings[s]; ok {
+		return t
+	}
+	if in.strings == nil {
+		in.strings = make(map[string]string)
+	}
+	in.strings[s] = s
+	in.size += len(s)
+	return s
+}
+
+// InternVisit interns the strings of a visit.
+func (in *Interner) InternVisit(v *Visit) {
+	v.URL = in.Intern(v.URL)
+	v.Title = in.Intern(v.Title)
+	v.Source = in.Intern(v.Source)
+	v.Device = in.Intern(v.Device)
+}
+
+// Len returns the number of distinct strings held.
+func (in *Interner) Len() int { return len(in.strings) }
+
+// Size returns the total length in bytes of the strings held.
+func (in *Interner) Size() int { return in.size }
+
+// Reset releases the strings held. Strings already returned by Intern
+// remain valid.
+func (in *Interner) Reset() {
+	in.strings = nil
+	in.size = 0
+}
@@ -0,0 +1,257 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package history
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/gob"
+	"errors"
+	"hash/fnv"
+	"io"
+	"os"
+)
+
+// DefaultMaxVisits is the number of visits held in memory by a Merger
+// before spilling, when MaxVisits is zero.
+const DefaultMaxVisits = 1 << 20
+
+// spillPartitions is the number of temporary files that spilled visits
+// are partitioned into by URL. Each is deduplicated in memory, so at
+// most about 1/spillPartitions of the visits are held at once.
+const spillPartitions = 64
+
+// Merger collapses duplicate visits like Dedup, for timelines too large
+// to hold in memory twice. Strings in visits are interned as they are
+// added, so visits to the same URL share one copy of it.
+//
+// When SpillDir is set and more than MaxVisits visits have been added,
+// they are written to temporary files in SpillDir, partitioned by URL,
+// and each partition is deduplicated separately. The result is the
+// same as that of Dedup on all visits in the order added.
+type Merger struct {
+	SpillDir  string // directory for temporary files; visits are only held in memory when empty
+	MaxVisits int    // visits held in memory before spilling; defaults to DefaultMaxVisits
+
+	strings Interner
+	visits  []indexed
+	n       int
+	parts   []*spillFile
+	done    bool
+}
+
+// spillFile is a temporary file of visits in a partition.
+type spillFile struct {
+	f   *os.File
+	w   *bufio.Writer
+	enc *gob.Encoder
+	dec *gob.Decoder
+	cur spillRecord // next visit when merging
+}
+
+// spillRecord is a visit in a spill file.
+type spillRecord struct {
+	Index int
+	Visit Visit
+}
+
+// Add adds a visit.
+func (m *Merger) Add(v Visit) error {
+	if m.done {
+		return errors.New("history: Merger used after Each")
+	}
+	m.strings.InternVisit(&v)
+	m.visits = append(m.visits, indexed{v, m.n})
+	m.n++
+	max := m.MaxVisits
+	if max <= 0 {
+		max = DefaultMaxVisits
+	}
+	if m.SpillDir != "" && len(m.visits) >= max {
+		return m.spill()
+	}
+	return nil
+}
+
+// Len returns the number of visits added.
+func (m *Merger) Len() int { return m.n }
+
+// Each calls fn for each deduplicated visit, ordered by time, then URL,
+// with ties in the order added. The visit is only valid during the call. The
+// Merger cannot be used afterwards and its temporary files are removed.
+func (m *Merger) Each(fn func(v *Visit) error) error {
+	if m.done {
+		return errors.New("history: Merger used after Each")
+	}
+	m.done = true
+	defer m.Close()
+	if m.parts == nil {
+		kept := dedupIndexed(m.visits)
+		m.visits = nil
+		m.strings.Reset()
+		for i := range kept {
+			if err := fn(&kept[i].Visit); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := m.spill(); err != nil {
+		return err
+	}
+	for _, p := range m.parts {
+		if err := p.dedup(); err != nil {
+			return err
+		}
+	}
+	h := make(spillHeap, 0, len(m.parts))
+	for _, p := range m.parts {
+		if err := p.next(); err == io.EOF {
+			continue
+		} else if err != nil {
+			return err
+		}
+		h = append(h, p)
+	}
+	heap.Init(&h)
+	for len(h) != 0 {
+		p := h[0]
+		if err := fn(&p.cur.Visit); err != nil {
+			return err
+		}
+		if err := p.next(); err == io.EOF {
+			heap.Pop(&h)
+		} else if err != nil {
+			return err
+		} else {
+			heap.Fix(&h, 0)
+		}
+	}
+	return nil
+}
+
+// Close removes the temporary files. It is called by Each and is only
+// needed when Each is not called.
+func (m *Merger) Close() error {
+	var first error
+	for _, p := range m.parts {
+		if err := p.f.Close(); err != nil && first == nil {
+			first = err
+		}
+		if err := os.Remove(p.f.Name()); err != nil && first == nil {
+			first = err
+		}
+	}
+	m.parts = nil
+	m.visits = nil
+	m.strings.Reset()
+	m.done = true
+	return first
+}
+
+// spill writes the visits held in memory to the partitions and releases
+// them.
+func (m *Merger) spill() error {
+	if m.parts == nil {
+		m.parts = make([]*spillFile, 0, spillPartitions)
+		for i := 0; i < spillPartitions; i++ {
+			f, err := os.CreateTemp(m.SpillDir, "history-spill-*")
+			if err != nil {
+				return err
+			}
+			w := bufio.NewWriter(f)
+			m.parts = append(m.parts, &spillFile{f: f, w: w, enc: gob.NewEncoder(w)})
+		}
+	}
+	h := fnv.New32a()
+	for i := range m.visits {
+		v := &m.visits[i]
+		h.Reset()
+		io.WriteString(h, v.URL)
+		p := m.parts[h.Sum32()%spillPartitions]
+		if err := p.enc.Encode(&spillRecord{v.index, v.Visit}); err != nil {
+			return err
+		}
+	}
+	m.visits = m.visits[:0]
+	m.strings.Reset()
+	return nil
+}
+
+// dedup deduplicates the visits in the partition and rewrites it
+// ordered by time, then URL.
+func (p *spillFile) dedup() error {
+	if err := p.w.Flush(); err != nil {
+		return err
+	}
+	if _, err := p.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var strings Interner
+	var visits []indexed
+	dec := gob.NewDecoder(bufio.NewReader(p.f))
+	for {
+		var r spillRecord
+		if err := dec.Decode(&r); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		strings.InternVisit(&r.Visit)
+		visits = append(visits, indexed{r.Visit, r.Index})
+	}
+	kept := dedupIndexed(visits)
+
+	if err := p.f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := p.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	p.w.Reset(p.f)
+	enc := gob.NewEncoder(p.w)
+	for i := range kept {
+		if err := enc.Encode(&spillRecord{kept[i].index, kept[i].Visit}); err != nil {
+			return err
+		}
+	}
+	if err := p.w.Flush(); err != nil {
+		return err
+	}
+	if _, err := p.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	p.dec = gob.NewDecoder(bufio.NewReader(p.f))
+	return nil
+}
+
+// next reads the next visit of the deduplicated partition.
+func (p *spillFile) next() error {
+	p.cur = spillRecord{}
+	return p.dec.Decode(&p.cur)
+}
+
+// spillHeap orders deduplicated partitions by their next visit.
+type spillHeap []*spillFile
+
+func (h spillHeap) Len() int { return len(h) }
+func (h spillHeap) Less(i, j int) bool {
+	a, b := &h[i].cur, &h[j].cur
+	if !a.Visit.Time.Equal(b.Visit.Time) || a.Visit.URL != b.Visit.URL {
+		return visitBefore(&a.Visit, &b.Visit)
+	}
+	return a.Index < b.Index
+}
+func (h spillHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *spillHeap) Push(x interface{}) { *h = append(*h, x.(*spillFile)) }
+func (h *spillHeap) Pop() interface{} {
+	old := *h
+	p := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return p
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package history
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// timeline generates n visits to urls URLs, with each URL in a separate
+// allocation like decoded visits, and with a duplicate of every third
+// visit from a less precise source.
+func timeline(n, urls int) []Visit {
+	at := time.Date(2021, 2, 18, 0, 0, 0, 0, time.UTC)
+	visits := make([]Visit, 0, n)
+	for i := 0; len(visits) < n; i++ {
+		v := Visit{
+			URL:       fmt.Sprintf("https://www.example.com/articles/2021/02/%d/a-page-title-in-the-path?utm_source=feed&id=%d", i*7919%urls, i%urls),
+			Title:     fmt.Sprintf("Page %d", i%urls),
+			Time:      at.Add(time.Duration(i) * 1234567 * time.Microsecond),
+			Source:    SourceChrome,
+			Precision: PrecisionMicro,
+			Trust:     TrustBrowser,
+		}
+		visits = append(visits, v)
+		if i%3 == 0 && len(visits) < n {
+			v.Time = v.Time.Truncate(time.Millisecond)
+			v.Title = ""
+			v.Source = SourceHistoryTrends
+			v.Precision = PrecisionMilli
+			v.Trust = TrustExport
+			visits = append(visits, v)
+		}
+	}
+	return visits
+}
+
+func mergeVisits(m *Merger, visits []Visit) ([]Visit, error) {
+	for _, v := range visits {
+		if err := m.Add(v); err != nil {
+			return nil, err
+		}
+	}
+	var merged []Visit
+	err := m.Each(func(v *Visit) error {
+		merged = append(merged, *v)
+		return nil
+	})
+	return merged, err
+}
+
+func TestMerger(t *testing.T) {
+	visits := timeline(5000, 300)
+	// Equal times across URLs exercise the ordering of ties.
+	visits = append(visits, visits[10], visits[20], visits[10])
+	visits[len(visits)-2].URL = "https://example.org/"
+	want := Dedup(visits)
+
+	for _, m := range []*Merger{
+		{},
+		{SpillDir: t.TempDir(), MaxVisits: 700},
+	} {
+		got, err := mergeVisits(m, visits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Merger{MaxVisits: %d}: got %d visits, want %d", m.MaxVisits, len(got), len(want))
+		}
+		if m.SpillDir != "" {
+			entries, err := os.ReadDir(m.SpillDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("%d temporary files not removed", len(entries))
+			}
+		}
+	}
+}
+
+func TestInterner(t *testing.T) {
+	var in Interner
+	a := in.Intern(string([]byte("https://example.com/")))
+	b := in.Intern(string([]byte("https://example.com/")))
+	if a != b || in.Len() != 1 || in.Size() != len(a) {
+		t.Errorf("Len = %d, Size = %d", in.Len(), in.Size())
+	}
+	if in.Intern("") != "" || in.Len() != 1 {
+		t.Errorf("empty string interned")
+	}
+}
+
+// The benchmarks decode a timeline, like merging archives, and report
+// the live heap once all visits are held, before deduplicating.
+
+func BenchmarkDedup(b *testing.B) {
+	data := encodeTimeline(b, timeline(200000, 5000))
+	base := liveHeap()
+	b.ReportAllocs()
+	b.ResetTimer()
+	var heap uint64
+	for i := 0; i < b.N; i++ {
+		var visits []Visit
+		decodeTimeline(b, data, func(v *Visit) error {
+			visits = append(visits, *v)
+			return nil
+		})
+		heap += liveHeap() - base
+		Dedup(visits)
+	}
+	b.ReportMetric(float64(heap)/float64(b.N)/(1<<20), "heap-MB/op")
+}
+
+func BenchmarkMerger(b *testing.B) {
+	benchmarkMerger(b, "", 0)
+}
+
+func BenchmarkMergerSpill(b *testing.B) {
+	benchmarkMerger(b, b.TempDir(), 20000)
+}
+
+func benchmarkMerger(b *testing.B, spillDir string, maxVisits int) {
+	data := encodeTimeline(b, timeline(200000, 5000))
+	base := liveHeap()
+	b.ReportAllocs()
+	b.ResetTimer()
+	var heap uint64
+	for i := 0; i < b.N; i++ {
+		m := &Merger{SpillDir: spillDir, MaxVisits: maxVisits}
+		decodeTimeline(b, data, func(v *Visit) error {
+			return m.Add(*v)
+		})
+		heap += liveHeap() - base
+		if err := m.Each(func(v *Visit) error { return nil }); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(heap)/float64(b.N)/(1<<20), "heap-MB/op")
+}
+
+func encodeTimeline(b *testing.B, visits []Visit) []byte {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for i := range visits {
+		if err := enc.EncodeVisit(&visits[i]); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decodeTimeline(b *testing.B, data []byte, fn func(v *Visit) error) {
+	d := NewDecoder(bytes.NewReader(data))
+	for {
+		r, err := d.Decode()
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			b.Fatal(err)
+		}
+		if err := fn(r.Visit); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func liveHeap() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package history
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Order is the order that exporters and reports write visits and
+// downloads in.
+type Order uint8
+
+// Values for Order:
+const (
+	// OrderTime orders records by time, then URL, with ties in input
+	// order, so that output does not depend on the order that profiles,
+	// files, or maps were read in and is diffable across runs. It is the
+	// default.
+	OrderTime Order = iota
+	// OrderInput keeps records in the order that they were read.
+	OrderInput
+)
+
+// ParseOrder parses the name of an Order, as returned by its String
+// method.
+func ParseOrder(name string) (Order, error) {
+	for o := OrderTime; o <= OrderInput; o++ {
+		if o.String() == name {
+			return o, nil
+		}
+	}
+	return 0, fmt.Errorf("history: unknown order %q", name)
+}
+
+// SortVisits sorts visits by time, then URL, with ties in their
+// original order.
+func SortVisits(visits []Visit) {
+	sort.SliceStable(visits, func(i, j int) bool {
+		return visitBefore(&visits[i], &visits[j])
+	})
+}
+
+// SortDownloads sorts downloads by start time, then URL, with ties in
+// their original order.
+func SortDownloads(downloads []Download) {
+	sort.SliceStable(downloads, func(i, j int) bool {
+		a, b := &downloads[i], &downloads[j]
+		if !a.StartTime.Equal(b.StartTime) {
+			return a.StartTime.Before(b.StartTime)
+		}
+		return a.URL < b.URL
+	})
+}
+
+// visitBefore reports whether v is before w by time, then URL.
+func visitBefore(v, w *Visit) bool {
+	if !v.Time.Equal(w.Time) {
+		return v.Time.Before(w.Time)
+	}
+	return v.URL < w.URL
+}
+
+func (o Order) String() string {
+	switch o {
+	case OrderTime:
+		return "time"
+	case OrderInput:
+		return "input"
+	default:
+		return fmt.Sprintf("order(%d)", uint8(o))
+	}
+}
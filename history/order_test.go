@@ -0,0 +1,61 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSortVisits(t *testing.T) {
+	at := time.Date(2021, 2, 18, 1, 2, 3, 0, time.UTC)
+	visits := []Visit{
+		{URL: "https://example.org/", Time: at, Source: SourceChrome},
+		{URL: "https://example.com/", Time: at.Add(time.Second)},
+		{URL: "https://example.net/", Time: at},
+		{URL: "https://example.org/", Time: at, Source: SourceFirefox},
+		{URL: "https://example.com/", Time: at.Add(-time.Second)},
+	}
+	want := []Visit{
+		{URL: "https://example.com/", Time: at.Add(-time.Second)},
+		{URL: "https://example.net/", Time: at},
+		{URL: "https://example.org/", Time: at, Source: SourceChrome},
+		{URL: "https://example.org/", Time: at, Source: SourceFirefox},
+		{URL: "https://example.com/", Time: at.Add(time.Second)},
+	}
+	SortVisits(visits)
+	if !reflect.DeepEqual(visits, want) {
+		t.Errorf("got:\n%v\nwant:\n%v", visits, want)
+	}
+
+	downloads := []Download{
+		{URL: "https://example.org/b.zip", StartTime: at},
+		{URL: "https://example.org/a.zip", StartTime: at},
+		{URL: "https://example.org/c.zip", StartTime: at.Add(-time.Second)},
+	}
+	SortDownloads(downloads)
+	var urls []string
+	for _, d := range downloads {
+		urls = append(urls, d.URL)
+	}
+	wantURLs := []string{"https://example.org/c.zip", "https://example.org/a.zip", "https://example.org/b.zip"}
+	if !reflect.DeepEqual(urls, wantURLs) {
+		t.Errorf("got downloads:\n%q\nwant:\n%q", urls, wantURLs)
+	}
+}
+
+func TestParseOrder(t *testing.T) {
+	for _, o := range []Order{OrderTime, OrderInput} {
+		if got, err := ParseOrder(o.String()); err != nil || got != o {
+			t.Errorf("ParseOrder(%q) = %v, %v", o.String(), got, err)
+		}
+	}
+	if _, err := ParseOrder("url"); err == nil {
+		t.Error("no error for an unknown order")
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package history
+
+import (
+	"sort"
+	"time"
+
+	"github.com/andrewarchi/browser/chrome"
+)
+
+// redirectWindow is the longest time between a visit and a redirect
+// from it, when the redirect is not linked to the visit by ID. Client
+// redirects, by a script or a meta refresh, usually follow the page
+// within seconds.
+const redirectWindow = 30 * time.Second
+
+// CollapseRedirects collapses redirect chains into single logical
+// visits, so that a click through a link shortener or a sign-in flow
+// counts once.
+//
+// A visit with a server or client redirect qualifier continues the
+// chain of its referring visit, which is found by FromVisit among the
+// visits from the same source and device. Visits without IDs, such as
+// those in Takeout, continue the chain of the previous visit from the
+// same source and device within a short window: a server redirect,
+// when that visit does not end a chain, and a client redirect, when it
+// does.
+//
+// A chain becomes a visit to its last URL, with its title, at the time
+// of the first visit, with the transition of the first visit and the
+// end of the chain. The result is ordered by time, with ties in the
+// order of visits.
+func CollapseRedirects(visits []Visit) []Visit {
+	order := make([]int, len(visits))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return visits[order[i]].Time.Before(visits[order[j]].Time)
+	})
+	rank := make([]int, len(visits))
+	byID := make(map[visitID]int)
+	for r, i := range order {
+		rank[i] = r
+		if v := &visits[i]; v.ID != 0 {
+			byID[visitID{v.Source, v.Device, v.ID}] = i
+		}
+	}
+
+	// Chains are resolved in order, so the root of a referring visit,
+	// which must be earlier, is already known.
+	root := make([]int, len(visits))
+	dest := make(map[int]int) // key: root; value: last visit in the chain
+	prev := make(map[sourceDevice]int)
+	for _, i := range order {
+		v := &visits[i]
+		root[i] = i
+		if p := redirectParent(visits, v, byID, prev); p != -1 && rank[p] < rank[i] {
+			root[i] = root[p]
+		}
+		dest[root[i]] = i
+		prev[sourceDevice{v.Source, v.Device}] = i
+	}
+
+	collapsed := make([]Visit, 0, len(dest))
+	for _, i := range order {
+		if root[i] != i {
+			continue
+		}
+		first, last := &visits[i], &visits[dest[i]]
+		v := *last
+		v.Time = first.Time
+		v.Transition = first.Transition&^chrome.TransitionChainEnd | last.Transition&chrome.TransitionChainEnd
+		v.Device = first.Device
+		v.ID, v.FromVisit = first.ID, first.FromVisit
+		if v.Title == "" {
+			for r := rank[dest[i]] - 1; r >= rank[i]; r-- {
+				if j := order[r]; root[j] == i && visits[j].Title != "" {
+					v.Title = visits[j].Title
+					break
+				}
+			}
+		}
+		collapsed = append(collapsed, v)
+	}
+	return collapsed
+}
+
+type visitID struct {
+	Source, Device string
+	ID             int64
+}
+
+type sourceDevice struct {
+	Source, Device string
+}
+
+// redirectParent returns the index of the visit that v was redirected
+// from, or -1 when v is not a redirect or the visit is not known.
+func redirectParent(visits []Visit, v *Visit, byID map[visitID]int, prev map[sourceDevice]int) int {
+	redirect := v.Transition & chrome.TransitionIsRedirectMask
+	if redirect == 0 {
+		return -1
+	}
+	if v.FromVisit != 0 {
+		if p, ok := byID[visitID{v.Source, v.Device, v.FromVisit}]; ok {
+			return p
+		}
+		return -1
+	}
+	if v.ID != 0 {
+		return -1 // linked, but without a referrer
+	}
+	p, ok := prev[sourceDevice{v.Source, v.Device}]
+	if !ok || v.Time.Sub(visits[p].Time) > redirectWindow {
+		return -1
+	}
+	ended := visits[p].Transition&chrome.TransitionChainEnd != 0
+	if redirect&chrome.TransitionServerRedirect != 0 && !ended ||
+		redirect&chrome.TransitionClientRedirect != 0 && ended {
+		return p
+	}
+	return -1
+}
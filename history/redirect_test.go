@@ -0,0 +1,48 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/chrome"
+)
+
+func TestCollapseRedirects(t *testing.T) {
+	at := time.Date(2021, 2, 18, 1, 2, 3, 0, time.UTC)
+	const (
+		start  = chrome.TransitionChainStart
+		end    = chrome.TransitionChainEnd
+		server = chrome.TransitionServerRedirect
+		client = chrome.TransitionClientRedirect
+	)
+	visits := []Visit{
+		// Linked by ID: a link through a shortener, which redirects by
+		// server to a page that redirects by script.
+		{URL: "https://short.example/x", Time: at, Transition: chrome.TransitionLink | start, Source: SourceChrome, ID: 1, FromVisit: 9},
+		{URL: "https://example.com/a", Time: at.Add(time.Millisecond), Transition: chrome.TransitionLink | server | end, Source: SourceChrome, ID: 2, FromVisit: 1},
+		{URL: "https://example.com/b", Title: "B", Time: at.Add(2 * time.Second), Transition: chrome.TransitionLink | client | start | end, Source: SourceChrome, ID: 3, FromVisit: 2},
+		// Same IDs on another device are a separate chain.
+		{URL: "http://example.org/", Time: at.Add(time.Second), Transition: chrome.TransitionTyped | start, Source: SourceChrome, Device: "phone", ID: 1},
+		{URL: "https://example.org/", Title: "Org", Time: at.Add(time.Second + time.Millisecond), Transition: chrome.TransitionTyped | server | end, Source: SourceChrome, Device: "phone", ID: 2, FromVisit: 1},
+		// Without IDs, by order and qualifiers.
+		{URL: "http://example.net/", Title: "Moved", Time: at.Add(time.Hour), Transition: chrome.TransitionTyped | start, Source: SourceTakeout},
+		{URL: "https://example.net/", Time: at.Add(time.Hour + time.Millisecond), Transition: chrome.TransitionTyped | server | end, Source: SourceTakeout},
+		{URL: "https://example.net/later", Time: at.Add(2 * time.Hour), Transition: chrome.TransitionLink | client | start | end, Source: SourceTakeout},
+	}
+	want := []Visit{
+		{URL: "https://example.com/b", Title: "B", Time: at, Transition: chrome.TransitionLink | start | end, Source: SourceChrome, ID: 1, FromVisit: 9},
+		{URL: "https://example.org/", Title: "Org", Time: at.Add(time.Second), Transition: chrome.TransitionTyped | start | end, Source: SourceChrome, Device: "phone", ID: 1},
+		{URL: "https://example.net/", Title: "Moved", Time: at.Add(time.Hour), Transition: chrome.TransitionTyped | start | end, Source: SourceTakeout},
+		{URL: "https://example.net/later", Time: at.Add(2 * time.Hour), Transition: chrome.TransitionLink | client | start | end, Source: SourceTakeout},
+	}
+	if got := CollapseRedirects(visits); !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package history provides a browser-independent model for browsing
+// history and converts history from the supported sources.
+package history
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/extensions/historytrends"
+	"github.com/andrewarchi/browser/firefox"
+	"github.com/andrewarchi/browser/takeout"
+	"github.com/andrewarchi/browser/tools/browserexport"
+	"github.com/andrewarchi/browser/tools/promnesia"
+	"github.com/andrewarchi/browser/tools/urllog"
+)
+
+// Visit is a page visit in browsing history, independent of the browser
+// or export that it was read from. The visit time is in UTC.
+type Visit struct {
+	URL        string
+	Title      string
+	Time       time.Time // UTC
+	Transition chrome.PageTransition
+	Source     string // source read from (e.g. "historytrends", "takeout")
+	Device     string // device that recorded the visit, when known
+	Precision  Precision
+	Trust      Trust
+	ID         int64 // visit ID in the source, when known
+	FromVisit  int64 // ID of the referring visit in the source, or 0
+}
+
+// Precision is the granularity of the times in a source. Times are
+// truncated to it and two times from sources of different precisions
+// are equal when they are equal at the coarser precision.
+type Precision uint8
+
+// Values for Precision:
+const (
+	PrecisionUnknown Precision = iota
+	PrecisionMicro
+	PrecisionMilli
+	PrecisionSecond
+	PrecisionDay
+)
+
+// Trust is the reliability of a source, as how directly it records the
+// browser's own history.
+type Trust uint8
+
+// Values for Trust:
+const (
+	TrustUnknown   Trust = iota
+	TrustRecovered       // carved from deleted database pages, which may be stale or incomplete
+	TrustExport          // export by an extension or service, e.g. History Trends Unlimited or Takeout
+	TrustBrowser         // read from the browser's profile
+)
+
+// Sources of visits:
+const (
+	SourceChrome        = "chrome"
+	SourceFirefox       = "firefox"
+	SourceHistoryTrends = "historytrends"
+	SourceTakeout       = "takeout"
+	SourceBrowserExport = "browserexport"
+	SourcePromnesia     = "promnesia"
+	SourceURLLog        = "urllog"
+)
+
+// FromHistoryTrends converts visits in a History Trends Unlimited
+// export.
+func FromHistoryTrends(ex *historytrends.Export) []Visit {
+	visits := make([]Visit, len(ex.Visits))
+	for i, v := range ex.Visits {
+		visits[i] = Visit{
+			URL:        v.URL,
+			Title:      v.PageTitle,
+			Time:       v.VisitTime.UTC(),
+			Transition: v.Transition,
+			Source:     SourceHistoryTrends,
+			Precision:  PrecisionMilli,
+			Trust:      TrustExport,
+			ID:         v.VisitID,
+		}
+	}
+	return visits
+}
+
+// FromChromeHistory converts visits read from the History database of
+// a Chrome profile.
+func FromChromeHistory(history []chrome.HistoryVisit) []Visit {
+	visits := make([]Visit, len(history))
+	for i, v := range history {
+		visits[i] = Visit{
+			URL:        v.URL,
+			Title:      v.Title,
+			Time:       v.VisitTime.UTC(),
+			Transition: v.Transition,
+			Source:     SourceChrome,
+			Precision:  PrecisionMicro,
+			Trust:      TrustBrowser,
+			ID:         v.ID,
+			FromVisit:  v.FromVisit,
+		}
+	}
+	return visits
+}
+
+// FromRecoveredChromeHistory converts visits recovered from the
+// deleted pages of a Chrome History database by History.Recover. Visits
+// without a known URL are dropped.
+func FromRecoveredChromeHistory(history []chrome.HistoryVisit) []Visit {
+	visits := make([]Visit, 0, len(history))
+	for _, v := range history {
+		if v.URL == "" {
+			continue
+		}
+		visits = append(visits, Visit{
+			URL:        v.URL,
+			Title:      v.Title,
+			Time:       v.VisitTime.UTC(),
+			Transition: v.Transition,
+			Source:     SourceChrome,
+			Precision:  PrecisionMicro,
+			Trust:      TrustRecovered,
+			ID:         v.ID,
+			FromVisit:  v.FromVisit,
+		})
+	}
+	return visits
+}
+
+// FromFirefoxPlaces converts visits read from places.sqlite in a
+// Firefox profile by firefox.ParsePlacesVisits. Visit types are mapped
+// to the nearest Chrome transitions, with redirects as server
+// redirects, so that CollapseRedirects follows them by FromVisit.
+func FromFirefoxPlaces(places []firefox.Visit) []Visit {
+	visits := make([]Visit, len(places))
+	for i, v := range places {
+		visits[i] = Visit{
+			URL:        v.URL,
+			Title:      v.Title,
+			Time:       v.VisitDate.UTC(),
+			Transition: firefoxTransition(v.VisitType),
+			Source:     SourceFirefox,
+			Precision:  PrecisionMicro,
+			Trust:      TrustBrowser,
+			ID:         v.ID,
+			FromVisit:  v.FromVisit,
+		}
+	}
+	return visits
+}
+
+// firefoxTransition maps a Firefox visit type to a Chrome transition.
+// Download visits and unknown types are links.
+func firefoxTransition(t firefox.VisitType) chrome.PageTransition {
+	switch t {
+	case firefox.VisitTyped:
+		return chrome.TransitionTyped
+	case firefox.VisitBookmark:
+		return chrome.TransitionAutoBookmark
+	case firefox.VisitEmbed:
+		return chrome.TransitionAutoSubframe
+	case firefox.VisitRedirectPermanent, firefox.VisitRedirectTemporary:
+		return chrome.TransitionLink | chrome.TransitionServerRedirect
+	case firefox.VisitFramedLink:
+		return chrome.TransitionManualSubframe
+	case firefox.VisitReload:
+		return chrome.TransitionReload
+	default:
+		return chrome.TransitionLink
+	}
+}
+
+// FromRecoveredFirefoxPlaces converts URLs recovered from the deleted
+// pages of a Firefox places.sqlite database by firefox.RecoverPlaces.
+// Only the last visit to each URL is known, so places without one are
+// dropped.
+func FromRecoveredFirefoxPlaces(places []firefox.RecoveredPlace) []Visit {
+	visits := make([]Visit, 0, len(places))
+	for _, p := range places {
+		if p.LastVisitDate.IsZero() {
+			continue
+		}
+		visits = append(visits, Visit{
+			URL:       p.URL,
+			Title:     p.Title,
+			Time:      p.LastVisitDate.UTC(),
+			Source:    SourceFirefox,
+			Precision: PrecisionMicro,
+			Trust:     TrustRecovered,
+		})
+	}
+	return visits
+}
+
+// FromTakeout converts visits in the Chrome browser history of a
+// Takeout export. Visits synced from other devices are attributed to
+// the device name, or to the client ID when the device is not listed.
+func FromTakeout(data *takeout.Chrome) []Visit {
+	devices := data.Devices()
+	visits := make([]Visit, len(data.BrowserHistory))
+	for i, v := range data.BrowserHistory {
+		var device string
+		if d := devices.Device(&v); d != nil {
+			device = d.ClientName
+		} else if len(v.ClientID) != 0 {
+			device = v.ClientID.String()
+		}
+		visits[i] = Visit{
+			URL:        v.URL,
+			Title:      v.Title,
+			Time:       v.Time.UTC(),
+			Transition: v.PageTransition,
+			Source:     SourceTakeout,
+			Device:     device,
+			Precision:  PrecisionMicro,
+			Trust:      TrustExport,
+		}
+	}
+	return visits
+}
+
+// FromBrowserExport converts visits saved by browserexport, from
+// either saved databases or JSON.
+func FromBrowserExport(bv []browserexport.Visit) []Visit {
+	visits := make([]Visit, len(bv))
+	for i, v := range bv {
+		precision := PrecisionMicro
+		if v.Browser == "" {
+			precision = secondOrMicro(v.Time)
+		}
+		visits[i] = Visit{
+			URL:       v.URL,
+			Title:     v.Title,
+			Time:      v.Time.UTC(),
+			Source:    SourceBrowserExport,
+			Precision: precision,
+			Trust:     TrustExport,
+		}
+	}
+	return visits
+}
+
+// FromPromnesia converts visits in a Promnesia database. Only visits
+// to URLs with a scheme are kept, which drops those found in notes
+// without one. Promnesia does not record page titles.
+func FromPromnesia(pv []promnesia.Visit) []Visit {
+	visits := make([]Visit, 0, len(pv))
+	for _, v := range pv {
+		if !strings.Contains(v.OrigURL, "://") {
+			continue
+		}
+		visits = append(visits, Visit{
+			URL:       v.OrigURL,
+			Time:      v.Time.UTC(),
+			Source:    SourcePromnesia,
+			Precision: secondOrMicro(v.Time),
+			Trust:     TrustExport,
+		})
+	}
+	return visits
+}
+
+// FromURLLog converts the entries in a URL log. Entries without a time
+// are dropped.
+func FromURLLog(entries []urllog.Entry) []Visit {
+	visits := make([]Visit, 0, len(entries))
+	for _, e := range entries {
+		if e.Time.IsZero() {
+			continue
+		}
+		visits = append(visits, Visit{
+			URL:       e.URL,
+			Title:     e.Title,
+			Time:      e.Time.UTC(),
+			Source:    SourceURLLog,
+			Precision: secondOrMicro(e.Time),
+			Trust:     TrustExport,
+		})
+	}
+	return visits
+}
+
+// secondOrMicro returns the precision of a time formatted as text,
+// which omits a zero fraction.
+func secondOrMicro(t time.Time) Precision {
+	if t.Nanosecond() != 0 {
+		return PrecisionMicro
+	}
+	return PrecisionSecond
+}
+
+// Duration returns the length of the precision, or zero when unknown.
+func (p Precision) Duration() time.Duration {
+	switch p {
+	case PrecisionMicro:
+		return time.Microsecond
+	case PrecisionMilli:
+		return time.Millisecond
+	case PrecisionSecond:
+		return time.Second
+	case PrecisionDay:
+		return 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+func (p Precision) String() string {
+	switch p {
+	case PrecisionUnknown:
+		return "unknown"
+	case PrecisionMicro:
+		return "microsecond"
+	case PrecisionMilli:
+		return "millisecond"
+	case PrecisionSecond:
+		return "second"
+	case PrecisionDay:
+		return "day"
+	default:
+		return fmt.Sprintf("precision(%d)", uint8(p))
+	}
+}
+
+func (t Trust) String() string {
+	switch t {
+	case TrustUnknown:
+		return "unknown"
+	case TrustRecovered:
+		return "recovered"
+	case TrustExport:
+		return "export"
+	case TrustBrowser:
+		return "browser"
+	default:
+		return fmt.Sprintf("trust(%d)", uint8(t))
+	}
+}
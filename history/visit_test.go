@@ -0,0 +1,96 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/extensions/historytrends"
+	"github.com/andrewarchi/browser/firefox"
+	"github.com/andrewarchi/browser/jsonutil"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/takeout"
+)
+
+func TestFromHistoryTrends(t *testing.T) {
+	est := time.FixedZone("EST", -5*60*60)
+	ex := &historytrends.Export{Visits: []historytrends.Visit{
+		{URL: "https://example.com/", VisitTime: time.Date(2021, 2, 17, 20, 0, 0, 123000000, est),
+			Transition: chrome.TransitionTyped, PageTitle: "Example", VisitID: 42},
+		{URL: "https://example.org/", VisitTime: time.Date(2021, 2, 18, 2, 0, 0, 0, time.UTC),
+			Transition: chrome.TransitionLink},
+	}}
+	want := []Visit{
+		{URL: "https://example.com/", Title: "Example", Time: time.Date(2021, 2, 18, 1, 0, 0, 123000000, time.UTC),
+			Transition: chrome.TransitionTyped, Source: SourceHistoryTrends, Precision: PrecisionMilli,
+			Trust: TrustExport, ID: 42},
+		{URL: "https://example.org/", Time: time.Date(2021, 2, 18, 2, 0, 0, 0, time.UTC),
+			Transition: chrome.TransitionLink, Source: SourceHistoryTrends, Precision: PrecisionMilli,
+			Trust: TrustExport},
+	}
+	if got := FromHistoryTrends(ex); !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got, want)
+	}
+}
+
+func TestFromTakeout(t *testing.T) {
+	at := time.Date(2021, 2, 18, 1, 2, 3, 456789000, time.UTC)
+	usec := timefmt.UnixMicro{Time: at}
+	data := &takeout.Chrome{
+		BrowserHistory: []takeout.Visit{
+			{URL: "https://example.com/", Title: "Example", PageTransition: chrome.TransitionTyped,
+				ClientID: jsonutil.Base64("phone"), Time: usec},
+			{URL: "https://example.org/", PageTransition: chrome.TransitionLink,
+				ClientID: jsonutil.Base64("other"), Time: usec},
+			{URL: "https://example.net/", PageTransition: chrome.TransitionLink, Time: usec},
+		},
+		DeviceInfo: []takeout.Device{
+			{CacheGUID: jsonutil.Base64("phone").String(), ClientName: "Pixel 4"},
+		},
+	}
+	visit := func(url, title string, transition chrome.PageTransition, device string) Visit {
+		return Visit{URL: url, Title: title, Time: at, Transition: transition, Source: SourceTakeout,
+			Device: device, Precision: PrecisionMicro, Trust: TrustExport}
+	}
+	want := []Visit{
+		visit("https://example.com/", "Example", chrome.TransitionTyped, "Pixel 4"),
+		visit("https://example.org/", "", chrome.TransitionLink, jsonutil.Base64("other").String()),
+		visit("https://example.net/", "", chrome.TransitionLink, ""),
+	}
+	if got := FromTakeout(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got, want)
+	}
+}
+
+func TestFromFirefoxPlaces(t *testing.T) {
+	at := time.Date(2021, 2, 18, 1, 0, 0, 0, time.UTC)
+	places := []firefox.Visit{
+		{ID: 1, URL: "https://example.com/", Title: "Example", VisitDate: at, VisitType: firefox.VisitTyped},
+		{ID: 2, URL: "https://example.com/home", VisitDate: at.Add(time.Second),
+			VisitType: firefox.VisitRedirectTemporary, FromVisit: 1},
+		{ID: 3, URL: "https://example.com/file.zip", VisitDate: at.Add(time.Minute), VisitType: firefox.VisitDownload},
+	}
+	want := []Visit{
+		{URL: "https://example.com/", Title: "Example", Time: at, Transition: chrome.TransitionTyped,
+			Source: SourceFirefox, Precision: PrecisionMicro, Trust: TrustBrowser, ID: 1},
+		{URL: "https://example.com/home", Time: at.Add(time.Second),
+			Transition: chrome.TransitionLink | chrome.TransitionServerRedirect,
+			Source:     SourceFirefox, Precision: PrecisionMicro, Trust: TrustBrowser, ID: 2, FromVisit: 1},
+		{URL: "https://example.com/file.zip", Time: at.Add(time.Minute), Transition: chrome.TransitionLink,
+			Source: SourceFirefox, Precision: PrecisionMicro, Trust: TrustBrowser, ID: 3},
+	}
+	got := FromFirefoxPlaces(places)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got, want)
+	}
+	if collapsed := CollapseRedirects(got); len(collapsed) != 2 || collapsed[0].URL != "https://example.com/home" {
+		t.Errorf("redirect not collapsed: %+v", collapsed)
+	}
+}
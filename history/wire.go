@@ -0,0 +1,397 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+/*
+	Wire format
+
+	Visits and downloads are encoded as JSON Lines. The first line is a
+	header with the schema version and each following line is a record
+	with a "type" field, for example:
+
+	{"format":"browser-history","schema_version":2}
+	{"type":"visit","url":"https://example.com/","time":"2021-02-18T00:00:00Z","transition":805306368,"precision":"microsecond","trust":"browser"}
+	{"type":"download","url":"https://example.com/a.zip","start_time":"2021-02-18T00:00:00Z","state":"complete"}
+
+	Field names are stable. New versions only add fields and record
+	types and never change the meaning of existing fields, so the decoder
+	reads archives from newer versions by skipping unknown fields and
+	record types. Times are RFC 3339 in UTC with nanosecond precision.
+	Transitions are the full numeric page transition, with qualifiers.
+
+	Version 2 added the precision and trust of visits. They are unknown
+	in version 1. Version 3 added the IDs of visits and their referring
+	visits in their source, which link redirect chains.
+*/
+
+// SchemaVersion is the version of the wire format written by Encoder.
+const SchemaVersion = 3
+
+const wireFormat = "browser-history"
+
+type wireHeader struct {
+	Format        string `json:"format"`
+	SchemaVersion int    `json:"schema_version"`
+}
+
+type wireVisit struct {
+	Type       string    `json:"type,omitempty"`
+	URL        string    `json:"url"`
+	Title      string    `json:"title,omitempty"`
+	Time       time.Time `json:"time"`
+	Transition uint32    `json:"transition"`
+	Source     string    `json:"source,omitempty"`
+	Device     string    `json:"device,omitempty"`
+	Precision  string    `json:"precision,omitempty"`
+	Trust      string    `json:"trust,omitempty"`
+	ID         int64     `json:"id,omitempty"`
+	FromVisit  int64     `json:"from_visit,omitempty"`
+}
+
+type wireDownload struct {
+	Type          string     `json:"type,omitempty"`
+	URL           string     `json:"url"`
+	Referrer      string     `json:"referrer,omitempty"`
+	TargetPath    string     `json:"target_path,omitempty"`
+	MIMEType      string     `json:"mime_type,omitempty"`
+	StartTime     time.Time  `json:"start_time"`
+	EndTime       *time.Time `json:"end_time,omitempty"`
+	ReceivedBytes int64      `json:"received_bytes"`
+	TotalBytes    int64      `json:"total_bytes"`
+	State         string     `json:"state"`
+	Source        string     `json:"source,omitempty"`
+	File          *wireFile  `json:"file,omitempty"`
+}
+
+type wireFile struct {
+	Exists    bool         `json:"exists"`
+	Size      int64        `json:"size,omitempty"`
+	ModTime   *time.Time   `json:"mod_time,omitempty"`
+	SHA256    jsonutil.Hex `json:"sha256,omitempty"`
+	CheckTime time.Time    `json:"check_time"`
+}
+
+// MarshalJSON implements the json.Marshaler interface using the wire
+// format.
+func (v Visit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.wire(""))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface using the
+// wire format. Unknown fields are ignored for forward compatibility.
+func (v *Visit) UnmarshalJSON(data []byte) error {
+	var w wireVisit
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	*v = Visit{
+		URL:        w.URL,
+		Title:      w.Title,
+		Time:       w.Time.UTC(),
+		Transition: chrome.PageTransition(w.Transition),
+		Source:     w.Source,
+		Device:     w.Device,
+		Precision:  parsePrecision(w.Precision),
+		Trust:      parseTrust(w.Trust),
+		ID:         w.ID,
+		FromVisit:  w.FromVisit,
+	}
+	return nil
+}
+
+func (v *Visit) wire(typ string) *wireVisit {
+	w := &wireVisit{
+		Type:       typ,
+		URL:        v.URL,
+		Title:      v.Title,
+		Time:       v.Time.UTC(),
+		Transition: uint32(v.Transition),
+		Source:     v.Source,
+		Device:     v.Device,
+		ID:         v.ID,
+		FromVisit:  v.FromVisit,
+	}
+	if v.Precision != PrecisionUnknown {
+		w.Precision = v.Precision.String()
+	}
+	if v.Trust != TrustUnknown {
+		w.Trust = v.Trust.String()
+	}
+	return w
+}
+
+// MarshalJSON implements the json.Marshaler interface using the wire
+// format.
+func (d Download) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.wire(""))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface using the
+// wire format. Unknown fields are ignored for forward compatibility.
+func (d *Download) UnmarshalJSON(data []byte) error {
+	var w wireDownload
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	*d = Download{
+		URL:           w.URL,
+		Referrer:      w.Referrer,
+		TargetPath:    w.TargetPath,
+		MIMEType:      w.MIMEType,
+		StartTime:     w.StartTime.UTC(),
+		EndTime:       fromTimePtr(w.EndTime),
+		ReceivedBytes: w.ReceivedBytes,
+		TotalBytes:    w.TotalBytes,
+		State:         parseDownloadState(w.State),
+		Source:        w.Source,
+	}
+	if w.File != nil {
+		d.File = &DownloadFile{
+			Exists:    w.File.Exists,
+			Size:      w.File.Size,
+			ModTime:   fromTimePtr(w.File.ModTime),
+			SHA256:    w.File.SHA256,
+			CheckTime: w.File.CheckTime.UTC(),
+		}
+	}
+	return nil
+}
+
+func (d *Download) wire(typ string) *wireDownload {
+	w := &wireDownload{
+		Type:          typ,
+		URL:           d.URL,
+		Referrer:      d.Referrer,
+		TargetPath:    d.TargetPath,
+		MIMEType:      d.MIMEType,
+		StartTime:     d.StartTime.UTC(),
+		EndTime:       toTimePtr(d.EndTime),
+		ReceivedBytes: d.ReceivedBytes,
+		TotalBytes:    d.TotalBytes,
+		State:         d.State.String(),
+		Source:        d.Source,
+	}
+	if d.File != nil {
+		w.File = &wireFile{
+			Exists:    d.File.Exists,
+			Size:      d.File.Size,
+			ModTime:   toTimePtr(d.File.ModTime),
+			SHA256:    d.File.SHA256,
+			CheckTime: d.File.CheckTime.UTC(),
+		}
+	}
+	return w
+}
+
+func toTimePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+func fromTimePtr(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// parseDownloadState parses the string form of a download state.
+// States added by newer versions are unknown.
+func parseDownloadState(s string) DownloadState {
+	for state := DownloadUnknown; state <= DownloadInterrupted; state++ {
+		if state.String() == s {
+			return state
+		}
+	}
+	return DownloadUnknown
+}
+
+// parsePrecision parses the string form of a precision. Precisions
+// added by newer versions are unknown.
+func parsePrecision(s string) Precision {
+	for p := PrecisionUnknown; p <= PrecisionDay; p++ {
+		if p.String() == s {
+			return p
+		}
+	}
+	return PrecisionUnknown
+}
+
+// parseTrust parses the string form of a trust. Levels added by newer
+// versions are unknown.
+func parseTrust(s string) Trust {
+	for t := TrustUnknown; t <= TrustBrowser; t++ {
+		if t.String() == s {
+			return t
+		}
+	}
+	return TrustUnknown
+}
+
+// Encoder writes visits and downloads in the wire format.
+type Encoder struct {
+	w      *bufio.Writer
+	header bool
+}
+
+// NewEncoder returns a new Encoder that writes to w. Flush must be
+// called after the last record.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w)}
+}
+
+// EncodeVisit writes a visit.
+func (e *Encoder) EncodeVisit(v *Visit) error {
+	return e.encode(v.wire("visit"))
+}
+
+// EncodeDownload writes a download.
+func (e *Encoder) EncodeDownload(d *Download) error {
+	return e.encode(d.wire("download"))
+}
+
+func (e *Encoder) encode(v interface{}) error {
+	if !e.header {
+		if err := e.writeLine(&wireHeader{wireFormat, SchemaVersion}); err != nil {
+			return err
+		}
+		e.header = true
+	}
+	return e.writeLine(v)
+}
+
+func (e *Encoder) writeLine(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := e.w.Write(b); err != nil {
+		return err
+	}
+	return e.w.WriteByte('\n')
+}
+
+// Flush writes the header, if no records have been written, and any
+// buffered data to the underlying io.Writer.
+func (e *Encoder) Flush() error {
+	if !e.header {
+		if err := e.writeLine(&wireHeader{wireFormat, SchemaVersion}); err != nil {
+			return err
+		}
+		e.header = true
+	}
+	return e.w.Flush()
+}
+
+// Record is a single record read by Decoder. Exactly one of Visit and
+// Download is set.
+type Record struct {
+	Visit    *Visit
+	Download *Download
+}
+
+// Decoder reads visits and downloads in the wire format.
+type Decoder struct {
+	s       *bufio.Scanner
+	version int
+	line    int
+}
+
+// NewDecoder returns a new Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 64<<20)
+	return &Decoder{s: s}
+}
+
+// SchemaVersion returns the schema version of the stream, once the
+// header has been read by Decode.
+func (d *Decoder) SchemaVersion() int { return d.version }
+
+// Decode reads the next record. Records of types unknown to this
+// version are skipped. It returns io.EOF at the end of the stream.
+func (d *Decoder) Decode() (*Record, error) {
+	for {
+		line, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		if d.version == 0 {
+			var h wireHeader
+			if err := json.Unmarshal(line, &h); err != nil {
+				return nil, d.errorf("header: %w", err)
+			}
+			if h.Format != wireFormat || h.SchemaVersion < 1 {
+				return nil, d.errorf("not a %s stream: %q", wireFormat, line)
+			}
+			d.version = h.SchemaVersion
+			continue
+		}
+		var typ struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(line, &typ); err != nil {
+			return nil, d.errorf("%w", err)
+		}
+		switch typ.Type {
+		case "visit":
+			var v Visit
+			if err := json.Unmarshal(line, &v); err != nil {
+				return nil, d.errorf("visit: %w", err)
+			}
+			return &Record{Visit: &v}, nil
+		case "download":
+			var dl Download
+			if err := json.Unmarshal(line, &dl); err != nil {
+				return nil, d.errorf("download: %w", err)
+			}
+			return &Record{Download: &dl}, nil
+		case "":
+			return nil, d.errorf("record has no type")
+		default:
+			if d.version <= SchemaVersion {
+				return nil, d.errorf("unknown record type %q in schema version %d", typ.Type, d.version)
+			}
+		}
+	}
+}
+
+func (d *Decoder) next() ([]byte, error) {
+	for d.s.Scan() {
+		d.line++
+		if line := bytes.TrimSpace(d.s.Bytes()); len(line) != 0 {
+			return line, nil
+		}
+	}
+	if err := d.s.Err(); err != nil {
+		return nil, err
+	}
+	if d.version == 0 {
+		return nil, errors.New("history: missing header")
+	}
+	return nil, io.EOF
+}
+
+func (d *Decoder) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("history: line %d: %w", d.line, fmt.Errorf(format, args...))
+}
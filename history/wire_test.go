@@ -0,0 +1,98 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package history
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/chrome"
+)
+
+func TestWireRoundTrip(t *testing.T) {
+	visit := Visit{
+		URL:        "https://example.com/",
+		Title:      "Example",
+		Time:       time.Date(2021, 2, 18, 1, 2, 3, 456789000, time.UTC),
+		Transition: chrome.TransitionTyped | chrome.TransitionChainStart | chrome.TransitionChainEnd,
+		Source:     SourceTakeout,
+		Device:     "laptop",
+		Precision:  PrecisionMicro,
+		Trust:      TrustExport,
+		ID:         12,
+		FromVisit:  11,
+	}
+	download := Download{
+		URL:        "https://example.com/a.zip",
+		TargetPath: "/tmp/a.zip",
+		StartTime:  time.Date(2021, 2, 18, 0, 0, 0, 0, time.UTC),
+		TotalBytes: -1,
+		State:      DownloadComplete,
+		Source:     SourceFirefox,
+		File:       &DownloadFile{Exists: true, Size: 3, SHA256: []byte{1, 2, 3}, CheckTime: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	if err := e.EncodeVisit(&visit); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.EncodeDownload(&download); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDecoder(&buf)
+	r, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Visit == nil || !reflect.DeepEqual(*r.Visit, visit) {
+		t.Errorf("got visit %+v, want %+v", r.Visit, visit)
+	}
+	r, err = d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Download == nil || !reflect.DeepEqual(*r.Download, download) {
+		t.Errorf("got download %+v, want %+v", r.Download, download)
+	}
+	if _, err := d.Decode(); err != io.EOF {
+		t.Errorf("got error %v, want EOF", err)
+	}
+	if d.SchemaVersion() != SchemaVersion {
+		t.Errorf("got schema version %d, want %d", d.SchemaVersion(), SchemaVersion)
+	}
+}
+
+func TestWireForwardCompatible(t *testing.T) {
+	const stream = `{"format":"browser-history","schema_version":99}
+{"type":"tab","url":"https://example.com/"}
+{"type":"visit","url":"https://example.com/","time":"2021-02-18T00:00:00Z","transition":1,"new_field":true}
+`
+	d := NewDecoder(strings.NewReader(stream))
+	r, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Visit == nil || r.Visit.URL != "https://example.com/" || r.Visit.Transition != chrome.TransitionTyped {
+		t.Errorf("got record %+v", r)
+	}
+
+	const old = `{"format":"browser-history","schema_version":1}
+{"type":"tab","url":"https://example.com/"}
+`
+	if _, err := NewDecoder(strings.NewReader(old)).Decode(); err == nil {
+		t.Error("unknown record type accepted in current schema version")
+	}
+}
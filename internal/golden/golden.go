@@ -0,0 +1,122 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package golden checks parsers against a corpus of sanitized sample
+// files and golden files of their expected output.
+//
+// A corpus is a directory in testdata with a subdirectory per artifact,
+// named after the file that the browser writes (e.g. "times.json").
+// Each entry in an artifact directory is a sample, which may be a file
+// or a directory, and is paired with a golden file of the same name
+// with the suffix ".golden", containing the parsed sample as indented
+// JSON. Samples should be sanitized with the sanitize package before
+// being added.
+//
+// To add a sample, copy it into the artifact directory and regenerate
+// the golden files with:
+//
+//	go test ./firefox -run TestCorpus -update
+//
+// then review the changes to the golden files.
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// Suffix is the file extension of golden files.
+const Suffix = ".golden"
+
+// ParseFunc parses a sample file or directory.
+type ParseFunc func(filename string) (interface{}, error)
+
+// Corpus parses each sample in the corpus directory with the parser
+// for its artifact and compares the result against its golden file.
+// Artifact directories without a parser are reported as errors, so
+// that samples are not silently ignored, while parsers without samples
+// are logged.
+func Corpus(t *testing.T, dir string, parsers map[string]ParseFunc) {
+	t.Helper()
+	artifacts, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, artifact := range artifacts {
+		if !artifact.IsDir() {
+			continue
+		}
+		name := artifact.Name()
+		parse, ok := parsers[name]
+		if !ok {
+			t.Errorf("%s: no parser for artifact", filepath.Join(dir, name))
+			continue
+		}
+		seen[name] = true
+		samples, err := os.ReadDir(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sample := range samples {
+			if strings.HasSuffix(sample.Name(), Suffix) {
+				continue
+			}
+			filename := filepath.Join(dir, name, sample.Name())
+			t.Run(name+"/"+sample.Name(), func(t *testing.T) {
+				v, err := parse(filename)
+				if err != nil {
+					t.Fatal(err)
+				}
+				Check(t, filename+Suffix, v)
+			})
+		}
+	}
+
+	var missing []string
+	for name := range parsers {
+		if !seen[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		t.Logf("%s: no samples for artifact", filepath.Join(dir, name))
+	}
+}
+
+// Check compares v, marshaled as indented JSON, against the contents
+// of a golden file. When the -update flag is set, the golden file is
+// written instead.
+func Check(t *testing.T, filename string, v interface{}) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+	if *update {
+		if err := os.WriteFile(filename, got, 0o666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s: output differs from golden file (run with -update to regenerate)\ngot:\n%s\nwant:\n%s", filename, got, want)
+	}
+}
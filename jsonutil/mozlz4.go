@@ -8,36 +8,16 @@ package jsonutil
 
 import (
 	"bytes"
-	"encoding/binary"
-	"errors"
-	"fmt"
 	"io"
 	"io/ioutil"
 
-	"github.com/pierrec/lz4/v4"
+	"github.com/andrewarchi/browser/compress/mozlz4"
 )
 
-const mozLz4Magic = 0x6d6f7a4c7a343000 // "mozLz40\x00"
-
+// DecompressMozLz4 decompresses mozLz4 data. It is equivalent to
+// mozlz4.Decode.
 func DecompressMozLz4(b []byte) ([]byte, error) {
-	if len(b) < 12 {
-		return nil, errors.New("mozlz4: missing header")
-	}
-	magic := binary.BigEndian.Uint64(b)
-	if magic != mozLz4Magic {
-		return nil, fmt.Errorf("mozlz4: invalid magic number: %08x", magic)
-	}
-	size := binary.LittleEndian.Uint32(b[8:])
-
-	data := make([]byte, size)
-	n, err := lz4.UncompressBlock(b[12:], data)
-	if err != nil {
-		return nil, fmt.Errorf("mozlz4: decompress: %w", err)
-	}
-	if n != int(size) {
-		return nil, fmt.Errorf("mozlz4: header size %d and decompressed size %d differ", size, n)
-	}
-	return data, nil
+	return mozlz4.Decode(b)
 }
 
 func UnmarshalMozLz4(b []byte, v interface{}) error {
@@ -54,7 +54,9 @@ func FromInt(n, nsec int64, unit Unit, epoch Epoch) time.Time {
 	}
 }
 
-var windowsEpoch = time.Date(1601, 1, 1, 0, 0, 0, 0, time.UTC)
+// windowsToUnix is the number of seconds from the Windows epoch to the
+// Unix epoch.
+const windowsToUnix = 11644473600
 
 func ToInt(t time.Time, unit Unit, epoch Epoch) (n, nsec int64) {
 	if t.IsZero() {
@@ -62,17 +64,15 @@ func ToInt(t time.Time, unit Unit, epoch Epoch) (n, nsec int64) {
 	}
 	e := int64(exp[unit])
 	e0 := int64(exp[Nano-unit])
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
 	switch epoch {
 	case Unix:
-		sec, nsec := t.Unix(), t.UnixNano()/1e9
-		return sec*e + nsec/e0, nsec % e0
 	case Windows:
-		nsec := int64(t.Sub(windowsEpoch))
-		// TODO sub loses precision on large times
-		return nsec / e0, nsec % e0
+		sec += windowsToUnix
 	default:
 		panic(fmt.Sprintf("illegal epoch: %d", epoch))
 	}
+	return sec*e + nsec/e0, nsec % e0
 }
 
 func Parse(s string, unit Unit, epoch Epoch) (time.Time, error) {
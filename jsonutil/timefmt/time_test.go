@@ -0,0 +1,57 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package timefmt
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFormatParse(t *testing.T) {
+	tm := time.Date(2021, 2, 18, 1, 2, 3, 456789000, time.UTC)
+	tests := []struct {
+		Unit  Unit
+		Epoch Epoch
+		Want  string
+	}{
+		{Sec, Unix, "1613610123.456789"},
+		{Milli, Unix, "1613610123456.789"},
+		{Micro, Unix, "1613610123456789"},
+		{Micro, Windows, "13258083723456789"},
+	}
+	for i, tt := range tests {
+		s := Format(tm, tt.Unit, tt.Epoch)
+		if s != tt.Want {
+			t.Errorf("#%d: got: %s, want: %s", i, s, tt.Want)
+		}
+		got, err := Parse(s, tt.Unit, tt.Epoch)
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+		} else if !got.Equal(tm) {
+			t.Errorf("#%d: got: %s, want: %s", i, got, tm)
+		}
+	}
+}
+
+func TestQuotedChrome(t *testing.T) {
+	const data = `"13258083723456789"`
+	var tm QuotedChrome
+	if err := json.Unmarshal([]byte(data), &tm); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2021, 2, 18, 1, 2, 3, 456789000, time.UTC); !tm.Equal(want) {
+		t.Errorf("got: %s, want: %s", tm, want)
+	}
+	b, err := json.Marshal(tm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != data {
+		t.Errorf("got: %s, want: %s", b, data)
+	}
+}
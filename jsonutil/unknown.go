@@ -24,6 +24,12 @@ func (u *UnknownObj) UnmarshalJSON(data []byte) error {
 	return fmt.Errorf("jsonutil: unmarshal of unknown object type: %q", data)
 }
 
+// MarshalJSON implements the json.Marshaler interface. It writes {},
+// which is the only non-null value that unmarshals.
+func (u UnknownObj) MarshalJSON() ([]byte, error) {
+	return []byte("{}"), nil
+}
+
 // UnknownType represents a json value for which the full type
 // information is not known. Any unmarshal of a value that is not null
 // will raise an error. This is to ensure no data loss until all types
@@ -39,3 +45,9 @@ func (u *UnknownType) UnmarshalJSON(data []byte) error {
 	}
 	return fmt.Errorf("jsonutil: unmarshal of unknown type: %q", data)
 }
+
+// MarshalJSON implements the json.Marshaler interface. It writes null,
+// which is the only value that unmarshals.
+func (u UnknownType) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
@@ -25,7 +25,7 @@ func (id Firefox) MarshalText() ([]byte, error) {
 		return []byte(id.ID), nil
 	}
 	if id.UUID != nil {
-		return id.UUID.MarshalText()
+		return id.UUID.Encode(Braced), nil
 	}
 	return nil, nil
 }
@@ -52,7 +52,7 @@ func (id Firefox) MarshalJSON() ([]byte, error) {
 		return []byte(strconv.Quote(id.ID)), nil
 	}
 	if id.UUID != nil {
-		return id.UUID.MarshalJSON()
+		return []byte(strconv.Quote(string(id.UUID.Encode(Braced)))), nil
 	}
 	return []byte("null"), nil
 }
@@ -120,3 +120,35 @@ func (uuid *UUID) UnmarshalJSON(data []byte) error {
 func (uuid *UUID) String() string {
 	return string(uuid.Encode(Normal))
 }
+
+// GUID is a UUID that is formatted with braces, as
+// "{xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx}", like the GUIDs of Windows
+// and Firefox.
+type GUID UUID
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (guid *GUID) MarshalText() ([]byte, error) {
+	return (*UUID)(guid).Encode(Braced), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (guid *GUID) UnmarshalText(data []byte) error {
+	return (*UUID)(guid).UnmarshalText(data)
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (guid *GUID) MarshalJSON() ([]byte, error) {
+	if guid == nil {
+		return []byte("null"), nil
+	}
+	return []byte(strconv.Quote(guid.String())), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (guid *GUID) UnmarshalJSON(data []byte) error {
+	return jsonutil.QuotedUnmarshal(data, guid)
+}
+
+func (guid *GUID) String() string {
+	return string((*UUID)(guid).Encode(Braced))
+}
@@ -41,3 +41,21 @@ func TestDecode(t *testing.T) {
 		}
 	}
 }
+
+func TestGUIDJSON(t *testing.T) {
+	guid := GUID(uuid)
+	b, err := guid.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"{01234567-89ab-cdef-0123-456789abcdef}"`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+	var got GUID
+	if err := got.UnmarshalJSON(b); err != nil {
+		t.Fatal(err)
+	}
+	if got != guid {
+		t.Errorf("got %s, want %s", &got, &guid)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package manifest parses the manifest.json of WebExtensions, for
+// both Chrome and Firefox and both Manifest V2 and V3, normalizes
+// their permissions, and scores their risk. It also reads the ZIP
+// archives of packaged extensions and compares them to the installed
+// files.
+//
+// Manifest references:
+// https://developer.chrome.com/docs/extensions/mv3/manifest/
+// https://developer.mozilla.org/en-US/docs/Mozilla/Add-ons/WebExtensions/manifest.json
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+// Manifest is a WebExtension manifest.json. Only the fields needed to
+// identify an extension and the access it requests are decoded.
+type Manifest struct {
+	ManifestVersion         int                      `json:"manifest_version"` // 2 or 3
+	Name                    string                   `json:"name"`             // may be a message like "__MSG_name__"
+	Version                 string                   `json:"version"`
+	Description             string                   `json:"description,omitempty"`
+	DefaultLocale           string                   `json:"default_locale,omitempty"`
+	HomepageURL             string                   `json:"homepage_url,omitempty"`
+	UpdateURL               string                   `json:"update_url,omitempty"` // Chrome only
+	Key                     string                   `json:"key,omitempty"`        // Chrome only; public key that the ID is derived from
+	Permissions             []string                 `json:"permissions,omitempty"`
+	OptionalPermissions     []string                 `json:"optional_permissions,omitempty"`
+	HostPermissions         []string                 `json:"host_permissions,omitempty"`          // since V3
+	OptionalHostPermissions []string                 `json:"optional_host_permissions,omitempty"` // since V3
+	ContentScripts          []ContentScript          `json:"content_scripts,omitempty"`
+	Background              *Background              `json:"background,omitempty"`
+	ContentSecurityPolicy   ContentSecurityPolicy    `json:"content_security_policy,omitempty"`
+	BrowserSpecificSettings *BrowserSpecificSettings `json:"browser_specific_settings,omitempty"` // Firefox only
+	Applications            *BrowserSpecificSettings `json:"applications,omitempty"`              // Firefox only; before browser_specific_settings
+}
+
+// ContentScript is a script injected into pages that match a pattern.
+type ContentScript struct {
+	Matches        []string `json:"matches"`
+	ExcludeMatches []string `json:"exclude_matches,omitempty"`
+	JS             []string `json:"js,omitempty"`
+	CSS            []string `json:"css,omitempty"`
+	RunAt          string   `json:"run_at,omitempty"` // e.g. "document_idle"
+	AllFrames      bool     `json:"all_frames,omitempty"`
+}
+
+// Background is the background page or scripts of an extension.
+type Background struct {
+	Page          string   `json:"page,omitempty"`
+	Scripts       []string `json:"scripts,omitempty"`
+	ServiceWorker string   `json:"service_worker,omitempty"` // since V3
+	Persistent    *bool    `json:"persistent,omitempty"`
+}
+
+// BrowserSpecificSettings holds the Firefox-specific settings.
+type BrowserSpecificSettings struct {
+	Gecko *GeckoSettings `json:"gecko,omitempty"`
+}
+
+// GeckoSettings identifies an extension to Firefox.
+type GeckoSettings struct {
+	ID               string `json:"id,omitempty"` // e.g. "addon@example.com" or "{uuid}"
+	StrictMinVersion string `json:"strict_min_version,omitempty"`
+	StrictMaxVersion string `json:"strict_max_version,omitempty"`
+	UpdateURL        string `json:"update_url,omitempty"`
+}
+
+// ContentSecurityPolicy is the content security policy of an
+// extension. In V2, it is a single string and, in V3, it is an object
+// with a policy for extension pages and for sandboxed pages.
+type ContentSecurityPolicy struct {
+	ExtensionPages string `json:"extension_pages,omitempty"`
+	Sandbox        string `json:"sandbox,omitempty"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (csp *ContentSecurityPolicy) UnmarshalJSON(data []byte) error {
+	if len(data) != 0 && data[0] == '"' {
+		*csp = ContentSecurityPolicy{}
+		return json.Unmarshal(data, &csp.ExtensionPages)
+	}
+	type policy ContentSecurityPolicy
+	return json.Unmarshal(data, (*policy)(csp))
+}
+
+// Decode decodes a manifest. A leading byte order mark, which Chrome
+// permits, is skipped.
+func Decode(r io.Reader) (*Manifest, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))
+	var m Manifest
+	if err := jsonutil.DecodeAllowUnknownFields(bytes.NewReader(b), &m); err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// ParseFile parses a manifest.json file.
+func ParseFile(filename string) (*Manifest, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Decode(f)
+}
+
+// GeckoID returns the Firefox add-on ID, if set.
+func (m *Manifest) GeckoID() string {
+	for _, s := range []*BrowserSpecificSettings{m.BrowserSpecificSettings, m.Applications} {
+		if s != nil && s.Gecko != nil && s.Gecko.ID != "" {
+			return s.Gecko.ID
+		}
+	}
+	return ""
+}
+
+// Permissions is a normalized set of permissions, split into API
+// permissions and host match patterns, as is done by V3 and by
+// browsers internally.
+type Permissions struct {
+	API   []string // e.g. "tabs" or "webRequest", sorted
+	Hosts []string // match patterns, normalized and sorted
+}
+
+// Required returns the permissions requested at install, including the
+// hosts matched by content scripts.
+func (m *Manifest) Required() Permissions {
+	var hosts []string
+	for _, cs := range m.ContentScripts {
+		hosts = append(hosts, cs.Matches...)
+	}
+	return NewPermissions(m.Permissions, m.HostPermissions, hosts)
+}
+
+// Optional returns the permissions that may be requested at runtime.
+func (m *Manifest) Optional() Permissions {
+	return NewPermissions(m.OptionalPermissions, m.OptionalHostPermissions)
+}
+
+// NewPermissions normalizes lists of permissions. Host patterns may be
+// mixed with API permissions, as in V2 and in the permissions granted
+// in browser preferences.
+func NewPermissions(lists ...[]string) Permissions {
+	api := make(map[string]bool)
+	hosts := make(map[string]bool)
+	for _, list := range lists {
+		for _, p := range list {
+			p = strings.TrimSpace(p)
+			if p == "" {
+				continue
+			}
+			if IsHostPattern(p) {
+				hosts[NormalizePattern(p)] = true
+			} else {
+				api[p] = true
+			}
+		}
+	}
+	return Permissions{API: sortedKeys(api), Hosts: sortedKeys(hosts)}
+}
+
+// IsHostPattern reports whether a permission is a match pattern, like
+// "https://*.example.com/*" or "<all_urls>", rather than an API.
+func IsHostPattern(p string) bool {
+	return p == AllURLs || strings.Contains(p, "://")
+}
+
+// AllURLs is the match pattern for all URLs with a permitted scheme.
+const AllURLs = "<all_urls>"
+
+// NormalizePattern normalizes a match pattern by lowercasing its scheme
+// and host and adding the path "/*" when it has none, as in origins
+// like "https://example.com".
+func NormalizePattern(p string) string {
+	if p == AllURLs {
+		return p
+	}
+	i := strings.Index(p, "://")
+	if i == -1 {
+		return p
+	}
+	scheme, rest := strings.ToLower(p[:i]), p[i+3:]
+	host, path := rest, "/*"
+	if j := strings.IndexByte(rest, '/'); j != -1 {
+		host, path = rest[:j], rest[j:]
+	}
+	return scheme + "://" + strings.ToLower(host) + path
+}
+
+// IsBroadPattern reports whether a match pattern matches every host,
+// like "<all_urls>", "*://*/*", or "https://*/".
+func IsBroadPattern(p string) bool {
+	if p == AllURLs {
+		return true
+	}
+	i := strings.Index(p, "://")
+	if i == -1 {
+		return false
+	}
+	host := p[i+3:]
+	if j := strings.IndexByte(host, '/'); j != -1 {
+		host = host[:j]
+	}
+	return host == "*"
+}
+
+func sortedKeys(m map[string]bool) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package manifest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		Name     string
+		JSON     string
+		Required Permissions
+		Optional Permissions
+		CSP      ContentSecurityPolicy
+		GeckoID  string
+	}{{
+		Name: "Firefox V2",
+		JSON: `{"manifest_version": 2, "name": "Tab Tool", "version": "1.0",
+			"permissions": ["tabs", "storage", "HTTPS://Example.COM/*", "tabs"],
+			"optional_permissions": ["clipboardRead", "https://example.org"],
+			"content_scripts": [{"matches": ["*://*.example.net/*"], "js": ["content.js"]}],
+			"content_security_policy": "script-src 'self'; object-src 'self'",
+			"applications": {"gecko": {"id": "tabtool@example.com"}},
+			"developer": {"name": "Example"}}`,
+		Required: Permissions{API: []string{"storage", "tabs"},
+			Hosts: []string{"*://*.example.net/*", "https://example.com/*"}},
+		Optional: Permissions{API: []string{"clipboardRead"}, Hosts: []string{"https://example.org/*"}},
+		CSP:      ContentSecurityPolicy{ExtensionPages: "script-src 'self'; object-src 'self'"},
+		GeckoID:  "tabtool@example.com",
+	}, {
+		Name: "Chrome V3 with BOM",
+		JSON: "\xef\xbb\xbf" + `{"manifest_version": 3, "name": "__MSG_name__", "version": "2.1.0",
+			"permissions": ["scripting", "declarativeNetRequest"],
+			"host_permissions": ["<all_urls>"],
+			"optional_host_permissions": ["https://*/*"],
+			"background": {"service_worker": "sw.js"},
+			"content_security_policy": {"extension_pages": "script-src 'self'"},
+			"update_url": "https://clients2.google.com/service/update2/crx"}`,
+		Required: Permissions{API: []string{"declarativeNetRequest", "scripting"}, Hosts: []string{AllURLs}},
+		Optional: Permissions{Hosts: []string{"https://*/*"}},
+		CSP:      ContentSecurityPolicy{ExtensionPages: "script-src 'self'"},
+	}}
+	for _, tt := range tests {
+		m, err := Decode(strings.NewReader(tt.JSON))
+		if err != nil {
+			t.Errorf("%s: %v", tt.Name, err)
+			continue
+		}
+		if got := m.Required(); !reflect.DeepEqual(got, tt.Required) {
+			t.Errorf("%s: got required:\n%+v\nwant:\n%+v", tt.Name, got, tt.Required)
+		}
+		if got := m.Optional(); !reflect.DeepEqual(got, tt.Optional) {
+			t.Errorf("%s: got optional:\n%+v\nwant:\n%+v", tt.Name, got, tt.Optional)
+		}
+		if m.ContentSecurityPolicy != tt.CSP {
+			t.Errorf("%s: got CSP %+v, want %+v", tt.Name, m.ContentSecurityPolicy, tt.CSP)
+		}
+		if id := m.GeckoID(); id != tt.GeckoID {
+			t.Errorf("%s: got Gecko ID %q, want %q", tt.Name, id, tt.GeckoID)
+		}
+	}
+}
+
+func TestIsBroadPattern(t *testing.T) {
+	for p, want := range map[string]bool{
+		AllURLs:                 true,
+		"*://*/*":               true,
+		"https://*/":            true,
+		"file:///*":             false,
+		"*://*.example.com/*":   false,
+		"https://example.com/*": false,
+		"webRequest":            false,
+	} {
+		if got := IsBroadPattern(p); got != want {
+			t.Errorf("IsBroadPattern(%q) = %t, want %t", p, got, want)
+		}
+	}
+}
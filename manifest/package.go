@@ -0,0 +1,133 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package manifest
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ReadZip reads manifest.json from the ZIP archive of a packaged
+// extension, such as a CRX or XPI.
+func ReadZip(z *zip.Reader) (*Manifest, error) {
+	for _, f := range z.File {
+		if f.Name == "manifest.json" {
+			r, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer r.Close()
+			return Decode(r)
+		}
+	}
+	return nil, errors.New("manifest: no manifest.json in package")
+}
+
+// ZipFiles returns the names of the files in the ZIP archive of a
+// packaged extension, excluding directories, sorted.
+func ZipFiles(z *zip.Reader) []string {
+	var names []string
+	for _, f := range z.File {
+		if !strings.HasSuffix(f.Name, "/") {
+			names = append(names, f.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FileDiff is the difference between the files in a package and the
+// files installed from it.
+type FileDiff struct {
+	Modified []string // in both, but with different contents
+	Missing  []string // in the package, but not installed
+	Added    []string // installed, but not in the package
+}
+
+// Empty reports whether the package and the installed files match.
+func (d *FileDiff) Empty() bool {
+	return len(d.Modified) == 0 && len(d.Missing) == 0 && len(d.Added) == 0
+}
+
+// CompareDir compares the files in the ZIP archive of a packaged
+// extension to the files installed from it in dir. Paths are
+// slash-separated and sorted. Installed paths with a prefix in ignore,
+// like "_metadata/" for the hashes that Chrome computes on install, are
+// skipped.
+func CompareDir(z *zip.Reader, dir string, ignore ...string) (*FileDiff, error) {
+	var d FileDiff
+	packaged := make(map[string]bool)
+	for _, f := range z.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		packaged[f.Name] = true
+		installed, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(f.Name)))
+		if errors.Is(err, os.ErrNotExist) {
+			d.Missing = append(d.Missing, f.Name)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		same, err := zipFileEqual(f, installed)
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			d.Modified = append(d.Modified, f.Name)
+		}
+	}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		for _, prefix := range ignore {
+			if strings.HasPrefix(rel, prefix) {
+				return nil
+			}
+		}
+		if !packaged[rel] {
+			d.Added = append(d.Added, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(d.Modified)
+	sort.Strings(d.Missing)
+	sort.Strings(d.Added)
+	return &d, nil
+}
+
+func zipFileEqual(f *zip.File, data []byte) (bool, error) {
+	if f.UncompressedSize64 != uint64(len(data)) {
+		return false, nil
+	}
+	r, err := f.Open()
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(r, int64(len(data))+1))
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(b, data), nil
+}
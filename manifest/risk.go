@@ -0,0 +1,155 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package manifest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Risk is an assessment of the access that an extension has. It is a
+// heuristic for prioritizing review, rather than a verdict.
+type Risk struct {
+	Score   int // sum of the weights of the factors
+	Level   RiskLevel
+	Factors []RiskFactor // in descending order of weight
+}
+
+// RiskFactor is a permission or capability that contributes to risk.
+type RiskFactor struct {
+	Permission string // e.g. "webRequest" or "<all_urls>"
+	Weight     int
+	Reason     string
+}
+
+// RiskLevel is a coarse level of risk.
+type RiskLevel uint8
+
+// Values for RiskLevel:
+const (
+	RiskLow RiskLevel = iota
+	RiskMedium
+	RiskHigh
+)
+
+// Thresholds of Risk.Score for each level.
+const (
+	riskMediumScore = 3
+	riskHighScore   = 8
+)
+
+// apiRisks are the weights of API permissions that grant access to
+// browsing data, page content, or the system.
+var apiRisks = map[string]RiskFactor{
+	"clipboardRead":         {Weight: 3, Reason: "reads the clipboard"},
+	"clipboardWrite":        {Weight: 1, Reason: "writes the clipboard"},
+	"contentSettings":       {Weight: 2, Reason: "changes site permissions"},
+	"cookies":               {Weight: 2, Reason: "reads and changes cookies"},
+	"debugger":              {Weight: 5, Reason: "attaches the debugger to tabs"},
+	"declarativeNetRequest": {Weight: 1, Reason: "blocks or modifies requests by rules"},
+	"downloads":             {Weight: 1, Reason: "manages downloads"},
+	"history":               {Weight: 2, Reason: "reads and changes browsing history"},
+	"management":            {Weight: 2, Reason: "manages other extensions"},
+	"nativeMessaging":       {Weight: 4, Reason: "communicates with native applications"},
+	"privacy":               {Weight: 2, Reason: "changes privacy settings"},
+	"proxy":                 {Weight: 4, Reason: "routes traffic through a proxy"},
+	"scripting":             {Weight: 2, Reason: "injects scripts into pages"},
+	"tabs":                  {Weight: 1, Reason: "reads the URLs and titles of tabs"},
+	"webNavigation":         {Weight: 1, Reason: "observes navigations"},
+	"webRequest":            {Weight: 3, Reason: "observes network requests"},
+	"webRequestBlocking":    {Weight: 2, Reason: "blocks or modifies network requests"},
+}
+
+// Weights of host access, content scripts, and content security policy.
+const (
+	broadHostWeight    = 5
+	broadScriptWeight  = 2 // in addition to broadHostWeight
+	unsafeEvalWeight   = 2
+	unsafeEvalCSPToken = "'unsafe-eval'"
+)
+
+// Risk scores the permissions requested by the manifest.
+func (m *Manifest) Risk() Risk {
+	r := ScoreRisk(m.Required())
+	for _, cs := range m.ContentScripts {
+		if matchesAll(cs.Matches) {
+			r.add(RiskFactor{Permission: "content_scripts", Weight: broadScriptWeight,
+				Reason: "injects scripts into every page"})
+			break
+		}
+	}
+	csp := m.ContentSecurityPolicy
+	if strings.Contains(csp.ExtensionPages, unsafeEvalCSPToken) || strings.Contains(csp.Sandbox, unsafeEvalCSPToken) {
+		r.add(RiskFactor{Permission: "content_security_policy", Weight: unsafeEvalWeight,
+			Reason: "allows eval in extension pages"})
+	}
+	r.finish()
+	return r
+}
+
+// ScoreRisk scores a set of permissions, such as the permissions
+// granted to an extension in browser preferences.
+func ScoreRisk(perms Permissions) Risk {
+	var r Risk
+	for _, p := range perms.API {
+		if f, ok := apiRisks[p]; ok {
+			f.Permission = p
+			r.add(f)
+		}
+	}
+	for _, h := range perms.Hosts {
+		if IsBroadPattern(h) {
+			r.add(RiskFactor{Permission: h, Weight: broadHostWeight, Reason: "accesses every site"})
+			break
+		}
+	}
+	r.finish()
+	return r
+}
+
+func (r *Risk) add(f RiskFactor) {
+	r.Factors = append(r.Factors, f)
+	r.Score += f.Weight
+}
+
+// finish orders the factors and sets the level from the score.
+func (r *Risk) finish() {
+	sort.SliceStable(r.Factors, func(i, j int) bool {
+		return r.Factors[i].Weight > r.Factors[j].Weight
+	})
+	switch {
+	case r.Score >= riskHighScore:
+		r.Level = RiskHigh
+	case r.Score >= riskMediumScore:
+		r.Level = RiskMedium
+	default:
+		r.Level = RiskLow
+	}
+}
+
+func matchesAll(patterns []string) bool {
+	for _, p := range patterns {
+		if IsBroadPattern(NormalizePattern(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (level RiskLevel) String() string {
+	switch level {
+	case RiskLow:
+		return "low"
+	case RiskMedium:
+		return "medium"
+	case RiskHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("level(%d)", uint8(level))
+	}
+}
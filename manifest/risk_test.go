@@ -0,0 +1,53 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRisk(t *testing.T) {
+	m := &Manifest{
+		ManifestVersion: 2,
+		Permissions:     []string{"webRequest", "webRequestBlocking", "storage", "*://*/*"},
+		ContentScripts:  []ContentScript{{Matches: []string{"<all_urls>"}}},
+		ContentSecurityPolicy: ContentSecurityPolicy{
+			ExtensionPages: "script-src 'self' 'unsafe-eval'; object-src 'self'",
+		},
+	}
+	r := m.Risk()
+	want := Risk{Score: 14, Level: RiskHigh, Factors: []RiskFactor{
+		{"*://*/*", 5, "accesses every site"},
+		{"webRequest", 3, "observes network requests"},
+		{"webRequestBlocking", 2, "blocks or modifies network requests"},
+		{"content_scripts", 2, "injects scripts into every page"},
+		{"content_security_policy", 2, "allows eval in extension pages"},
+	}}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", r, want)
+	}
+
+	tests := []struct {
+		Perms []string
+		Score int
+		Level RiskLevel
+	}{
+		{nil, 0, RiskLow},
+		{[]string{"storage", "https://example.com/*"}, 0, RiskLow},
+		{[]string{"clipboardWrite", "tabs"}, 2, RiskLow},
+		{[]string{"clipboardRead"}, 3, RiskMedium},
+		{[]string{"https://*/*", "cookies"}, 7, RiskMedium},
+		{[]string{"nativeMessaging", "proxy"}, 8, RiskHigh},
+	}
+	for _, tt := range tests {
+		r := ScoreRisk(NewPermissions(tt.Perms))
+		if r.Score != tt.Score || r.Level != tt.Level {
+			t.Errorf("%q: got score %d (%s), want %d (%s)", tt.Perms, r.Score, r.Level, tt.Score, tt.Level)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package browser holds the options shared by the parsers and walkers
+// in its subpackages, which parse and convert data from Firefox- and
+// Chromium-based browsers.
+package browser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Options configures how a parser or walker handles errors. The zero
+// value collects errors and continues.
+type Options struct {
+	OnError ErrorPolicy
+}
+
+// ErrorPolicy is how a parser or walker handles an error in a record or
+// in a file as a whole. Parsers that read files as a whole treat record
+// errors as file errors.
+type ErrorPolicy uint8
+
+// Values for ErrorPolicy:
+const (
+	// Collect skips the record or file and continues, then returns the
+	// data that was read with all errors as Errors.
+	Collect ErrorPolicy = iota
+	// FailFast stops at the first error and returns it.
+	FailFast
+	// SkipRecord silently skips the record, or the file for errors in a
+	// file as a whole.
+	SkipRecord
+	// SkipFile silently skips the file, including the records already
+	// read from it.
+	SkipFile
+)
+
+// ParseErrorPolicy parses the name of an ErrorPolicy, as returned by
+// its String method.
+func ParseErrorPolicy(name string) (ErrorPolicy, error) {
+	for p := Collect; p <= SkipFile; p++ {
+		if p.String() == name {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("browser: unknown error policy %q", name)
+}
+
+// Error is an error in a file or in a record of a file.
+type Error struct {
+	File   string // path of the file
+	Record int    // index of the record, starting at 1, or 0 for the file
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Record == 0 {
+		return e.File + ": " + e.Err.Error()
+	}
+	return fmt.Sprintf("%s: record %d: %v", e.File, e.Record, e.Err)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Errors is the errors collected under Collect, in the order that they
+// occurred.
+type Errors []*Error
+
+func (errs Errors) Error() string {
+	switch len(errs) {
+	case 0:
+		return "no errors"
+	case 1:
+		return errs[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d errors:", len(errs))
+	for _, err := range errs {
+		b.WriteString("\n\t")
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns the errors, so that errors.Is and errors.As match any
+// of them.
+func (errs Errors) Unwrap() []error {
+	unwrapped := make([]error, len(errs))
+	for i, err := range errs {
+		unwrapped[i] = err
+	}
+	return unwrapped
+}
+
+// ErrorHandler applies an ErrorPolicy while parsing.
+type ErrorHandler struct {
+	policy ErrorPolicy
+	errs   Errors
+}
+
+// Handler returns a handler that applies the error policy of o.
+func (o Options) Handler() *ErrorHandler {
+	return &ErrorHandler{policy: o.OnError}
+}
+
+// File handles an error in file as a whole. It returns a non-nil error
+// when parsing should stop, and otherwise the file is skipped.
+func (h *ErrorHandler) File(file string, err error) error {
+	return h.handle(&Error{File: file, Err: err})
+}
+
+// Record handles an error in a record of file. It returns a non-nil
+// error when parsing should stop, and otherwise skipFile reports
+// whether the rest of the file and the records already read from it
+// are dropped, or else only the record is skipped.
+func (h *ErrorHandler) Record(file string, record int, err error) (skipFile bool, stop error) {
+	if err := h.handle(&Error{File: file, Record: record, Err: err}); err != nil {
+		return true, err
+	}
+	return h.policy == SkipFile, nil
+}
+
+// Merge handles the error returned by a parser that was called with the
+// same options, such as a profile-level parser that reads a file with
+// another. The errors that it collected are collected by h, and any
+// other error is returned, as parsing should stop.
+func (h *ErrorHandler) Merge(err error) error {
+	var errs Errors
+	if errors.As(err, &errs) {
+		h.errs = append(h.errs, errs...)
+		return nil
+	}
+	return err
+}
+
+func (h *ErrorHandler) handle(err *Error) error {
+	switch h.policy {
+	case FailFast:
+		return err
+	case Collect:
+		h.errs = append(h.errs, err)
+	}
+	return nil
+}
+
+// Err returns the errors collected under Collect, or nil when there are
+// none.
+func (h *ErrorHandler) Err() error {
+	if len(h.errs) == 0 {
+		return nil
+	}
+	return h.errs
+}
+
+func (p ErrorPolicy) String() string {
+	switch p {
+	case Collect:
+		return "collect"
+	case FailFast:
+		return "fail-fast"
+	case SkipRecord:
+		return "skip-record"
+	case SkipFile:
+		return "skip-file"
+	default:
+		return fmt.Sprintf("policy(%d)", uint8(p))
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package browser
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestErrorHandler(t *testing.T) {
+	for _, tt := range []struct {
+		policy   ErrorPolicy
+		stop     bool
+		skipFile bool
+		errs     int
+	}{
+		{Collect, false, false, 2},
+		{FailFast, true, true, 0},
+		{SkipRecord, false, false, 0},
+		{SkipFile, false, true, 0},
+	} {
+		h := Options{OnError: tt.policy}.Handler()
+		skipFile, stop := h.Record("a.tsv", 3, io.ErrUnexpectedEOF)
+		if (stop != nil) != tt.stop || skipFile != tt.skipFile {
+			t.Errorf("%s: got skip file %t, stop %v", tt.policy, skipFile, stop)
+		}
+		if err := h.File("b.json", io.EOF); (err != nil) != tt.stop {
+			t.Errorf("%s: got file error %v", tt.policy, err)
+		}
+		var errs Errors
+		if errors.As(h.Err(), &errs); len(errs) != tt.errs {
+			t.Errorf("%s: got errors %v, want %d", tt.policy, h.Err(), tt.errs)
+		}
+		if tt.errs != 0 && (!errors.Is(errs[0], io.ErrUnexpectedEOF) || errs[0].Error() != "a.tsv: record 3: unexpected EOF") {
+			t.Errorf("%s: got first error %v", tt.policy, errs[0])
+		}
+		if p, err := ParseErrorPolicy(tt.policy.String()); err != nil || p != tt.policy {
+			t.Errorf("%s: parsed as %s, %v", tt.policy, p, err)
+		}
+	}
+}
+
+func TestErrorHandlerMerge(t *testing.T) {
+	inner := Options{}.Handler()
+	inner.File("a.json", io.EOF)
+	inner.File("b.json", io.ErrUnexpectedEOF)
+	h := Options{}.Handler()
+	h.File("c.json", io.ErrClosedPipe)
+	if err := h.Merge(inner.Err()); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if err := h.Merge(nil); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	var errs Errors
+	if errors.As(h.Err(), &errs); len(errs) != 3 || errs[1].File != "a.json" {
+		t.Errorf("got errors %v", h.Err())
+	}
+	if !errors.Is(h.Err(), io.ErrUnexpectedEOF) {
+		t.Errorf("errors.Is does not match a collected error")
+	}
+	if err := h.Merge(io.ErrShortWrite); err != io.ErrShortWrite {
+		t.Errorf("got error %v, want %v", err, io.ErrShortWrite)
+	}
+}
@@ -0,0 +1,432 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package privacy audits the privacy-relevant settings of browser
+// profiles, such as Do Not Track, the third-party cookie policy,
+// HTTPS-Only Mode, and telemetry, and normalizes them, so that the
+// settings of Chrome and Firefox profiles can be compared side by side.
+// Per-site exceptions to the settings are audited too.
+package privacy
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/firefox"
+)
+
+// Audit is the privacy settings of a browser profile, with a setting
+// for each name in Names, in that order.
+type Audit struct {
+	Browser    string // e.g. "chrome" or "firefox"
+	Profile    string // profile directory
+	Settings   []Setting
+	Exceptions []Exception // ordered by name, then site
+}
+
+// Setting is a privacy setting, normalized across browsers.
+type Setting struct {
+	Name    string // one of Names
+	Value   string // normalized value, e.g. ValueOn, or ValueUnsupported
+	Default bool   // not changed from the browser default
+	Pref    string // preference that it was read from, e.g. "privacy.donottrackheader.enabled"
+}
+
+// Exception is a per-site override of a setting.
+type Exception struct {
+	Name    string // one of Names
+	Site    string // origin or host, e.g. "https://example.com" or "example.com"
+	Value   string // normalized value
+	Private bool   // only in private windows
+	Source  string // store that it was read from, e.g. "permissions.sqlite"
+}
+
+// Names of settings:
+const (
+	DoNotTrack           = "do_not_track"           // send the DNT header
+	GlobalPrivacyControl = "global_privacy_control" // send the Sec-GPC header
+	ThirdPartyCookies    = "third_party_cookies"    // policy for cookies in third-party contexts
+	CookieBanners        = "cookie_banners"         // automatically handle cookie consent banners
+	TrackingProtection   = "tracking_protection"    // block trackers and fingerprinters
+	HTTPSOnly            = "https_only"             // upgrade connections to HTTPS, or warn
+	Telemetry            = "telemetry"              // upload usage and technical data
+	Studies              = "studies"                // install and run studies or field trials
+	CrashReports         = "crash_reports"          // send crash reports automatically
+	SafeBrowsing         = "safe_browsing"          // check URLs and downloads against block lists
+)
+
+// Names are the names of settings in the order audited.
+var Names = []string{
+	DoNotTrack,
+	GlobalPrivacyControl,
+	ThirdPartyCookies,
+	CookieBanners,
+	TrackingProtection,
+	HTTPSOnly,
+	Telemetry,
+	Studies,
+	CrashReports,
+	SafeBrowsing,
+}
+
+// Normalized values:
+const (
+	ValueOn          = "on"
+	ValueOff         = "off"
+	ValueUnsupported = "n/a"
+
+	// Values for ThirdPartyCookies:
+	CookiesAllow           = "allow"
+	CookiesBlockPrivate    = "block-in-private" // blocked only in private windows
+	CookiesBlockTrackers   = "block-trackers"
+	CookiesPartition       = "partition"       // partitioned by top-level site, and trackers blocked
+	CookiesBlockUnvisited  = "block-unvisited" // blocked for sites not visited at the top level
+	CookiesBlockThirdParty = "block-third-party"
+	CookiesBlockAll        = "block-all"
+	CookiesSession         = "session" // cleared when the browser closes; only for exceptions
+
+	// Values for CookieBanners:
+	CookieBannersReject         = "reject"
+	CookieBannersRejectOrAccept = "reject-or-accept" // accept when there is no reject option
+	CookieBannersPrivate        = "private"          // reject, only in private windows
+
+	// Values for TrackingProtection:
+	TrackingProtectionStandard = "standard"
+	TrackingProtectionStrict   = "strict"
+	TrackingProtectionCustom   = "custom"
+
+	// Values for HTTPSOnly:
+	HTTPSOnlyPrivate = "private" // only in private windows
+
+	// Values for SafeBrowsing:
+	SafeBrowsingEnhanced = "enhanced"
+)
+
+// Browsers for Audit:
+const (
+	BrowserChrome  = "chrome"
+	BrowserFirefox = "firefox"
+)
+
+// firefoxCookieBehaviors are the values of network.cookie.cookieBehavior.
+// https://searchfox.org/mozilla-central/source/netwerk/cookie/nsICookieService.idl
+var firefoxCookieBehaviors = []string{
+	0: CookiesAllow,
+	1: CookiesBlockThirdParty,
+	2: CookiesBlockAll,
+	3: CookiesBlockUnvisited,
+	4: CookiesBlockTrackers,
+	5: CookiesPartition,
+}
+
+// FromFirefox audits the preferences in prefs.js of a Firefox profile.
+// Preferences that are not set have the defaults of current versions
+// of Firefox.
+//
+// Preference defaults:
+// https://searchfox.org/mozilla-central/source/modules/libpref/init/all.js
+// https://searchfox.org/mozilla-central/source/browser/app/profile/firefox.js
+func FromFirefox(prefs firefox.Prefs, profileDir string) *Audit {
+	a := &Audit{Browser: BrowserFirefox, Profile: profileDir}
+	a.add(firefoxBool(prefs, DoNotTrack, "privacy.donottrackheader.enabled", false))
+	a.add(firefoxBool(prefs, GlobalPrivacyControl, "privacy.globalprivacycontrol.enabled", false))
+
+	cookies := Setting{Name: ThirdPartyCookies, Pref: "network.cookie.cookieBehavior"}
+	n := 5
+	behavior, set := firefoxValue(prefs, cookies.Pref)
+	if set {
+		if i, ok := behavior.(int); ok {
+			n = i
+		} else {
+			n = -1
+		}
+	}
+	if n >= 0 && n < len(firefoxCookieBehaviors) {
+		cookies.Value = firefoxCookieBehaviors[n]
+	} else {
+		cookies.Value = fmt.Sprintf("unknown(%v)", behavior)
+	}
+	cookies.Default = n == 5
+	a.add(cookies)
+
+	banners := Setting{Name: CookieBanners, Value: ValueOff, Default: true, Pref: "cookiebanners.service.mode"}
+	if v, _ := firefoxValue(prefs, banners.Pref); v != nil {
+		banners.Value = firefoxCookieBannerMode(v)
+		banners.Default = banners.Value == ValueOff
+	}
+	if banners.Value == ValueOff {
+		pbm := "cookiebanners.service.mode.privateBrowsing"
+		if v, _ := firefoxValue(prefs, pbm); v != nil && v != 0 {
+			banners = Setting{Name: CookieBanners, Value: CookieBannersPrivate, Pref: pbm}
+		}
+	}
+	a.add(banners)
+
+	tracking := Setting{Name: TrackingProtection, Value: TrackingProtectionStandard, Default: true,
+		Pref: "browser.contentblocking.category"}
+	if v, _ := firefoxValue(prefs, tracking.Pref); v != nil {
+		tracking.Value = fmt.Sprint(v)
+		tracking.Default = tracking.Value == TrackingProtectionStandard
+	}
+	a.add(tracking)
+
+	https := firefoxBool(prefs, HTTPSOnly, "dom.security.https_only_mode", false)
+	if https.Value == ValueOff {
+		if pbm := firefoxBool(prefs, HTTPSOnly, "dom.security.https_only_mode_pbm", false); pbm.Value == ValueOn {
+			https = Setting{Name: HTTPSOnly, Value: HTTPSOnlyPrivate, Pref: pbm.Pref}
+		}
+	}
+	a.add(https)
+
+	a.add(firefoxBool(prefs, Telemetry, "datareporting.healthreport.uploadEnabled", true))
+	a.add(firefoxBool(prefs, Studies, "app.shield.optoutstudies.enabled", true))
+	a.add(firefoxBool(prefs, CrashReports, "browser.crashReports.unsubmittedCheck.autoSubmit2", false))
+	a.add(firefoxBool(prefs, SafeBrowsing, "browser.safebrowsing.malware.enabled", true))
+	return a
+}
+
+// AddFirefoxExceptions adds the per-site exceptions in
+// permissions.sqlite and content-prefs.sqlite of a Firefox profile:
+// sites with tracking protection disabled, HTTPS-Only Mode exceptions,
+// cookie permissions, and cookie banner handling modes. Other
+// permissions are ignored. perms and contentPrefs may be nil.
+func (a *Audit) AddFirefoxExceptions(perms []firefox.Permission, contentPrefs *firefox.ContentPrefs) {
+	for _, p := range perms {
+		e := Exception{Site: p.Origin, Source: firefox.PermissionsFile,
+			Private: p.OriginAttributes != nil && p.OriginAttributes.PrivateBrowsingID != 0}
+		switch p.Type {
+		case firefox.PermissionTrackingProtection, firefox.PermissionTrackingProtectionPB:
+			if p.Permission != firefox.PermissionAllow {
+				continue
+			}
+			e.Name, e.Value = TrackingProtection, ValueOff
+			e.Private = e.Private || p.Type == firefox.PermissionTrackingProtectionPB
+		case firefox.PermissionHTTPSOnlyLoadInsecure:
+			switch p.Permission {
+			case firefox.PermissionAllow, firefox.PermissionHTTPSOnlyAllowSession, firefox.PermissionHTTPSFirstAllow:
+				e.Value = ValueOff
+			case firefox.PermissionDeny:
+				e.Value = ValueOn
+			default:
+				continue
+			}
+			e.Name = HTTPSOnly
+		case firefox.PermissionCookie:
+			switch p.Permission {
+			case firefox.PermissionAllow:
+				e.Value = CookiesAllow
+			case firefox.PermissionDeny:
+				e.Value = CookiesBlockAll
+			case firefox.PermissionCookieSession:
+				e.Value = CookiesSession
+			default:
+				continue
+			}
+			e.Name = ThirdPartyCookies
+		default:
+			continue
+		}
+		a.Exceptions = append(a.Exceptions, e)
+	}
+	if contentPrefs != nil {
+		for site, mode := range contentPrefs.CookieBannerModes() {
+			a.Exceptions = append(a.Exceptions, Exception{Name: CookieBanners, Site: site,
+				Value: firefoxCookieBannerMode(int(mode)), Source: firefox.ContentPrefsFile})
+		}
+	}
+	a.sortExceptions()
+}
+
+// firefoxCookieBannerMode normalizes a firefox.CookieBannerMode.
+func firefoxCookieBannerMode(v interface{}) string {
+	switch v {
+	case int(firefox.CookieBannerDisabled):
+		return ValueOff
+	case int(firefox.CookieBannerReject):
+		return CookieBannersReject
+	case int(firefox.CookieBannerRejectOrAccept):
+		return CookieBannersRejectOrAccept
+	default:
+		return fmt.Sprintf("unknown(%v)", v)
+	}
+}
+
+// firefoxValue returns the effective value of a preference and whether
+// it is set.
+func firefoxValue(prefs firefox.Prefs, name string) (interface{}, bool) {
+	p, ok := prefs[name]
+	if !ok {
+		return nil, false
+	}
+	v := p.Value()
+	return v, v != nil
+}
+
+func firefoxBool(prefs firefox.Prefs, name, pref string, def bool) Setting {
+	v, _ := firefoxValue(prefs, pref)
+	b, ok := v.(bool)
+	if !ok {
+		b = def
+	}
+	return Setting{Name: name, Value: onOff(b), Default: b == def, Pref: pref}
+}
+
+// chromeCookieControlsModes are the values of
+// profile.cookie_controls_mode.
+// https://source.chromium.org/chromium/chromium/src/+/master:components/content_settings/core/common/cookie_controls_enforcement.h
+var chromeCookieControlsModes = []string{
+	0: CookiesAllow,
+	1: CookiesBlockThirdParty,
+	2: CookiesBlockPrivate,
+}
+
+// FromChrome audits the preferences of a Chrome profile, as read by
+// chrome.ProfilePrefsSnapshot, and Local State, which has the settings
+// for the browser as a whole, such as metrics reporting. localState may
+// be nil. Preferences that are not set have the defaults of current
+// versions of Chrome.
+//
+// Preference names:
+// https://source.chromium.org/chromium/chromium/src/+/master:chrome/common/pref_names.cc
+func FromChrome(prefs, localState chrome.PrefsSnapshot, profileDir string) *Audit {
+	a := &Audit{Browser: BrowserChrome, Profile: profileDir}
+	a.add(chromeBool(prefs, DoNotTrack, "enable_do_not_track", false))
+	a.add(Setting{Name: GlobalPrivacyControl, Value: ValueUnsupported, Default: true})
+
+	cookies := Setting{Name: ThirdPartyCookies, Pref: "profile.cookie_controls_mode", Value: CookiesBlockPrivate, Default: true}
+	if v, ok := prefs.Lookup(cookies.Pref); ok {
+		n, ok := v.(float64)
+		if i := int(n); ok && float64(i) == n && i >= 0 && i < len(chromeCookieControlsModes) {
+			cookies.Value = chromeCookieControlsModes[i]
+		} else {
+			cookies.Value = fmt.Sprintf("unknown(%v)", v)
+		}
+		cookies.Default = cookies.Value == CookiesBlockPrivate
+	}
+	// The older setting and the default content setting for cookies
+	// take precedence.
+	if v, ok := prefs.Lookup("profile.block_third_party_cookies"); ok && v == true {
+		cookies = Setting{Name: ThirdPartyCookies, Value: CookiesBlockThirdParty, Pref: "profile.block_third_party_cookies"}
+	}
+	if v, ok := prefs.Lookup("profile.default_content_setting_values.cookies"); ok && v == float64(2) {
+		cookies = Setting{Name: ThirdPartyCookies, Value: CookiesBlockAll, Pref: "profile.default_content_setting_values.cookies"}
+	}
+	a.add(cookies)
+
+	a.add(Setting{Name: CookieBanners, Value: ValueUnsupported, Default: true})
+	a.add(Setting{Name: TrackingProtection, Value: ValueUnsupported, Default: true})
+	a.add(chromeBool(prefs, HTTPSOnly, "https_only_mode_enabled", false))
+	a.add(chromeBool(localState, Telemetry, "user_experience_metrics.reporting_enabled", false))
+	a.add(Setting{Name: Studies, Value: ValueUnsupported, Default: true})
+	// Crash reports are sent with metrics reporting.
+	a.add(chromeBool(localState, CrashReports, "user_experience_metrics.reporting_enabled", false))
+
+	safe := chromeBool(prefs, SafeBrowsing, "safebrowsing.enabled", true)
+	if enhanced := chromeBool(prefs, SafeBrowsing, "safebrowsing.enhanced", false); safe.Value == ValueOn && enhanced.Value == ValueOn {
+		safe = Setting{Name: SafeBrowsing, Value: SafeBrowsingEnhanced, Pref: enhanced.Pref}
+	}
+	a.add(safe)
+	return a
+}
+
+func chromeBool(prefs chrome.PrefsSnapshot, name, pref string, def bool) Setting {
+	b := def
+	if v, ok := prefs.Lookup(pref); ok {
+		if vb, ok := v.(bool); ok {
+			b = vb
+		}
+	}
+	return Setting{Name: name, Value: onOff(b), Default: b == def, Pref: pref}
+}
+
+func onOff(b bool) string {
+	if b {
+		return ValueOn
+	}
+	return ValueOff
+}
+
+func (a *Audit) add(s Setting) {
+	a.Settings = append(a.Settings, s)
+}
+
+// sortExceptions orders the exceptions by the order of their names in
+// Names, then by site.
+func (a *Audit) sortExceptions() {
+	order := make(map[string]int, len(Names))
+	for i, name := range Names {
+		order[name] = i
+	}
+	sort.SliceStable(a.Exceptions, func(i, j int) bool {
+		ei, ej := &a.Exceptions[i], &a.Exceptions[j]
+		if ei.Name != ej.Name {
+			return order[ei.Name] < order[ej.Name]
+		}
+		if ei.Site != ej.Site {
+			return ei.Site < ej.Site
+		}
+		return !ei.Private && ej.Private
+	})
+}
+
+// Setting returns the setting with the name, or nil when it is not
+// audited.
+func (a *Audit) Setting(name string) *Setting {
+	for i := range a.Settings {
+		if a.Settings[i].Name == name {
+			return &a.Settings[i]
+		}
+	}
+	return nil
+}
+
+// WriteTable writes the audits as a table with a row for each setting
+// and a column for each audit. Values changed from the browser default
+// are marked with "*".
+func WriteTable(w io.Writer, audits ...*Audit) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprint(tw, "setting")
+	for _, a := range audits {
+		fmt.Fprintf(tw, "\t%s %s", a.Browser, a.Profile)
+	}
+	fmt.Fprintln(tw)
+	for _, name := range Names {
+		fmt.Fprint(tw, name)
+		for _, a := range audits {
+			value := ValueUnsupported
+			if s := a.Setting(name); s != nil {
+				value = s.Value
+				if !s.Default {
+					value += "*"
+				}
+			}
+			fmt.Fprintf(tw, "\t%s", value)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// WriteExceptions writes the per-site exceptions of the audits as a
+// table with a row for each exception. Exceptions only in private
+// windows are marked with "(private)".
+func WriteExceptions(w io.Writer, audits ...*Audit) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "browser\tprofile\tsetting\tsite\tvalue")
+	for _, a := range audits {
+		for _, e := range a.Exceptions {
+			value := e.Value
+			if e.Private {
+				value += " (private)"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", a.Browser, a.Profile, e.Name, e.Site, value)
+		}
+	}
+	return tw.Flush()
+}
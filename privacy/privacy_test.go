@@ -0,0 +1,145 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package privacy
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/firefox"
+)
+
+func TestFromFirefox(t *testing.T) {
+	prefs := firefox.Prefs{
+		"privacy.donottrackheader.enabled":         {User: true},
+		"network.cookie.cookieBehavior":            {Default: 5, User: 1},
+		"dom.security.https_only_mode_pbm":         {User: true},
+		"datareporting.healthreport.uploadEnabled": {User: false},
+		"app.shield.optoutstudies.enabled":         {Default: true, User: false, Locked: true},
+		"cookiebanners.service.mode":               {User: 2},
+		"browser.contentblocking.category":         {Default: "standard", User: "strict"},
+	}
+	got := FromFirefox(prefs, "default")
+	want := &Audit{Browser: BrowserFirefox, Profile: "default", Settings: []Setting{
+		{DoNotTrack, ValueOn, false, "privacy.donottrackheader.enabled"},
+		{GlobalPrivacyControl, ValueOff, true, "privacy.globalprivacycontrol.enabled"},
+		{ThirdPartyCookies, CookiesBlockThirdParty, false, "network.cookie.cookieBehavior"},
+		{CookieBanners, CookieBannersRejectOrAccept, false, "cookiebanners.service.mode"},
+		{TrackingProtection, TrackingProtectionStrict, false, "browser.contentblocking.category"},
+		{HTTPSOnly, HTTPSOnlyPrivate, false, "dom.security.https_only_mode_pbm"},
+		{Telemetry, ValueOff, false, "datareporting.healthreport.uploadEnabled"},
+		{Studies, ValueOn, true, "app.shield.optoutstudies.enabled"},
+		{CrashReports, ValueOff, true, "browser.crashReports.unsubmittedCheck.autoSubmit2"},
+		{SafeBrowsing, ValueOn, true, "browser.safebrowsing.malware.enabled"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got, want)
+	}
+}
+
+func TestFromChrome(t *testing.T) {
+	var prefs, localState chrome.PrefsSnapshot
+	if err := json.Unmarshal([]byte(`{
+		"enable_do_not_track": true,
+		"profile": {"cookie_controls_mode": 1},
+		"safebrowsing": {"enabled": true, "enhanced": true}
+	}`), &prefs); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(`{
+		"user_experience_metrics": {"reporting_enabled": true}
+	}`), &localState); err != nil {
+		t.Fatal(err)
+	}
+	got := FromChrome(prefs, localState, "Default")
+	want := &Audit{Browser: BrowserChrome, Profile: "Default", Settings: []Setting{
+		{DoNotTrack, ValueOn, false, "enable_do_not_track"},
+		{GlobalPrivacyControl, ValueUnsupported, true, ""},
+		{ThirdPartyCookies, CookiesBlockThirdParty, false, "profile.cookie_controls_mode"},
+		{CookieBanners, ValueUnsupported, true, ""},
+		{TrackingProtection, ValueUnsupported, true, ""},
+		{HTTPSOnly, ValueOff, true, "https_only_mode_enabled"},
+		{Telemetry, ValueOn, false, "user_experience_metrics.reporting_enabled"},
+		{Studies, ValueUnsupported, true, ""},
+		{CrashReports, ValueOn, false, "user_experience_metrics.reporting_enabled"},
+		{SafeBrowsing, SafeBrowsingEnhanced, false, "safebrowsing.enhanced"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got, want)
+	}
+
+	var b bytes.Buffer
+	if err := WriteTable(&b, got, FromFirefox(nil, "default")); err != nil {
+		t.Fatal(err)
+	}
+	table := `setting                 chrome Default      firefox default
+do_not_track            on*                 off
+global_privacy_control  n/a                 off
+third_party_cookies     block-third-party*  partition
+cookie_banners          n/a                 off
+tracking_protection     n/a                 standard
+https_only              off                 off
+telemetry               on*                 on
+studies                 n/a                 on
+crash_reports           on*                 off
+safe_browsing           enhanced*           on
+`
+	if b.String() != table {
+		t.Errorf("got table:\n%s\nwant:\n%s", b.String(), table)
+	}
+}
+
+func TestAddFirefoxExceptions(t *testing.T) {
+	prefs := firefox.Prefs{"cookiebanners.service.mode.privateBrowsing": {User: 1}}
+	a := FromFirefox(prefs, "default")
+	if s := a.Setting(CookieBanners); s.Value != CookieBannersPrivate || s.Default {
+		t.Errorf("got cookie banners %+v", s)
+	}
+	perms := []firefox.Permission{
+		{Origin: "https://example.com", OriginAttributes: &firefox.OriginAttributes{},
+			Type: firefox.PermissionTrackingProtection, Permission: firefox.PermissionAllow},
+		{Origin: "https://example.org", OriginAttributes: &firefox.OriginAttributes{PrivateBrowsingID: 1},
+			Type: firefox.PermissionTrackingProtection, Permission: firefox.PermissionAllow},
+		{Origin: "http://example.net", Type: firefox.PermissionHTTPSOnlyLoadInsecure,
+			Permission: firefox.PermissionHTTPSOnlyAllowSession},
+		{Origin: "https://example.com", Type: firefox.PermissionCookie, Permission: firefox.PermissionCookieSession},
+		{Origin: "https://example.com", Type: "geo", Permission: firefox.PermissionAllow},
+	}
+	contentPrefs := &firefox.ContentPrefs{Sites: []firefox.SiteContentPrefs{{
+		Site:  "example.net",
+		Prefs: []firefox.ContentPref{{Setting: firefox.ContentPrefCookieBanner, Value: int64(0)}},
+	}}}
+	a.AddFirefoxExceptions(perms, contentPrefs)
+	want := []Exception{
+		{ThirdPartyCookies, "https://example.com", CookiesSession, false, firefox.PermissionsFile},
+		{CookieBanners, "example.net", ValueOff, false, firefox.ContentPrefsFile},
+		{TrackingProtection, "https://example.com", ValueOff, false, firefox.PermissionsFile},
+		{TrackingProtection, "https://example.org", ValueOff, true, firefox.PermissionsFile},
+		{HTTPSOnly, "http://example.net", ValueOff, false, firefox.PermissionsFile},
+	}
+	if !reflect.DeepEqual(a.Exceptions, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", a.Exceptions, want)
+	}
+
+	var b bytes.Buffer
+	if err := WriteExceptions(&b, a); err != nil {
+		t.Fatal(err)
+	}
+	table := `browser  profile  setting              site                 value
+firefox  default  third_party_cookies  https://example.com  session
+firefox  default  cookie_banners       example.net          off
+firefox  default  tracking_protection  https://example.com  off
+firefox  default  tracking_protection  https://example.org  off (private)
+firefox  default  https_only           http://example.net   off
+`
+	if b.String() != table {
+		t.Errorf("got table:\n%s\nwant:\n%s", b.String(), table)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package protoutil provides utilities for decoding protocol buffer
+// messages without generated code.
+package protoutil
+
+import (
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field is a single field in an encoded message. Only the value
+// corresponding to the wire type is set.
+type Field struct {
+	Num     protowire.Number
+	Type    protowire.Type
+	Varint  uint64 // VarintType, Fixed32Type, and Fixed64Type
+	Bytes   []byte // BytesType
+	Message string // name of the enclosing message, for errors
+}
+
+// Walk calls fn for each field in the encoded message b, in the order
+// encountered. Groups are not supported.
+func Walk(b []byte, message string, fn func(f *Field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("protoutil: %s: %w", message, protowire.ParseError(n))
+		}
+		b = b[n:]
+		f := Field{Num: num, Type: typ, Message: message}
+		switch typ {
+		case protowire.VarintType:
+			f.Varint, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.Varint = uint64(v)
+		case protowire.Fixed64Type:
+			f.Varint, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			f.Bytes, n = protowire.ConsumeBytes(b)
+		default:
+			return fmt.Errorf("protoutil: %s: field %d has unsupported wire type %d", message, num, typ)
+		}
+		if n < 0 {
+			return fmt.Errorf("protoutil: %s: field %d: %w", message, num, protowire.ParseError(n))
+		}
+		b = b[n:]
+		if err := fn(&f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Int64 returns the field as an int64.
+func (f *Field) Int64() int64 { return int64(f.Varint) }
+
+// Int returns the field as an int.
+func (f *Field) Int() int { return int(int32(f.Varint)) }
+
+// Bool returns the field as a bool.
+func (f *Field) Bool() bool { return f.Varint != 0 }
+
+// Double returns the field as a float64.
+func (f *Field) Double() float64 { return math.Float64frombits(f.Varint) }
+
+// String returns the field as a string.
+func (f *Field) String() string { return string(f.Bytes) }
+
+// PackedInts decodes the field as a packed repeated varint field.
+func (f *Field) PackedInts() ([]int, error) {
+	var ints []int
+	b := f.Bytes
+	for len(b) > 0 {
+		v, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return nil, fmt.Errorf("protoutil: %s: field %d: %w", f.Message, f.Num, protowire.ParseError(n))
+		}
+		ints = append(ints, int(int32(v)))
+		b = b[n:]
+	}
+	return ints, nil
+}
+
+// Unknown returns an error for a field that is not known. This is to
+// ensure no data loss until all fields have been determined.
+func (f *Field) Unknown() error {
+	return fmt.Errorf("protoutil: %s: unknown field %d with wire type %d", f.Message, f.Num, f.Type)
+}
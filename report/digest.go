@@ -0,0 +1,378 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package report
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Period is a calendar week, starting on Monday, or month.
+type Period struct {
+	Start time.Time // midnight on the first day, in the location of the period
+	Unit  PeriodUnit
+}
+
+// PeriodUnit is the length of a Period.
+type PeriodUnit uint8
+
+// Values for PeriodUnit:
+const (
+	PeriodWeek PeriodUnit = iota
+	PeriodMonth
+)
+
+// WeekOf returns the week, starting on Monday, that contains t, in the
+// location of t.
+func WeekOf(t time.Time) Period {
+	y, m, d := t.Date()
+	offset := (int(t.Weekday()) + 6) % 7
+	return Period{time.Date(y, m, d-offset, 0, 0, 0, 0, t.Location()), PeriodWeek}
+}
+
+// MonthOf returns the month that contains t, in the location of t.
+func MonthOf(t time.Time) Period {
+	y, m, _ := t.Date()
+	return Period{time.Date(y, m, 1, 0, 0, 0, 0, t.Location()), PeriodMonth}
+}
+
+// End returns the start of the next period.
+func (p Period) End() time.Time {
+	if p.Unit == PeriodMonth {
+		return p.Start.AddDate(0, 1, 0)
+	}
+	return p.Start.AddDate(0, 0, 7)
+}
+
+// Prior returns the period before p.
+func (p Period) Prior() Period {
+	if p.Unit == PeriodMonth {
+		return Period{p.Start.AddDate(0, -1, 0), p.Unit}
+	}
+	return Period{p.Start.AddDate(0, 0, -7), p.Unit}
+}
+
+// Contains reports whether t is within the period.
+func (p Period) Contains(t time.Time) bool {
+	return !t.Before(p.Start) && t.Before(p.End())
+}
+
+// String formats the period, e.g. "week of 2021-02-15" or
+// "February 2021".
+func (p Period) String() string {
+	if p.Unit == PeriodMonth {
+		return p.Start.Format("January 2006")
+	}
+	return "week of " + p.Start.Format("2006-01-02")
+}
+
+// Range formats the first and last days of the period.
+func (p Period) Range() string {
+	return p.Start.Format("2006-01-02") + " to " + p.End().AddDate(0, 0, -1).Format("2006-01-02")
+}
+
+// Digest is a summary of browsing in a period, compared with the prior
+// period.
+type Digest struct {
+	Title           string
+	Period          Period
+	Visits          int
+	PriorVisits     int
+	Domains         int         // distinct domains visited
+	TopSites        []SiteCount // most visited domains, up to digestTopSites
+	NewDomains      []SiteCount // domains first visited in the period, up to digestMaxList
+	TotalNewDomains int
+	Searches        []Search // up to digestMaxList, most frequent first
+	TotalSearches   int
+}
+
+// SiteCount is the number of visits to a domain in a period and in the
+// prior period.
+type SiteCount struct {
+	Domain      string
+	Visits      int
+	PriorVisits int
+}
+
+// Search is a query to a search engine in a period.
+type Search struct {
+	Query  string
+	Engine string // e.g. "Google"
+	Count  int
+}
+
+const (
+	digestTopSites = 10
+	digestMaxList  = 20
+)
+
+// NewDigest summarizes the visits in d in the period. Visits are
+// collapsed and filtered like in WriteHTML, and domains are new when
+// they have no earlier visits in d.
+func NewDigest(d *Data, p Period) *Digest {
+	visits, _ := d.timeline()
+	prior := p.Prior()
+	dg := &Digest{
+		Title:  d.Title,
+		Period: p,
+	}
+	if dg.Title == "" {
+		dg.Title = "Browsing digest: " + p.String()
+	}
+
+	sites := make(map[string]*SiteCount)
+	seenBefore := make(map[string]bool)
+	searches := make(map[Search]*Search)
+	var searchOrder []*Search
+	for _, v := range visits {
+		domain := Domain(v.URL)
+		switch {
+		case v.Time.Before(p.Start):
+			seenBefore[domain] = true
+			if prior.Contains(v.Time) {
+				dg.PriorVisits++
+				if s, ok := sites[domain]; ok {
+					s.PriorVisits++
+				} else {
+					sites[domain] = &SiteCount{Domain: domain, PriorVisits: 1}
+				}
+			}
+		case p.Contains(v.Time):
+			dg.Visits++
+			s, ok := sites[domain]
+			if !ok {
+				s = &SiteCount{Domain: domain}
+				sites[domain] = s
+			}
+			s.Visits++
+			if engine, query := SearchQuery(v.URL); query != "" {
+				dg.TotalSearches++
+				key := Search{Query: query, Engine: engine}
+				if q, ok := searches[key]; ok {
+					q.Count++
+				} else {
+					q := &Search{query, engine, 1}
+					searches[key] = q
+					searchOrder = append(searchOrder, q)
+				}
+			}
+		}
+	}
+
+	var visited []SiteCount
+	for _, s := range sites {
+		if s.Visits == 0 {
+			continue
+		}
+		visited = append(visited, *s)
+		if !seenBefore[s.Domain] {
+			dg.NewDomains = append(dg.NewDomains, *s)
+		}
+	}
+	dg.Domains = len(visited)
+	dg.TotalNewDomains = len(dg.NewDomains)
+	sortSites(visited)
+	sortSites(dg.NewDomains)
+	dg.TopSites = truncateSites(visited, digestTopSites)
+	dg.NewDomains = truncateSites(dg.NewDomains, digestMaxList)
+
+	// Searches in order of first use break ties.
+	sort.SliceStable(searchOrder, func(i, j int) bool {
+		return searchOrder[i].Count > searchOrder[j].Count
+	})
+	for i, s := range searchOrder {
+		if i == digestMaxList {
+			break
+		}
+		dg.Searches = append(dg.Searches, *s)
+	}
+	return dg
+}
+
+func sortSites(s []SiteCount) {
+	sort.Slice(s, func(i, j int) bool {
+		if s[i].Visits != s[j].Visits {
+			return s[i].Visits > s[j].Visits
+		}
+		return s[i].Domain < s[j].Domain
+	})
+}
+
+func truncateSites(s []SiteCount, n int) []SiteCount {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// Change formats the change in visits from the prior period as a
+// percentage, e.g. "+20%", or "new" when there were none.
+func (dg *Digest) Change() string {
+	if dg.PriorVisits == 0 {
+		if dg.Visits == 0 {
+			return "no change"
+		}
+		return "new"
+	}
+	pct := (dg.Visits - dg.PriorVisits) * 100 / dg.PriorVisits
+	if pct >= 0 {
+		return fmt.Sprintf("+%d%%", pct)
+	}
+	return fmt.Sprintf("%d%%", pct)
+}
+
+// searchEngines are the search engines whose queries are listed in
+// digests. A domain ending in "." matches any public suffix.
+var searchEngines = []struct {
+	Name, Domain, Path, Param string
+}{
+	{"Google", "google.", "/search", "q"},
+	{"Bing", "bing.com", "/search", "q"},
+	{"DuckDuckGo", "duckduckgo.com", "/", "q"},
+	{"Yahoo", "yahoo.com", "/search", "p"},
+	{"Yandex", "yandex.", "/search", "text"},
+	{"Baidu", "baidu.com", "/s", "wd"},
+	{"Ecosia", "ecosia.org", "/search", "q"},
+	{"Startpage", "startpage.com", "/", "query"},
+	{"Brave", "brave.com", "/search", "q"},
+	{"Kagi", "kagi.com", "/search", "q"},
+	{"YouTube", "youtube.com", "/results", "search_query"},
+	{"Wikipedia", "wikipedia.org", "/w/index.php", "search"},
+}
+
+// SearchQuery returns the search engine and query of a search results
+// URL of a known engine, or "" when it is not one.
+func SearchQuery(rawURL string) (engine, query string) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", ""
+	}
+	domain := Domain(rawURL)
+	for _, e := range searchEngines {
+		if strings.HasSuffix(e.Domain, ".") {
+			if !strings.HasPrefix(domain, e.Domain) {
+				continue
+			}
+		} else if domain != e.Domain {
+			continue
+		}
+		if !strings.HasPrefix(u.Path, e.Path) {
+			continue
+		}
+		if q := strings.TrimSpace(u.Query().Get(e.Param)); q != "" {
+			return e.Name, q
+		}
+	}
+	return "", ""
+}
+
+// WriteMarkdown writes the digest as Markdown.
+func (dg *Digest) WriteMarkdown(w io.Writer) error {
+	return markdownDigestTemplate.Execute(w, dg)
+}
+
+// WriteHTML writes the digest as a self-contained HTML page.
+func (dg *Digest) WriteHTML(w io.Writer) error {
+	return htmlDigestTemplate.Execute(w, dg)
+}
+
+// markdownEscaper escapes text that could be read as Markdown syntax.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "|", `\|`, "#", `\#`, "\n", " ",
+)
+
+var markdownDigestTemplate = texttemplate.Must(texttemplate.New("digest").Funcs(texttemplate.FuncMap{
+	"md": markdownEscaper.Replace,
+}).Parse(`# {{md .Title}}
+
+{{.Period.Range}}
+
+- Visits: {{.Visits}} ({{.Change}} from {{.PriorVisits}} the {{.Period.Unit}} before)
+- Domains: {{.Domains}}, {{.TotalNewDomains}} new
+- Searches: {{.TotalSearches}}
+
+## Top sites
+{{if .TopSites}}
+| Site | Visits | Prior {{.Period.Unit}} |
+| --- | ---: | ---: |
+{{range .TopSites}}| {{md .Domain}} | {{.Visits}} | {{.PriorVisits}} |
+{{end}}{{else}}
+None.
+{{end}}
+## New domains
+{{if .NewDomains}}
+{{range .NewDomains}}- {{md .Domain}} ({{.Visits}})
+{{end}}{{if gt .TotalNewDomains (len .NewDomains)}}- and {{.TotalNewDomains}} in all
+{{end}}{{else}}
+None.
+{{end}}
+## Searches
+{{if .Searches}}
+{{range .Searches}}- {{md .Query}} ({{.Engine}}{{if gt .Count 1}}, {{.Count}} times{{end}})
+{{end}}{{else}}
+None.
+{{end}}`))
+
+var htmlDigestTemplate = htmltemplate.Must(htmltemplate.New("digest").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 60em; padding: 0 1em; color: #222; }
+h1 { margin-bottom: 0; }
+.meta { color: #666; }
+table { border-collapse: collapse; }
+th, td { padding: 0.2em 0.8em; text-align: left; }
+td.n { text-align: right; }
+li { margin: 0.15em 0; overflow-wrap: anywhere; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">{{.Period.Range}}</p>
+<ul>
+<li>Visits: {{.Visits}} ({{.Change}} from {{.PriorVisits}} the {{.Period.Unit}} before)</li>
+<li>Domains: {{.Domains}}, {{.TotalNewDomains}} new</li>
+<li>Searches: {{.TotalSearches}}</li>
+</ul>
+<h2>Top sites</h2>
+{{if .TopSites}}<table>
+<tr><th>Site</th><th>Visits</th><th>Prior {{.Period.Unit}}</th></tr>{{range .TopSites}}
+<tr><td>{{.Domain}}</td><td class="n">{{.Visits}}</td><td class="n">{{.PriorVisits}}</td></tr>{{end}}
+</table>{{else}}<p>None.</p>{{end}}
+<h2>New domains</h2>
+{{if .NewDomains}}<ul>{{range .NewDomains}}
+<li>{{.Domain}} ({{.Visits}})</li>{{end}}{{if gt .TotalNewDomains (len .NewDomains)}}
+<li>and {{.TotalNewDomains}} in all</li>{{end}}
+</ul>{{else}}<p>None.</p>{{end}}
+<h2>Searches</h2>
+{{if .Searches}}<ul>{{range .Searches}}
+<li>{{.Query}} ({{.Engine}}{{if gt .Count 1}}, {{.Count}} times{{end}})</li>{{end}}
+</ul>{{else}}<p>None.</p>{{end}}
+</body>
+</html>
+`))
+
+func (u PeriodUnit) String() string {
+	switch u {
+	case PeriodWeek:
+		return "week"
+	case PeriodMonth:
+		return "month"
+	default:
+		return fmt.Sprintf("unit(%d)", uint8(u))
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package report
+
+import (
+	"bytes"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/classify"
+	"github.com/andrewarchi/browser/history"
+)
+
+func TestPeriod(t *testing.T) {
+	at := time.Date(2021, 3, 3, 15, 0, 0, 0, time.UTC) // Wednesday
+	week := WeekOf(at)
+	if want := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC); !week.Start.Equal(want) {
+		t.Errorf("got week start %v, want %v", week.Start, want)
+	}
+	if got, want := week.Prior().Range(), "2021-02-22 to 2021-02-28"; got != want {
+		t.Errorf("got prior week %q, want %q", got, want)
+	}
+	month := MonthOf(at)
+	if got, want := month.String(), "March 2021"; got != want {
+		t.Errorf("got month %q, want %q", got, want)
+	}
+	if got, want := month.Prior().Range(), "2021-02-01 to 2021-02-28"; got != want {
+		t.Errorf("got prior month %q, want %q", got, want)
+	}
+}
+
+func TestNewDigest(t *testing.T) {
+	week := WeekOf(time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC))
+	day := func(d int) time.Time { return week.Start.AddDate(0, 0, d).Add(12 * time.Hour) }
+	visits := []history.Visit{
+		{URL: "https://old.example/", Time: day(-30)},
+		{URL: "https://www.example.com/a", Time: day(-3)},
+		{URL: "https://example.com/b", Time: day(0)},
+		{URL: "https://example.com/c", Time: day(1)},
+		{URL: "https://new.example/", Time: day(2)},
+		{URL: "https://www.google.com/search?q=go+generics", Time: day(2)},
+		{URL: "https://www.google.co.uk/search?q=go+generics&hl=en", Time: day(3)},
+		{URL: "https://duckduckgo.com/?q=sqlite", Time: day(4)},
+		{URL: "https://tracker.example/pixel", Time: day(5)},
+		{URL: "https://example.com/next", Time: day(7)},
+	}
+	noise := classify.Func(func(u *url.URL) classify.Result {
+		return classify.Result{Noise: u.Hostname() == "tracker.example"}
+	})
+	dg := NewDigest(&Data{Visits: visits, Classifier: noise}, week)
+	want := &Digest{
+		Title:       "Browsing digest: week of 2021-03-01",
+		Period:      week,
+		Visits:      6,
+		PriorVisits: 1,
+		Domains:     5,
+		TopSites: []SiteCount{
+			{"example.com", 2, 1},
+			{"duckduckgo.com", 1, 0},
+			{"google.co.uk", 1, 0},
+			{"google.com", 1, 0},
+			{"new.example", 1, 0},
+		},
+		NewDomains: []SiteCount{
+			{"duckduckgo.com", 1, 0},
+			{"google.co.uk", 1, 0},
+			{"google.com", 1, 0},
+			{"new.example", 1, 0},
+		},
+		TotalNewDomains: 4,
+		Searches: []Search{
+			{"go generics", "Google", 2},
+			{"sqlite", "DuckDuckGo", 1},
+		},
+		TotalSearches: 3,
+	}
+	if !reflect.DeepEqual(dg, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", dg, want)
+	}
+	if got := dg.Change(); got != "+500%" {
+		t.Errorf("got change %q, want +500%%", got)
+	}
+
+	var b bytes.Buffer
+	if err := dg.WriteMarkdown(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"# Browsing digest: week of 2021-03-01\n",
+		"- Visits: 6 (+500% from 1 the week before)\n",
+		"| example.com | 2 | 1 |\n",
+		"- go generics (Google, 2 times)\n",
+	} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("Markdown does not contain %q:\n%s", s, b.String())
+		}
+	}
+	b.Reset()
+	if err := dg.WriteHTML(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "<li>sqlite (DuckDuckGo)</li>") {
+		t.Errorf("HTML does not list search:\n%s", b.String())
+	}
+}
+
+func TestSearchQuery(t *testing.T) {
+	tests := []struct {
+		url, engine, query string
+	}{
+		{"https://www.google.com/search?q=a+b", "Google", "a b"},
+		{"https://www.bing.com/search?q=c", "Bing", "c"},
+		{"https://search.yahoo.com/search?p=d", "Yahoo", "d"},
+		{"https://www.youtube.com/results?search_query=e", "YouTube", "e"},
+		{"https://www.google.com/maps?q=f", "", ""},
+		{"https://example.com/search?q=g", "", ""},
+	}
+	for _, tt := range tests {
+		engine, query := SearchQuery(tt.url)
+		if engine != tt.engine || query != tt.query {
+			t.Errorf("SearchQuery(%q) = %q, %q, want %q, %q", tt.url, engine, query, tt.engine, tt.query)
+		}
+	}
+}
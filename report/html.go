@@ -0,0 +1,309 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package report generates human-readable reports of browsing data.
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser/bookmark"
+	"github.com/andrewarchi/browser/classify"
+	"github.com/andrewarchi/browser/history"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Data is the browsing data included in a report.
+type Data struct {
+	Title     string
+	Generated time.Time
+	Location  *time.Location // for grouping and display; defaults to UTC
+	Visits    []history.Visit
+	Bookmarks []bookmark.BookmarkEntry
+	// Icons optionally returns an image data URI (e.g. from
+	// chrome.Favicons.IconDataURI) for a page URL, or "" for none. Other
+	// URLs are dropped.
+	Icons func(pageURL string) string
+	// Classifier classifies visited URLs, so that noise, such as tracker
+	// and redirect URLs, is excluded from the report. When nil, the
+	// classifiers registered with classify.Register are used.
+	Classifier classify.Classifier
+	// CollapseRedirects collapses redirect chains in Visits into single
+	// visits with history.CollapseRedirects.
+	CollapseRedirects bool
+	// Order is the order of visits in reports. With history.OrderTime,
+	// the default, WriteHTML lists visits newest first, then by URL, and
+	// NewDigest breaks ties between searches by their first use in time.
+	// With history.OrderInput, visits are taken in the order of Visits.
+	Order history.Order
+}
+
+// WriteHTML writes a self-contained HTML page that lists history by
+// month and by domain and lists bookmarks by folder. The page has no
+// external resources and can be viewed offline in any browser.
+func WriteHTML(w io.Writer, d *Data) error {
+	loc := d.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	page := htmlPage{
+		Title:     d.Title,
+		Generated: d.Generated.In(loc).Format("2006-01-02 15:04 MST"),
+		Bookmarks: bookmarkNodes(d.Bookmarks, loc, d.icon),
+	}
+	if page.Title == "" {
+		page.Title = "Browsing history"
+	}
+
+	visits, excluded := d.timeline()
+	page.Visits = len(visits)
+	page.Excluded = excluded
+	if d.Order == history.OrderTime {
+		sort.SliceStable(visits, func(i, j int) bool {
+			if !visits[i].Time.Equal(visits[j].Time) {
+				return visits[i].Time.After(visits[j].Time)
+			}
+			return visits[i].URL < visits[j].URL
+		})
+	}
+	byMonth := make(map[string]*htmlGroup)
+	byDomain := make(map[string]*htmlGroup)
+	for _, v := range visits {
+		t := v.Time.In(loc)
+		hv := htmlVisit{
+			Time:   t.Format("2006-01-02 15:04"),
+			URL:    v.URL,
+			Title:  v.Title,
+			Domain: Domain(v.URL),
+			Icon:   d.icon(v.URL),
+		}
+		if hv.Title == "" {
+			hv.Title = v.URL
+		}
+		month := t.Format("2006-01")
+		g, ok := byMonth[month]
+		if !ok {
+			g = &htmlGroup{Name: t.Format("January 2006"), key: month}
+			byMonth[month] = g
+		}
+		g.Visits = append(g.Visits, hv)
+		g, ok = byDomain[hv.Domain]
+		if !ok {
+			g = &htmlGroup{Name: hv.Domain, key: hv.Domain}
+			byDomain[hv.Domain] = g
+		}
+		g.Visits = append(g.Visits, hv)
+	}
+	page.Months = sortGroups(byMonth, func(a, b *htmlGroup) bool {
+		return a.key > b.key
+	})
+	page.Domains = sortGroups(byDomain, func(a, b *htmlGroup) bool {
+		if len(a.Visits) != len(b.Visits) {
+			return len(a.Visits) > len(b.Visits)
+		}
+		return a.key < b.key
+	})
+	return htmlTemplate.Execute(w, &page)
+}
+
+// timeline returns a copy of the visits, with redirect chains
+// collapsed when enabled, without those classified as noise, ordered by
+// d.Order, and the number of visits excluded as noise.
+func (d *Data) timeline() ([]history.Visit, int) {
+	all := d.Visits
+	if d.CollapseRedirects {
+		all = history.CollapseRedirects(all)
+	}
+	var c classify.Classifier = d.Classifier
+	if c == nil {
+		c = classify.Default()
+	}
+	visits := make([]history.Visit, 0, len(all))
+	for _, v := range all {
+		if !classify.URL(c, v.URL).Noise {
+			visits = append(visits, v)
+		}
+	}
+	if d.Order == history.OrderTime {
+		history.SortVisits(visits)
+	}
+	return visits, len(all) - len(visits)
+}
+
+// Domain returns the registrable domain (eTLD+1) of a URL, falling back
+// to the hostname or scheme when it has none.
+func Domain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "(invalid)"
+	}
+	host := u.Hostname()
+	if host == "" {
+		return u.Scheme + ":"
+	}
+	if d, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return d
+	}
+	return host
+}
+
+type htmlPage struct {
+	Title     string
+	Generated string
+	Visits    int
+	Excluded  int // visits classified as noise
+	Months    []*htmlGroup
+	Domains   []*htmlGroup
+	Bookmarks []htmlBookmark
+}
+
+type htmlGroup struct {
+	Name   string
+	Visits []htmlVisit
+	key    string
+}
+
+type htmlVisit struct {
+	Time, URL, Title, Domain string
+	Icon                     template.URL
+}
+
+type htmlBookmark struct {
+	Title    string
+	URL      string
+	Added    string
+	Icon     template.URL
+	Children []htmlBookmark
+	IsFolder bool
+}
+
+func sortGroups(groups map[string]*htmlGroup, less func(a, b *htmlGroup) bool) []*htmlGroup {
+	s := make([]*htmlGroup, 0, len(groups))
+	for _, g := range groups {
+		s = append(s, g)
+	}
+	sort.Slice(s, func(i, j int) bool { return less(s[i], s[j]) })
+	return s
+}
+
+// icon returns the icon data URI for a page. Only image data URIs are
+// trusted, so that Icons cannot inject other URLs, such as javascript:
+// URLs, into the page.
+func (d *Data) icon(pageURL string) template.URL {
+	if d.Icons == nil {
+		return ""
+	}
+	uri := d.Icons(pageURL)
+	if !strings.HasPrefix(uri, "data:image/") {
+		return ""
+	}
+	return template.URL(uri)
+}
+
+func bookmarkNodes(entries []bookmark.BookmarkEntry, loc *time.Location, icon func(string) template.URL) []htmlBookmark {
+	nodes := make([]htmlBookmark, 0, len(entries))
+	for _, e := range entries {
+		switch e := e.(type) {
+		case *bookmark.BookmarkFolder:
+			nodes = append(nodes, htmlBookmark{
+				Title:    e.Title,
+				Added:    formatDate(e.AddDate, loc),
+				Children: bookmarkNodes(e.Entries, loc, icon),
+				IsFolder: true,
+			})
+		case *bookmark.Bookmark:
+			title := e.Title
+			if title == "" {
+				title = e.URL
+			}
+			nodes = append(nodes, htmlBookmark{
+				Title: title,
+				URL:   e.URL,
+				Added: formatDate(e.AddDate, loc),
+				Icon:  icon(e.URL),
+			})
+		case *bookmark.BookmarkSeparator:
+		default:
+			panic(fmt.Sprintf("report: illegal bookmark entry type: %T", e))
+		}
+	}
+	return nodes
+}
+
+func formatDate(t time.Time, loc *time.Location) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(loc).Format("2006-01-02")
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 60em; padding: 0 1em; color: #222; }
+h1 { margin-bottom: 0; }
+.meta { color: #666; }
+#search { width: 100%; font-size: 1.1em; padding: 0.4em; margin: 1em 0; box-sizing: border-box; }
+summary { cursor: pointer; font-weight: bold; padding: 0.2em 0; }
+ul { list-style: none; padding-left: 1.2em; }
+li { margin: 0.15em 0; overflow-wrap: anywhere; }
+time, .domain { color: #666; font-size: 0.9em; }
+.hidden { display: none; }
+img.icon { width: 16px; height: 16px; vertical-align: middle; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">{{.Visits}} visits{{if .Excluded}}, excluding {{.Excluded}} classified as noise{{end}}. Generated {{.Generated}}.</p>
+<input id="search" type="search" placeholder="Search titles and URLs" autofocus>
+
+<h2>History by month</h2>
+{{range .Months}}<details class="group"><summary>{{.Name}} ({{len .Visits}})</summary>
+<ul>{{range .Visits}}
+<li class="item"><time>{{.Time}}</time> {{template "icon" .Icon}}<a href="{{.URL}}">{{.Title}}</a> <span class="domain">{{.Domain}}</span></li>{{end}}
+</ul></details>
+{{end}}
+<h2>History by domain</h2>
+{{range .Domains}}<details class="group"><summary>{{.Name}} ({{len .Visits}})</summary>
+<ul>{{range .Visits}}
+<li class="item"><time>{{.Time}}</time> {{template "icon" .Icon}}<a href="{{.URL}}">{{.Title}}</a></li>{{end}}
+</ul></details>
+{{end}}
+{{if .Bookmarks}}<h2>Bookmarks</h2>
+{{template "bookmarks" .Bookmarks}}{{end}}
+<script>
+(function() {
+  var search = document.getElementById("search");
+  search.addEventListener("input", function() {
+    var q = search.value.toLowerCase();
+    document.querySelectorAll(".item").forEach(function(li) {
+      li.classList.toggle("hidden", q !== "" && li.textContent.toLowerCase().indexOf(q) === -1);
+    });
+    document.querySelectorAll("details.group").forEach(function(d) {
+      var any = d.querySelector(".item:not(.hidden)") !== null;
+      d.classList.toggle("hidden", !any);
+      d.open = q !== "" && any;
+    });
+  });
+})();
+</script>
+</body>
+</html>
+{{define "bookmarks"}}<ul>{{range .}}
+{{if .IsFolder}}<li><details open><summary>{{.Title}}</summary>{{template "bookmarks" .Children}}</details></li>{{else}}<li class="item">{{template "icon" .Icon}}<a href="{{.URL}}">{{.Title}}</a> <time>{{.Added}}</time></li>{{end}}{{end}}
+</ul>{{end}}
+{{define "icon"}}{{if .}}<img class="icon" src="{{.}}" alt=""> {{end}}{{end}}
+`))
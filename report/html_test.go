@@ -0,0 +1,66 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package report
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/bookmark"
+	"github.com/andrewarchi/browser/classify"
+	"github.com/andrewarchi/browser/history"
+)
+
+func TestWriteHTML(t *testing.T) {
+	at := time.Date(2021, 2, 18, 1, 2, 3, 0, time.UTC)
+	const icon = "data:image/png;base64,iVBORw0KGgo="
+	d := &Data{
+		Generated: at,
+		Visits: []history.Visit{
+			{URL: "https://example.com/", Title: "Example <Home>", Time: at},
+			{URL: "https://evil.example/", Time: at.Add(-time.Hour)},
+		},
+		Bookmarks: []bookmark.BookmarkEntry{
+			&bookmark.BookmarkFolder{Title: "Reading", Entries: []bookmark.BookmarkEntry{
+				&bookmark.Bookmark{Title: "Docs", URL: "https://example.com/"},
+			}},
+		},
+		Icons: func(pageURL string) string {
+			if pageURL == "https://example.com/" {
+				return icon
+			}
+			return "javascript:alert(1)"
+		},
+		Classifier: classify.Func(func(u *url.URL) classify.Result { return classify.Result{} }),
+	}
+	var b strings.Builder
+	if err := WriteHTML(&b, d); err != nil {
+		t.Fatal(err)
+	}
+	html := b.String()
+	for _, s := range []string{
+		"<title>Browsing history</title>",
+		"Example &lt;Home&gt;",
+		`<img class="icon" src="` + icon + `"`,
+		"<summary>Reading</summary>",
+		"February 2021",
+		"evil.example",
+	} {
+		if !strings.Contains(html, s) {
+			t.Errorf("report does not contain %q", s)
+		}
+	}
+	if strings.Contains(html, "javascript:") {
+		t.Error("report contains an untrusted icon URL")
+	}
+	// Newest first.
+	if i, j := strings.Index(html, "https://example.com/"), strings.Index(html, "https://evil.example/"); i > j {
+		t.Error("visits are not newest first")
+	}
+}
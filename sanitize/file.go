@@ -0,0 +1,123 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sanitize
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"unicode/utf8"
+
+	"github.com/andrewarchi/browser/compress/mozlz4"
+)
+
+// Format is the format of an artifact.
+type Format uint8
+
+// Values for Format:
+const (
+	FormatUnknown Format = iota
+	FormatJSON
+	FormatMozLz4 // JSON compressed with mozLz4 framing
+	FormatSQLite
+	FormatHTML
+	FormatText
+)
+
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+// DetectFormat detects the format of the contents of a file.
+func DetectFormat(data []byte) Format {
+	switch {
+	case bytes.HasPrefix(data, sqliteMagic):
+		return FormatSQLite
+	case mozlz4.IsMozLz4(data):
+		return FormatMozLz4
+	case !utf8.Valid(data) || bytes.IndexByte(data, 0) != -1:
+		return FormatUnknown
+	}
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\ufeff")), " \t\r\n")
+	switch {
+	case len(trimmed) == 0:
+		return FormatText
+	case trimmed[0] == '{' || trimmed[0] == '[':
+		return FormatJSON
+	case trimmed[0] == '<':
+		return FormatHTML
+	}
+	return FormatText
+}
+
+// Bytes sanitizes the contents of a file in any format, except SQLite,
+// which must be sanitized with File or SQLite.
+func (s *Sanitizer) Bytes(data []byte) ([]byte, error) {
+	switch f := DetectFormat(data); f {
+	case FormatJSON:
+		return s.JSON(data)
+	case FormatMozLz4:
+		return s.MozLz4(data)
+	case FormatHTML:
+		return s.HTML(data)
+	case FormatText:
+		return s.Text(data), nil
+	case FormatSQLite:
+		return nil, errors.New("sanitize: SQLite databases must be sanitized as files")
+	default:
+		return nil, errors.New("sanitize: unsupported format")
+	}
+}
+
+// File sanitizes the file src in any supported format and writes the
+// result to dst, which must not be the same file.
+func (s *Sanitizer) File(src, dst string) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if DetectFormat(data) == FormatSQLite {
+		return s.SQLite(src, dst)
+	}
+	b, err := s.Bytes(data)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, src)
+	}
+	return ioutil.WriteFile(dst, b, 0666)
+}
+
+// MozLz4 sanitizes a JSON document compressed with mozLz4 framing, as
+// used for sessionstore.jsonlz4 and search.json.mozlz4, and compresses
+// the result in the same framing.
+func (s *Sanitizer) MozLz4(data []byte) ([]byte, error) {
+	b, err := mozlz4.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = s.compactJSON(b); err != nil {
+		return nil, err
+	}
+	return mozlz4.Encode(b)
+}
+
+func (f Format) String() string {
+	switch f {
+	case FormatUnknown:
+		return "unknown"
+	case FormatJSON:
+		return "json"
+	case FormatMozLz4:
+		return "mozlz4"
+	case FormatSQLite:
+		return "sqlite"
+	case FormatHTML:
+		return "html"
+	case FormatText:
+		return "text"
+	default:
+		return fmt.Sprintf("format(%d)", uint8(f))
+	}
+}
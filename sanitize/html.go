@@ -0,0 +1,74 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sanitize
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// HTML sanitizes an HTML document, such as a Netscape bookmark file.
+// Text and attribute values are sanitized, while tags, comments, and
+// the doctype are kept. Tag and attribute names are lowercased, which
+// parsers of the format ignore.
+func (s *Sanitizer) HTML(data []byte) ([]byte, error) {
+	z := html.NewTokenizer(bytes.NewReader(data))
+	var buf bytes.Buffer
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return nil, fmt.Errorf("sanitize: %w", err)
+			}
+			return buf.Bytes(), nil
+		case html.TextToken:
+			text := string(z.Text())
+			trimmed := strings.TrimSpace(text)
+			if trimmed == "" {
+				buf.Write(z.Raw())
+				continue
+			}
+			i := strings.Index(text, trimmed)
+			buf.WriteString(html.EscapeString(text[:i] + s.String(trimmed) + text[i+len(trimmed):]))
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			for i, a := range t.Attr {
+				t.Attr[i].Val = s.attr(a.Key, a.Val)
+			}
+			buf.WriteString(t.String())
+		case html.EndTagToken:
+			buf.WriteString(z.Token().String())
+		default:
+			buf.Write(z.Raw())
+		}
+	}
+}
+
+// attr sanitizes an attribute value. Tags and keywords in bookmark
+// files are single words, so would otherwise be kept.
+func (s *Sanitizer) attr(key, val string) string {
+	switch key {
+	case "tags":
+		tags := strings.Split(val, ",")
+		for i, tag := range tags {
+			if tag != "" {
+				tags[i] = "tag-" + s.hash(tag)
+			}
+		}
+		return strings.Join(tags, ",")
+	case "shortcuturl":
+		if val != "" {
+			return "keyword-" + s.hash(val)
+		}
+		return val
+	}
+	return s.Field(key, val)
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sanitize
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// JSON sanitizes the strings in a JSON document and returns it
+// indented. Object keys and non-string values are kept, except for
+// keys that are URLs, email addresses, or paths.
+func (s *Sanitizer) JSON(data []byte) ([]byte, error) {
+	b, err := s.compactJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, "", "  "); err != nil {
+		return nil, fmt.Errorf("sanitize: %w", err)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func (s *Sanitizer) compactJSON(data []byte) ([]byte, error) {
+	d := newDecoder(data)
+	var buf bytes.Buffer
+	if err := s.sanitizeJSON(d, &buf, ""); err != nil {
+		return nil, fmt.Errorf("sanitize: %w", err)
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, errors.New("sanitize: trailing data after JSON value")
+	}
+	return buf.Bytes(), nil
+}
+
+// sanitizeJSON sanitizes the next value, which is in the field key or
+// in an array in that field.
+func (s *Sanitizer) sanitizeJSON(d *json.Decoder, buf *bytes.Buffer, key string) error {
+	tok, err := d.Token()
+	if err != nil {
+		return err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '{':
+			buf.WriteByte('{')
+			for i := 0; d.More(); i++ {
+				if i != 0 {
+					buf.WriteByte(',')
+				}
+				key, err := d.Token()
+				if err != nil {
+					return err
+				}
+				k := key.(string)
+				writeJSONString(buf, s.MapKey(k))
+				buf.WriteByte(':')
+				if prefix := fieldPrefix(k); prefix != "" {
+					err = s.replaceString(d, buf, prefix)
+				} else {
+					err = s.sanitizeJSON(d, buf, k)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			buf.WriteByte('}')
+		case '[':
+			buf.WriteByte('[')
+			for i := 0; d.More(); i++ {
+				if i != 0 {
+					buf.WriteByte(',')
+				}
+				if err := s.sanitizeJSON(d, buf, key); err != nil {
+					return err
+				}
+			}
+			buf.WriteByte(']')
+		}
+		// Consume the closing delimiter.
+		_, err := d.Token()
+		return err
+	case string:
+		writeJSONString(buf, s.Field(key, tok))
+	case json.Number:
+		buf.WriteString(tok.String())
+	case bool:
+		buf.WriteString(strconv.FormatBool(tok))
+	case nil:
+		buf.WriteString("null")
+	}
+	return nil
+}
+
+// replaceString replaces a non-empty string value with a placeholder
+// with the given prefix, or sanitizes other values as usual.
+func (s *Sanitizer) replaceString(d *json.Decoder, buf *bytes.Buffer, prefix string) error {
+	var v json.RawMessage
+	if err := d.Decode(&v); err != nil {
+		return err
+	}
+	var str string
+	if err := json.Unmarshal(v, &str); err == nil {
+		if str != "" {
+			str = prefix + s.hash(str)
+		}
+		writeJSONString(buf, str)
+		return nil
+	}
+	return s.sanitizeJSON(newDecoder(v), buf, "")
+}
+
+func newDecoder(data []byte) *json.Decoder {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return d
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(false)
+	_ = e.Encode(s)             // strings always encode
+	buf.Truncate(buf.Len() - 1) // trailing newline
+}
@@ -0,0 +1,270 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package sanitize replaces private values in browser artifacts with
+// deterministic placeholders, so that samples can be shared in bug
+// reports and added to the test corpus.
+//
+// JSON (optionally in mozLz4 framing), SQLite databases, bookmark HTML,
+// and line-oriented text such as TSV exports are supported; File
+// detects the format from the contents.
+//
+// Structure is preserved: object keys, schemas, numbers, and booleans
+// are kept, so that a sanitized file parses the same way as the
+// original. Strings are replaced unless they are on an explicit
+// allowlist: quoted numbers and timestamps, versions, GUIDs, and the
+// values of enum fields, such as "type" or "status". URLs keep their
+// scheme and port. The same input always yields the same placeholder,
+// so references between values remain intact.
+package sanitize
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Sanitizer replaces private values with placeholders derived from an
+// HMAC of each value. The zero value uses an empty key and is ready to
+// use.
+type Sanitizer struct {
+	// Key is mixed into placeholders so that sanitized values cannot be
+	// recovered by hashing guesses, such as popular URLs. Placeholders
+	// are only comparable between files sanitized with the same key.
+	Key []byte
+}
+
+var defaultSanitizer Sanitizer
+
+// String sanitizes a string with the default sanitizer.
+func String(s string) string { return defaultSanitizer.String(s) }
+
+// JSON sanitizes a JSON document with the default sanitizer.
+func JSON(data []byte) ([]byte, error) { return defaultSanitizer.JSON(data) }
+
+// Bytes sanitizes the contents of a file with the default sanitizer.
+func Bytes(data []byte) ([]byte, error) { return defaultSanitizer.Bytes(data) }
+
+// File sanitizes a file with the default sanitizer.
+func File(src, dst string) error { return defaultSanitizer.File(src, dst) }
+
+// maxTokenLen is the length above which strings without spaces are
+// considered opaque data, such as tokens or encoded blobs, rather than
+// identifiers.
+const maxTokenLen = 64
+
+// keepValues are strings that are kept wherever they occur.
+var keepValues = map[string]bool{
+	"true":  true,
+	"false": true,
+}
+
+// internalSchemes are URL schemes that address browser resources,
+// rather than user data, and are kept unchanged.
+var internalSchemes = map[string]bool{
+	"about":    true,
+	"chrome":   true,
+	"resource": true,
+}
+
+var (
+	schemeRE  = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+	emailRE   = regexp.MustCompile(`^[^\s@/:]+@[^\s@/:]+\.[a-zA-Z]{2,}$`)
+	winPathRE = regexp.MustCompile(`^[a-zA-Z]:\\`)
+
+	// secretKeyRE and nameKeyRE match field names for credentials and
+	// personal names.
+	secretKeyRE = regexp.MustCompile(`(?i)(token|password|secret|encrypted)`)
+	nameKeyRE   = regexp.MustCompile(`(?i)(^|_)(full|given|family|first|last|display|gaia)_?name$`)
+
+	// numberRE matches quoted numbers and timestamps, including History
+	// Trends Unlimited times prefixed with "U". versionRE matches
+	// versions, such as "99.0.4844.51" or "3.1b2". uuidRE matches UUIDs,
+	// optionally in braces, and extensionIDRE matches Chrome extension
+	// IDs.
+	numberRE      = regexp.MustCompile(`^U?-?[0-9]+(\.[0-9]+)?$`)
+	versionRE     = regexp.MustCompile(`^[0-9]+(\.[0-9]+)+([a-z]+[0-9]*)?$`)
+	uuidRE        = regexp.MustCompile(`^\{?[0-9a-fA-F]{8}(-[0-9a-fA-F]{4}){3}-[0-9a-fA-F]{12}\}?$`)
+	extensionIDRE = regexp.MustCompile(`^[a-p]{32}$`)
+
+	// enumKeyRE matches field names with enum values, which are kept
+	// when they match enumRE, and guidKeyRE matches field names with
+	// GUIDs in browser-specific formats, which are kept when they match
+	// guidRE.
+	enumKeyRE = regexp.MustCompile(`(?i)(type|kind|state|status|reason|mode|format|transition|charset|encoding|locale|permissions|^root)$`)
+	enumRE    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.:/+-]*$`)
+	guidKeyRE = regexp.MustCompile(`(?i)(guid|uuid)$`)
+	guidRE    = regexp.MustCompile(`^[A-Za-z0-9_+/={}-]+$`)
+)
+
+// String returns a placeholder for str, unless it is on the allowlist
+// of numbers, versions, and GUIDs. Use Field for values of named
+// fields, which may also be enum values.
+func (s *Sanitizer) String(str string) string {
+	if str == "" || isKept(str) {
+		return str
+	}
+	if v, ok := s.replaceSpecial(str); ok {
+		return v
+	}
+	if strings.HasPrefix(str, "{") || strings.HasPrefix(str, "[") {
+		// Some formats store JSON documents within strings.
+		if b, err := s.compactJSON([]byte(str)); err == nil {
+			return string(b)
+		}
+	}
+	if isText(str) {
+		return "text-" + s.hash(str)
+	}
+	if len(str) > maxTokenLen {
+		return "data-" + s.hash(str)
+	}
+	return "value-" + s.hash(str)
+}
+
+// isKept reports whether a string is on the allowlist of values that
+// are kept regardless of their field.
+func isKept(str string) bool {
+	return keepValues[str] || numberRE.MatchString(str) || versionRE.MatchString(str) ||
+		uuidRE.MatchString(str) || extensionIDRE.MatchString(str)
+}
+
+// MapKey returns a placeholder for an object key. Keys are usually part
+// of the structure of a format, so only URLs, email addresses, and
+// paths are replaced.
+func (s *Sanitizer) MapKey(key string) string {
+	if v, ok := s.replaceSpecial(key); ok {
+		return v
+	}
+	return key
+}
+
+// URL returns a URL with the same scheme and port and placeholders for
+// the host, path, query, and fragment. URLs with internal browser
+// schemes, such as about: and chrome:, are kept unchanged.
+func (s *Sanitizer) URL(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Scheme == "" {
+		return "text-" + s.hash(rawurl)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if internalSchemes[scheme] {
+		return rawurl
+	}
+	if u.Opaque != "" || u.Host == "" && u.Path == "" {
+		return scheme + ":" + s.hash(rawurl)
+	}
+	var b strings.Builder
+	b.WriteString(scheme)
+	b.WriteString("://")
+	if u.Host != "" {
+		b.WriteString("host-")
+		b.WriteString(s.hash(u.Hostname()))
+		b.WriteString(".example")
+		if port := u.Port(); port != "" {
+			b.WriteByte(':')
+			b.WriteString(port)
+		}
+	}
+	b.WriteByte('/')
+	if p := strings.TrimPrefix(u.EscapedPath(), "/"); p != "" {
+		b.WriteString(s.hash(p))
+	}
+	// Keep distinct URLs distinct, as some databases require unique
+	// URLs.
+	if u.RawQuery != "" || u.ForceQuery {
+		b.WriteByte('?')
+		b.WriteString(s.hash(u.RawQuery))
+	}
+	if u.Fragment != "" {
+		b.WriteByte('#')
+		b.WriteString(s.hash(u.Fragment))
+	}
+	return b.String()
+}
+
+// replaceSpecial replaces URLs, email addresses, and file paths.
+func (s *Sanitizer) replaceSpecial(str string) (string, bool) {
+	switch {
+	case schemeRE.MatchString(str) && !strings.ContainsAny(str, " \t\n") && looksLikeURL(str):
+		return s.URL(str), true
+	case emailRE.MatchString(str):
+		return "user-" + s.hash(str) + "@example.com", true
+	case winPathRE.MatchString(str):
+		return str[:3] + "path-" + s.hash(str), true
+	case strings.HasPrefix(str, "/") || strings.HasPrefix(str, "~/"):
+		return "/path-" + s.hash(str), true
+	}
+	return "", false
+}
+
+// looksLikeURL distinguishes URLs from identifiers containing colons,
+// such as "internal:privateBrowsingAllowed" or "12:30".
+func looksLikeURL(str string) bool {
+	i := strings.IndexByte(str, ':')
+	scheme := strings.ToLower(str[:i])
+	rest := str[i+1:]
+	if strings.HasPrefix(rest, "//") {
+		return true
+	}
+	switch scheme {
+	case "about", "blob", "data", "file", "jar", "javascript", "mailto", "tel", "view-source":
+		return true
+	}
+	return false
+}
+
+// isText reports whether a string is free text, such as a title or a
+// name, rather than an identifier.
+func isText(str string) bool {
+	for _, r := range str {
+		if unicode.IsSpace(r) || r > unicode.MaxASCII && unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Field sanitizes the value of a named field, such as an object key or
+// a database column. Values of fields for credentials and personal
+// names are always replaced. Identifiers in fields for enums and GUIDs
+// are kept, as are the values kept by String.
+func (s *Sanitizer) Field(name, value string) string {
+	if value == "" {
+		return value
+	}
+	if prefix := fieldPrefix(name); prefix != "" {
+		return prefix + s.hash(value)
+	}
+	if v, ok := s.replaceSpecial(value); ok {
+		return v
+	}
+	if len(value) <= maxTokenLen && (enumKeyRE.MatchString(name) && enumRE.MatchString(value) ||
+		guidKeyRE.MatchString(name) && guidRE.MatchString(value)) {
+		return value
+	}
+	return s.String(value)
+}
+
+func fieldPrefix(name string) string {
+	switch {
+	case secretKeyRE.MatchString(name):
+		return "secret-"
+	case nameKeyRE.MatchString(name):
+		return "text-"
+	}
+	return ""
+}
+
+func (s *Sanitizer) hash(str string) string {
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write([]byte(str))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
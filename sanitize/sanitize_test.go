@@ -0,0 +1,263 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sanitize
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andrewarchi/browser/compress/mozlz4"
+)
+
+func TestString(t *testing.T) {
+	keep := []string{
+		"",
+		"true",
+		"13258080000000000",
+		"-1",
+		"U1613610123456.789",
+		"{6ba7b810-9dad-11d1-80b4-00c04fd430c8}",
+		"6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		"aapocclcgogkmnckokdopfmhonfmgoek",
+		"1.2.3",
+		"99.0.4844.51",
+		"3.1b2",
+		"about:newtab",
+		"chrome://browser/content/browser.xhtml",
+		"resource://services-settings/remote-settings.js",
+	}
+	for i, s := range keep {
+		if got := String(s); got != s {
+			t.Errorf("#%d: got: %q, want: %q", i, got, s)
+		}
+	}
+
+	replace := []struct {
+		In, Prefix string
+	}{
+		{"https://www.example.com/private/path?q=1", "https://host-"},
+		{"http://localhost:8080/", "http://host-"},
+		{"mailto:someone@example.com", "mailto:"},
+		{"jar:file:///home/someone/ext.xpi!/", "jar:"},
+		{"someone@example.com", "user-"},
+		{"/home/someone/Downloads/file.pdf", "/path-"},
+		{`C:\Users\someone\file.pdf`, `C:\path-`},
+		{"My Bookmarks", "text-"},
+		{"Überweisung", "text-"},
+		{strings.Repeat("a", 100), "data-"},
+		{"someone", "value-"},
+		{"extension", "value-"},
+		{"internal:privateBrowsingAllowed", "value-"},
+		{"SID=31d4d96e407aad42", "value-"},
+		{"12:30", "value-"},
+	}
+	for i, tt := range replace {
+		got := String(tt.In)
+		if !strings.HasPrefix(got, tt.Prefix) || strings.Contains(got, "someone") {
+			t.Errorf("#%d: got: %q, want prefix: %q", i, got, tt.Prefix)
+		}
+		if again := String(tt.In); again != got {
+			t.Errorf("#%d: not deterministic: %q and %q", i, got, again)
+		}
+	}
+
+	if got := String("someone"); len(got) != len("value-")+16 {
+		t.Errorf("got placeholder %q, want 8-byte hash", got)
+	}
+
+	keyed := Sanitizer{Key: []byte("key")}
+	if keyed.String("My Bookmarks") == String("My Bookmarks") {
+		t.Error("key does not change placeholders")
+	}
+}
+
+func TestField(t *testing.T) {
+	keep := []struct {
+		Name, Value string
+	}{
+		{"type", "extension"},
+		{"installType", "normal"},
+		{"mime_type", "application/pdf"},
+		{"type", "text/x-moz-place-container"},
+		{"root", "bookmarksMenuFolder"},
+		{"last_charset", "UTF-8"},
+		{"permissions", "internal:privateBrowsingAllowed"},
+		{"guid", "abcdefghijkl"},
+		{"cache_guid", "uZCjH4vLyoC8/kzWb6Gi+Q=="},
+		{"title", "1.2.3"},
+	}
+	for i, tt := range keep {
+		if got := defaultSanitizer.Field(tt.Name, tt.Value); got != tt.Value {
+			t.Errorf("#%d: got: %q, want: %q", i, got, tt.Value)
+		}
+	}
+
+	replace := []struct {
+		Name, Value, Prefix string
+	}{
+		{"title", "Example", "value-"},
+		{"value", "31d4d96e407aad42", "value-"},
+		{"username", "someone", "value-"},
+		{"password", "1234", "secret-"},
+		{"given_name", "Someone", "text-"},
+		{"type", "tel:5551234", "tel:"},
+		{"type", "some one", "text-"},
+		{"state", strings.Repeat("a", 100), "data-"},
+		{"guid", "/home/someone", "/path-"},
+	}
+	for i, tt := range replace {
+		if got := defaultSanitizer.Field(tt.Name, tt.Value); !strings.HasPrefix(got, tt.Prefix) || strings.Contains(got, "someone") {
+			t.Errorf("#%d: got: %q, want prefix: %q", i, got, tt.Prefix)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	in := `{"title":"My Page","url":"https://example.com/a","visits":3,"typed":true,` +
+		`"https://example.com/":{"type":"url","date":"13258080000000000"},"tags":[null,"a tag"],` +
+		`"state":"{\"name\":\"Some Name\"}","sessionToken":"abc","password":{"n":1}}`
+	want := `{
+  "title": "` + String("My Page") + `",
+  "url": "` + String("https://example.com/a") + `",
+  "visits": 3,
+  "typed": true,
+  "` + String("https://example.com/") + `": {
+    "type": "url",
+    "date": "13258080000000000"
+  },
+  "tags": [
+    null,
+    "` + String("a tag") + `"
+  ],
+  "state": "{\"name\":\"` + String("Some Name") + `\"}",
+  "sessionToken": "secret-` + defaultSanitizer.hash("abc") + `",
+  "password": {
+    "n": 1
+  }
+}
+`
+	got, err := JSON([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := JSON([]byte(`{"a":1} {}`)); err == nil {
+		t.Error("trailing data not rejected")
+	}
+}
+
+func TestText(t *testing.T) {
+	in := "U1612345678901\thttps://example.com/\tMy Page\r\n1\n"
+	want := "U1612345678901\t" + String("https://example.com/") + "\t" + String("My Page") + "\r\n1\n"
+	if got := string(defaultSanitizer.Text([]byte(in))); got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+	in = "1\nkey 1612345678 https://example.com^userContextId=1\n"
+	want = "1\n" + String("key") + " 1612345678 " + String("https://example.com^userContextId=1") + "\n"
+	if got := string(defaultSanitizer.Text([]byte(in))); got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	in := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<DL><p>
+    <DT><A HREF="https://example.com/" ADD_DATE="1612345678" TAGS="work,news" SHORTCUTURL="ex">Example &amp; Co</A>
+</DL><p>
+`
+	want := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<dl><p>
+    <dt><a href="` + String("https://example.com/") + `" add_date="1612345678" tags="tag-` +
+		defaultSanitizer.hash("work") + `,tag-` + defaultSanitizer.hash("news") + `" shortcuturl="keyword-` +
+		defaultSanitizer.hash("ex") + `">` + String("Example & Co") + `</a>
+</dl><p>
+`
+	got, err := defaultSanitizer.HTML([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMozLz4(t *testing.T) {
+	in := []byte(`{"url":"https://example.com/"}`)
+	compressed, err := mozlz4.Encode(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Bytes(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := mozlz4.Decode(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"url":"` + String("https://example.com/") + `"}`; string(b) != want {
+		t.Errorf("got: %s, want: %s", b, want)
+	}
+}
+
+func TestSQLite(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "src.sqlite"), filepath.Join(dir, "dst.sqlite")
+	db, err := sql.Open("sqlite3", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`PRAGMA user_version = 53`,
+		`CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR, guid TEXT, frecency INTEGER, icon BLOB)`,
+		`INSERT INTO moz_places VALUES (1, 'https://example.com/?a', 'My Page', 'abcdefghijkl', 100, x'0102'), (2, 'https://example.com/?b', NULL, 'bcdefghijklm', 0, NULL)`,
+		// Calls a function that only exists in Firefox.
+		`CREATE TRIGGER moz_places_afterinsert AFTER INSERT ON moz_places BEGIN SELECT store_last_inserted_id('moz_places', NEW.id); END`,
+		`CREATE UNIQUE INDEX moz_places_url ON moz_places (url)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	if err := File(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	out, err := sql.Open("sqlite3", dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	var version int
+	if err := out.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil || version != 53 {
+		t.Errorf("user_version = %d, %v", version, err)
+	}
+	var triggers int
+	if err := out.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'trigger'`).Scan(&triggers); err != nil || triggers != 1 {
+		t.Errorf("got %d triggers, %v", triggers, err)
+	}
+	var url, guid string
+	var title sql.NullString
+	var frecency int
+	var icon []byte
+	if err := out.QueryRow(`SELECT url, title, guid, frecency, icon FROM moz_places WHERE id = 1`).Scan(&url, &title, &guid, &frecency, &icon); err != nil {
+		t.Fatal(err)
+	}
+	if url != String("https://example.com/?a") || title.String != String("My Page") ||
+		guid != "abcdefghijkl" || frecency != 100 || string(icon) != "\x00\x00" {
+		t.Errorf("got row %q, %q, %q, %d, %q", url, title.String, guid, frecency, icon)
+	}
+	if err := File(src, dst); err == nil {
+		t.Error("existing destination not rejected")
+	}
+}
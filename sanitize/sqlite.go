@@ -0,0 +1,151 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sanitize
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// SQLite copies the SQLite database src to a new database dst with the
+// same schema and user version and sanitized contents. Text values are
+// sanitized according to their column name, blobs are replaced with
+// zeros of the same length, and numbers are kept. dst must not already
+// exist.
+//
+// Indexes, triggers, and views are created after the rows are copied,
+// so that triggers which call functions defined by the browser, such as
+// those in places.sqlite, are not run.
+func (s *Sanitizer) SQLite(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("sanitize: %s already exists", dst)
+	}
+	in, err := sqliteutil.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := sql.Open("sqlite3", dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := s.copySQLite(in, out); err != nil {
+		return fmt.Errorf("sanitize: %s: %w", src, err)
+	}
+	return out.Close()
+}
+
+type sqliteObject struct {
+	Type, Name, SQL string
+}
+
+func (s *Sanitizer) copySQLite(in, out *sql.DB) error {
+	var objects []sqliteObject
+	err := sqliteutil.Query(in, `
+		SELECT type, name, sql FROM sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+		ORDER BY type != 'table', rowid`, func(rows *sql.Rows) error {
+		var o sqliteObject
+		if err := rows.Scan(&o.Type, &o.Name, &o.SQL); err != nil {
+			return err
+		}
+		objects = append(objects, o)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var version int64
+	if err := in.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+
+	tx, err := out.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, o := range objects {
+		if o.Type == "table" {
+			if _, err := tx.Exec(o.SQL); err != nil {
+				return fmt.Errorf("create table %s: %w", o.Name, err)
+			}
+		}
+	}
+	for _, o := range objects {
+		if o.Type == "table" {
+			if err := s.copyTable(in, tx, o.Name); err != nil {
+				return fmt.Errorf("table %s: %w", o.Name, err)
+			}
+		}
+	}
+	for _, o := range objects {
+		if o.Type != "table" {
+			if _, err := tx.Exec(o.SQL); err != nil {
+				return fmt.Errorf("create %s %s: %w", o.Type, o.Name, err)
+			}
+		}
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Sanitizer) copyTable(in *sql.DB, tx *sql.Tx, table string) error {
+	rows, err := in.Query(`SELECT * FROM ` + quoteIdent(table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	quoted := make([]string, len(cols))
+	for i, col := range cols {
+		quoted[i] = quoteIdent(col)
+	}
+	insert, err := tx.Prepare(fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`, quoteIdent(table),
+		strings.Join(quoted, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ")))
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	values := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		for i, v := range values {
+			switch v := v.(type) {
+			case string:
+				values[i] = s.Field(cols[i], v)
+			case []byte:
+				values[i] = make([]byte, len(v))
+			}
+		}
+		if _, err := insert.Exec(values...); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sanitize
+
+import "bytes"
+
+// Text sanitizes line-oriented text, such as TSV exports from History
+// Trends Unlimited or enumerate_devices.txt in Firefox. Each field is
+// sanitized separately, where fields are separated by tabs or, when
+// the text contains no tabs, by spaces. Line endings are kept.
+func (s *Sanitizer) Text(data []byte) []byte {
+	sep := []byte{'\t'}
+	if bytes.IndexByte(data, '\t') == -1 {
+		sep = []byte{' '}
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(data))
+	for len(data) != 0 {
+		line := data
+		var eol []byte
+		if i := bytes.IndexByte(data, '\n'); i != -1 {
+			line, eol, data = data[:i], data[i:i+1], data[i+1:]
+		} else {
+			data = nil
+		}
+		if bytes.HasSuffix(line, []byte{'\r'}) {
+			line, eol = line[:len(line)-1], append([]byte{'\r'}, eol...)
+		}
+		for i, field := range bytes.Split(line, sep) {
+			if i != 0 {
+				buf.Write(sep)
+			}
+			buf.WriteString(s.String(string(field)))
+		}
+		buf.Write(eol)
+	}
+	return buf.Bytes()
+}
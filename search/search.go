@@ -0,0 +1,205 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package search converts the search engines of browsers into a common
+// model and back, so that custom site-search keywords can be carried
+// between browsers. Chrome and Firefox both use OpenSearch URL
+// templates, with "{searchTerms}" substituted by the query, so
+// templates are kept as is.
+package search
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/firefox"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/takeout"
+)
+
+// Engine is a search engine, independent of the browser that it was
+// read from.
+type Engine struct {
+	Name       string
+	Keyword    string // e.g. "w" or "@wikipedia"; empty when not set
+	URL        string // search URL template
+	SuggestURL string // JSON suggestions URL template
+	FaviconURL string
+	Custom     bool // added by the user or a site, rather than bundled with the browser
+	Created    time.Time
+	Modified   time.Time
+	Browser    string // e.g. "chrome" or "firefox"
+	Source     string // source read from, e.g. "search.json.mozlz4", "Web Data", or "takeout"
+}
+
+// Browsers and sources:
+const (
+	BrowserChrome  = "chrome"
+	BrowserFirefox = "firefox"
+
+	SourceFirefoxSearch = firefox.SearchEnginesFile
+	SourceChromeWebData = chrome.WebDataFile
+	SourceTakeout       = "takeout"
+)
+
+// FromFirefox converts the engines in search.json.mozlz4. Engines
+// without a GET search URL, such as POST-only engines, are dropped.
+func FromFirefox(s *firefox.SearchEngines) []Engine {
+	engines := make([]Engine, 0, len(s.Engines))
+	for i := range s.Engines {
+		e := &s.Engines[i]
+		u := e.SearchURL(firefox.SearchTypeHTML)
+		if u == "" {
+			continue
+		}
+		engines = append(engines, Engine{
+			Name:       e.Name,
+			Keyword:    e.Alias(),
+			URL:        u,
+			SuggestURL: e.SearchURL(firefox.SearchTypeSuggestJSON),
+			FaviconURL: e.IconURL,
+			Custom:     !e.IsAppProvided,
+			Browser:    BrowserFirefox,
+			Source:     SourceFirefoxSearch,
+		})
+	}
+	return engines
+}
+
+// FromChrome converts the keywords in the Web Data database of a Chrome
+// profile.
+func FromChrome(keywords []chrome.Keyword) []Engine {
+	engines := make([]Engine, 0, len(keywords))
+	for i := range keywords {
+		k := &keywords[i]
+		engines = append(engines, Engine{
+			Name:       k.ShortName,
+			Keyword:    k.Keyword,
+			URL:        k.URL,
+			SuggestURL: k.SuggestURL,
+			FaviconURL: k.FaviconURL,
+			Custom:     k.IsCustom(),
+			Created:    k.DateCreated,
+			Modified:   k.LastModified,
+			Browser:    BrowserChrome,
+			Source:     SourceChromeWebData,
+		})
+	}
+	return engines
+}
+
+// FromTakeout converts the search engines in a Takeout export of
+// Chrome.
+func FromTakeout(data *takeout.Chrome) []Engine {
+	engines := make([]Engine, 0, len(data.SearchEngines))
+	for _, e := range data.SearchEngines {
+		engines = append(engines, Engine{
+			Name:       e.ShortName,
+			Keyword:    e.Keyword,
+			URL:        e.URL,
+			SuggestURL: e.SuggestionsURL,
+			FaviconURL: e.FaviconURL,
+			Custom:     e.PrepopulateID == 0,
+			Created:    e.DateCreated.Time,
+			Modified:   e.LastModified.Time,
+			Browser:    BrowserChrome,
+			Source:     SourceTakeout,
+		})
+	}
+	return engines
+}
+
+// Custom returns the engines added by the user or a site.
+func Custom(engines []Engine) []Engine {
+	var custom []Engine
+	for _, e := range engines {
+		if e.Custom {
+			custom = append(custom, e)
+		}
+	}
+	return custom
+}
+
+// ToFirefox converts engines to engines for search.json.mozlz4, as
+// added by the user.
+func ToFirefox(engines []Engine) []firefox.SearchEngine {
+	converted := make([]firefox.SearchEngine, 0, len(engines))
+	for _, e := range engines {
+		urls := []firefox.SearchEngineURL{{
+			Template: e.URL,
+			Type:     firefox.SearchTypeHTML,
+			Method:   "GET",
+		}}
+		if e.SuggestURL != "" {
+			urls = append(urls, firefox.SearchEngineURL{
+				Template: e.SuggestURL,
+				Type:     firefox.SearchTypeSuggestJSON,
+				Method:   "GET",
+			})
+		}
+		converted = append(converted, firefox.SearchEngine{
+			Name:         e.Name,
+			LoadPath:     "[user]",
+			IconURL:      e.FaviconURL,
+			QueryCharset: "UTF-8",
+			MetaData:     firefox.SearchEngineMeta{Alias: e.Keyword},
+			URLs:         urls,
+		})
+	}
+	return converted
+}
+
+// ToChrome converts engines to keywords for Web Data, as added by the
+// user. Chrome requires a keyword, so engines without one use the host
+// of their URL, as Chrome does for engines that it discovers.
+func ToChrome(engines []Engine) []chrome.Keyword {
+	converted := make([]chrome.Keyword, 0, len(engines))
+	for _, e := range engines {
+		converted = append(converted, chrome.Keyword{
+			ShortName:      e.Name,
+			Keyword:        e.chromeKeyword(),
+			FaviconURL:     e.FaviconURL,
+			URL:            e.URL,
+			DateCreated:    e.Created,
+			InputEncodings: []string{"UTF-8"},
+			SuggestURL:     e.SuggestURL,
+			LastModified:   e.Modified,
+		})
+	}
+	return converted
+}
+
+// ToTakeout converts engines to search engines in the format of
+// SearchEngines.json in a Takeout export of Chrome.
+func ToTakeout(engines []Engine) []takeout.SearchEngine {
+	converted := make([]takeout.SearchEngine, 0, len(engines))
+	for _, e := range engines {
+		converted = append(converted, takeout.SearchEngine{
+			ShortName:      e.Name,
+			Keyword:        e.chromeKeyword(),
+			URL:            e.URL,
+			SuggestionsURL: e.SuggestURL,
+			FaviconURL:     e.FaviconURL,
+			DateCreated:    timefmt.Chrome{Time: e.Created},
+			LastModified:   timefmt.Chrome{Time: e.Modified},
+			InputEncodings: "UTF-8",
+		})
+	}
+	return converted
+}
+
+func (e *Engine) chromeKeyword() string {
+	if e.Keyword != "" {
+		return e.Keyword
+	}
+	u, err := url.Parse(strings.ReplaceAll(e.URL, "{searchTerms}", ""))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package search
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/firefox"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/takeout"
+)
+
+func TestFirefoxToChrome(t *testing.T) {
+	s := &firefox.SearchEngines{Engines: []firefox.SearchEngine{{
+		Name:           "Google",
+		IsAppProvided:  true,
+		DefinedAliases: []string{"@google"},
+		URLs:           []firefox.SearchEngineURL{{Template: "https://www.google.com/search?q={searchTerms}"}},
+	}, {
+		Name:     "Wiki",
+		LoadPath: "[user]",
+		MetaData: firefox.SearchEngineMeta{Alias: "w"},
+		URLs: []firefox.SearchEngineURL{{
+			Template: "https://en.wikipedia.org/w/index.php",
+			Params:   []firefox.SearchEngineURLParam{{Name: "search", Value: "{searchTerms}"}},
+		}},
+	}, {
+		Name: "Docs",
+		URLs: []firefox.SearchEngineURL{{Template: "https://www.docs.example.com/?q={searchTerms}"}},
+	}}}
+	engines := Custom(FromFirefox(s))
+	want := []Engine{{
+		Name:    "Wiki",
+		Keyword: "w",
+		URL:     "https://en.wikipedia.org/w/index.php?search={searchTerms}",
+		Custom:  true,
+		Browser: BrowserFirefox,
+		Source:  SourceFirefoxSearch,
+	}, {
+		Name:    "Docs",
+		URL:     "https://www.docs.example.com/?q={searchTerms}",
+		Custom:  true,
+		Browser: BrowserFirefox,
+		Source:  SourceFirefoxSearch,
+	}}
+	if !reflect.DeepEqual(engines, want) {
+		t.Fatalf("got:\n%+v\nwant:\n%+v", engines, want)
+	}
+
+	keywords := ToChrome(engines)
+	if len(keywords) != 2 || keywords[0].Keyword != "w" || keywords[1].Keyword != "docs.example.com" {
+		t.Errorf("got keywords %+v", keywords)
+	}
+	back := FromChrome(keywords)
+	for i := range back {
+		if back[i].URL != engines[i].URL || back[i].Name != engines[i].Name || !back[i].Custom {
+			t.Errorf("got %+v after round trip, want %+v", back[i], engines[i])
+		}
+	}
+}
+
+func TestTakeoutToFirefox(t *testing.T) {
+	created := time.Date(2021, 2, 18, 0, 0, 0, 0, time.UTC)
+	data := &takeout.Chrome{SearchEngines: []takeout.SearchEngine{{
+		ShortName:     "Google",
+		Keyword:       "google.com",
+		URL:           "{google:baseURL}search?q={searchTerms}",
+		PrepopulateID: 1,
+	}, {
+		ShortName:      "Maps",
+		Keyword:        "m",
+		URL:            "https://maps.example.com/?q={searchTerms}",
+		SuggestionsURL: "https://maps.example.com/suggest?q={searchTerms}",
+		DateCreated:    timefmt.Chrome{Time: created},
+		LastModified:   timefmt.Chrome{Time: created},
+	}}}
+	engines := Custom(FromTakeout(data))
+	if len(engines) != 1 || engines[0].Keyword != "m" || !engines[0].Created.Equal(created) {
+		t.Fatalf("got %+v", engines)
+	}
+	converted := ToFirefox(engines)
+	want := []firefox.SearchEngine{{
+		Name:         "Maps",
+		LoadPath:     "[user]",
+		QueryCharset: "UTF-8",
+		MetaData:     firefox.SearchEngineMeta{Alias: "m"},
+		URLs: []firefox.SearchEngineURL{
+			{Template: "https://maps.example.com/?q={searchTerms}", Type: firefox.SearchTypeHTML, Method: "GET"},
+			{Template: "https://maps.example.com/suggest?q={searchTerms}", Type: firefox.SearchTypeSuggestJSON, Method: "GET"},
+		},
+	}}
+	if !reflect.DeepEqual(converted, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", converted, want)
+	}
+	if got := FromFirefox(&firefox.SearchEngines{Engines: converted}); got[0].SuggestURL != engines[0].SuggestURL {
+		t.Errorf("got suggest URL %q after round trip, want %q", got[0].SuggestURL, engines[0].SuggestURL)
+	}
+	if got := ToTakeout(engines); got[0].Keyword != "m" || got[0].SuggestionsURL != engines[0].SuggestURL {
+		t.Errorf("got %+v", got)
+	}
+}
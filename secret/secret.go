@@ -0,0 +1,78 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package secret provides a string type for passwords, cookie values,
+// and other credentials that is redacted when formatted or marshaled,
+// so that secrets are not leaked into logs and reports by accident.
+package secret
+
+import (
+	"fmt"
+	"io"
+)
+
+// Secret is a sensitive string. It is printed by fmt and marshaled as
+// JSON or text as Redacted, unless empty. The plaintext is only
+// available from Reveal, so exporters that include secrets must opt in
+// explicitly. Unmarshaling reads plaintext.
+type Secret string
+
+// Redacted replaces a non-empty secret in output.
+const Redacted = "[REDACTED]"
+
+// Reveal returns the plaintext of the secret.
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+// IsEmpty reports whether the secret is empty, without revealing it.
+func (s Secret) IsEmpty() bool {
+	return s == ""
+}
+
+func (s Secret) redacted() string {
+	if s == "" {
+		return ""
+	}
+	return Redacted
+}
+
+// String returns Redacted, or "" when empty.
+func (s Secret) String() string {
+	return s.redacted()
+}
+
+// GoString returns the redacted secret as a Go literal, for %#v.
+func (s Secret) GoString() string {
+	return fmt.Sprintf("secret.Secret(%q)", s.redacted())
+}
+
+// Format formats the redacted secret for all verbs, including %x and
+// %q, which would otherwise bypass String.
+func (s Secret) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if f.Flag('#') {
+			io.WriteString(f, s.GoString())
+			return
+		}
+	case 'q':
+		fmt.Fprintf(f, "%q", s.redacted())
+		return
+	}
+	io.WriteString(f, s.redacted())
+}
+
+// MarshalText returns the redacted secret, which is also used for JSON.
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.redacted()), nil
+}
+
+// UnmarshalText sets the secret to the plaintext.
+func (s *Secret) UnmarshalText(text []byte) error {
+	*s = Secret(text)
+	return nil
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSecretRedacted(t *testing.T) {
+	s := Secret("hunter2")
+	v := struct {
+		User     string
+		Password Secret
+		Ptr      *Secret
+	}{"someone", s, &s}
+	for _, format := range []string{"%s", "%v", "%+v", "%#v", "%q", "%x", "%X", "%10s", "%d"} {
+		if got := fmt.Sprintf(format, v); strings.Contains(got, "hunter2") || strings.Contains(got, "68756e74657232") {
+			t.Errorf("%s: got: %s", format, got)
+		}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"User":"someone","Password":"[REDACTED]","Ptr":"[REDACTED]"}`; string(b) != want {
+		t.Errorf("got JSON: %s, want: %s", b, want)
+	}
+	if s.Reveal() != "hunter2" {
+		t.Errorf("got revealed: %q", s.Reveal())
+	}
+	if Secret("").String() != "" || !Secret("").IsEmpty() {
+		t.Error("empty secret not empty")
+	}
+
+	var u struct{ Password Secret }
+	if err := json.Unmarshal([]byte(`{"Password":"hunter2"}`), &u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Password.Reveal() != "hunter2" {
+		t.Errorf("got unmarshaled: %q", u.Password.Reveal())
+	}
+}
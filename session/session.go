@@ -0,0 +1,292 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package session provides a browser-independent model for sessions of
+// open windows and tabs, which keeps their organization, such as tab
+// groups, containers, pinned tabs, and window geometry, so that a
+// session migrated between browsers is not flattened into a list of
+// URLs.
+package session
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/extensions/tabcloud"
+	"github.com/andrewarchi/browser/firefox"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+)
+
+// Session is a set of open windows.
+type Session struct {
+	Windows    []Window
+	Containers []Container // referenced by Tab.Container
+	Browser    string      // e.g. "chrome" or "firefox"
+	Source     string      // source read from, e.g. "sessionstore.jsonlz4" or "tabcloud"
+}
+
+// Window is a browser window.
+type Window struct {
+	Name     string // user-assigned, when supported
+	Tabs     []Tab
+	Groups   []Group // referenced by Tab.Group
+	Selected int     // index of the selected tab, or -1
+	Bounds   Bounds
+	State    WindowState
+	Private  bool
+}
+
+// Bounds is the position and size of a window in screen pixels. It is
+// zero when unknown.
+type Bounds struct {
+	X, Y          int
+	Width, Height int
+}
+
+// WindowState is the show state of a window.
+type WindowState uint8
+
+// Values for WindowState:
+const (
+	StateNormal WindowState = iota
+	StateMaximized
+	StateMinimized
+	StateFullscreen
+)
+
+// Tab is a tab and its current page.
+type Tab struct {
+	URL          string
+	Title        string
+	Favicon      string // URL
+	Pinned       bool
+	Hidden       bool
+	Muted        bool
+	Group        string // ID of a group in Window.Groups, or empty
+	Container    int64  // ID of a container in Session.Containers, or 0
+	LastAccessed time.Time
+	History      []Entry // back-forward history, when known
+	HistoryIndex int     // index of the current entry in History
+}
+
+// Entry is an entry in the back-forward history of a tab.
+type Entry struct {
+	URL   string
+	Title string
+}
+
+// Group is a group of tabs in a window, like Chrome tab groups and
+// Firefox tab groups.
+type Group struct {
+	ID        string
+	Title     string
+	Color     string // e.g. "blue"
+	Collapsed bool
+}
+
+// Container is an identity that isolates the cookies and storage of
+// tabs, like Firefox containers.
+type Container struct {
+	ID    int64
+	Name  string
+	Color string // e.g. "blue"
+	Icon  string // e.g. "briefcase"
+}
+
+// Browsers and sources:
+const (
+	BrowserChrome  = "chrome"
+	BrowserFirefox = "firefox"
+
+	SourceChromeSavedTabGroups = "saved_tab_groups"
+	SourceFirefoxSession       = "sessionstore.jsonlz4"
+	SourceTabCloud             = "tabcloud"
+)
+
+// FromFirefox converts the open windows of a Firefox session. When
+// containers are given, from containers.json, the containers used by
+// tabs are named.
+func FromFirefox(s *firefox.Session, containers *firefox.Containers) *Session {
+	out := &Session{Browser: BrowserFirefox, Source: SourceFirefoxSession}
+	used := make(map[int64]bool)
+	for _, w := range s.Windows {
+		win := Window{
+			Selected: w.Selected - 1,
+			Bounds:   Bounds{w.ScreenX, w.ScreenY, w.Width, w.Height},
+			State:    parseSizeMode(w.SizeMode),
+			Private:  w.IsPrivate,
+		}
+		if win.Selected >= len(w.Tabs) {
+			win.Selected = -1
+		}
+		for _, g := range w.Groups {
+			win.Groups = append(win.Groups, Group{ID: g.ID, Title: g.Name, Color: g.Color, Collapsed: g.Collapsed})
+		}
+		for _, t := range w.Tabs {
+			tab := Tab{
+				Favicon:      t.Image,
+				Pinned:       t.Pinned,
+				Hidden:       t.Hidden,
+				Muted:        t.Muted,
+				Group:        t.GroupID,
+				Container:    int64(t.UserContextID),
+				LastAccessed: t.LastAccessed.Time,
+				HistoryIndex: t.Index - 1,
+			}
+			for _, e := range t.Entries {
+				tab.History = append(tab.History, Entry{URL: e.URL, Title: e.Title})
+			}
+			if cur := t.Current(); cur != nil {
+				tab.URL, tab.Title = cur.URL, cur.Title
+			}
+			if tab.HistoryIndex < 0 || tab.HistoryIndex >= len(tab.History) {
+				tab.HistoryIndex = len(tab.History) - 1
+			}
+			if tab.Container != firefox.NoUserContextID {
+				used[tab.Container] = true
+			}
+			win.Tabs = append(win.Tabs, tab)
+		}
+		out.Windows = append(out.Windows, win)
+	}
+	if containers != nil {
+		for _, id := range containers.Identities {
+			if used[id.UserContextID] {
+				out.Containers = append(out.Containers, Container{
+					ID:    id.UserContextID,
+					Name:  id.DisplayName(),
+					Color: string(id.Color),
+					Icon:  string(id.Icon),
+				})
+			}
+		}
+	}
+	return out
+}
+
+// ToFirefox converts a session to a Firefox session, which can be
+// written to sessionstore.jsonlz4 with firefox.WriteSession. Tabs in
+// containers keep their IDs, so the containers must be defined in the
+// target profile.
+func ToFirefox(s *Session) *firefox.Session {
+	out := &firefox.Session{
+		Version:        []interface{}{"sessionrestore", 1.0},
+		ClosedWindows:  []firefox.SessionWindow{},
+		SelectedWindow: 1,
+	}
+	for _, w := range s.Windows {
+		win := firefox.SessionWindow{
+			Selected:   w.Selected + 1,
+			ScreenX:    w.Bounds.X,
+			ScreenY:    w.Bounds.Y,
+			Width:      w.Bounds.Width,
+			Height:     w.Bounds.Height,
+			SizeMode:   formatSizeMode(w.State),
+			IsPrivate:  w.Private,
+			ClosedTabs: []firefox.ClosedTab{},
+		}
+		for _, g := range w.Groups {
+			win.Groups = append(win.Groups, firefox.SessionTabGroup{ID: g.ID, Name: g.Title, Color: g.Color, Collapsed: g.Collapsed})
+		}
+		for _, t := range w.Tabs {
+			tab := firefox.SessionTab{
+				Index:         t.HistoryIndex + 1,
+				LastAccessed:  timefmt.UnixMilli{Time: t.LastAccessed},
+				Pinned:        t.Pinned,
+				Hidden:        t.Hidden,
+				Muted:         t.Muted,
+				UserContextID: int(t.Container),
+				Image:         t.Favicon,
+				GroupID:       t.Group,
+			}
+			history := t.History
+			if len(history) == 0 {
+				history = []Entry{{URL: t.URL, Title: t.Title}}
+				tab.Index = 1
+			}
+			for i, e := range history {
+				tab.Entries = append(tab.Entries, firefox.SessionEntry{URL: e.URL, Title: e.Title, ID: i + 1})
+			}
+			win.Tabs = append(win.Tabs, tab)
+		}
+		out.Windows = append(out.Windows, win)
+	}
+	return out
+}
+
+// FromTabCloud converts the windows saved by the TabCloud extension,
+// which records only names, URLs, and pinned state.
+func FromTabCloud(windows []tabcloud.Window) *Session {
+	out := &Session{Browser: BrowserChrome, Source: SourceTabCloud}
+	for _, w := range windows {
+		win := Window{Name: w.Name, Selected: -1}
+		for _, t := range w.Tabs {
+			win.Tabs = append(win.Tabs, Tab{URL: t.URL, Title: t.Title, Favicon: t.Favicon, Pinned: t.Pinned})
+		}
+		out.Windows = append(out.Windows, win)
+	}
+	return out
+}
+
+// FromChromeSavedTabGroups converts the saved tab groups of a Chrome
+// profile to a window containing the tabs of every group, in order, so
+// that they can be reopened as groups.
+func FromChromeSavedTabGroups(groups []chrome.SavedTabGroup) *Session {
+	out := &Session{Browser: BrowserChrome, Source: SourceChromeSavedTabGroups}
+	win := Window{Selected: -1}
+	for _, g := range groups {
+		win.Groups = append(win.Groups, Group{ID: g.GUID, Title: g.Title, Color: g.Color.String()})
+		for _, t := range g.Tabs {
+			win.Tabs = append(win.Tabs, Tab{URL: t.URL, Title: t.Title, Group: g.GUID})
+		}
+	}
+	if len(win.Tabs) != 0 {
+		out.Windows = []Window{win}
+	}
+	return out
+}
+
+func parseSizeMode(mode string) WindowState {
+	switch mode {
+	case "maximized":
+		return StateMaximized
+	case "minimized":
+		return StateMinimized
+	case "fullscreen":
+		return StateFullscreen
+	default:
+		return StateNormal
+	}
+}
+
+func formatSizeMode(state WindowState) string {
+	switch state {
+	case StateMaximized:
+		return "maximized"
+	case StateMinimized:
+		return "minimized"
+	case StateFullscreen:
+		return "fullscreen"
+	default:
+		return "normal"
+	}
+}
+
+func (state WindowState) String() string {
+	switch state {
+	case StateNormal:
+		return "normal"
+	case StateMaximized:
+		return "maximized"
+	case StateMinimized:
+		return "minimized"
+	case StateFullscreen:
+		return "fullscreen"
+	default:
+		return fmt.Sprintf("state(%d)", uint8(state))
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package session
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/browser/chrome"
+	"github.com/andrewarchi/browser/extensions/tabcloud"
+	"github.com/andrewarchi/browser/firefox"
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+)
+
+func TestFirefox(t *testing.T) {
+	accessed := time.Unix(1613610123, 456000000).UTC()
+	s := &firefox.Session{Windows: []firefox.SessionWindow{{
+		Selected: 2,
+		Width:    1280, Height: 800, ScreenX: 10, ScreenY: 20,
+		SizeMode: "maximized",
+		Groups:   []firefox.SessionTabGroup{{ID: "1613610123456-1", Name: "Work", Color: "blue", Collapsed: true}},
+		Tabs: []firefox.SessionTab{
+			{
+				Entries:      []firefox.SessionEntry{{URL: "https://a.example/", Title: "A", ID: 1}},
+				Index:        1,
+				LastAccessed: timefmt.UnixMilli{Time: accessed},
+				Pinned:       true,
+			},
+			{
+				Entries: []firefox.SessionEntry{
+					{URL: "https://b.example/", Title: "B", ID: 1},
+					{URL: "https://b.example/2", Title: "B2", ID: 2},
+				},
+				Index:         1,
+				LastAccessed:  timefmt.UnixMilli{Time: accessed},
+				UserContextID: 2,
+				GroupID:       "1613610123456-1",
+			},
+		},
+	}}}
+	containers := &firefox.Containers{Identities: []firefox.ContainerIdentity{
+		{UserContextID: 1, Icon: firefox.IconFingerprint, Color: firefox.ColorBlue, L10nID: "userContextPersonal.label"},
+		{UserContextID: 2, Icon: firefox.IconBriefcase, Color: firefox.ColorOrange, L10nID: "userContextWork.label"},
+	}}
+
+	got := FromFirefox(s, containers)
+	want := &Session{
+		Windows: []Window{{
+			Tabs: []Tab{
+				{URL: "https://a.example/", Title: "A", Pinned: true, LastAccessed: accessed,
+					History: []Entry{{"https://a.example/", "A"}}},
+				{URL: "https://b.example/", Title: "B", Group: "1613610123456-1", Container: 2, LastAccessed: accessed,
+					History: []Entry{{"https://b.example/", "B"}, {"https://b.example/2", "B2"}}},
+			},
+			Groups:   []Group{{ID: "1613610123456-1", Title: "Work", Color: "blue", Collapsed: true}},
+			Selected: 1,
+			Bounds:   Bounds{10, 20, 1280, 800},
+			State:    StateMaximized,
+		}},
+		Containers: []Container{{ID: 2, Name: "Work", Color: "orange", Icon: "briefcase"}},
+		Browser:    BrowserFirefox,
+		Source:     SourceFirefoxSession,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got:\n%+v\nwant:\n%+v", got, want)
+	}
+
+	// Converting to Firefox and back keeps the organization.
+	var buf bytes.Buffer
+	if err := firefox.WriteSession(&buf, ToFirefox(got)); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "sessionstore.jsonlz4")
+	if err := os.WriteFile(filename, buf.Bytes(), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := firefox.ParseSession(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got2 := FromFirefox(restored, containers); !reflect.DeepEqual(got2, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got2, want)
+	}
+}
+
+func TestFromTabCloud(t *testing.T) {
+	got := FromTabCloud([]tabcloud.Window{{Name: "Reading", Tabs: []tabcloud.Tab{
+		{URL: "https://a.example/", Title: "A", Pinned: true},
+		{URL: "https://b.example/", Title: "B", Favicon: "https://b.example/favicon.ico"},
+	}}})
+	want := &Session{
+		Windows: []Window{{Name: "Reading", Selected: -1, Tabs: []Tab{
+			{URL: "https://a.example/", Title: "A", Pinned: true},
+			{URL: "https://b.example/", Title: "B", Favicon: "https://b.example/favicon.ico"},
+		}}},
+		Browser: BrowserChrome,
+		Source:  SourceTabCloud,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got, want)
+	}
+}
+
+func TestFromChromeSavedTabGroups(t *testing.T) {
+	got := FromChromeSavedTabGroups([]chrome.SavedTabGroup{
+		{GUID: "g1", Title: "Work", Color: chrome.TabGroupBlue, Tabs: []chrome.SavedTab{
+			{URL: "https://mail.example.com/", Title: "Mail"},
+			{URL: "https://docs.example.com/", Title: "Docs"},
+		}},
+		{GUID: "g2", Title: "Recipes", Color: chrome.TabGroupOrange, Tabs: []chrome.SavedTab{
+			{URL: "https://food.example/", Title: "Soup"},
+		}},
+	})
+	want := &Session{
+		Windows: []Window{{
+			Selected: -1,
+			Groups:   []Group{{ID: "g1", Title: "Work", Color: "blue"}, {ID: "g2", Title: "Recipes", Color: "orange"}},
+			Tabs: []Tab{
+				{URL: "https://mail.example.com/", Title: "Mail", Group: "g1"},
+				{URL: "https://docs.example.com/", Title: "Docs", Group: "g1"},
+				{URL: "https://food.example/", Title: "Soup", Group: "g2"},
+			},
+		}},
+		Browser: BrowserChrome,
+		Source:  SourceChromeSavedTabGroups,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got, want)
+	}
+}
@@ -0,0 +1,405 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sqliteutil
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+// SQLite file format:
+// https://www.sqlite.org/fileformat2.html
+//
+// Deleted rows often remain in the database file until their pages are
+// reused: pages freed by a delete are added to the freelist with their
+// contents intact, unless secure_delete is enabled, and earlier
+// versions of pages remain in the write-ahead log until it is
+// checkpointed and reset. Recover carves table rows from both.
+
+// RecordSource is where a recovered record was found.
+type RecordSource uint8
+
+// Values for RecordSource:
+const (
+	SourceFreelist RecordSource = iota + 1 // freelist leaf page in the database
+	SourceWAL                              // page frame in the -wal file
+)
+
+// RecoveredRecord is a row carved from a table b-tree leaf page that is
+// not reachable from the live database. It may be a deleted row, an
+// older version of a live row, or a copy of a live row, so callers must
+// compare it with the live rows. Recovered data is unreliable.
+type RecoveredRecord struct {
+	Source    RecordSource
+	Page      uint32 // database page number
+	Frame     int    // index of the WAL frame, for SourceWAL
+	RowID     int64
+	Values    []interface{} // int64, float64, string, []byte, or nil
+	Truncated bool          // the overflow pages of the payload could not be read
+}
+
+// Recover carves the rows in the freed pages of an SQLite database and
+// in the frames of its write-ahead log, if it has one. The database is
+// read directly, without SQLite, so it may be open by another process.
+// Pages that are not table b-tree leaf pages are skipped.
+func Recover(filename string) ([]RecoveredRecord, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	db, err := parseFileHeader(data)
+	if err != nil {
+		return nil, fmt.Errorf("sqliteutil: recover %s: %w", filename, err)
+	}
+
+	var records []RecoveredRecord
+	for _, page := range db.freelistLeaves() {
+		b := db.page(page, -1)
+		for _, r := range db.carvePage(b, page == 1, -1) {
+			r.Source, r.Page = SourceFreelist, page
+			records = append(records, r)
+		}
+	}
+
+	wal, err := os.ReadFile(filename + "-wal")
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	} else if err != nil {
+		return nil, err
+	}
+	frames, err := walFrames(wal, db.pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("sqliteutil: recover %s-wal: %w", filename, err)
+	}
+	db.frames = frames
+	db.walPages = make(map[uint32][]int)
+	for i, f := range frames {
+		db.walPages[f.page] = append(db.walPages[f.page], i)
+	}
+	// Read overflow pages as of the commit of the transaction that wrote
+	// each frame.
+	commits := make([]int, len(frames))
+	commit := len(frames) - 1
+	for i := len(frames) - 1; i >= 0; i-- {
+		if frames[i].commit {
+			commit = i
+		}
+		commits[i] = commit
+	}
+	for i, f := range frames {
+		for _, r := range db.carvePage(f.data, f.page == 1, commits[i]) {
+			r.Source, r.Page, r.Frame = SourceWAL, f.page, i
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+type dbFile struct {
+	data       []byte
+	pageSize   int
+	usableSize int
+	pageCount  uint32
+	freelist   uint32           // first freelist trunk page
+	frames     []walFrame       // frames in the WAL
+	walPages   map[uint32][]int // indexes of the frames of each page
+}
+
+func parseFileHeader(data []byte) (*dbFile, error) {
+	if len(data) < 100 || string(data[:16]) != "SQLite format 3\x00" {
+		return nil, errors.New("not an SQLite database")
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("invalid page size %d", pageSize)
+	}
+	return &dbFile{
+		data:       data,
+		pageSize:   pageSize,
+		usableSize: pageSize - int(data[20]),
+		pageCount:  uint32(len(data) / pageSize),
+		freelist:   binary.BigEndian.Uint32(data[32:]),
+	}, nil
+}
+
+// page returns the contents of a page as of a WAL frame, which is its
+// latest frame at or before it, or else the page in the database file.
+// A negative frame reads only the database file. It returns nil if the
+// page is past the end of the file.
+func (db *dbFile) page(n uint32, frame int) []byte {
+	frames := db.walPages[n]
+	for i := len(frames) - 1; i >= 0; i-- {
+		if frames[i] <= frame {
+			return db.frames[frames[i]].data
+		}
+	}
+	if n == 0 || n > db.pageCount {
+		return nil
+	}
+	off := int(n-1) * db.pageSize
+	return db.data[off : off+db.pageSize]
+}
+
+// freelistLeaves returns the leaf pages of the freelist. Trunk pages
+// hold only page numbers after their headers.
+func (db *dbFile) freelistLeaves() []uint32 {
+	var leaves []uint32
+	seen := make(map[uint32]bool)
+	for trunk := db.freelist; trunk != 0 && !seen[trunk]; {
+		seen[trunk] = true
+		b := db.page(trunk, -1)
+		if b == nil {
+			break
+		}
+		n := int(binary.BigEndian.Uint32(b[4:]))
+		if n > (db.usableSize-8)/4 {
+			break
+		}
+		for i := 0; i < n; i++ {
+			leaves = append(leaves, binary.BigEndian.Uint32(b[8+4*i:]))
+		}
+		trunk = binary.BigEndian.Uint32(b)
+	}
+	return leaves
+}
+
+type walFrame struct {
+	page   uint32
+	commit bool // last frame of a transaction
+	data   []byte
+}
+
+func walFrames(wal []byte, pageSize int) ([]walFrame, error) {
+	if len(wal) < 32 {
+		return nil, nil // empty log
+	}
+	if magic := binary.BigEndian.Uint32(wal); magic != 0x377f0682 && magic != 0x377f0683 {
+		return nil, errors.New("not a write-ahead log")
+	}
+	if size := int(binary.BigEndian.Uint32(wal[8:])); size != pageSize {
+		return nil, fmt.Errorf("page size %d differs from database page size %d", size, pageSize)
+	}
+	var frames []walFrame
+	for off := 32; off+24+pageSize <= len(wal); off += 24 + pageSize {
+		frames = append(frames, walFrame{
+			page:   binary.BigEndian.Uint32(wal[off:]),
+			commit: binary.BigEndian.Uint32(wal[off+4:]) != 0,
+			data:   wal[off+24 : off+24+pageSize],
+		})
+	}
+	return frames, nil
+}
+
+// carvePage parses the cells of a table b-tree leaf page, reading
+// overflow pages as of a WAL frame. Malformed cells are skipped.
+func (db *dbFile) carvePage(b []byte, first bool, frame int) []RecoveredRecord {
+	hdr := 0
+	if first {
+		hdr = 100
+	}
+	if len(b) < hdr+8 || b[hdr] != 0x0d {
+		return nil
+	}
+	cells := int(binary.BigEndian.Uint16(b[hdr+3:]))
+	if hdr+8+2*cells > db.usableSize {
+		return nil
+	}
+	var records []RecoveredRecord
+	for i := 0; i < cells; i++ {
+		ptr := int(binary.BigEndian.Uint16(b[hdr+8+2*i:]))
+		if ptr < hdr+8+2*cells || ptr >= db.usableSize {
+			continue
+		}
+		if r, ok := db.carveCell(b[:db.usableSize], ptr, frame); ok {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+func (db *dbFile) carveCell(b []byte, off, frame int) (RecoveredRecord, bool) {
+	var r RecoveredRecord
+	size, n := readVarint(b[off:])
+	if n == 0 || size > math.MaxInt32 {
+		return r, false
+	}
+	off += n
+	rowID, n := readVarint(b[off:])
+	if n == 0 {
+		return r, false
+	}
+	off += n
+	r.RowID = int64(rowID)
+
+	payload, truncated, ok := db.payload(b, off, int(size), frame)
+	if !ok {
+		return r, false
+	}
+	values, ok := parseRecord(payload, truncated)
+	if !ok {
+		return r, false
+	}
+	r.Values, r.Truncated = values, truncated
+	return r, true
+}
+
+// payload reads the payload of a table leaf cell, following overflow
+// pages as of a WAL frame.
+func (db *dbFile) payload(b []byte, off, size, frame int) ([]byte, bool, bool) {
+	u := db.usableSize
+	x := u - 35
+	local := size
+	if size > x {
+		m := (u-12)*32/255 - 23
+		local = m + (size-m)%(u-4)
+		if local > x {
+			local = m
+		}
+	}
+	if off+local > len(b) {
+		return nil, false, false
+	}
+	if local == size {
+		return b[off : off+size], false, true
+	}
+	if off+local+4 > len(b) {
+		return nil, false, false
+	}
+	payload := append([]byte(nil), b[off:off+local]...)
+	next := binary.BigEndian.Uint32(b[off+local:])
+	seen := make(map[uint32]bool)
+	for len(payload) < size {
+		page := db.page(next, frame)
+		if page == nil || seen[next] {
+			return payload, true, true
+		}
+		seen[next] = true
+		n := size - len(payload)
+		if n > u-4 {
+			n = u - 4
+		}
+		payload = append(payload, page[4:4+n]...)
+		next = binary.BigEndian.Uint32(page)
+	}
+	return payload, false, true
+}
+
+// parseRecord parses a record in the SQLite record format. When the
+// payload is truncated, the values past its end are nil.
+func parseRecord(b []byte, truncated bool) ([]interface{}, bool) {
+	hdrSize, n := readVarint(b)
+	if n == 0 || hdrSize < uint64(n) || hdrSize > uint64(len(b)) {
+		return nil, false
+	}
+	var types []uint64
+	for off := n; off < int(hdrSize); {
+		t, n := readVarint(b[off:int(hdrSize)])
+		if n == 0 || t == 10 || t == 11 {
+			return nil, false
+		}
+		types = append(types, t)
+		off += n
+	}
+	values := make([]interface{}, len(types))
+	off := int(hdrSize)
+	for i, t := range types {
+		// Garbage serial types can have sizes that overflow int, so
+		// check the size before converting it.
+		size := serialSize(t)
+		if size > uint64(len(b)-off) {
+			if truncated {
+				return values, true
+			}
+			return nil, false
+		}
+		v := b[off : off+int(size)]
+		switch {
+		case t == 0:
+			values[i] = nil
+		case t <= 6:
+			values[i] = readInt(v)
+		case t == 7:
+			values[i] = math.Float64frombits(binary.BigEndian.Uint64(v))
+		case t == 8:
+			values[i] = int64(0)
+		case t == 9:
+			values[i] = int64(1)
+		case t%2 == 0:
+			values[i] = append([]byte(nil), v...)
+		default:
+			values[i] = string(v)
+		}
+		off += int(size)
+	}
+	if off != len(b) {
+		return nil, false
+	}
+	return values, true
+}
+
+// serialSize returns the size in bytes of a value with serial type t.
+func serialSize(t uint64) uint64 {
+	switch t {
+	case 0, 8, 9:
+		return 0
+	case 1, 2, 3, 4:
+		return t
+	case 5:
+		return 6
+	case 6, 7:
+		return 8
+	}
+	if t%2 == 0 {
+		return (t - 12) / 2
+	}
+	return (t - 13) / 2
+}
+
+// readInt reads a big-endian two's complement integer of 1 to 8 bytes.
+func readInt(b []byte) int64 {
+	v := int64(int8(b[0]))
+	for _, c := range b[1:] {
+		v = v<<8 | int64(c)
+	}
+	return v
+}
+
+// readVarint reads an SQLite variable-length integer, returning its
+// length, or 0 if b is too short.
+func readVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9; i++ {
+		if i >= len(b) {
+			return 0, 0
+		}
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v, 9
+}
+
+func (s RecordSource) String() string {
+	switch s {
+	case SourceFreelist:
+		return "freelist"
+	case SourceWAL:
+		return "wal"
+	default:
+		return fmt.Sprintf("source(%d)", uint8(s))
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sqliteutil
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	for _, wal := range []bool{false, true} {
+		filename := filepath.Join(t.TempDir(), "test.sqlite")
+		db, err := sql.Open("sqlite3", filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.SetMaxOpenConns(1)
+		mode := "DELETE"
+		if wal {
+			mode = "WAL"
+		}
+		for _, q := range []string{
+			`PRAGMA secure_delete = OFF`,
+			`PRAGMA journal_mode = ` + mode,
+			`PRAGMA wal_autocheckpoint = 0`,
+			`CREATE TABLE urls (id INTEGER PRIMARY KEY, url TEXT, n INTEGER, score REAL)`,
+		} {
+			if _, err := db.Exec(q); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := 1; i <= 200; i++ {
+			url := fmt.Sprintf("https://example.com/%d/%s", i, strings.Repeat("x", 50))
+			if i == 7 {
+				url += strings.Repeat("y", 10000) // overflows
+			}
+			if _, err := db.Exec(`INSERT INTO urls VALUES (?, ?, ?, ?)`, i, url, -i, 0.5); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := db.Exec(`DELETE FROM urls WHERE id > 1`); err != nil {
+			t.Fatal(err)
+		}
+
+		records, err := Recover(filename)
+		if err != nil {
+			t.Fatalf("wal=%t: %v", wal, err)
+		}
+		found := make(map[int64]bool)
+		for _, r := range records {
+			if len(r.Values) != 4 || r.Values[0] != nil {
+				continue // sqlite_master
+			}
+			found[r.RowID] = true
+			if r.Truncated && !wal {
+				continue // overflow pages reused by the freelist
+			}
+			url, ok := r.Values[1].(string)
+			if !ok || !strings.HasPrefix(url, fmt.Sprintf("https://example.com/%d/", r.RowID)) ||
+				r.Values[2] != -r.RowID || r.Values[3] != 0.5 {
+				t.Errorf("wal=%t: row %d: got values %.40v", wal, r.RowID, r.Values)
+			}
+			if want := SourceFreelist; r.Source != want && !wal {
+				t.Errorf("wal=%t: got source %s, want %s", wal, r.Source, want)
+			}
+			if r.RowID == 7 && (r.Truncated || len(url) < 10000) {
+				t.Errorf("wal=%t: overflow payload not read: truncated=%t, length %d", wal, r.Truncated, len(url))
+			}
+		}
+		for i := int64(2); i <= 200; i++ {
+			if !found[i] {
+				t.Errorf("wal=%t: row %d not recovered", wal, i)
+				break
+			}
+		}
+		db.Close()
+	}
+}
+
+func TestParseRecordGarbage(t *testing.T) {
+	for _, b := range [][]byte{
+		// Serial type 0xffffffffffffffff as a 9-byte varint.
+		{10, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 'a'},
+		// Serial type 0xfffffffffffffffe.
+		{10, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe, 'a'},
+		// Serial type 1<<63 + 13, half of which wraps to negative int.
+		{10, 0x81, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x0d, 'a'},
+		// Reserved serial type 10.
+		{2, 10},
+		// Header size past the end.
+		{5, 1, 1},
+	} {
+		for _, truncated := range []bool{false, true} {
+			values, ok := parseRecord(b, truncated)
+			if ok && (!truncated || len(values) != 0 && values[0] != nil) {
+				t.Errorf("parseRecord(%x, %t) = %v, true", b, truncated, values)
+			}
+		}
+	}
+
+	values, ok := parseRecord([]byte{3, 1, 23, 42, 'a', 'b', 'c', 'd', 'e'}, false)
+	if !ok || len(values) != 2 || values[0] != int64(42) || values[1] != "abcde" {
+		t.Errorf("got %v, %t", values, ok)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package sqliteutil provides utilities for reading SQLite databases.
+package sqliteutil
+
+import (
+	"database/sql"
+	"net/url"
+	"os"
+
+	_ "github.com/mattn/go-sqlite3" // register sqlite3 driver
+)
+
+// Open opens an SQLite database for reading. Unlike sql.Open, the file
+// must already exist and errors from os.Stat are returned unchanged, so
+// that callers can check for os.ErrNotExist.
+//
+// Browsers hold exclusive locks on some databases while running, so
+// reading may fail with "database is locked" until the browser is
+// closed or the database is copied elsewhere.
+func Open(filename string) (*sql.DB, error) {
+	if _, err := os.Stat(filename); err != nil {
+		return nil, err
+	}
+	u := url.URL{Scheme: "file", Opaque: (&url.URL{Path: filename}).EscapedPath(), RawQuery: "mode=ro"}
+	db, err := sql.Open("sqlite3", u.String())
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Query executes a query and calls scan for each row. The rows are
+// closed before returning.
+func Query(db *sql.DB, query string, scan func(rows *sql.Rows) error, args ...interface{}) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// HasTable reports whether the database contains the named table.
+func HasTable(db *sql.DB, table string) (bool, error) {
+	var n int
+	err := db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&n)
+	return n != 0, err
+}
+
+// Columns returns the names of the columns in a table.
+func Columns(db *sql.DB, table string) ([]string, error) {
+	var cols []string
+	err := Query(db, `SELECT name FROM pragma_table_info(?)`, func(rows *sql.Rows) error {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		cols = append(cols, name)
+		return nil
+	}, table)
+	return cols, err
+}
@@ -11,9 +11,11 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"time"
 
 	"github.com/andrewarchi/archive"
+	"github.com/andrewarchi/browser"
 	"github.com/andrewarchi/browser/bookmark"
 	"github.com/andrewarchi/browser/chrome"
 	"github.com/andrewarchi/browser/jsonutil"
@@ -30,6 +32,8 @@ type Chrome struct {
 	Bookmarks []bookmark.BookmarkEntry
 	// BrowserHistory.json
 	BrowserHistory []Visit `json:"Browser History"`
+	// Device Information.json
+	DeviceInfo []Device `json:"Device Info"`
 	// Dictionary.csv - TODO unknown structure
 	// Extensions.json
 	Extensions        []Extension        `json:"Extensions"`
@@ -42,6 +46,28 @@ type Chrome struct {
 	Preferences  []Preference           `json:"Preferences"`
 	Themes       []Theme                `json:"Themes"`
 	ManagedUsers []jsonutil.UnknownType `json:"Managed Users"`
+
+	// Unknown lists the files that were not parsed, because their data
+	// type or structure is unknown, with ChromeOptions.SkipUnknown.
+	Unknown []UnknownFile `json:"-"`
+}
+
+// UnknownFile is a file in a Takeout export that was not parsed.
+type UnknownFile struct {
+	Name string // path in the export, e.g. "Takeout/Chrome/Dictionary.csv"
+	Size int64
+	Kind Kind
+}
+
+// ChromeOptions configures ParseChromeOptions.
+type ChromeOptions struct {
+	browser.Options
+
+	// SkipUnknown records files of unknown data type or structure in
+	// Chrome.Unknown and continues, instead of handling them as errors,
+	// so that files added to Takeout after this package are reported
+	// without failing the export.
+	SkipUnknown bool
 }
 
 type AutofillProfile struct {
@@ -79,6 +105,90 @@ type Visit struct {
 	Time           timefmt.UnixMicro     `json:"time_usec"`
 }
 
+// Device is a device syncing with the account. Each device has a
+// unique cache GUID, which identifies the device in the client_id of
+// history visits.
+type Device struct {
+	CacheGUID            string               `json:"cache_guid"`  // base64
+	ClientName           string               `json:"client_name"` // e.g. "Pixel 4" or hostname
+	DeviceType           string               `json:"device_type"` // e.g. "TYPE_LINUX", "TYPE_PHONE"
+	SyncUserAgent        string               `json:"sync_user_agent"`
+	ChromeVersion        string               `json:"chrome_version"`
+	SigninScopedDeviceID string               `json:"signin_scoped_device_id"`
+	LastUpdatedTimestamp timefmt.UnixMilli    `json:"last_updated_timestamp"`
+	Manufacturer         string               `json:"manufacturer,omitempty"`
+	Model                string               `json:"model,omitempty"`
+	FeatureFields        *DeviceFeatureFields `json:"feature_fields,omitempty"`
+	SharingFields        jsonutil.UnknownObj  `json:"sharing_fields"`
+	InvalidationFields   jsonutil.UnknownObj  `json:"invalidation_fields"`
+	PhoneAsASecurityKey  jsonutil.UnknownType `json:"paask_fields"`
+}
+
+// DeviceFeatureFields lists features supported by a device.
+type DeviceFeatureFields struct {
+	SendTabToSelfReceivingEnabled bool `json:"send_tab_to_self_receiving_enabled"`
+}
+
+// Device returns the device that a visit was synced from, or nil when
+// the visit has no client ID or the device is not listed. To look up
+// the devices of many visits, use Devices.
+func (c *Chrome) Device(v *Visit) *Device {
+	if len(v.ClientID) == 0 {
+		return nil
+	}
+	return c.Devices().Device(v)
+}
+
+// DeviceIndex looks up devices by the client IDs of visits. The key is
+// the decoded cache GUID.
+type DeviceIndex map[string]*Device
+
+// Devices indexes the devices by cache GUID, decoding each GUID once.
+// When devices share a GUID, the first is used. Devices with GUIDs that
+// are not base64 match no visits.
+func (c *Chrome) Devices() DeviceIndex {
+	idx := make(DeviceIndex, len(c.DeviceInfo))
+	for i := range c.DeviceInfo {
+		var guid jsonutil.Base64
+		if err := guid.UnmarshalText([]byte(c.DeviceInfo[i].CacheGUID)); err != nil {
+			continue
+		}
+		if _, ok := idx[string(guid)]; !ok {
+			idx[string(guid)] = &c.DeviceInfo[i]
+		}
+	}
+	return idx
+}
+
+// Device returns the device that a visit was synced from, or nil when
+// the visit has no client ID or the device is not listed.
+func (idx DeviceIndex) Device(v *Visit) *Device {
+	if len(v.ClientID) == 0 {
+		return nil
+	}
+	return idx[string(v.ClientID)]
+}
+
+// HistoryByDevice splits browser history by the device that each visit
+// was synced from. The key is the cache GUID of the device. Visits
+// without a client ID are keyed by "" and visits with client IDs of
+// unlisted devices are keyed by the base64 client ID. Visits retain
+// their order within each device.
+func (c *Chrome) HistoryByDevice() map[string][]Visit {
+	devices := c.Devices()
+	byDevice := make(map[string][]Visit)
+	for _, v := range c.BrowserHistory {
+		var key string
+		if d := devices.Device(&v); d != nil {
+			key = d.CacheGUID
+		} else if len(v.ClientID) != 0 {
+			key = v.ClientID.String()
+		}
+		byDevice[key] = append(byDevice[key], v)
+	}
+	return byDevice
+}
+
 type Extension struct {
 	IncognitoEnabled     bool   `json:"incognito_enabled"`
 	RemoteInstall        bool   `json:"remote_install"`
@@ -139,16 +249,32 @@ type Theme struct {
 	UseCustomTheme          bool `json:"use_custom_theme"`
 }
 
-// ParseChrome parses Chrome data in a Takeout export.
+// ParseChrome parses Chrome data in a Takeout export. A file that fails
+// to parse, such as a file added to Takeout after this package, does
+// not stop the export from being parsed: the data that was parsed is
+// returned with the errors as browser.Errors.
 func ParseChrome(filename string) (*Chrome, error) {
+	return ParseChromeOptions(filename, ChromeOptions{})
+}
+
+// ParseChromeOptions parses Chrome data in a Takeout export and handles
+// errors by the error policy in opts. Files are parsed as a whole, so
+// the data in a file that fails to parse is dropped.
+func ParseChromeOptions(filename string, opts ChromeOptions) (*Chrome, error) {
 	ex, err := NewExport(filename)
 	if err != nil {
 		return nil, err
 	}
 	data := &Chrome{ExportTime: ex.Time}
-	err = ex.Walk(func(f archive.File) error {
-		dir, base := filepath.Split(f.Name())
-		if dir != "Takeout/Chrome/" {
+	unknown := func(f archive.File, kind Kind, err error) error {
+		if !opts.SkipUnknown {
+			return err
+		}
+		data.Unknown = append(data.Unknown, UnknownFile{f.Name(), f.FileInfo().Size(), kind})
+		return nil
+	}
+	err = ex.WalkOptions(opts.Options, func(f archive.File, kind Kind) error {
+		if dir, _ := filepath.Split(f.Name()); dir != "Takeout/Chrome/" {
 			return nil
 		}
 		r, err := f.Open()
@@ -156,29 +282,45 @@ func ParseChrome(filename string) (*Chrome, error) {
 			return err
 		}
 		defer r.Close()
-		switch base {
-		case "Autofill.json", "BrowserHistory.json", "Extensions.json",
-			"SearchEngines.json", "SyncSettings.json":
-			return jsonutil.Decode(r, data)
-		case "Bookmarks.html":
+		switch kind.DataType {
+		case "Autofill", "BrowserHistory", "DeviceInformation",
+			"Extensions", "SearchEngines", "SyncSettings":
+			var file Chrome
+			if err := jsonutil.Decode(r, &file); err != nil {
+				return err
+			}
+			mergeChrome(data, &file)
+		case "Bookmarks":
 			b, err := bookmark.ParseHTML(r)
 			if err != nil {
 				return err
 			}
 			data.Bookmarks = b
-		case "Dictionary.csv": // TODO unknown structure
+		case "Dictionary": // TODO unknown structure
 			if f.FileInfo().Size() != 0 {
-				return errors.New("dictionary structure unknown")
+				return unknown(f, kind, errors.New("dictionary structure unknown"))
 			}
 		default:
-			return errors.New("unknown file")
+			return unknown(f, kind, errors.New("unknown file"))
 		}
 		return nil
 	})
-	if err != nil {
+	var errs browser.Errors
+	if err != nil && !errors.As(err, &errs) {
 		return nil, err
 	}
-	return data, nil
+	return data, err
+}
+
+// mergeChrome sets the fields of dst that are set in src, which holds
+// the data of a single file.
+func mergeChrome(dst, src *Chrome) {
+	d, s := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+	for i := 0; i < s.NumField(); i++ {
+		if f := s.Field(i); !f.IsZero() {
+			d.Field(i).Set(f)
+		}
+	}
 }
 
 // ExtractChrome extracts Chrome data in a Takeout export to a
@@ -192,7 +334,7 @@ func ExtractChrome(filename, dir string) error {
 	if err := os.Mkdir(out, 0755); err != nil {
 		return err
 	}
-	return ex.Walk(func(f archive.File) error {
+	return ex.Walk(func(f archive.File, kind Kind) error {
 		dir, base := filepath.Split(f.Name())
 		if dir != "Takeout/Chrome/" {
 			return nil
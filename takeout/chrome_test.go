@@ -0,0 +1,138 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package takeout
+
+import (
+	"archive/zip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/andrewarchi/browser"
+	"github.com/andrewarchi/browser/jsonutil"
+)
+
+func TestParseChromeSkipUnknown(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "takeout-20210218T150405Z-001.zip")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, file := range []struct{ name, data string }{
+		{"Takeout/Chrome/SearchEngines.json", `{"Search Engines": [{"short_name": "Wiki", "keyword": "w"}]}`},
+		{"Takeout/Chrome/Reading List.json", `{"Reading List": []}`},
+		{"Takeout/Chrome/Dictionary.csv", "word\n"},
+		{"Takeout/Chrome/Profile 1/SearchEngines.json", `{"Search Engines": [{"short_name": "Nested", "keyword": "n"}]}`},
+	} {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(file.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ParseChrome(filename)
+	var errs browser.Errors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("got error %v, want 2 errors", err)
+	}
+	if len(data.SearchEngines) != 1 || len(data.Unknown) != 0 {
+		t.Errorf("got %+v", data)
+	}
+
+	data, err = ParseChromeOptions(filename, ChromeOptions{SkipUnknown: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []UnknownFile{
+		{"Takeout/Chrome/Reading List.json", 20, Kind{Product: "Chrome", Format: FormatJSON}},
+		{"Takeout/Chrome/Dictionary.csv", 5, Kind{Product: "Chrome", DataType: "Dictionary", Format: FormatCSV}},
+	}
+	if !reflect.DeepEqual(data.Unknown, want) {
+		t.Errorf("got unknown files:\n%+v\nwant:\n%+v", data.Unknown, want)
+	}
+	if len(data.SearchEngines) != 1 || data.SearchEngines[0].Keyword != "w" {
+		t.Errorf("got search engines %+v", data.SearchEngines)
+	}
+
+	_, err = ParseChromeOptions(filename, ChromeOptions{Options: browser.Options{OnError: browser.FailFast}})
+	if err == nil || errors.As(err, &errs) {
+		t.Errorf("got error %v, want first error only", err)
+	}
+
+	dir := t.TempDir()
+	if err := ExtractChrome(filename, dir); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "takeout-20210218T150405Z", "SearchEngines.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"w"`) {
+		t.Errorf("nested file overwrote SearchEngines.json: %s", b)
+	}
+}
+
+func TestHistoryByDevice(t *testing.T) {
+	phone, laptop := jsonutil.Base64("phone-guid"), jsonutil.Base64("laptop-guid")
+	data := &Chrome{
+		BrowserHistory: []Visit{
+			{URL: "https://example.com/1", ClientID: phone},
+			{URL: "https://example.com/2", ClientID: laptop},
+			{URL: "https://example.com/3"},
+			{URL: "https://example.com/4", ClientID: jsonutil.Base64("unlisted")},
+			{URL: "https://example.com/5", ClientID: phone},
+			{URL: "https://example.com/6", ClientID: jsonutil.Base64{0xff, 0xfe}},
+		},
+		DeviceInfo: []Device{
+			{CacheGUID: phone.String(), ClientName: "Pixel 4"},
+			{CacheGUID: laptop.String(), ClientName: "laptop"},
+			{CacheGUID: phone.String(), ClientName: "duplicate"},
+			{CacheGUID: "not base64!", ClientName: "invalid"},
+		},
+	}
+	for i, want := range []string{"Pixel 4", "laptop", "", "", "Pixel 4", ""} {
+		var got string
+		if d := data.Device(&data.BrowserHistory[i]); d != nil {
+			got = d.ClientName
+		}
+		if got != want {
+			t.Errorf("visit %d: got device %q, want %q", i+1, got, want)
+		}
+	}
+
+	byDevice := data.HistoryByDevice()
+	urls := make(map[string][]string)
+	for key, visits := range byDevice {
+		for _, v := range visits {
+			urls[key] = append(urls[key], v.URL)
+		}
+	}
+	want := map[string][]string{
+		phone.String():                       {"https://example.com/1", "https://example.com/5"},
+		laptop.String():                      {"https://example.com/2"},
+		"":                                   {"https://example.com/3"},
+		jsonutil.Base64("unlisted").String(): {"https://example.com/4"},
+		jsonutil.Base64{0xff, 0xfe}.String(): {"https://example.com/6"},
+	}
+	if !reflect.DeepEqual(urls, want) {
+		t.Errorf("got:\n%v\nwant:\n%v", urls, want)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package takeout
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/andrewarchi/archive"
+)
+
+type exportFile struct {
+	name string
+	data []byte
+}
+
+// exportFiles generates Takeout files with about size bytes of
+// history.
+func exportFiles(size int) []exportFile {
+	var files []exportFile
+	for i := 0; size > 0; i++ {
+		var b strings.Builder
+		b.WriteString(`{"Browser History": [`)
+		for j := 0; b.Len() < 1<<20 && b.Len() < size; j++ {
+			if j != 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, `{"page_transition": "LINK", "title": "Article %d - Example News", "url": "https://www.example.com/articles/%d?id=%d", "client_id": "dGVzdA==", "time_usec": %d}`,
+				j%5000, j%5000, j, 1613606400000000+j*1234567)
+		}
+		b.WriteString("]}")
+		files = append(files, exportFile{fmt.Sprintf("Takeout/Chrome/BrowserHistory-%d.json", i), []byte(b.String())})
+		size -= b.Len()
+	}
+	return files
+}
+
+// writeExport writes files to the first part of a zip or tgz export.
+func writeExport(tb testing.TB, dir, ext string, files []exportFile) string {
+	filename := filepath.Join(dir, "takeout-20210218T150405Z-001."+ext)
+	f, err := os.Create(filename)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+	if ext == "zip" {
+		zw := zip.NewWriter(f)
+		for _, file := range files {
+			w, err := zw.Create(file.name)
+			if err != nil {
+				tb.Fatal(err)
+			}
+			w.Write(file.data)
+		}
+		if err := zw.Close(); err != nil {
+			tb.Fatal(err)
+		}
+	} else {
+		gw := gzip.NewWriter(f)
+		tw := tar.NewWriter(gw)
+		for _, file := range files {
+			h := &tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.data)), Typeflag: tar.TypeReg}
+			if err := tw.WriteHeader(h); err != nil {
+				tb.Fatal(err)
+			}
+			tw.Write(file.data)
+		}
+		if err := tw.Close(); err != nil {
+			tb.Fatal(err)
+		}
+		if err := gw.Close(); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	return filename
+}
+
+func readExport(filename string) ([]exportFile, error) {
+	ex, err := NewExport(filename)
+	if err != nil {
+		return nil, err
+	}
+	var files []exportFile
+	err = ex.Walk(func(f archive.File, kind Kind) error {
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		files = append(files, exportFile{f.Name(), b})
+		return nil
+	})
+	return files, err
+}
+
+func TestWalkTgz(t *testing.T) {
+	files := exportFiles(3 << 20)
+	for _, ext := range []string{"zip", "tgz"} {
+		got, err := readExport(writeExport(t, t.TempDir(), ext, files))
+		if err != nil {
+			t.Fatalf("%s: %v", ext, err)
+		}
+		if !reflect.DeepEqual(got, files) {
+			t.Errorf("%s: files differ", ext)
+		}
+	}
+
+	// A truncated part is an error, rather than a short walk.
+	filename := writeExport(t, t.TempDir(), "tgz", files)
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(filename, info.Size()/2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readExport(filename); err == nil {
+		t.Error("no error for truncated tgz")
+	}
+}
+
+// The benchmarks walk an export of 64 MiB of history and read each
+// file, as parsers do. BenchmarkWalkTgz, which decompresses with pgzip,
+// should stay ahead of BenchmarkWalkTgzSerial, which decompresses with
+// gzip in the walking goroutine. Since every file is read, zip has no
+// advantage here; in real exports, most files are skipped, which zip
+// does without decompressing them.
+
+func BenchmarkWalkZip(b *testing.B) {
+	benchmarkWalk(b, "zip", walkPart)
+}
+
+func BenchmarkWalkTgz(b *testing.B) {
+	benchmarkWalk(b, "tgz", walkPart)
+}
+
+func BenchmarkWalkTgzSerial(b *testing.B) {
+	benchmarkWalk(b, "tgz", archive.Walk)
+}
+
+func benchmarkWalk(b *testing.B, ext string, walkPart func(string, archive.WalkFunc) error) {
+	files := exportFiles(64 << 20)
+	filename := writeExport(b, b.TempDir(), ext, files)
+	var size int64
+	for _, f := range files {
+		size += int64(len(f.data))
+	}
+	b.SetBytes(size)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := walkPart(filename, func(f archive.File) error {
+			r, err := f.Open()
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			_, err = io.Copy(ioutil.Discard, r)
+			return err
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package takeout
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/andrewarchi/archive"
+)
+
+// Kind classifies a file in a Takeout export.
+type Kind struct {
+	Product  string // top-level directory, e.g. "Chrome", "My Activity", "YouTube and YouTube Music"
+	DataType string // e.g. "BrowserHistory", "Bookmarks"; empty when unknown
+	Format   Format
+}
+
+// Format is the file format of a file in a Takeout export.
+type Format uint8
+
+// Values for Format:
+const (
+	FormatUnknown Format = iota
+	FormatJSON
+	FormatHTML
+	FormatCSV
+	FormatMbox
+	FormatICS
+	FormatVCF
+	FormatKML
+	FormatImage
+	FormatVideo
+)
+
+// WalkFunc is the type of function that is called for each file
+// visited by Export.Walk.
+type WalkFunc func(f archive.File, kind Kind) error
+
+// kindPatterns classifies the data type of files by path within the
+// Takeout directory. The first matching pattern is used.
+var kindPatterns = []struct {
+	Pattern  *regexp.Regexp
+	DataType string
+}{
+	{regexp.MustCompile(`^archive_browser\.html$`), "Index"},
+	{regexp.MustCompile(`^Chrome/Autofill\.json$`), "Autofill"},
+	{regexp.MustCompile(`^Chrome/Bookmarks\.html$`), "Bookmarks"},
+	{regexp.MustCompile(`^Chrome/BrowserHistory\.json$`), "BrowserHistory"},
+	{regexp.MustCompile(`^Chrome/Device Information\.json$`), "DeviceInformation"},
+	{regexp.MustCompile(`^Chrome/Dictionary\.csv$`), "Dictionary"},
+	{regexp.MustCompile(`^Chrome/Extensions\.json$`), "Extensions"},
+	{regexp.MustCompile(`^Chrome/SearchEngines\.json$`), "SearchEngines"},
+	{regexp.MustCompile(`^Chrome/SyncSettings\.json$`), "SyncSettings"},
+	{regexp.MustCompile(`^My Activity/[^/]+/MyActivity\.(?:html|json)$`), "MyActivity"},
+	{regexp.MustCompile(`^YouTube and YouTube Music/history/watch-history\.(?:html|json)$`), "WatchHistory"},
+	{regexp.MustCompile(`^YouTube and YouTube Music/history/search-history\.(?:html|json)$`), "SearchHistory"},
+	{regexp.MustCompile(`^YouTube and YouTube Music/playlists/`), "Playlists"},
+	{regexp.MustCompile(`^YouTube and YouTube Music/subscriptions/`), "Subscriptions"},
+	{regexp.MustCompile(`^Location History/Semantic Location History/`), "SemanticLocationHistory"},
+	{regexp.MustCompile(`^Location History/(?:Location History|Records)\.json$`), "LocationHistory"},
+	{regexp.MustCompile(`^Mail/[^/]+\.mbox$`), "Mail"},
+	{regexp.MustCompile(`^Contacts/[^/]+/[^/]+\.vcf$`), "Contacts"},
+	{regexp.MustCompile(`^Calendar/[^/]+\.ics$`), "Calendar"},
+	{regexp.MustCompile(`^Keep/[^/]+\.(?:html|json)$`), "Notes"},
+	{regexp.MustCompile(`^Google Photos/`), "Photos"},
+	{regexp.MustCompile(`^Drive/`), "Drive"},
+}
+
+var formatExts = map[string]Format{
+	".json": FormatJSON,
+	".html": FormatHTML,
+	".csv":  FormatCSV,
+	".mbox": FormatMbox,
+	".ics":  FormatICS,
+	".vcf":  FormatVCF,
+	".kml":  FormatKML,
+	".jpg":  FormatImage,
+	".jpeg": FormatImage,
+	".png":  FormatImage,
+	".gif":  FormatImage,
+	".heic": FormatImage,
+	".webp": FormatImage,
+	".mp4":  FormatVideo,
+	".mov":  FormatVideo,
+}
+
+// Classify classifies a file by its path in a Takeout export, such as
+// "Takeout/Chrome/BrowserHistory.json". Files outside of the Takeout
+// directory have an empty product.
+func Classify(name string) Kind {
+	var kind Kind
+	kind.Format = formatExts[strings.ToLower(path.Ext(name))]
+	rel := strings.TrimPrefix(name, "Takeout/")
+	if rel == name {
+		return kind
+	}
+	if i := strings.IndexByte(rel, '/'); i != -1 {
+		kind.Product = rel[:i]
+	}
+	for _, p := range kindPatterns {
+		if p.Pattern.MatchString(rel) {
+			kind.DataType = p.DataType
+			break
+		}
+	}
+	return kind
+}
+
+func (f Format) String() string {
+	switch f {
+	case FormatUnknown:
+		return "unknown"
+	case FormatJSON:
+		return "json"
+	case FormatHTML:
+		return "html"
+	case FormatCSV:
+		return "csv"
+	case FormatMbox:
+		return "mbox"
+	case FormatICS:
+		return "ics"
+	case FormatVCF:
+		return "vcf"
+	case FormatKML:
+		return "kml"
+	case FormatImage:
+		return "image"
+	case FormatVideo:
+		return "video"
+	default:
+		return fmt.Sprintf("format(%d)", uint8(f))
+	}
+}
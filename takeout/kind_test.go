@@ -0,0 +1,53 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package takeout
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		want Kind
+	}{
+		{"Takeout/archive_browser.html", Kind{"", "Index", FormatHTML}},
+		{"Takeout/Chrome/Autofill.json", Kind{"Chrome", "Autofill", FormatJSON}},
+		{"Takeout/Chrome/Bookmarks.html", Kind{"Chrome", "Bookmarks", FormatHTML}},
+		{"Takeout/Chrome/BrowserHistory.json", Kind{"Chrome", "BrowserHistory", FormatJSON}},
+		{"Takeout/Chrome/Device Information.json", Kind{"Chrome", "DeviceInformation", FormatJSON}},
+		{"Takeout/Chrome/Dictionary.csv", Kind{"Chrome", "Dictionary", FormatCSV}},
+		{"Takeout/Chrome/Extensions.json", Kind{"Chrome", "Extensions", FormatJSON}},
+		{"Takeout/Chrome/SearchEngines.json", Kind{"Chrome", "SearchEngines", FormatJSON}},
+		{"Takeout/Chrome/SyncSettings.json", Kind{"Chrome", "SyncSettings", FormatJSON}},
+		{"Takeout/Chrome/Reading List.json", Kind{"Chrome", "", FormatJSON}},
+		{"Takeout/Chrome/Profile 1/BrowserHistory.json", Kind{"Chrome", "", FormatJSON}},
+		{"Takeout/My Activity/Chrome/MyActivity.html", Kind{"My Activity", "MyActivity", FormatHTML}},
+		{"Takeout/My Activity/Search/MyActivity.json", Kind{"My Activity", "MyActivity", FormatJSON}},
+		{"Takeout/My Activity/MyActivity.html", Kind{"My Activity", "", FormatHTML}},
+		{"Takeout/YouTube and YouTube Music/history/watch-history.json", Kind{"YouTube and YouTube Music", "WatchHistory", FormatJSON}},
+		{"Takeout/YouTube and YouTube Music/history/search-history.html", Kind{"YouTube and YouTube Music", "SearchHistory", FormatHTML}},
+		{"Takeout/YouTube and YouTube Music/playlists/Liked.csv", Kind{"YouTube and YouTube Music", "Playlists", FormatCSV}},
+		{"Takeout/YouTube and YouTube Music/subscriptions/subscriptions.csv", Kind{"YouTube and YouTube Music", "Subscriptions", FormatCSV}},
+		{"Takeout/Location History/Semantic Location History/2021/2021_FEBRUARY.json", Kind{"Location History", "SemanticLocationHistory", FormatJSON}},
+		{"Takeout/Location History/Records.json", Kind{"Location History", "LocationHistory", FormatJSON}},
+		{"Takeout/Location History/Location History.json", Kind{"Location History", "LocationHistory", FormatJSON}},
+		{"Takeout/Mail/All mail Including Spam and Trash.mbox", Kind{"Mail", "Mail", FormatMbox}},
+		{"Takeout/Contacts/My Contacts/My Contacts.vcf", Kind{"Contacts", "Contacts", FormatVCF}},
+		{"Takeout/Calendar/user@example.com.ics", Kind{"Calendar", "Calendar", FormatICS}},
+		{"Takeout/Keep/Note.html", Kind{"Keep", "Notes", FormatHTML}},
+		{"Takeout/Google Photos/Photos from 2021/IMG_0001.JPG", Kind{"Google Photos", "Photos", FormatImage}},
+		{"Takeout/Google Photos/Photos from 2021/VID_0001.mp4", Kind{"Google Photos", "Photos", FormatVideo}},
+		{"Takeout/Drive/Notes.txt", Kind{"Drive", "Drive", FormatUnknown}},
+		{"Takeout/Fit/Daily activity metrics.csv", Kind{"Fit", "", FormatCSV}},
+		{"Takeout/README", Kind{}},
+		{"Chrome/BrowserHistory.json", Kind{"", "", FormatJSON}},
+		{"", Kind{}},
+	} {
+		if got := Classify(tt.name); got != tt.want {
+			t.Errorf("Classify(%q) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package browserexport reads the history saved by browserexport
+// (https://github.com/seanbreckenridge/browserexport): the database
+// copies written by "browserexport save" and the JSON written by
+// "browserexport merge --json".
+package browserexport
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser/jsonutil/timefmt"
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Visit is a page visit, as in the Visit model of browserexport.
+type Visit struct {
+	URL          string
+	Time         time.Time // UTC
+	Title        string
+	Description  string
+	PreviewImage string
+	Duration     time.Duration // time on the page, when known
+	Browser      string        // browser schema of the database, empty for JSON
+}
+
+// Browser schemas of saved databases:
+const (
+	BrowserChromium = "chromium" // Chrome, Chromium, Brave, Edge, and others
+	BrowserFirefox  = "firefox"  // Firefox, Waterfox, and others
+	BrowserSafari   = "safari"
+)
+
+// cocoaEpoch is the Unix time of the Core Data epoch, 2001-01-01, used
+// by Safari.
+const cocoaEpoch = 978307200
+
+// ParseDatabase reads the visits in a database saved by "browserexport
+// save", which is a copy of the history database of the browser. The
+// schema is detected from its tables. Visits are ordered by time.
+func ParseDatabase(filename string) ([]Visit, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	browser, err := detect(db)
+	if err != nil {
+		return nil, fmt.Errorf("browserexport: %w", err)
+	}
+	var visits []Visit
+	switch browser {
+	case BrowserChromium:
+		err = sqliteutil.Query(db, `
+			SELECT u.url, v.visit_time, u.title, v.visit_duration
+			FROM visits v JOIN urls u ON v.url = u.id
+			ORDER BY v.visit_time, v.id`, func(rows *sql.Rows) error {
+			var v Visit
+			var t, duration int64
+			var title sql.NullString
+			if err := rows.Scan(&v.URL, &t, &title, &duration); err != nil {
+				return err
+			}
+			v.Time = timefmt.FromInt(t, 0, timefmt.Micro, timefmt.Windows)
+			v.Title = title.String
+			v.Duration = time.Duration(duration) * time.Microsecond
+			visits = append(visits, v)
+			return nil
+		})
+	case BrowserFirefox:
+		cols, cerr := sqliteutil.Columns(db, "moz_places")
+		if cerr != nil {
+			return nil, fmt.Errorf("browserexport: %w", cerr)
+		}
+		description, preview := "NULL", "NULL"
+		for _, col := range cols {
+			switch col {
+			case "description":
+				description = "p.description"
+			case "preview_image_url":
+				preview = "p.preview_image_url"
+			}
+		}
+		err = sqliteutil.Query(db, `
+			SELECT p.url, v.visit_date, p.title, `+description+`, `+preview+`
+			FROM moz_historyvisits v JOIN moz_places p ON v.place_id = p.id
+			ORDER BY v.visit_date, v.id`, func(rows *sql.Rows) error {
+			var v Visit
+			var t int64
+			var title, desc, image sql.NullString
+			if err := rows.Scan(&v.URL, &t, &title, &desc, &image); err != nil {
+				return err
+			}
+			v.Time = timefmt.FromInt(t, 0, timefmt.Micro, timefmt.Unix)
+			v.Title = title.String
+			v.Description = desc.String
+			v.PreviewImage = image.String
+			visits = append(visits, v)
+			return nil
+		})
+	case BrowserSafari:
+		err = sqliteutil.Query(db, `
+			SELECT i.url, v.visit_time, v.title
+			FROM history_visits v JOIN history_items i ON v.history_item = i.id
+			ORDER BY v.visit_time, v.id`, func(rows *sql.Rows) error {
+			var v Visit
+			var t float64
+			var title sql.NullString
+			if err := rows.Scan(&v.URL, &t, &title); err != nil {
+				return err
+			}
+			sec, frac := math.Modf(t)
+			v.Time = time.Unix(cocoaEpoch+int64(sec), int64(frac*1e9)).UTC().Round(time.Microsecond)
+			v.Title = title.String
+			visits = append(visits, v)
+			return nil
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("browserexport: %s: %w", browser, err)
+	}
+	for i := range visits {
+		visits[i].Browser = browser
+	}
+	return visits, nil
+}
+
+// detect returns the browser schema of a database by its tables.
+func detect(db *sql.DB) (string, error) {
+	for _, s := range []struct {
+		browser string
+		tables  []string
+	}{
+		{BrowserChromium, []string{"urls", "visits"}},
+		{BrowserFirefox, []string{"moz_places", "moz_historyvisits"}},
+		{BrowserSafari, []string{"history_items", "history_visits"}},
+	} {
+		found := true
+		for _, table := range s.tables {
+			ok, err := sqliteutil.HasTable(db, table)
+			if err != nil {
+				return "", err
+			}
+			found = found && ok
+		}
+		if found {
+			return s.browser, nil
+		}
+	}
+	return "", errors.New("unrecognized history database")
+}
+
+// jsonVisit is a visit in the output of "browserexport merge --json".
+type jsonVisit struct {
+	URL      string `json:"url"`
+	DT       string `json:"dt"`
+	Metadata *struct {
+		Title        string   `json:"title"`
+		Description  string   `json:"description"`
+		PreviewImage string   `json:"preview_image"`
+		Duration     *float64 `json:"duration"` // seconds
+	} `json:"metadata"`
+}
+
+// ParseJSON reads the visits written by "browserexport merge --json",
+// which is an array of visits with times in ISO 8601 format. Times
+// without an offset are UTC. Visits are ordered by time.
+func ParseJSON(r io.Reader) ([]Visit, error) {
+	var jv []jsonVisit
+	if err := json.NewDecoder(r).Decode(&jv); err != nil {
+		return nil, fmt.Errorf("browserexport: %w", err)
+	}
+	visits := make([]Visit, len(jv))
+	for i, v := range jv {
+		t, err := parseTime(v.DT)
+		if err != nil {
+			return nil, fmt.Errorf("browserexport: %s: %w", v.URL, err)
+		}
+		visits[i] = Visit{URL: v.URL, Time: t}
+		if m := v.Metadata; m != nil {
+			visits[i].Title = m.Title
+			visits[i].Description = m.Description
+			visits[i].PreviewImage = m.PreviewImage
+			if m.Duration != nil {
+				visits[i].Duration = time.Duration(*m.Duration * float64(time.Second))
+			}
+		}
+	}
+	sort.SliceStable(visits, func(i, j int) bool {
+		return visits[i].Time.Before(visits[j].Time)
+	})
+	return visits, nil
+}
+
+// parseTime parses a time formatted by Python datetime.isoformat.
+func parseTime(s string) (time.Time, error) {
+	s = strings.Replace(s, " ", "T", 1)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package browserexport
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // register sqlite3 driver
+)
+
+func TestParseDatabase(t *testing.T) {
+	tests := []struct {
+		schema string
+		want   []Visit
+	}{{
+		`CREATE TABLE urls (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR);
+		CREATE TABLE visits (id INTEGER PRIMARY KEY, url INTEGER NOT NULL, visit_time INTEGER NOT NULL,
+			visit_duration INTEGER DEFAULT 0 NOT NULL);
+		INSERT INTO urls VALUES (1, 'https://example.com/', 'Example');
+		INSERT INTO visits VALUES (1, 1, 13258000000000000, 2000000), (2, 1, 13257000000000000, 0);`,
+		[]Visit{
+			{URL: "https://example.com/", Time: time.Date(2021, 2, 5, 12, 0, 0, 0, time.UTC), Title: "Example", Browser: BrowserChromium},
+			{URL: "https://example.com/", Time: time.Date(2021, 2, 17, 1, 46, 40, 0, time.UTC), Title: "Example", Duration: 2 * time.Second, Browser: BrowserChromium},
+		},
+	}, {
+		`CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR, description TEXT);
+		CREATE TABLE moz_historyvisits (id INTEGER PRIMARY KEY, place_id INTEGER, visit_date INTEGER);
+		INSERT INTO moz_places VALUES (1, 'https://example.org/', NULL, 'About');
+		INSERT INTO moz_historyvisits VALUES (1, 1, 1613606400000000);`,
+		[]Visit{
+			{URL: "https://example.org/", Time: time.Date(2021, 2, 18, 0, 0, 0, 0, time.UTC), Description: "About", Browser: BrowserFirefox},
+		},
+	}, {
+		`CREATE TABLE history_items (id INTEGER PRIMARY KEY, url TEXT NOT NULL UNIQUE);
+		CREATE TABLE history_visits (id INTEGER PRIMARY KEY, history_item INTEGER NOT NULL, visit_time REAL NOT NULL, title TEXT);
+		INSERT INTO history_items VALUES (1, 'https://example.net/');
+		INSERT INTO history_visits VALUES (1, 1, 635299200.5, 'Net');`,
+		[]Visit{
+			{URL: "https://example.net/", Time: time.Date(2021, 2, 18, 0, 0, 0, 5e8, time.UTC), Title: "Net", Browser: BrowserSafari},
+		},
+	}}
+	for i, tt := range tests {
+		filename := filepath.Join(t.TempDir(), "history.sqlite")
+		db, err := sql.Open("sqlite3", filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(tt.schema); err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+		visits, err := ParseDatabase(filename)
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(visits, tt.want) {
+			t.Errorf("#%d: got:\n%+v\nwant:\n%+v", i, visits, tt.want)
+		}
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	visits, err := ParseJSON(strings.NewReader(`[
+		{"url": "https://example.com/b", "dt": "2021-02-18T01:00:00.250000+01:00", "metadata": {"title": "B", "description": null, "preview_image": null, "duration": 1.5}},
+		{"url": "https://example.com/a", "dt": "2021-02-17 23:00:00", "metadata": null}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Visit{
+		{URL: "https://example.com/a", Time: time.Date(2021, 2, 17, 23, 0, 0, 0, time.UTC)},
+		{URL: "https://example.com/b", Time: time.Date(2021, 2, 18, 0, 0, 0, 25e7, time.UTC), Title: "B", Duration: 1500 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(visits, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", visits, want)
+	}
+}
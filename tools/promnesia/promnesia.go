@@ -0,0 +1,92 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package promnesia reads the database of visits indexed by Promnesia
+// (https://github.com/karlicoss/promnesia), which collects visits from
+// browsers and other sources, such as notes and chat logs.
+package promnesia
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/browser/sqliteutil"
+)
+
+// Promnesia database schema:
+// https://github.com/karlicoss/promnesia/blob/master/src/promnesia/database/common.py
+//
+// The database is written by "promnesia index", usually to
+// ~/.local/share/promnesia/promnesia.sqlite. Times are stored as text
+// in ISO 8601 format with an offset.
+
+// Visit is a row in the visits table.
+type Visit struct {
+	NormURL      string // normalized URL, without scheme, "www.", or tracking parameters
+	OrigURL      string
+	Time         time.Time // in the recorded offset
+	LocatorTitle string    // title of where the visit was found, e.g. a file path
+	LocatorHref  string    // link to where the visit was found, e.g. "editor:///notes.org:12"
+	Src          string    // indexer that found it, e.g. "browser" or "org"
+	Context      string    // surrounding text, when found in notes
+	Duration     time.Duration
+}
+
+// ParseDatabase reads the visits in promnesia.sqlite, ordered by time.
+func ParseDatabase(filename string) ([]Visit, error) {
+	db, err := sqliteutil.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var visits []Visit
+	err = sqliteutil.Query(db, `
+		SELECT norm_url, orig_url, dt, locator_title, locator_href, src, context, duration
+		FROM visits`, func(rows *sql.Rows) error {
+		var v Visit
+		var dt string
+		var locTitle, locHref, src, context sql.NullString
+		var duration sql.NullInt64
+		if err := rows.Scan(&v.NormURL, &v.OrigURL, &dt, &locTitle, &locHref, &src, &context, &duration); err != nil {
+			return err
+		}
+		t, err := parseTime(dt)
+		if err != nil {
+			return fmt.Errorf("%s: %w", v.OrigURL, err)
+		}
+		v.Time = t
+		v.LocatorTitle = locTitle.String
+		v.LocatorHref = locHref.String
+		v.Src = src.String
+		v.Context = context.String
+		v.Duration = time.Duration(duration.Int64) * time.Second
+		visits = append(visits, v)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("promnesia: %w", err)
+	}
+	// Times have varying offsets, so are sorted after parsing.
+	sort.SliceStable(visits, func(i, j int) bool {
+		return visits[i].Time.Before(visits[j].Time)
+	})
+	return visits, nil
+}
+
+// parseTime parses a time formatted by Python datetime.isoformat.
+// Times without an offset are UTC.
+func parseTime(s string) (time.Time, error) {
+	s = strings.Replace(s, " ", "T", 1)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
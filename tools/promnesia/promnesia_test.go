@@ -0,0 +1,60 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package promnesia
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // register sqlite3 driver
+)
+
+func TestParseDatabase(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "promnesia.sqlite")
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE visits (norm_url VARCHAR, orig_url VARCHAR, dt VARCHAR,
+		locator_title VARCHAR, locator_href VARCHAR, src VARCHAR, context VARCHAR, duration INTEGER);
+		INSERT INTO visits VALUES
+			('example.com', 'https://www.example.com/', '2021-02-18T01:00:00+01:00', '/home/user/notes.org',
+				'editor:///home/user/notes.org:3', 'org', 'See https://www.example.com/', NULL),
+			('example.org', 'https://example.org/', '2021-02-17T23:30:00.500000+00:00', 'History',
+				'file:///home/user/History', 'browser', NULL, 30);`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	visits, err := ParseDatabase(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cet := time.FixedZone("", 60*60)
+	want := []Visit{
+		{"example.org", "https://example.org/", time.Date(2021, 2, 17, 23, 30, 0, 5e8, time.FixedZone("", 0)),
+			"History", "file:///home/user/History", "browser", "", 30 * time.Second},
+		{"example.com", "https://www.example.com/", time.Date(2021, 2, 18, 1, 0, 0, 0, cet),
+			"/home/user/notes.org", "editor:///home/user/notes.org:3", "org", "See https://www.example.com/", 0},
+	}
+	if len(visits) != len(want) {
+		t.Fatalf("got %d visits, want %d", len(visits), len(want))
+	}
+	for i := range want {
+		got := visits[i]
+		if !got.Time.Equal(want[i].Time) {
+			t.Errorf("#%d: got time %v, want %v", i, got.Time, want[i].Time)
+		}
+		got.Time = want[i].Time
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("#%d: got:\n%+v\nwant:\n%+v", i, got, want[i])
+		}
+	}
+}
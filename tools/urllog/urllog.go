@@ -0,0 +1,167 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package urllog reads plain-text logs of visited URLs, as kept by
+// history tools such as HistoryHound and by shell hooks that log to
+// zsh extended history format.
+package urllog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry is a URL in a log.
+type Entry struct {
+	URL   string
+	Title string    // title, when logged after the URL
+	Time  time.Time // zero when the line has no time
+	Line  int       // line number, from 1
+}
+
+// Parse reads the URLs in a log, in order. Each line is one of:
+//
+//	: 1613606400:0;open https://example.com/     zsh extended history
+//	1613606400<TAB>https://example.com/<TAB>Title  Unix time, URL, and title
+//	2021-02-18T00:00:00Z https://example.com/ Title
+//	2021-02-18 00:00:00<TAB>https://example.com/
+//	https://example.com/
+//
+// Fields are separated by tabs or, when a line has no tabs, by spaces,
+// in which case the title is the rest of the line. Times are Unix
+// seconds, with an optional fraction, or RFC 3339, or date and time
+// without an offset, which are in loc, or UTC when loc is nil. Each URL
+// in a zsh history command is an entry, and commands without URLs,
+// blank lines, and lines starting with "#" are skipped.
+func Parse(r io.Reader, loc *time.Location) ([]Entry, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	var entries []Entry
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	line := 0
+	for s.Scan() {
+		line++
+		text := strings.TrimRight(s.Text(), "\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if strings.HasPrefix(text, ": ") {
+			t, cmd, err := parseZsh(text)
+			if err != nil {
+				return nil, fmt.Errorf("urllog: line %d: %w", line, err)
+			}
+			for _, u := range findURLs(cmd) {
+				entries = append(entries, Entry{URL: u, Time: t, Line: line})
+			}
+			continue
+		}
+		e, err := parseLine(text, loc)
+		if err != nil {
+			return nil, fmt.Errorf("urllog: line %d: %w", line, err)
+		}
+		e.Line = line
+		entries = append(entries, e)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("urllog: %w", err)
+	}
+	return entries, nil
+}
+
+// parseZsh parses a line in zsh extended history format,
+// ": <start>:<elapsed>;<command>".
+func parseZsh(text string) (time.Time, string, error) {
+	i := strings.IndexByte(text, ';')
+	if i == -1 {
+		return time.Time{}, "", errors.New("zsh history line without command")
+	}
+	meta := strings.SplitN(text[2:i], ":", 2)
+	sec, err := strconv.ParseInt(meta[0], 10, 64)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("zsh history time: %w", err)
+	}
+	return time.Unix(sec, 0).UTC(), text[i+1:], nil
+}
+
+// parseLine parses a line of a time, URL, and title, or a bare URL.
+func parseLine(text string, loc *time.Location) (Entry, error) {
+	var fields []string
+	if strings.Contains(text, "\t") {
+		fields = strings.Split(text, "\t")
+	} else {
+		fields = strings.SplitN(strings.TrimSpace(text), " ", 3)
+		// Join a date and time separated by a space.
+		if len(fields) >= 2 && !isURL(fields[1]) && !isURL(fields[0]) {
+			fields = append([]string{fields[0] + " " + fields[1]}, strings.SplitN(strings.Join(fields[2:], " "), " ", 2)...)
+		}
+	}
+	var e Entry
+	if !isURL(fields[0]) {
+		t, err := parseTime(strings.TrimSpace(fields[0]), loc)
+		if err != nil {
+			return e, err
+		}
+		e.Time = t
+		fields = fields[1:]
+	}
+	if len(fields) == 0 || !isURL(fields[0]) {
+		return e, errors.New("no URL")
+	}
+	e.URL = strings.TrimSpace(fields[0])
+	if len(fields) > 1 {
+		e.Title = strings.TrimSpace(strings.Join(fields[1:], " "))
+	}
+	return e, nil
+}
+
+func parseTime(s string, loc *time.Location) (time.Time, error) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e6))*1e3).UTC(), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t.UTC(), nil
+	}
+	s = strings.Replace(s, "T", " ", 1)
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05.999999999", s, loc); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// urlPrefixes are the schemes of URLs found in logs.
+var urlPrefixes = []string{"http://", "https://", "ftp://", "file://"}
+
+func isURL(s string) bool {
+	s = strings.TrimSpace(s)
+	for _, p := range urlPrefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// findURLs returns the URLs in a shell command, with surrounding quotes
+// removed.
+func findURLs(cmd string) []string {
+	var urls []string
+	for _, f := range strings.Fields(cmd) {
+		f = strings.Trim(f, `'"<>()`)
+		if isURL(f) {
+			urls = append(urls, f)
+		}
+	}
+	return urls
+}
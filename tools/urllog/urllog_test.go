@@ -0,0 +1,47 @@
+// Copyright (c) 2021 Andrew Archibald
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package urllog
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	log := `# visited URLs
+: 1613606400:0;open 'https://example.com/a' https://example.com/b
+: 1613606401:0;ls -l
+1613606402.5	https://example.com/c	Title C
+
+2021-02-18T00:00:03Z https://example.com/d Title D
+2021-02-17 19:00:04 https://example.com/e
+https://example.com/f
+`
+	est := time.FixedZone("EST", -5*60*60)
+	entries, err := Parse(strings.NewReader(log), est)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2021, 2, 18, 0, 0, 0, 0, time.UTC)
+	want := []Entry{
+		{URL: "https://example.com/a", Time: at, Line: 2},
+		{URL: "https://example.com/b", Time: at, Line: 2},
+		{URL: "https://example.com/c", Title: "Title C", Time: at.Add(2500 * time.Millisecond), Line: 4},
+		{URL: "https://example.com/d", Title: "Title D", Time: at.Add(3 * time.Second), Line: 6},
+		{URL: "https://example.com/e", Time: at.Add(4 * time.Second), Line: 7},
+		{URL: "https://example.com/f", Line: 8},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", entries, want)
+	}
+
+	if _, err := Parse(strings.NewReader("yesterday https://example.com/\n"), nil); err == nil {
+		t.Error("parsed invalid time")
+	}
+}